@@ -56,6 +56,10 @@ type Subcluster struct {
 	Devices []int
 	// Snaps contains the expected snap state for this subcluster.
 	Snaps []ClusterSnap
+	// MaxParallel is the maximum number of devices of this subcluster that
+	// apply changes to their snaps at the same time, in the order of their
+	// device IDs. Zero means that devices do not coordinate.
+	MaxParallel int
 }
 
 // ClusterSnapState describes the relationship of a snap to the cluster.
@@ -251,10 +255,19 @@ func checkClusterSubcluster(subcluster map[string]any) (Subcluster, error) {
 		return Subcluster{}, err
 	}
 
+	maxParallel, err := checkIntWithDefault(subcluster, "max-parallel", 0)
+	if err != nil {
+		return Subcluster{}, err
+	}
+	if _, ok := subcluster["max-parallel"]; ok && maxParallel < 1 {
+		return Subcluster{}, fmt.Errorf(`"max-parallel" header must be >=1: %d`, maxParallel)
+	}
+
 	return Subcluster{
-		Name:    name,
-		Devices: ids,
-		Snaps:   snaps,
+		Name:        name,
+		Devices:     ids,
+		Snaps:       snaps,
+		MaxParallel: maxParallel,
 	}, nil
 }
 
@@ -76,6 +76,7 @@ subclusters:
     name: additional-cluster
     devices:
       - 2
+    max-parallel: 1
     snaps:
       -
         state: removed
@@ -127,6 +128,7 @@ func (cs *clusterSuite) TestDecodeOK(c *C) {
 	c.Check(subclusters[0].Snaps[1].State, Equals, asserts.ClusterSnapStateEvacuated)
 	c.Check(subclusters[0].Snaps[1].Instance, Equals, "evacuated-snap")
 	c.Check(subclusters[0].Snaps[1].Channel, Equals, "edge")
+	c.Check(subclusters[0].MaxParallel, Equals, 0)
 
 	c.Check(subclusters[1].Name, Equals, "additional-cluster")
 	c.Check(subclusters[1].Devices, DeepEquals, []int{2})
@@ -134,6 +136,7 @@ func (cs *clusterSuite) TestDecodeOK(c *C) {
 	c.Check(subclusters[1].Snaps[0].State, Equals, asserts.ClusterSnapStateRemoved)
 	c.Check(subclusters[1].Snaps[0].Instance, Equals, "removed-snap")
 	c.Check(subclusters[1].Snaps[0].Channel, Equals, "24/stable")
+	c.Check(subclusters[1].MaxParallel, Equals, 1)
 }
 
 func (cs *clusterSuite) TestDecodeInvalidTopLevel(c *C) {
@@ -144,7 +147,7 @@ func (cs *clusterSuite) TestDecodeInvalidTopLevel(c *C) {
 		{"cluster-id: bf3675f5-cffa-40f4-a119-7492ccc08e04\n", "cluster-id: \n", `"cluster-id" header should not be empty`},
 		{"sequence: 3\n", "sequence: 0\n", `"sequence" must be >=1: 0`},
 		{"devices:\n  -\n    id: 1\n    device: 9cc45ad6-d01b-4efd-9f76-db55b76c076b.ubuntu-core-24-amd64.canonical\n    addresses:\n      - 192.168.1.10\n      - 10.0.0.10\n  -\n    id: 2\n    device: bc3c0a19-cdad-4cfc-a6f0-85e917bc6280.ubuntu-core-24-amd64.canonical\n    addresses:\n      - 192.168.1.20\n", "devices: not-a-list\n", `"devices" header must be a list`},
-		{"subclusters:\n  -\n    name: default\n    devices:\n      - 1\n      - 2\n    snaps:\n      -\n        state: clustered\n        instance: clustered-snap\n        channel: stable\n      -\n        state: evacuated\n        instance: evacuated-snap\n        channel: edge\n  -\n    name: additional-cluster\n    devices:\n      - 2\n    snaps:\n      -\n        state: removed\n        instance: removed-snap\n        channel: 24/stable\n", "subclusters: not-a-list\n", `"subclusters" header must be a list`},
+		{"subclusters:\n  -\n    name: default\n    devices:\n      - 1\n      - 2\n    snaps:\n      -\n        state: clustered\n        instance: clustered-snap\n        channel: stable\n      -\n        state: evacuated\n        instance: evacuated-snap\n        channel: edge\n  -\n    name: additional-cluster\n    devices:\n      - 2\n    max-parallel: 1\n    snaps:\n      -\n        state: removed\n        instance: removed-snap\n        channel: 24/stable\n", "subclusters: not-a-list\n", `"subclusters" header must be a list`},
 	}
 
 	for _, test := range invalidTests {
@@ -196,7 +199,9 @@ func (cs *clusterSuite) TestDecodeInvalidSubclusters(c *C) {
 		{"    name: additional-cluster\n", "    name: default\n", `"subclusters" field contains duplicate subcluster name "default"`},
 		{"    devices:\n      - 1\n      - 2\n", "    devices: not-a-list\n", `"devices" header must be a list of strings`},
 		{"    snaps:\n      -\n        state: clustered\n        instance: clustered-snap\n        channel: stable\n      -\n        state: evacuated\n        instance: evacuated-snap\n        channel: edge\n", "    snaps: not-a-list\n", `"snaps" header must be a list`},
-		{"      - 2\n    snaps:", "      - 999\n    snaps:", `"subclusters" references unknown device id 999`},
+		{"      - 2\n    max-parallel:", "      - 999\n    max-parallel:", `"subclusters" references unknown device id 999`},
+		{"    max-parallel: 1\n", "    max-parallel: 0\n", `"max-parallel" header must be >=1: 0`},
+		{"    max-parallel: 1\n", "    max-parallel: many\n", `"max-parallel" header is not an integer: many`},
 	}
 
 	for _, test := range invalidTests {
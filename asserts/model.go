@@ -595,7 +595,11 @@ type Model struct {
 	serialAuthority  []string
 	sysUserAuthority []string
 	preseedAuthority []string
-	timestamp        time.Time
+
+	autoRevertAll   bool
+	autoRevertSnaps []string
+
+	timestamp time.Time
 }
 
 // BrandID returns the brand identifier. Same as the authority id.
@@ -776,6 +780,13 @@ func (mod *Model) PreseedAuthority() []string {
 	return mod.preseedAuthority
 }
 
+// AutoRevertSnaps returns the snaps whose refreshes are to be reverted when
+// their health check fails shortly after, as listed by the optional
+// auto-revert header. all is true if it applies to all snaps.
+func (mod *Model) AutoRevertSnaps() (snaps []string, all bool) {
+	return mod.autoRevertSnaps, mod.autoRevertAll
+}
+
 // Timestamp returns the time when the model assertion was issued.
 func (mod *Model) Timestamp() time.Time {
 	return mod.timestamp
@@ -844,6 +855,30 @@ func checkOptionalAuthority(headers map[string]any, name string, brandID string,
 	}
 }
 
+func checkOptionalAutoRevert(headers map[string]any) (snaps []string, all bool, err error) {
+	v, ok := headers["auto-revert"]
+	if !ok {
+		return nil, false, nil
+	}
+	switch x := v.(type) {
+	case string:
+		if x == "*" {
+			return nil, true, nil
+		}
+	case []any:
+		lst, err := checkStringList(headers, "auto-revert")
+		if err == nil {
+			for _, name := range lst {
+				if err := validateSnapName(name, "auto-revert"); err != nil {
+					return nil, false, err
+				}
+			}
+			return lst, false, nil
+		}
+	}
+	return nil, false, fmt.Errorf(`"auto-revert" header must be '*' or a list of snap names`)
+}
+
 func checkOptionalSerialAuthority(headers map[string]any, brandID string) ([]string, error) {
 	const acceptsWildcard = false
 	return checkOptionalAuthority(headers, "serial-authority", brandID, acceptsWildcard)
@@ -1187,6 +1222,11 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		return nil, err
 	}
 
+	autoRevertSnaps, autoRevertAll, err := checkOptionalAutoRevert(assert.headers)
+	if err != nil {
+		return nil, err
+	}
+
 	timestamp, err := checkRFC3339Date(assert.headers, "timestamp")
 	if err != nil {
 		return nil, err
@@ -1222,6 +1262,8 @@ func assembleModel(assert assertionBase) (Assertion, error) {
 		serialAuthority:            serialAuthority,
 		sysUserAuthority:           sysUserAuthority,
 		preseedAuthority:           preseedAuthority,
+		autoRevertAll:              autoRevertAll,
+		autoRevertSnaps:            autoRevertSnaps,
 		timestamp:                  timestamp,
 	}, nil
 }
@@ -550,6 +550,32 @@ func (mods *modelSuite) TestDecodePreseedAuthorityIsOptional(c *C) {
 	c.Check(model.PreseedAuthority(), DeepEquals, []string{"brand-id1", "foo", "bar"})
 }
 
+func (mods *modelSuite) TestDecodeAutoRevertIsOptional(c *C) {
+	withTimestamp := strings.Replace(modelExample, "TSLINE", mods.tsLine, 1)
+	a, err := asserts.Decode([]byte(withTimestamp))
+	c.Assert(err, IsNil)
+	model := a.(*asserts.Model)
+	snaps, all := model.AutoRevertSnaps()
+	c.Check(snaps, HasLen, 0)
+	c.Check(all, Equals, false)
+
+	encoded := strings.Replace(withTimestamp, preseedAuths, preseedAuths+"auto-revert:\n  - foo\n  - bar\n", 1)
+	a, err = asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	model = a.(*asserts.Model)
+	snaps, all = model.AutoRevertSnaps()
+	c.Check(snaps, DeepEquals, []string{"foo", "bar"})
+	c.Check(all, Equals, false)
+
+	encoded = strings.Replace(withTimestamp, preseedAuths, preseedAuths+"auto-revert: *\n", 1)
+	a, err = asserts.Decode([]byte(encoded))
+	c.Assert(err, IsNil)
+	model = a.(*asserts.Model)
+	snaps, all = model.AutoRevertSnaps()
+	c.Check(snaps, HasLen, 0)
+	c.Check(all, Equals, true)
+}
+
 func (mods *modelSuite) TestDecodeKernelTrack(c *C) {
 	withTimestamp := strings.Replace(modelExample, "TSLINE", mods.tsLine, 1)
 	encoded := strings.Replace(withTimestamp, "kernel: baz-linux\n", "kernel: baz-linux=18\n", 1)
@@ -632,6 +658,9 @@ func (mods *modelSuite) TestDecodeInvalid(c *C) {
 		{preseedAuths, "preseed-authority:\n  a: 1\n", `"preseed-authority" header must be a list of account ids`},
 		{preseedAuths, "preseed-authority:\n  - 5_6\n", `"preseed-authority" header must be a list of account ids`},
 		{preseedAuths, "preseed-authority: *\n", `"preseed-authority" header must be a list of account ids`},
+		{preseedAuths, preseedAuths + "auto-revert: all\n", `"auto-revert" header must be '\*' or a list of snap names`},
+		{preseedAuths, preseedAuths + "auto-revert:\n  a: 1\n", `"auto-revert" header must be '\*' or a list of snap names`},
+		{preseedAuths, preseedAuths + "auto-revert:\n  - Foo_\n", `invalid snap name in "auto-revert" header: Foo_`},
 		{reqSnaps, "grade: dangerous\n", `cannot specify a grade for model without the extended snaps header`},
 	}
 
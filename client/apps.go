@@ -56,6 +56,17 @@ type AppInfo struct {
 	Active      bool             `json:"active,omitempty"`
 	CommonID    string           `json:"common-id,omitempty"`
 	Activators  []AppActivator   `json:"activators,omitempty"`
+	Probe       *AppProbe        `json:"liveness-probe,omitempty"`
+}
+
+// AppProbe describes the status of the liveness probe of a service.
+type AppProbe struct {
+	// Status is either "passing" or "failing".
+	Status string `json:"status"`
+	// Failures is the number of probes the service failed in a row.
+	Failures  int       `json:"failures,omitempty"`
+	LastCheck time.Time `json:"last-check"`
+	Message   string    `json:"message,omitempty"`
 }
 
 // MarshalJSON marshals the AppActivator in such a way to retain
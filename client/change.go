@@ -167,6 +167,8 @@ func (c ChangeSelector) String() string {
 		return "ready"
 	case ChangesAll:
 		return "all"
+	case ChangesArchived:
+		return "archived"
 	}
 
 	panic(fmt.Sprintf("unknown ChangeSelector %d", c))
@@ -175,12 +177,18 @@ func (c ChangeSelector) String() string {
 const (
 	ChangesInProgress ChangeSelector = 1 << iota
 	ChangesReady
+	// ChangesArchived selects the changes which were pruned and kept in
+	// the change archive
+	ChangesArchived
 	ChangesAll = ChangesReady | ChangesInProgress
 )
 
 type ChangesOptions struct {
 	SnapName string // if empty, no filtering by name is done
 	Selector ChangeSelector
+	// Since and Kind can only be used with ChangesArchived
+	Since time.Time // if zero, no filtering by ready time is done
+	Kind  string    // if empty, no filtering by kind is done
 }
 
 func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
@@ -192,6 +200,12 @@ func (client *Client) Changes(opts *ChangesOptions) ([]*Change, error) {
 		if opts.SnapName != "" {
 			query.Set("for", opts.SnapName)
 		}
+		if !opts.Since.IsZero() {
+			query.Set("since", opts.Since.Format(time.RFC3339))
+		}
+		if opts.Kind != "" {
+			query.Set("kind", opts.Kind)
+		}
 	}
 
 	var chgds []changeAndData
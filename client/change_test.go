@@ -21,6 +21,7 @@ package client_test
 
 import (
 	"io"
+	"net/url"
 	"time"
 
 	"gopkg.in/check.v1"
@@ -135,6 +136,7 @@ func (cs *clientSuite) TestClientChangesString(c *check.C) {
 		client.ChangesAll:        "all",
 		client.ChangesReady:      "ready",
 		client.ChangesInProgress: "in-progress",
+		client.ChangesArchived:   "archived",
 	} {
 		c.Check(k.String(), check.Equals, v)
 	}
@@ -179,6 +181,34 @@ func (cs *clientSuite) TestClientChanges(c *check.C) {
 
 }
 
+func (cs *clientSuite) TestClientChangesArchived(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [{
+  "id":   "uno",
+  "kind": "foo",
+  "summary": "...",
+  "status": "Done",
+  "ready": true,
+  "tasks": [{"kind": "bar", "summary": "...", "status": "Done", "progress": {"done": 1, "total": 1}}]
+}]}`
+
+	since := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	chgs, err := cs.cli.Changes(&client.ChangesOptions{
+		Selector: client.ChangesArchived,
+		SnapName: "foo",
+		Since:    since,
+		Kind:     "refresh-snap",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(chgs, check.HasLen, 1)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/changes")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": []string{"archived"},
+		"for":    []string{"foo"},
+		"since":  []string{"2026-10-01T12:00:00Z"},
+		"kind":   []string{"refresh-snap"},
+	})
+}
+
 func (cs *clientSuite) TestClientChangesData(c *check.C) {
 	cs.rsp = `{"type": "sync", "result": [{
   "id":   "uno",
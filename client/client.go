@@ -672,6 +672,10 @@ type RefreshInfo struct {
 	Last     string `json:"last,omitempty"`
 	Hold     string `json:"hold,omitempty"`
 	Next     string `json:"next,omitempty"`
+	// Blackout contains the refresh.blackout setting.
+	Blackout string `json:"blackout,omitempty"`
+	// WindowEndBehavior contains the refresh.window-end-behavior setting.
+	WindowEndBehavior string `json:"window-end-behavior,omitempty"`
 }
 
 // SysInfo holds system information
@@ -121,6 +121,9 @@ func ClientAppInfoNotes(app *client.AppInfo) string {
 	if seenDbus {
 		notes = append(notes, "dbus-activated")
 	}
+	if app.Probe != nil {
+		notes = append(notes, "probe-"+app.Probe.Status)
+	}
 	if len(notes) == 0 {
 		return "-"
 	}
@@ -328,4 +328,10 @@ func (*cmdSuite) TestAppStatusNotes(c *C) {
 		},
 	}
 	c.Check(clientutil.ClientAppInfoNotes(&ai), Equals, "user,timer-activated,socket-activated,dbus-activated")
+
+	ai = client.AppInfo{
+		Daemon: "simple",
+		Probe:  &client.AppProbe{Status: "failing", Failures: 2},
+	}
+	c.Check(clientutil.ClientAppInfoNotes(&ai), Equals, "probe-failing")
 }
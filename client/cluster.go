@@ -0,0 +1,241 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+)
+
+// AssembleClusterOptions carries the parameters of a cluster assembly.
+type AssembleClusterOptions struct {
+	// Secret is shared by all the devices assembling the cluster.
+	Secret string `json:"secret"`
+	// Address is the IP address and port this device listens on.
+	Address string `json:"address"`
+	// ExpectedSize is the number of devices in the cluster.
+	ExpectedSize int `json:"expected-size"`
+	// Peers optionally lists the addresses of other devices.
+	Peers []string `json:"peers,omitempty"`
+}
+
+type postClusterData struct {
+	Action string `json:"action"`
+	*AssembleClusterOptions
+}
+
+// AssembleCluster starts assembling a cluster with other devices. Once the
+// change is done, its "cluster-assertion" data holds the headers of the
+// resulting cluster assertion.
+func (client *Client) AssembleCluster(opts *AssembleClusterOptions) (changeID string, err error) {
+	if opts == nil || opts.Secret == "" {
+		return "", fmt.Errorf("cannot assemble cluster without a secret")
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(postClusterData{
+		Action:                 "assemble",
+		AssembleClusterOptions: opts,
+	}); err != nil {
+		return "", err
+	}
+
+	chgID, err := client.doAsync("POST", "/v2/cluster", nil, nil, &body)
+	if err != nil {
+		return "", fmt.Errorf("cannot assemble cluster: %w", err)
+	}
+
+	return chgID, nil
+}
+
+// ClusterJoinRequestOptions carries the parameters of a request to join an
+// existing cluster.
+type ClusterJoinRequestOptions struct {
+	// Secret is shared with the device of the cluster admitting this one.
+	Secret string `json:"secret"`
+	// Address is the IP address and port at which this device can be
+	// reached by the other devices of the cluster.
+	Address string `json:"address"`
+}
+
+// ClusterJoinRequest creates a request for this device to join an existing
+// cluster, to be handed to one of the devices of the cluster.
+func (client *Client) ClusterJoinRequest(opts *ClusterJoinRequestOptions) (json.RawMessage, error) {
+	if opts == nil || opts.Secret == "" {
+		return nil, fmt.Errorf("cannot create cluster join request without a secret")
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(struct {
+		Action string `json:"action"`
+		*ClusterJoinRequestOptions
+	}{
+		Action:                    "join-request",
+		ClusterJoinRequestOptions: opts,
+	}); err != nil {
+		return nil, err
+	}
+
+	var req json.RawMessage
+	if _, err := client.doSync("POST", "/v2/cluster", nil, nil, &body, &req); err != nil {
+		return nil, fmt.Errorf("cannot create cluster join request: %w", err)
+	}
+
+	return req, nil
+}
+
+// AddClusterDeviceOptions carries the parameters for admitting a device into
+// the cluster.
+type AddClusterDeviceOptions struct {
+	// Secret is the secret the join request was created with.
+	Secret string `json:"secret"`
+	// Request is the join request created by the device.
+	Request json.RawMessage `json:"request"`
+	// Subclusters optionally lists the subclusters the device is added to.
+	Subclusters []string `json:"subclusters,omitempty"`
+}
+
+// AddClusterDevice verifies the join request of a device and returns the
+// headers of the next cluster assertion, which adds the device to the
+// cluster.
+func (client *Client) AddClusterDevice(opts *AddClusterDeviceOptions) (map[string]any, error) {
+	if opts == nil || len(opts.Request) == 0 {
+		return nil, fmt.Errorf("cannot add device to cluster without a join request")
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(struct {
+		Action string `json:"action"`
+		*AddClusterDeviceOptions
+	}{
+		Action:                  "add-device",
+		AddClusterDeviceOptions: opts,
+	}); err != nil {
+		return nil, err
+	}
+
+	headers, err := client.doClusterAssertionSync(&body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot add device to cluster: %w", err)
+	}
+	return headers, nil
+}
+
+// RemoveClusterDeviceOptions carries the parameters for removing a device
+// from the cluster.
+type RemoveClusterDeviceOptions struct {
+	// Device is the id of the device in the cluster assertion.
+	Device int `json:"device"`
+	// Replacement is the optional id of the device taking over the
+	// subclusters of the removed device.
+	Replacement int `json:"replacement,omitempty"`
+}
+
+// RemoveClusterDevice returns the headers of the next cluster assertion,
+// which removes a device from the cluster.
+func (client *Client) RemoveClusterDevice(opts *RemoveClusterDeviceOptions) (map[string]any, error) {
+	if opts == nil || opts.Device <= 0 {
+		return nil, fmt.Errorf("cannot remove device from cluster without a device id")
+	}
+
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(struct {
+		Action string `json:"action"`
+		*RemoveClusterDeviceOptions
+	}{
+		Action:                     "remove-device",
+		RemoveClusterDeviceOptions: opts,
+	}); err != nil {
+		return nil, err
+	}
+
+	headers, err := client.doClusterAssertionSync(&body)
+	if err != nil {
+		return nil, fmt.Errorf("cannot remove device from cluster: %w", err)
+	}
+	return headers, nil
+}
+
+func (client *Client) doClusterAssertionSync(body *bytes.Buffer) (map[string]any, error) {
+	var result struct {
+		ClusterAssertion map[string]any `json:"cluster-assertion"`
+	}
+	if _, err := client.doSync("POST", "/v2/cluster", nil, nil, body, &result); err != nil {
+		return nil, err
+	}
+	return result.ClusterAssertion, nil
+}
+
+// UpdateCluster installs the given bundle containing a signed cluster
+// assertion and its prerequisites. A device that is not part of a cluster
+// yet joins the cluster.
+func (client *Client) UpdateCluster(assertions []byte) error {
+	var body bytes.Buffer
+	if err := json.NewEncoder(&body).Encode(map[string]string{
+		"action":     "update",
+		"assertions": string(assertions),
+	}); err != nil {
+		return err
+	}
+
+	_, err := client.doSync("POST", "/v2/cluster", nil, nil, &body, nil)
+	return err
+}
+
+// ClusterSubclusterRollout is the rollout state of a subcluster on a device.
+type ClusterSubclusterRollout struct {
+	// Status is one of "waiting", "applying" or "done".
+	Status string `json:"status"`
+	// Healthy is true when none of the snaps of the subcluster reports a
+	// health problem.
+	Healthy bool `json:"healthy"`
+}
+
+// ClusterDeviceRollout is the rollout state of a device of the cluster, as
+// reported by that device.
+type ClusterDeviceRollout struct {
+	Device      int                                 `json:"device"`
+	DeviceID    string                              `json:"device-id"`
+	Sequence    int                                 `json:"sequence,omitempty"`
+	Subclusters map[string]ClusterSubclusterRollout `json:"subclusters,omitempty"`
+	// Error is set when the device could not be asked for its state.
+	Error string `json:"error,omitempty"`
+}
+
+// ClusterRollout returns the rollout state of the devices of the subclusters
+// that take turns to apply changes.
+func (client *Client) ClusterRollout() ([]ClusterDeviceRollout, error) {
+	var rollouts []ClusterDeviceRollout
+	if _, err := client.doSync("GET", "/v2/cluster/rollout", nil, nil, nil, &rollouts); err != nil {
+		return nil, fmt.Errorf("cannot get cluster rollout: %w", err)
+	}
+	return rollouts, nil
+}
+
+// ClusterEvacuatedSnaps returns the names of the snaps evacuated from this
+// device following the cluster assertion.
+func (client *Client) ClusterEvacuatedSnaps() ([]string, error) {
+	var names []string
+	if _, err := client.doSync("GET", "/v2/cluster/evacuated", nil, nil, nil, &names); err != nil {
+		return nil, fmt.Errorf("cannot get evacuated snaps: %w", err)
+	}
+	return names, nil
+}
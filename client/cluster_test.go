@@ -0,0 +1,218 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package client_test
+
+import (
+	"encoding/json"
+	"io"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+)
+
+func (cs *clientSuite) TestAssembleCluster(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+
+	chgID, err := cs.cli.AssembleCluster(&client.AssembleClusterOptions{
+		Secret:       "secret",
+		Address:      "192.168.1.10:7070",
+		ExpectedSize: 3,
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(chgID, check.Equals, "42")
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/cluster")
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]any
+	c.Assert(json.Unmarshal(body, &req), check.IsNil)
+	c.Check(req, check.DeepEquals, map[string]any{
+		"action":        "assemble",
+		"secret":        "secret",
+		"address":       "192.168.1.10:7070",
+		"expected-size": float64(3),
+	})
+}
+
+func (cs *clientSuite) TestAssembleClusterError(c *check.C) {
+	cs.status = 400
+	cs.rsp = `{"type": "error", "result": {"message": "device is already part of cluster"}}`
+	_, err := cs.cli.AssembleCluster(&client.AssembleClusterOptions{Secret: "secret"})
+	c.Check(err, check.ErrorMatches, `cannot assemble cluster: device is already part of cluster`)
+
+	_, err = cs.cli.AssembleCluster(&client.AssembleClusterOptions{})
+	c.Check(err, check.ErrorMatches, `cannot assemble cluster without a secret`)
+}
+
+func (cs *clientSuite) TestClusterJoinRequest(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"identity": {"rdt": "rdt"}, "address": "192.168.1.30:7070"}
+	}`
+
+	req, err := cs.cli.ClusterJoinRequest(&client.ClusterJoinRequestOptions{
+		Secret:  "secret",
+		Address: "192.168.1.30:7070",
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(string(req), check.Equals, `{"identity": {"rdt": "rdt"}, "address": "192.168.1.30:7070"}`)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/cluster")
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var data map[string]any
+	c.Assert(json.Unmarshal(body, &data), check.IsNil)
+	c.Check(data, check.DeepEquals, map[string]any{
+		"action":  "join-request",
+		"secret":  "secret",
+		"address": "192.168.1.30:7070",
+	})
+
+	_, err = cs.cli.ClusterJoinRequest(&client.ClusterJoinRequestOptions{})
+	c.Check(err, check.ErrorMatches, `cannot create cluster join request without a secret`)
+}
+
+func (cs *clientSuite) TestAddClusterDevice(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"cluster-assertion": {"type": "cluster", "sequence": "2"}}
+	}`
+
+	headers, err := cs.cli.AddClusterDevice(&client.AddClusterDeviceOptions{
+		Secret:      "secret",
+		Request:     json.RawMessage(`{"address":"192.168.1.30:7070"}`),
+		Subclusters: []string{"default"},
+	})
+	c.Assert(err, check.IsNil)
+	c.Check(headers, check.DeepEquals, map[string]any{"type": "cluster", "sequence": "2"})
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var data map[string]any
+	c.Assert(json.Unmarshal(body, &data), check.IsNil)
+	c.Check(data, check.DeepEquals, map[string]any{
+		"action":      "add-device",
+		"secret":      "secret",
+		"request":     map[string]any{"address": "192.168.1.30:7070"},
+		"subclusters": []any{"default"},
+	})
+
+	_, err = cs.cli.AddClusterDevice(&client.AddClusterDeviceOptions{Secret: "secret"})
+	c.Check(err, check.ErrorMatches, `cannot add device to cluster without a join request`)
+}
+
+func (cs *clientSuite) TestRemoveClusterDevice(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {"cluster-assertion": {"type": "cluster", "sequence": "3"}}
+	}`
+
+	headers, err := cs.cli.RemoveClusterDevice(&client.RemoveClusterDeviceOptions{Device: 2, Replacement: 3})
+	c.Assert(err, check.IsNil)
+	c.Check(headers, check.DeepEquals, map[string]any{"type": "cluster", "sequence": "3"})
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var data map[string]any
+	c.Assert(json.Unmarshal(body, &data), check.IsNil)
+	c.Check(data, check.DeepEquals, map[string]any{
+		"action":      "remove-device",
+		"device":      float64(2),
+		"replacement": float64(3),
+	})
+
+	_, err = cs.cli.RemoveClusterDevice(&client.RemoveClusterDeviceOptions{})
+	c.Check(err, check.ErrorMatches, `cannot remove device from cluster without a device id`)
+}
+
+func (cs *clientSuite) TestRemoveClusterDeviceError(c *check.C) {
+	cs.status = 400
+	cs.rsp = `{"type": "error", "result": {"message": "device not found in cluster"}}`
+	_, err := cs.cli.RemoveClusterDevice(&client.RemoveClusterDeviceOptions{Device: 2})
+	c.Check(err, check.ErrorMatches, `cannot remove device from cluster: device not found in cluster`)
+}
+
+func (cs *clientSuite) TestUpdateCluster(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": null}`
+
+	err := cs.cli.UpdateCluster([]byte("bundle"))
+	c.Assert(err, check.IsNil)
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var data map[string]any
+	c.Assert(json.Unmarshal(body, &data), check.IsNil)
+	c.Check(data, check.DeepEquals, map[string]any{
+		"action":     "update",
+		"assertions": "bundle",
+	})
+
+	cs.status = 400
+	cs.rsp = `{"type": "error", "result": {"message": "cannot update cluster: bad bundle"}}`
+	err = cs.cli.UpdateCluster([]byte("bundle"))
+	c.Check(err, check.ErrorMatches, `cannot update cluster: bad bundle`)
+}
+
+func (cs *clientSuite) TestClusterRollout(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": [
+		{"device": 1, "device-id": "serial-1.model.brand", "sequence": 2, "subclusters": {"ha": {"status": "done", "healthy": true}}},
+		{"device": 2, "device-id": "serial-2.model.brand", "error": "connection refused"}
+	]}`
+
+	rollouts, err := cs.cli.ClusterRollout()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/cluster/rollout")
+	c.Check(rollouts, check.DeepEquals, []client.ClusterDeviceRollout{{
+		Device:   1,
+		DeviceID: "serial-1.model.brand",
+		Sequence: 2,
+		Subclusters: map[string]client.ClusterSubclusterRollout{
+			"ha": {Status: "done", Healthy: true},
+		},
+	}, {
+		Device:   2,
+		DeviceID: "serial-2.model.brand",
+		Error:    "connection refused",
+	}})
+
+	cs.status = 404
+	cs.rsp = `{"type": "error", "result": {"message": "device is not part of a cluster"}}`
+	_, err = cs.cli.ClusterRollout()
+	c.Check(err, check.ErrorMatches, `cannot get cluster rollout: device is not part of a cluster`)
+}
+
+func (cs *clientSuite) TestClusterEvacuatedSnaps(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": ["bar", "foo"]}`
+
+	names, err := cs.cli.ClusterEvacuatedSnaps()
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/cluster/evacuated")
+	c.Check(names, check.DeepEquals, []string{"bar", "foo"})
+
+	cs.status = 500
+	cs.rsp = `{"type": "error", "result": {"message": "boom"}}`
+	_, err = cs.cli.ClusterEvacuatedSnaps()
+	c.Check(err, check.ErrorMatches, `cannot get evacuated snaps: boom`)
+}
@@ -105,6 +105,9 @@ type SnapHealth struct {
 	Status    string        `json:"status"`
 	Message   string        `json:"message,omitempty"`
 	Code      string        `json:"code,omitempty"`
+	// RecheckTime is when the check-health hook is run again, if the
+	// snap asked for it.
+	RecheckTime time.Time `json:"recheck-time,omitzero"`
 }
 
 type SnapRefreshInhibit struct {
@@ -162,6 +165,8 @@ var ErrNoSnapsInstalled = errors.New("no snaps installed")
 
 type ListOptions struct {
 	All bool
+	// Health selects only the snaps which reported their health.
+	Health bool
 }
 
 // Information about a category
@@ -177,8 +182,11 @@ func (client *Client) List(names []string, opts *ListOptions) ([]*Snap, error) {
 	}
 
 	q := make(url.Values)
-	if opts.All {
+	switch {
+	case opts.All:
 		q.Add("select", "all")
+	case opts.Health:
+		q.Add("select", "health")
 	}
 	if len(names) > 0 {
 		q.Add("snaps", strings.Join(names, ","))
@@ -41,6 +41,15 @@ func (cs *clientSuite) TestClientSnapsCallsEndpoint(c *check.C) {
 	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{})
 }
 
+func (cs *clientSuite) TestClientSnapsHealth(c *check.C) {
+	_, _ = cs.cli.List([]string{"foo"}, &client.ListOptions{Health: true})
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"select": []string{"health"},
+		"snaps":  []string{"foo"},
+	})
+}
+
 func (cs *clientSuite) TestClientFindRefreshSetsQuery(c *check.C) {
 	_, _, _ = cs.cli.Find(&client.FindOptions{
 		Refresh: true,
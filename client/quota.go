@@ -29,22 +29,35 @@ import (
 )
 
 type postQuotaData struct {
-	Action      string       `json:"action"`
-	GroupName   string       `json:"group-name"`
-	Parent      string       `json:"parent,omitempty"`
-	Snaps       []string     `json:"snaps,omitempty"`
-	Services    []string     `json:"services,omitempty"`
-	Constraints *QuotaValues `json:"constraints,omitempty"`
+	Action            string       `json:"action"`
+	GroupName         string       `json:"group-name"`
+	Parent            string       `json:"parent,omitempty"`
+	Snaps             []string     `json:"snaps,omitempty"`
+	Services          []string     `json:"services,omitempty"`
+	Constraints       *QuotaValues `json:"constraints,omitempty"`
+	MemoryEventPolicy string       `json:"memory-event-policy,omitempty"`
 }
 
 type QuotaGroupResult struct {
-	GroupName   string       `json:"group-name"`
-	Parent      string       `json:"parent,omitempty"`
-	Subgroups   []string     `json:"subgroups,omitempty"`
-	Snaps       []string     `json:"snaps,omitempty"`
-	Services    []string     `json:"services,omitempty"`
-	Constraints *QuotaValues `json:"constraints,omitempty"`
-	Current     *QuotaValues `json:"current,omitempty"`
+	GroupName         string             `json:"group-name"`
+	Parent            string             `json:"parent,omitempty"`
+	Subgroups         []string           `json:"subgroups,omitempty"`
+	Snaps             []string           `json:"snaps,omitempty"`
+	Services          []string           `json:"services,omitempty"`
+	Constraints       *QuotaValues       `json:"constraints,omitempty"`
+	Current           *QuotaValues       `json:"current,omitempty"`
+	MemoryEventPolicy string             `json:"memory-event-policy,omitempty"`
+	MemoryEvents      *QuotaMemoryEvents `json:"memory-events,omitempty"`
+}
+
+// QuotaMemoryEvents holds the number of times the processes in a quota group
+// hit its memory limit, since the group was created.
+type QuotaMemoryEvents struct {
+	High    uint64    `json:"high,omitempty"`
+	Max     uint64    `json:"max,omitempty"`
+	OOM     uint64    `json:"oom,omitempty"`
+	OOMKill uint64    `json:"oom-kill,omitempty"`
+	Last    time.Time `json:"last,omitempty"`
 }
 
 type QuotaCPUValues struct {
@@ -66,12 +79,31 @@ type QuotaJournalValues struct {
 	*QuotaJournalRate
 }
 
+type QuotaIODeviceValues struct {
+	Device    string        `json:"device"`
+	ReadBps   quantity.Size `json:"read-bps,omitempty"`
+	WriteBps  quantity.Size `json:"write-bps,omitempty"`
+	ReadIOPS  int           `json:"read-iops,omitempty"`
+	WriteIOPS int           `json:"write-iops,omitempty"`
+}
+
+// QuotaIOValues holds the per device limits when used as constraints, and
+// the bytes read and written by the group when used as current usage.
+type QuotaIOValues struct {
+	Devices    []QuotaIODeviceValues `json:"devices,omitempty"`
+	ReadBytes  quantity.Size         `json:"read-bytes,omitempty"`
+	WriteBytes quantity.Size         `json:"write-bytes,omitempty"`
+}
+
 type QuotaValues struct {
-	Memory  quantity.Size       `json:"memory,omitempty"`
-	CPU     *QuotaCPUValues     `json:"cpu,omitempty"`
-	CPUSet  *QuotaCPUSetValues  `json:"cpu-set,omitempty"`
-	Threads int                 `json:"threads,omitempty"`
-	Journal *QuotaJournalValues `json:"journal,omitempty"`
+	Memory     quantity.Size       `json:"memory,omitempty"`
+	MemoryHigh quantity.Size       `json:"memory-high,omitempty"`
+	MemorySwap quantity.Size       `json:"memory-swap,omitempty"`
+	CPU        *QuotaCPUValues     `json:"cpu,omitempty"`
+	CPUSet     *QuotaCPUSetValues  `json:"cpu-set,omitempty"`
+	Threads    int                 `json:"threads,omitempty"`
+	Journal    *QuotaJournalValues `json:"journal,omitempty"`
+	IO         *QuotaIOValues      `json:"io,omitempty"`
 }
 
 type EnsureQuotaOptions struct {
@@ -84,6 +116,9 @@ type EnsureQuotaOptions struct {
 	// Constraints are the resource limits that should be applied to the quota group,
 	// these are added or modified, not removed.
 	Constraints *QuotaValues
+	// MemoryEventPolicy is what is done when processes in the quota group
+	// are killed for running out of memory: "notice", "warn" or "restart".
+	MemoryEventPolicy string
 }
 
 // EnsureQuota creates a quota group or updates an existing group with the options
@@ -99,12 +134,13 @@ func (client *Client) EnsureQuota(groupName string, opts *EnsureQuotaOptions) (c
 	// TODO: use naming.ValidateQuotaGroup()
 
 	data := &postQuotaData{
-		Action:      "ensure",
-		GroupName:   groupName,
-		Parent:      opts.Parent,
-		Snaps:       opts.Snaps,
-		Services:    opts.Services,
-		Constraints: opts.Constraints,
+		Action:            "ensure",
+		GroupName:         groupName,
+		Parent:            opts.Parent,
+		Snaps:             opts.Snaps,
+		Services:          opts.Services,
+		Constraints:       opts.Constraints,
+		MemoryEventPolicy: opts.MemoryEventPolicy,
 	}
 
 	var body bytes.Buffer
@@ -135,7 +135,7 @@ func (cs *clientSuite) TestGetQuotaGroup(c *check.C) {
 			"subgroups":["foo-subgrp"],
 			"snaps":["snap-a"],
 			"services":["snap-a.svc1"],
-			"constraints": { "memory": 999 },
+			"constraints": { "memory": 999, "memory-high": 900, "memory-swap": 2048 },
 			"current": { "memory": 450 }
 		}
 	}`
@@ -148,13 +148,69 @@ func (cs *clientSuite) TestGetQuotaGroup(c *check.C) {
 		GroupName:   "foo",
 		Parent:      "bar",
 		Subgroups:   []string{"foo-subgrp"},
-		Constraints: &client.QuotaValues{Memory: quantity.Size(999)},
+		Constraints: &client.QuotaValues{Memory: quantity.Size(999), MemoryHigh: quantity.Size(900), MemorySwap: quantity.Size(2048)},
 		Current:     &client.QuotaValues{Memory: quantity.Size(450)},
 		Snaps:       []string{"snap-a"},
 		Services:    []string{"snap-a.svc1"},
 	})
 }
 
+func (cs *clientSuite) TestEnsureQuotaGroupMemoryEventPolicy(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"type": "async",
+		"status-code": 202,
+		"change": "42"
+	}`
+
+	_, err := cs.cli.EnsureQuota("foo", &client.EnsureQuotaOptions{
+		Constraints:       &client.QuotaValues{Memory: quantity.SizeMiB},
+		MemoryEventPolicy: "restart",
+	})
+	c.Assert(err, check.IsNil)
+	body, err := io.ReadAll(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	var req map[string]any
+	err = jsonutil.DecodeWithNumber(bytes.NewReader(body), &req)
+	c.Assert(err, check.IsNil)
+	c.Check(req, check.DeepEquals, map[string]any{
+		"action":     "ensure",
+		"group-name": "foo",
+		"constraints": map[string]any{
+			"memory": json.Number("1048576"),
+		},
+		"memory-event-policy": "restart",
+	})
+}
+
+func (cs *clientSuite) TestGetQuotaGroupMemoryEvents(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"snaps":["snap-a"],
+			"constraints": { "memory": 999 },
+			"memory-event-policy": "warn",
+			"memory-events": { "high": 5, "oom-kill": 2, "last": "2026-10-17T10:00:00Z" }
+		}
+	}`
+
+	grp, err := cs.cli.GetQuotaGroup("foo")
+	c.Assert(err, check.IsNil)
+	c.Check(grp, check.DeepEquals, &client.QuotaGroupResult{
+		GroupName:         "foo",
+		Constraints:       &client.QuotaValues{Memory: quantity.Size(999)},
+		Snaps:             []string{"snap-a"},
+		MemoryEventPolicy: "warn",
+		MemoryEvents: &client.QuotaMemoryEvents{
+			High:    5,
+			OOMKill: 2,
+			Last:    time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+		},
+	})
+}
+
 func (cs *clientSuite) TestGetQuotaGroupError(c *check.C) {
 	cs.status = 500
 	cs.rsp = `{"type": "error"}`
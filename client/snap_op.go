@@ -64,6 +64,7 @@ type SnapOptions struct {
 	Time             string          `json:"time,omitempty"`
 	HoldLevel        string          `json:"hold-level,omitempty"`
 	Users            []string        `json:"users,omitempty"`
+	Encrypt          bool            `json:"encrypt,omitempty"`
 }
 
 func writeFieldBool(mw *multipart.Writer, key string, val bool) error {
@@ -122,6 +123,7 @@ type actionData struct {
 	Name       string   `json:"name,omitempty"`
 	SnapPath   string   `json:"snap-path,omitempty"`
 	Components []string `json:"components,omitempty"`
+	DryRun     bool     `json:"dry-run,omitempty"`
 	*SnapOptions
 }
 
@@ -136,6 +138,8 @@ type multiActionData struct {
 	Time           string              `json:"time,omitempty"`
 	HoldLevel      string              `json:"hold-level,omitempty"`
 	Components     map[string][]string `json:"components,omitempty"`
+	Encrypt        bool                `json:"encrypt,omitempty"`
+	DryRun         bool                `json:"dry-run,omitempty"`
 }
 
 // Install adds the snap with the given name from the given channel (or
@@ -170,6 +174,65 @@ func (client *Client) RefreshMany(names []string, components map[string][]string
 	return client.doMultiSnapAction("refresh", names, components, options)
 }
 
+// RefreshPlan describes what a refresh would do, as reported by a dry-run.
+type RefreshPlan struct {
+	Summary      string   `json:"summary"`
+	SnapNames    []string `json:"snap-names,omitempty"`
+	Tasks        []*Task  `json:"tasks"`
+	DownloadSize int64    `json:"download-size"`
+	// RebootRequired lists the snaps whose refresh requires a reboot.
+	RebootRequired []string `json:"reboot-required,omitempty"`
+	// Reconnections lists the connections that are re-established
+	// once the snaps are refreshed.
+	Reconnections []string `json:"reconnections,omitempty"`
+	// Hooks lists the hooks run during the refresh, as <snap>:<hook>.
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+// RefreshDryRun reports what refreshing the snap with the given name would
+// do, without doing it.
+func (client *Client) RefreshDryRun(name string, components []string, options *SnapOptions) (*RefreshPlan, error) {
+	if options != nil && options.Dangerous {
+		return nil, ErrDangerousNotApplicable
+	}
+
+	action := actionData{
+		Action:      "refresh",
+		SnapOptions: options,
+		Components:  components,
+		DryRun:      true,
+	}
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal snap action: %s", err)
+	}
+	return client.doRefreshDryRun(fmt.Sprintf("/v2/snaps/%s", name), data)
+}
+
+// RefreshManyDryRun reports what refreshing the given snaps (all, if names
+// is empty) would do, without doing it.
+func (client *Client) RefreshManyDryRun(names []string, components map[string][]string, options *SnapOptions) (*RefreshPlan, error) {
+	action := newMultiActionData("refresh", names, components, options)
+	action.DryRun = true
+	data, err := json.Marshal(&action)
+	if err != nil {
+		return nil, fmt.Errorf("cannot marshal multi-snap action: %s", err)
+	}
+	return client.doRefreshDryRun("/v2/snaps", data)
+}
+
+func (client *Client) doRefreshDryRun(path string, data []byte) (*RefreshPlan, error) {
+	headers := map[string]string{
+		"Content-Type": "application/json",
+	}
+
+	var plan RefreshPlan
+	if _, err := client.doSync("POST", path, nil, headers, bytes.NewBuffer(data), &plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
 func (client *Client) HoldRefreshes(name string, options *SnapOptions) (changeID string, err error) {
 	return client.doSnapAction("hold", name, nil, options)
 }
@@ -204,9 +267,19 @@ func (client *Client) Switch(name string, options *SnapOptions) (changeID string
 	return client.doSnapAction("switch", name, nil, options)
 }
 
+// SnapshotSaveOptions holds the options for saving snapshots.
+type SnapshotSaveOptions struct {
+	// Encrypt the snapshot set with a key kept on the device.
+	Encrypt bool
+}
+
 // SnapshotMany snapshots many snaps (all, if names empty) for many users (all, if users is empty).
-func (client *Client) SnapshotMany(names []string, users []string) (setID uint64, changeID string, err error) {
-	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, nil, &SnapOptions{Users: users})
+func (client *Client) SnapshotMany(names []string, users []string, opts *SnapshotSaveOptions) (setID uint64, changeID string, err error) {
+	options := &SnapOptions{Users: users}
+	if opts != nil {
+		options.Encrypt = opts.Encrypt
+	}
+	result, changeID, err := client.doMultiSnapActionFull("snapshot", names, nil, options)
 	if err != nil {
 		return 0, "", err
 	}
@@ -253,7 +326,7 @@ func (client *Client) doMultiSnapAction(actionName string, snaps []string, compo
 	return changeID, err
 }
 
-func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, components map[string][]string, options *SnapOptions) (result json.RawMessage, changeID string, err error) {
+func newMultiActionData(actionName string, snaps []string, components map[string][]string, options *SnapOptions) multiActionData {
 	action := multiActionData{
 		Action:     actionName,
 		Snaps:      snaps,
@@ -269,8 +342,15 @@ func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, c
 		action.ValidationSets = options.ValidationSets
 		action.Time = options.Time
 		action.HoldLevel = options.HoldLevel
+		action.Encrypt = options.Encrypt
 	}
 
+	return action
+}
+
+func (client *Client) doMultiSnapActionFull(actionName string, snaps []string, components map[string][]string, options *SnapOptions) (result json.RawMessage, changeID string, err error) {
+	action := newMultiActionData(actionName, snaps, components, options)
+
 	data, err := json.Marshal(&action)
 	if err != nil {
 		return nil, "", fmt.Errorf("cannot marshal multi-snap action: %s", err)
@@ -108,7 +108,7 @@ func (cs *clientSuite) TestClientMultiOpSnapServerError(c *check.C) {
 		_, err := s.op(cs.cli, nil, nil)
 		c.Check(err, check.ErrorMatches, `.*fail`, check.Commentf(s.action))
 	}
-	_, _, err := cs.cli.SnapshotMany(nil, nil)
+	_, _, err := cs.cli.SnapshotMany(nil, nil, nil)
 	c.Check(err, check.ErrorMatches, `.*fail`)
 }
 
@@ -128,7 +128,7 @@ func (cs *clientSuite) TestClientMultiOpSnapResponseError(c *check.C) {
 		_, err := s.op(cs.cli, nil, nil)
 		c.Check(err, check.ErrorMatches, `.*server error: "Internal Server Error"`, check.Commentf(s.action))
 	}
-	_, _, err := cs.cli.SnapshotMany(nil, nil)
+	_, _, err := cs.cli.SnapshotMany(nil, nil, nil)
 	c.Check(err, check.ErrorMatches, `.*server error: "Internal Server Error"`)
 }
 
@@ -221,6 +221,55 @@ func (cs *clientSuite) TestClientMultiOpSnap(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientRefreshDryRun(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"result": {
+			"summary": "Refresh \"foo\" snap",
+			"snap-names": ["foo"],
+			"tasks": [{"id": "1", "kind": "download-snap", "summary": "Download snap \"foo\"", "status": "Do"}],
+			"download-size": 1024,
+			"reboot-required": ["foo"],
+			"reconnections": ["foo:network core:network"],
+			"hooks": ["foo:post-refresh"]
+		}
+	}`
+	expected := &client.RefreshPlan{
+		Summary:        `Refresh "foo" snap`,
+		SnapNames:      []string{"foo"},
+		Tasks:          []*client.Task{{ID: "1", Kind: "download-snap", Summary: `Download snap "foo"`, Status: "Do"}},
+		DownloadSize:   1024,
+		RebootRequired: []string{"foo"},
+		Reconnections:  []string{"foo:network core:network"},
+		Hooks:          []string{"foo:post-refresh"},
+	}
+
+	plan, err := cs.cli.RefreshDryRun("foo", nil, &client.SnapOptions{Channel: "edge"})
+	c.Assert(err, check.IsNil)
+	c.Check(plan, check.DeepEquals, expected)
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps/foo")
+	var jsonBody map[string]any
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]any{
+		"action":  "refresh",
+		"channel": "edge",
+		"dry-run": true,
+	})
+
+	plan, err = cs.cli.RefreshManyDryRun(nil, nil, &client.SnapOptions{IgnoreRunning: true})
+	c.Assert(err, check.IsNil)
+	c.Check(plan, check.DeepEquals, expected)
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snaps")
+	jsonBody = nil
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]any{
+		"action":         "refresh",
+		"ignore-running": true,
+		"dry-run":        true,
+	})
+}
+
 func (cs *clientSuite) TestClientMultiOpSnapTransactional(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
@@ -292,7 +341,7 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 		"status-code": 202,
 		"type": "async"
 	}`
-	setID, changeID, err := cs.cli.SnapshotMany([]string{pkgName}, nil)
+	setID, changeID, err := cs.cli.SnapshotMany([]string{pkgName}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(cs.req.Header.Get("Content-Type"), check.Equals, "application/json")
 
@@ -309,6 +358,27 @@ func (cs *clientSuite) TestClientMultiSnapshot(c *check.C) {
 	c.Check(changeID, check.Equals, "d728")
 }
 
+func (cs *clientSuite) TestClientMultiSnapshotEncrypted(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"result": {"set-id": 42},
+		"change": "d728",
+		"status-code": 202,
+		"type": "async"
+	}`
+	setID, _, err := cs.cli.SnapshotMany(nil, []string{"user"}, &client.SnapshotSaveOptions{Encrypt: true})
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(42))
+
+	var jsonBody map[string]any
+	c.Assert(json.NewDecoder(cs.req.Body).Decode(&jsonBody), check.IsNil)
+	c.Check(jsonBody, check.DeepEquals, map[string]any{
+		"action":  "snapshot",
+		"users":   []any{"user"},
+		"encrypt": true,
+	})
+}
+
 func (cs *clientSuite) TestClientOpInstallPath(c *check.C) {
 	cs.status = 202
 	cs.rsp = `{
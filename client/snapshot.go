@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"context"
 	"crypto/sha256"
+	"encoding/base64"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -39,6 +40,10 @@ import (
 // SnapshotExportMediaType is the media type used to identify snapshot exports in the API.
 const SnapshotExportMediaType = "application/x.snapd.snapshot"
 
+// SnapshotPassphraseHeader is the header used to pass the (base64 encoded)
+// passphrase for encrypting or decrypting snapshot exports.
+const SnapshotPassphraseHeader = "X-Snapd-Snapshot-Passphrase"
+
 var (
 	ErrSnapshotSetNotFound   = errors.New("no snapshot set with the given ID")
 	ErrSnapshotSnapsNotFound = errors.New("no snapshot for the requested snaps found in the set with the given ID")
@@ -50,6 +55,31 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+
+	Target     *SnapshotTarget `json:"target,omitempty"`
+	Passphrase string          `json:"passphrase,omitempty"`
+}
+
+// SnapshotTarget is a remote location that snapshot sets can be pushed to
+// and pulled from.
+//
+// The URL is either of the form s3://host/bucket/key (s3+http:// for plain
+// HTTP) for an object in an S3-compatible object store, using the
+// "access-key", "secret-key" and optionally "region" and "session-token"
+// credentials, or an http:// or https:// URL written with PUT, as
+// supported by WebDAV servers, using either the "username" and "password"
+// or the "token" credentials.
+type SnapshotTarget struct {
+	URL         string            `json:"url"`
+	Credentials map[string]string `json:"credentials,omitempty"`
+}
+
+// SnapshotTransferOptions holds the options for pushing and pulling
+// snapshot sets.
+type SnapshotTransferOptions struct {
+	// Passphrase is used to encrypt the pushed export, or to decrypt the
+	// pulled one. Pushing encrypted snapshot sets requires it.
+	Passphrase string
 }
 
 // A Snapshot is a collection of archives with a simple metadata json file
@@ -89,6 +119,10 @@ type Snapshot struct {
 	// newer snapd just updates this flag on the fly for snapshots
 	// returned by List().
 	Auto bool `json:"auto,omitempty"`
+
+	// set if the archives' data is encrypted with the key of the
+	// snapshot set
+	Encrypted bool `json:"encrypted,omitempty"`
 }
 
 // IsValid checks whether the snapshot is missing information that
@@ -218,6 +252,32 @@ func (client *Client) RestoreSnapshots(setID uint64, snaps []string, users []str
 	})
 }
 
+// PushSnapshot uploads the export of the given snapshot set to the target.
+func (client *Client) PushSnapshot(setID uint64, target *SnapshotTarget, opts *SnapshotTransferOptions) (changeID string, err error) {
+	action := &snapshotAction{
+		SetID:  setID,
+		Action: "push",
+		Target: target,
+	}
+	if opts != nil {
+		action.Passphrase = opts.Passphrase
+	}
+	return client.snapshotAction(action)
+}
+
+// PullSnapshot imports the export in the target as a new snapshot set. The
+// ID of the set is in the "set-id" data of the change once done.
+func (client *Client) PullSnapshot(target *SnapshotTarget, opts *SnapshotTransferOptions) (changeID string, err error) {
+	action := &snapshotAction{
+		Action: "pull",
+		Target: target,
+	}
+	if opts != nil {
+		action.Passphrase = opts.Passphrase
+	}
+	return client.snapshotAction(action)
+}
+
 func (client *Client) snapshotAction(action *snapshotAction) (changeID string, err error) {
 	data, err := json.Marshal(action)
 	if err != nil {
@@ -231,11 +291,24 @@ func (client *Client) snapshotAction(action *snapshotAction) (changeID string, e
 	return client.doAsync("POST", "/v2/snapshots", nil, headers, bytes.NewBuffer(data))
 }
 
+// SnapshotExportOptions holds the options for exporting snapshot sets.
+type SnapshotExportOptions struct {
+	// Passphrase, if set, is used to encrypt the export. Exporting
+	// encrypted snapshot sets requires it.
+	Passphrase string
+}
+
 // SnapshotExport streams the requested snapshot set.
 //
 // The return value includes the length of the returned stream.
-func (client *Client) SnapshotExport(setID uint64) (stream io.ReadCloser, contentLength int64, err error) {
-	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), nil, nil, nil)
+func (client *Client) SnapshotExport(setID uint64, opts *SnapshotExportOptions) (stream io.ReadCloser, contentLength int64, err error) {
+	var headers map[string]string
+	if opts != nil && opts.Passphrase != "" {
+		headers = map[string]string{
+			SnapshotPassphraseHeader: base64.StdEncoding.EncodeToString([]byte(opts.Passphrase)),
+		}
+	}
+	rsp, err := client.raw(context.Background(), "GET", fmt.Sprintf("/v2/snapshots/%v/export", setID), nil, headers, nil)
 	if err != nil {
 		return nil, 0, err
 	}
@@ -266,12 +339,21 @@ type SnapshotImportSet struct {
 	Snaps []string `json:"snaps"`
 }
 
+// SnapshotImportOptions holds the options for importing snapshot sets.
+type SnapshotImportOptions struct {
+	// Passphrase is used to decrypt exports encrypted with one.
+	Passphrase string
+}
+
 // SnapshotImport imports an exported snapshot set.
-func (client *Client) SnapshotImport(exportStream io.Reader, size int64) (SnapshotImportSet, error) {
+func (client *Client) SnapshotImport(exportStream io.Reader, size int64, opts *SnapshotImportOptions) (SnapshotImportSet, error) {
 	headers := map[string]string{
 		"Content-Type":   SnapshotExportMediaType,
 		"Content-Length": strconv.FormatInt(size, 10),
 	}
+	if opts != nil && opts.Passphrase != "" {
+		headers[SnapshotPassphraseHeader] = base64.StdEncoding.EncodeToString([]byte(opts.Passphrase))
+	}
 
 	var importSet SnapshotImportSet
 	if _, err := client.doSync("POST", "/v2/snapshots", nil, headers, exportStream, &importSet); err != nil {
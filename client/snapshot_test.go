@@ -143,13 +143,58 @@ func (cs *clientSuite) TestClientRestoreSnapshots(c *check.C) {
 	cs.testClientSnapshotAction(c, "restore", cs.cli.RestoreSnapshots)
 }
 
+func (cs *clientSuite) TestClientPushSnapshot(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"status-code": 202,
+		"type": "async",
+		"change": "1too3"
+	}`
+	target := &client.SnapshotTarget{
+		URL:         "s3://minio:9000/bucket/key",
+		Credentials: map[string]string{"access-key": "access", "secret-key": "secret"},
+	}
+	id, err := cs.cli.PushSnapshot(42, target, &client.SnapshotTransferOptions{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1too3")
+
+	c.Check(cs.req.Method, check.Equals, "POST")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/snapshots")
+	act, err := client.UnmarshalSnapshotAction(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(act.SetID, check.Equals, uint64(42))
+	c.Check(act.Action, check.Equals, "push")
+	c.Check(act.Target, check.DeepEquals, target)
+	c.Check(act.Passphrase, check.Equals, "sekrit")
+}
+
+func (cs *clientSuite) TestClientPullSnapshot(c *check.C) {
+	cs.status = 202
+	cs.rsp = `{
+		"status-code": 202,
+		"type": "async",
+		"change": "1too3"
+	}`
+	target := &client.SnapshotTarget{URL: "https://dav.example.com/snapshot.tar"}
+	id, err := cs.cli.PullSnapshot(target, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(id, check.Equals, "1too3")
+
+	act, err := client.UnmarshalSnapshotAction(cs.req.Body)
+	c.Assert(err, check.IsNil)
+	c.Check(act.SetID, check.Equals, uint64(0))
+	c.Check(act.Action, check.Equals, "pull")
+	c.Check(act.Target, check.DeepEquals, target)
+	c.Check(act.Passphrase, check.Equals, "")
+}
+
 func (cs *clientSuite) TestClientExportSnapshotSpecificErr(c *check.C) {
 	content := `{"type":"error","status-code":400,"result":{"message":"boom","kind":"err-kind","value":"err-value"}}`
 	cs.contentLength = int64(len(content))
 	cs.rsp = content
 	cs.status = 400
 	cs.header = http.Header{"Content-Type": []string{"application/json"}}
-	_, _, err := cs.cli.SnapshotExport(42)
+	_, _, err := cs.cli.SnapshotExport(42, nil)
 	c.Check(err, check.ErrorMatches, "boom")
 }
 
@@ -174,7 +219,7 @@ func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 		cs.rsp = t.content
 		cs.status = t.status
 
-		r, size, err := cs.cli.SnapshotExport(42)
+		r, size, err := cs.cli.SnapshotExport(42, nil)
 		if t.status == 200 {
 			c.Assert(err, check.IsNil, comm)
 			c.Assert(cs.countingCloser.closeCalled, check.Equals, 0)
@@ -192,6 +237,28 @@ func (cs *clientSuite) TestClientExportSnapshot(c *check.C) {
 	}
 }
 
+func (cs *clientSuite) TestClientExportSnapshotPassphrase(c *check.C) {
+	cs.contentLength = int64(len("test-export"))
+	cs.header = http.Header{"Content-Type": []string{client.SnapshotExportMediaType}}
+	cs.rsp = "test-export"
+	cs.status = 200
+
+	r, _, err := cs.cli.SnapshotExport(42, &client.SnapshotExportOptions{Passphrase: "sekrit passphrase"})
+	c.Assert(err, check.IsNil)
+	defer r.Close()
+	c.Check(cs.req.Header.Get(client.SnapshotPassphraseHeader), check.Equals, "c2Vrcml0IHBhc3NwaHJhc2U=")
+}
+
+func (cs *clientSuite) TestClientSnapshotImportPassphrase(c *check.C) {
+	cs.rsp = `{"type": "sync", "result": {"set-id": 42, "snaps": ["foo"]}}`
+	cs.status = 200
+
+	importSet, err := cs.cli.SnapshotImport(strings.NewReader("fake"), 4, &client.SnapshotImportOptions{Passphrase: "sekrit passphrase"})
+	c.Assert(err, check.IsNil)
+	c.Check(importSet.ID, check.Equals, uint64(42))
+	c.Check(cs.req.Header.Get(client.SnapshotPassphraseHeader), check.Equals, "c2Vrcml0IHBhc3NwaHJhc2U=")
+}
+
 func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 	type tableT struct {
 		rsp    string
@@ -212,7 +279,7 @@ func (cs *clientSuite) TestClientSnapshotImport(c *check.C) {
 
 		fakeSnapshotData := "fake"
 		r := strings.NewReader(fakeSnapshotData)
-		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)))
+		importSet, err := cs.cli.SnapshotImport(r, int64(len(fakeSnapshotData)), nil)
 		if t.error != "" {
 			c.Assert(err, check.NotNil, comm)
 			c.Check(err.Error(), check.Equals, t.error, comm)
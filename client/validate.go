@@ -26,6 +26,8 @@ import (
 	"net/url"
 
 	"golang.org/x/xerrors"
+
+	"github.com/snapcore/snapd/snap"
 )
 
 // ValidateApplyOptions carries options for ApplyValidationSet.
@@ -133,3 +135,51 @@ func (client *Client) ValidationSet(accountID, name string, sequence int) (*Vali
 	}
 	return res, nil
 }
+
+// ValidationSetChange describes a change to a snap or component needed to
+// enforce validation sets.
+type ValidationSetChange struct {
+	// Action is one of install, refresh or remove.
+	Action    string `json:"action"`
+	Snap      string `json:"snap"`
+	Component string `json:"component,omitempty"`
+	// Revision is the revision required by the validation sets, if any.
+	Revision snap.Revision `json:"revision"`
+	// CurrentRevision is the installed revision, if any.
+	CurrentRevision snap.Revision `json:"current-revision"`
+}
+
+// ValidationSetPreview holds the changes needed to enforce a validation set
+// together with the already enforced ones.
+type ValidationSetPreview struct {
+	AccountID string                 `json:"account-id"`
+	Name      string                 `json:"name"`
+	Sequence  int                    `json:"sequence"`
+	Changes   []*ValidationSetChange `json:"changes,omitempty"`
+	// Conflict describes the conflicts with the enforced validation sets
+	// preventing enforcing the validation set, if any.
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// PreviewValidationSet returns the changes that enforcing the given
+// validation set identified by account/name and optional sequence (if
+// non-zero) would need, without enforcing it.
+func (client *Client) PreviewValidationSet(accountID, name string, sequence int) (*ValidationSetPreview, error) {
+	if accountID == "" || name == "" {
+		return nil, xerrors.Errorf("cannot preview validation set without account ID and name")
+	}
+
+	q := url.Values{}
+	q.Set("preview", "true")
+	if sequence != 0 {
+		q.Set("sequence", fmt.Sprintf("%d", sequence))
+	}
+
+	var res *ValidationSetPreview
+	path := fmt.Sprintf("/v2/validation-sets/%s/%s", accountID, name)
+	if _, err := client.doSync("GET", path, q, nil, nil, &res); err != nil {
+		fmt := "cannot preview validation set: %w"
+		return nil, xerrors.Errorf(fmt, err)
+	}
+	return res, nil
+}
@@ -27,6 +27,7 @@ import (
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/snap"
 )
 
 var errorResponseJSON = `{
@@ -238,3 +239,48 @@ func (cs *clientSuite) TestValidationSetWithSequence(c *check.C) {
 		AccountID: "abc", Name: "def", Mode: "monitor", Sequence: 9, Valid: false,
 	})
 }
+
+func (cs *clientSuite) TestPreviewValidationSet(c *check.C) {
+	cs.rsp = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"account-id": "foo",
+			"name": "bar",
+			"sequence": 3,
+			"changes": [
+				{"action": "refresh", "snap": "baz", "revision": "2", "current-revision": "1"},
+				{"action": "install", "snap": "baz", "component": "comp"}
+			]
+		}
+	}`
+
+	preview, err := cs.cli.PreviewValidationSet("foo", "bar", 3)
+	c.Assert(err, check.IsNil)
+	c.Check(cs.req.Method, check.Equals, "GET")
+	c.Check(cs.req.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+	c.Check(cs.req.URL.Query(), check.DeepEquals, url.Values{
+		"preview":  []string{"true"},
+		"sequence": []string{"3"},
+	})
+	c.Check(preview, check.DeepEquals, &client.ValidationSetPreview{
+		AccountID: "foo",
+		Name:      "bar",
+		Sequence:  3,
+		Changes: []*client.ValidationSetChange{
+			{Action: "refresh", Snap: "baz", Revision: snap.R(2), CurrentRevision: snap.R(1)},
+			{Action: "install", Snap: "baz", Component: "comp"},
+		},
+	})
+}
+
+func (cs *clientSuite) TestPreviewValidationSetError(c *check.C) {
+	cs.status = 500
+	cs.rsp = errorResponseJSON
+
+	_, err := cs.cli.PreviewValidationSet("foo", "bar", 0)
+	c.Assert(err, check.ErrorMatches, "cannot preview validation set: failed")
+
+	_, err = cs.cli.PreviewValidationSet("foo", "", 0)
+	c.Assert(err, check.ErrorMatches, `cannot preview validation set without account ID and name`)
+}
@@ -188,27 +188,12 @@ func NewAssembleState(
 		return nil, err
 	}
 
-	// calculate the HMAC that this device would use to authenticate itself
-	fp := CalculateFP(cert.Certificate[0])
-	hmac := CalculateHMAC(config.RDT, fp, config.Secret)
-
-	// sign the HMAC with our private key to create the SerialProof
-	proof, err := config.Signer(hmac)
-	if err != nil {
-		return nil, fmt.Errorf("cannot sign hmac for serial proof: %v", err)
-	}
-
-	bundle, err := buildSerialBundle(config.Serial, assertDB)
+	self, err := NewIdentity(config.RDT, CalculateFP(cert.Certificate[0]), config.Secret, config.Serial, assertDB, config.Signer)
 	if err != nil {
-		return nil, fmt.Errorf("cannot build serial bundle: %w", err)
+		return nil, err
 	}
 
-	if err := ensureLocalDevicePresent(&validated, assertDB, config.Secret, Identity{
-		RDT:          config.RDT,
-		FP:           CalculateFP(cert.Certificate[0]),
-		SerialBundle: bundle,
-		SerialProof:  proof,
-	}); err != nil {
+	if err := ensureLocalDevicePresent(&validated, assertDB, config.Secret, self); err != nil {
 		return nil, err
 	}
 
@@ -248,7 +233,7 @@ func NewAssembleState(
 		commit:       commit,
 		clock:        config.Clock,
 		cert:         cert,
-		authHMAC:     hmac,
+		authHMAC:     CalculateHMAC(self.RDT, self.FP, config.Secret),
 		trusted:      validated.trusted,
 		fingerprints: validated.fingerprints,
 		addresses:    validated.addresses,
@@ -818,6 +803,36 @@ func (h *peerHandle) CommitDevices(devices Devices) error {
 	return nil
 }
 
+// NewIdentity builds the identity that a device presents to its peers. The
+// serial proof, signed by signer with the device's private key, binds the
+// device's serial assertion to the given token, fingerprint and shared secret.
+func NewIdentity(
+	rdt DeviceToken, fp Fingerprint, secret string,
+	serial *asserts.Serial, db asserts.RODatabase,
+	signer func([]byte) ([]byte, error),
+) (Identity, error) {
+	// calculate the HMAC that this device would use to authenticate itself
+	hmac := CalculateHMAC(rdt, fp, secret)
+
+	// sign the HMAC with our private key to create the SerialProof
+	proof, err := signer(hmac)
+	if err != nil {
+		return Identity{}, fmt.Errorf("cannot sign hmac for serial proof: %v", err)
+	}
+
+	bundle, err := BuildSerialBundle(serial, db)
+	if err != nil {
+		return Identity{}, fmt.Errorf("cannot build serial bundle: %w", err)
+	}
+
+	return Identity{
+		RDT:          rdt,
+		FP:           fp,
+		SerialBundle: bundle,
+		SerialProof:  proof,
+	}, nil
+}
+
 func CalculateHMAC(rdt DeviceToken, fp Fingerprint, secret string) []byte {
 	mac := hmac.New(sha512.New, []byte(secret))
 	mac.Write(fp[:])
@@ -849,7 +864,9 @@ func untrustedSend(
 	return client.Untrusted(ctx, addr, kind, data)
 }
 
-func buildSerialBundle(serial *asserts.Serial, db asserts.RODatabase) (string, error) {
+// BuildSerialBundle returns the encoded serial assertion together with the
+// assertions needed to verify it.
+func BuildSerialBundle(serial *asserts.Serial, db asserts.RODatabase) (string, error) {
 	retrieve := func(ref *asserts.Ref) (asserts.Assertion, error) {
 		return ref.Resolve(db.Find)
 	}
@@ -886,7 +903,7 @@ func ensureLocalDevicePresent(
 			// proof signatures will not be byte-identical across runs. thus, to
 			// check for consistency, we need to validate the existing proof
 			// instead of requiring equality
-			serial, err := verifySerialBundle(unverifiedID.SerialBundle, db)
+			serial, err := VerifySerialBundle(unverifiedID.SerialBundle, db)
 			if err != nil {
 				return fmt.Errorf("cannot validate serial bundle for local device %q: %w", self.RDT, err)
 			}
@@ -105,7 +105,7 @@ func createTestSerialBundle(
 	signing *assertstest.StoreStack,
 ) (*asserts.Serial, string, asserts.PrivateKey) {
 	serial, key := createTestSerial(c, signing)
-	bundle, err := buildSerialBundle(serial, signing.Database)
+	bundle, err := BuildSerialBundle(serial, signing.Database)
 	c.Assert(err, check.Equals, nil)
 	return serial, bundle, key
 }
@@ -1662,7 +1662,7 @@ func (s *clusterSuite) TestNewAssembleStateSessionImportAcceptsDifferentSerialPr
 	serial, ok := assertion.(*asserts.Serial)
 	c.Assert(ok, check.Equals, true)
 
-	bundle, err := buildSerialBundle(serial, signing.Database)
+	bundle, err := BuildSerialBundle(serial, signing.Database)
 	c.Assert(err, check.IsNil)
 
 	fp := CalculateFP(cert.Certificate[0])
@@ -1728,7 +1728,7 @@ func (s *clusterSuite) TestNewAssembleStateInvalidSessionData(c *check.C) {
 	cert, err := tls.X509KeyPair([]byte(cfg.TLSCert), []byte(cfg.TLSKey))
 	c.Assert(err, check.IsNil)
 	localFP := CalculateFP(cert.Certificate[0])
-	bundle, err := buildSerialBundle(cfg.Serial, db)
+	bundle, err := BuildSerialBundle(cfg.Serial, db)
 	c.Assert(err, check.IsNil)
 	proof, err := cfg.Signer(CalculateHMAC(cfg.RDT, localFP, cfg.Secret))
 	c.Assert(err, check.IsNil)
@@ -275,7 +275,10 @@ func (d *DeviceQueryTracker) Lookup(rdt DeviceToken) (Identity, bool) {
 	return id, ok
 }
 
-func verifySerialBundle(bundle string, db asserts.RODatabase) (*asserts.Serial, error) {
+// VerifySerialBundle checks the assertions of a bundle created with
+// [BuildSerialBundle] against db and returns the serial assertion it
+// contains.
+func VerifySerialBundle(bundle string, db asserts.RODatabase) (*asserts.Serial, error) {
 	if bundle == "" {
 		return nil, errors.New("serial bundle is empty")
 	}
@@ -323,27 +326,36 @@ func verifySerialBundle(bundle string, db asserts.RODatabase) (*asserts.Serial,
 }
 
 func (d *DeviceQueryTracker) validateID(id Identity) error {
-	serial, err := verifySerialBundle(id.SerialBundle, d.assertDB)
+	_, err := VerifyIdentity(id, d.secret, d.assertDB)
+	return err
+}
+
+// VerifyIdentity checks that the identity's serial bundle is valid and that
+// its serial proof was signed by the device key from the serial assertion,
+// over the HMAC calculated with the given shared secret. The verified serial
+// assertion is returned.
+func VerifyIdentity(id Identity, secret string, db asserts.RODatabase) (*asserts.Serial, error) {
+	serial, err := VerifySerialBundle(id.SerialBundle, db)
 	if err != nil {
-		return fmt.Errorf("invalid identity for device %s: %w", id.RDT, err)
+		return nil, fmt.Errorf("invalid identity for device %s: %w", id.RDT, err)
 	}
 
 	if len(id.SerialProof) == 0 {
-		return fmt.Errorf("device %s has empty serial proof", id.RDT)
+		return nil, fmt.Errorf("device %s has empty serial proof", id.RDT)
 	}
 
 	// extract device public key from serial assertion
 	key := serial.DeviceKey()
 
 	// calculate the HMAC that should have been signed
-	expectedHMAC := CalculateHMAC(id.RDT, id.FP, d.secret)
+	expectedHMAC := CalculateHMAC(id.RDT, id.FP, secret)
 
 	// verify the SerialProof is a valid signature of the HMAC
 	if err := asserts.RawVerifyWithKey(expectedHMAC, id.SerialProof, key); err != nil {
-		return fmt.Errorf("serial proof verification failed for device %s: %w", id.RDT, err)
+		return nil, fmt.Errorf("serial proof verification failed for device %s: %w", id.RDT, err)
 	}
 
-	return nil
+	return serial, nil
 }
 
 // RecordIdentity records identity information for a device.
@@ -21,6 +21,7 @@ package assemblestate_test
 
 import (
 	"bytes"
+	"errors"
 	"io"
 	"sort"
 	"strings"
@@ -1156,3 +1157,37 @@ func hasSignal(ch <-chan struct{}) bool {
 		return false
 	}
 }
+
+func (s *deviceTrackerSuite) TestNewAndVerifyIdentity(c *check.C) {
+	db, signing := mockAssertDB(c)
+	serial, key := createTestSerial(c, signing)
+
+	id, err := assemblestate.NewIdentity("rdt", assemblestate.Fingerprint{1}, "secret", serial, signing.Database, privateKeySigner(key))
+	c.Assert(err, check.IsNil)
+	c.Check(id.RDT, check.Equals, assemblestate.DeviceToken("rdt"))
+	c.Check(id.FP, check.Equals, assemblestate.Fingerprint{1})
+	c.Check(id.SerialBundle, check.Equals, buildSerialBundle(c, serial, signing.Database))
+
+	verified, err := assemblestate.VerifyIdentity(id, "secret", db)
+	c.Assert(err, check.IsNil)
+	c.Check(verified.Serial(), check.Equals, serial.Serial())
+
+	// the proof is bound to the shared secret
+	_, err = assemblestate.VerifyIdentity(id, "other-secret", db)
+	c.Check(err, check.ErrorMatches, "serial proof verification failed for device rdt: .*")
+
+	// and to the device token
+	id.RDT = "other-rdt"
+	_, err = assemblestate.VerifyIdentity(id, "secret", db)
+	c.Check(err, check.ErrorMatches, "serial proof verification failed for device other-rdt: .*")
+}
+
+func (s *deviceTrackerSuite) TestNewIdentitySignerError(c *check.C) {
+	_, signing := mockAssertDB(c)
+	serial, _ := createTestSerial(c, signing)
+
+	_, err := assemblestate.NewIdentity("rdt", assemblestate.Fingerprint{}, "secret", serial, signing.Database, func([]byte) ([]byte, error) {
+		return nil, errors.New("boom")
+	})
+	c.Check(err, check.ErrorMatches, "cannot sign hmac for serial proof: boom")
+}
@@ -0,0 +1,190 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package discovery advertises the address a device uses for cluster
+// assembly and finds the other devices taking part in the assembly on the
+// local network, using mDNS service discovery.
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/netutil/mdns"
+	"github.com/snapcore/snapd/randutil"
+)
+
+// ServiceType is the DNS-SD service type under which devices assembling a
+// cluster are advertised.
+const ServiceType = "_snapd-cluster._tcp"
+
+var (
+	defaultPeriod        = 5 * time.Second
+	defaultBrowseTimeout = 1 * time.Second
+)
+
+// Options customizes a Discovery.
+type Options struct {
+	// MDNSConn is the connection mDNS queries are answered on, defaults to
+	// a connection joined to the mDNS multicast group. It is closed once
+	// Run returns.
+	MDNSConn net.PacketConn
+	// QueryAddr is where queries for peers are sent, defaults to the mDNS
+	// multicast group.
+	QueryAddr net.Addr
+	// Period is how often peers are looked for.
+	Period time.Duration
+	// BrowseTimeout is how long answers from peers are waited for.
+	BrowseTimeout time.Duration
+}
+
+// Discovery advertises an assembly address and looks for the addresses of
+// the peers.
+type Discovery struct {
+	ip       net.IP
+	port     int
+	instance string
+	opts     Options
+}
+
+// New returns a Discovery advertising address, which must be an IPv4 address
+// and a port.
+func New(address string, opts *Options) (*Discovery, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, fmt.Errorf("cannot advertise address %q: %v", address, err)
+	}
+	ip := net.ParseIP(host).To4()
+	if ip == nil {
+		return nil, fmt.Errorf("cannot advertise address %q: host must be an IPv4 address", address)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil || port <= 0 || port > 65535 {
+		return nil, fmt.Errorf("cannot advertise address %q: invalid port", address)
+	}
+
+	d := &Discovery{
+		ip:       ip,
+		port:     port,
+		instance: "snapd-" + strings.ToLower(randutil.RandomString(12)),
+	}
+	if opts != nil {
+		d.opts = *opts
+	}
+	if d.opts.QueryAddr == nil {
+		d.opts.QueryAddr = mdns.GroupAddr
+	}
+	if d.opts.Period == 0 {
+		d.opts.Period = defaultPeriod
+	}
+	if d.opts.BrowseTimeout == 0 {
+		d.opts.BrowseTimeout = defaultBrowseTimeout
+	}
+	return d, nil
+}
+
+// Instance returns the name under which the address is advertised.
+func (d *Discovery) Instance() string {
+	return d.instance
+}
+
+var mdnsListen = mdns.Listen
+
+// Run advertises the address and periodically sends the addresses of the
+// peers found to discoveries, until the context is done.
+func (d *Discovery) Run(ctx context.Context, discoveries chan<- []string) error {
+	conn := d.opts.MDNSConn
+	if conn == nil {
+		var err error
+		conn, err = mdnsListen(nil)
+		if err != nil {
+			return fmt.Errorf("cannot listen for mDNS queries: %v", err)
+		}
+	}
+	defer conn.Close()
+
+	responder, err := mdns.NewResponder(conn, mdns.GroupAddr, mdns.Service{
+		Instance: d.instance,
+		Service:  ServiceType,
+		Port:     d.port,
+		IPs:      []net.IP{d.ip},
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	served := make(chan error, 1)
+	go func() { served <- responder.Serve(ctx) }()
+
+	for {
+		peers, err := d.browse(ctx)
+		if err != nil {
+			logger.Debugf("cannot look for cluster peers: %v", err)
+		}
+		if len(peers) > 0 {
+			select {
+			case discoveries <- peers:
+			case <-ctx.Done():
+			}
+		}
+
+		select {
+		case <-time.After(d.opts.Period):
+		case err := <-served:
+			if err != nil {
+				return fmt.Errorf("cannot answer mDNS queries: %v", err)
+			}
+			return nil
+		case <-ctx.Done():
+			return <-served
+		}
+	}
+}
+
+func (d *Discovery) browse(ctx context.Context) ([]string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, d.opts.BrowseTimeout)
+	defer cancel()
+	entries, err := mdns.Browse(ctx, conn, d.opts.QueryAddr, ServiceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var peers []string
+	for _, e := range entries {
+		if strings.EqualFold(e.Instance, d.instance) {
+			continue
+		}
+		peers = append(peers, e.Addresses()...)
+	}
+	return peers, nil
+}
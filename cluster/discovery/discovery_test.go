@@ -0,0 +1,168 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package discovery_test
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/cluster/discovery"
+	"github.com/snapcore/snapd/netutil/mdns"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type discoverySuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&discoverySuite{})
+
+func listenLoopback(c *C) net.PacketConn {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	return conn
+}
+
+// run runs d in the background until the end of the test.
+func (s *discoverySuite) run(c *C, d *discovery.Discovery, discoveries chan<- []string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- d.Run(ctx, discoveries) }()
+	s.AddCleanup(func() {
+		cancel()
+		c.Check(<-done, IsNil)
+	})
+}
+
+func (s *discoverySuite) TestRunFindsPeer(c *C) {
+	peerConn := listenLoopback(c)
+	peer, err := discovery.New("10.0.0.7:7070", &discovery.Options{
+		MDNSConn: peerConn,
+		Period:   time.Hour,
+	})
+	c.Assert(err, IsNil)
+	s.run(c, peer, make(chan []string))
+
+	d, err := discovery.New("10.0.0.8:7070", &discovery.Options{
+		MDNSConn:      listenLoopback(c),
+		QueryAddr:     peerConn.LocalAddr(),
+		Period:        10 * time.Millisecond,
+		BrowseTimeout: 50 * time.Millisecond,
+	})
+	c.Assert(err, IsNil)
+	c.Check(d.Instance(), Matches, "snapd-[a-z0-9]{12}")
+	c.Check(d.Instance(), Not(Equals), peer.Instance())
+
+	discoveries := make(chan []string)
+	s.run(c, d, discoveries)
+
+	select {
+	case addrs := <-discoveries:
+		c.Check(addrs, DeepEquals, []string{"10.0.0.7:7070"})
+	case <-time.After(5 * time.Second):
+		c.Fatal("timed out waiting for discoveries")
+	}
+}
+
+func (s *discoverySuite) TestRunIgnoresSelf(c *C) {
+	conn := listenLoopback(c)
+	d, err := discovery.New("10.0.0.7:7070", &discovery.Options{
+		MDNSConn:      conn,
+		QueryAddr:     conn.LocalAddr(),
+		Period:        10 * time.Millisecond,
+		BrowseTimeout: 20 * time.Millisecond,
+	})
+	c.Assert(err, IsNil)
+
+	discoveries := make(chan []string)
+	s.run(c, d, discoveries)
+
+	select {
+	case addrs := <-discoveries:
+		c.Errorf("unexpected discoveries: %v", addrs)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func (s *discoverySuite) TestRunIgnoresOtherServices(c *C) {
+	conn := listenLoopback(c)
+	defer conn.Close()
+	r, err := mdns.NewResponder(conn, mdns.GroupAddr, mdns.Service{
+		Instance: "cache",
+		Service:  "_snapd-cache._tcp",
+		Port:     4242,
+	})
+	c.Assert(err, IsNil)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Serve(ctx)
+
+	d, err := discovery.New("10.0.0.8:7070", &discovery.Options{
+		MDNSConn:      listenLoopback(c),
+		QueryAddr:     conn.LocalAddr(),
+		Period:        10 * time.Millisecond,
+		BrowseTimeout: 20 * time.Millisecond,
+	})
+	c.Assert(err, IsNil)
+
+	discoveries := make(chan []string)
+	s.run(c, d, discoveries)
+
+	select {
+	case addrs := <-discoveries:
+		c.Errorf("unexpected discoveries: %v", addrs)
+	case <-time.After(200 * time.Millisecond):
+	}
+}
+
+func (s *discoverySuite) TestRunListenError(c *C) {
+	s.AddCleanup(discovery.MockMDNSListen(func(iface *net.Interface) (net.PacketConn, error) {
+		c.Check(iface, IsNil)
+		return nil, errors.New("boom")
+	}))
+
+	d, err := discovery.New("10.0.0.7:7070", nil)
+	c.Assert(err, IsNil)
+
+	err = d.Run(context.Background(), make(chan []string))
+	c.Check(err, ErrorMatches, "cannot listen for mDNS queries: boom")
+}
+
+func (s *discoverySuite) TestNewErrors(c *C) {
+	for _, tc := range []struct {
+		addr, err string
+	}{
+		{"10.0.0.7", `cannot advertise address "10.0.0.7": .*missing port.*`},
+		{"host:7070", `cannot advertise address "host:7070": host must be an IPv4 address`},
+		{"[::1]:7070", `cannot advertise address "\[::1\]:7070": host must be an IPv4 address`},
+		{"10.0.0.7:0", `cannot advertise address "10.0.0.7:0": invalid port`},
+		{"10.0.0.7:http", `cannot advertise address "10.0.0.7:http": invalid port`},
+	} {
+		_, err := discovery.New(tc.addr, nil)
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s", tc.addr))
+	}
+}
@@ -119,6 +119,10 @@ func findCommand(app *snap.AppInfo, command string) (string, error) {
 		cmd = app.ReloadCommand
 	case "post-stop":
 		cmd = app.PostStopCommand
+	case "probe":
+		if app.LivenessProbe != nil {
+			cmd = app.LivenessProbe.Command
+		}
 	case "", "gdb", "gdbserver":
 		cmd = app.Command
 	default:
@@ -64,6 +64,9 @@ apps:
   stop-command: stop-app
   post-stop-command: post-stop-app
   completer: you/complete/me
+  daemon: simple
+  liveness-probe:
+   exec: probe-app
   environment:
    BASE_PATH: /some/path
    LD_LIBRARY_PATH: ${BASE_PATH}/lib
@@ -139,6 +142,7 @@ func (s *snapExecSuite) TestFindCommand(c *C) {
 		{cmd: "", expected: `run-app cmd-arg1 $SNAP_DATA`},
 		{cmd: "stop", expected: "stop-app"},
 		{cmd: "post-stop", expected: "post-stop-app"},
+		{cmd: "probe", expected: "probe-app"},
 	} {
 		cmd, err := snapExec.FindCommand(info.Apps["app"], t.cmd)
 		c.Check(err, IsNil)
@@ -160,6 +164,9 @@ func (s *snapExecSuite) TestFindCommandNoCommand(c *C) {
 
 	_, err = snapExec.FindCommand(info.Apps["nostop"], "stop")
 	c.Check(err, ErrorMatches, `no "stop" command found for "nostop"`)
+
+	_, err = snapExec.FindCommand(info.Apps["nostop"], "probe")
+	c.Check(err, ErrorMatches, `no "probe" command found for "nostop"`)
 }
 
 func (s *snapExecSuite) TestSnapExecAppIntegration(c *C) {
@@ -43,6 +43,7 @@ change.
 type cmdChanges struct {
 	clientMixin
 	timeMixin
+	Archived   bool `long:"archived"`
 	Positional struct {
 		Snap string `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -55,7 +56,10 @@ type cmdTasks struct {
 
 func init() {
 	addCommand("changes", shortChangesHelp, longChangesHelp,
-		func() flags.Commander { return &cmdChanges{} }, timeDescs, nil)
+		func() flags.Commander { return &cmdChanges{} }, timeDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"archived": i18n.G("Show changes pruned from the system state and kept in the change archive"),
+		}), nil)
 	addCommand("tasks", shortTasksHelp, longTasksHelp,
 		func() flags.Commander { return &cmdTasks{} },
 		changeIDMixinOptDesc.also(timeDescs),
@@ -100,6 +104,9 @@ func (c *cmdChanges) Execute(args []string) error {
 		SnapName: c.Positional.Snap,
 		Selector: client.ChangesAll,
 	}
+	if c.Archived {
+		opts.Selector = client.ChangesArchived
+	}
 
 	changes, err := queryChanges(c.client, &opts)
 	if err != nil {
@@ -0,0 +1,525 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/signtool"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdCluster struct{}
+
+var shortClusterHelp = i18n.G("Manage clusters of devices")
+var longClusterHelp = i18n.G(`
+The cluster command contains sub-commands to organize devices into a
+cluster, described by a cluster assertion.
+`)
+
+var shortClusterAssembleHelp = i18n.G("Assemble a new cluster")
+var longClusterAssembleHelp = i18n.G(`
+The assemble command discovers and authenticates the other devices taking
+part in the assembly, which must be started on each of them with the same
+secret and expected size. Once all the devices are connected to each other,
+the resulting cluster assertion is signed with the given key and written to
+standard output, followed by the account-key and account assertions needed
+to validate it.
+
+The clustering experimental feature must be enabled.
+`)
+
+type cmdClusterAssemble struct {
+	waitMixin
+	Secret       string   `long:"secret" required:"yes"`
+	Address      string   `long:"address" required:"yes"`
+	ExpectedSize int      `long:"expected-size" required:"yes"`
+	Peers        []string `long:"peer"`
+	KeyName      keyName  `short:"k" default:"default"`
+}
+
+func init() {
+	addClusterCommand("assemble",
+		shortClusterAssembleHelp,
+		longClusterAssembleHelp,
+		func() flags.Commander {
+			return &cmdClusterAssemble{}
+		}, waitDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"secret": i18n.G("Secret shared by all the devices assembling the cluster"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"address": i18n.G("IP address and port to listen on for the other devices"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"expected-size": i18n.G("Number of devices in the cluster"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"peer": i18n.G("Address of another device taking part in the assembly (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"k": i18n.G("Name of the key used to sign the cluster assertion, otherwise use the default key"),
+		}), nil)
+}
+
+func (x *cmdClusterAssemble) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	// check the key up front, assembly is not something to redo just
+	// because it cannot be signed
+	keypairMgr, privKey, err := clusterSigningKey(x.KeyName)
+	if err != nil {
+		return err
+	}
+
+	changeID, err := x.client.AssembleCluster(&client.AssembleClusterOptions{
+		Secret:       x.Secret,
+		Address:      x.Address,
+		ExpectedSize: x.ExpectedSize,
+		Peers:        x.Peers,
+	})
+	if err != nil {
+		return err
+	}
+
+	chg, err := x.wait(changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var headers map[string]any
+	if err := chg.Get("cluster-assertion", &headers); err != nil {
+		return fmt.Errorf(i18n.G("cannot get cluster assertion from change %s: %v"), changeID, err)
+	}
+
+	bundle, err := signClusterAssertion(headers, keypairMgr, privKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = Stdout.Write(bundle)
+	return err
+}
+
+var shortClusterJoinRequestHelp = i18n.G("Request to join an existing cluster")
+var longClusterJoinRequestHelp = i18n.G(`
+The join-request command writes to standard output a request for this device
+to join an existing cluster. The request is authenticated with the given
+secret and must be handed, along with the secret, to one of the devices of
+the cluster, where it is processed by 'snap cluster add-device'.
+
+The clustering experimental feature must be enabled.
+`)
+
+type cmdClusterJoinRequest struct {
+	clientMixin
+	Secret  string `long:"secret" required:"yes"`
+	Address string `long:"address" required:"yes"`
+}
+
+var shortClusterAddDeviceHelp = i18n.G("Add a device to the cluster")
+var longClusterAddDeviceHelp = i18n.G(`
+The add-device command verifies the given join request of a device, created
+with 'snap cluster join-request', and writes to standard output the next
+cluster assertion, which adds the device to the cluster, signed with the
+given key and followed by the account-key and account assertions needed to
+validate it. The key must belong to the account that signed the cluster
+assertion.
+
+The result is installed on every device of the cluster, including the new
+one, with 'snap cluster update'.
+`)
+
+type cmdClusterAddDevice struct {
+	clientMixin
+	Secret      string   `long:"secret" required:"yes"`
+	Subclusters []string `long:"subcluster"`
+	KeyName     keyName  `short:"k" default:"default"`
+	Positional  struct {
+		Request flags.Filename `required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortClusterRemoveDeviceHelp = i18n.G("Remove a device from the cluster")
+var longClusterRemoveDeviceHelp = i18n.G(`
+The remove-device command writes to standard output the next cluster
+assertion, which removes the device with the given id from the cluster,
+signed with the given key and followed by the account-key and account
+assertions needed to validate it.
+
+The removed device leaves all of its subclusters. A subcluster cannot be left
+without devices: a replacement device can be given to take over the
+subclusters of the removed device, and thus their snaps.
+
+The result is installed on the remaining devices of the cluster with
+'snap cluster update'.
+`)
+
+type cmdClusterRemoveDevice struct {
+	clientMixin
+	Replacement int     `long:"replacement"`
+	KeyName     keyName `short:"k" default:"default"`
+	Positional  struct {
+		Device int `required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortClusterUpdateHelp = i18n.G("Install a new cluster assertion")
+var longClusterUpdateHelp = i18n.G(`
+The update command installs the given cluster assertion, along with its
+prerequisite assertions, as produced by 'snap cluster add-device' or
+'snap cluster remove-device'. A device that is not part of a cluster yet
+joins the cluster.
+`)
+
+type cmdClusterUpdate struct {
+	clientMixin
+	Positional struct {
+		Bundle flags.Filename `required:"yes"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+var shortClusterRolloutHelp = i18n.G("Show the rollout state of the cluster")
+var longClusterRolloutHelp = i18n.G(`
+The rollout command shows how far the devices of the subclusters setting a
+maximum number of devices updating at once are in applying the current
+cluster assertion. Such devices take turns in the order of their ids, each
+waiting for the devices ahead of it to be done and healthy.
+`)
+
+type cmdClusterRollout struct {
+	clientMixin
+}
+
+var shortClusterEvacuatedHelp = i18n.G("List the snaps evacuated from this device")
+var longClusterEvacuatedHelp = i18n.G(`
+The evacuated command lists the snaps that the cluster assertion evacuates
+from this device. Their services are stopped and disabled, while their
+revisions and data are kept so they can be restored later.
+`)
+
+type cmdClusterEvacuated struct {
+	clientMixin
+}
+
+func init() {
+	addClusterCommand("rollout",
+		shortClusterRolloutHelp,
+		longClusterRolloutHelp,
+		func() flags.Commander {
+			return &cmdClusterRollout{}
+		}, nil, nil)
+	addClusterCommand("evacuated",
+		shortClusterEvacuatedHelp,
+		longClusterEvacuatedHelp,
+		func() flags.Commander {
+			return &cmdClusterEvacuated{}
+		}, nil, nil)
+	addClusterCommand("join-request",
+		shortClusterJoinRequestHelp,
+		longClusterJoinRequestHelp,
+		func() flags.Commander {
+			return &cmdClusterJoinRequest{}
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"secret": i18n.G("Secret shared with the device of the cluster adding this one"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"address": i18n.G("IP address and port at which the other devices can reach this one"),
+		}, nil)
+	addClusterCommand("add-device",
+		shortClusterAddDeviceHelp,
+		longClusterAddDeviceHelp,
+		func() flags.Commander {
+			return &cmdClusterAddDevice{}
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"secret": i18n.G("Secret the join request was created with"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"subcluster": i18n.G("Subcluster to add the device to (can be repeated)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"k": i18n.G("Name of the key used to sign the cluster assertion, otherwise use the default key"),
+		}, []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<join-request>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("File containing the join request of the device"),
+		}})
+	addClusterCommand("remove-device",
+		shortClusterRemoveDeviceHelp,
+		longClusterRemoveDeviceHelp,
+		func() flags.Commander {
+			return &cmdClusterRemoveDevice{}
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"replacement": i18n.G("Id of the device taking over the subclusters of the removed device"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"k": i18n.G("Name of the key used to sign the cluster assertion, otherwise use the default key"),
+		}, []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<device-id>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("Id of the device in the cluster assertion"),
+		}})
+	addClusterCommand("update",
+		shortClusterUpdateHelp,
+		longClusterUpdateHelp,
+		func() flags.Commander {
+			return &cmdClusterUpdate{}
+		}, nil, []argDesc{{
+			// TRANSLATORS: This needs to begin with < and end with >
+			name: i18n.G("<assertions>"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			desc: i18n.G("File containing the cluster assertion and its prerequisites"),
+		}})
+}
+
+func (x *cmdClusterJoinRequest) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	req, err := x.client.ClusterJoinRequest(&client.ClusterJoinRequestOptions{
+		Secret:  x.Secret,
+		Address: x.Address,
+	})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(Stdout, "%s\n", req)
+	return nil
+}
+
+func (x *cmdClusterAddDevice) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	req, err := os.ReadFile(string(x.Positional.Request))
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read join request: %v"), err)
+	}
+
+	keypairMgr, privKey, err := clusterSigningKey(x.KeyName)
+	if err != nil {
+		return err
+	}
+
+	headers, err := x.client.AddClusterDevice(&client.AddClusterDeviceOptions{
+		Secret:      x.Secret,
+		Request:     json.RawMessage(req),
+		Subclusters: x.Subclusters,
+	})
+	if err != nil {
+		return err
+	}
+
+	bundle, err := signClusterAssertion(headers, keypairMgr, privKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = Stdout.Write(bundle)
+	return err
+}
+
+func (x *cmdClusterRemoveDevice) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	keypairMgr, privKey, err := clusterSigningKey(x.KeyName)
+	if err != nil {
+		return err
+	}
+
+	headers, err := x.client.RemoveClusterDevice(&client.RemoveClusterDeviceOptions{
+		Device:      x.Positional.Device,
+		Replacement: x.Replacement,
+	})
+	if err != nil {
+		return err
+	}
+
+	bundle, err := signClusterAssertion(headers, keypairMgr, privKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = Stdout.Write(bundle)
+	return err
+}
+
+func (x *cmdClusterUpdate) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	bundle, err := os.ReadFile(string(x.Positional.Bundle))
+	if err != nil {
+		return fmt.Errorf(i18n.G("cannot read cluster assertions: %v"), err)
+	}
+
+	return x.client.UpdateCluster(bundle)
+}
+
+func (x *cmdClusterRollout) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	rollouts, err := x.client.ClusterRollout()
+	if err != nil {
+		return err
+	}
+
+	if len(rollouts) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No subcluster of the cluster limits the number of devices updating at once."))
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Device\tSubcluster\tSequence\tStatus\tHealth"))
+	var unreachable []client.ClusterDeviceRollout
+	for _, rollout := range rollouts {
+		if rollout.Error != "" {
+			fmt.Fprintf(w, "%d\t-\t-\t-\t-\n", rollout.Device)
+			unreachable = append(unreachable, rollout)
+			continue
+		}
+
+		names := make([]string, 0, len(rollout.Subclusters))
+		for name := range rollout.Subclusters {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+
+		for _, name := range names {
+			sr := rollout.Subclusters[name]
+			health := i18n.G("healthy")
+			if !sr.Healthy {
+				health = i18n.G("unhealthy")
+			}
+			fmt.Fprintf(w, "%d\t%s\t%d\t%s\t%s\n", rollout.Device, name, rollout.Sequence, sr.Status, health)
+		}
+	}
+	w.Flush()
+
+	for _, rollout := range unreachable {
+		// TRANSLATORS: %d is the id of the device, %s the error message
+		fmt.Fprintf(Stderr, i18n.G("cannot get rollout state of device %d: %s\n"), rollout.Device, rollout.Error)
+	}
+	return nil
+}
+
+func (x *cmdClusterEvacuated) Execute(args []string) error {
+	if len(args) > 0 {
+		return ErrExtraArgs
+	}
+
+	names, err := x.client.ClusterEvacuatedSnaps()
+	if err != nil {
+		return err
+	}
+
+	if len(names) == 0 {
+		fmt.Fprintln(Stderr, i18n.G("No snaps are evacuated from this device."))
+		return nil
+	}
+
+	for _, name := range names {
+		fmt.Fprintln(Stdout, name)
+	}
+	return nil
+}
+
+func clusterSigningKey(name keyName) (signtool.KeypairManager, asserts.PrivateKey, error) {
+	keypairMgr, err := signtool.GetKeypairManager()
+	if err != nil {
+		return nil, nil, err
+	}
+	privKey, err := keypairMgr.GetByName(string(name))
+	if err != nil {
+		// TRANSLATORS: %q is the key name, %v the error message
+		return nil, nil, fmt.Errorf(i18n.G("cannot use %q key: %v"), name, err)
+	}
+	return keypairMgr, privKey, nil
+}
+
+// signClusterAssertion signs a cluster assertion with the given headers on
+// behalf of the account owning privKey, and returns it along with the
+// account-key and account assertions. Updates of an existing cluster carry
+// the authority of the cluster already, which must own the key.
+func signClusterAssertion(headers map[string]any, keypairMgr signtool.KeypairManager, privKey asserts.PrivateKey) ([]byte, error) {
+	ak, err := mustGetOneAssert("account-key", map[string]string{"public-key-sha3-384": privKey.PublicKey().ID()})
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot sign cluster assertion: %v"), err)
+	}
+	accountKey := ak.(*asserts.AccountKey)
+
+	account, err := mustGetOneAssert("account", map[string]string{"account-id": accountKey.AccountID()})
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot sign cluster assertion: %v"), err)
+	}
+
+	statement, err := json.Marshal(headers)
+	if err != nil {
+		return nil, err
+	}
+
+	var complement map[string]any
+	if _, ok := headers["authority-id"]; !ok {
+		complement = map[string]any{
+			"authority-id": accountKey.AccountID(),
+		}
+	}
+
+	encoded, err := signtool.Sign(&signtool.Options{
+		KeyID:      privKey.PublicKey().ID(),
+		AccountKey: accountKey,
+		Statement:  statement,
+		Complement: complement,
+	}, keypairMgr)
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot sign cluster assertion: %v"), err)
+	}
+
+	var buf bytes.Buffer
+	enc := asserts.NewEncoder(&buf)
+	if err := enc.WriteEncoded(encoded); err != nil {
+		return nil, err
+	}
+	for _, a := range []asserts.Assertion{accountKey, account} {
+		if err := enc.Encode(a); err != nil {
+			return nil, err
+		}
+	}
+
+	return buf.Bytes(), nil
+}
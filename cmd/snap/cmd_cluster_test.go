@@ -0,0 +1,358 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	snap "github.com/snapcore/snapd/cmd/snap"
+	"github.com/snapcore/snapd/store"
+)
+
+const mockClusterChange = `{"type": "sync", "result": {
+  "ready": true,
+  "status": "Done",
+  "data": {"cluster-assertion": {
+    "type": "cluster",
+    "cluster-id": "bf3675f5-cffa-40f4-a119-7492ccc08e04",
+    "sequence": "1",
+    "devices": [{
+      "id": "1",
+      "device": "9cc45ad6-d01b-4efd-9f76-db55b76c076b.ubuntu-core-24-amd64.canonical",
+      "addresses": ["192.168.1.10"]
+    }],
+    "timestamp": "2026-01-01T00:00:00Z"
+  }}
+}}`
+
+func (s *SnapKeysSuite) TestClusterAssemble(c *C) {
+	var server *httptest.Server
+
+	restorer := snap.MockStoreNew(func(cfg *store.Config, stoCtx store.DeviceAndAuthContext) *store.Store {
+		if cfg == nil {
+			cfg = store.DefaultConfig()
+		}
+		serverURL, _ := url.Parse(server.URL)
+		cfg.AssertionsBaseURL = serverURL
+		return store.New(cfg, stoCtx)
+	})
+	defer restorer()
+
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		switch r.URL.Path {
+		case "/v2/assertions/account-key/g4Pks54W_US4pZuxhgG_RHNAf_UeZBBuZyGRLLmMj1Do3GkE_r_5A5BFjx24ZwVJ":
+			fmt.Fprint(w, mockAccountKeyAssertion)
+		case "/v2/assertions/account/devel1":
+			fmt.Fprint(w, mockAccountAssertion)
+		default:
+			c.Fatalf("unexpected store request %q", r.URL.Path)
+		}
+	}))
+	defer server.Close()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/cluster")
+			body, err := io.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"assemble","secret":"secret","address":"192.168.1.10:7070","expected-size":1,"peers":["192.168.1.11:7070"]}`+"\n")
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type": "async", "change": "42", "status-code": 202}`)
+		case 1:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/changes/42")
+			fmt.Fprintln(w, mockClusterChange)
+		default:
+			c.Fatalf("expected to get 2 requests, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "assemble",
+		"--secret", "secret", "--address", "192.168.1.10:7070", "--expected-size", "1",
+		"--peer", "192.168.1.11:7070", "-k", "default"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 2)
+	s.checkSignChainResults(c, asserts.ClusterType)
+}
+
+func (s *SnapKeysSuite) TestClusterAssembleUnknownKey(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request to snapd")
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "assemble",
+		"--secret", "secret", "--address", "192.168.1.10:7070", "--expected-size", "1",
+		"-k", "nonexistent"})
+	c.Assert(err, ErrorMatches, `cannot use "nonexistent" key: .*`)
+}
+
+// mockClusterAssertionStore serves the account-key and account assertions of
+// the test key.
+func mockClusterAssertionStore(c *C) (restore func()) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "GET")
+		switch r.URL.Path {
+		case "/v2/assertions/account-key/g4Pks54W_US4pZuxhgG_RHNAf_UeZBBuZyGRLLmMj1Do3GkE_r_5A5BFjx24ZwVJ":
+			fmt.Fprint(w, mockAccountKeyAssertion)
+		case "/v2/assertions/account/devel1":
+			fmt.Fprint(w, mockAccountAssertion)
+		default:
+			c.Fatalf("unexpected store request %q", r.URL.Path)
+		}
+	}))
+
+	restorer := snap.MockStoreNew(func(cfg *store.Config, stoCtx store.DeviceAndAuthContext) *store.Store {
+		if cfg == nil {
+			cfg = store.DefaultConfig()
+		}
+		serverURL, _ := url.Parse(server.URL)
+		cfg.AssertionsBaseURL = serverURL
+		return store.New(cfg, stoCtx)
+	})
+	return func() {
+		restorer()
+		server.Close()
+	}
+}
+
+const mockNextClusterAssertion = `{"type": "sync", "result": {"cluster-assertion": {
+  "type": "cluster",
+  "authority-id": "%s",
+  "cluster-id": "bf3675f5-cffa-40f4-a119-7492ccc08e04",
+  "sequence": "2",
+  "devices": [{
+    "id": "1",
+    "device": "9cc45ad6-d01b-4efd-9f76-db55b76c076b.ubuntu-core-24-amd64.canonical",
+    "addresses": ["192.168.1.10:7070"]
+  }],
+  "timestamp": "2026-01-01T00:00:00Z"
+}}}`
+
+func (s *SnapKeysSuite) TestClusterAddDevice(c *C) {
+	defer mockClusterAssertionStore(c)()
+
+	reqFile := filepath.Join(c.MkDir(), "request.json")
+	c.Assert(os.WriteFile(reqFile, []byte(`{"identity": {"rdt": "rdt"}, "address": "192.168.1.30:7070"}`+"\n"), 0644), IsNil)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/cluster")
+			body, err := io.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"add-device","secret":"secret","request":{"identity":{"rdt":"rdt"},"address":"192.168.1.30:7070"},"subclusters":["default"]}`+"\n")
+			fmt.Fprintf(w, mockNextClusterAssertion, "devel1")
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "add-device",
+		"--secret", "secret", "--subcluster", "default", reqFile})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 1)
+	s.checkSignChainResults(c, asserts.ClusterType)
+}
+
+func (s *SnapKeysSuite) TestClusterRemoveDevice(c *C) {
+	defer mockClusterAssertionStore(c)()
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/cluster")
+			body, err := io.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"remove-device","device":2,"replacement":3}`+"\n")
+			fmt.Fprintf(w, mockNextClusterAssertion, "devel1")
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "remove-device", "--replacement", "3", "2"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 1)
+	s.checkSignChainResults(c, asserts.ClusterType)
+}
+
+func (s *SnapKeysSuite) TestClusterRemoveDeviceOtherAuthority(c *C) {
+	defer mockClusterAssertionStore(c)()
+
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, mockNextClusterAssertion, "other-account")
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "remove-device", "2"})
+	c.Assert(err, ErrorMatches, "cannot sign cluster assertion: authority-id does not match the account-id of the signing account-key")
+}
+
+func (s *SnapSuite) TestClusterJoinRequest(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/cluster")
+			body, err := io.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"join-request","secret":"secret","address":"192.168.1.30:7070"}`+"\n")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"identity": {"rdt": "rdt"}, "address": "192.168.1.30:7070"}}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "join-request", "--secret", "secret", "--address", "192.168.1.30:7070"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 1)
+	c.Check(s.Stdout(), Equals, `{"identity": {"rdt": "rdt"}, "address": "192.168.1.30:7070"}`+"\n")
+}
+
+func (s *SnapSuite) TestClusterUpdate(c *C) {
+	bundleFile := filepath.Join(c.MkDir(), "cluster.assert")
+	c.Assert(os.WriteFile(bundleFile, []byte("bundle"), 0644), IsNil)
+
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "POST")
+			c.Check(r.URL.Path, Equals, "/v2/cluster")
+			body, err := io.ReadAll(r.Body)
+			c.Check(err, IsNil)
+			c.Check(string(body), Equals, `{"action":"update","assertions":"bundle"}`+"\n")
+			fmt.Fprintln(w, `{"type": "sync", "result": null}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "update", bundleFile})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 1)
+	c.Check(s.Stdout(), Equals, "")
+
+	_, err = snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "update", filepath.Join(c.MkDir(), "missing")})
+	c.Check(err, ErrorMatches, "cannot read cluster assertions: .*")
+}
+
+func (s *SnapSuite) TestClusterRollout(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/cluster/rollout")
+			fmt.Fprintln(w, `{"type": "sync", "result": [
+				{"device": 1, "device-id": "serial-1.model.brand", "sequence": 2, "subclusters": {"ha": {"status": "done", "healthy": true}, "db": {"status": "done", "healthy": false}}},
+				{"device": 2, "device-id": "serial-2.model.brand", "sequence": 2, "subclusters": {"ha": {"status": "applying", "healthy": true}}},
+				{"device": 3, "device-id": "serial-3.model.brand", "error": "connection refused"}
+			]}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "rollout"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 1)
+	c.Check(s.Stdout(), Equals, `
+Device  Subcluster  Sequence  Status    Health
+1       db          2         done      unhealthy
+1       ha          2         done      healthy
+2       ha          2         applying  healthy
+3       -           -         -         -
+`[1:])
+	c.Check(s.Stderr(), Equals, "cannot get rollout state of device 3: connection refused\n")
+}
+
+func (s *SnapSuite) TestClusterRolloutNothingCoordinated(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "rollout"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No subcluster of the cluster limits the number of devices updating at once.\n")
+}
+
+func (s *SnapSuite) TestClusterEvacuated(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, Equals, "GET")
+			c.Check(r.URL.Path, Equals, "/v2/cluster/evacuated")
+			fmt.Fprintln(w, `{"type": "sync", "result": ["bar", "foo"]}`)
+		default:
+			c.Fatalf("expected to get 1 request, now on %d", n+1)
+		}
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "evacuated"})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(n, Equals, 1)
+	c.Check(s.Stdout(), Equals, "bar\nfoo\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
+func (s *SnapSuite) TestClusterEvacuatedNone(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, `{"type": "sync", "result": []}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"cluster", "evacuated"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "")
+	c.Check(s.Stderr(), Equals, "No snaps are evacuated from this device.\n")
+}
@@ -20,7 +20,11 @@
 package main
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"text/tabwriter"
 	"time"
 
@@ -32,7 +36,9 @@ import (
 )
 
 const longSnapsCacheHelp = `
-Show statistics of the local snap downloads cache.
+Show statistics of the local snap downloads cache. Entries still in use are
+listed with the snap or component blob they are used by, including the blobs
+rebuilt from deltas.
 `
 
 type cmdSnapDownloadsCache struct {
@@ -65,6 +71,43 @@ func boolYesNo(b bool) string {
 	return "no"
 }
 
+// blobInfos returns the infos of the snap and component blobs, which share
+// their file with the cache entries they were downloaded to or rebuilt from
+// deltas as.
+func blobInfos() ([]os.FileInfo, error) {
+	entries, err := os.ReadDir(dirs.SnapBlobDir)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var infos []os.FileInfo
+	for _, entry := range entries {
+		ext := filepath.Ext(entry.Name())
+		if ext != ".snap" && ext != ".comp" {
+			continue
+		}
+		fi, err := entry.Info()
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, fi)
+	}
+	return infos, nil
+}
+
+// cacheEntryBlob returns the name of the blob using the cache entry, or ""
+// if there is none.
+func cacheEntryBlob(entry os.FileInfo, blobs []os.FileInfo) string {
+	for _, blob := range blobs {
+		if os.SameFile(entry, blob) {
+			return blob.Name()
+		}
+	}
+	return ""
+}
+
 func (x *cmdSnapDownloadsCache) Execute(args []string) error {
 	cacheDir := dirs.SnapDownloadCacheDir
 
@@ -97,6 +140,11 @@ func (x *cmdSnapDownloadsCache) Execute(args []string) error {
 		return fmt.Errorf("cannot obtain cache stats: %w", err)
 	}
 
+	blobs, err := blobInfos()
+	if err != nil {
+		return fmt.Errorf("cannot obtain snap blobs: %w", err)
+	}
+
 	// TODO add ability to invoke cleanup?
 
 	fmt.Fprintf(Stdout, "Cache location: %v\n", cacheDir)
@@ -108,11 +156,20 @@ func (x *cmdSnapDownloadsCache) Execute(args []string) error {
 	fmt.Fprintf(Stdout, "Total size: %v\n", quantity.FormatAmount(stats.TotalSize, -1))
 	removedSize := int64(0)
 	candidatesSize := int64(0)
+	snapsSize := int64(0)
+	componentsSize := int64(0)
 	if len(stats.Entries) > 0 {
 		tw := tabwriter.NewWriter(Stdout, 2, 2, 1, ' ', 0)
 
-		fmt.Fprintf(tw, "Name\tSize\tMod time\tCandidate\tWould remove\n")
+		fmt.Fprintf(tw, "Name\tSize\tMod time\tCandidate\tWould remove\tBlob\n")
 		for _, entry := range stats.Entries {
+			blob := cacheEntryBlob(entry.Info, blobs)
+			switch {
+			case strings.HasSuffix(blob, ".snap"):
+				snapsSize += entry.Info.Size()
+			case strings.HasSuffix(blob, ".comp"):
+				componentsSize += entry.Info.Size()
+			}
 
 			if !entry.Candidate && !x.All {
 				continue
@@ -127,17 +184,23 @@ func (x *cmdSnapDownloadsCache) Execute(args []string) error {
 				}
 			}
 
-			fmt.Fprintf(tw, "%s\t%v\t%s\t%v\t%s\n",
+			if blob == "" {
+				blob = "-"
+			}
+			fmt.Fprintf(tw, "%s\t%v\t%s\t%v\t%s\t%s\n",
 				entry.Info.Name(),
 				quantity.FormatAmount(uint64(entry.Info.Size()), -1),
 				entry.Info.ModTime(),
 				boolYesNo(entry.Candidate),
 				boolYesNo(entry.Remove),
+				blob,
 			)
 		}
 		tw.Flush()
 	}
 
+	fmt.Fprintf(Stdout, "Total size used by snaps: %v\n", quantity.FormatAmount(uint64(snapsSize), -1))
+	fmt.Fprintf(Stdout, "Total size used by components: %v\n", quantity.FormatAmount(uint64(componentsSize), -1))
 	fmt.Fprintf(Stdout, "Total removed size: %v\n", quantity.FormatAmount(uint64(removedSize), -1))
 	fmt.Fprintf(Stdout, "Total candidates size: %v\n", quantity.FormatAmount(uint64(candidatesSize), -1))
 	fmt.Fprintf(Stdout, "Remaining size: %v\n", quantity.FormatAmount((uint64(candidatesSize)-uint64(removedSize)), -1))
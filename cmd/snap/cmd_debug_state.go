@@ -82,7 +82,8 @@ func loadState(path string) (*state.State, error) {
 	}
 	defer r.Close()
 
-	return state.ReadState(nil, r)
+	// also replay the journal of the journaling state backend, if any
+	return state.ReadStateWithJournal(r, path+".journal")
 }
 
 func init() {
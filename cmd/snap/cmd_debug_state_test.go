@@ -21,6 +21,7 @@ package main_test
 
 import (
 	"fmt"
+	"hash/crc32"
 	"os"
 	"path/filepath"
 	"time"
@@ -319,6 +320,24 @@ func (s *SnapSuite) TestDebugChanges(c *C) {
 	c.Check(s.Stderr(), Equals, "")
 }
 
+func (s *SnapSuite) TestDebugChangesReplaysJournal(c *C) {
+	dir := c.MkDir()
+	stateFile := filepath.Join(dir, "test-state.json")
+	c.Assert(os.WriteFile(stateFile, stateJSON, 0644), IsNil)
+	// change 9 was pruned after the state was last written out entirely
+	record := `{"generation":0,"changes":{"9":null},"tasks":{"11":null,"12":null},"last-change-id":10,"last-task-id":31}`
+	journal := fmt.Sprintf("%08x %s\n", crc32.ChecksumIEEE([]byte(record)), record)
+	c.Assert(os.WriteFile(stateFile+".journal", []byte(journal), 0644), IsNil)
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--abs-time", "--changes", stateFile})
+	c.Assert(err, IsNil)
+	c.Assert(rest, DeepEquals, []string{})
+	c.Check(s.Stdout(), Equals,
+		"ID   Status  Spawn                 Ready                 Label        Summary\n"+
+			"10   Done    2009-11-10T23:00:10Z  2009-11-10T23:00:30Z  revert-snap  revert c snap\n")
+	c.Check(s.Stderr(), Equals, "")
+}
+
 func (s *SnapSuite) TestDebugChangesMissingState(c *C) {
 	_, err := main.Parser(main.Client()).ParseArgs([]string{"debug", "state", "--changes", "/missing-state.json"})
 	c.Check(err, ErrorMatches, "cannot read the state file: open /missing-state.json: no such file or directory")
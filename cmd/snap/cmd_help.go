@@ -237,15 +237,16 @@ var helpCategories = []helpCategory{
 		Label:           i18n.G("Snapshots"),
 		Description:     i18n.G("archives of snap data"),
 		Commands:        []string{"saved", "save", "check-snapshot", "restore", "forget"},
-		AllOnlyCommands: []string{"export-snapshot", "import-snapshot"},
+		AllOnlyCommands: []string{"export-snapshot", "import-snapshot", "snapshot"},
 	}, {
 		Label:       i18n.G("Issue reporting"),
 		Description: i18n.G("report issues with a specific snap"),
 		Commands:    []string{"report-issue"},
 	}, {
-		Label:       i18n.G("Device"),
-		Description: i18n.G("manage device"),
-		Commands:    []string{"model", "remodel", "reboot", "recovery"},
+		Label:           i18n.G("Device"),
+		Description:     i18n.G("manage device"),
+		Commands:        []string{"model", "remodel", "reboot", "recovery"},
+		AllOnlyCommands: []string{"cluster"},
 	}, {
 		Label:       i18n.G("Warnings"),
 		Other:       true,
@@ -85,6 +85,13 @@ with the remove-quota command and recreated with a lower limit. Increasing the
 memory limit for a quota group does not restart any services associated with 
 snaps in the quota group.
 
+The memory high limit sets the memory usage above which the processes in a
+quota group are throttled instead of being killed, and the memory swap limit
+sets the swap they can use. Both require cgroup v2 and can be increased and
+decreased after being set on a quota group. The memory high limit cannot be
+larger than the memory limit of the group, and the limits of a sub-group
+cannot be larger than those of its parent group.
+
 The CPU limit for a quota group can be both increased and decreased after being
 set on a quota group. The CPU limit can be specified as a single percentage which
 means that the quota group is allowed an overall percentage of the CPU resources. Setting
@@ -99,10 +106,23 @@ The threads limit for a quota group can be increased but not decreased. To
 decrease the threads limit for a quota group, the entire group must be removed
 with the remove-quota command and recreated with a lower limit.
 
+The I/O limits restrict the bandwidth and the operations per second of reads
+from and writes to block devices, and are given per device as a comma separated
+list of <device>=<limit> pairs, e.g. --io-write-bps=/dev/mmcblk0=10MB. They
+require cgroup v2, and can be increased and decreased after being set on a
+group. Limits set for a device are kept when new limits are set for other
+devices.
+
 The journal limits can be increased and decreased after being set on a group.
 Setting a journal limit will cause the snaps in the group to be put into the same
 journal namespace. This will affect the behaviour of the log command.
 
+The memory event policy of a quota group with a memory limit sets what is done
+when processes in the group are killed for running out of memory. Such events
+are always recorded as notices and shown by the quota command. With "warn" a
+warning is added as well, and with "restart" the services in the group are
+restarted.
+
 New quotas can be set on existing quota groups, but existing quotas cannot be removed
 from a quota group, without removing and recreating the entire group.
 
@@ -116,15 +136,22 @@ func init() {
 	addCommand("set-quota", shortSetQuotaHelp, longSetQuotaHelp,
 		func() flags.Commander { return &cmdSetQuota{} },
 		waitDescs.also(map[string]string{
-			"memory":             i18n.G("Memory quota"),
-			"cpu":                i18n.G("CPU quota"),
-			"cpu-set":            i18n.G("CPU set quota"),
-			"threads":            i18n.G("Threads quota"),
-			"journal-size":       i18n.G("Journal size quota"),
-			"journal-rate-limit": i18n.G("Journal rate limit as <message count>/<message period>"),
-			"parent":             i18n.G("Parent quota group"),
+			"memory":              i18n.G("Memory quota"),
+			"memory-high":         i18n.G("Memory usage above which processes are throttled"),
+			"memory-swap":         i18n.G("Swap quota"),
+			"cpu":                 i18n.G("CPU quota"),
+			"cpu-set":             i18n.G("CPU set quota"),
+			"threads":             i18n.G("Threads quota"),
+			"journal-size":        i18n.G("Journal size quota"),
+			"journal-rate-limit":  i18n.G("Journal rate limit as <message count>/<message period>"),
+			"io-read-bps":         i18n.G("I/O read bandwidth quota as <device>=<bytes per second>"),
+			"io-write-bps":        i18n.G("I/O write bandwidth quota as <device>=<bytes per second>"),
+			"io-read-iops":        i18n.G("I/O read operations quota as <device>=<operations per second>"),
+			"io-write-iops":       i18n.G("I/O write operations quota as <device>=<operations per second>"),
+			"memory-event-policy": i18n.G("What to do when processes run out of memory: notice, warn or restart"),
+			"parent":              i18n.G("Parent quota group"),
 		}), nil)
-	addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} }, nil, nil)
+	addCommand("quota", shortQuotaHelp, longQuotaHelp, func() flags.Commander { return &cmdQuota{} }, timeDescs, nil)
 	addCommand("quotas", shortQuotasHelp, longQuotasHelp, func() flags.Commander { return &cmdQuotas{} }, nil, nil)
 	addCommand("remove-quota", shortRemoveQuotaHelp, longRemoveQuotaHelp, func() flags.Commander { return &cmdRemoveQuota{} }, nil, nil)
 }
@@ -132,14 +159,21 @@ func init() {
 type cmdSetQuota struct {
 	waitMixin
 
-	MemoryMax        string `long:"memory" optional:"true"`
-	CPUMax           string `long:"cpu" optional:"true"`
-	CPUSet           string `long:"cpu-set" optional:"true"`
-	ThreadsMax       string `long:"threads" optional:"true"`
-	JournalSizeMax   string `long:"journal-size" optional:"true"`
-	JournalRateLimit string `long:"journal-rate-limit" optional:"true"`
-	Parent           string `long:"parent" optional:"true"`
-	Positional       struct {
+	MemoryMax         string `long:"memory" optional:"true"`
+	MemoryHigh        string `long:"memory-high" optional:"true"`
+	MemorySwapMax     string `long:"memory-swap" optional:"true"`
+	CPUMax            string `long:"cpu" optional:"true"`
+	CPUSet            string `long:"cpu-set" optional:"true"`
+	ThreadsMax        string `long:"threads" optional:"true"`
+	JournalSizeMax    string `long:"journal-size" optional:"true"`
+	JournalRateLimit  string `long:"journal-rate-limit" optional:"true"`
+	IOReadBps         string `long:"io-read-bps" optional:"true"`
+	IOWriteBps        string `long:"io-write-bps" optional:"true"`
+	IOReadIOPS        string `long:"io-read-iops" optional:"true"`
+	IOWriteIOPS       string `long:"io-write-iops" optional:"true"`
+	MemoryEventPolicy string `long:"memory-event-policy" optional:"true" choice:"notice" choice:"warn" choice:"restart"`
+	Parent            string `long:"parent" optional:"true"`
+	Positional        struct {
 		GroupName string        `positional-arg-name:"<group-name>" required:"true"`
 		Snaps     []serviceName `positional-arg-name:"<snap-or-service>" optional:"true"`
 	} `positional-args:"yes"`
@@ -194,6 +228,79 @@ func parseJournalRateQuota(journalRateLimit string) (count int, period time.Dura
 	return count, period, nil
 }
 
+// parseIOQuota parses a comma separated list of <device>=<value> pairs,
+// calling setLimit for each of them.
+func parseIOQuota(ioLimits string, setLimit func(device, value string) error) error {
+	for _, pair := range strutil.CommaSeparatedList(ioLimits) {
+		device, value, ok := strings.Cut(pair, "=")
+		if !ok || device == "" || value == "" {
+			return fmt.Errorf("io limit %q must be of the form <device>=<limit>", pair)
+		}
+		if err := setLimit(device, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (x *cmdSetQuota) parseIOQuotas() (*client.QuotaIOValues, error) {
+	var devices []client.QuotaIODeviceValues
+	ioDevice := func(device string) *client.QuotaIODeviceValues {
+		for i := range devices {
+			if devices[i].Device == device {
+				return &devices[i]
+			}
+		}
+		devices = append(devices, client.QuotaIODeviceValues{Device: device})
+		return &devices[len(devices)-1]
+	}
+	parseBandwidth := func(device, value string) (quantity.Size, error) {
+		bps, err := strutil.ParseByteSize(value)
+		if err != nil || bps <= 0 {
+			return 0, fmt.Errorf("cannot use bandwidth value %q for device %q", value, device)
+		}
+		return quantity.Size(bps), nil
+	}
+	parseIOPS := func(device, value string) (int, error) {
+		iops, err := strconv.ParseUint(value, 10, 32)
+		if err != nil || iops == 0 {
+			return 0, fmt.Errorf("cannot use iops value %q for device %q", value, device)
+		}
+		return int(iops), nil
+	}
+
+	for _, ioQuota := range []struct {
+		flag, value string
+		set         func(device, value string) error
+	}{
+		{"io-read-bps", x.IOReadBps, func(device, value string) (err error) {
+			ioDevice(device).ReadBps, err = parseBandwidth(device, value)
+			return err
+		}},
+		{"io-write-bps", x.IOWriteBps, func(device, value string) (err error) {
+			ioDevice(device).WriteBps, err = parseBandwidth(device, value)
+			return err
+		}},
+		{"io-read-iops", x.IOReadIOPS, func(device, value string) (err error) {
+			ioDevice(device).ReadIOPS, err = parseIOPS(device, value)
+			return err
+		}},
+		{"io-write-iops", x.IOWriteIOPS, func(device, value string) (err error) {
+			ioDevice(device).WriteIOPS, err = parseIOPS(device, value)
+			return err
+		}},
+	} {
+		if err := parseIOQuota(ioQuota.value, ioQuota.set); err != nil {
+			return nil, fmt.Errorf("cannot parse %s quota %q: %v", ioQuota.flag, ioQuota.value, err)
+		}
+	}
+
+	if len(devices) == 0 {
+		return nil, nil
+	}
+	return &client.QuotaIOValues{Devices: devices}, nil
+}
+
 func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 	var quotaValues client.QuotaValues
 
@@ -205,6 +312,22 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		quotaValues.Memory = quantity.Size(value)
 	}
 
+	if x.MemoryHigh != "" {
+		value, err := strutil.ParseByteSize(x.MemoryHigh)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse memory high limit %q: %v", x.MemoryHigh, err)
+		}
+		quotaValues.MemoryHigh = quantity.Size(value)
+	}
+
+	if x.MemorySwapMax != "" {
+		value, err := strutil.ParseByteSize(x.MemorySwapMax)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse memory swap limit %q: %v", x.MemorySwapMax, err)
+		}
+		quotaValues.MemorySwap = quantity.Size(value)
+	}
+
 	if x.CPUMax != "" {
 		countValue, percentageValue, err := parseCpuQuota(x.CPUMax)
 		if err != nil {
@@ -266,12 +389,19 @@ func (x *cmdSetQuota) parseQuotas() (*client.QuotaValues, error) {
 		}
 	}
 
+	ioValues, err := x.parseIOQuotas()
+	if err != nil {
+		return nil, err
+	}
+	quotaValues.IO = ioValues
+
 	return &quotaValues, nil
 }
 
 func (x *cmdSetQuota) hasQuotaSet() bool {
-	return x.MemoryMax != "" || x.CPUMax != "" || x.CPUSet != "" ||
-		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != ""
+	return x.MemoryMax != "" || x.MemoryHigh != "" || x.MemorySwapMax != "" || x.CPUMax != "" || x.CPUSet != "" ||
+		x.ThreadsMax != "" || x.JournalSizeMax != "" || x.JournalRateLimit != "" ||
+		x.IOReadBps != "" || x.IOWriteBps != "" || x.IOReadIOPS != "" || x.IOWriteIOPS != ""
 }
 
 func (x *cmdSetQuota) splitSnapsAndServices() (snaps []string, services []string) {
@@ -299,7 +429,7 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 	var chgID string
 
 	switch {
-	case !quotaProvided && x.Parent == "" && len(x.Positional.Snaps) == 0:
+	case !quotaProvided && x.MemoryEventPolicy == "" && x.Parent == "" && len(x.Positional.Snaps) == 0:
 		// no snaps or services were specified, no memory limit was specified, and no parent
 		// was specified, so just the group name was provided - this is not
 		// supported since there is nothing to change/create
@@ -338,27 +468,32 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 		// means leave the group with whatever parent it has, or if it doesn't
 		// currently exist, create the group without a parent group
 		chgID, err = x.client.EnsureQuota(x.Positional.GroupName, &client.EnsureQuotaOptions{
-			Parent:      x.Parent,
-			Snaps:       snaps,
-			Services:    services,
-			Constraints: quotaValues,
+			Parent:            x.Parent,
+			Snaps:             snaps,
+			Services:          services,
+			Constraints:       quotaValues,
+			MemoryEventPolicy: x.MemoryEventPolicy,
 		})
 		if err != nil {
 			return err
 		}
-	case len(x.Positional.Snaps) != 0:
-		// there are snaps or services specified for this group but no limits, so the
-		// group must already exist and we must be adding the specified snaps or services to
-		// the group
+	case len(x.Positional.Snaps) != 0 || x.MemoryEventPolicy != "":
+		// there are snaps or services specified for this group, or its memory
+		// event policy, but no limits, so the group must already exist and we
+		// must be adding the specified snaps or services to the group
 
 		// TODO: this case may someday also imply overwriting the current set of
 		// snaps or services with whatever was specified with some option, but we don't
 		// currently support that, so currently all snaps or services specified here are
 		// just added to the group
+		if !groupExists && len(x.Positional.Snaps) == 0 {
+			return fmt.Errorf("cannot create quota group without any limit")
+		}
 		chgID, err = x.client.EnsureQuota(x.Positional.GroupName, &client.EnsureQuotaOptions{
-			Parent:   x.Parent,
-			Snaps:    snaps,
-			Services: services,
+			Parent:            x.Parent,
+			Snaps:             snaps,
+			Services:          services,
+			MemoryEventPolicy: x.MemoryEventPolicy,
 		})
 		if err != nil {
 			return err
@@ -380,6 +515,7 @@ func (x *cmdSetQuota) Execute(args []string) (err error) {
 
 type cmdQuota struct {
 	clientMixin
+	timeMixin
 
 	Positional struct {
 		GroupName string `positional-arg-name:"<group-name>" required:"true"`
@@ -416,6 +552,14 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 		val := strings.TrimSpace(fmtSize(int64(group.Constraints.Memory)))
 		fmt.Fprintf(w, "  memory:\t%s\n", val)
 	}
+	if group.Constraints.MemoryHigh != 0 {
+		val := strings.TrimSpace(fmtSize(int64(group.Constraints.MemoryHigh)))
+		fmt.Fprintf(w, "  memory-high:\t%s\n", val)
+	}
+	if group.Constraints.MemorySwap != 0 {
+		val := strings.TrimSpace(fmtSize(int64(group.Constraints.MemorySwap)))
+		fmt.Fprintf(w, "  memory-swap:\t%s\n", val)
+	}
 	if group.Constraints.CPU != nil {
 		fmt.Fprintf(w, "  cpu-count:\t%d\n", group.Constraints.CPU.Count)
 		fmt.Fprintf(w, "  cpu-percentage:\t%d\n", group.Constraints.CPU.Percentage)
@@ -438,12 +582,22 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 				group.Constraints.Journal.RatePeriod)
 		}
 	}
+	if group.Constraints.IO != nil {
+		for _, ioLimit := range formatIOLimits(group.Constraints.IO.Devices) {
+			fmt.Fprintf(w, "  %s:\t%s\n", ioLimit.name, ioLimit.value)
+		}
+	}
 
 	memoryUsage := "0B"
 	currentThreads := 0
+	ioReadUsage, ioWriteUsage := "0B", "0B"
 	if group.Current != nil {
 		memoryUsage = strings.TrimSpace(fmtSize(int64(group.Current.Memory)))
 		currentThreads = group.Current.Threads
+		if group.Current.IO != nil {
+			ioReadUsage = fmtSize(int64(group.Current.IO.ReadBytes))
+			ioWriteUsage = fmtSize(int64(group.Current.IO.WriteBytes))
+		}
 	}
 
 	fmt.Fprintf(w, "current:\n")
@@ -453,6 +607,31 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 	if group.Constraints.Threads != 0 {
 		fmt.Fprintf(w, "  threads:\t%d\n", currentThreads)
 	}
+	if group.Constraints.IO != nil && len(group.Constraints.IO.Devices) > 0 {
+		fmt.Fprintf(w, "  io-read:\t%s\n", ioReadUsage)
+		fmt.Fprintf(w, "  io-write:\t%s\n", ioWriteUsage)
+	}
+
+	if group.MemoryEventPolicy != "" {
+		fmt.Fprintf(w, "memory-event-policy:\t%s\n", group.MemoryEventPolicy)
+	}
+	if events := group.MemoryEvents; events != nil {
+		fmt.Fprintf(w, "memory-events:\n")
+		for _, event := range []struct {
+			name  string
+			count uint64
+		}{
+			{"high", events.High},
+			{"max", events.Max},
+			{"oom", events.OOM},
+			{"oom-kill", events.OOMKill},
+		} {
+			if event.count != 0 {
+				fmt.Fprintf(w, "  %s:\t%d\n", event.name, event.count)
+			}
+		}
+		fmt.Fprintf(w, "  last:\t%s\n", x.fmtTime(events.Last))
+	}
 
 	if len(group.Subgroups) > 0 {
 		fmt.Fprint(w, "subgroups:\n")
@@ -476,6 +655,47 @@ func (x *cmdQuota) Execute(args []string) (err error) {
 	return nil
 }
 
+type ioLimit struct {
+	name  string
+	value string
+}
+
+// formatIOLimits returns the io limits of the devices, one per kind of
+// limit, in the same <device>=<limit> form used to set them.
+func formatIOLimits(devices []client.QuotaIODeviceValues) []ioLimit {
+	var readBps, writeBps, readIOPS, writeIOPS []string
+	for _, dev := range devices {
+		if dev.ReadBps != 0 {
+			readBps = append(readBps, dev.Device+"="+fmtSize(int64(dev.ReadBps)))
+		}
+		if dev.WriteBps != 0 {
+			writeBps = append(writeBps, dev.Device+"="+fmtSize(int64(dev.WriteBps)))
+		}
+		if dev.ReadIOPS != 0 {
+			readIOPS = append(readIOPS, dev.Device+"="+strconv.Itoa(dev.ReadIOPS))
+		}
+		if dev.WriteIOPS != 0 {
+			writeIOPS = append(writeIOPS, dev.Device+"="+strconv.Itoa(dev.WriteIOPS))
+		}
+	}
+
+	var limits []ioLimit
+	for _, l := range []struct {
+		name   string
+		values []string
+	}{
+		{"io-read-bps", readBps},
+		{"io-write-bps", writeBps},
+		{"io-read-iops", readIOPS},
+		{"io-write-iops", writeIOPS},
+	} {
+		if len(l.values) > 0 {
+			limits = append(limits, ioLimit{name: l.name, value: strings.Join(l.values, ",")})
+		}
+	}
+	return limits
+}
+
 type cmdRemoveQuota struct {
 	waitMixin
 
@@ -527,6 +747,12 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 		if q.Constraints.Memory != 0 {
 			grpConstraints = append(grpConstraints, "memory="+strings.TrimSpace(fmtSize(int64(q.Constraints.Memory))))
 		}
+		if q.Constraints.MemoryHigh != 0 {
+			grpConstraints = append(grpConstraints, "memory-high="+strings.TrimSpace(fmtSize(int64(q.Constraints.MemoryHigh))))
+		}
+		if q.Constraints.MemorySwap != 0 {
+			grpConstraints = append(grpConstraints, "memory-swap="+strings.TrimSpace(fmtSize(int64(q.Constraints.MemorySwap))))
+		}
 
 		// format cpu constraint as cpu=NxM%,cpu-set=x,y,z
 		if q.Constraints.CPU != nil {
@@ -560,6 +786,13 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			}
 		}
 
+		// format io constraints as io-read-bps=<device>=N
+		if q.Constraints.IO != nil {
+			for _, ioLimit := range formatIOLimits(q.Constraints.IO.Devices) {
+				grpConstraints = append(grpConstraints, ioLimit.name+"="+ioLimit.value)
+			}
+		}
+
 		// format current resource values as memory=N,threads=N
 		var grpCurrent []string
 		if q.Current != nil {
@@ -569,6 +802,14 @@ func (x *cmdQuotas) Execute(args []string) (err error) {
 			if q.Constraints.Threads != 0 && q.Current.Threads != 0 {
 				grpCurrent = append(grpCurrent, "threads="+fmt.Sprintf("%d", q.Current.Threads))
 			}
+			if q.Constraints.IO != nil && q.Current.IO != nil {
+				if q.Current.IO.ReadBytes != 0 {
+					grpCurrent = append(grpCurrent, "io-read="+fmtSize(int64(q.Current.IO.ReadBytes)))
+				}
+				if q.Current.IO.WriteBytes != 0 {
+					grpCurrent = append(grpCurrent, "io-write="+fmtSize(int64(q.Current.IO.WriteBytes)))
+				}
+			}
 		}
 
 		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", q.GroupName, q.Parent, strings.Join(grpConstraints, ","), strings.Join(grpCurrent, ","))
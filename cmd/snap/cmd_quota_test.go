@@ -109,6 +109,8 @@ type fakeQuotaGroupPostHandlerOpts struct {
 	cpuCount      int
 	cpuPercentage int
 	cpuSet        []int
+
+	memoryEventPolicy string
 }
 
 type quotasEnsureBodyConstraintsCPU struct {
@@ -134,6 +136,8 @@ type quotasEnsureBody struct {
 	Snaps       []string                    `json:"snaps,omitempty"`
 	Services    []string                    `json:"services,omitempty"`
 	Constraints quotasEnsureBodyConstraints `json:"constraints,omitempty"`
+
+	MemoryEventPolicy string `json:"memory-event-policy,omitempty"`
 }
 
 func (s *quotaSuite) makeFakeQuotaPostHandler(c *check.C, opts fakeQuotaGroupPostHandlerOpts) func(w http.ResponseWriter, r *http.Request) {
@@ -156,6 +160,8 @@ func (s *quotaSuite) makeFakeQuotaPostHandler(c *check.C, opts fakeQuotaGroupPos
 				Snaps:       opts.snaps,
 				Services:    opts.services,
 				Constraints: quotasEnsureBodyConstraints{},
+
+				MemoryEventPolicy: opts.memoryEventPolicy,
 			}
 			if opts.maxMemory != 0 {
 				exp.Constraints.Memory = opts.maxMemory
@@ -259,6 +265,69 @@ func (s *quotaSuite) TestParseQuotas(c *check.C) {
 	}
 }
 
+func (s *quotaSuite) TestParseIOQuotas(c *check.C) {
+	for _, testData := range []struct {
+		readBps, writeBps, readIOPS, writeIOPS string
+
+		quotas string
+		err    string
+	}{
+		{readBps: "/dev/sda=10MB", quotas: `{"io":{"devices":[{"device":"/dev/sda","read-bps":10000000}]}}`},
+		{writeIOPS: "/dev/sda=100, /dev/sdb=50", quotas: `{"io":{"devices":[{"device":"/dev/sda","write-iops":100},{"device":"/dev/sdb","write-iops":50}]}}`},
+		{readBps: "/dev/sda=1kB", writeBps: "/dev/sdb=2kB", readIOPS: "/dev/sda=10", writeIOPS: "/dev/sda=20",
+			quotas: `{"io":{"devices":[{"device":"/dev/sda","read-bps":1000,"read-iops":10,"write-iops":20},{"device":"/dev/sdb","write-bps":2000}]}}`},
+
+		// Error cases
+		{readBps: "/dev/sda", err: `cannot parse io-read-bps quota "/dev/sda": io limit "/dev/sda" must be of the form <device>=<limit>`},
+		{readBps: "=10MB", err: `cannot parse io-read-bps quota "=10MB": io limit "=10MB" must be of the form <device>=<limit>`},
+		{writeBps: "/dev/sda=10", err: `cannot parse io-write-bps quota "/dev/sda=10": cannot use bandwidth value "10" for device "/dev/sda"`},
+		{readIOPS: "/dev/sda=x", err: `cannot parse io-read-iops quota "/dev/sda=x": cannot use iops value "x" for device "/dev/sda"`},
+		{writeIOPS: "/dev/sda=0", err: `cannot parse io-write-iops quota "/dev/sda=0": cannot use iops value "0" for device "/dev/sda"`},
+	} {
+		quotas, err := main.ParseIOQuotaValues(testData.readBps, testData.writeBps, testData.readIOPS, testData.writeIOPS)
+		testLabel := check.Commentf("%v", testData)
+		if testData.err == "" {
+			c.Check(err, check.IsNil, testLabel)
+			var jsonQuota bytes.Buffer
+			err := json.NewEncoder(&jsonQuota).Encode(quotas)
+			c.Assert(err, check.IsNil, testLabel)
+			c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, testData.quotas, testLabel)
+		} else {
+			c.Check(err, check.ErrorMatches, testData.err, testLabel)
+		}
+	}
+}
+
+func (s *quotaSuite) TestParseMemoryQuotas(c *check.C) {
+	for _, testData := range []struct {
+		memoryHigh    string
+		memorySwapMax string
+
+		quotas string
+		err    string
+	}{
+		{memoryHigh: "12MB", quotas: `{"memory-high":12000000}`},
+		{memorySwapMax: "1GB", quotas: `{"memory-swap":1000000000}`},
+		{memoryHigh: "12MB", memorySwapMax: "1GB", quotas: `{"memory-high":12000000,"memory-swap":1000000000}`},
+
+		// Error cases
+		{memoryHigh: "12", err: `cannot parse memory high limit "12": cannot parse "12": need a number with a unit as input`},
+		{memorySwapMax: "1XB", err: `cannot parse memory swap limit "1XB": cannot parse "1XB": try 'kB' or 'MB'`},
+	} {
+		quotas, err := main.ParseMemoryQuotaValues(testData.memoryHigh, testData.memorySwapMax)
+		testLabel := check.Commentf("%v", testData)
+		if testData.err == "" {
+			c.Check(err, check.IsNil, testLabel)
+			var jsonQuota bytes.Buffer
+			err := json.NewEncoder(&jsonQuota).Encode(quotas)
+			c.Assert(err, check.IsNil, testLabel)
+			c.Check(strings.TrimSpace(jsonQuota.String()), check.Equals, testData.quotas, testLabel)
+		} else {
+			c.Check(err, check.ErrorMatches, testData.err, testLabel)
+		}
+	}
+}
+
 func (s *quotaSuite) TestSetQuotaInvalidArgs(c *check.C) {
 	const json = `{
 		"type": "sync",
@@ -445,6 +514,67 @@ current:
 	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 2)
 }
 
+func (s *quotaSuite) TestGetMemoryHighAndSwapQuotaGroup(c *check.C) {
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"memory": 1000000, "memory-high": 800000, "memory-swap": 2000000},
+			"current": {"memory": 900}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, json))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  memory:       1.00MB
+  memory-high:  800kB
+  memory-swap:  2.00MB
+current:
+  memory:  900B
+`[1:])
+}
+
+func (s *quotaSuite) TestMemoryEventsQuotaGroup(c *check.C) {
+	const json = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"memory": 1000},
+			"current": {"memory": 900},
+			"memory-event-policy": "warn",
+			"memory-events": {"high": 12, "oom-kill": 2, "last": "2026-10-17T10:00:00Z"}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, json))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "--abs-time", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+name:  foo
+constraints:
+  memory:  1000B
+current:
+  memory:             900B
+memory-event-policy:  warn
+memory-events:
+  high:      12
+  oom-kill:  2
+  last:      2026-10-17T10:00:00Z
+`[1:])
+}
+
 func (s *quotaSuite) TestGetCpuQuotaGroupSimple(c *check.C) {
 	const jsonTemplate = `{
 		"type": "sync",
@@ -517,6 +647,40 @@ current:
 	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
 }
 
+func (s *quotaSuite) TestIOQuotaGroupSimple(c *check.C) {
+	const jsonTemplate = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name": "foo",
+			"constraints": {"io":{"devices":[
+				{"device":"/dev/mmcblk0","read-bps":10000000,"write-iops":100},
+				{"device":"/dev/sda","read-bps":20000000}
+			]}},
+			"current": {"io":{"read-bytes":1500000,"write-bytes":3000}}
+		}
+	}`
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupHandler(c, jsonTemplate))
+
+	outputTemplate := `
+name:  foo
+constraints:
+  io-read-bps:    /dev/mmcblk0=10.0MB,/dev/sda=20.0MB
+  io-write-iops:  /dev/mmcblk0=100
+current:
+  io-read:   1.50MB
+  io-write:  3000B
+`[1:]
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quota", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, outputTemplate)
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestSetQuotaGroupCreateNew(c *check.C) {
 	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
 	fakeHandlerOpts := fakeQuotaGroupPostHandlerOpts{
@@ -570,6 +734,50 @@ func (s *quotaSuite) TestSetQuotaGroupUpdateExistingUnhappyWithParent(c *check.C
 	s.testSetQuotaGroupUpdateExistingUnhappy(c, "cannot move a quota group to a new parent", exists, "--parent=bar")
 }
 
+func (s *quotaSuite) TestSetQuotaGroupCreateNewMemoryEventPolicyOnlyUnhappy(c *check.C) {
+	const exists = false
+	s.testSetQuotaGroupUpdateExistingUnhappy(c, "cannot create quota group without any limit", exists, "--memory-event-policy=warn")
+}
+
+func (s *quotaSuite) TestSetQuotaGroupMemoryEventPolicyInvalid(c *check.C) {
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"set-quota", "foo", "--memory-event-policy=reboot"})
+	c.Assert(err, check.ErrorMatches, "Invalid value `reboot' for option `--memory-event-policy'.*")
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 0)
+}
+
+func (s *quotaSuite) TestSetQuotaGroupMemoryEventPolicy(c *check.C) {
+	const postJSON = `{"type": "async", "status-code": 202,"change":"42", "result": []}`
+	const getJson = `{
+		"type": "sync",
+		"status-code": 200,
+		"result": {
+			"group-name":"foo",
+			"constraints": { "memory": 1000 },
+			"current": { "memory": 500 }
+		}
+	}`
+
+	routes := map[string]http.HandlerFunc{
+		"/v2/quotas": s.makeFakeQuotaPostHandler(c, fakeQuotaGroupPostHandlerOpts{
+			action:            "ensure",
+			body:              postJSON,
+			groupName:         "foo",
+			memoryEventPolicy: "restart",
+		}),
+		"/v2/quotas/foo": s.makeFakeGetQuotaGroupHandler(c, getJson),
+		"/v2/changes/42": makeChangesHandler(c),
+	}
+	s.RedirectClientToTestServer(dispatchFakeHandlers(c, routes))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"set-quota", "foo", "--memory-event-policy=restart"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.quotaGetGroupHandlerCalls, check.Equals, 1)
+	c.Check(s.quotaPostHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) testSetQuotaGroupUpdateExistingUnhappy(c *check.C, errPattern string, exists bool, args ...string) {
 	if exists {
 		// existing group has 1000 memory limit
@@ -747,6 +955,28 @@ bbb      zzz     memory=1000B                              memory=400B
 	c.Check(s.quotaGetGroupsHandlerCalls, check.Equals, 1)
 }
 
+func (s *quotaSuite) TestGetAllIOQuotaGroups(c *check.C) {
+	restore := main.MockIsStdinTTY(true)
+	defer restore()
+
+	s.RedirectClientToTestServer(s.makeFakeGetQuotaGroupsHandler(c,
+		`{"type": "sync", "status-code": 200, "result": [
+			{"group-name":"io0","constraints":{"io":{"devices":[{"device":"/dev/sda","write-bps":1000000}]}},"current":{"io":{"write-bytes":5000}}},
+			{"group-name":"io1","constraints":{"memory":1000,"io":{"devices":[{"device":"/dev/sda","read-iops":10}]}}}
+			]}`))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"quotas"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `
+Quota  Parent  Constraints                            Current
+io0            io-write-bps=/dev/sda=1.00MB           io-write=5000B
+io1            memory=1000B,io-read-iops=/dev/sda=10  
+`[1:])
+	c.Check(s.quotaGetGroupsHandlerCalls, check.Equals, 1)
+}
+
 func (s *quotaSuite) TestGetAllQuotaGroupsInconsistencyError(c *check.C) {
 	restore := main.MockIsStdinTTY(true)
 	defer restore()
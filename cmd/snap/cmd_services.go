@@ -64,6 +64,9 @@ status of the user services for the root user itself, --user can be provided.
 If executed as a non-root user, the 'Startup'|'Current' status of user services 
 will be the current status for the invoking user. To view the global enablement
 status of user services, --global can be provided.
+
+For services with a liveness probe, the 'Notes' column shows whether the service
+passed its last probe, as probe-passing or probe-failing.
 `)
 	shortLogsHelp = i18n.G("Retrieve logs for services")
 	longLogsHelp  = i18n.G(`
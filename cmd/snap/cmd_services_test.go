@@ -439,6 +439,73 @@ foo.zed  enabled  active    -
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *appOpSuite) TestAppStatusProbes(c *check.C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.URL.Path, check.Equals, "/v2/apps")
+			c.Check(r.URL.Query().Get("select"), check.Equals, "service")
+			c.Check(r.Method, check.Equals, "GET")
+			w.WriteHeader(200)
+			enc := json.NewEncoder(w)
+			enc.Encode(map[string]any{
+				"type": "sync",
+				"result": []map[string]any{
+					{
+						"snap":    "foo",
+						"name":    "bar",
+						"daemon":  "simple",
+						"active":  true,
+						"enabled": true,
+						"liveness-probe": map[string]any{
+							"status":     "passing",
+							"last-check": "2026-10-01T12:00:00Z",
+						},
+					}, {
+						"snap":    "foo",
+						"name":    "baz",
+						"daemon":  "simple",
+						"active":  true,
+						"enabled": true,
+						"activators": []map[string]any{
+							{"name": "baz-sock", "type": "socket", "active": true, "enabled": true},
+						},
+						"liveness-probe": map[string]any{
+							"status":     "failing",
+							"failures":   2,
+							"last-check": "2026-10-01T12:00:00Z",
+							"message":    "connection refused",
+						},
+					}, {
+						"snap":    "foo",
+						"name":    "zed",
+						"daemon":  "simple",
+						"active":  true,
+						"enabled": true,
+					},
+				},
+				"status":      "OK",
+				"status-code": 200,
+			})
+		default:
+			c.Fatalf("expected to get 1 requests, now on %d", n+1)
+		}
+
+		n++
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"services", "--global"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `Service  Startup  Current  Notes
+foo.bar  enabled  active   probe-passing
+foo.baz  enabled  active   socket-activated,probe-failing
+foo.zed  enabled  active   -
+`)
+	c.Check(n, check.Equals, 1)
+}
+
 func (s *appOpSuite) TestServiceCompletion(c *check.C) {
 	n := 0
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
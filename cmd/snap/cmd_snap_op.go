@@ -976,6 +976,7 @@ type cmdRefresh struct {
 	Transaction      client.TransactionType `long:"transaction" default:"per-snap" choice:"all-snaps" choice:"per-snap"`
 	Hold             string                 `long:"hold" optional:"yes" optional-value:"forever"`
 	Unhold           bool                   `long:"unhold"`
+	DryRun           bool                   `long:"dry-run"`
 	Positional       struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -988,6 +989,18 @@ func (x *cmdRefresh) refreshMany(snaps []string, opts *client.SnapOptions) error
 		return err
 	}
 
+	if x.DryRun {
+		plan, err := x.client.RefreshManyDryRun(names, compsBySnap, opts)
+		if err != nil {
+			return err
+		}
+		if len(plan.Tasks) == 0 {
+			fmt.Fprintln(Stderr, i18n.G("All snaps up to date."))
+			return nil
+		}
+		return x.showRefreshPlan(plan)
+	}
+
 	changeID, err := x.client.RefreshMany(names, compsBySnap, opts)
 	if err != nil {
 		return err
@@ -1022,6 +1035,19 @@ func (x *cmdRefresh) refreshOne(name string, opts *client.SnapOptions) error {
 		return errors.New(i18n.G("no snap for the component(s) was specified"))
 	}
 
+	if x.DryRun {
+		plan, err := x.client.RefreshDryRun(snapName, comps, opts)
+		if err != nil {
+			msg, err := errorToCmdMessage(snapName, "refresh", err, opts)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintln(Stderr, msg)
+			return nil
+		}
+		return x.showRefreshPlan(plan)
+	}
+
 	changeID, err := x.client.Refresh(snapName, comps, opts)
 	if err != nil {
 		msg, err := errorToCmdMessage(snapName, "refresh", err, opts)
@@ -1045,6 +1071,42 @@ func (x *cmdRefresh) refreshOne(name string, opts *client.SnapOptions) error {
 	return showDone(x.client, chg, &changedSnapsData{names: []string{snapName}, comps: nil}, "refresh", opts, x.getEscapes())
 }
 
+// showRefreshPlan shows the tasks a refresh would run, like 'snap tasks'
+// does for a change, followed by what they entail.
+func (x *cmdRefresh) showRefreshPlan(plan *client.RefreshPlan) error {
+	fmt.Fprintln(Stdout, plan.Summary)
+	fmt.Fprintln(Stdout)
+
+	w := tabWriter()
+	fmt.Fprint(w, i18n.G("Status\tSpawn\tReady\tSummary\n"))
+	for _, t := range plan.Tasks {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", t.Status, x.fmtTime(t.SpawnTime), "-", t.Summary)
+	}
+	w.Flush()
+	fmt.Fprintln(Stdout)
+
+	w = tabWriter()
+	fmt.Fprintf(w, i18n.G("download-size:\t%s\n"), fmtSize(plan.DownloadSize))
+	if len(plan.RebootRequired) > 0 {
+		fmt.Fprintf(w, i18n.G("reboot-required:\t%s\n"), strings.Join(plan.RebootRequired, ", "))
+	} else {
+		fmt.Fprint(w, i18n.G("reboot-required:\t-\n"))
+	}
+	if len(plan.Reconnections) > 0 {
+		fmt.Fprint(w, i18n.G("reconnections:\n"))
+		for _, conn := range plan.Reconnections {
+			fmt.Fprintf(w, "  %s\n", conn)
+		}
+	}
+	if len(plan.Hooks) > 0 {
+		fmt.Fprint(w, i18n.G("hooks:\n"))
+		for _, hook := range plan.Hooks {
+			fmt.Fprintf(w, "  %s\n", hook)
+		}
+	}
+	return w.Flush()
+}
+
 func parseSysinfoTime(s string) time.Time {
 	t, err := time.Parse(time.RFC3339, s)
 	if err != nil {
@@ -1066,6 +1128,12 @@ func (x *cmdRefresh) showRefreshTimes() error {
 	} else {
 		return errors.New("internal error: both refresh.timer and refresh.schedule are empty")
 	}
+	if sysinfo.Refresh.WindowEndBehavior != "" {
+		fmt.Fprintf(Stdout, "window-end: %s\n", sysinfo.Refresh.WindowEndBehavior)
+	}
+	if sysinfo.Refresh.Blackout != "" {
+		fmt.Fprintf(Stdout, "blackout: %s\n", sysinfo.Refresh.Blackout)
+	}
 	last := parseSysinfoTime(sysinfo.Refresh.Last)
 	hold := parseSysinfoTime(sysinfo.Refresh.Hold)
 	next := parseSysinfoTime(sysinfo.Refresh.Next)
@@ -1156,7 +1224,7 @@ func (x *cmdRefresh) Execute([]string) error {
 
 	otherFlags := x.Amend || x.Revision != "" || x.Cohort != "" ||
 		x.LeaveCohort || x.List || x.Time || x.IgnoreValidation || x.IgnoreRunning ||
-		x.Transaction != client.TransactionPerSnap
+		x.Transaction != client.TransactionPerSnap || x.DryRun
 
 	switch {
 	case x.Tracking:
@@ -1664,6 +1732,8 @@ func init() {
 			"hold": i18n.G("Hold refreshes for a specified duration (or forever, if no value is specified)"),
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"unhold": i18n.G("Remove refresh hold"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"dry-run": i18n.G("Show what the refresh would do, without doing it"),
 		}), nil)
 	addCommand("try", shortTryHelp, longTryHelp, func() flags.Commander { return &cmdTry{} }, waitDescs.also(modeDescs), nil)
 	addCommand("enable", shortEnableHelp, longEnableHelp, func() flags.Commander { return &cmdEnable{} }, waitDescs, nil)
@@ -2081,6 +2081,23 @@ next: 2017-04-26T00:58:00+02:00
 	c.Check(n, check.Equals, 1)
 }
 
+func (s *SnapSuite) TestRefreshTimeBlackout(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, check.Equals, "/v2/system-info")
+		fmt.Fprintln(w, `{"type": "sync", "status-code": 200, "result": {"refresh": {"timer": "mon,10:00-12:00", "window-end-behavior": "defer", "blackout": "11-20..01-05", "last": "2017-04-25T17:35:00+02:00", "next": "2017-04-26T00:58:00+02:00"}}}`)
+	})
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--time", "--abs-time"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.DeepEquals, []string{})
+	c.Check(s.Stdout(), check.Equals, `timer: mon,10:00-12:00
+window-end: defer
+blackout: 11-20..01-05
+last: 2017-04-25T17:35:00+02:00
+next: 2017-04-26T00:58:00+02:00
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
 func (s *SnapSuite) TestRefreshTimeShowsHolds(c *check.C) {
 	type testcase struct {
 		in  string
@@ -2408,6 +2425,85 @@ func (s *SnapSuite) TestRefreshHoldAndUnholdFailWithOtherFlags(c *check.C) {
 	}
 }
 
+func (s *SnapSuite) TestRefreshDryRun(c *check.C) {
+	var n int
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch n {
+		case 0:
+			c.Check(r.Method, check.Equals, "POST")
+			c.Check(r.URL.Path, check.Equals, "/v2/snaps/foo")
+			c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]any{
+				"action":      "refresh",
+				"transaction": string(client.TransactionPerSnap),
+				"dry-run":     true,
+			})
+			fmt.Fprintln(w, `{"type": "sync", "result": {
+  "summary": "Refresh \"foo\" snap",
+  "snap-names": ["foo"],
+  "tasks": [
+    {"id": "1", "kind": "download-snap", "summary": "Download snap \"foo\" (2) from channel \"stable\"", "status": "Do", "spawn-time": "2026-10-17T10:00:00Z"},
+    {"id": "2", "kind": "link-snap", "summary": "Make snap \"foo\" (2) available to the system", "status": "Do", "spawn-time": "2026-10-17T10:00:00Z"}
+  ],
+  "download-size": 1000000,
+  "reboot-required": ["foo"],
+  "reconnections": ["foo:network core:network"],
+  "hooks": ["foo:post-refresh"]
+}}`)
+		default:
+			c.Errorf("expected to get 1 request, now on %d", n+1)
+			fmt.Fprintln(w, `{"type": "error", "result": {"message": "received too many requests"}, "status-code": 500}`)
+		}
+
+		n++
+	})
+
+	rest, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run", "--abs-time", "foo"})
+	c.Assert(err, check.IsNil)
+	c.Assert(rest, check.HasLen, 0)
+	c.Check(n, check.Equals, 1)
+	c.Check(s.Stdout(), check.Equals, `Refresh "foo" snap
+
+Status  Spawn                 Ready  Summary
+Do      2026-10-17T10:00:00Z  -      Download snap "foo" (2) from channel "stable"
+Do      2026-10-17T10:00:00Z  -      Make snap "foo" (2) available to the system
+
+download-size:    1.00MB
+reboot-required:  foo
+reconnections:
+  foo:network core:network
+hooks:
+  foo:post-refresh
+`)
+	c.Check(s.Stderr(), check.Equals, "")
+}
+
+func (s *SnapSuite) TestRefreshManyDryRunNoUpdates(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, check.Equals, "POST")
+		c.Check(r.URL.Path, check.Equals, "/v2/snaps")
+		c.Check(DecodedRequestBody(c, r), check.DeepEquals, map[string]any{
+			"action":      "refresh",
+			"transaction": string(client.TransactionPerSnap),
+			"dry-run":     true,
+		})
+		fmt.Fprintln(w, `{"type": "sync", "result": {"summary": "Refresh all snaps: no updates", "tasks": [], "download-size": 0}}`)
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--dry-run"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stdout(), check.Equals, "")
+	c.Check(s.Stderr(), check.Equals, "All snaps up to date.\n")
+}
+
+func (s *SnapSuite) TestRefreshDryRunFailsWithHold(c *check.C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request")
+	})
+
+	_, err := snap.Parser(snap.Client()).ParseArgs([]string{"refresh", "--hold", "--dry-run"})
+	c.Assert(err, check.ErrorMatches, "cannot use --hold with other flags")
+}
+
 func (s *SnapSuite) TestRefreshHoldAllowedTimeUnits(c *check.C) {
 	now := time.Now()
 	restore := snap.MockTimeNow(func() time.Time {
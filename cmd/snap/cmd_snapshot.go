@@ -28,6 +28,7 @@ import (
 
 	"github.com/jessevdk/go-flags"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/strutil/quantity"
@@ -64,6 +65,10 @@ for which users, or a combination of these.
 If a snap is included in a save operation, excluding its system and
 configuration data from the snapshot is not currently possible. This
 restriction may be lifted in the future.
+
+With --encrypt, the snapshot data is encrypted with a key of its own that
+is kept on the device. This is only possible on systems with encrypted
+data, and such snapshots can only be exported with a passphrase.
 `)
 var longForgetHelp = i18n.G(`
 The forget command deletes a snapshot. This operation can not be
@@ -106,11 +111,16 @@ restriction may be lifted in the future.
 
 var longExportSnapshotHelp = i18n.G(`
 Export a snapshot to the given filename.
+
+With --passphrase-file, the export is encrypted with the passphrase read
+from the given file. Encrypted snapshots can only be exported this way.
 `)
 
 var longImportSnapshotHelp = i18n.G(`
 Import an exported snapshot set to the system. The snapshot is imported
 with a new snapshot ID and can be restored using the restore command.
+
+Exports encrypted with a passphrase require --passphrase-file.
 `)
 
 type savedCmd struct {
@@ -162,6 +172,9 @@ func (x *savedCmd) Execute([]string) error {
 			if sh.Auto {
 				notes = append(notes, "auto")
 			}
+			if sh.Encrypted {
+				notes = append(notes, "encrypted")
+			}
 			if sh.Broken != "" {
 				notes = append(notes, "broken: "+sh.Broken)
 			}
@@ -181,6 +194,7 @@ type saveCmd struct {
 	waitMixin
 	durationMixin
 	Users      string `long:"users"`
+	Encrypt    bool   `long:"encrypt"`
 	Positional struct {
 		Snaps []installedSnapName `positional-arg-name:"<snap>"`
 	} `positional-args:"yes"`
@@ -189,7 +203,7 @@ type saveCmd struct {
 func (x *saveCmd) Execute([]string) error {
 	snaps := installedSnapNames(x.Positional.Snaps)
 	users := strutil.CommaSeparatedList(x.Users)
-	setID, changeID, err := x.client.SnapshotMany(snaps, users)
+	setID, changeID, err := x.client.SnapshotMany(snaps, users, &client.SnapshotSaveOptions{Encrypt: x.Encrypt})
 	if err != nil {
 		return err
 	}
@@ -342,6 +356,8 @@ func init() {
 		}, durationDescs.also(waitDescs).also(map[string]string{
 			// TRANSLATORS: This should not start with a lowercase letter.
 			"users": i18n.G("Snapshot data of only specific users (comma-separated) (default: all users)"),
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"encrypt": i18n.G("Encrypt the snapshot data with a key kept on the device"),
 		}), nil)
 
 	addCommand("restore",
@@ -406,7 +422,10 @@ func init() {
 		longExportSnapshotHelp,
 		func() flags.Commander {
 			return &exportSnapshotCmd{}
-		}, nil, []argDesc{
+		}, map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase-file": i18n.G("Encrypt the export with the passphrase read from the given file"),
+		}, []argDesc{
 			{
 				name: "<id>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -425,7 +444,10 @@ func init() {
 		longImportSnapshotHelp,
 		func() flags.Commander {
 			return &importSnapshotCmd{}
-		}, nil, []argDesc{
+		}, durationDescs.also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase-file": i18n.G("Decrypt the export with the passphrase read from the given file"),
+		}), []argDesc{
 			{
 				name: "<filename>",
 				// TRANSLATORS: This should not start with a lowercase letter.
@@ -434,9 +456,24 @@ func init() {
 		})
 }
 
+// readPassphraseFile reads a passphrase from the given file, dropping the
+// trailing newline if any.
+func readPassphraseFile(filename flags.Filename) (string, error) {
+	data, err := os.ReadFile(string(filename))
+	if err != nil {
+		return "", fmt.Errorf(i18n.G("cannot read passphrase: %v"), err)
+	}
+	passphrase := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+	if passphrase == "" {
+		return "", fmt.Errorf(i18n.G("cannot use empty passphrase from %q"), filename)
+	}
+	return passphrase, nil
+}
+
 type exportSnapshotCmd struct {
 	clientMixin
-	Positional struct {
+	PassphraseFile flags.Filename `long:"passphrase-file"`
+	Positional     struct {
 		ID       snapshotID `positional-arg-name:"<id>"`
 		Filename string     `long:"filename"`
 	} `positional-args:"yes" required:"yes"`
@@ -448,7 +485,15 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 		return err
 	}
 
-	r, expectedSize, err := x.client.SnapshotExport(setID)
+	var opts client.SnapshotExportOptions
+	if x.PassphraseFile != "" {
+		opts.Passphrase, err = readPassphraseFile(x.PassphraseFile)
+		if err != nil {
+			return err
+		}
+	}
+
+	r, expectedSize, err := x.client.SnapshotExport(setID, &opts)
 	if err != nil {
 		return err
 	}
@@ -490,12 +535,22 @@ func (x *exportSnapshotCmd) Execute([]string) (err error) {
 type importSnapshotCmd struct {
 	clientMixin
 	durationMixin
-	Positional struct {
+	PassphraseFile flags.Filename `long:"passphrase-file"`
+	Positional     struct {
 		Filename string `long:"filename"`
 	} `positional-args:"yes" required:"yes"`
 }
 
 func (x *importSnapshotCmd) Execute([]string) error {
+	var opts client.SnapshotImportOptions
+	if x.PassphraseFile != "" {
+		var err error
+		opts.Passphrase, err = readPassphraseFile(x.PassphraseFile)
+		if err != nil {
+			return err
+		}
+	}
+
 	filename := x.Positional.Filename
 	f, err := os.Open(filename)
 	if err != nil {
@@ -507,7 +562,7 @@ func (x *importSnapshotCmd) Execute([]string) error {
 		return fmt.Errorf("cannot stat file: %v", err)
 	}
 
-	importSet, err := x.client.SnapshotImport(f, st.Size())
+	importSet, err := x.client.SnapshotImport(f, st.Size(), &opts)
 	if err != nil {
 		return err
 	}
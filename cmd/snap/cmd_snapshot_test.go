@@ -113,6 +113,94 @@ func (s *SnapSuite) TestSnapshotExportHappy(c *C) {
 	c.Check(exportedSnapshotPath+".part", testutil.FileAbsent)
 }
 
+func (s *SnapSuite) TestSnapshotExportPassphrase(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		c.Check(r.URL.Path, Equals, "/v2/snapshots/1/export")
+		c.Check(r.Header.Get(client.SnapshotPassphraseHeader), Equals, "c2Vrcml0IHBhc3NwaHJhc2U=")
+		w.Header().Set("Content-Type", client.SnapshotExportMediaType)
+		fmt.Fprint(w, "Hello World!")
+	})
+
+	dir := c.MkDir()
+	passphraseFile := filepath.Join(dir, "passphrase")
+	c.Assert(os.WriteFile(passphraseFile, []byte("sekrit passphrase\n"), 0600), IsNil)
+	exportedSnapshotPath := filepath.Join(dir, "export-snapshot.snapshot")
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--passphrase-file", passphraseFile, "1", exportedSnapshotPath})
+	c.Assert(err, IsNil)
+	c.Check(n, Equals, 1)
+	c.Check(exportedSnapshotPath, testutil.FileEquals, "Hello World!")
+}
+
+func (s *SnapSuite) TestSnapshotExportPassphraseFileErrors(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Fatalf("unexpected request")
+	})
+
+	dir := c.MkDir()
+	exportedSnapshotPath := filepath.Join(dir, "export-snapshot.snapshot")
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--passphrase-file", filepath.Join(dir, "missing"), "1", exportedSnapshotPath})
+	c.Check(err, ErrorMatches, "cannot read passphrase: .* no such file or directory")
+
+	emptyFile := filepath.Join(dir, "empty")
+	c.Assert(os.WriteFile(emptyFile, []byte("\n"), 0600), IsNil)
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"export-snapshot", "--passphrase-file", emptyFile, "1", exportedSnapshotPath})
+	c.Check(err, ErrorMatches, `cannot use empty passphrase from ".*/empty"`)
+	c.Check(exportedSnapshotPath, testutil.FileAbsent)
+}
+
+func (s *SnapSuite) TestSnapshotSaveEncrypt(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snaps":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]any{
+				"action":  "snapshot",
+				"snaps":   []any{"htop"},
+				"encrypt": true,
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9", "result": {"set-id": 5}}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {}}}`)
+		case "/v2/snapshots":
+			snapshotTime := time.Now().Format(time.RFC3339)
+			fmt.Fprintf(w, `{"type":"sync","status-code":200,"status":"OK","result":[{"id":5,"snapshots":[{"set":5,"time":%q,"snap":"htop","revision":"1168","epoch":{"read":[0],"write":[0]},"summary":"","version":"2","sha3-384":{"archive.tgz":""},"size":1,"encrypted":true}]}]}`, snapshotTime)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"save", "--encrypt", "htop"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.MatchesWrapped, "Set  Snap  Age    Version  Rev   Size    Notes\n5    htop  .*  2        1168      1B  encrypted\n")
+}
+
+func (s *SnapSuite) TestSnapshotImportPassphrase(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/v2/snapshots" && r.Method == "POST":
+			c.Check(r.Header.Get(client.SnapshotPassphraseHeader), Equals, "c2Vrcml0IHBhc3NwaHJhc2U=")
+			fmt.Fprintln(w, `{"type": "sync", "result": {"set-id": 42, "snaps": ["htop"]}}`)
+		case r.URL.Path == "/v2/snapshots":
+			fmt.Fprintln(w, `{"type":"sync","status-code":200,"status":"OK","result":[]}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	dir := c.MkDir()
+	passphraseFile := filepath.Join(dir, "passphrase")
+	c.Assert(os.WriteFile(passphraseFile, []byte("sekrit passphrase"), 0600), IsNil)
+	exportedSnapshotPath := filepath.Join(dir, "mocked-snapshot.snapshot")
+	c.Assert(os.WriteFile(exportedSnapshotPath, []byte("encrypted export data"), 0644), IsNil)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"import-snapshot", "--passphrase-file", passphraseFile, exportedSnapshotPath})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), testutil.Contains, "Imported snapshot as #42\n")
+}
+
 func (s *SnapSuite) mockSnapshotsServer(c *C) {
 	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
 		switch r.URL.Path {
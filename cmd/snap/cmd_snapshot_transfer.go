@@ -0,0 +1,238 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/i18n"
+)
+
+type cmdSnapshot struct{}
+
+var shortSnapshotHelp = i18n.G("Transfer snapshots to and from remote targets")
+var longSnapshotHelp = i18n.G(`
+The snapshot command contains sub-commands to push snapshots to, and
+pull snapshots from, remote targets.
+
+Targets are given as URLs. s3://host/bucket/key (or s3+http:// for plain
+HTTP) refers to an object in an S3-compatible object store, accessed with
+the access-key, secret-key and optionally region and session-token
+credentials. http:// and https:// URLs are written with PUT and read with
+GET, as done by WebDAV servers, using either the username and password or
+the token credentials.
+
+Credentials are read from the file given with --credentials-file, with one
+key=value pair per line.
+`)
+
+var (
+	shortSnapshotPushHelp = i18n.G("Push a snapshot to a remote target")
+	shortSnapshotPullHelp = i18n.G("Pull a snapshot from a remote target")
+)
+
+var longSnapshotPushHelp = i18n.G(`
+The push command uploads an export of the given snapshot to the target.
+Interrupted uploads are resumed if the target supports it.
+
+With --passphrase-file, the export is encrypted with the passphrase read
+from the given file. Encrypted snapshots can only be pushed this way.
+`)
+
+var longSnapshotPullHelp = i18n.G(`
+The pull command downloads the export in the target and imports it as a
+new snapshot, which can be restored using the restore command.
+
+Exports encrypted with a passphrase require --passphrase-file.
+`)
+
+// readCredentialsFile reads key=value pairs, one per line, from the given
+// file. Empty lines and lines starting with # are ignored.
+func readCredentialsFile(filename flags.Filename) (map[string]string, error) {
+	f, err := os.Open(string(filename))
+	if err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot read credentials: %v"), err)
+	}
+	defer f.Close()
+
+	creds := make(map[string]string)
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			return nil, fmt.Errorf(i18n.G("cannot parse line %d of credentials file %q: expected key=value"), n, filename)
+		}
+		creds[key] = strings.TrimSpace(value)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf(i18n.G("cannot read credentials: %v"), err)
+	}
+	return creds, nil
+}
+
+type snapshotTransferMixin struct {
+	CredentialsFile flags.Filename `long:"credentials-file"`
+	PassphraseFile  flags.Filename `long:"passphrase-file"`
+}
+
+func (x *snapshotTransferMixin) target(url string) (*client.SnapshotTarget, *client.SnapshotTransferOptions, error) {
+	target := &client.SnapshotTarget{URL: url}
+	var opts client.SnapshotTransferOptions
+	var err error
+	if x.CredentialsFile != "" {
+		target.Credentials, err = readCredentialsFile(x.CredentialsFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if x.PassphraseFile != "" {
+		opts.Passphrase, err = readPassphraseFile(x.PassphraseFile)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return target, &opts, nil
+}
+
+type snapshotPushCmd struct {
+	waitMixin
+	snapshotTransferMixin
+	Positional struct {
+		ID  snapshotID `positional-arg-name:"<id>"`
+		URL string     `positional-arg-name:"<url>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *snapshotPushCmd) Execute([]string) error {
+	setID, err := x.Positional.ID.ToUint()
+	if err != nil {
+		return err
+	}
+	target, opts, err := x.target(x.Positional.URL)
+	if err != nil {
+		return err
+	}
+
+	changeID, err := x.client.PushSnapshot(setID, target, opts)
+	if err != nil {
+		return err
+	}
+	_, err = x.wait(changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	// TRANSLATORS: the first argument is the identifier of the snapshot, the second one is the URL of the target.
+	fmt.Fprintf(Stdout, i18n.G("Pushed snapshot #%s to %s\n"), x.Positional.ID, x.Positional.URL)
+	return nil
+}
+
+type snapshotPullCmd struct {
+	waitMixin
+	snapshotTransferMixin
+	Positional struct {
+		URL string `positional-arg-name:"<url>"`
+	} `positional-args:"yes" required:"yes"`
+}
+
+func (x *snapshotPullCmd) Execute([]string) error {
+	target, opts, err := x.target(x.Positional.URL)
+	if err != nil {
+		return err
+	}
+
+	changeID, err := x.client.PullSnapshot(target, opts)
+	if err != nil {
+		return err
+	}
+	chg, err := x.wait(changeID)
+	if err == noWait {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var setID uint64
+	if err := chg.Get("set-id", &setID); err != nil {
+		return fmt.Errorf(i18n.G("cannot get ID of pulled snapshot: %v"), err)
+	}
+	fmt.Fprintf(Stdout, i18n.G("Pulled snapshot as #%d\n"), setID)
+	return nil
+}
+
+func init() {
+	transferDescs := map[string]string{
+		// TRANSLATORS: This should not start with a lowercase letter.
+		"credentials-file": i18n.G("Read the credentials for the target from the given file"),
+	}
+
+	addSnapshotCommand("push",
+		shortSnapshotPushHelp,
+		longSnapshotPushHelp,
+		func() flags.Commander {
+			return &snapshotPushCmd{}
+		}, waitDescs.also(transferDescs).also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase-file": i18n.G("Encrypt the pushed export with the passphrase read from the given file"),
+		}), []argDesc{
+			{
+				name: "<id>",
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("Set id of snapshot to push"),
+			}, {
+				// TRANSLATORS: This should retain < ... >. The URL is the location of the remote target.
+				name: i18n.G("<url>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("The URL of the target to push to"),
+			},
+		})
+
+	addSnapshotCommand("pull",
+		shortSnapshotPullHelp,
+		longSnapshotPullHelp,
+		func() flags.Commander {
+			return &snapshotPullCmd{}
+		}, waitDescs.also(transferDescs).also(map[string]string{
+			// TRANSLATORS: This should not start with a lowercase letter.
+			"passphrase-file": i18n.G("Decrypt the pulled export with the passphrase read from the given file"),
+		}), []argDesc{
+			{
+				// TRANSLATORS: This should retain < ... >. The URL is the location of the remote target.
+				name: i18n.G("<url>"),
+				// TRANSLATORS: This should not start with a lowercase letter.
+				desc: i18n.G("The URL of the target to pull from"),
+			},
+		})
+}
@@ -0,0 +1,140 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package main_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	. "gopkg.in/check.v1"
+
+	main "github.com/snapcore/snapd/cmd/snap"
+)
+
+func (s *SnapSuite) TestSnapshotPush(c *C) {
+	n := 0
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		n++
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]any{
+				"action": "push",
+				"set":    json.Number("42"),
+				"target": map[string]any{
+					"url": "s3://minio:9000/bucket/key",
+					"credentials": map[string]any{
+						"access-key": "access",
+						"secret-key": "se=cret",
+					},
+				},
+				"passphrase": "sekrit",
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"snap-names": ["htop"]}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	dir := c.MkDir()
+	credsFile := filepath.Join(dir, "creds")
+	c.Assert(os.WriteFile(credsFile, []byte("# minio\naccess-key=access\n\nsecret-key = se=cret\n"), 0600), IsNil)
+	passphraseFile := filepath.Join(dir, "passphrase")
+	c.Assert(os.WriteFile(passphraseFile, []byte("sekrit\n"), 0600), IsNil)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot", "push", "--credentials-file", credsFile, "--passphrase-file", passphraseFile, "42", "s3://minio:9000/bucket/key"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Pushed snapshot #42 to s3://minio:9000/bucket/key\n")
+	c.Check(s.Stderr(), Equals, "")
+	c.Check(n, Equals, 2)
+}
+
+func (s *SnapSuite) TestSnapshotPushErrors(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		c.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot", "push", "x", "s3://minio/bucket/key"})
+	c.Check(err, ErrorMatches, `invalid argument for snapshot set id: expected a non-negative integer argument \(see 'snap help saved'\)`)
+
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"snapshot", "push", "1"})
+	c.Check(err, ErrorMatches, "the required argument `<url>` was not provided")
+
+	dir := c.MkDir()
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"snapshot", "push", "--credentials-file", filepath.Join(dir, "missing"), "1", "s3://minio/bucket/key"})
+	c.Check(err, ErrorMatches, "cannot read credentials: open .*/missing: no such file or directory")
+
+	credsFile := filepath.Join(dir, "creds")
+	c.Assert(os.WriteFile(credsFile, []byte("access-key=access\nsecret-key\n"), 0600), IsNil)
+	_, err = main.Parser(main.Client()).ParseArgs([]string{"snapshot", "push", "--credentials-file", credsFile, "1", "s3://minio/bucket/key"})
+	c.Check(err, ErrorMatches, `cannot parse line 2 of credentials file ".*/creds": expected key=value`)
+}
+
+func (s *SnapSuite) TestSnapshotPull(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			c.Check(r.Method, Equals, "POST")
+			c.Check(DecodedRequestBody(c, r), DeepEquals, map[string]any{
+				"action": "pull",
+				"set":    json.Number("0"),
+				"target": map[string]any{
+					"url":         "https://dav.example.com/snapshot.tar",
+					"credentials": map[string]any{"token": "t"},
+				},
+			})
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		case "/v2/changes/9":
+			fmt.Fprintln(w, `{"type": "sync", "result": {"ready": true, "status": "Done", "data": {"set-id": 7, "snap-names": ["htop"]}}}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	credsFile := filepath.Join(c.MkDir(), "creds")
+	c.Assert(os.WriteFile(credsFile, []byte("token=t\n"), 0600), IsNil)
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot", "pull", "--credentials-file", credsFile, "https://dav.example.com/snapshot.tar"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "Pulled snapshot as #7\n")
+}
+
+func (s *SnapSuite) TestSnapshotPullNoWait(c *C) {
+	s.RedirectClientToTestServer(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/v2/snapshots":
+			w.WriteHeader(202)
+			fmt.Fprintln(w, `{"type":"async", "status-code": 202, "change": "9"}`)
+		default:
+			c.Errorf("unexpected path %q", r.URL.Path)
+		}
+	})
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"snapshot", "pull", "--no-wait", "https://dav.example.com/snapshot.tar"})
+	c.Assert(err, IsNil)
+	c.Check(s.Stdout(), Equals, "9\n")
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
 )
 
@@ -36,6 +37,7 @@ type cmdValidate struct {
 	Monitor    bool `long:"monitor"`
 	Enforce    bool `long:"enforce"`
 	Forget     bool `long:"forget"`
+	Preview    bool `long:"preview"`
 	Refresh    bool `long:"refresh"`
 	Positional struct {
 		ValidationSet string `positional-arg-name:"<validation-set>"`
@@ -53,6 +55,9 @@ fixed revisions.
 A validation set can either be in monitoring mode, in which case its constraints
 aren't enforced, or in enforcing mode, in which case snapd will not allow
 operations which would result in snaps breaking the validation set's constraints.
+
+With --preview, the changes to installed snaps and components that enforcing
+the validation set would need are listed, without enforcing it.
 `)
 
 func init() {
@@ -64,6 +69,8 @@ func init() {
 		// TRANSLATORS: This should not start with a lowercase letter.
 		"forget": i18n.G("Forget the given validation set"),
 		// TRANSLATORS: This should not start with a lowercase letter.
+		"preview": i18n.G("Show the changes enforcing the given validation set would need"),
+		// TRANSLATORS: This should not start with a lowercase letter.
 		"refresh": i18n.G("Refresh or install snaps to satisfy enforced validation sets"),
 	})), []argDesc{{
 		// TRANSLATORS: This needs to begin with < and end with >
@@ -97,6 +104,7 @@ func (cmd *cmdValidate) Execute(args []string) error {
 		{"monitor", cmd.Monitor},
 		{"enforce", cmd.Enforce},
 		{"forget", cmd.Forget},
+		{"preview", cmd.Preview},
 	} {
 		if a.set {
 			if action != "" {
@@ -154,6 +162,10 @@ func (cmd *cmdValidate) Execute(args []string) error {
 			return nil
 		}
 
+		if cmd.Preview {
+			return cmd.preview(accountID, name, seq)
+		}
+
 		// forget
 		if cmd.Forget {
 			return cmd.client.ForgetValidationSet(accountID, name, seq)
@@ -216,3 +228,36 @@ func (cmd *cmdValidate) Execute(args []string) error {
 
 	return nil
 }
+
+func fmtRevision(rev snap.Revision) string {
+	if rev.Unset() {
+		return "-"
+	}
+	return rev.String()
+}
+
+func (cmd *cmdValidate) preview(accountID, name string, seq int) error {
+	preview, err := cmd.client.PreviewValidationSet(accountID, name, seq)
+	if err != nil {
+		return err
+	}
+	vset := fmt.Sprintf("%s/%s=%d", preview.AccountID, preview.Name, preview.Sequence)
+	if preview.Conflict != "" {
+		return fmt.Errorf(i18n.G("cannot enforce validation set %s: %s"), vset, preview.Conflict)
+	}
+	if len(preview.Changes) == 0 {
+		fmt.Fprintf(Stdout, i18n.G("No changes needed to enforce validation set %s\n"), vset)
+		return nil
+	}
+
+	w := tabWriter()
+	fmt.Fprintln(w, i18n.G("Snap\tComponent\tAction\tCurrent\tRequired"))
+	for _, chg := range preview.Changes {
+		comp := chg.Component
+		if comp == "" {
+			comp = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\n", chg.Snap, comp, chg.Action, fmtRevision(chg.CurrentRevision), fmtRevision(chg.Revision))
+	}
+	return w.Flush()
+}
@@ -107,6 +107,8 @@ func (s *validateSuite) TestValidateInvalidArgs(c *check.C) {
 		{[]string{"--monitor"}, `missing validation set argument`},
 		{[]string{"--forget"}, `missing validation set argument`},
 		{[]string{"--forget", "foo/-"}, `cannot parse validation set "foo/-": invalid validation set name "-"`},
+		{[]string{"--preview", "--enforce"}, `cannot use --enforce and --preview together`},
+		{[]string{"--preview"}, `missing validation set argument`},
 	} {
 		s.stdout.Reset()
 		s.stderr.Reset()
@@ -303,3 +305,57 @@ func (s *validateSuite) TestValidationSetsRefreshEnforceNoUnmetConstraints(c *ch
 	c.Check(s.Stderr(), check.Equals, "")
 	c.Check(s.Stdout(), check.Equals, "Enforced validation set \"foo/bar\"\n")
 }
+
+func makeFakeValidationSetPreviewHandler(c *check.C, body, sequence string) func(w http.ResponseWriter, r *http.Request) {
+	var called bool
+	return func(w http.ResponseWriter, r *http.Request) {
+		if called {
+			c.Fatalf("expected a single request")
+		}
+		called = true
+		c.Check(r.URL.Path, check.Equals, "/v2/validation-sets/foo/bar")
+		c.Check(r.Method, check.Equals, "GET")
+		c.Check(r.URL.Query().Get("preview"), check.Equals, "true")
+		c.Check(r.URL.Query().Get("sequence"), check.Equals, sequence)
+		w.WriteHeader(200)
+		fmt.Fprintln(w, body)
+	}
+}
+
+func (s *validateSuite) TestValidatePreview(c *check.C) {
+	s.RedirectClientToTestServer(makeFakeValidationSetPreviewHandler(c, `{"type": "sync", "status-code": 200, "result": {
+		"account-id": "foo", "name": "bar", "sequence": 3,
+		"changes": [
+			{"action": "install", "snap": "baz"},
+			{"action": "refresh", "snap": "other", "revision": "5", "current-revision": "4"},
+			{"action": "remove", "snap": "other", "component": "comp", "current-revision": "2"}
+		]}}`, "3"))
+
+	rest, err := main.Parser(main.Client()).ParseArgs([]string{"validate", "--preview", "foo/bar=3"})
+	c.Assert(err, check.IsNil)
+	c.Check(rest, check.HasLen, 0)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, `Snap   Component  Action   Current  Required
+baz    -          install  -        -
+other  -          refresh  4        5
+other  comp       remove   2        -
+`)
+}
+
+func (s *validateSuite) TestValidatePreviewNoChanges(c *check.C) {
+	s.RedirectClientToTestServer(makeFakeValidationSetPreviewHandler(c, `{"type": "sync", "status-code": 200, "result": {"account-id": "foo", "name": "bar", "sequence": 3}}`, ""))
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"validate", "--preview", "foo/bar"})
+	c.Assert(err, check.IsNil)
+	c.Check(s.Stderr(), check.Equals, "")
+	c.Check(s.Stdout(), check.Equals, "No changes needed to enforce validation set foo/bar=3\n")
+}
+
+func (s *validateSuite) TestValidatePreviewConflict(c *check.C) {
+	s.RedirectClientToTestServer(makeFakeValidationSetPreviewHandler(c, `{"type": "sync", "status-code": 200, "result": {"account-id": "foo", "name": "bar", "sequence": 3, "conflict": "validation sets are in conflict:\n- cannot constrain snap \"baz\" as both invalid (foo/bar) and required at any revision (foo/other)"}}`, ""))
+
+	_, err := main.Parser(main.Client()).ParseArgs([]string{"validate", "--preview", "foo/bar"})
+	c.Assert(err, check.ErrorMatches, `cannot enforce validation set foo/bar=3: validation sets are in conflict:
+- cannot constrain snap "baz" as both invalid \(foo/bar\) and required at any revision \(foo/other\)`)
+	c.Check(s.Stdout(), check.Equals, "")
+}
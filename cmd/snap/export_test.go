@@ -479,6 +479,26 @@ func ParseQuotaValues(maxMemory, cpuMax, cpuSet, threadsMax, journalSizeMax, jou
 	return quotas.parseQuotas()
 }
 
+func ParseMemoryQuotaValues(memoryHigh, memorySwapMax string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.MemoryHigh = memoryHigh
+	quotas.MemorySwapMax = memorySwapMax
+
+	return quotas.parseQuotas()
+}
+
+func ParseIOQuotaValues(readBps, writeBps, readIOPS, writeIOPS string) (*client.QuotaValues, error) {
+	var quotas cmdSetQuota
+
+	quotas.IOReadBps = readBps
+	quotas.IOWriteBps = writeBps
+	quotas.IOReadIOPS = readIOPS
+	quotas.IOWriteIOPS = writeIOPS
+
+	return quotas.parseQuotas()
+}
+
 func MockSeedWriterReadManifest(f func(manifestFile string) (*seedwriter.Manifest, error)) (restore func()) {
 	restore = testutil.Backup(&seedwriterReadManifest)
 	seedwriterReadManifest = f
@@ -105,6 +105,12 @@ var debugCommands []*cmdInfo
 // routineCommands holds information about all internal commands.
 var routineCommands []*cmdInfo
 
+// snapshotCommands holds information about all "snap snapshot" commands.
+var snapshotCommands []*cmdInfo
+
+// clusterCommands holds information about all "snap cluster" commands.
+var clusterCommands []*cmdInfo
+
 // addCommand replaces parser.addCommand() in a way that is compatible with
 // re-constructing a pristine parser.
 func addCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
@@ -152,6 +158,38 @@ func addRoutineCommand(name, shortHelp, longHelp string, builder func() flags.Co
 	return info
 }
 
+// addSnapshotCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding "snap snapshot" commands.
+func addSnapshotCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+		optDescs:  optDescs,
+		argDescs:  argDescs,
+	}
+	snapshotCommands = append(snapshotCommands, info)
+	return info
+}
+
+// addClusterCommand replaces parser.addCommand() in a way that is
+// compatible with re-constructing a pristine parser. It is meant for
+// adding "snap cluster" commands.
+func addClusterCommand(name, shortHelp, longHelp string, builder func() flags.Commander, optDescs map[string]string, argDescs []argDesc) *cmdInfo {
+	info := &cmdInfo{
+		name:      name,
+		shortHelp: shortHelp,
+		longHelp:  longHelp,
+		builder:   builder,
+		optDescs:  optDescs,
+		argDescs:  argDescs,
+	}
+	clusterCommands = append(clusterCommands, info)
+	return info
+}
+
 type parserSetter interface {
 	setParser(*flags.Parser)
 }
@@ -340,7 +378,7 @@ func Parser(cli *client.Client) *flags.Parser {
 	// add --help like what go-flags would do for us, but hidden
 	addHelp(parser)
 
-	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands))
+	seen := make(map[string]bool, len(commands)+len(debugCommands)+len(routineCommands)+len(snapshotCommands)+len(clusterCommands))
 	checkUnique := func(ci *cmdInfo, kind string) {
 		if seen[ci.shortHelp] && ci.shortHelp != "Internal" && ci.shortHelp != "Deprecated (hidden)" {
 			logger.Panicf(`%scommand %q has an already employed description != "Internal"|"Deprecated (hidden)": %s`, kind, ci.name, ci.shortHelp)
@@ -371,6 +409,24 @@ func Parser(cli *client.Client) *flags.Parser {
 	registerCommands(cli, parser, routineCommand, routineCommands, func(ci *cmdInfo) {
 		checkUnique(ci, "routine ")
 	})
+	// Add the snapshot command
+	snapshotCommand, err := parser.AddCommand("snapshot", shortSnapshotHelp, longSnapshotHelp, &cmdSnapshot{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "snapshot", err)
+	}
+	// Add all the sub-commands of the snapshot command
+	registerCommands(cli, parser, snapshotCommand, snapshotCommands, func(ci *cmdInfo) {
+		checkUnique(ci, "snapshot ")
+	})
+	// Add the cluster command
+	clusterCommand, err := parser.AddCommand("cluster", shortClusterHelp, longClusterHelp, &cmdCluster{})
+	if err != nil {
+		logger.Panicf("cannot add command %q: %v", "cluster", err)
+	}
+	// Add all the sub-commands of the cluster command
+	registerCommands(cli, parser, clusterCommand, clusterCommands, func(ci *cmdInfo) {
+		checkUnique(ci, "cluster ")
+	})
 	return parser
 }
 
@@ -95,6 +95,9 @@ var api = []*Command{
 	requestsRuleCmd,
 	systemSecurebootCmd,
 	systemVolumesCmd,
+	clusterCmd,
+	clusterRolloutCmd,
+	clusterEvacuatedCmd,
 }
 
 type featureEndpoint struct {
@@ -30,6 +30,7 @@ import (
 	"strconv"
 	"strings"
 
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/client/clientutil"
 	"github.com/snapcore/snapd/osutil/user"
 	"github.com/snapcore/snapd/overlord/auth"
@@ -60,6 +61,8 @@ var (
 
 var serviceControlChangeKind = swfeats.RegisterChangeKind("service-control")
 
+var servicestateServiceProbeStatus = servicestate.ServiceProbeStatus
+
 var newStatusDecorator = func(ctx context.Context, isGlobal bool, uid string) clientutil.StatusDecorator {
 	if isGlobal {
 		return servicestate.NewStatusDecorator(progress.Null)
@@ -117,10 +120,33 @@ func getAppsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	if err != nil {
 		return InternalError("%v", err)
 	}
+	addProbeStatuses(c.d.overlord.State(), clientAppInfos)
 
 	return SyncResponse(clientAppInfos)
 }
 
+// addProbeStatuses adds the status of their liveness probe to the services.
+func addProbeStatuses(st *state.State, appInfos []client.AppInfo) {
+	st.Lock()
+	defer st.Unlock()
+	for i := range appInfos {
+		status := servicestateServiceProbeStatus(st, appInfos[i].Snap, appInfos[i].Name)
+		if status == nil {
+			continue
+		}
+		probe := &client.AppProbe{
+			Status:    "passing",
+			Failures:  status.Failures,
+			LastCheck: status.LastCheck,
+			Message:   status.Message,
+		}
+		if !status.Passing {
+			probe.Status = "failing"
+		}
+		appInfos[i].Probe = probe
+	}
+}
+
 type appInfoOptions struct {
 	service bool
 }
@@ -32,6 +32,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/check.v1"
 
@@ -365,6 +366,48 @@ func (s *appsSuite) TestGetAppsInfoServices(c *check.C) {
 	c.Check(sort.StringsAreSorted(appNames), check.Equals, true)
 }
 
+func (s *appsSuite) TestGetAppsInfoProbeStatus(c *check.C) {
+	lastCheck := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	r := daemon.MockServicestateServiceProbeStatus(func(st *state.State, snapName, appName string) *servicestate.ProbeStatus {
+		switch snapName + "." + appName {
+		case "snap-a.svc1":
+			return &servicestate.ProbeStatus{Passing: true, LastCheck: lastCheck}
+		case "snap-a.svc2":
+			return &servicestate.ProbeStatus{Failures: 2, LastCheck: lastCheck, Message: "connection refused"}
+		}
+		return nil
+	})
+	defer r()
+	for _, name := range []string{"snap-a.svc1", "snap-a.svc2"} {
+		s.SysctlBufs = append(s.SysctlBufs, []byte(fmt.Sprintf(`
+Id=snap.%s.service
+Names=snap.%[1]s.service
+Type=simple
+ActiveState=active
+UnitFileState=enabled
+NeedDaemonReload=no
+`[1:], name)))
+	}
+
+	req, err := http.NewRequest("GET", "/v2/apps?names=snap-a", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	apps := rsp.Result.([]client.AppInfo)
+	c.Assert(apps, check.HasLen, 2)
+	c.Check(apps[0].Probe, check.DeepEquals, &client.AppProbe{
+		Status:    "passing",
+		LastCheck: lastCheck,
+	})
+	c.Check(apps[1].Probe, check.DeepEquals, &client.AppProbe{
+		Status:    "failing",
+		Failures:  2,
+		LastCheck: lastCheck,
+		Message:   "connection refused",
+	})
+}
+
 func (s *appsSuite) TestGetAppsInfoServicesWithGlobal(c *check.C) {
 	// System services from active snaps
 	svcNames := []string{"snap-a.svc1", "snap-a.svc2"}
@@ -0,0 +1,214 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+)
+
+var clusterCmd = &Command{
+	Path:        "/v2/cluster",
+	POST:        postCluster,
+	Actions:     []string{"assemble", "join-request", "add-device", "remove-device", "update"},
+	WriteAccess: rootAccess{},
+}
+
+var clusterRolloutCmd = &Command{
+	Path:       "/v2/cluster/rollout",
+	GET:        getClusterRollout,
+	ReadAccess: rootAccess{},
+}
+
+var clusterEvacuatedCmd = &Command{
+	Path:       "/v2/cluster/evacuated",
+	GET:        getClusterEvacuated,
+	ReadAccess: rootAccess{},
+}
+
+var (
+	clusterstateAssemble             = clusterstate.Assemble
+	clusterstateNewJoinRequest       = clusterstate.NewJoinRequest
+	clusterstateAddDevice            = clusterstate.AddDevice
+	clusterstateRemoveDevice         = clusterstate.RemoveDevice
+	clusterstateInitializeNewCluster = clusterstate.InitializeNewCluster
+	clusterstateUpdateCluster        = clusterstate.UpdateCluster
+	clusterstateRollout              = clusterstate.Rollout
+	clusterstateEvacuatedSnaps       = clusterstate.EvacuatedSnaps
+)
+
+type postClusterData struct {
+	Action       string   `json:"action"`
+	Secret       string   `json:"secret"`
+	Address      string   `json:"address"`
+	ExpectedSize int      `json:"expected-size"`
+	Peers        []string `json:"peers,omitempty"`
+
+	// for add-device
+	Request     *clusterstate.JoinRequest `json:"request,omitempty"`
+	Subclusters []string                  `json:"subclusters,omitempty"`
+
+	// for remove-device
+	Device      int `json:"device,omitempty"`
+	Replacement int `json:"replacement,omitempty"`
+
+	// for update
+	Assertions string `json:"assertions,omitempty"`
+}
+
+func postCluster(c *Command, r *http.Request, _ *auth.UserState) Response {
+	var data postClusterData
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		return BadRequest("cannot decode cluster action from request body: %v", err)
+	}
+
+	switch data.Action {
+	case "assemble":
+		return assembleCluster(c, &data)
+	case "join-request":
+		return createClusterJoinRequest(c, &data)
+	case "add-device":
+		return addClusterDevice(c, &data)
+	case "remove-device":
+		return removeClusterDevice(c, &data)
+	case "update":
+		return updateCluster(c, &data)
+	default:
+		return BadRequest("unsupported cluster action %q", data.Action)
+	}
+}
+
+func assembleCluster(c *Command, data *postClusterData) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := clusterstateAssemble(st, clusterstate.AssembleOptions{
+		Secret:       data.Secret,
+		Address:      data.Address,
+		ExpectedSize: data.ExpectedSize,
+		Peers:        data.Peers,
+	})
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+
+	ensureStateSoon(st)
+	return AsyncResponse(nil, chg.ID())
+}
+
+func createClusterJoinRequest(c *Command, data *postClusterData) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	req, err := clusterstateNewJoinRequest(st, c.d.overlord.DeviceManager(), data.Secret, data.Address)
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+
+	return SyncResponse(req)
+}
+
+func addClusterDevice(c *Command, data *postClusterData) Response {
+	if data.Request == nil {
+		return BadRequest("cannot add device to cluster without a join request")
+	}
+
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	headers, err := clusterstateAddDevice(st, clusterstate.AddDeviceOptions{
+		Secret:      data.Secret,
+		Request:     *data.Request,
+		Subclusters: data.Subclusters,
+	})
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+
+	return SyncResponse(map[string]any{"cluster-assertion": headers})
+}
+
+func removeClusterDevice(c *Command, data *postClusterData) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	headers, err := clusterstateRemoveDevice(st, clusterstate.RemoveDeviceOptions{
+		Device:      data.Device,
+		Replacement: data.Replacement,
+	})
+	if err != nil {
+		return BadRequest(err.Error())
+	}
+
+	return SyncResponse(map[string]any{"cluster-assertion": headers})
+}
+
+func updateCluster(c *Command, data *postClusterData) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	err := clusterstateUpdateCluster(st, strings.NewReader(data.Assertions))
+	if errors.Is(err, clusterstate.ErrNoClusterAssertion) {
+		// this device is joining the cluster
+		err = clusterstateInitializeNewCluster(st, strings.NewReader(data.Assertions))
+	}
+	if err != nil {
+		return BadRequest("cannot update cluster: %v", err)
+	}
+
+	return SyncResponse(nil)
+}
+
+func getClusterRollout(c *Command, r *http.Request, _ *auth.UserState) Response {
+	// the state is unlocked while the other devices of the cluster are asked
+	// about their progress
+	rollouts, err := clusterstateRollout(r.Context(), c.d.overlord.State(), c.d.overlord.DeviceManager())
+	if errors.Is(err, clusterstate.ErrNoClusterAssertion) {
+		return NotFound("device is not part of a cluster")
+	}
+	if err != nil {
+		return BadRequest("cannot get cluster rollout: %v", err)
+	}
+
+	return SyncResponse(rollouts)
+}
+
+func getClusterEvacuated(c *Command, r *http.Request, _ *auth.UserState) Response {
+	st := c.d.overlord.State()
+	st.Lock()
+	defer st.Unlock()
+
+	names, err := clusterstateEvacuatedSnaps(st)
+	if err != nil {
+		return InternalError("cannot get evacuated snaps: %v", err)
+	}
+
+	return SyncResponse(names)
+}
@@ -0,0 +1,346 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/daemon"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+var _ = check.Suite(&clusterSuite{})
+
+type clusterSuite struct {
+	apiBaseSuite
+}
+
+func (s *clusterSuite) SetUpTest(c *check.C) {
+	s.apiBaseSuite.SetUpTest(c)
+	s.daemon(c)
+	s.expectWriteAccess(daemon.RootAccess{})
+
+	_, restore := daemon.MockEnsureStateSoon(func(st *state.State) {})
+	s.AddCleanup(restore)
+}
+
+func (s *clusterSuite) TestAssemble(c *check.C) {
+	var called int
+	s.AddCleanup(daemon.MockClusterstateAssemble(func(st *state.State, opts clusterstate.AssembleOptions) (*state.Change, error) {
+		called++
+		c.Check(opts, check.DeepEquals, clusterstate.AssembleOptions{
+			Secret:       "secret",
+			Address:      "192.168.1.10:7070",
+			ExpectedSize: 3,
+			Peers:        []string{"192.168.1.11:7070"},
+		})
+		return st.NewChange("assemble-cluster", "..."), nil
+	}))
+
+	body := `{"action": "assemble", "secret": "secret", "address": "192.168.1.10:7070", "expected-size": 3, "peers": ["192.168.1.11:7070"]}`
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.asyncReq(c, req, nil, actionIsExpected)
+	c.Check(called, check.Equals, 1)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "assemble-cluster")
+}
+
+func (s *clusterSuite) TestAssembleError(c *check.C) {
+	s.AddCleanup(daemon.MockClusterstateAssemble(func(st *state.State, opts clusterstate.AssembleOptions) (*state.Change, error) {
+		return nil, errors.New("cannot assemble cluster: secret must be provided")
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(`{"action": "assemble"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot assemble cluster: secret must be provided")
+}
+
+func (s *clusterSuite) TestJoinRequest(c *check.C) {
+	var called int
+	s.AddCleanup(daemon.MockClusterstateNewJoinRequest(func(st *state.State, signer clusterstate.DeviceKeySigner, secret, address string) (*clusterstate.JoinRequest, error) {
+		called++
+		c.Check(signer, check.Equals, s.d.Overlord().DeviceManager())
+		c.Check(secret, check.Equals, "secret")
+		c.Check(address, check.Equals, "192.168.1.30:7070")
+		return &clusterstate.JoinRequest{
+			Identity: assemblestate.Identity{RDT: "rdt"},
+			Address:  address,
+		}, nil
+	}))
+
+	body := `{"action": "join-request", "secret": "secret", "address": "192.168.1.30:7070"}`
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(called, check.Equals, 1)
+	c.Check(rsp.Result, check.DeepEquals, &clusterstate.JoinRequest{
+		Identity: assemblestate.Identity{RDT: "rdt"},
+		Address:  "192.168.1.30:7070",
+	})
+}
+
+func (s *clusterSuite) TestAddDevice(c *check.C) {
+	var called int
+	s.AddCleanup(daemon.MockClusterstateAddDevice(func(st *state.State, opts clusterstate.AddDeviceOptions) (map[string]any, error) {
+		called++
+		c.Check(opts, check.DeepEquals, clusterstate.AddDeviceOptions{
+			Secret: "secret",
+			Request: clusterstate.JoinRequest{
+				Identity: assemblestate.Identity{RDT: "rdt"},
+				Address:  "192.168.1.30:7070",
+			},
+			Subclusters: []string{"default"},
+		})
+		return map[string]any{"type": "cluster", "sequence": "2"}, nil
+	}))
+
+	body := `{"action": "add-device", "secret": "secret", "request": {"identity": {"rdt": "rdt"}, "address": "192.168.1.30:7070"}, "subclusters": ["default"]}`
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(body))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(called, check.Equals, 1)
+	c.Check(rsp.Result, check.DeepEquals, map[string]any{
+		"cluster-assertion": map[string]any{"type": "cluster", "sequence": "2"},
+	})
+}
+
+func (s *clusterSuite) TestAddDeviceNoRequest(c *check.C) {
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(`{"action": "add-device", "secret": "secret"}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot add device to cluster without a join request")
+}
+
+func (s *clusterSuite) TestRemoveDevice(c *check.C) {
+	var called int
+	s.AddCleanup(daemon.MockClusterstateRemoveDevice(func(st *state.State, opts clusterstate.RemoveDeviceOptions) (map[string]any, error) {
+		called++
+		c.Check(opts, check.DeepEquals, clusterstate.RemoveDeviceOptions{Device: 2, Replacement: 3})
+		return map[string]any{"type": "cluster", "sequence": "3"}, nil
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(`{"action": "remove-device", "device": 2, "replacement": 3}`))
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(called, check.Equals, 1)
+	c.Check(rsp.Result, check.DeepEquals, map[string]any{
+		"cluster-assertion": map[string]any{"type": "cluster", "sequence": "3"},
+	})
+}
+
+func (s *clusterSuite) TestRemoveDeviceError(c *check.C) {
+	s.AddCleanup(daemon.MockClusterstateRemoveDevice(func(st *state.State, opts clusterstate.RemoveDeviceOptions) (map[string]any, error) {
+		return nil, errors.New("cannot remove device 2 from cluster: device not found in cluster")
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(`{"action": "remove-device", "device": 2}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot remove device 2 from cluster: device not found in cluster")
+}
+
+func (s *clusterSuite) TestUpdate(c *check.C) {
+	var updated, initialized []string
+	s.AddCleanup(daemon.MockClusterstateUpdateCluster(func(st *state.State, bundle io.Reader) error {
+		data, err := io.ReadAll(bundle)
+		c.Check(err, check.IsNil)
+		updated = append(updated, string(data))
+		return nil
+	}))
+	s.AddCleanup(daemon.MockClusterstateInitializeNewCluster(func(st *state.State, bundle io.Reader) error {
+		data, err := io.ReadAll(bundle)
+		c.Check(err, check.IsNil)
+		initialized = append(initialized, string(data))
+		return nil
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(`{"action": "update", "assertions": "bundle"}`))
+	c.Assert(err, check.IsNil)
+
+	s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(updated, check.DeepEquals, []string{"bundle"})
+	c.Check(initialized, check.HasLen, 0)
+}
+
+func (s *clusterSuite) TestUpdateJoinsCluster(c *check.C) {
+	var initialized []string
+	s.AddCleanup(daemon.MockClusterstateUpdateCluster(func(st *state.State, bundle io.Reader) error {
+		return clusterstate.ErrNoClusterAssertion
+	}))
+	s.AddCleanup(daemon.MockClusterstateInitializeNewCluster(func(st *state.State, bundle io.Reader) error {
+		data, err := io.ReadAll(bundle)
+		c.Check(err, check.IsNil)
+		initialized = append(initialized, string(data))
+		return nil
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(`{"action": "update", "assertions": "bundle"}`))
+	c.Assert(err, check.IsNil)
+
+	s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(initialized, check.DeepEquals, []string{"bundle"})
+}
+
+func (s *clusterSuite) TestUpdateError(c *check.C) {
+	s.AddCleanup(daemon.MockClusterstateUpdateCluster(func(st *state.State, bundle io.Reader) error {
+		return errors.New("assertion bundle missing cluster assertion")
+	}))
+
+	req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(`{"action": "update", "assertions": ""}`))
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot update cluster: assertion bundle missing cluster assertion")
+}
+
+func (s *clusterSuite) TestBadRequests(c *check.C) {
+	for _, tc := range []struct {
+		body, err string
+	}{
+		{`{"action": "frobnicate"}`, `unsupported cluster action "frobnicate"`},
+		{`{`, `cannot decode cluster action from request body: .*`},
+	} {
+		req, err := http.NewRequest("POST", "/v2/cluster", bytes.NewBufferString(tc.body))
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil, actionIsUnexpected)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, tc.err)
+	}
+}
+
+func (s *clusterSuite) TestRollout(c *check.C) {
+	s.expectReadAccess(daemon.RootAccess{})
+
+	rollouts := []clusterstate.DeviceRollout{{
+		Device:   1,
+		DeviceID: "serial-1.model.brand",
+		Sequence: 2,
+		Subclusters: map[string]clusterstate.SubclusterRollout{
+			"ha": {Status: clusterstate.RolloutDone, Healthy: true},
+		},
+	}, {
+		Device:   2,
+		DeviceID: "serial-2.model.brand",
+		Error:    "connection refused",
+	}}
+	s.AddCleanup(daemon.MockClusterstateRollout(func(ctx context.Context, st *state.State, signer clusterstate.DeviceKeySigner) ([]clusterstate.DeviceRollout, error) {
+		// queries to the other devices are signed with the device key
+		c.Check(signer, check.Equals, s.d.Overlord().DeviceManager())
+		return rollouts, nil
+	}))
+
+	req, err := http.NewRequest("GET", "/v2/cluster/rollout", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsUnexpected)
+	c.Check(rsp.Result, check.DeepEquals, rollouts)
+}
+
+func (s *clusterSuite) TestRolloutErrors(c *check.C) {
+	s.expectReadAccess(daemon.RootAccess{})
+
+	for _, tc := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{clusterstate.ErrNoClusterAssertion, 404, "device is not part of a cluster"},
+		{errors.New("boom"), 400, "cannot get cluster rollout: boom"},
+	} {
+		restore := daemon.MockClusterstateRollout(func(ctx context.Context, st *state.State, signer clusterstate.DeviceKeySigner) ([]clusterstate.DeviceRollout, error) {
+			return nil, tc.err
+		})
+
+		req, err := http.NewRequest("GET", "/v2/cluster/rollout", nil)
+		c.Assert(err, check.IsNil)
+
+		rspe := s.errorReq(c, req, nil, actionIsUnexpected)
+		c.Check(rspe.Status, check.Equals, tc.status)
+		c.Check(rspe.Message, check.Equals, tc.message)
+		restore()
+	}
+}
+
+func (s *clusterSuite) TestEvacuated(c *check.C) {
+	s.expectReadAccess(daemon.RootAccess{})
+
+	s.AddCleanup(daemon.MockClusterstateEvacuatedSnaps(func(st *state.State) ([]string, error) {
+		return []string{"bar", "foo"}, nil
+	}))
+
+	req, err := http.NewRequest("GET", "/v2/cluster/evacuated", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsUnexpected)
+	c.Check(rsp.Result, check.DeepEquals, []string{"bar", "foo"})
+}
+
+func (s *clusterSuite) TestEvacuatedNone(c *check.C) {
+	s.expectReadAccess(daemon.RootAccess{})
+
+	// the real thing, nothing was evacuated
+	req, err := http.NewRequest("GET", "/v2/cluster/evacuated", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsUnexpected)
+	c.Check(rsp.Result, check.DeepEquals, []string{})
+}
+
+func (s *clusterSuite) TestEvacuatedError(c *check.C) {
+	s.expectReadAccess(daemon.RootAccess{})
+
+	s.AddCleanup(daemon.MockClusterstateEvacuatedSnaps(func(st *state.State) ([]string, error) {
+		return nil, errors.New("boom")
+	}))
+
+	req, err := http.NewRequest("GET", "/v2/cluster/evacuated", nil)
+	c.Assert(err, check.IsNil)
+
+	rspe := s.errorReq(c, req, nil, actionIsUnexpected)
+	c.Check(rspe.Status, check.Equals, 500)
+	c.Check(rspe.Message, check.Equals, "cannot get evacuated snaps: boom")
+}
@@ -24,6 +24,7 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
 	"sort"
 	"time"
 
@@ -35,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/fdestate"
@@ -161,6 +163,12 @@ func sysInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 	} else {
 		refreshInfo.Schedule = refreshScheduleStr
 	}
+	if err := tr.GetMaybe("core", "refresh.blackout", &refreshInfo.Blackout); err != nil {
+		return InternalError("cannot get refresh blackout: %s", err)
+	}
+	if err := tr.GetMaybe("core", "refresh.window-end-behavior", &refreshInfo.WindowEndBehavior); err != nil {
+		return InternalError("cannot get refresh window end behavior: %s", err)
+	}
 
 	m := map[string]any{
 		"series":         release.Series,
@@ -289,13 +297,48 @@ func getChange(c *Command, r *http.Request, user *auth.UserState) Response {
 	chID := muxVars(r)["id"]
 	state := c.d.overlord.State()
 	state.Lock()
-	defer state.Unlock()
-	chg := state.Change(chID)
-	if chg == nil {
+	var chgInfo *changeInfo
+	if chg := state.Change(chID); chg != nil {
+		chgInfo = change2changeInfo(chg)
+	}
+	state.Unlock()
+	if chgInfo != nil {
+		return SyncResponse(chgInfo)
+	}
+
+	// the change might have been pruned already
+	archived, err := c.d.overlord.ChangeArchive().Changes(&changearchive.Filter{ID: chID})
+	if err != nil {
+		return InternalError("cannot read change archive: %v", err)
+	}
+	if len(archived) == 0 {
 		return NotFound("cannot find change with id %q", chID)
 	}
+	return SyncResponse(archivedChange2changeInfo(archived[len(archived)-1]))
+}
 
-	return SyncResponse(change2changeInfo(chg))
+func getArchivedChanges(c *Command, query url.Values) Response {
+	filter := &changearchive.Filter{
+		Kind:     query.Get("kind"),
+		SnapName: query.Get("for"),
+	}
+	if since := query.Get("since"); since != "" {
+		t, err := time.Parse(time.RFC3339, since)
+		if err != nil {
+			return BadRequest("invalid since parameter: %v", err)
+		}
+		filter.Since = t
+	}
+
+	archived, err := c.d.overlord.ChangeArchive().Changes(filter)
+	if err != nil {
+		return InternalError("cannot read change archive: %v", err)
+	}
+	chgInfos := make([]*changeInfo, 0, len(archived))
+	for _, chg := range archived {
+		chgInfos = append(chgInfos, archivedChange2changeInfo(chg))
+	}
+	return SyncResponse(chgInfos)
 }
 
 func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -304,6 +347,12 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	if qselect == "" {
 		qselect = "in-progress"
 	}
+	if qselect == "archived" {
+		return getArchivedChanges(c, query)
+	}
+	if query.Get("since") != "" || query.Get("kind") != "" {
+		return BadRequest("since and kind can only be used to select archived changes")
+	}
 	var filter func(*state.Change) bool
 	switch qselect {
 	case "all":
@@ -313,7 +362,7 @@ func getChanges(c *Command, r *http.Request, user *auth.UserState) Response {
 	case "ready":
 		filter = func(chg *state.Change) bool { return chg.IsReady() }
 	default:
-		return BadRequest("select should be one of: all,in-progress,ready")
+		return BadRequest("select should be one of: all,in-progress,ready,archived")
 	}
 
 	if wantedName := query.Get("for"); wantedName != "" {
@@ -450,29 +499,7 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	tasks := chg.Tasks()
 	taskInfos := make([]*taskInfo, len(tasks))
 	for j, t := range tasks {
-		label, done, total := t.Progress()
-
-		taskInfo := &taskInfo{
-			ID:      t.ID(),
-			Kind:    t.Kind(),
-			Summary: t.Summary(),
-			Status:  t.Status().String(),
-			Log:     t.Log(),
-			Progress: taskInfoProgress{
-				Label: label,
-				Done:  done,
-				Total: total,
-			},
-			SpawnTime: t.SpawnTime(),
-		}
-		readyTime := t.ReadyTime()
-		if !readyTime.IsZero() {
-			taskInfo.ReadyTime = &readyTime
-		}
-		if data, err := taskApiData(t); err == nil {
-			taskInfo.Data = data
-		}
-		taskInfos[j] = taskInfo
+		taskInfos[j] = task2taskInfo(t)
 	}
 	chgInfo.Tasks = taskInfos
 
@@ -484,6 +511,71 @@ func change2changeInfo(chg *state.Change) *changeInfo {
 	return chgInfo
 }
 
+func task2taskInfo(t *state.Task) *taskInfo {
+	label, done, total := t.Progress()
+
+	taskInfo := &taskInfo{
+		ID:      t.ID(),
+		Kind:    t.Kind(),
+		Summary: t.Summary(),
+		Status:  t.Status().String(),
+		Log:     t.Log(),
+		Progress: taskInfoProgress{
+			Label: label,
+			Done:  done,
+			Total: total,
+		},
+		SpawnTime: t.SpawnTime(),
+	}
+	readyTime := t.ReadyTime()
+	if !readyTime.IsZero() {
+		taskInfo.ReadyTime = &readyTime
+	}
+	if data, err := taskApiData(t); err == nil {
+		taskInfo.Data = data
+	}
+	return taskInfo
+}
+
+func archivedChange2changeInfo(chg *changearchive.Change) *changeInfo {
+	readyTime := chg.ReadyTime
+	chgInfo := &changeInfo{
+		ID:      chg.ID,
+		Kind:    chg.Kind,
+		Summary: chg.Summary,
+		Status:  chg.Status,
+		Ready:   true,
+		Err:     chg.Err,
+
+		SpawnTime: chg.SpawnTime,
+		ReadyTime: &readyTime,
+
+		Data: chg.Data,
+	}
+	chgInfo.Tasks = make([]*taskInfo, len(chg.Tasks))
+	for j, t := range chg.Tasks {
+		taskInfo := &taskInfo{
+			ID:      t.ID,
+			Kind:    t.Kind,
+			Summary: t.Summary,
+			Status:  t.Status,
+			Log:     t.Log,
+			Progress: taskInfoProgress{
+				Label: t.ProgressLabel,
+				Done:  t.ProgressDone,
+				Total: t.ProgressTotal,
+			},
+			SpawnTime: t.SpawnTime,
+		}
+		if !t.ReadyTime.IsZero() {
+			readyTime := t.ReadyTime
+			taskInfo.ReadyTime = &readyTime
+		}
+		chgInfo.Tasks[j] = taskInfo
+	}
+	return chgInfo
+}
+
 var snapstateSnapsAffectedByTask = snapstate.SnapsAffectedByTask
 
 // taskApiData returns a map similar to change data which is currently
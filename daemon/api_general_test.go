@@ -34,6 +34,7 @@ import (
 
 	"github.com/snapcore/snapd/arch"
 	"github.com/snapcore/snapd/boot"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/dirs/dirstest"
@@ -506,6 +507,29 @@ func (s *generalSuite) TestSysInfoIsManaged(c *check.C) {
 	c.Check(rsp.Result.(map[string]any)["managed"], check.Equals, true)
 }
 
+func (s *generalSuite) TestSysInfoRefreshBlackout(c *check.C) {
+	s.expectSystemInfoReadAccess()
+	d := s.daemon(c)
+
+	st := d.Overlord().State()
+	st.Lock()
+	tr := config.NewTransaction(st)
+	tr.Set("core", "refresh.blackout", "11-20..01-05")
+	tr.Set("core", "refresh.window-end-behavior", "defer")
+	tr.Commit()
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/system-info", nil)
+	c.Assert(err, check.IsNil)
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Result.(map[string]any)["refresh"], check.DeepEquals, client.RefreshInfo{
+		Timer:             "00:00~24:00/4",
+		Blackout:          "11-20..01-05",
+		WindowEndBehavior: "defer",
+	})
+}
+
 func (s *generalSuite) TestSysInfoWorksDegraded(c *check.C) {
 	s.expectSystemInfoReadAccess()
 	d := s.daemon(c)
@@ -1073,6 +1097,100 @@ func (s *generalSuite) TestStateChange(c *check.C) {
 	})
 }
 
+func (s *generalSuite) setupArchivedChanges(c *check.C) (d *daemon.Daemon, ids []string) {
+	d = s.daemon(c)
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	ids = setupChanges(st)
+	// only the "remove" change is ready, and gets archived
+	st.Prune(time.Now(), 0, time.Hour, 100)
+	c.Assert(st.Change(ids[1]), check.IsNil)
+	return d, ids
+}
+
+func (s *generalSuite) TestStateChangesArchived(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	s.expectChangesReadAccess()
+	_, ids := s.setupArchivedChanges(c)
+
+	req, err := http.NewRequest("GET", "/v2/changes?select=archived", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.HasLen, 1)
+
+	rec := httptest.NewRecorder()
+	rsp.ServeHTTP(rec, nil)
+	c.Assert(rec.Code, check.Equals, 200)
+	c.Check(rec.Body.String(), check.Matches, `.*{"id":"`+ids[1]+`","kind":"remove","summary":"remove..","status":"Error","tasks":\[{"id":"`+ids[4]+`","kind":"unlink","summary":"1...","status":"Error","log":\["2016-04-21T01:02:03Z ERROR rm failed"],"progress":{"label":"","done":1,"total":1},"spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}],"ready":true,"err":"[^"]+","spawn-time":"2016-04-21T01:02:03Z","ready-time":"2016-04-21T01:02:03Z"}.*`)
+
+	for _, tc := range []struct {
+		query string
+		n     int
+	}{
+		{"select=archived&kind=remove", 1},
+		{"select=archived&kind=install", 0},
+		{"select=archived&since=2016-04-21T00:00:00Z", 1},
+		{"select=archived&since=2016-04-22T00:00:00Z", 0},
+		{"select=archived&for=funky-snap-name", 0},
+	} {
+		req, err := http.NewRequest("GET", "/v2/changes?"+tc.query, nil)
+		c.Assert(err, check.IsNil)
+		rsp := s.syncReq(c, req, nil, actionIsExpected)
+		c.Check(rsp.Result, check.HasLen, tc.n, check.Commentf(tc.query))
+	}
+}
+
+func (s *generalSuite) TestStateChangesArchivedErrors(c *check.C) {
+	s.expectChangesReadAccess()
+	s.daemon(c)
+
+	for _, tc := range []struct {
+		query string
+		err   string
+	}{
+		{"select=archived&since=yesterday", `invalid since parameter: .*`},
+		{"select=all&since=2016-04-22T00:00:00Z", `since and kind can only be used to select archived changes`},
+		{"kind=remove", `since and kind can only be used to select archived changes`},
+		{"select=other", `select should be one of: all,in-progress,ready,archived`},
+	} {
+		req, err := http.NewRequest("GET", "/v2/changes?"+tc.query, nil)
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Matches, tc.err)
+	}
+}
+
+func (s *generalSuite) TestStateChangeArchived(c *check.C) {
+	restore := state.MockTime(time.Date(2016, 04, 21, 1, 2, 3, 0, time.UTC))
+	defer restore()
+
+	s.expectChangeReadAccess()
+	_, ids := s.setupArchivedChanges(c)
+
+	req, err := http.NewRequest("GET", "/v2/changes/"+ids[1], nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, &daemon.ChangeInfo{})
+	chgInfo := rsp.Result.(*daemon.ChangeInfo)
+	c.Check(chgInfo.Kind, check.Equals, "remove")
+	c.Check(chgInfo.Status, check.Equals, "Error")
+	c.Check(chgInfo.Ready, check.Equals, true)
+	c.Assert(chgInfo.Tasks, check.HasLen, 1)
+	c.Check(chgInfo.Tasks[0].ID, check.Equals, ids[4])
+
+	req, err = http.NewRequest("GET", "/v2/changes/9999", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 404)
+	c.Check(rspe.Message, check.Equals, `cannot find change with id "9999"`)
+}
+
 func (s *generalSuite) expectManageAccess() {
 	s.expectWriteAccess(daemon.AuthenticatedAccess{Polkit: "io.snapcraft.snapd.manage"})
 }
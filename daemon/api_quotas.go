@@ -20,6 +20,7 @@
 package daemon
 
 import (
+	"errors"
 	"net/http"
 	"sort"
 
@@ -31,6 +32,7 @@ import (
 	"github.com/snapcore/snapd/overlord/swfeats"
 	"github.com/snapcore/snapd/snap/naming"
 	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/systemd"
 )
 
 var (
@@ -51,12 +53,13 @@ var (
 
 type postQuotaGroupData struct {
 	// Action can be "ensure" or "remove"
-	Action      string             `json:"action"`
-	GroupName   string             `json:"group-name"`
-	Parent      string             `json:"parent,omitempty"`
-	Snaps       []string           `json:"snaps,omitempty"`
-	Services    []string           `json:"services,omitempty"`
-	Constraints client.QuotaValues `json:"constraints,omitempty"`
+	Action            string             `json:"action"`
+	GroupName         string             `json:"group-name"`
+	Parent            string             `json:"parent,omitempty"`
+	Snaps             []string           `json:"snaps,omitempty"`
+	Services          []string           `json:"services,omitempty"`
+	Constraints       client.QuotaValues `json:"constraints,omitempty"`
+	MemoryEventPolicy string             `json:"memory-event-policy,omitempty"`
 }
 
 var (
@@ -86,12 +89,29 @@ var getQuotaUsage = func(grp *quota.Group) (*client.QuotaValues, error) {
 		currentUsage.Threads = threads
 	}
 
+	if len(grp.IOLimit) != 0 {
+		read, write, err := grp.CurrentIOUsage()
+		switch {
+		case errors.Is(err, systemd.ErrIOUsageUnavailable):
+			// without io accounting there is no usage to report
+		case err != nil:
+			return nil, err
+		default:
+			currentUsage.IO = &client.QuotaIOValues{
+				ReadBytes:  read,
+				WriteBytes: write,
+			}
+		}
+	}
+
 	return &currentUsage, nil
 }
 
 func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 	var constraints client.QuotaValues
 	constraints.Memory = grp.MemoryLimit
+	constraints.MemoryHigh = grp.MemoryHighLimit
+	constraints.MemorySwap = grp.MemorySwapLimit
 	constraints.Threads = grp.ThreadLimit
 
 	if grp.CPULimit != nil {
@@ -114,6 +134,18 @@ func createQuotaValues(grp *quota.Group) *client.QuotaValues {
 			}
 		}
 	}
+	if len(grp.IOLimit) != 0 {
+		constraints.IO = &client.QuotaIOValues{}
+		for _, dev := range grp.IOLimit {
+			constraints.IO.Devices = append(constraints.IO.Devices, client.QuotaIODeviceValues{
+				Device:    dev.Device,
+				ReadBps:   dev.ReadBps,
+				WriteBps:  dev.WriteBps,
+				ReadIOPS:  dev.ReadIOPS,
+				WriteIOPS: dev.WriteIOPS,
+			})
+		}
+	}
 	return &constraints
 }
 
@@ -183,14 +215,29 @@ func getQuotaGroupInfo(c *Command, r *http.Request, _ *auth.UserState) Response
 		return InternalError(err.Error())
 	}
 
+	memoryEvents, err := servicestate.QuotaMemoryEventsForGroup(st, groupName)
+	if err != nil {
+		return InternalError(err.Error())
+	}
+
 	res := client.QuotaGroupResult{
-		GroupName:   group.Name,
-		Parent:      group.ParentGroup,
-		Snaps:       group.Snaps,
-		Services:    group.Services,
-		Subgroups:   group.SubGroups,
-		Constraints: createQuotaValues(group),
-		Current:     currentUsage,
+		GroupName:         group.Name,
+		Parent:            group.ParentGroup,
+		Snaps:             group.Snaps,
+		Services:          group.Services,
+		Subgroups:         group.SubGroups,
+		Constraints:       createQuotaValues(group),
+		Current:           currentUsage,
+		MemoryEventPolicy: group.MemoryEventPolicy,
+	}
+	if memoryEvents != nil {
+		res.MemoryEvents = &client.QuotaMemoryEvents{
+			High:    memoryEvents.High,
+			Max:     memoryEvents.Max,
+			OOM:     memoryEvents.OOM,
+			OOMKill: memoryEvents.OOMKill,
+			Last:    memoryEvents.Last,
+		}
 	}
 	return SyncResponse(res)
 }
@@ -200,6 +247,12 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 	if values.Memory != 0 {
 		resourcesBuilder.WithMemoryLimit(values.Memory)
 	}
+	if values.MemoryHigh != 0 {
+		resourcesBuilder.WithMemoryHighLimit(values.MemoryHigh)
+	}
+	if values.MemorySwap != 0 {
+		resourcesBuilder.WithMemorySwapLimit(values.MemorySwap)
+	}
 	if values.CPU != nil {
 		if values.CPU.Count != 0 {
 			resourcesBuilder.WithCPUCount(values.CPU.Count)
@@ -223,6 +276,16 @@ func quotaValuesToResources(values client.QuotaValues) quota.Resources {
 			resourcesBuilder.WithJournalRate(values.Journal.RateCount, values.Journal.RatePeriod)
 		}
 	}
+	if values.IO != nil {
+		// zero values are passed on too, so that a device without any
+		// limit is rejected when validating the resources
+		for _, dev := range values.IO.Devices {
+			resourcesBuilder.WithIOReadBandwidth(dev.Device, dev.ReadBps)
+			resourcesBuilder.WithIOWriteBandwidth(dev.Device, dev.WriteBps)
+			resourcesBuilder.WithIOReadIOPS(dev.Device, dev.ReadIOPS)
+			resourcesBuilder.WithIOWriteIOPS(dev.Device, dev.WriteIOPS)
+		}
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -260,10 +323,11 @@ func postQuotaGroup(c *Command, r *http.Request, _ *auth.UserState) Response {
 		if err == servicestate.ErrQuotaNotFound {
 			// then we need to create the quota
 			ts, err = servicestateCreateQuota(st, data.GroupName, servicestate.CreateQuotaOptions{
-				ParentName:     data.Parent,
-				Snaps:          data.Snaps,
-				Services:       data.Services,
-				ResourceLimits: resourceLimits,
+				ParentName:        data.Parent,
+				Snaps:             data.Snaps,
+				Services:          data.Services,
+				ResourceLimits:    resourceLimits,
+				MemoryEventPolicy: data.MemoryEventPolicy,
 			})
 			if err != nil {
 				return errToResponse(err, nil, BadRequest, "cannot create quota group: %v")
@@ -272,9 +336,10 @@ func postQuotaGroup(c *Command, r *http.Request, _ *auth.UserState) Response {
 		} else if err == nil {
 			// the quota group already exists, update it
 			updateOpts := servicestate.UpdateQuotaOptions{
-				AddSnaps:          data.Snaps,
-				AddServices:       data.Services,
-				NewResourceLimits: resourceLimits,
+				AddSnaps:             data.Snaps,
+				AddServices:          data.Services,
+				NewResourceLimits:    resourceLimits,
+				NewMemoryEventPolicy: data.MemoryEventPolicy,
 			}
 			ts, err = servicestateUpdateQuota(st, data.GroupName, updateOpts)
 			if err != nil {
@@ -84,12 +84,16 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil, nil,
 		quota.NewResourcesBuilder().
 			WithMemoryLimit(quantity.SizeMiB).
+			WithMemoryHighLimit(quantity.SizeMiB).
+			WithMemorySwapLimit(4*quantity.SizeMiB).
 			WithCPUCount(1).
 			WithCPUPercentage(100).
 			WithThreadLimit(256).
 			WithCPUSet([]int{0, 1}).
 			WithJournalRate(150, time.Second).
 			WithJournalSize(quantity.SizeMiB).
+			WithIOReadBandwidth("/dev/sda", 10*quantity.SizeMiB).
+			WithIOWriteIOPS("/dev/sda", 100).
 			Build())
 	allGroups, err2 := servicestate.AllQuotas(st)
 	st.Unlock()
@@ -103,6 +107,8 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 
 	quotaValues := daemon.CreateQuotaValues(grp)
 	c.Check(quotaValues.Memory, check.DeepEquals, quantity.SizeMiB)
+	c.Check(quotaValues.MemoryHigh, check.DeepEquals, quantity.SizeMiB)
+	c.Check(quotaValues.MemorySwap, check.DeepEquals, 4*quantity.SizeMiB)
 	c.Check(quotaValues.Threads, check.DeepEquals, 256)
 	c.Check(quotaValues.CPU, check.DeepEquals, &client.QuotaCPUValues{
 		Count:      1,
@@ -118,6 +124,11 @@ func (s *apiQuotaSuite) TestCreateQuotaValues(c *check.C) {
 			RatePeriod: time.Second,
 		},
 	})
+	c.Check(quotaValues.IO, check.DeepEquals, &client.QuotaIOValues{
+		Devices: []client.QuotaIODeviceValues{
+			{Device: "/dev/sda", ReadBps: 10 * quantity.SizeMiB, WriteIOPS: 100},
+		},
+	})
 }
 
 func (s *apiQuotaSuite) TestPostQuotaUnknownAction(c *check.C) {
@@ -199,6 +210,75 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaCreateHappy(c *check.C) {
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateIOHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.ResourceLimits, check.DeepEquals, quota.NewResourcesBuilder().
+			WithIOReadBandwidth("/dev/mmcblk0", 10*quantity.SizeMiB).
+			WithIOWriteBandwidth("/dev/mmcblk0", 0).
+			WithIOReadIOPS("/dev/mmcblk0", 0).
+			WithIOWriteIOPS("/dev/mmcblk0", 50).
+			Build())
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Snaps:     []string{"some-snap"},
+		Constraints: client.QuotaValues{IO: &client.QuotaIOValues{
+			Devices: []client.QuotaIODeviceValues{
+				{Device: "/dev/mmcblk0", ReadBps: 10 * quantity.SizeMiB, WriteIOPS: 50},
+			},
+		}},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+}
+
+func (s *apiQuotaSuite) TestPostEnsureQuotaCreateMemoryHighAndSwapHappy(c *check.C) {
+	var createCalled int
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.ResourceLimits, check.DeepEquals, quota.NewResourcesBuilder().
+			WithMemoryLimit(quantity.SizeGiB).
+			WithMemoryHighLimit(512*quantity.SizeMiB).
+			WithMemorySwapLimit(256*quantity.SizeMiB).
+			Build())
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	data, err := json.Marshal(daemon.PostQuotaGroupData{
+		Action:    "ensure",
+		GroupName: "booze",
+		Snaps:     []string{"some-snap"},
+		Constraints: client.QuotaValues{
+			Memory:     quantity.SizeGiB,
+			MemoryHigh: 512 * quantity.SizeMiB,
+			MemorySwap: 256 * quantity.SizeMiB,
+		},
+	})
+	c.Assert(err, check.IsNil)
+
+	req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(data))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 202)
+	c.Assert(createCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaCreateQuotaConflicts(c *check.C) {
 	var createCalled int
 	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
@@ -488,6 +568,56 @@ func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateMemoryHappy(c *check.C) {
 	c.Assert(s.ensureSoonCalled, check.Equals, 1)
 }
 
+func (s *apiQuotaSuite) TestPostEnsureQuotaMemoryEventPolicy(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "ginger-ale", "", nil, nil, quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	createCalled := 0
+	r := daemon.MockServicestateCreateQuota(func(st *state.State, name string, createOpts servicestate.CreateQuotaOptions) (*state.TaskSet, error) {
+		createCalled++
+		c.Check(name, check.Equals, "booze")
+		c.Check(createOpts.MemoryEventPolicy, check.Equals, "restart")
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	updateCalled := 0
+	r = daemon.MockServicestateUpdateQuota(func(st *state.State, name string, opts servicestate.UpdateQuotaOptions) (*state.TaskSet, error) {
+		updateCalled++
+		c.Check(name, check.Equals, "ginger-ale")
+		c.Check(opts, check.DeepEquals, servicestate.UpdateQuotaOptions{
+			NewMemoryEventPolicy: "warn",
+		})
+		ts := state.NewTaskSet(st.NewTask("foo-quota", "..."))
+		return ts, nil
+	})
+	defer r()
+
+	for _, data := range []daemon.PostQuotaGroupData{{
+		Action:            "ensure",
+		GroupName:         "booze",
+		Constraints:       client.QuotaValues{Memory: quantity.SizeMiB},
+		MemoryEventPolicy: "restart",
+	}, {
+		Action:            "ensure",
+		GroupName:         "ginger-ale",
+		MemoryEventPolicy: "warn",
+	}} {
+		body, err := json.Marshal(data)
+		c.Assert(err, check.IsNil)
+		req, err := http.NewRequest("POST", "/v2/quotas", bytes.NewBuffer(body))
+		c.Assert(err, check.IsNil)
+		rsp := s.asyncReq(c, req, nil, actionIsExpected)
+		c.Assert(rsp.Status, check.Equals, 202)
+	}
+	c.Check(createCalled, check.Equals, 1)
+	c.Check(updateCalled, check.Equals, 1)
+}
+
 func (s *apiQuotaSuite) TestPostEnsureQuotaUpdateConflicts(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
@@ -846,6 +976,69 @@ func (s *apiQuotaSuite) TestGetQuota(c *check.C) {
 	c.Check(s.ensureSoonCalled, check.Equals, 0)
 }
 
+func (s *apiQuotaSuite) TestGetQuotaIOUsageUnavailable(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	err := servicestatetest.MockQuotaInState(st, "foo", "", nil, nil, quota.NewResourcesBuilder().WithIOWriteBandwidth("/dev/sda", quantity.SizeMiB).Build())
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	r := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		switch args[0] {
+		case "is-active":
+			return []byte("active"), nil
+		case "show":
+			// io accounting is not enabled
+			return []byte(args[2] + "=[not set]"), nil
+		}
+		c.Errorf("unexpected systemctl call %v", args)
+		return nil, fmt.Errorf("broken test")
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Assert(rsp.Result, check.FitsTypeOf, client.QuotaGroupResult{})
+	res := rsp.Result.(client.QuotaGroupResult)
+	c.Check(res.Current, check.DeepEquals, &client.QuotaValues{})
+}
+
+func (s *apiQuotaSuite) TestGetQuotaMemoryEvents(c *check.C) {
+	st := s.d.Overlord().State()
+	st.Lock()
+	mockQuotas(st, c)
+	var allGrps map[string]*quota.Group
+	c.Assert(st.Get("quotas", &allGrps), check.IsNil)
+	allGrps["foo"].MemoryEventPolicy = quota.MemoryEventPolicyWarn
+	st.Set("quotas", allGrps)
+	st.Set("quota-memory-events", map[string]any{
+		"foo": map[string]any{
+			"recorded": map[string]any{"high": 3, "oom-kill": 1, "last": "2026-10-17T10:00:00Z"},
+			"seen":     map[string]any{"high": 3, "oom-kill": 1},
+		},
+	})
+	st.Unlock()
+
+	r := daemon.MockGetQuotaUsage(func(grp *quota.Group) (*client.QuotaValues, error) {
+		return &client.QuotaValues{Memory: quantity.Size(500)}, nil
+	})
+	defer r()
+
+	req, err := http.NewRequest("GET", "/v2/quotas/foo", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	res := rsp.Result.(client.QuotaGroupResult)
+	c.Check(res.MemoryEventPolicy, check.Equals, "warn")
+	c.Check(res.MemoryEvents, check.DeepEquals, &client.QuotaMemoryEvents{
+		High:    3,
+		OOMKill: 1,
+		Last:    time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC),
+	})
+}
+
 func (s *apiQuotaSuite) TestGetQuotaInvalidName(c *check.C) {
 	st := s.d.Overlord().State()
 	st.Lock()
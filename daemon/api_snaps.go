@@ -26,15 +26,21 @@ import (
 	"fmt"
 	"mime"
 	"net/http"
+	"reflect"
+	"sort"
 	"strings"
 	"time"
 
 	"github.com/snapcore/snapd/asserts/snapasserts"
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/i18n"
+	"github.com/snapcore/snapd/interfaces"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/ifacestate"
+	"github.com/snapcore/snapd/overlord/restart"
 	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -220,6 +226,10 @@ func postSnap(c *Command, r *http.Request, user *auth.UserState) Response {
 		return inst.errToResponse(err)
 	}
 
+	if inst.DryRun {
+		return dryRunResponse(st, res)
+	}
+
 	changeKind, ok := changeKind(inst.Action)
 	if !ok {
 		return BadRequest("unknown action %s", inst.Action)
@@ -298,10 +308,12 @@ type snapInstruction struct {
 	Snaps                  []string                         `json:"snaps"`
 	Users                  []string                         `json:"users"`
 	SnapshotOptions        map[string]*snap.SnapshotOptions `json:"snapshot-options"`
+	Encrypt                bool                             `json:"encrypt"`
 	ValidationSets         []string                         `json:"validation-sets"`
 	QuotaGroupName         string                           `json:"quota-group"`
 	Time                   string                           `json:"time"`
 	HoldLevel              string                           `json:"hold-level"`
+	DryRun                 bool                             `json:"dry-run"`
 
 	// The fields below should not be unmarshalled into. Do not export them.
 	userID int
@@ -461,6 +473,15 @@ func (inst *snapInstruction) validate() error {
 		}
 	}
 
+	if inst.DryRun {
+		if inst.Action != refreshCmdAction {
+			return fmt.Errorf(`dry-run can only be specified for the "refresh" action`)
+		}
+		if len(inst.ValidationSets) > 0 {
+			return fmt.Errorf("dry-run cannot be specified with validation sets to enforce")
+		}
+	}
+
 	if inst.Unaliased && inst.Prefer {
 		return errUnaliasedPreferConflict
 	}
@@ -483,6 +504,10 @@ func (inst *snapInstruction) validate() error {
 		inst.cleanSnapshotOptions()
 	}
 
+	if inst.Encrypt && inst.Action != snapshotCmdAction {
+		return fmt.Errorf(`encrypt can only be specified for the "snapshot" action`)
+	}
+
 	if len(inst.CompsRaw) > 0 {
 		switch inst.Action {
 		case removeCmdAction, installCmdAction, refreshCmdAction:
@@ -500,6 +525,26 @@ type snapInstructionResult struct {
 	AffectedComponents map[string][]string
 	Tasksets           []*state.TaskSet
 	Result             map[string]any
+
+	// dryRunTargets holds the infos of the revisions that a dry-run
+	// would refresh the affected snaps to, keyed by instance name.
+	dryRunTargets map[string]*snap.Info
+}
+
+// dryRunPrereqTracker records the target infos of the snaps that a dry-run
+// would refresh.
+type dryRunPrereqTracker struct {
+	snap.SimplePrereqTracker
+	targets map[string]*snap.Info
+}
+
+func newDryRunPrereqTracker() *dryRunPrereqTracker {
+	return &dryRunPrereqTracker{targets: make(map[string]*snap.Info)}
+}
+
+// Add implements snapstate.PrereqTracker.
+func (t *dryRunPrereqTracker) Add(info *snap.Info) {
+	t.targets[info.InstanceName()] = info
 }
 
 var errDevJailModeConflict = errors.New("cannot use devmode and jailmode flags together")
@@ -625,9 +670,12 @@ func snapUpdate(ctx context.Context, inst *snapInstruction, st *state.State) (*s
 		flags.Amend = true
 	}
 
-	// we need refreshed snap-declarations to enforce refresh-control as best as we can
-	if err = assertstateRefreshSnapAssertions(st, inst.userID, nil); err != nil {
-		return nil, err
+	// we need refreshed snap-declarations to enforce refresh-control as best as we can,
+	// but a dry-run must leave the state untouched
+	if !inst.DryRun {
+		if err = assertstateRefreshSnapAssertions(st, inst.userID, nil); err != nil {
+			return nil, err
+		}
 	}
 
 	// TODO: once we completely move away from the old snapstate API, this
@@ -642,20 +690,32 @@ func snapUpdate(ctx context.Context, inst *snapInstruction, st *state.State) (*s
 		AdditionalComponents: inst.CompsForSnaps[inst.Snaps[0]],
 	})
 
-	ts, err := snapstateUpdateOne(ctx, st, goal, nil, snapstate.Options{
+	opts := snapstate.Options{
 		Flags:  flags,
 		UserID: inst.userID,
-	})
+	}
+	var tracker *dryRunPrereqTracker
+	if inst.DryRun {
+		tracker = newDryRunPrereqTracker()
+		opts.DryRun = true
+		opts.PrereqTracker = tracker
+	}
+
+	ts, err := snapstateUpdateOne(ctx, st, goal, nil, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return &snapInstructionResult{
+	res := &snapInstructionResult{
 		Summary:            installRefreshMessage(inst.Snaps[0], inst),
 		Tasksets:           []*state.TaskSet{ts},
 		Affected:           inst.Snaps,
 		AffectedComponents: inst.CompsForSnaps,
-	}, nil
+	}
+	if tracker != nil {
+		res.dryRunTargets = tracker.targets
+	}
+	return res, nil
 }
 
 func snapRemove(_ context.Context, inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
@@ -891,6 +951,10 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 		return inst.errToResponse(err)
 	}
 
+	if inst.DryRun {
+		return dryRunResponse(st, res)
+	}
+
 	changeKind, ok := changeKind(inst.Action)
 	if !ok {
 		return BadRequest("unknown action %s", inst.Action)
@@ -920,6 +984,195 @@ func snapOpMany(c *Command, r *http.Request, user *auth.UserState) Response {
 	return AsyncResponse(res.Result, chg.ID())
 }
 
+// refreshPlan describes what a refresh would do, as reported by a dry-run.
+type refreshPlan struct {
+	Summary      string      `json:"summary"`
+	SnapNames    []string    `json:"snap-names,omitempty"`
+	Tasks        []*taskInfo `json:"tasks"`
+	DownloadSize int64       `json:"download-size"`
+	// RebootRequired lists the snaps whose refresh requires a reboot.
+	RebootRequired []string `json:"reboot-required,omitempty"`
+	// Reconnections lists the connections whose plug or slot changes
+	// interface or attributes with the refresh, and are thus
+	// re-established with the new definition.
+	Reconnections []string `json:"reconnections,omitempty"`
+	// Hooks lists the hooks run during the refresh, as <snap>:<hook>.
+	Hooks []string `json:"hooks,omitempty"`
+}
+
+// dryRunResponse reports what the task sets of the result would do and then
+// discards their tasks, without ever registering them with a change.
+func dryRunResponse(st *state.State, res *snapInstructionResult) Response {
+	var tasks []*state.Task
+	seen := make(map[string]bool)
+	for _, ts := range res.Tasksets {
+		for _, t := range ts.Tasks() {
+			if seen[t.ID()] {
+				continue
+			}
+			seen[t.ID()] = true
+			tasks = append(tasks, t)
+		}
+	}
+	defer st.DiscardTasks(tasks)
+
+	plan, err := makeRefreshPlan(st, res.Summary, res.Affected, res.dryRunTargets, tasks)
+	if err != nil {
+		return InternalError("cannot report refresh plan: %v", err)
+	}
+	return SyncResponse(plan)
+}
+
+func makeRefreshPlan(st *state.State, summary string, affected []string, targets map[string]*snap.Info, tasks []*state.Task) (*refreshPlan, error) {
+	plan := &refreshPlan{
+		Summary:   summary,
+		SnapNames: affected,
+		Tasks:     make([]*taskInfo, 0, len(tasks)),
+	}
+
+	byID := make(map[string]*state.Task, len(tasks))
+	for _, t := range tasks {
+		byID[t.ID()] = t
+	}
+
+	for _, t := range tasks {
+		plan.Tasks = append(plan.Tasks, task2taskInfo(t))
+
+		switch t.Kind() {
+		case "download-snap":
+			var snapsup snapstate.SnapSetup
+			if err := getPlanTaskSetup(byID, t, "snap-setup", &snapsup); err != nil {
+				return nil, err
+			}
+			if snapsup.DownloadInfo != nil {
+				plan.DownloadSize += snapsup.DownloadInfo.Size
+			}
+		case "download-component":
+			var compsup snapstate.ComponentSetup
+			if err := getPlanTaskSetup(byID, t, "component-setup", &compsup); err != nil {
+				return nil, err
+			}
+			if compsup.DownloadInfo != nil {
+				plan.DownloadSize += compsup.DownloadInfo.Size
+			}
+		case "run-hook":
+			var hooksup hookstate.HookSetup
+			if err := t.Get("hook-setup", &hooksup); err != nil {
+				return nil, err
+			}
+			plan.Hooks = append(plan.Hooks, fmt.Sprintf("%s:%s", hooksup.Snap, hooksup.Hook))
+		}
+
+		if restart.TaskIsRestartBoundary(t, restart.RestartBoundaryDirectionDo) {
+			var snapsup snapstate.SnapSetup
+			if err := getPlanTaskSetup(byID, t, "snap-setup", &snapsup); err != nil {
+				return nil, err
+			}
+			if !strutil.ListContains(plan.RebootRequired, snapsup.InstanceName()) {
+				plan.RebootRequired = append(plan.RebootRequired, snapsup.InstanceName())
+			}
+		}
+	}
+
+	conns, err := ifacestate.ConnectionStates(st)
+	if err != nil {
+		return nil, err
+	}
+	for id, cstate := range conns {
+		if !cstate.Active() {
+			continue
+		}
+		connRef, err := interfaces.ParseConnRef(id)
+		if err != nil {
+			return nil, err
+		}
+		changed, err := connChangedByRefresh(st, connRef, targets)
+		if err != nil {
+			return nil, err
+		}
+		if changed {
+			plan.Reconnections = append(plan.Reconnections, id)
+		}
+	}
+	sort.Strings(plan.Reconnections)
+
+	return plan, nil
+}
+
+// connChangedByRefresh returns whether refreshing the snaps to the given
+// targets changes the interface or the attributes of the plug or the slot of
+// the connection.
+func connChangedByRefresh(st *state.State, connRef *interfaces.ConnRef, targets map[string]*snap.Info) (bool, error) {
+	if target := targets[connRef.PlugRef.Snap]; target != nil {
+		current, err := currentSnapInfo(st, connRef.PlugRef.Snap)
+		if err != nil {
+			return false, err
+		}
+		var cur *snap.PlugInfo
+		if current != nil {
+			cur = current.Plugs[connRef.PlugRef.Name]
+		}
+		tgt := target.Plugs[connRef.PlugRef.Name]
+		if (cur == nil) != (tgt == nil) {
+			return true, nil
+		}
+		if cur != nil && (cur.Interface != tgt.Interface || !reflect.DeepEqual(cur.Attrs, tgt.Attrs)) {
+			return true, nil
+		}
+	}
+	if target := targets[connRef.SlotRef.Snap]; target != nil {
+		current, err := currentSnapInfo(st, connRef.SlotRef.Snap)
+		if err != nil {
+			return false, err
+		}
+		var cur *snap.SlotInfo
+		if current != nil {
+			cur = current.Slots[connRef.SlotRef.Name]
+		}
+		tgt := target.Slots[connRef.SlotRef.Name]
+		// implicit slots are in neither info and thus left unchanged
+		if (cur == nil) != (tgt == nil) {
+			return true, nil
+		}
+		if cur != nil && (cur.Interface != tgt.Interface || !reflect.DeepEqual(cur.Attrs, tgt.Attrs)) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// currentSnapInfo returns the info of the current revision of the snap, or
+// nil if the snap is not installed.
+func currentSnapInfo(st *state.State, instanceName string) (*snap.Info, error) {
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, instanceName, &snapst); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return snapst.CurrentInfo()
+}
+
+// getPlanTaskSetup gets the setup under key from the task or from the task it
+// refers to, as snapstate does for setups shared by the tasks of a snap. The
+// tasks of a plan are not registered with a change, so unlike with
+// snapstate.TaskSnapSetup the referred task cannot be looked up in the state.
+func getPlanTaskSetup(byID map[string]*state.Task, t *state.Task, key string, setup any) error {
+	if t.Has(key) {
+		return t.Get(key, setup)
+	}
+	var id string
+	if err := t.Get(key+"-task", &id); err != nil {
+		return err
+	}
+	setupTask := byID[id]
+	if setupTask == nil {
+		return fmt.Errorf("internal error: cannot find %s task %s", key, id)
+	}
+	return setupTask.Get(key, setup)
+}
+
 type snapManyActionFunc func(context.Context, *snapInstruction, *state.State) (*snapInstructionResult, error)
 
 func (inst *snapInstruction) dispatchForMany() (op snapManyActionFunc) {
@@ -1051,12 +1304,15 @@ func snapUpdateMany(ctx context.Context, inst *snapInstruction, st *state.State)
 	// we need refreshed snap-declarations to enforce refresh-control as best as
 	// we can, this also ensures that snap-declarations and their prerequisite
 	// assertions are updated regularly; update validation sets assertions only
-	// if refreshing all snaps (no snap names explicitly requested).
+	// if refreshing all snaps (no snap names explicitly requested). A dry-run
+	// must leave the state untouched though.
 	opts := &assertstate.RefreshAssertionsOptions{
 		IsRefreshOfAllSnaps: len(inst.Snaps) == 0,
 	}
-	if err := assertstateRefreshSnapAssertions(st, inst.userID, opts); err != nil {
-		return nil, err
+	if !inst.DryRun {
+		if err := assertstateRefreshSnapAssertions(st, inst.userID, opts); err != nil {
+			return nil, err
+		}
 	}
 
 	updates := make([]snapstate.StoreUpdate, 0, len(inst.Snaps))
@@ -1078,12 +1334,20 @@ func snapUpdateMany(ctx context.Context, inst *snapInstruction, st *state.State)
 		flags.Transaction = client.TransactionPerSnap
 	}
 
-	goal := snapstateStoreUpdateGoal(updates...)
-	updated, uts, err := snapstateUpdateWithGoal(ctx, st, goal, nil, snapstate.Options{
+	updateOpts := snapstate.Options{
 		Flags: flags,
-	})
+	}
+	var tracker *dryRunPrereqTracker
+	if inst.DryRun {
+		tracker = newDryRunPrereqTracker()
+		updateOpts.DryRun = true
+		updateOpts.PrereqTracker = tracker
+	}
+
+	goal := snapstateStoreUpdateGoal(updates...)
+	updated, uts, err := snapstateUpdateWithGoal(ctx, st, goal, nil, updateOpts)
 	if err != nil {
-		if opts.IsRefreshOfAllSnaps {
+		if opts.IsRefreshOfAllSnaps && !inst.DryRun {
 			if err := assertstateRestoreValidationSetsTracking(st); err != nil && !errors.Is(err, state.ErrNoState) {
 				return nil, err
 			}
@@ -1107,11 +1371,15 @@ func snapUpdateMany(ctx context.Context, inst *snapInstruction, st *state.State)
 		msg = multiInstallRefreshMessage(updated, inst)
 	}
 
-	return &snapInstructionResult{
+	res := &snapInstructionResult{
 		Summary:  msg,
 		Affected: updated,
 		Tasksets: tasksets,
-	}, nil
+	}
+	if tracker != nil {
+		res.dryRunTargets = tracker.targets
+	}
+	return res, nil
 }
 
 func snapEnforceValidationSets(ctx context.Context, inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
@@ -1285,6 +1553,8 @@ func getSnapsInfo(c *Command, r *http.Request, user *auth.UserState) Response {
 		sel = snapSelectEnabled
 	case "refresh-inhibited":
 		sel = snapSelectRefreshInhibited
+	case "health":
+		sel = snapSelectHealth
 	default:
 		return BadRequest("invalid select parameter: %q", sel)
 	}
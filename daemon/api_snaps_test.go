@@ -45,6 +45,9 @@ import (
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/sequence"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
@@ -221,6 +224,42 @@ func (s *snapsSuite) TestSnapsInfoOnlyLocal(c *check.C) {
 	})
 }
 
+func (s *snapsSuite) TestSnapsInfoSelectHealth(c *check.C) {
+	s.expectSnapsReadAccess()
+	d := s.daemon(c)
+
+	s.mkInstalledInState(c, d, "healthy", "foo", "v1", snap.R(10), true, "")
+	s.mkInstalledInState(c, d, "unchecked", "foo", "v1", snap.R(10), true, "")
+	recheckTime := time.Date(2026, 10, 1, 12, 5, 0, 0, time.UTC)
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("health", map[string]healthstate.HealthState{
+		"healthy": {
+			Revision:    snap.R(10),
+			Timestamp:   time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC),
+			Status:      healthstate.WaitingStatus,
+			Message:     "waiting for the network",
+			RecheckTime: recheckTime,
+		},
+	})
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", "/v2/snaps?select=health", nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+
+	snaps := snapList(rsp.Result)
+	c.Assert(snaps, check.HasLen, 1)
+	c.Check(snaps[0]["name"], check.Equals, "healthy")
+	c.Check(snaps[0]["health"], check.DeepEquals, map[string]any{
+		"status":       "waiting",
+		"revision":     "10",
+		"timestamp":    "2026-10-01T12:00:00Z",
+		"message":      "waiting for the network",
+		"recheck-time": "2026-10-01T12:05:00Z",
+	})
+}
+
 func (s *snapsSuite) TestSnapsInfoAllMixedPublishers(c *check.C) {
 	s.expectSnapsReadAccess()
 	d := s.daemon(c)
@@ -632,7 +671,7 @@ func (s *snapsSuite) TestPostSnapsRemoveManyWithPurge(c *check.C) {
 func (s *snapsSuite) TestPostSnapsOptionsClean(c *check.C) {
 	var snapshotSaveCalled int
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		snapshotSaveCalled++
 
 		c.Check(snaps, check.HasLen, 3)
@@ -708,6 +747,165 @@ func (s *snapsSuite) testPostSnapsOp(c *check.C, extraJSON, contentType string)
 	return systemRestartImmediate
 }
 
+func (s *snapsSuite) TestPostSnapsOpRefreshDryRun(c *check.C) {
+	// the content slot changes attributes with the refresh, the network
+	// plug stays the same
+	target := snaptest.MockInfo(c, `name: fake1
+version: 2
+plugs:
+  network:
+slots:
+  content:
+    content: new-content
+`, &snap.SideInfo{RealName: "fake1", Revision: snap.R(2)})
+
+	defer daemon.MockAssertstateRefreshSnapAssertions(func(*state.State, int, *assertstate.RefreshAssertionsOptions) error {
+		c.Error("assertions must not be refreshed by a dry-run")
+		return nil
+	})()
+	defer daemon.MockSnapstateUpdateWithGoal(func(_ context.Context, st *state.State, g snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		c.Check(opts.DryRun, check.Equals, true)
+		c.Assert(opts.PrereqTracker, check.NotNil)
+		opts.PrereqTracker.Add(target)
+
+		dl := st.NewTask("download-snap", "Download snap \"fake1\"")
+		dl.Set("snap-setup", &snapstate.SnapSetup{
+			SideInfo:     &snap.SideInfo{RealName: "fake1", Revision: snap.R(2)},
+			DownloadInfo: &snap.DownloadInfo{Size: 1024},
+		})
+		hook := st.NewTask("run-hook", "Run post-refresh hook of \"fake1\" snap")
+		hook.Set("hook-setup", &hookstate.HookSetup{Snap: "fake1", Hook: "post-refresh"})
+		hook.WaitFor(dl)
+		link := st.NewTask("link-snap", "Make snap \"fake1\" available")
+		link.Set("snap-setup-task", dl.ID())
+		restart.MarkTaskAsRestartBoundary(link, restart.RestartBoundaryDirectionDo)
+		link.WaitFor(hook)
+		return []string{"fake1"}, &snapstate.UpdateTaskSets{Refresh: []*state.TaskSet{state.NewTaskSet(dl, hook, link)}}, nil
+	})()
+
+	d := s.daemonWithOverlordMockAndStore()
+	s.mkInstalledInState(c, d, "fake1", "", "v1", snap.R(1), true, `
+plugs:
+  network:
+  camera:
+slots:
+  content:
+    content: old-content
+`)
+	st := d.Overlord().State()
+	st.Lock()
+	st.Set("conns", map[string]any{
+		"fake1:network core:network":  map[string]any{"interface": "network", "auto": true},
+		"other:home core:home":        map[string]any{"interface": "home", "auto": true},
+		"fake1:camera core:camera":    map[string]any{"interface": "camera", "undesired": true},
+		"fake2:content fake1:content": map[string]any{"interface": "content"},
+	})
+	before := stateData(c, st)
+	st.Unlock()
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	plan, ok := rsp.Result.(*daemon.RefreshPlan)
+	c.Assert(ok, check.Equals, true)
+	c.Check(plan.Summary, check.Equals, `Refresh "fake1" snap`)
+	c.Check(plan.SnapNames, check.DeepEquals, []string{"fake1"})
+	c.Assert(plan.Tasks, check.HasLen, 3)
+	c.Check(plan.Tasks[0].Kind, check.Equals, "download-snap")
+	c.Check(plan.Tasks[0].Status, check.Equals, "Do")
+	c.Check(plan.Tasks[1].Kind, check.Equals, "run-hook")
+	c.Check(plan.Tasks[2].Kind, check.Equals, "link-snap")
+	c.Check(plan.DownloadSize, check.Equals, int64(1024))
+	c.Check(plan.RebootRequired, check.DeepEquals, []string{"fake1"})
+	c.Check(plan.Reconnections, check.DeepEquals, []string{
+		"fake2:content fake1:content",
+	})
+	c.Check(plan.Hooks, check.DeepEquals, []string{"fake1:post-refresh"})
+
+	// nothing is left behind in the state
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.AllTasksForTests(), check.HasLen, 0)
+	c.Check(stateData(c, st), check.DeepEquals, before)
+}
+
+// stateData returns the data of the state, as persisted.
+func stateData(c *check.C, st *state.State) map[string]any {
+	data, err := json.Marshal(st)
+	c.Assert(err, check.IsNil)
+	var m struct {
+		Data map[string]any `json:"data"`
+	}
+	c.Assert(json.Unmarshal(data, &m), check.IsNil)
+	return m.Data
+}
+
+func (s *snapsSuite) TestPostSnapRefreshDryRun(c *check.C) {
+	defer daemon.MockAssertstateRefreshSnapAssertions(func(*state.State, int, *assertstate.RefreshAssertionsOptions) error {
+		c.Error("assertions must not be refreshed by a dry-run")
+		return nil
+	})()
+	defer daemon.MockSnapstateUpdateOne(func(ctx context.Context, st *state.State, g snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) (*state.TaskSet, error) {
+		c.Check(opts.DryRun, check.Equals, true)
+		t := st.NewTask("fake-refresh-snap", "Doing a fake refresh")
+		return state.NewTaskSet(t), nil
+	})()
+
+	d := s.daemonWithOverlordMockAndStore()
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps/some-snap", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	plan, ok := rsp.Result.(*daemon.RefreshPlan)
+	c.Assert(ok, check.Equals, true)
+	c.Check(plan.Summary, check.Equals, `Refresh "some-snap" snap`)
+	c.Check(plan.SnapNames, check.DeepEquals, []string{"some-snap"})
+	c.Assert(plan.Tasks, check.HasLen, 1)
+	c.Check(plan.Tasks[0].Summary, check.Equals, "Doing a fake refresh")
+	c.Check(plan.DownloadSize, check.Equals, int64(0))
+	c.Check(plan.RebootRequired, check.HasLen, 0)
+
+	st := d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	c.Check(st.AllTasksForTests(), check.HasLen, 0)
+}
+
+func (s *snapsSuite) TestDryRunOnlyForRefresh(c *check.C) {
+	s.daemon(c)
+
+	for _, body := range []string{
+		`{"action": "install", "snaps": ["foo"], "dry-run": true}`,
+		`{"action": "remove", "snaps": ["foo"], "dry-run": true}`,
+	} {
+		buf := bytes.NewBufferString(body)
+		req, err := http.NewRequest("POST", "/v2/snaps", buf)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, check.Equals, 400)
+		c.Check(rspe.Message, check.Equals, `dry-run can only be specified for the "refresh" action`)
+	}
+
+	buf := bytes.NewBufferString(`{"action": "refresh", "validation-sets": ["foo/bar"], "dry-run": true}`)
+	req, err := http.NewRequest("POST", "/v2/snaps", buf)
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", "application/json")
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "dry-run cannot be specified with validation sets to enforce")
+}
+
 func (s *snapsSuite) TestPostSnapsOpInvalidCharset(c *check.C) {
 	s.daemon(c)
 
@@ -2185,6 +2383,22 @@ func (s *snapsSuite) TestPostSnapTerminateWrongAction(c *check.C) {
 	}
 }
 
+func (s *snapsSuite) TestPostSnapsEncryptWrongAction(c *check.C) {
+	s.daemonWithOverlordMock()
+	const expectedErr = `encrypt can only be specified for the "snapshot" action`
+
+	for _, action := range []string{"install", "refresh", "remove"} {
+		buf := strings.NewReader(fmt.Sprintf(`{"action": "%s", "snaps": ["some-snap"], "encrypt": true}`, action))
+		req, err := http.NewRequest("POST", "/v2/snaps", buf)
+		c.Assert(err, check.IsNil)
+		req.Header.Set("Content-Type", "application/json")
+
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, check.Equals, 400, check.Commentf("%q", action))
+		c.Check(rspe.Message, check.Equals, expectedErr, check.Commentf("%q", action))
+	}
+}
+
 func (s *snapsSuite) TestPostSnapTerminateWithRevisionSet(c *check.C) {
 	s.daemonWithOverlordMock()
 
@@ -21,6 +21,7 @@ package daemon
 
 import (
 	"context"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -32,6 +33,7 @@ import (
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/swfeats"
 	"github.com/snapcore/snapd/strutil"
@@ -42,7 +44,7 @@ var snapshotCmd = &Command{
 	Path:        "/v2/snapshots",
 	GET:         listSnapshots,
 	POST:        changeSnapshots,
-	Actions:     []string{"check", "restore", "forget"},
+	Actions:     []string{"check", "restore", "forget", "push", "pull"},
 	ReadAccess:  openAccess{},
 	WriteAccess: authenticatedAccess{Polkit: polkitActionManage},
 }
@@ -61,12 +63,16 @@ var (
 	snapshotSave    = snapshotstate.Save
 	snapshotExport  = snapshotstate.Export
 	snapshotImport  = snapshotstate.Import
+	snapshotPush    = snapshotstate.Push
+	snapshotPull    = snapshotstate.Pull
 )
 
 var (
 	checkSnapshotChangeKind   = swfeats.RegisterChangeKind("check-snapshot")
 	restoreSnapshotChangeKind = swfeats.RegisterChangeKind("restore-snapshot")
 	forgetSnapshotChangeKind  = swfeats.RegisterChangeKind("forget-snapshot")
+	pushSnapshotChangeKind    = swfeats.RegisterChangeKind("push-snapshot")
+	pullSnapshotChangeKind    = swfeats.RegisterChangeKind("pull-snapshot")
 )
 
 func listSnapshots(c *Command, r *http.Request, user *auth.UserState) Response {
@@ -97,9 +103,15 @@ type snapshotAction struct {
 	Action string   `json:"action"`
 	Snaps  []string `json:"snaps,omitempty"`
 	Users  []string `json:"users,omitempty"`
+
+	Target     *client.SnapshotTarget `json:"target,omitempty"`
+	Passphrase string                 `json:"passphrase,omitempty"`
 }
 
 func (action snapshotAction) String() string {
+	if action.Action == "pull" {
+		return fmt.Sprintf("Pull of snapshot set from %s", action.Target.URL)
+	}
 	// verb of snapshot #N [for snaps %q] [for users %q]
 	var snaps string
 	var users string
@@ -127,7 +139,12 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		return BadRequest("extra content found after snapshot operation")
 	}
 
-	if action.SetID == 0 {
+	// pull creates a new snapshot set
+	if action.Action == "pull" {
+		if action.SetID != 0 {
+			return BadRequest(`snapshot "pull" operation cannot specify snapshot set ID`)
+		}
+	} else if action.SetID == 0 {
 		return BadRequest("snapshot operation requires snapshot set ID")
 	}
 
@@ -135,6 +152,22 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		return BadRequest("snapshot operation requires action")
 	}
 
+	var spec *target.Spec
+	switch action.Action {
+	case "push", "pull":
+		if action.Target == nil || action.Target.URL == "" {
+			return BadRequest("snapshot %q operation requires a target", action.Action)
+		}
+		if len(action.Snaps) != 0 || len(action.Users) != 0 {
+			return BadRequest("snapshot %q operation cannot specify snaps or users", action.Action)
+		}
+		spec = &target.Spec{URL: action.Target.URL, Credentials: action.Target.Credentials}
+	default:
+		if action.Target != nil || action.Passphrase != "" {
+			return BadRequest("snapshot %q operation cannot specify a target or passphrase", action.Action)
+		}
+	}
+
 	var affected []string
 	var ts *state.TaskSet
 	var err error
@@ -157,6 +190,18 @@ func changeSnapshots(c *Command, r *http.Request, user *auth.UserState) Response
 		}
 		affected, ts, err = snapshotForget(st, action.SetID, action.Snaps)
 		changeKind = forgetSnapshotChangeKind
+	case "push":
+		affected, ts, err = snapshotPush(st, action.SetID, spec, action.Passphrase)
+		if err != nil && err != client.ErrSnapshotSetNotFound {
+			return BadRequest("cannot push snapshot set #%d: %v", action.SetID, err)
+		}
+		changeKind = pushSnapshotChangeKind
+	case "pull":
+		ts, err = snapshotPull(st, spec, action.Passphrase)
+		if err != nil {
+			return BadRequest("cannot pull snapshot set: %v", err)
+		}
+		changeKind = pullSnapshotChangeKind
 	default:
 		return BadRequest("unknown snapshot operation %q", action.Action)
 	}
@@ -193,7 +238,12 @@ func getSnapshotExport(c *Command, r *http.Request, user *auth.UserState) Respon
 		return BadRequest("'id' must be a positive base 10 number; got %q", sid)
 	}
 
-	export, err := snapshotExport(r.Context(), st, setID)
+	passphrase, err := snapshotPassphrase(r)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
+	export, err := snapshotExport(r.Context(), st, setID, passphrase)
 	if err != nil {
 		return BadRequest("cannot export %v: %v", setID, err)
 	}
@@ -218,9 +268,14 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 	// ensure we don't read more than we expect
 	limitedBodyReader := io.LimitReader(r.Body, expectedSize)
 
+	passphrase, err := snapshotPassphrase(r)
+	if err != nil {
+		return BadRequest("%v", err)
+	}
+
 	// XXX: check that we have enough space to import the compressed snapshots
 	st := c.d.overlord.State()
-	setID, snapNames, err := snapshotImport(r.Context(), st, limitedBodyReader)
+	setID, snapNames, err := snapshotImport(r.Context(), st, limitedBodyReader, passphrase)
 	if err != nil {
 		return BadRequest(err.Error())
 	}
@@ -229,8 +284,23 @@ func doSnapshotImport(c *Command, r *http.Request, user *auth.UserState) Respons
 	return SyncResponse(result)
 }
 
+// snapshotPassphrase returns the passphrase for encrypting or decrypting
+// a snapshot export passed with the request, if any.
+func snapshotPassphrase(r *http.Request) (string, error) {
+	encoded := r.Header.Get(client.SnapshotPassphraseHeader)
+	if encoded == "" {
+		return "", nil
+	}
+	passphrase, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", fmt.Errorf("cannot decode snapshot passphrase: %v", err)
+	}
+	return string(passphrase), nil
+}
+
 func snapshotMany(_ context.Context, inst *snapInstruction, st *state.State) (*snapInstructionResult, error) {
-	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions)
+	flags := &snapshotstate.SaveFlags{Encrypt: inst.Encrypt}
+	setID, snapshotted, ts, err := snapshotSave(st, inst.Snaps, inst.Users, inst.SnapshotOptions, flags)
 	if err != nil {
 		return nil, err
 	}
@@ -34,6 +34,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/daemon"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 )
@@ -53,7 +54,7 @@ func (s *snapshotSuite) SetUpTest(c *check.C) {
 
 func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.IsNil)
 		t := s.NewTask("fake-snapshot-2", "Snapshot two")
@@ -74,7 +75,7 @@ func (s *snapshotSuite) TestSnapshotManyOptionsNone(c *check.C) {
 func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	var snapshotSaveCalled int
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		snapshotSaveCalled++
 		c.Check(snaps, check.HasLen, 2)
 		c.Check(options, check.HasLen, 2)
@@ -99,9 +100,29 @@ func (s *snapshotSuite) TestSnapshotManyOptionsFull(c *check.C) {
 	c.Check(snapshotSaveCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestSnapshotManyEncrypt(c *check.C) {
+	var snapshotSaveCalled int
+	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
+		snapshotSaveCalled++
+		c.Check(flags, check.DeepEquals, &snapshotstate.SaveFlags{Encrypt: true})
+		t := s.NewTask("fake-snapshot-2", "Snapshot two")
+		return 1, snaps, state.NewTaskSet(t), nil
+	})()
+
+	inst := daemon.MustUnmarshalSnapInstruction(c, `{"action": "snapshot", "snaps": ["foo"], "encrypt": true}`)
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	_, err := inst.DispatchForMany()(context.Background(), inst, st)
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+	c.Check(snapshotSaveCalled, check.Equals, 1)
+}
+
 func (s *snapshotSuite) TestSnapshotManyError(c *check.C) {
 	defer daemon.MockSnapshotSave(func(s *state.State, snaps, users []string,
-		options map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error) {
+		options map[string]*snap.SnapshotOptions, flags *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error) {
 		c.Check(snaps, check.HasLen, 2)
 		return 0, nil, nil, &snap.NotInstalledError{Snap: "foo"}
 	})()
@@ -236,6 +257,24 @@ func (s *snapshotSuite) TestChangeSnapshots400(c *check.C) {
 		}, {
 			body:  `{"set": 42, "action": "forget", "users": ["foo"]}`,
 			error: `snapshot "forget" operation cannot specify users`,
+		}, {
+			body:  `{"set": 42, "action": "check", "passphrase": "foo"}`,
+			error: `snapshot "check" operation cannot specify a target or passphrase`,
+		}, {
+			body:  `{"set": 42, "action": "push"}`,
+			error: `snapshot "push" operation requires a target`,
+		}, {
+			body:  `{"set": 42, "action": "push", "target": {"url": "s3://h/b/k"}, "snaps": ["foo"]}`,
+			error: `snapshot "push" operation cannot specify snaps or users`,
+		}, {
+			body:  `{"action": "push", "target": {"url": "s3://h/b/k"}}`,
+			error: "snapshot operation requires snapshot set ID",
+		}, {
+			body:  `{"set": 42, "action": "pull", "target": {"url": "s3://h/b/k"}}`,
+			error: `snapshot "pull" operation cannot specify snapshot set ID`,
+		}, {
+			body:  `{"action": "pull", "target": {"url": ""}}`,
+			error: `snapshot "pull" operation requires a target`,
 		},
 	}
 
@@ -355,10 +394,107 @@ func (s *snapshotSuite) TestChangeSnapshot(c *check.C) {
 	}
 }
 
+func (s *snapshotSuite) TestChangeSnapshotPush(c *check.C) {
+	var gotSpec *target.Spec
+	var gotPassphrase string
+	defer daemon.MockSnapshotPush(func(st *state.State, setID uint64, spec *target.Spec, passphrase string) ([]string, *state.TaskSet, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		gotSpec = spec
+		gotPassphrase = passphrase
+		return []string{"foo"}, state.NewTaskSet(), nil
+	})()
+
+	body := `{"set": 42, "action": "push", "target": {"url": "s3://minio:9000/bucket/key", "credentials": {"access-key": "a", "secret-key": "s"}}, "passphrase": "sekrit"}`
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 202)
+	c.Check(gotSpec, check.DeepEquals, &target.Spec{
+		URL:         "s3://minio:9000/bucket/key",
+		Credentials: map[string]string{"access-key": "a", "secret-key": "s"},
+	})
+	c.Check(gotPassphrase, check.Equals, "sekrit")
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "push-snapshot")
+	c.Check(chg.Summary(), check.Equals, "Push of snapshot set #42")
+	var apiData map[string]any
+	c.Assert(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]any{"snap-names": []any{"foo"}})
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPushErrors(c *check.C) {
+	var pushErr error
+	defer daemon.MockSnapshotPush(func(*state.State, uint64, *target.Spec, string) ([]string, *state.TaskSet, error) {
+		return nil, nil, pushErr
+	})()
+
+	body := `{"set": 42, "action": "push", "target": {"url": "s3://minio:9000/bucket/key"}}`
+	for _, t := range []struct {
+		err     error
+		status  int
+		message string
+	}{
+		{client.ErrSnapshotSetNotFound, 404, client.ErrSnapshotSetNotFound.Error()},
+		{errors.New("bad target"), 400, "cannot push snapshot set #42: bad target"},
+	} {
+		pushErr = t.err
+		req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+		c.Assert(err, check.IsNil)
+		rspe := s.errorReq(c, req, nil, actionIsExpected)
+		c.Check(rspe.Status, check.Equals, t.status)
+		c.Check(rspe.Message, check.Equals, t.message)
+	}
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPull(c *check.C) {
+	var gotSpec *target.Spec
+	defer daemon.MockSnapshotPull(func(st *state.State, spec *target.Spec, passphrase string) (*state.TaskSet, error) {
+		gotSpec = spec
+		c.Check(passphrase, check.Equals, "")
+		return state.NewTaskSet(), nil
+	})()
+
+	body := `{"action": "pull", "target": {"url": "https://dav.example.com/snapshot.tar", "credentials": {"token": "t"}}}`
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rsp := s.asyncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 202)
+	c.Check(gotSpec, check.DeepEquals, &target.Spec{
+		URL:         "https://dav.example.com/snapshot.tar",
+		Credentials: map[string]string{"token": "t"},
+	})
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	defer st.Unlock()
+	chg := st.Change(rsp.Change)
+	c.Assert(chg, check.NotNil)
+	c.Check(chg.Kind(), check.Equals, "pull-snapshot")
+	c.Check(chg.Summary(), check.Equals, "Pull of snapshot set from https://dav.example.com/snapshot.tar")
+}
+
+func (s *snapshotSuite) TestChangeSnapshotPullError(c *check.C) {
+	defer daemon.MockSnapshotPull(func(*state.State, *target.Spec, string) (*state.TaskSet, error) {
+		return nil, errors.New("bad target")
+	})()
+
+	body := `{"action": "pull", "target": {"url": "ftp://host/snapshot.tar"}}`
+	req, err := http.NewRequest("POST", "/v2/snapshots", strings.NewReader(body))
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "cannot pull snapshot set: bad target")
+}
+
 func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	var snapshotExportCalled int
 
-	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, passphrase string) (*snapshotstate.SnapshotExport, error) {
 		snapshotExportCalled++
 		c.Check(setID, check.Equals, uint64(1))
 		return &snapshotstate.SnapshotExport{}, nil
@@ -372,6 +508,34 @@ func (s *snapshotSuite) TestExportSnapshots(c *check.C) {
 	c.Check(snapshotExportCalled, check.Equals, 1)
 }
 
+func (s *snapshotSuite) TestExportSnapshotsPassphrase(c *check.C) {
+	var snapshotExportCalled int
+
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, passphrase string) (*snapshotstate.SnapshotExport, error) {
+		snapshotExportCalled++
+		c.Check(passphrase, check.Equals, "sekrit passphrase")
+		return &snapshotstate.SnapshotExport{}, nil
+	})()
+
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set(client.SnapshotPassphraseHeader, "c2Vrcml0IHBhc3NwaHJhc2U=")
+
+	rsp := s.req(c, req, nil, actionIsExpected)
+	c.Check(rsp, check.FitsTypeOf, &daemon.SnapshotExportResponse{})
+	c.Check(snapshotExportCalled, check.Equals, 1)
+}
+
+func (s *snapshotSuite) TestExportSnapshotsBadPassphraseEncoding(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/snapshots/1/export", nil)
+	c.Assert(err, check.IsNil)
+	req.Header.Set(client.SnapshotPassphraseHeader, "not base64!")
+
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Check(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Matches, `cannot decode snapshot passphrase: .*`)
+}
+
 func (s *snapshotSuite) TestExportSnapshotsBadRequestOnNonNumericID(c *check.C) {
 	req, err := http.NewRequest("GET", "/v2/snapshots/xxx/export", nil)
 	c.Assert(err, check.IsNil)
@@ -384,7 +548,7 @@ func (s *snapshotSuite) TestExportSnapshotsBadRequestOnNonNumericID(c *check.C)
 func (s *snapshotSuite) TestExportSnapshotsBadRequestOnError(c *check.C) {
 	var snapshotExportCalled int
 
-	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64) (*snapshotstate.SnapshotExport, error) {
+	defer daemon.MockSnapshotExport(func(ctx context.Context, st *state.State, setID uint64, passphrase string) (*snapshotstate.SnapshotExport, error) {
 		snapshotExportCalled++
 		return nil, fmt.Errorf("boom")
 	})()
@@ -403,7 +567,7 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 
 	setID := uint64(3)
 	snapNames := []string{"baz", "bar", "foo"}
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, string) (uint64, []string, error) {
 		return setID, snapNames, nil
 	})()
 
@@ -417,8 +581,29 @@ func (s *snapshotSuite) TestImportSnapshot(c *check.C) {
 	c.Check(rsp.Result, check.DeepEquals, map[string]any{"set-id": setID, "snaps": snapNames})
 }
 
+func (s *snapshotSuite) TestImportSnapshotPassphrase(c *check.C) {
+	data := []byte("mocked snapshot export data file")
+
+	var snapshotImportCalled int
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, passphrase string) (uint64, []string, error) {
+		snapshotImportCalled++
+		c.Check(passphrase, check.Equals, "sekrit passphrase")
+		return 3, []string{"foo"}, nil
+	})()
+
+	req, err := http.NewRequest("POST", "/v2/snapshots", bytes.NewReader(data))
+	req.Header.Add("Content-Length", strconv.Itoa(len(data)))
+	c.Assert(err, check.IsNil)
+	req.Header.Set("Content-Type", client.SnapshotExportMediaType)
+	req.Header.Set(client.SnapshotPassphraseHeader, "c2Vrcml0IHBhc3NwaHJhc2U=")
+
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Check(rsp.Status, check.Equals, 200)
+	c.Check(snapshotImportCalled, check.Equals, 1)
+}
+
 func (s *snapshotSuite) TestImportSnapshotError(c *check.C) {
-	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(context.Context, *state.State, io.Reader, string) (uint64, []string, error) {
 		return uint64(0), nil, errors.New("no")
 	})()
 
@@ -447,7 +632,7 @@ func (s *snapshotSuite) TestImportSnapshotNoContentLengthError(c *check.C) {
 func (s *snapshotSuite) TestImportSnapshotLimits(c *check.C) {
 	var dataRead int
 
-	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader) (uint64, []string, error) {
+	defer daemon.MockSnapshotImport(func(ctx context.Context, st *state.State, r io.Reader, passphrase string) (uint64, []string, error) {
 		data, err := io.ReadAll(r)
 		c.Assert(err, check.IsNil)
 		dataRead = len(data)
@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/release"
+	"github.com/snapcore/snapd/snap"
 )
 
 var (
@@ -195,10 +196,23 @@ func getValidationSet(c *Command, r *http.Request, user *auth.UserState) Respons
 		}
 	}
 
+	var preview bool
+	switch query.Get("preview") {
+	case "", "false":
+	case "true":
+		preview = true
+	default:
+		return BadRequest("invalid preview argument")
+	}
+
 	st := c.d.overlord.State()
 	st.Lock()
 	defer st.Unlock()
 
+	if preview {
+		return previewValidationSet(st, accountID, name, sequence, user)
+	}
+
 	var tr assertstate.ValidationSetTracking
 	err := assertstate.GetValidationSet(st, accountID, name, &tr)
 	if errors.Is(err, state.ErrNoState) || (err == nil && sequence != 0 && sequence != tr.PinnedAt) {
@@ -416,3 +430,171 @@ func enforceValidationSet(st *state.State, accountID, name string, sequence, use
 	}
 	return SyncResponse(*res)
 }
+
+type validationSetChange struct {
+	Action    string `json:"action"`
+	Snap      string `json:"snap"`
+	Component string `json:"component,omitempty"`
+	// Revision is the revision required by the validation sets, if any.
+	Revision snap.Revision `json:"revision,omitzero"`
+	// CurrentRevision is the installed revision, if any.
+	CurrentRevision snap.Revision `json:"current-revision,omitzero"`
+}
+
+type validationSetPreview struct {
+	AccountID string                `json:"account-id"`
+	Name      string                `json:"name"`
+	Sequence  int                   `json:"sequence"`
+	Changes   []validationSetChange `json:"changes,omitempty"`
+	// Conflict describes the conflicts with the other enforced validation
+	// sets, if any.
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// previewValidationSet handles snap validate --preview accountId/name[=sequence],
+// reporting the changes needed to enforce the validation set together with
+// the already enforced ones, without applying anything.
+// The state needs to be locked by the caller.
+func previewValidationSet(st *state.State, accountID, name string, sequence int, user *auth.UserState) Response {
+	as, err := getSingleSeqFormingAssertion(st, accountID, name, sequence, user)
+	if _, ok := err.(*asserts.NotFoundError); ok {
+		// not in the store - try to find in the database
+		as, err = validationSetAssertFromDb(st, accountID, name, sequence)
+		if _, ok := err.(*asserts.NotFoundError); ok {
+			return validationSetNotFound(accountID, name, sequence)
+		}
+	}
+	if err != nil {
+		return InternalError(err.Error())
+	}
+	vset := as.(*asserts.ValidationSet)
+
+	sets, err := enforcedValidationSetsWith(st, vset)
+	if err != nil {
+		return InternalError(err.Error())
+	}
+
+	res := validationSetPreview{
+		AccountID: vset.AccountID(),
+		Name:      vset.Name(),
+		Sequence:  vset.Sequence(),
+	}
+	if err := sets.Conflict(); err != nil {
+		if _, ok := err.(*snapasserts.ValidationSetsConflictError); !ok {
+			return InternalError(err.Error())
+		}
+		res.Conflict = err.Error()
+		return SyncResponse(res)
+	}
+
+	snaps, ignoreValidation, err := snapstate.InstalledSnaps(st)
+	if err != nil {
+		return InternalError(err.Error())
+	}
+	err = checkInstalledSnaps(sets, snaps, ignoreValidation)
+	if verr, ok := err.(*snapasserts.ValidationSetsValidationError); ok {
+		res.Changes = validationSetChanges(verr, snaps)
+	} else if err != nil {
+		return InternalError(err.Error())
+	}
+	return SyncResponse(res)
+}
+
+// enforcedValidationSetsWith returns the enforced validation sets, with the
+// given one replacing any enforced set with the same account and name.
+func enforcedValidationSetsWith(st *state.State, vset *asserts.ValidationSet) (*snapasserts.ValidationSets, error) {
+	validationSets, err := assertstate.ValidationSets(st)
+	if err != nil {
+		return nil, fmt.Errorf("accessing validation sets failed: %v", err)
+	}
+
+	sets := snapasserts.NewValidationSets()
+	for _, tr := range validationSets {
+		if tr.Mode != assertstate.Enforce || (tr.AccountID == vset.AccountID() && tr.Name == vset.Name()) {
+			continue
+		}
+		as, err := validationSetAssertFromDb(st, tr.AccountID, tr.Name, tr.Sequence())
+		if err != nil {
+			return nil, fmt.Errorf("cannot get assertion for validation set tracking %s/%s/%d: %v", tr.AccountID, tr.Name, tr.Sequence(), err)
+		}
+		if err := sets.Add(as); err != nil {
+			return nil, err
+		}
+	}
+	if err := sets.Add(vset); err != nil {
+		return nil, err
+	}
+	return sets, nil
+}
+
+// validationSetChanges turns the errors from checking the installed snaps
+// against validation sets into the changes that would fix them.
+func validationSetChanges(verr *snapasserts.ValidationSetsValidationError, snaps []*snapasserts.InstalledSnap) []validationSetChange {
+	installed := make(map[string]*snapasserts.InstalledSnap, len(snaps))
+	for _, sn := range snaps {
+		installed[sn.SnapName()] = sn
+	}
+	currentRevision := func(snapName, compName string) snap.Revision {
+		sn := installed[snapName]
+		if sn == nil {
+			return snap.Revision{}
+		}
+		if compName == "" {
+			return sn.Revision
+		}
+		for _, comp := range sn.Components {
+			if comp.ComponentName == compName {
+				return comp.Revision
+			}
+		}
+		return snap.Revision{}
+	}
+	// conflicts are reported before checking the snaps, so there is a
+	// single required revision at most
+	requiredRevision := func(revs map[snap.Revision][]string) snap.Revision {
+		for rev := range revs {
+			return rev
+		}
+		return snap.Revision{}
+	}
+
+	var changes []validationSetChange
+	add := func(action, snapName, compName string, rev snap.Revision) {
+		changes = append(changes, validationSetChange{
+			Action:          action,
+			Snap:            snapName,
+			Component:       compName,
+			Revision:        rev,
+			CurrentRevision: currentRevision(snapName, compName),
+		})
+	}
+
+	for snapName, revs := range verr.MissingSnaps {
+		add("install", snapName, "", requiredRevision(revs))
+	}
+	for snapName, revs := range verr.WrongRevisionSnaps {
+		add("refresh", snapName, "", requiredRevision(revs))
+	}
+	for snapName := range verr.InvalidSnaps {
+		add("remove", snapName, "", snap.Revision{})
+	}
+	for snapName, compErr := range verr.ComponentErrors {
+		for compName, revs := range compErr.MissingComponents {
+			add("install", snapName, compName, requiredRevision(revs))
+		}
+		for compName, revs := range compErr.WrongRevisionComponents {
+			add("refresh", snapName, compName, requiredRevision(revs))
+		}
+		for compName := range compErr.InvalidComponents {
+			add("remove", snapName, compName, snap.Revision{})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		if changes[i].Snap != changes[j].Snap {
+			return changes[i].Snap < changes[j].Snap
+		}
+		return changes[i].Component < changes[j].Component
+	})
+	return changes
+}
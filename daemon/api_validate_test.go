@@ -123,6 +123,10 @@ func (s *apiValidationSetsSuite) mockAssert(c *check.C, name, sequence string) a
 		"presence": "required",
 		"revision": "1",
 	}}
+	return s.mockAssertWithSnaps(c, name, sequence, snaps)
+}
+
+func (s *apiValidationSetsSuite) mockAssertWithSnaps(c *check.C, name, sequence string, snaps []any) asserts.Assertion {
 	headers := map[string]any{
 		"authority-id": s.dev1acct.AccountID(),
 		"account-id":   s.dev1acct.AccountID(),
@@ -962,3 +966,142 @@ func (s *apiValidationSetsSuite) TestApplyValidationSetEnforceModeError(c *check
 	c.Assert(rspe.Status, check.Equals, 400)
 	c.Check(string(rspe.Message), check.Equals, "cannot enforce validation set: boom")
 }
+
+func (s *apiValidationSetsSuite) TestPreviewValidationSet(c *check.C) {
+	s.mockSeqFormingAssertionFn = func(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+		c.Check(sequenceKey, check.DeepEquals, []string{"16", s.dev1acct.AccountID(), "bar"})
+		c.Check(sequence, check.Equals, 2)
+		return s.mockAssertWithSnaps(c, "bar", "2", []any{
+			map[string]any{
+				"id":       "yOqKhntON3vR7kwEbVPsILm7bUViPDzz",
+				"name":     "snap-b",
+				"presence": "required",
+				"revision": "1",
+			},
+			map[string]any{
+				"id":       "mysnapcccccccccccccccccccccccccc",
+				"name":     "snap-c",
+				"presence": "required",
+			},
+			map[string]any{
+				"id":       "mysnapdddddddddddddddddddddddddd",
+				"name":     "snap-d",
+				"presence": "invalid",
+			},
+		}), nil
+	}
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	for _, si := range []*snap.SideInfo{
+		{RealName: "snap-b", Revision: snap.R(2), SnapID: "yOqKhntON3vR7kwEbVPsILm7bUViPDzz"},
+		{RealName: "snap-d", Revision: snap.R(7), SnapID: "mysnapdddddddddddddddddddddddddd"},
+	} {
+		snapstate.Set(st, si.RealName, &snapstate.SnapState{
+			Active:   true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{si}),
+			Current:  si.Revision,
+		})
+	}
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/v2/validation-sets/%s/bar?preview=true&sequence=2", s.dev1acct.AccountID()), nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, daemon.ValidationSetPreview{
+		AccountID: s.dev1acct.AccountID(),
+		Name:      "bar",
+		Sequence:  2,
+		Changes: []daemon.ValidationSetChange{
+			{Action: "refresh", Snap: "snap-b", Revision: snap.R(1), CurrentRevision: snap.R(2)},
+			{Action: "install", Snap: "snap-c"},
+			{Action: "remove", Snap: "snap-d", CurrentRevision: snap.R(7)},
+		},
+	})
+
+	// nothing was enforced
+	st.Lock()
+	defer st.Unlock()
+	sets, err := assertstate.ValidationSets(st)
+	c.Assert(err, check.IsNil)
+	c.Check(sets, check.HasLen, 0)
+}
+
+func (s *apiValidationSetsSuite) TestPreviewValidationSetConflict(c *check.C) {
+	s.mockSeqFormingAssertionFn = func(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+		return s.mockAssertWithSnaps(c, "bar", "1", []any{
+			map[string]any{
+				"id":       "yOqKhntON3vR7kwEbVPsILm7bUViPDzz",
+				"name":     "snap-b",
+				"presence": "invalid",
+			},
+		}), nil
+	}
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	// foo requires snap-b
+	s.mockValidationSetsTracking(st)
+	assertstatetest.AddMany(st, s.dev1acct, s.acct1Key)
+	c.Assert(assertstate.Add(st, s.mockAssert(c, "foo", "9")), check.IsNil)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/v2/validation-sets/%s/bar?preview=true", s.dev1acct.AccountID()), nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	res := rsp.Result.(daemon.ValidationSetPreview)
+	c.Check(res.Changes, check.HasLen, 0)
+	c.Check(res.Conflict, check.Matches, `(?s)validation sets are in conflict:.*cannot constrain snap "snap-b" as both invalid .* and required at revision 1.*`)
+}
+
+func (s *apiValidationSetsSuite) TestPreviewValidationSetReplacesEnforced(c *check.C) {
+	s.mockSeqFormingAssertionFn = func(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+		return s.mockAssertWithSnaps(c, "foo", "10", []any{
+			map[string]any{
+				"id":       "yOqKhntON3vR7kwEbVPsILm7bUViPDzz",
+				"name":     "snap-b",
+				"presence": "invalid",
+			},
+		}), nil
+	}
+
+	st := s.d.Overlord().State()
+	st.Lock()
+	// the enforced foo at sequence 9 requires snap-b
+	s.mockValidationSetsTracking(st)
+	assertstatetest.AddMany(st, s.dev1acct, s.acct1Key)
+	c.Assert(assertstate.Add(st, s.mockAssert(c, "foo", "9")), check.IsNil)
+	st.Unlock()
+
+	req, err := http.NewRequest("GET", fmt.Sprintf("/v2/validation-sets/%s/foo?preview=true", s.dev1acct.AccountID()), nil)
+	c.Assert(err, check.IsNil)
+	rsp := s.syncReq(c, req, nil, actionIsExpected)
+	c.Assert(rsp.Status, check.Equals, 200)
+	c.Check(rsp.Result, check.DeepEquals, daemon.ValidationSetPreview{
+		AccountID: s.dev1acct.AccountID(),
+		Name:      "foo",
+		Sequence:  10,
+	})
+}
+
+func (s *apiValidationSetsSuite) TestPreviewValidationSetNotFound(c *check.C) {
+	s.mockSeqFormingAssertionFn = func(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+		return nil, &asserts.NotFoundError{Type: assertType}
+	}
+
+	req, err := http.NewRequest("GET", "/v2/validation-sets/foo/bar?preview=true", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Assert(rspe.Status, check.Equals, 404)
+	c.Check(string(rspe.Kind), check.Equals, "validation-set-not-found")
+}
+
+func (s *apiValidationSetsSuite) TestPreviewValidationSetInvalidArg(c *check.C) {
+	req, err := http.NewRequest("GET", "/v2/validation-sets/foo/bar?preview=maybe", nil)
+	c.Assert(err, check.IsNil)
+	rspe := s.errorReq(c, req, nil, actionIsExpected)
+	c.Assert(rspe.Status, check.Equals, 400)
+	c.Check(rspe.Message, check.Equals, "invalid preview argument")
+}
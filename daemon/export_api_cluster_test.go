@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package daemon
+
+import (
+	"context"
+	"io"
+
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func MockClusterstateAssemble(f func(st *state.State, opts clusterstate.AssembleOptions) (*state.Change, error)) (restore func()) {
+	return testutil.Mock(&clusterstateAssemble, f)
+}
+
+func MockClusterstateNewJoinRequest(f func(st *state.State, signer clusterstate.DeviceKeySigner, secret, address string) (*clusterstate.JoinRequest, error)) (restore func()) {
+	return testutil.Mock(&clusterstateNewJoinRequest, f)
+}
+
+func MockClusterstateAddDevice(f func(st *state.State, opts clusterstate.AddDeviceOptions) (map[string]any, error)) (restore func()) {
+	return testutil.Mock(&clusterstateAddDevice, f)
+}
+
+func MockClusterstateRemoveDevice(f func(st *state.State, opts clusterstate.RemoveDeviceOptions) (map[string]any, error)) (restore func()) {
+	return testutil.Mock(&clusterstateRemoveDevice, f)
+}
+
+func MockClusterstateInitializeNewCluster(f func(st *state.State, bundle io.Reader) error) (restore func()) {
+	return testutil.Mock(&clusterstateInitializeNewCluster, f)
+}
+
+func MockClusterstateUpdateCluster(f func(st *state.State, bundle io.Reader) error) (restore func()) {
+	return testutil.Mock(&clusterstateUpdateCluster, f)
+}
+
+func MockClusterstateRollout(f func(ctx context.Context, st *state.State, signer clusterstate.DeviceKeySigner) ([]clusterstate.DeviceRollout, error)) (restore func()) {
+	return testutil.Mock(&clusterstateRollout, f)
+}
+
+func MockClusterstateEvacuatedSnaps(f func(st *state.State) ([]string, error)) (restore func()) {
+	return testutil.Mock(&clusterstateEvacuatedSnaps, f)
+}
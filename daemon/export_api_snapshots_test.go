@@ -28,11 +28,12 @@ import (
 
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
 )
 
-func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions) (uint64, []string, *state.TaskSet, error)) (restore func()) {
+func MockSnapshotSave(newSave func(*state.State, []string, []string, map[string]*snap.SnapshotOptions, *snapshotstate.SaveFlags) (uint64, []string, *state.TaskSet, error)) (restore func()) {
 	oldSave := snapshotSave
 	snapshotSave = newSave
 	return func() {
@@ -48,7 +49,7 @@ func MockSnapshotList(newList func(context.Context, *state.State, uint64, []stri
 	}
 }
 
-func MockSnapshotExport(newExport func(context.Context, *state.State, uint64) (*snapshotstate.SnapshotExport, error)) (restore func()) {
+func MockSnapshotExport(newExport func(context.Context, *state.State, uint64, string) (*snapshotstate.SnapshotExport, error)) (restore func()) {
 	oldExport := snapshotExport
 	snapshotExport = newExport
 	return func() {
@@ -80,7 +81,23 @@ func MockSnapshotForget(newForget func(*state.State, uint64, []string) ([]string
 	}
 }
 
-func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader) (uint64, []string, error)) (restore func()) {
+func MockSnapshotPush(newPush func(*state.State, uint64, *target.Spec, string) ([]string, *state.TaskSet, error)) (restore func()) {
+	oldPush := snapshotPush
+	snapshotPush = newPush
+	return func() {
+		snapshotPush = oldPush
+	}
+}
+
+func MockSnapshotPull(newPull func(*state.State, *target.Spec, string) (*state.TaskSet, error)) (restore func()) {
+	oldPull := snapshotPull
+	snapshotPull = newPull
+	return func() {
+		snapshotPull = oldPull
+	}
+}
+
+func MockSnapshotImport(newImport func(context.Context, *state.State, io.Reader, string) (uint64, []string, error)) (restore func()) {
 	oldImport := snapshotImport
 	snapshotImport = newImport
 	return func() {
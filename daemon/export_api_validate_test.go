@@ -26,7 +26,9 @@ import (
 )
 
 type (
-	ValidationSetResult = validationSetResult
+	ValidationSetResult  = validationSetResult
+	ValidationSetPreview = validationSetPreview
+	ValidationSetChange  = validationSetChange
 )
 
 func MockCheckInstalledSnaps(f func(vsets *snapasserts.ValidationSets, snaps []*snapasserts.InstalledSnap, ignoreValidation map[string]bool) error) func() {
@@ -39,6 +39,7 @@ import (
 	"github.com/snapcore/snapd/overlord/devicestate"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/restart"
+	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -369,6 +370,7 @@ type (
 	APIError        = apiError
 	ErrorResult     = errorResult
 	SnapInstruction = snapInstruction
+	RefreshPlan     = refreshPlan
 )
 
 func (inst *snapInstruction) Dispatch() snapActionFunc {
@@ -431,6 +433,10 @@ func MockNewStatusDecorator(f func(ctx context.Context, isGlobal bool, uid strin
 	return restore
 }
 
+func MockServicestateServiceProbeStatus(f func(st *state.State, snapName, appName string) *servicestate.ProbeStatus) (restore func()) {
+	return testutil.Mock(&servicestateServiceProbeStatus, f)
+}
+
 func MockConfdbstateGetView(f func(_ *state.State, _, _, _ string) (*confdb.View, error)) (restore func()) {
 	return testutil.Mock(&confdbstateGetView, f)
 }
@@ -119,6 +119,7 @@ const (
 	snapSelectAll
 	snapSelectEnabled
 	snapSelectRefreshInhibited
+	snapSelectHealth
 )
 
 // allLocalSnapInfos returns the information about the all current snaps and their SnapStates.
@@ -142,6 +143,10 @@ func allLocalSnapInfos(st *state.State, sel snapSelect, wanted map[string]bool)
 			continue
 		}
 		health := clientHealthFromHealthstate(healths[name])
+		if sel == snapSelectHealth && health == nil {
+			// skip snaps which never reported their health
+			continue
+		}
 
 		userHold, gatingHold, err := getUserAndGatingHolds(st, name)
 		if err != nil {
@@ -216,11 +221,12 @@ func clientHealthFromHealthstate(h *healthstate.HealthState) *client.SnapHealth
 		return nil
 	}
 	return &client.SnapHealth{
-		Revision:  h.Revision,
-		Timestamp: h.Timestamp,
-		Status:    h.Status.String(),
-		Message:   h.Message,
-		Code:      h.Code,
+		Revision:    h.Revision,
+		Timestamp:   h.Timestamp,
+		Status:      h.Status.String(),
+		Message:     h.Message,
+		Code:        h.Code,
+		RecheckTime: h.RecheckTime,
 	}
 }
 
@@ -84,9 +84,11 @@ var (
 	SnapAssertsSpoolDir   string
 	SnapSeqDir            string
 
-	SnapStateFile     string
-	SnapStateLockFile string
-	SnapSystemKeyFile string
+	SnapStateFile         string
+	SnapStateJournalFile  string
+	SnapChangeArchiveFile string
+	SnapStateLockFile     string
+	SnapSystemKeyFile     string
 
 	SnapRepairConfigFile string
 	SnapRepairDir        string
@@ -389,6 +391,11 @@ func SnapStateFileUnder(rootdir string) string {
 	return filepath.Join(rootdir, snappyDir, "state.json")
 }
 
+// SnapStateJournalFileUnder returns the path to snapd state journal file under rootdir.
+func SnapStateJournalFileUnder(rootdir string) string {
+	return filepath.Join(rootdir, snappyDir, "state.json.journal")
+}
+
 // SnapStateLockFileUnder returns the path to snapd state lock file under rootdir.
 func SnapStateLockFileUnder(rootdir string) string {
 	return filepath.Join(rootdir, snappyDir, "state.lock")
@@ -621,6 +628,8 @@ func SetRootDir(rootdir string) {
 	SnapSeqDir = filepath.Join(rootdir, snappyDir, "sequence")
 
 	SnapStateFile = SnapStateFileUnder(rootdir)
+	SnapStateJournalFile = SnapStateJournalFileUnder(rootdir)
+	SnapChangeArchiveFile = filepath.Join(rootdir, snappyDir, "changes-archive.json.gz")
 	SnapStateLockFile = SnapStateLockFileUnder(rootdir)
 	SnapSystemKeyFile = filepath.Join(rootdir, snappyDir, "system-key")
 
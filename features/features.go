@@ -85,6 +85,11 @@ const (
 	SeedRefresh
 	// SnapDeltaFormat enables deltas that use the "snap delta" format
 	SnapDeltaFormat
+	// SnapshotDeduplication enables storing snapshot data in a chunk-deduplicated store.
+	SnapshotDeduplication
+	// StateJournal enables persisting the snapd state by appending its
+	// modifications to a journal instead of rewriting it on every change.
+	StateJournal
 	// lastFeature is the final known feature, it is only used for testing.
 	lastFeature
 )
@@ -141,6 +146,10 @@ var featureNames = map[SnapdFeature]string{
 	SeedRefresh: "seed-refresh",
 
 	SnapDeltaFormat: "snap-delta-format",
+
+	SnapshotDeduplication: "snapshot-deduplication",
+
+	StateJournal: "state-journal",
 }
 
 // featuresEnabledWhenUnset contains a set of features that are enabled when not explicitly configured.
@@ -164,6 +173,8 @@ var featuresExported = map[SnapdFeature]bool{
 	RefreshAppAwarenessUX: true,
 	Confdb:                true,
 	AppArmorPrompting:     true,
+
+	StateJournal: true,
 }
 
 var (
@@ -0,0 +1,492 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package mdns implements a minimal multicast DNS (RFC 6762) responder and
+// browser for DNS-SD (RFC 6763) services on the local link.
+//
+// Only what snapd needs is supported: a responder answers PTR, SRV, TXT and A
+// queries for a single service instance, and a browser sends one-shot
+// queries from an ephemeral port and collects the unicast answers.
+package mdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// Port is the well-known mDNS port.
+const Port = 5353
+
+// GroupAddr is the IPv4 mDNS multicast group address.
+var GroupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: Port}
+
+const (
+	// records are only valid for a short while, peers come and go
+	recordTTL = 120
+
+	// mDNS uses the top bit of the class of questions to request a unicast
+	// response and of answers to signal a cache flush
+	classTopBit = 1 << 15
+
+	maxPacketSize = 9000
+)
+
+// Service describes a DNS-SD service instance announced by a Responder.
+type Service struct {
+	// Instance is the instance name, which must be a single DNS label.
+	Instance string
+	// Service is the service type, e.g. "_snapd-cache._tcp".
+	Service string
+	// Domain defaults to "local".
+	Domain string
+	// Host is the target host name, as a single label, defaults to the
+	// instance name.
+	Host string
+	// Port is the port the service listens on.
+	Port int
+	// IPs are the IPv4 addresses of the host. If empty, browsers use the
+	// source address of the answer.
+	IPs []net.IP
+	// Text holds the key=value pairs of the TXT record.
+	Text []string
+}
+
+func (s *Service) domain() string {
+	if s.Domain == "" {
+		return "local"
+	}
+	return strings.TrimSuffix(s.Domain, ".")
+}
+
+func (s *Service) serviceName() string {
+	return fmt.Sprintf("%s.%s.", s.Service, s.domain())
+}
+
+func (s *Service) instanceName() string {
+	return fmt.Sprintf("%s.%s", s.Instance, s.serviceName())
+}
+
+func (s *Service) hostName() string {
+	host := s.Host
+	if host == "" {
+		host = s.Instance
+	}
+	return fmt.Sprintf("%s.%s.", host, s.domain())
+}
+
+// Validate checks that the service can be announced.
+func (s *Service) Validate() error {
+	if s.Instance == "" || strings.Contains(s.Instance, ".") {
+		return fmt.Errorf("invalid service instance name %q", s.Instance)
+	}
+	if strings.Contains(s.Host, ".") {
+		return fmt.Errorf("invalid service host name %q", s.Host)
+	}
+	if !strings.HasPrefix(s.Service, "_") || !strings.HasSuffix(s.Service, "._tcp") && !strings.HasSuffix(s.Service, "._udp") {
+		return fmt.Errorf("invalid service type %q", s.Service)
+	}
+	if s.Port <= 0 || s.Port > 65535 {
+		return fmt.Errorf("invalid service port %d", s.Port)
+	}
+	for _, ip := range s.IPs {
+		if ip.To4() == nil {
+			return fmt.Errorf("invalid service address %s: only IPv4 is supported", ip)
+		}
+	}
+	return nil
+}
+
+// Listen opens a socket bound to the mDNS port and joined to the mDNS
+// multicast group on the given interface, or on the system default
+// interface if iface is nil.
+func Listen(iface *net.Interface) (net.PacketConn, error) {
+	return net.ListenMulticastUDP("udp4", iface, GroupAddr)
+}
+
+// Responder answers mDNS queries for a service.
+type Responder struct {
+	conn  net.PacketConn
+	group net.Addr
+	svc   Service
+}
+
+// NewResponder returns a Responder answering queries for svc received on
+// conn. Answers to multicast queries, i.e. coming from the mDNS port, are sent
+// to group, other queries are answered directly.
+func NewResponder(conn net.PacketConn, group net.Addr, svc Service) (*Responder, error) {
+	if err := svc.Validate(); err != nil {
+		return nil, err
+	}
+	return &Responder{
+		conn:  conn,
+		group: group,
+		svc:   svc,
+	}, nil
+}
+
+// Serve answers queries until the context is cancelled or the connection is
+// closed.
+func (r *Responder) Serve(ctx context.Context) error {
+	defer interruptOnDone(ctx, r.conn)()
+
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := r.conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+
+		resp, err := r.answer(buf[:n], isMulticastQuery(from))
+		if err != nil {
+			logger.Debugf("cannot answer mDNS query from %s: %v", from, err)
+			continue
+		}
+		if resp == nil {
+			continue
+		}
+
+		to := from
+		if isMulticastQuery(from) {
+			to = r.group
+		}
+		if _, err := r.conn.WriteTo(resp, to); err != nil {
+			logger.Debugf("cannot send mDNS answer to %s: %v", to, err)
+		}
+	}
+}
+
+func isMulticastQuery(from net.Addr) bool {
+	udp, ok := from.(*net.UDPAddr)
+	return ok && udp.Port == Port
+}
+
+// answer builds the response to the query in msg, or returns nil if the
+// query is not about our service.
+func (r *Responder) answer(msg []byte, multicast bool) ([]byte, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	if hdr.Response {
+		return nil, nil
+	}
+	questions, err := p.AllQuestions()
+	if err != nil {
+		return nil, err
+	}
+
+	var wantPTR, wantSRV, wantTXT, wantA bool
+	var matched []dnsmessage.Question
+	for _, q := range questions {
+		name := q.Name.String()
+		switch {
+		case nameEqual(name, r.svc.serviceName()) && (q.Type == dnsmessage.TypePTR || q.Type == dnsmessage.TypeALL):
+			wantPTR = true
+		case nameEqual(name, r.svc.instanceName()) && (q.Type == dnsmessage.TypeSRV || q.Type == dnsmessage.TypeALL):
+			wantSRV = true
+		case nameEqual(name, r.svc.instanceName()) && q.Type == dnsmessage.TypeTXT:
+			wantTXT = true
+		case nameEqual(name, r.svc.hostName()) && q.Type == dnsmessage.TypeA:
+			wantA = true
+		default:
+			continue
+		}
+		matched = append(matched, q)
+	}
+	if len(matched) == 0 {
+		return nil, nil
+	}
+
+	// a PTR answer comes with everything needed to reach the instance,
+	// RFC 6763 section 12.1
+	if wantPTR {
+		wantSRV, wantTXT, wantA = true, true, true
+	}
+	if wantSRV {
+		wantA = true
+	}
+
+	respHdr := dnsmessage.Header{Response: true, Authoritative: true}
+	if !multicast {
+		// legacy unicast responses echo the query ID and questions,
+		// RFC 6762 section 6.7
+		respHdr.ID = hdr.ID
+	}
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), respHdr)
+	b.EnableCompression()
+	if !multicast {
+		if err := b.StartQuestions(); err != nil {
+			return nil, err
+		}
+		for _, q := range matched {
+			q.Class &^= classTopBit
+			if err := b.Question(q); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := b.StartAnswers(); err != nil {
+		return nil, err
+	}
+	if err := r.buildRecords(&b, multicast, wantPTR, wantSRV, wantTXT, wantA); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+func (r *Responder) buildRecords(b *dnsmessage.Builder, multicast, ptr, srv, txt, a bool) error {
+	svcName, err := dnsmessage.NewName(r.svc.serviceName())
+	if err != nil {
+		return err
+	}
+	instName, err := dnsmessage.NewName(r.svc.instanceName())
+	if err != nil {
+		return err
+	}
+	hostName, err := dnsmessage.NewName(r.svc.hostName())
+	if err != nil {
+		return err
+	}
+	header := func(name dnsmessage.Name, unique bool) dnsmessage.ResourceHeader {
+		class := dnsmessage.ClassINET
+		// the cache flush bit must not be set in legacy unicast
+		// responses
+		if unique && multicast {
+			class |= classTopBit
+		}
+		return dnsmessage.ResourceHeader{Name: name, Class: class, TTL: recordTTL}
+	}
+
+	if ptr {
+		if err := b.PTRResource(header(svcName, false), dnsmessage.PTRResource{PTR: instName}); err != nil {
+			return err
+		}
+	}
+	if srv {
+		if err := b.SRVResource(header(instName, true), dnsmessage.SRVResource{Port: uint16(r.svc.Port), Target: hostName}); err != nil {
+			return err
+		}
+	}
+	if txt {
+		text := r.svc.Text
+		if len(text) == 0 {
+			// a TXT record must contain at least one string,
+			// RFC 6763 section 6.1
+			text = []string{""}
+		}
+		if err := b.TXTResource(header(instName, true), dnsmessage.TXTResource{TXT: text}); err != nil {
+			return err
+		}
+	}
+	if a {
+		for _, ip := range r.svc.IPs {
+			var addr [4]byte
+			copy(addr[:], ip.To4())
+			if err := b.AResource(header(hostName, true), dnsmessage.AResource{A: addr}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Entry is a service instance found while browsing.
+type Entry struct {
+	// Instance is the instance name.
+	Instance string
+	// Host is the fully qualified target host name.
+	Host string
+	// Port is the port the service listens on.
+	Port int
+	// IPs are the addresses of the host.
+	IPs []net.IP
+	// Text holds the key=value pairs of the TXT record.
+	Text []string
+}
+
+// Addresses returns the host:port addresses at which the instance can be
+// reached.
+func (e *Entry) Addresses() []string {
+	addrs := make([]string, 0, len(e.IPs))
+	for _, ip := range e.IPs {
+		addrs = append(addrs, net.JoinHostPort(ip.String(), fmt.Sprint(e.Port)))
+	}
+	return addrs
+}
+
+// Browse queries dest, usually GroupAddr, for instances of the given service
+// type in the "local" domain using conn, which should not be bound to the
+// mDNS port so that responders answer directly. Answers are collected until
+// the context is done.
+func Browse(ctx context.Context, conn net.PacketConn, dest net.Addr, service string) ([]*Entry, error) {
+	svcName := fmt.Sprintf("%s.local.", service)
+	name, err := dnsmessage.NewName(svcName)
+	if err != nil {
+		return nil, err
+	}
+	b := dnsmessage.NewBuilder(make([]byte, 0, 512), dnsmessage.Header{})
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: dnsmessage.TypePTR, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	query, err := b.Finish()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.WriteTo(query, dest); err != nil {
+		return nil, fmt.Errorf("cannot send mDNS query: %v", err)
+	}
+
+	defer interruptOnDone(ctx, conn)()
+
+	found := make(map[string]*Entry)
+	var order []string
+	buf := make([]byte, maxPacketSize)
+	for {
+		n, from, err := conn.ReadFrom(buf)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		entries, err := parseAnswer(buf[:n], svcName, from)
+		if err != nil {
+			logger.Debugf("cannot parse mDNS answer from %s: %v", from, err)
+			continue
+		}
+		for _, e := range entries {
+			if _, ok := found[e.Instance]; !ok {
+				order = append(order, e.Instance)
+			}
+			found[e.Instance] = e
+		}
+	}
+
+	entries := make([]*Entry, 0, len(order))
+	for _, inst := range order {
+		entries = append(entries, found[inst])
+	}
+	return entries, nil
+}
+
+// parseAnswer extracts the complete service instances contained in msg.
+func parseAnswer(msg []byte, svcName string, from net.Addr) ([]*Entry, error) {
+	var p dnsmessage.Parser
+	hdr, err := p.Start(msg)
+	if err != nil {
+		return nil, err
+	}
+	if !hdr.Response {
+		return nil, nil
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, err
+	}
+	var records []dnsmessage.Resource
+	for _, all := range []func() ([]dnsmessage.Resource, error){p.AllAnswers, p.AllAuthorities, p.AllAdditionals} {
+		rs, err := all()
+		if err != nil {
+			return nil, err
+		}
+		records = append(records, rs...)
+	}
+
+	var instances []string
+	srvs := make(map[string]*dnsmessage.SRVResource)
+	txts := make(map[string][]string)
+	ips := make(map[string][]net.IP)
+	for _, rr := range records {
+		name := strings.ToLower(rr.Header.Name.String())
+		switch body := rr.Body.(type) {
+		case *dnsmessage.PTRResource:
+			if nameEqual(name, svcName) {
+				instances = append(instances, strings.ToLower(body.PTR.String()))
+			}
+		case *dnsmessage.SRVResource:
+			srvs[name] = body
+		case *dnsmessage.TXTResource:
+			txts[name] = body.TXT
+		case *dnsmessage.AResource:
+			ips[name] = append(ips[name], net.IP(body.A[:]).To16())
+		}
+	}
+
+	var entries []*Entry
+	for _, inst := range instances {
+		srv := srvs[inst]
+		if srv == nil {
+			continue
+		}
+		host := strings.ToLower(srv.Target.String())
+		e := &Entry{
+			Instance: strings.TrimSuffix(inst, "."+strings.ToLower(svcName)),
+			Host:     host,
+			Port:     int(srv.Port),
+			IPs:      ips[host],
+		}
+		for _, kv := range txts[inst] {
+			if kv != "" {
+				e.Text = append(e.Text, kv)
+			}
+		}
+		if len(e.IPs) == 0 {
+			if udp, ok := from.(*net.UDPAddr); ok {
+				e.IPs = []net.IP{udp.IP}
+			}
+		}
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+// interruptOnDone unblocks reads from conn once the context is done. The
+// returned function must be called to release resources.
+func interruptOnDone(ctx context.Context, conn net.PacketConn) (release func()) {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now())
+		case <-done:
+		}
+	}()
+	return func() { close(done) }
+}
+
+func nameEqual(a, b string) bool {
+	return strings.EqualFold(strings.TrimSuffix(a, "."), strings.TrimSuffix(b, "."))
+}
@@ -0,0 +1,138 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package mdns_test
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/netutil/mdns"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type mdnsSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&mdnsSuite{})
+
+func listenLoopback(c *C) net.PacketConn {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	return conn
+}
+
+// serve runs a responder for svc on a loopback socket and returns its
+// address.
+func (s *mdnsSuite) serve(c *C, svc mdns.Service) net.Addr {
+	conn := listenLoopback(c)
+	r, err := mdns.NewResponder(conn, mdns.GroupAddr, svc)
+	c.Assert(err, IsNil)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- r.Serve(ctx) }()
+	s.AddCleanup(func() {
+		cancel()
+		c.Check(<-done, IsNil)
+		conn.Close()
+	})
+	return conn.LocalAddr()
+}
+
+func browse(c *C, dest net.Addr, service string) []*mdns.Entry {
+	conn := listenLoopback(c)
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	entries, err := mdns.Browse(ctx, conn, dest, service)
+	c.Assert(err, IsNil)
+	return entries
+}
+
+func (s *mdnsSuite) TestBrowseFindsService(c *C) {
+	addr := s.serve(c, mdns.Service{
+		Instance: "peer-one",
+		Service:  "_snapd-test._tcp",
+		Port:     4242,
+		IPs:      []net.IP{net.IPv4(10, 0, 0, 7)},
+		Text:     []string{"v=1", "id=abc"},
+	})
+
+	entries := browse(c, addr, "_snapd-test._tcp")
+	c.Assert(entries, HasLen, 1)
+	e := entries[0]
+	c.Check(e.Instance, Equals, "peer-one")
+	c.Check(e.Host, Equals, "peer-one.local.")
+	c.Check(e.Port, Equals, 4242)
+	c.Check(e.Text, DeepEquals, []string{"v=1", "id=abc"})
+	c.Check(e.Addresses(), DeepEquals, []string{"10.0.0.7:4242"})
+}
+
+func (s *mdnsSuite) TestBrowseFallsBackToSourceAddress(c *C) {
+	addr := s.serve(c, mdns.Service{
+		Instance: "peer-two",
+		Service:  "_snapd-test._tcp",
+		Port:     1234,
+	})
+
+	entries := browse(c, addr, "_snapd-test._tcp")
+	c.Assert(entries, HasLen, 1)
+	c.Check(entries[0].Text, HasLen, 0)
+	c.Check(entries[0].Addresses(), DeepEquals, []string{"127.0.0.1:1234"})
+}
+
+func (s *mdnsSuite) TestBrowseIgnoresOtherServices(c *C) {
+	addr := s.serve(c, mdns.Service{
+		Instance: "peer-three",
+		Service:  "_other._tcp",
+		Port:     1234,
+	})
+
+	entries := browse(c, addr, "_snapd-test._tcp")
+	c.Check(entries, HasLen, 0)
+}
+
+func (s *mdnsSuite) TestServiceValidate(c *C) {
+	for _, tc := range []struct {
+		svc mdns.Service
+		err string
+	}{
+		{mdns.Service{Service: "_a._tcp", Port: 1}, `invalid service instance name ""`},
+		{mdns.Service{Instance: "a.b", Service: "_a._tcp", Port: 1}, `invalid service instance name "a.b"`},
+		{mdns.Service{Instance: "a", Host: "h.local", Service: "_a._tcp", Port: 1}, `invalid service host name "h.local"`},
+		{mdns.Service{Instance: "a", Service: "a._tcp", Port: 1}, `invalid service type "a._tcp"`},
+		{mdns.Service{Instance: "a", Service: "_a._sctp", Port: 1}, `invalid service type "_a._sctp"`},
+		{mdns.Service{Instance: "a", Service: "_a._tcp", Port: 0}, `invalid service port 0`},
+		{mdns.Service{Instance: "a", Service: "_a._tcp", Port: 1, IPs: []net.IP{net.ParseIP("::1")}}, `invalid service address ::1: only IPv4 is supported`},
+	} {
+		c.Check(tc.svc.Validate(), ErrorMatches, tc.err)
+	}
+
+	svc := mdns.Service{Instance: "a", Service: "_a._udp", Port: 1, IPs: []net.IP{net.IPv4(1, 2, 3, 4)}}
+	c.Check(svc.Validate(), IsNil)
+}
@@ -20,9 +20,15 @@
 package overlord
 
 import (
+	"errors"
+	"io"
+	"os"
 	"time"
 
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/state"
 )
 
 type overlordStateBackend struct {
@@ -37,3 +43,103 @@ func (osb *overlordStateBackend) Checkpoint(data []byte) error {
 func (osb *overlordStateBackend) EnsureBefore(d time.Duration) {
 	osb.ensureBefore(d)
 }
+
+// minJournalCompactionSize is the size the state journal can always grow to
+// before being compacted, even when the state is smaller.
+var minJournalCompactionSize int64 = 1024 * 1024
+
+// overlordJournalStateBackend is a state.JournalBackend appending the
+// modifications of the state to a journal next to the state file, which is
+// compacted into the state file once it grows larger than it.
+type overlordJournalStateBackend struct {
+	overlordStateBackend
+	journalPath string
+	// compactOnly is set when the journal is only kept around to recover
+	// the state written before the state-journal feature was disabled.
+	compactOnly bool
+
+	journal        *os.File
+	journalSize    int64
+	checkpointSize int64
+}
+
+func newStateBackend(ensureBefore func(d time.Duration)) state.Backend {
+	osb := overlordStateBackend{
+		path:         dirs.SnapStateFile,
+		ensureBefore: ensureBefore,
+	}
+	enabled := features.StateJournal.IsEnabled()
+	if !enabled && !osutil.FileExists(dirs.SnapStateJournalFile) {
+		return &osb
+	}
+	return &overlordJournalStateBackend{
+		overlordStateBackend: osb,
+		journalPath:          dirs.SnapStateJournalFile,
+		compactOnly:          !enabled,
+	}
+}
+
+func (osb *overlordJournalStateBackend) Checkpoint(data []byte) error {
+	if err := osb.overlordStateBackend.Checkpoint(data); err != nil {
+		return err
+	}
+	osb.checkpointSize = int64(len(data))
+	if osb.journal != nil {
+		osb.journal.Close()
+		osb.journal = nil
+	}
+	osb.journalSize = 0
+	if err := os.Remove(osb.journalPath); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (osb *overlordJournalStateBackend) Append(record []byte) error {
+	if osb.journal == nil {
+		f, err := os.OpenFile(osb.journalPath, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+		if err != nil {
+			return err
+		}
+		osb.journal = f
+	}
+	_, err := osb.journal.Write(record)
+	if err == nil {
+		err = osb.journal.Sync()
+	}
+	if err != nil {
+		// drop what was partially written, if possible, otherwise the
+		// state gets checkpointed instead which empties the journal
+		osb.journal.Truncate(osb.journalSize)
+		return err
+	}
+	osb.journalSize += int64(len(record))
+	return nil
+}
+
+func (osb *overlordJournalStateBackend) NeedsCompaction() bool {
+	if osb.compactOnly {
+		return true
+	}
+	return osb.journalSize >= minJournalCompactionSize && osb.journalSize >= osb.checkpointSize
+}
+
+func (osb *overlordJournalStateBackend) Journal() (io.ReadCloser, error) {
+	if fi, err := os.Stat(osb.path); err == nil {
+		osb.checkpointSize = fi.Size()
+	}
+	f, err := os.Open(osb.journalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	osb.journalSize = fi.Size()
+	return f, nil
+}
@@ -0,0 +1,171 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package overlord_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type stateBackendSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&stateBackendSuite{})
+
+func (s *stateBackendSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+	c.Assert(os.MkdirAll(filepath.Dir(dirs.SnapStateFile), 0755), IsNil)
+}
+
+func (s *stateBackendSuite) enableStateJournal(c *C) {
+	c.Assert(os.MkdirAll(dirs.FeaturesDir, 0755), IsNil)
+	c.Assert(os.WriteFile(features.StateJournal.ControlFile(), nil, 0644), IsNil)
+}
+
+func (s *stateBackendSuite) readState(c *C) *state.State {
+	r, err := os.Open(dirs.SnapStateFile)
+	c.Assert(err, IsNil)
+	defer r.Close()
+	st, err := state.ReadState(overlord.NewStateBackend(func(time.Duration) {}), r)
+	c.Assert(err, IsNil)
+	return st
+}
+
+func (s *stateBackendSuite) TestStateBackendWithoutJournal(c *C) {
+	backend := overlord.NewStateBackend(func(time.Duration) {})
+	_, ok := backend.(state.JournalBackend)
+	c.Check(ok, Equals, false)
+
+	st := state.New(backend)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"foo":"bar"`)
+	c.Check(dirs.SnapStateJournalFile, testutil.FileAbsent)
+}
+
+func (s *stateBackendSuite) TestStateBackendJournal(c *C) {
+	s.enableStateJournal(c)
+
+	backend := overlord.NewStateBackend(func(time.Duration) {})
+	jb, ok := backend.(state.JournalBackend)
+	c.Assert(ok, Equals, true)
+
+	st := state.New(backend)
+	st.Lock()
+	st.Set("foo", "bar")
+	// the entire state is checkpointed first
+	st.Unlock()
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"foo":"bar"`)
+	c.Check(dirs.SnapStateJournalFile, testutil.FileAbsent)
+
+	st.Lock()
+	st.Set("baz", 42)
+	chg := st.NewChange("kind", "summary")
+	chg.AddTask(st.NewTask("task", "summary"))
+	st.Unlock()
+	c.Check(dirs.SnapStateFile, Not(testutil.FileContains), `"baz"`)
+	c.Check(dirs.SnapStateJournalFile, testutil.FileContains, `"baz":42`)
+	c.Check(jb.NeedsCompaction(), Equals, false)
+
+	st1 := s.readState(c)
+	st1.Lock()
+	defer st1.Unlock()
+	var baz int
+	c.Assert(st1.Get("baz", &baz), IsNil)
+	c.Check(baz, Equals, 42)
+	c.Assert(st1.Change(chg.ID()), NotNil)
+	c.Check(st1.Change(chg.ID()).Tasks(), HasLen, 1)
+}
+
+func (s *stateBackendSuite) TestStateBackendJournalCompaction(c *C) {
+	s.enableStateJournal(c)
+	restore := overlord.MockMinJournalCompactionSize(0)
+	defer restore()
+
+	backend := overlord.NewStateBackend(func(time.Duration) {})
+	jb := backend.(state.JournalBackend)
+
+	st := state.New(backend)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+
+	st.Lock()
+	st.Set("data", make([]byte, 4096))
+	st.Unlock()
+	c.Check(dirs.SnapStateJournalFile, testutil.FilePresent)
+	// the journal is larger than the state was
+	c.Check(jb.NeedsCompaction(), Equals, true)
+
+	st.Lock()
+	st.Set("baz", 42)
+	st.Unlock()
+	c.Check(dirs.SnapStateJournalFile, testutil.FileAbsent)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"baz":42`)
+	c.Check(jb.NeedsCompaction(), Equals, false)
+}
+
+func (s *stateBackendSuite) TestStateBackendJournalDisabled(c *C) {
+	s.enableStateJournal(c)
+
+	st := state.New(overlord.NewStateBackend(func(time.Duration) {}))
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Unlock()
+	st.Lock()
+	st.Set("baz", 42)
+	st.Unlock()
+	c.Check(dirs.SnapStateJournalFile, testutil.FilePresent)
+
+	c.Assert(os.Remove(features.StateJournal.ControlFile()), IsNil)
+
+	// the journal is still read, but compacted on the first occasion
+	backend := overlord.NewStateBackend(func(time.Duration) {})
+	c.Check(backend.(state.JournalBackend).NeedsCompaction(), Equals, true)
+
+	st1 := s.readState(c)
+	st1.Lock()
+	var baz int
+	c.Assert(st1.Get("baz", &baz), IsNil)
+	c.Check(baz, Equals, 42)
+	st1.Set("qux", true)
+	st1.Unlock()
+	c.Check(dirs.SnapStateJournalFile, testutil.FileAbsent)
+	c.Check(dirs.SnapStateFile, testutil.FileContains, `"qux":true`)
+
+	backend = overlord.NewStateBackend(func(time.Duration) {})
+	_, ok := backend.(state.JournalBackend)
+	c.Check(ok, Equals, false)
+}
@@ -0,0 +1,313 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package changearchive keeps the history of the changes pruned from the
+// state in a compressed archive outside of it.
+package changearchive
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+// DefaultMaxSize is the size the archive is bounded to unless configured
+// otherwise with the changes.archive.max-size core option.
+const DefaultMaxSize = 16 * 1024 * 1024
+
+// Change is a change as it was when it was archived.
+type Change struct {
+	ID      string  `json:"id"`
+	Kind    string  `json:"kind"`
+	Summary string  `json:"summary"`
+	Status  string  `json:"status"`
+	Err     string  `json:"err,omitempty"`
+	Tasks   []*Task `json:"tasks,omitempty"`
+
+	SpawnTime time.Time `json:"spawn-time"`
+	ReadyTime time.Time `json:"ready-time"`
+
+	// SnapNames holds the names of the snaps the change operated on,
+	// as recorded in the change.
+	SnapNames []string                    `json:"snap-names,omitempty"`
+	Data      map[string]*json.RawMessage `json:"data,omitempty"`
+}
+
+// Task is a task of an archived change.
+type Task struct {
+	ID            string   `json:"id"`
+	Kind          string   `json:"kind"`
+	Summary       string   `json:"summary"`
+	Status        string   `json:"status"`
+	Log           []string `json:"log,omitempty"`
+	ProgressLabel string   `json:"progress-label,omitempty"`
+	ProgressDone  int      `json:"progress-done,omitempty"`
+	ProgressTotal int      `json:"progress-total,omitempty"`
+
+	SpawnTime time.Time `json:"spawn-time"`
+	ReadyTime time.Time `json:"ready-time,omitzero"`
+}
+
+func changeFromState(chg *state.Change) *Change {
+	archived := &Change{
+		ID:        chg.ID(),
+		Kind:      chg.Kind(),
+		Summary:   chg.Summary(),
+		Status:    chg.Status().String(),
+		SpawnTime: chg.SpawnTime(),
+		ReadyTime: chg.ReadyTime(),
+	}
+	if err := chg.Err(); err != nil {
+		archived.Err = err.Error()
+	}
+	// both are optional
+	chg.Get("snap-names", &archived.SnapNames)
+	chg.Get("api-data", &archived.Data)
+	for _, t := range chg.Tasks() {
+		label, done, total := t.Progress()
+		archived.Tasks = append(archived.Tasks, &Task{
+			ID:            t.ID(),
+			Kind:          t.Kind(),
+			Summary:       t.Summary(),
+			Status:        t.Status().String(),
+			Log:           t.Log(),
+			ProgressLabel: label,
+			ProgressDone:  done,
+			ProgressTotal: total,
+			SpawnTime:     t.SpawnTime(),
+			ReadyTime:     t.ReadyTime(),
+		})
+	}
+	return archived
+}
+
+// Archive is an append-only archive of changes. Every batch of changes is
+// appended to the archive file as a separate gzip member holding a JSON
+// object per change. Once the archive file grows beyond half of the maximum
+// size of the archive it is rotated, dropping the previously rotated one.
+type Archive struct {
+	mu   sync.Mutex
+	path string
+}
+
+// New returns an archive stored at the given path.
+func New(path string) *Archive {
+	return &Archive{path: path}
+}
+
+// Track makes the archive be populated with the changes pruned from the
+// given state.
+func (a *Archive) Track(st *state.State) {
+	st.AddChangesPrunedHandler(func(chgs []*state.Change) {
+		maxSize, err := configuredMaxSize(st)
+		if err != nil {
+			logger.Noticef("cannot archive pruned changes: %v", err)
+			return
+		}
+		archived := make([]*Change, 0, len(chgs))
+		for _, chg := range chgs {
+			archived = append(archived, changeFromState(chg))
+		}
+		if err := a.Add(archived, maxSize); err != nil {
+			logger.Noticef("cannot archive pruned changes: %v", err)
+		}
+	})
+}
+
+func configuredMaxSize(st *state.State) (int64, error) {
+	tr := config.NewTransaction(st)
+	var maxSizeStr string
+	if err := tr.GetMaybe("core", "changes.archive.max-size", &maxSizeStr); err != nil {
+		return 0, err
+	}
+	if maxSizeStr == "" {
+		return DefaultMaxSize, nil
+	}
+	maxSize, err := strutil.ParseByteSize(maxSizeStr)
+	if err != nil {
+		return 0, fmt.Errorf("cannot parse changes.archive.max-size: %v", err)
+	}
+	return maxSize, nil
+}
+
+func (a *Archive) rotatedPath() string {
+	return a.path + ".1"
+}
+
+// Add appends the given changes to the archive, rotating it first if it
+// would otherwise grow beyond half of maxSize.
+func (a *Archive) Add(chgs []*Change, maxSize int64) error {
+	if len(chgs) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(zw)
+	for _, chg := range chgs {
+		if err := enc.Encode(chg); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if err := os.MkdirAll(filepath.Dir(a.path), 0755); err != nil {
+		return err
+	}
+	if fi, err := os.Stat(a.path); err == nil && fi.Size()+int64(buf.Len()) > maxSize/2 {
+		if err := os.Rename(a.path, a.rotatedPath()); err != nil {
+			return err
+		}
+	}
+	f, err := os.OpenFile(a.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(buf.Bytes()); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Filter selects archived changes.
+type Filter struct {
+	// Since selects changes which became ready at or after the given
+	// time, if set.
+	Since time.Time
+	// Kind selects changes of the given kind, if set.
+	Kind string
+	// SnapName selects changes which operated on the given snap, if set.
+	SnapName string
+	// ID selects the change with the given ID, if set.
+	ID string
+}
+
+func (f *Filter) matches(chg *Change) bool {
+	if f == nil {
+		return true
+	}
+	if !f.Since.IsZero() && chg.ReadyTime.Before(f.Since) {
+		return false
+	}
+	if f.Kind != "" && chg.Kind != f.Kind {
+		return false
+	}
+	if f.SnapName != "" && !operatesOn(chg, f.SnapName) {
+		return false
+	}
+	if f.ID != "" && chg.ID != f.ID {
+		return false
+	}
+	return true
+}
+
+func operatesOn(chg *Change, snapName string) bool {
+	for _, name := range chg.SnapNames {
+		// the snap-names of service-control changes can include
+		// <snap>.<app>, see daemon.getChanges
+		if name, _ := snap.SplitSnapApp(name); name == snapName {
+			return true
+		}
+	}
+	return false
+}
+
+// Changes returns the archived changes matching the filter, ordered by the
+// time they became ready.
+func (a *Archive) Changes(filter *Filter) ([]*Change, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var chgs []*Change
+	for _, path := range []string{a.rotatedPath(), a.path} {
+		read, err := readArchiveFile(path, filter)
+		if err != nil {
+			return nil, err
+		}
+		chgs = append(chgs, read...)
+	}
+	sort.SliceStable(chgs, func(i, j int) bool {
+		return chgs[i].ReadyTime.Before(chgs[j].ReadyTime)
+	})
+	return chgs, nil
+}
+
+func readArchiveFile(path string, filter *Filter) ([]*Change, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	zr, err := gzip.NewReader(bufio.NewReader(f))
+	if errors.Is(err, io.EOF) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read change archive %s: %v", path, err)
+	}
+	defer zr.Close()
+
+	var chgs []*Change
+	dec := json.NewDecoder(zr)
+	for {
+		var chg Change
+		err := dec.Decode(&chg)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			// the last batch of changes was only partially appended
+			logger.Noticef("cannot read all of change archive %s: %v", path, err)
+			break
+		}
+		if filter.matches(&chg) {
+			chgs = append(chgs, &chg)
+		}
+	}
+	return chgs, nil
+}
@@ -0,0 +1,217 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package changearchive_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/changearchive"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type archiveSuite struct {
+	testutil.BaseTest
+
+	path string
+}
+
+var _ = Suite(&archiveSuite{})
+
+func (s *archiveSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.path = filepath.Join(c.MkDir(), "archive.json.gz")
+}
+
+func archivedChange(id, kind string, readyTime time.Time, snapNames ...string) *changearchive.Change {
+	return &changearchive.Change{
+		ID:        id,
+		Kind:      kind,
+		Summary:   "summary of " + id,
+		Status:    "Done",
+		SpawnTime: readyTime.Add(-time.Minute),
+		ReadyTime: readyTime,
+		SnapNames: snapNames,
+		Tasks: []*changearchive.Task{{
+			ID:      id + "0",
+			Kind:    "task",
+			Summary: "task of " + id,
+			Status:  "Done",
+			Log:     []string{"some log"},
+		}},
+	}
+}
+
+func ids(chgs []*changearchive.Change) []string {
+	var ids []string
+	for _, chg := range chgs {
+		ids = append(ids, chg.ID)
+	}
+	return ids
+}
+
+func (s *archiveSuite) TestAddAndQuery(c *C) {
+	a := changearchive.New(s.path)
+
+	chgs, err := a.Changes(nil)
+	c.Assert(err, IsNil)
+	c.Check(chgs, HasLen, 0)
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	chg1 := archivedChange("1", "install-snap", t0, "foo")
+	chg2 := archivedChange("2", "refresh-snap", t0.Add(time.Hour), "foo")
+	chg3 := archivedChange("3", "refresh-snap", t0.Add(2*time.Hour), "bar.svc")
+	c.Assert(a.Add([]*changearchive.Change{chg2, chg1}, changearchive.DefaultMaxSize), IsNil)
+	c.Assert(a.Add([]*changearchive.Change{chg3}, changearchive.DefaultMaxSize), IsNil)
+
+	chgs, err = a.Changes(nil)
+	c.Assert(err, IsNil)
+	c.Check(chgs, DeepEquals, []*changearchive.Change{chg1, chg2, chg3})
+
+	for _, tc := range []struct {
+		filter *changearchive.Filter
+		ids    []string
+	}{
+		{&changearchive.Filter{Kind: "refresh-snap"}, []string{"2", "3"}},
+		{&changearchive.Filter{Since: t0.Add(time.Hour)}, []string{"2", "3"}},
+		{&changearchive.Filter{Since: t0.Add(time.Hour), Kind: "install-snap"}, nil},
+		{&changearchive.Filter{SnapName: "foo"}, []string{"1", "2"}},
+		{&changearchive.Filter{SnapName: "bar"}, []string{"3"}},
+		{&changearchive.Filter{ID: "2"}, []string{"2"}},
+	} {
+		chgs, err := a.Changes(tc.filter)
+		c.Assert(err, IsNil)
+		c.Check(ids(chgs), DeepEquals, tc.ids, Commentf("%+v", tc.filter))
+	}
+}
+
+func (s *archiveSuite) TestRotation(c *C) {
+	a := changearchive.New(s.path)
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	// incompressible enough summaries
+	for i := 0; i < 30; i++ {
+		chg := archivedChange(fmt.Sprint(i), "kind", t0.Add(time.Duration(i)*time.Minute))
+		chg.Summary = strings.Repeat(fmt.Sprintf("%x", time.Duration(i).Nanoseconds()*7919), 100)
+		chg.Tasks[0].Log = []string{fmt.Sprintf("%v", t0.Add(time.Duration(i)*time.Second).UnixNano())}
+		c.Assert(a.Add([]*changearchive.Change{chg}, 2048), IsNil)
+	}
+
+	c.Check(s.path+".1", testutil.FilePresent)
+	for _, path := range []string{s.path, s.path + ".1"} {
+		fi, err := os.Stat(path)
+		c.Assert(err, IsNil)
+		c.Check(fi.Size() <= 1024, Equals, true)
+	}
+
+	// the oldest changes were dropped
+	chgs, err := a.Changes(nil)
+	c.Assert(err, IsNil)
+	c.Assert(len(chgs) > 0, Equals, true)
+	c.Check(len(chgs) < 30, Equals, true)
+	c.Check(chgs[len(chgs)-1].ID, Equals, "29")
+}
+
+func (s *archiveSuite) TestTruncatedArchive(c *C) {
+	a := changearchive.New(s.path)
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	c.Assert(a.Add([]*changearchive.Change{archivedChange("1", "kind", t0)}, changearchive.DefaultMaxSize), IsNil)
+	fi, err := os.Stat(s.path)
+	c.Assert(err, IsNil)
+	c.Assert(a.Add([]*changearchive.Change{archivedChange("2", "kind", t0)}, changearchive.DefaultMaxSize), IsNil)
+
+	// the last batch was only partially appended
+	c.Assert(os.Truncate(s.path, fi.Size()+20), IsNil)
+
+	chgs, err := a.Changes(nil)
+	c.Assert(err, IsNil)
+	c.Check(ids(chgs), DeepEquals, []string{"1"})
+}
+
+func (s *archiveSuite) TestTrack(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	a := changearchive.New(s.path)
+	a.Track(st)
+
+	chg := st.NewChange("refresh-snap", "Refresh snap \"foo\"")
+	chg.Set("snap-names", []string{"foo"})
+	t := st.NewTask("download-snap", "Download snap \"foo\"")
+	chg.AddTask(t)
+	t.Logf("some log")
+	t.SetStatus(state.ErrorStatus)
+	t.Errorf("boom")
+	c.Assert(chg.IsReady(), Equals, true)
+
+	st.Prune(time.Now(), 0, time.Hour, 100)
+	c.Assert(st.Change(chg.ID()), IsNil)
+
+	chgs, err := a.Changes(nil)
+	c.Assert(err, IsNil)
+	c.Assert(chgs, HasLen, 1)
+	archived := chgs[0]
+	c.Check(archived.ID, Equals, chg.ID())
+	c.Check(archived.Kind, Equals, "refresh-snap")
+	c.Check(archived.Summary, Equals, `Refresh snap "foo"`)
+	c.Check(archived.Status, Equals, "Error")
+	c.Check(archived.Err, Matches, `(?s)cannot perform the following tasks:.*Download snap "foo" \(boom\)`)
+	c.Check(archived.SnapNames, DeepEquals, []string{"foo"})
+	c.Check(archived.ReadyTime.Equal(chg.ReadyTime()), Equals, true)
+	c.Assert(archived.Tasks, HasLen, 1)
+	c.Check(archived.Tasks[0].Kind, Equals, "download-snap")
+	c.Check(archived.Tasks[0].Status, Equals, "Error")
+	c.Check(archived.Tasks[0].Log, HasLen, 2)
+}
+
+func (s *archiveSuite) TestTrackConfiguredMaxSize(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "changes.archive.max-size", "1kB"), IsNil)
+	tr.Commit()
+
+	a := changearchive.New(s.path)
+	a.Track(st)
+
+	for i := 0; i < 10; i++ {
+		chg := st.NewChange("kind", strings.Repeat(fmt.Sprintf("%x", i*7919), 100))
+		chg.SetStatus(state.DoneStatus)
+		st.Prune(time.Now(), 0, time.Hour, 100)
+	}
+	c.Check(s.path+".1", testutil.FilePresent)
+	chgs, err := a.Changes(nil)
+	c.Assert(err, IsNil)
+	c.Check(len(chgs) < 10, Equals, true)
+}
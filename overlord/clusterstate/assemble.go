@@ -0,0 +1,411 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"net"
+	"sync"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/cluster/discovery"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/randutil"
+)
+
+var assembleClusterChangeKind = swfeats.RegisterChangeKind("assemble-cluster")
+
+// assembler is the subset of [assemblestate.AssembleState] that is used to run
+// an assembly session.
+type assembler interface {
+	Run(
+		ctx context.Context,
+		ln net.Listener,
+		transport assemblestate.Transport,
+		discoveries <-chan []string,
+		opts assemblestate.RunOptions,
+	) ([]assemblestate.Identity, assemblestate.Routes, error)
+}
+
+var (
+	newAssembleState = func(
+		config assemblestate.AssembleConfig,
+		session assemblestate.AssembleSession,
+		selector func(self assemblestate.DeviceToken, identified func(assemblestate.DeviceToken) bool) (assemblestate.RouteSelector, error),
+		commit func(assemblestate.AssembleSession),
+		db asserts.RODatabase,
+	) (assembler, error) {
+		return assemblestate.NewAssembleState(config, session, selector, commit, db)
+	}
+	assertionDevices = assemblestate.AssertionDevices
+	netListen        = net.Listen
+
+	// discoverPeers advertises address on the local network and sends the
+	// addresses of the peers found to discoveries until the context is done.
+	discoverPeers = func(ctx context.Context, address string, discoveries chan<- []string) error {
+		d, err := discovery.New(address, nil)
+		if err != nil {
+			return err
+		}
+		return d.Run(ctx, discoveries)
+	}
+
+	// assemblePeriod is how often route information and the statically
+	// configured peers are published during assembly.
+	assemblePeriod = 5 * time.Second
+)
+
+// DeviceKeySigner can sign arbitrary data with the device's private key.
+type DeviceKeySigner interface {
+	SignWithDeviceKey(data []byte) ([]byte, error)
+}
+
+// AssembleOptions carries the parameters of a cluster assembly session.
+type AssembleOptions struct {
+	// Secret is the secret shared by all the devices taking part in the
+	// assembly, used to authenticate them to each other.
+	Secret string `json:"secret"`
+	// Address is the IP address and port that this device listens on for
+	// messages from its peers. It is published to the other devices and ends
+	// up in the cluster assertion.
+	Address string `json:"address"`
+	// ExpectedSize is the number of devices in the cluster. Assembly finishes
+	// once all of them are known and connected to each other.
+	ExpectedSize int `json:"expected-size"`
+	// Peers optionally lists the addresses of other devices taking part in
+	// the assembly, in addition to the ones found on the local network.
+	Peers []string `json:"peers,omitempty"`
+}
+
+func (opts *AssembleOptions) validate() error {
+	if opts.Secret == "" {
+		return errors.New("secret must be provided")
+	}
+	if opts.ExpectedSize < 1 {
+		return fmt.Errorf("expected cluster size must be a positive number: %d", opts.ExpectedSize)
+	}
+	if err := validateAddress(opts.Address); err != nil {
+		return err
+	}
+	for _, peer := range opts.Peers {
+		if err := validateAddress(peer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func validateAddress(addr string) error {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid address %q: %v", addr, err)
+	}
+	if net.ParseIP(host) == nil {
+		return fmt.Errorf("invalid address %q: host must be an IP address", addr)
+	}
+	if port == "" || port == "0" {
+		return fmt.Errorf("invalid address %q: port must be provided", addr)
+	}
+	return nil
+}
+
+// assembleSetup is kept in the assemble-cluster task so that an interrupted
+// assembly resumes with the same identity after a restart. The secret and
+// the TLS private key are dropped once the change is ready.
+type assembleSetup struct {
+	Options AssembleOptions           `json:"options"`
+	RDT     assemblestate.DeviceToken `json:"rdt"`
+	TLSCert []byte                    `json:"tls-cert"`
+	TLSKey  []byte                    `json:"tls-key"`
+}
+
+// Assemble creates a change that assembles a new cluster together with other
+// devices found on the network. Once done, the change's "api-data" carries the
+// headers of the resulting cluster assertion under "cluster-assertion", ready
+// to be signed. Callers must hold the state lock.
+func Assemble(st *state.State, opts AssembleOptions) (*state.Change, error) {
+	if err := checkClusteringEnabled(st); err != nil {
+		return nil, err
+	}
+
+	if err := opts.validate(); err != nil {
+		return nil, fmt.Errorf("cannot assemble cluster: %v", err)
+	}
+
+	cluster, err := CurrentCluster(st)
+	if err != nil && !errors.Is(err, ErrNoClusterAssertion) {
+		return nil, err
+	}
+	if cluster != nil {
+		return nil, fmt.Errorf("cannot assemble cluster: device is already part of cluster %q", cluster.ClusterID())
+	}
+
+	for _, chg := range st.Changes() {
+		if chg.Kind() == assembleClusterChangeKind && !chg.Status().Ready() {
+			return nil, fmt.Errorf("cannot assemble cluster: assembly already in progress in change %s", chg.ID())
+		}
+	}
+
+	if _, err := devicestate.Serial(st); err != nil {
+		return nil, fmt.Errorf("cannot assemble cluster without a serial assertion: %w", err)
+	}
+
+	rdt, err := randutil.CryptoToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	host, _, _ := net.SplitHostPort(opts.Address)
+	cert, key, err := generateCertificate(net.ParseIP(host), assemblestate.AssembleSessionLength*2)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate assembly certificate: %v", err)
+	}
+
+	t := st.NewTask("assemble-cluster", fmt.Sprintf("Assemble cluster of %d devices", opts.ExpectedSize))
+	t.Set("assemble-setup", assembleSetup{
+		Options: opts,
+		RDT:     assemblestate.DeviceToken(rdt),
+		TLSCert: cert,
+		TLSKey:  key,
+	})
+
+	chg := st.NewChange(assembleClusterChangeKind, "Assemble cluster")
+	chg.AddTask(t)
+
+	return chg, nil
+}
+
+// generateCertificate creates a self-signed certificate that identifies this
+// device to its peers for the given lifetime. The certificate is only valid
+// for the given IP address, if any.
+func generateCertificate(ip net.IP, lifetime time.Duration) (certPEM []byte, keyPEM []byte, err error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	now := time.Now()
+	template := x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: "snapd cluster"},
+		NotBefore:    now,
+		NotAfter:     now.Add(lifetime),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	if ip != nil {
+		template.IPAddresses = []net.IP{ip}
+	}
+
+	cert, err := x509.CreateCertificate(rand.Reader, &template, &template, pub, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	certPEM = pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert})
+	keyPEM = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	return certPEM, keyPEM, nil
+}
+
+func (m *ClusterManager) doAssembleCluster(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+
+	var setup assembleSetup
+	if err := t.Get("assemble-setup", &setup); err != nil {
+		st.Unlock()
+		return err
+	}
+
+	// a session is present if we are resuming an interrupted assembly
+	var session assemblestate.AssembleSession
+	if err := t.Get("assemble-session", &session); err != nil && !errors.Is(err, state.ErrNoState) {
+		st.Unlock()
+		return err
+	}
+
+	serial, err := devicestate.Serial(st)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	db := assertstate.DB(st)
+	st.Unlock()
+
+	commit := func(session assemblestate.AssembleSession) {
+		st.Lock()
+		defer st.Unlock()
+		t.Set("assemble-session", session)
+	}
+
+	selector := func(self assemblestate.DeviceToken, identified func(assemblestate.DeviceToken) bool) (assemblestate.RouteSelector, error) {
+		return assemblestate.NewPrioritySelector(self, nil, identified), nil
+	}
+
+	as, err := newAssembleState(assemblestate.AssembleConfig{
+		Secret:       setup.Options.Secret,
+		RDT:          setup.RDT,
+		TLSCert:      setup.TLSCert,
+		TLSKey:       setup.TLSKey,
+		ExpectedSize: setup.Options.ExpectedSize,
+		Serial:       serial,
+		Signer: func(data []byte) ([]byte, error) {
+			st.Lock()
+			defer st.Unlock()
+			return m.signer.SignWithDeviceKey(data)
+		},
+	}, session, selector, commit, db)
+	if err != nil {
+		return fmt.Errorf("cannot start cluster assembly: %v", err)
+	}
+
+	ln, err := netListen("tcp", setup.Options.Address)
+	if err != nil {
+		return fmt.Errorf("cannot listen for cluster assembly: %v", err)
+	}
+
+	ctx := tomb.Context(nil)
+	discoveries := make(chan []string)
+
+	// peers are looked for only while assembly runs
+	discoveryCtx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	if len(setup.Options.Peers) > 0 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			publishPeers(discoveryCtx, setup.Options.Peers, discoveries)
+		}()
+	}
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := discoverPeers(discoveryCtx, setup.Options.Address, discoveries); err != nil {
+			logger.Noticef("Cannot look for cluster peers on the local network: %v", err)
+		}
+	}()
+
+	ids, routes, err := as.Run(ctx, ln, assemblestate.NewHTTPSTransport(), discoveries, assemblestate.RunOptions{
+		Period: assemblePeriod,
+	})
+	cancel()
+	wg.Wait()
+	if err != nil {
+		return fmt.Errorf("cannot assemble cluster: %v", err)
+	}
+
+	// we were interrupted before assembly completed, the persisted session
+	// lets us carry on from where we were once the task runs again
+	if ctx.Err() != nil {
+		return &state.Retry{}
+	}
+
+	devices, err := assertionDevices(ids, routes)
+	if err != nil {
+		return fmt.Errorf("cannot build cluster devices: %v", err)
+	}
+
+	clusterID, err := randutil.RandomKernelUUID()
+	if err != nil {
+		return err
+	}
+
+	headers := map[string]any{
+		"type":       asserts.ClusterType.Name,
+		"cluster-id": clusterID,
+		"sequence":   "1",
+		"devices":    devices,
+		"timestamp":  time.Now().UTC().Format(time.RFC3339),
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	t.Change().Set("api-data", map[string]any{"cluster-assertion": headers})
+
+	return nil
+}
+
+// cleanupAssembleCluster drops the secret shared with the other devices and
+// the TLS private key from the task once the assembly is over, whether it
+// succeeded or not.
+func (m *ClusterManager) cleanupAssembleCluster(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var setup assembleSetup
+	if err := t.Get("assemble-setup", &setup); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil
+		}
+		return err
+	}
+	setup.Options.Secret = ""
+	setup.TLSKey = nil
+	t.Set("assemble-setup", setup)
+
+	return nil
+}
+
+// publishPeers repeatedly offers the given peer addresses for discovery, since
+// the peers might not be listening yet when assembly starts.
+func publishPeers(ctx context.Context, peers []string, discoveries chan<- []string) {
+	for {
+		select {
+		case discoveries <- peers:
+		case <-ctx.Done():
+			return
+		}
+
+		select {
+		case <-time.After(assemblePeriod):
+		case <-ctx.Done():
+			return
+		}
+	}
+}
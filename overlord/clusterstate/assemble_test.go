@@ -0,0 +1,470 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type assembleSuite struct {
+	testutil.BaseTest
+}
+
+var _ = check.Suite(&assembleSuite{})
+
+type fakeSigner struct {
+	signed [][]byte
+}
+
+func (f *fakeSigner) SignWithDeviceKey(data []byte) ([]byte, error) {
+	f.signed = append(f.signed, data)
+	return []byte("signature"), nil
+}
+
+type fakeAssembler struct {
+	run func(ctx context.Context, ln net.Listener, discoveries <-chan []string, opts assemblestate.RunOptions) ([]assemblestate.Identity, assemblestate.Routes, error)
+}
+
+func (f *fakeAssembler) Run(ctx context.Context, ln net.Listener, transport assemblestate.Transport, discoveries <-chan []string, opts assemblestate.RunOptions) ([]assemblestate.Identity, assemblestate.Routes, error) {
+	return f.run(ctx, ln, discoveries, opts)
+}
+
+func (s *assembleSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(clusterstate.MockAssemblePeriod(time.Millisecond))
+	s.AddCleanup(clusterstate.MockNetListen(func(network, address string) (net.Listener, error) {
+		return net.Listen(network, "127.0.0.1:0")
+	}))
+	s.AddCleanup(clusterstate.MockDiscoverPeers(func(ctx context.Context, address string, discoveries chan<- []string) error {
+		<-ctx.Done()
+		return nil
+	}))
+}
+
+func (s *assembleSuite) newState(c *check.C) *state.State {
+	st, stack := newStateWithStoreStack(c)
+	st.Lock()
+	defer st.Unlock()
+	addSerialToState(c, st, makeSerialAssertion(c, stack, "serial-1"))
+	return st
+}
+
+func assembleOptions() clusterstate.AssembleOptions {
+	return clusterstate.AssembleOptions{
+		Secret:       "secret",
+		Address:      "192.168.1.10:7070",
+		ExpectedSize: 2,
+		Peers:        []string{"192.168.1.11:7070"},
+	}
+}
+
+func (s *assembleSuite) TestAssembleErrors(c *check.C) {
+	st := s.newState(c)
+	st.Lock()
+	defer st.Unlock()
+
+	for _, tc := range []struct {
+		mutate func(*clusterstate.AssembleOptions)
+		err    string
+	}{
+		{func(o *clusterstate.AssembleOptions) { o.Secret = "" }, "cannot assemble cluster: secret must be provided"},
+		{func(o *clusterstate.AssembleOptions) { o.ExpectedSize = 0 }, "cannot assemble cluster: expected cluster size must be a positive number: 0"},
+		{func(o *clusterstate.AssembleOptions) { o.Address = "192.168.1.10" }, `cannot assemble cluster: invalid address "192.168.1.10": .*missing port in address`},
+		{func(o *clusterstate.AssembleOptions) { o.Address = "host:7070" }, `cannot assemble cluster: invalid address "host:7070": host must be an IP address`},
+		{func(o *clusterstate.AssembleOptions) { o.Address = "192.168.1.10:0" }, `cannot assemble cluster: invalid address "192.168.1.10:0": port must be provided`},
+		{func(o *clusterstate.AssembleOptions) { o.Peers = []string{"peer"} }, `cannot assemble cluster: invalid address "peer": .*`},
+	} {
+		opts := assembleOptions()
+		tc.mutate(&opts)
+		_, err := clusterstate.Assemble(st, opts)
+		c.Check(err, check.ErrorMatches, tc.err)
+	}
+	c.Check(st.Changes(), check.HasLen, 0)
+}
+
+func (s *assembleSuite) TestAssembleClusteringDisabled(c *check.C) {
+	st := s.newState(c)
+	st.Lock()
+	defer st.Unlock()
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "experimental.clustering", false), check.IsNil)
+	tr.Commit()
+
+	_, err := clusterstate.Assemble(st, assembleOptions())
+	c.Check(err, check.ErrorMatches, `experimental feature disabled - test it by setting 'experimental.clustering' to true`)
+}
+
+func (s *assembleSuite) TestAssembleNoSerial(c *check.C) {
+	st, _ := newStateWithStoreStack(c)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := clusterstate.Assemble(st, assembleOptions())
+	c.Check(err, check.ErrorMatches, `cannot assemble cluster without a serial assertion: .*`)
+}
+
+func (s *assembleSuite) TestAssembleExistingCluster(c *check.C) {
+	st, stack := newStateWithStoreStack(c)
+	st.Lock()
+	defer st.Unlock()
+	addSerialToState(c, st, makeSerialAssertion(c, stack, "serial-1"))
+
+	bundle, _ := makeClusterBundle(c, stack, nil, nil)
+	c.Assert(clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle)), check.IsNil)
+
+	_, err := clusterstate.Assemble(st, assembleOptions())
+	c.Check(err, check.ErrorMatches, `cannot assemble cluster: device is already part of cluster "cluster-id"`)
+}
+
+func (s *assembleSuite) TestAssembleAlreadyInProgress(c *check.C) {
+	st := s.newState(c)
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := clusterstate.Assemble(st, assembleOptions())
+	c.Assert(err, check.IsNil)
+
+	_, err = clusterstate.Assemble(st, assembleOptions())
+	c.Check(err, check.ErrorMatches, `cannot assemble cluster: assembly already in progress in change `+chg.ID())
+}
+
+func (s *assembleSuite) TestAssembleChange(c *check.C) {
+	st := s.newState(c)
+	st.Lock()
+	defer st.Unlock()
+
+	chg, err := clusterstate.Assemble(st, assembleOptions())
+	c.Assert(err, check.IsNil)
+	c.Check(chg.Kind(), check.Equals, "assemble-cluster")
+
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	c.Check(tasks[0].Kind(), check.Equals, "assemble-cluster")
+
+	var setup clusterstate.AssembleSetup
+	c.Assert(tasks[0].Get("assemble-setup", &setup), check.IsNil)
+	c.Check(setup.Options, check.DeepEquals, assembleOptions())
+	c.Check(setup.RDT, check.Not(check.Equals), assemblestate.DeviceToken(""))
+
+	// the generated certificate is usable for assembly
+	_, err = tls.X509KeyPair(setup.TLSCert, setup.TLSKey)
+	c.Check(err, check.IsNil)
+}
+
+func (s *assembleSuite) TestAssembleCluster(c *check.C) {
+	st := s.newState(c)
+	signer := &fakeSigner{}
+	runner := state.NewTaskRunner(st)
+	clusterstate.Manager(st, runner, signer)
+
+	st.Lock()
+	chg, err := clusterstate.Assemble(st, assembleOptions())
+	c.Assert(err, check.IsNil)
+	var setup clusterstate.AssembleSetup
+	c.Assert(chg.Tasks()[0].Get("assemble-setup", &setup), check.IsNil)
+	st.Unlock()
+
+	ids := []assemblestate.Identity{{RDT: "rdt-1"}, {RDT: "rdt-2"}}
+	routes := assemblestate.Routes{
+		Devices:   []assemblestate.DeviceToken{"rdt-1", "rdt-2"},
+		Addresses: []string{"192.168.1.10:7070", "192.168.1.11:7070"},
+		Routes:    []int{0, 1, 1, 1, 0, 0},
+	}
+	session := assemblestate.AssembleSession{
+		Initiated:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Discovered: []string{"192.168.1.11:7070"},
+	}
+
+	s.AddCleanup(clusterstate.MockNewAssembleState(func(
+		config assemblestate.AssembleConfig,
+		sess assemblestate.AssembleSession,
+		selector func(assemblestate.DeviceToken, func(assemblestate.DeviceToken) bool) (assemblestate.RouteSelector, error),
+		commit func(assemblestate.AssembleSession),
+		db asserts.RODatabase,
+	) (clusterstate.Assembler, error) {
+		c.Check(config.Secret, check.Equals, "secret")
+		c.Check(config.RDT, check.Equals, setup.RDT)
+		c.Check(config.TLSCert, check.DeepEquals, setup.TLSCert)
+		c.Check(config.TLSKey, check.DeepEquals, setup.TLSKey)
+		c.Check(config.ExpectedSize, check.Equals, 2)
+		c.Check(config.Serial.Serial(), check.Equals, "serial-1")
+		c.Check(sess, check.DeepEquals, assemblestate.AssembleSession{})
+		c.Check(db, check.NotNil)
+
+		sig, err := config.Signer([]byte("hmac"))
+		c.Check(err, check.IsNil)
+		c.Check(string(sig), check.Equals, "signature")
+
+		sel, err := selector("rdt-1", func(assemblestate.DeviceToken) bool { return true })
+		c.Check(err, check.IsNil)
+		c.Check(sel, check.NotNil)
+
+		return &fakeAssembler{run: func(ctx context.Context, ln net.Listener, discoveries <-chan []string, opts assemblestate.RunOptions) ([]assemblestate.Identity, assemblestate.Routes, error) {
+			defer ln.Close()
+			c.Check(opts.Period, check.Equals, time.Millisecond)
+
+			// the configured peers are offered for discovery
+			select {
+			case peers := <-discoveries:
+				c.Check(peers, check.DeepEquals, []string{"192.168.1.11:7070"})
+			case <-time.After(10 * time.Second):
+				c.Fatal("peers were not published")
+			}
+
+			commit(session)
+			return ids, routes, nil
+		}}, nil
+	}))
+
+	s.AddCleanup(clusterstate.MockAssertionDevices(func(gotIDs []assemblestate.Identity, gotRoutes assemblestate.Routes) ([]any, error) {
+		c.Check(gotIDs, check.DeepEquals, ids)
+		c.Check(gotRoutes, check.DeepEquals, routes)
+		return []any{
+			map[string]any{"id": "1", "device": "serial-1.ubuntu-core-24-amd64.canonical", "addresses": []any{"192.168.1.10"}},
+			map[string]any{"id": "2", "device": "serial-2.ubuntu-core-24-amd64.canonical", "addresses": []any{"192.168.1.11"}},
+		}, nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		runner.Ensure()
+		runner.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+
+	c.Assert(chg.Status(), check.Equals, state.DoneStatus, check.Commentf("%v", chg.Err()))
+	c.Check(signer.signed, check.DeepEquals, [][]byte{[]byte("hmac")})
+
+	// the secret and the TLS private key do not outlive the assembly
+	var cleaned clusterstate.AssembleSetup
+	c.Assert(chg.Tasks()[0].Get("assemble-setup", &cleaned), check.IsNil)
+	c.Check(cleaned.Options.Secret, check.Equals, "")
+	c.Check(cleaned.TLSKey, check.IsNil)
+	c.Check(cleaned.RDT, check.Equals, setup.RDT)
+	c.Check(cleaned.TLSCert, check.DeepEquals, setup.TLSCert)
+
+	var persisted assemblestate.AssembleSession
+	c.Assert(chg.Tasks()[0].Get("assemble-session", &persisted), check.IsNil)
+	c.Check(persisted.Initiated.Equal(session.Initiated), check.Equals, true)
+	c.Check(persisted.Discovered, check.DeepEquals, session.Discovered)
+
+	var data map[string]map[string]any
+	c.Assert(chg.Get("api-data", &data), check.IsNil)
+	headers := data["cluster-assertion"]
+	c.Check(headers["type"], check.Equals, "cluster")
+	c.Check(headers["sequence"], check.Equals, "1")
+	c.Check(headers["cluster-id"], check.Not(check.Equals), "")
+
+	// the headers make up a valid cluster assertion once signed
+	stack := assertstest.NewStoreStack("canonical", nil)
+	headers["authority-id"] = "canonical"
+	a, err := stack.Sign(asserts.ClusterType, headers, nil, "")
+	c.Assert(err, check.IsNil)
+	cluster := a.(*asserts.Cluster)
+	c.Check(cluster.Sequence(), check.Equals, 1)
+	c.Assert(cluster.Devices(), check.HasLen, 2)
+	c.Check(cluster.Devices()[1].Serial, check.Equals, "serial-2")
+	c.Check(cluster.Devices()[1].Addresses, check.DeepEquals, []string{"192.168.1.11"})
+}
+
+func (s *assembleSuite) TestAssembleClusterDiscoversPeers(c *check.C) {
+	st := s.newState(c)
+	runner := state.NewTaskRunner(st)
+	clusterstate.Manager(st, runner, &fakeSigner{})
+
+	opts := assembleOptions()
+	opts.Peers = nil
+
+	st.Lock()
+	chg, err := clusterstate.Assemble(st, opts)
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	s.AddCleanup(clusterstate.MockDiscoverPeers(func(ctx context.Context, address string, discoveries chan<- []string) error {
+		c.Check(address, check.Equals, "192.168.1.10:7070")
+		select {
+		case discoveries <- []string{"192.168.1.12:7070"}:
+		case <-ctx.Done():
+		}
+		<-ctx.Done()
+		return nil
+	}))
+	s.AddCleanup(clusterstate.MockNewAssembleState(func(
+		config assemblestate.AssembleConfig,
+		sess assemblestate.AssembleSession,
+		selector func(assemblestate.DeviceToken, func(assemblestate.DeviceToken) bool) (assemblestate.RouteSelector, error),
+		commit func(assemblestate.AssembleSession),
+		db asserts.RODatabase,
+	) (clusterstate.Assembler, error) {
+		return &fakeAssembler{run: func(ctx context.Context, ln net.Listener, discoveries <-chan []string, opts assemblestate.RunOptions) ([]assemblestate.Identity, assemblestate.Routes, error) {
+			defer ln.Close()
+
+			// peers found on the local network are offered for discovery
+			select {
+			case peers := <-discoveries:
+				c.Check(peers, check.DeepEquals, []string{"192.168.1.12:7070"})
+			case <-time.After(10 * time.Second):
+				c.Fatal("discovered peers were not published")
+			}
+			return []assemblestate.Identity{{RDT: "rdt-1"}}, assemblestate.Routes{}, nil
+		}}, nil
+	}))
+	s.AddCleanup(clusterstate.MockAssertionDevices(func([]assemblestate.Identity, assemblestate.Routes) ([]any, error) {
+		return []any{map[string]any{"id": "1", "device": "serial-1.ubuntu-core-24-amd64.canonical", "addresses": []any{"192.168.1.10"}}}, nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		runner.Ensure()
+		runner.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	c.Check(chg.Status(), check.Equals, state.DoneStatus, check.Commentf("%v", chg.Err()))
+}
+
+func (s *assembleSuite) TestAssembleClusterResumesAfterRestart(c *check.C) {
+	st := s.newState(c)
+
+	st.Lock()
+	chg, err := clusterstate.Assemble(st, assembleOptions())
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	session := assemblestate.AssembleSession{
+		Initiated:  time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		Discovered: []string{"192.168.1.11:7070"},
+	}
+
+	var sessions []assemblestate.AssembleSession
+	running := make(chan struct{})
+	s.AddCleanup(clusterstate.MockNewAssembleState(func(
+		config assemblestate.AssembleConfig,
+		sess assemblestate.AssembleSession,
+		selector func(assemblestate.DeviceToken, func(assemblestate.DeviceToken) bool) (assemblestate.RouteSelector, error),
+		commit func(assemblestate.AssembleSession),
+		db asserts.RODatabase,
+	) (clusterstate.Assembler, error) {
+		sessions = append(sessions, sess)
+		return &fakeAssembler{run: func(ctx context.Context, ln net.Listener, discoveries <-chan []string, opts assemblestate.RunOptions) ([]assemblestate.Identity, assemblestate.Routes, error) {
+			defer ln.Close()
+			if len(sessions) == 1 {
+				commit(session)
+				close(running)
+				// block until we are interrupted
+				<-ctx.Done()
+				return nil, assemblestate.Routes{}, nil
+			}
+			return []assemblestate.Identity{{RDT: "rdt-1"}}, assemblestate.Routes{}, nil
+		}}, nil
+	}))
+	s.AddCleanup(clusterstate.MockAssertionDevices(func([]assemblestate.Identity, assemblestate.Routes) ([]any, error) {
+		return []any{
+			map[string]any{"id": "1", "device": "serial-1.ubuntu-core-24-amd64.canonical", "addresses": []any{"192.168.1.10"}},
+		}, nil
+	}))
+
+	runner := state.NewTaskRunner(st)
+	clusterstate.Manager(st, runner, &fakeSigner{})
+	runner.Ensure()
+	<-running
+	runner.Stop()
+
+	st.Lock()
+	c.Check(chg.Status(), check.Equals, state.DoingStatus)
+	// the secret is needed to carry on
+	var setup clusterstate.AssembleSetup
+	c.Assert(chg.Tasks()[0].Get("assemble-setup", &setup), check.IsNil)
+	c.Check(setup.Options.Secret, check.Equals, "secret")
+	c.Check(setup.TLSKey, check.NotNil)
+	st.Unlock()
+
+	// a new runner, as after a restart, picks up the persisted session
+	runner = state.NewTaskRunner(st)
+	clusterstate.Manager(st, runner, &fakeSigner{})
+	for i := 0; i < 3; i++ {
+		runner.Ensure()
+		runner.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Status(), check.Equals, state.DoneStatus, check.Commentf("%v", chg.Err()))
+	c.Assert(sessions, check.HasLen, 2)
+	c.Check(sessions[0], check.DeepEquals, assemblestate.AssembleSession{})
+	c.Check(sessions[1].Initiated.Equal(session.Initiated), check.Equals, true)
+	c.Check(sessions[1].Discovered, check.DeepEquals, session.Discovered)
+}
+
+func (s *assembleSuite) TestAssembleClusterErrorDropsSecrets(c *check.C) {
+	st := s.newState(c)
+	runner := state.NewTaskRunner(st)
+	clusterstate.Manager(st, runner, &fakeSigner{})
+
+	st.Lock()
+	chg, err := clusterstate.Assemble(st, assembleOptions())
+	c.Assert(err, check.IsNil)
+	st.Unlock()
+
+	s.AddCleanup(clusterstate.MockNewAssembleState(func(
+		config assemblestate.AssembleConfig,
+		sess assemblestate.AssembleSession,
+		selector func(assemblestate.DeviceToken, func(assemblestate.DeviceToken) bool) (assemblestate.RouteSelector, error),
+		commit func(assemblestate.AssembleSession),
+		db asserts.RODatabase,
+	) (clusterstate.Assembler, error) {
+		return &fakeAssembler{run: func(ctx context.Context, ln net.Listener, discoveries <-chan []string, opts assemblestate.RunOptions) ([]assemblestate.Identity, assemblestate.Routes, error) {
+			defer ln.Close()
+			return nil, assemblestate.Routes{}, errors.New("boom")
+		}}, nil
+	}))
+
+	for i := 0; i < 3; i++ {
+		runner.Ensure()
+		runner.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Status(), check.Equals, state.ErrorStatus)
+	c.Check(chg.Err(), check.ErrorMatches, `(?s).*cannot assemble cluster: boom.*`)
+
+	var setup clusterstate.AssembleSetup
+	c.Assert(chg.Tasks()[0].Get("assemble-setup", &setup), check.IsNil)
+	c.Check(setup.Options.Secret, check.Equals, "")
+	c.Check(setup.TLSKey, check.IsNil)
+}
@@ -22,8 +22,13 @@ package clusterstate
 import (
 	"errors"
 	"fmt"
+	"sync"
 
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/features"
+	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/overlord/swfeats"
@@ -32,14 +37,26 @@ import (
 var applyClusterSubclusterChangeKind = swfeats.RegisterChangeKind("apply-cluster-subcluster")
 
 type ClusterManager struct {
-	state *state.State
+	state  *state.State
+	signer DeviceKeySigner
+
+	rolloutMu sync.Mutex
+	rollout   *rolloutServer
 }
 
 // Manager returns a new ClusterManager.
-func Manager(st *state.State) *ClusterManager {
-	return &ClusterManager{
-		state: st,
+func Manager(st *state.State, runner *state.TaskRunner, signer DeviceKeySigner) *ClusterManager {
+	m := &ClusterManager{
+		state:  st,
+		signer: signer,
 	}
+
+	runner.AddHandler("set-cluster-snap-state", m.doSetClusterSnapState, m.undoSetClusterSnapState)
+	runner.AddHandler("assemble-cluster", m.doAssembleCluster, nil)
+	runner.AddCleanup("assemble-cluster", m.cleanupAssembleCluster)
+	runner.AddHandler("wait-cluster-rollout", m.doWaitClusterRollout, nil)
+
+	return m
 }
 
 // Ensure ensures that the device state matches the expectations defined by the
@@ -65,6 +82,12 @@ func (m *ClusterManager) Ensure() error {
 		return fmt.Errorf("cannot get cluster assertion: %w", err)
 	}
 
+	// peers ask this device about its progress even when it has nothing to
+	// apply itself
+	if err := m.updateRolloutServer(cluster); err != nil {
+		logger.Noticef("Cannot answer cluster rollout queries: %v", err)
+	}
+
 	tasksets, err := applyClusterState(m.state, cluster)
 	if err != nil {
 		return err
@@ -76,18 +99,28 @@ func (m *ClusterManager) Ensure() error {
 
 	clusterChanges := inProgressClusterChanges(m.state)
 
+	subclusters := cluster.Subclusters()
 	for name, tasks := range tasksets {
 		ref := clusterChangeRef{ClusterID: cluster.ClusterID(), Subcluster: name}
 
 		// if we already have a change going on for this cluster id/subcluster
 		// pair, do not create another one
-		if clusterChanges[ref] {
+		if clusterChanges[ref] != nil {
 			continue
 		}
 
 		chg := m.state.NewChange(applyClusterSubclusterChangeKind, fmt.Sprintf("Apply subcluster %q state", name))
 		chg.Set("cluster-change-ref", ref)
 
+		// devices of a subcluster coordinating its rollouts wait for their
+		// turn before touching any snap
+		if i := subclusterIndex(subclusters, name); i >= 0 && coordinatesRollout(subclusters[i]) {
+			wait := m.state.NewTask("wait-cluster-rollout", fmt.Sprintf("Wait for the turn of this device in the rollout of subcluster %q", name))
+			wait.Set("subcluster", name)
+			tasks.WaitFor(wait)
+			chg.AddTask(wait)
+		}
+
 		chg.AddAll(tasks)
 	}
 
@@ -99,8 +132,8 @@ type clusterChangeRef struct {
 	Subcluster string `json:"subcluster"`
 }
 
-func inProgressClusterChanges(st *state.State) map[clusterChangeRef]bool {
-	changes := make(map[clusterChangeRef]bool)
+func inProgressClusterChanges(st *state.State) map[clusterChangeRef]*state.Change {
+	changes := make(map[clusterChangeRef]*state.Change)
 	for _, chg := range st.Changes() {
 		if chg.Kind() != applyClusterSubclusterChangeKind || chg.Status().Ready() {
 			continue
@@ -111,15 +144,80 @@ func inProgressClusterChanges(st *state.State) map[clusterChangeRef]bool {
 			continue // this should never happen
 		}
 
-		changes[ref] = true
+		changes[ref] = chg
 	}
 
 	return changes
 }
 
+// Stop implements StateStopper. It stops answering cluster rollout queries.
+func (m *ClusterManager) Stop() {
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+
+	if m.rollout != nil {
+		m.rollout.stop()
+		m.rollout = nil
+	}
+}
+
 func clusteringEnabled(st *state.State) (bool, error) {
 	st.Lock()
 	defer st.Unlock()
 	tr := config.NewTransaction(st)
 	return features.Flag(tr, features.Clustering)
 }
+
+// checkClusteringEnabled returns an error if the clustering experimental
+// feature is disabled. Callers must hold the state lock.
+func checkClusteringEnabled(st *state.State) error {
+	tr := config.NewTransaction(st)
+	enabled, err := features.Flag(tr, features.Clustering)
+	if err != nil {
+		return err
+	}
+	if !enabled {
+		return errors.New("experimental feature disabled - test it by setting 'experimental.clustering' to true")
+	}
+	return nil
+}
+
+func (m *ClusterManager) doSetClusterSnapState(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var name string
+	var snapState asserts.ClusterSnapState
+	if err := t.Get("snap-name", &name); err != nil {
+		return err
+	}
+	if err := t.Get("cluster-snap-state", &snapState); err != nil {
+		return err
+	}
+
+	evacuated, err := evacuatedSnaps(st)
+	if err != nil {
+		return err
+	}
+	t.Set("old-evacuated", evacuated[name])
+
+	return setSnapEvacuated(st, name, snapState == asserts.ClusterSnapStateEvacuated)
+}
+
+func (m *ClusterManager) undoSetClusterSnapState(t *state.Task, _ *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var name string
+	var oldEvacuated bool
+	if err := t.Get("snap-name", &name); err != nil {
+		return err
+	}
+	if err := t.Get("old-evacuated", &oldEvacuated); err != nil {
+		return err
+	}
+
+	return setSnapEvacuated(st, name, oldEvacuated)
+}
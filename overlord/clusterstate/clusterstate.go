@@ -24,13 +24,17 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"sort"
 	"strconv"
 
 	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 var (
@@ -39,6 +43,14 @@ var (
 	updateWithGoal   = snapstate.UpdateWithGoal
 	storeUpdateGoal  = snapstate.StoreUpdateGoal
 	storeInstallGoal = snapstate.StoreInstallGoal
+	currentInfo      = snapstate.CurrentInfo
+	serviceControl   = servicestate.Control
+
+	// automaticSnapshot is hooked by snapshotstate into snapstate, so it
+	// cannot be assigned here directly
+	automaticSnapshot = func(st *state.State, instanceName string) (*state.TaskSet, error) {
+		return snapstate.AutomaticSnapshot(st, instanceName)
+	}
 )
 
 // ErrNoClusterAssertion indicates there is no current cluster assertion available.
@@ -53,6 +65,10 @@ type clusterState struct {
 	// assertion. Maybe we should consider some sort of sequence container, like
 	// we use in snapstate?
 	Current clusterAssertionState `json:"current"`
+	// LastDeviceID is the highest id of the devices that were ever part of
+	// the cluster, as known to this device. The ids of removed devices are
+	// not given to devices joining later on.
+	LastDeviceID int `json:"last-device-id,omitempty"`
 }
 
 // clusterAssertionState contains the information needed to find a specific
@@ -102,6 +118,7 @@ func InitializeNewCluster(st *state.State, bundle io.Reader) error {
 			Sequence:    cluster.Sequence(),
 			AuthorityID: cluster.AuthorityID(),
 		},
+		LastDeviceID: highestDeviceID(cluster, 0),
 	})
 
 	// trigger an ensure pass so that the new assertion is picked up and applied
@@ -160,6 +177,7 @@ func UpdateCluster(st *state.State, bundle io.Reader) error {
 			Sequence:    cluster.Sequence(),
 			AuthorityID: cluster.AuthorityID(),
 		},
+		LastDeviceID: highestDeviceID(cluster, cs.LastDeviceID),
 	})
 
 	// trigger an ensure pass so that the new assertion is picked up and applied
@@ -168,6 +186,17 @@ func UpdateCluster(st *state.State, bundle io.Reader) error {
 	return nil
 }
 
+// highestDeviceID returns the highest of the given device id and the ids of
+// the devices of the cluster.
+func highestDeviceID(cluster *asserts.Cluster, id int) int {
+	for _, dev := range cluster.Devices() {
+		if dev.ID > id {
+			id = dev.ID
+		}
+	}
+	return id
+}
+
 // CurrentCluster returns the currently tracked cluster assertion. Callers must
 // hold the state lock.
 func CurrentCluster(st *state.State) (*asserts.Cluster, error) {
@@ -266,13 +295,13 @@ func applyClusterState(st *state.State, cluster *asserts.Cluster) (map[string]*s
 }
 
 func applySubcluster(st *state.State, subcluster asserts.Subcluster) (*state.TaskSet, error) {
-	installs, removals, updates, err := snapsForSubcluster(st, subcluster)
+	installs, removals, updates, evacuations, restorations, err := snapsForSubcluster(st, subcluster)
 	if err != nil {
 		return nil, err
 	}
 
 	combined := state.NewTaskSet()
-	if len(installs) == 0 && len(removals) == 0 && len(updates) == 0 {
+	if len(installs) == 0 && len(removals) == 0 && len(updates) == 0 && len(evacuations) == 0 && len(restorations) == 0 {
 		return combined, nil
 	}
 
@@ -317,26 +346,143 @@ func applySubcluster(st *state.State, subcluster asserts.Subcluster) (*state.Tas
 		appendTaskSets(installTS)
 	}
 
+	for _, name := range evacuations {
+		tss, err := evacuateSnap(st, name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create snap evacuation tasks: %w", err)
+		}
+		appendTaskSets(tss)
+	}
+
+	for _, name := range restorations {
+		tss, err := restoreSnap(st, name)
+		if err != nil {
+			return nil, fmt.Errorf("cannot create snap restoration tasks: %w", err)
+		}
+		appendTaskSets(tss)
+	}
+
 	return combined, nil
 }
 
+// evacuateSnap creates the tasks to stop and disable the services of the
+// snap, keeping its data and revision, and to take an automatic snapshot of
+// its data, before recording the snap as evacuated.
+func evacuateSnap(st *state.State, name string) ([]*state.TaskSet, error) {
+	var tss []*state.TaskSet
+
+	svcs, err := snapServices(st, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(svcs) > 0 {
+		inst := &servicestate.Instruction{
+			Action:      "stop",
+			StopOptions: client.StopOptions{Disable: true},
+		}
+		stopTSs, err := serviceControl(st, svcs, inst, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		tss = append(tss, stopTSs...)
+	}
+
+	snapshotTS, err := automaticSnapshot(st, name)
+	if err != nil && !errors.Is(err, snapstate.ErrNothingToDo) {
+		return nil, err
+	}
+	if snapshotTS != nil {
+		tss = append(tss, snapshotTS)
+	}
+
+	tss = append(tss, setClusterSnapStateTaskSet(st, name, asserts.ClusterSnapStateEvacuated))
+	chainTaskSets(tss)
+	return tss, nil
+}
+
+// restoreSnap creates the tasks to enable and start again the services of an
+// evacuated snap, before recording the snap as clustered.
+func restoreSnap(st *state.State, name string) ([]*state.TaskSet, error) {
+	var tss []*state.TaskSet
+
+	svcs, err := snapServices(st, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(svcs) > 0 {
+		inst := &servicestate.Instruction{
+			Action:       "start",
+			StartOptions: client.StartOptions{Enable: true},
+		}
+		startTSs, err := serviceControl(st, svcs, inst, nil, nil, nil)
+		if err != nil {
+			return nil, err
+		}
+		tss = append(tss, startTSs...)
+	}
+
+	tss = append(tss, setClusterSnapStateTaskSet(st, name, asserts.ClusterSnapStateClustered))
+	chainTaskSets(tss)
+	return tss, nil
+}
+
+func snapServices(st *state.State, name string) ([]*snap.AppInfo, error) {
+	info, err := currentInfo(st, name)
+	if err != nil {
+		return nil, err
+	}
+	return info.Services(), nil
+}
+
+func setClusterSnapStateTaskSet(st *state.State, name string, snapState asserts.ClusterSnapState) *state.TaskSet {
+	var summary string
+	if snapState == asserts.ClusterSnapStateEvacuated {
+		summary = fmt.Sprintf("Mark snap %q as evacuated from the cluster", name)
+	} else {
+		summary = fmt.Sprintf("Mark snap %q as clustered", name)
+	}
+	t := st.NewTask("set-cluster-snap-state", summary)
+	t.Set("snap-name", name)
+	t.Set("cluster-snap-state", snapState)
+	return state.NewTaskSet(t)
+}
+
+// chainTaskSets makes each task set wait for the previous one.
+func chainTaskSets(tss []*state.TaskSet) {
+	for i := 1; i < len(tss); i++ {
+		tss[i].WaitAll(tss[i-1])
+	}
+}
+
 func snapsForSubcluster(
 	st *state.State, subcluster asserts.Subcluster,
 ) (
 	installs []snapstate.StoreSnap,
 	removals []string,
 	updates []snapstate.StoreUpdate,
+	evacuations []string,
+	restorations []string,
 	err error,
 ) {
+	evacuated, err := evacuatedSnaps(st)
+	if err != nil {
+		return nil, nil, nil, nil, nil, err
+	}
+
 	for _, sn := range subcluster.Snaps {
 		var snapst snapstate.SnapState
 		if err := snapstate.Get(st, sn.Instance, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
-			return nil, nil, nil, err
+			return nil, nil, nil, nil, nil, err
 		}
 
-		// TODO: handle [asserts.ClusterSnapStateEvacuated]
 		switch sn.State {
 		case asserts.ClusterSnapStateClustered:
+			if snapst.IsInstalled() && evacuated[sn.Instance] {
+				// channel changes are applied once the snap is
+				// clustered again
+				restorations = append(restorations, sn.Instance)
+				continue
+			}
 			if snapst.IsInstalled() {
 				if sn.Channel != "" && snapst.TrackingChannel != sn.Channel {
 					updates = append(updates, snapstate.StoreUpdate{
@@ -363,10 +509,74 @@ func snapsForSubcluster(
 				continue
 			}
 			removals = append(removals, sn.Instance)
+		case asserts.ClusterSnapStateEvacuated:
+			// the revision and data of the snap are kept, an
+			// evacuated snap that is not installed stays so
+			if !snapst.IsInstalled() || evacuated[sn.Instance] {
+				continue
+			}
+			evacuations = append(evacuations, sn.Instance)
+		}
+	}
+
+	return installs, removals, updates, evacuations, restorations, nil
+}
+
+// EvacuatedSnaps returns the names of the installed snaps evacuated from
+// this device following the cluster assertion. Callers must hold the state
+// lock.
+func EvacuatedSnaps(st *state.State) ([]string, error) {
+	evacuated, err := evacuatedSnaps(st)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(evacuated))
+	for name := range evacuated {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// evacuatedSnaps returns the set of installed snaps marked as evacuated.
+func evacuatedSnaps(st *state.State) (map[string]bool, error) {
+	var marked map[string]bool
+	if err := st.Get("cluster-evacuated-snaps", &marked); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	evacuated := make(map[string]bool, len(marked))
+	for name := range marked {
+		var snapst snapstate.SnapState
+		if err := snapstate.Get(st, name, &snapst); err != nil && !errors.Is(err, state.ErrNoState) {
+			return nil, err
+		}
+		// snaps removed since are no longer evacuated
+		if snapst.IsInstalled() {
+			evacuated[name] = true
 		}
 	}
+	return evacuated, nil
+}
 
-	return installs, removals, updates, nil
+func setSnapEvacuated(st *state.State, name string, evacuated bool) error {
+	var marked map[string]bool
+	if err := st.Get("cluster-evacuated-snaps", &marked); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if marked == nil {
+		marked = make(map[string]bool)
+	}
+	if evacuated {
+		marked[name] = true
+	} else {
+		delete(marked, name)
+	}
+	if len(marked) == 0 {
+		st.Set("cluster-evacuated-snaps", nil)
+	} else {
+		st.Set("cluster-evacuated-snaps", marked)
+	}
+	return nil
 }
 
 func deviceInSubcluster(subcluster asserts.Subcluster, deviceID int) bool {
@@ -23,17 +23,23 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"os/user"
 	"strconv"
 	"testing"
 	"time"
 
+	"gopkg.in/tomb.v2"
+
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/auth"
 	"github.com/snapcore/snapd/overlord/clusterstate"
 	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/snapstate/sequence"
 	"github.com/snapcore/snapd/overlord/state"
@@ -378,7 +384,7 @@ func (s *managerSuite) TestEnsureIdempotent(c *check.C) {
 
 	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
 	c.Assert(err, check.IsNil)
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	st.Unlock()
 	defer st.Lock()
@@ -406,7 +412,7 @@ func (s *managerSuite) TestEnsureClusteringDisabled(c *check.C) {
 	st.Unlock()
 	defer st.Lock()
 
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	err = mgr.Ensure()
 	c.Assert(err, check.IsNil)
@@ -457,7 +463,7 @@ func (s *managerSuite) TestApplyClusterStateNoActions(c *check.C) {
 
 	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
 	c.Assert(err, check.IsNil)
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	st.Unlock()
 	defer st.Lock()
@@ -524,7 +530,7 @@ func (s *managerSuite) TestApplyClusterStateDeviceNotInAnySubcluster(c *check.C)
 	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
 	c.Assert(err, check.IsNil)
 
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	st.Unlock()
 	defer st.Lock()
@@ -651,7 +657,7 @@ func (s *managerSuite) TestApplyClusterStateInstallRemoveAndUpdate(c *check.C) {
 
 	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
 	c.Assert(err, check.IsNil)
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	st.Unlock()
 	defer st.Lock()
@@ -761,7 +767,7 @@ func (s *managerSuite) TestApplyClusterStateMultipleSubclusters(c *check.C) {
 
 	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
 	c.Assert(err, check.IsNil)
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	st.Unlock()
 	defer st.Lock()
@@ -816,7 +822,7 @@ func (s *managerSuite) TestApplyClusterStateDeviceMissing(c *check.C) {
 
 	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
 	c.Assert(err, check.IsNil)
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	st.Unlock()
 	defer st.Lock()
@@ -831,7 +837,7 @@ func (s *managerSuite) TestApplyClusterStateDeviceMissing(c *check.C) {
 func (s *managerSuite) TestApplyClusterStateNoClusterData(c *check.C) {
 	st, _ := newStateWithStoreStack(c)
 
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	c.Assert(mgr.Ensure(), check.IsNil)
 
@@ -927,7 +933,7 @@ func (s *managerSuite) TestApplyClusterStateSkipsExistingChange(c *check.C) {
 
 	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
 	c.Assert(err, check.IsNil)
-	mgr := clusterstate.Manager(st)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
 
 	st.Unlock()
 	defer st.Lock()
@@ -950,6 +956,297 @@ func (s *managerSuite) TestApplyClusterStateSkipsExistingChange(c *check.C) {
 	}
 }
 
+func setInstalled(st *state.State, name string) {
+	snapstate.Set(st, name, &snapstate.SnapState{
+		Active:          true,
+		Current:         snap.R(1),
+		TrackingChannel: "latest/stable",
+		Sequence: sequence.SnapSequence{
+			Revisions: []*sequence.RevisionSideState{
+				sequence.NewRevisionSideState(&snap.SideInfo{RealName: name, Revision: snap.R(1)}, nil),
+			},
+		},
+	})
+}
+
+// mockEvacuationHelpers mocks the services of snaps and taking snapshots,
+// recording the service instructions.
+func mockEvacuationHelpers(c *check.C, instructions *[]servicestate.Instruction) (restore func()) {
+	r1 := clusterstate.MockCurrentInfo(func(st *state.State, name string) (*snap.Info, error) {
+		info := &snap.Info{SideInfo: snap.SideInfo{RealName: name}}
+		info.Apps = map[string]*snap.AppInfo{
+			"svc": {Snap: info, Name: "svc", Daemon: "simple", DaemonScope: snap.SystemDaemon},
+			"cmd": {Snap: info, Name: "cmd"},
+		}
+		return info, nil
+	})
+	r2 := clusterstate.MockServiceControl(func(st *state.State, apps []*snap.AppInfo, inst *servicestate.Instruction, _ *user.User, _ *servicestate.Flags, _ *hookstate.Context) ([]*state.TaskSet, error) {
+		c.Assert(apps, check.HasLen, 1)
+		c.Check(apps[0].Name, check.Equals, "svc")
+		*instructions = append(*instructions, *inst)
+		return []*state.TaskSet{state.NewTaskSet(st.NewTask("service-control", inst.Action))}, nil
+	})
+	r3 := clusterstate.MockAutomaticSnapshot(func(st *state.State, name string) (*state.TaskSet, error) {
+		return state.NewTaskSet(st.NewTask("save-snapshot", "snapshot "+name)), nil
+	})
+	return func() {
+		r3()
+		r2()
+		r1()
+	}
+}
+
+func taskKinds(tasks []*state.Task) []string {
+	kinds := make([]string, 0, len(tasks))
+	for _, t := range tasks {
+		kinds = append(kinds, t.Kind())
+	}
+	return kinds
+}
+
+func (s *managerSuite) TestApplyClusterStateEvacuate(c *check.C) {
+	st, stack := newStateWithStoreStack(c)
+
+	st.Lock()
+	defer st.Unlock()
+
+	setInstalled(st, "to-evacuate")
+	setInstalled(st, "already-evacuated")
+	st.Set("cluster-evacuated-snaps", map[string]bool{"already-evacuated": true})
+
+	bundle, _ := makeClusterBundle(c, stack, []map[string]any{
+		{
+			"id":        "1",
+			"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.10"},
+		},
+	}, []map[string]any{{
+		"name":    "default",
+		"devices": []any{"1"},
+		"snaps": []any{
+			map[string]any{
+				"state":    "evacuated",
+				"instance": "to-evacuate",
+				"channel":  "latest/stable",
+			},
+			map[string]any{
+				"state":    "evacuated",
+				"instance": "already-evacuated",
+				"channel":  "latest/stable",
+			},
+			map[string]any{
+				"state":    "evacuated",
+				"instance": "not-installed",
+				"channel":  "latest/stable",
+			},
+		},
+	}})
+
+	serial := makeSerialAssertion(c, stack, "serial-1")
+	addSerialToState(c, st, serial)
+
+	var instructions []servicestate.Instruction
+	restore := mockEvacuationHelpers(c, &instructions)
+	defer restore()
+
+	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
+	c.Assert(err, check.IsNil)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	c.Assert(instructions, check.DeepEquals, []servicestate.Instruction{{
+		Action:      "stop",
+		StopOptions: client.StopOptions{Disable: true},
+	}})
+
+	changes := st.Changes()
+	c.Assert(changes, check.HasLen, 1)
+	tasks := changes[0].Tasks()
+	c.Assert(taskKinds(tasks), check.DeepEquals, []string{"service-control", "save-snapshot", "set-cluster-snap-state"})
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+	c.Check(tasks[2].WaitTasks(), check.DeepEquals, []*state.Task{tasks[1]})
+	c.Check(tasks[2].Summary(), check.Equals, `Mark snap "to-evacuate" as evacuated from the cluster`)
+
+	var name string
+	c.Assert(tasks[2].Get("snap-name", &name), check.IsNil)
+	c.Check(name, check.Equals, "to-evacuate")
+}
+
+func (s *managerSuite) TestApplyClusterStateEvacuateNoSnapshot(c *check.C) {
+	st, stack := newStateWithStoreStack(c)
+
+	st.Lock()
+	defer st.Unlock()
+
+	setInstalled(st, "to-evacuate")
+
+	bundle, _ := makeClusterBundle(c, stack, []map[string]any{
+		{
+			"id":        "1",
+			"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.10"},
+		},
+	}, []map[string]any{{
+		"name":    "default",
+		"devices": []any{"1"},
+		"snaps": []any{
+			map[string]any{
+				"state":    "evacuated",
+				"instance": "to-evacuate",
+				"channel":  "latest/stable",
+			},
+		},
+	}})
+
+	serial := makeSerialAssertion(c, stack, "serial-1")
+	addSerialToState(c, st, serial)
+
+	var instructions []servicestate.Instruction
+	restore := mockEvacuationHelpers(c, &instructions)
+	defer restore()
+	// automatic snapshots are disabled
+	restore = clusterstate.MockAutomaticSnapshot(func(st *state.State, name string) (*state.TaskSet, error) {
+		return nil, snapstate.ErrNothingToDo
+	})
+	defer restore()
+
+	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
+	c.Assert(err, check.IsNil)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	changes := st.Changes()
+	c.Assert(changes, check.HasLen, 1)
+	c.Check(taskKinds(changes[0].Tasks()), check.DeepEquals, []string{"service-control", "set-cluster-snap-state"})
+}
+
+func (s *managerSuite) TestApplyClusterStateRestoreEvacuated(c *check.C) {
+	st, stack := newStateWithStoreStack(c)
+
+	st.Lock()
+	defer st.Unlock()
+
+	setInstalled(st, "evacuated")
+	st.Set("cluster-evacuated-snaps", map[string]bool{"evacuated": true})
+
+	bundle, _ := makeClusterBundle(c, stack, []map[string]any{
+		{
+			"id":        "1",
+			"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.10"},
+		},
+	}, []map[string]any{{
+		"name":    "default",
+		"devices": []any{"1"},
+		"snaps": []any{
+			map[string]any{
+				"state":    "clustered",
+				"instance": "evacuated",
+				// the channel change waits for the snap to be restored
+				"channel": "latest/edge",
+			},
+		},
+	}})
+
+	serial := makeSerialAssertion(c, stack, "serial-1")
+	addSerialToState(c, st, serial)
+
+	var instructions []servicestate.Instruction
+	restore := mockEvacuationHelpers(c, &instructions)
+	defer restore()
+	restore = clusterstate.MockSnapstateUpdateWithGoal(func(context.Context, *state.State, snapstate.UpdateGoal, func(*snap.Info, *snapstate.SnapState) bool, snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		c.Fatal("unexpected update")
+		return nil, nil, errors.New("unexpected")
+	})
+	defer restore()
+
+	err := clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle))
+	c.Assert(err, check.IsNil)
+	mgr := clusterstate.Manager(st, state.NewTaskRunner(st), nil)
+
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+
+	c.Assert(instructions, check.DeepEquals, []servicestate.Instruction{{
+		Action:       "start",
+		StartOptions: client.StartOptions{Enable: true},
+	}})
+
+	changes := st.Changes()
+	c.Assert(changes, check.HasLen, 1)
+	tasks := changes[0].Tasks()
+	c.Assert(taskKinds(tasks), check.DeepEquals, []string{"service-control", "set-cluster-snap-state"})
+	c.Check(tasks[1].Summary(), check.Equals, `Mark snap "evacuated" as clustered`)
+}
+
+func (s *managerSuite) TestSetClusterSnapState(c *check.C) {
+	st, _ := newStateWithStoreStack(c)
+	runner := state.NewTaskRunner(st)
+	clusterstate.Manager(st, runner, nil)
+
+	st.Lock()
+	setInstalled(st, "foo")
+	setInstalled(st, "bar")
+	// snaps removed since being evacuated are not reported
+	st.Set("cluster-evacuated-snaps", map[string]bool{"bar": true, "removed": true})
+
+	evacuated, err := clusterstate.EvacuatedSnaps(st)
+	c.Assert(err, check.IsNil)
+	c.Check(evacuated, check.DeepEquals, []string{"bar"})
+
+	chg := st.NewChange("evacuate", "...")
+	t := st.NewTask("set-cluster-snap-state", "...")
+	t.Set("snap-name", "foo")
+	t.Set("cluster-snap-state", asserts.ClusterSnapStateEvacuated)
+	chg.AddTask(t)
+	st.Unlock()
+
+	for i := 0; i < 3; i++ {
+		runner.Ensure()
+		runner.Wait()
+	}
+
+	st.Lock()
+	c.Assert(chg.Status(), check.Equals, state.DoneStatus)
+	evacuated, err = clusterstate.EvacuatedSnaps(st)
+	c.Assert(err, check.IsNil)
+	c.Check(evacuated, check.DeepEquals, []string{"bar", "foo"})
+
+	// restoring is undone when the change fails
+	runner.AddHandler("error-trigger", func(t *state.Task, _ *tomb.Tomb) error {
+		return errors.New("boom")
+	}, nil)
+	chg = st.NewChange("restore", "...")
+	t = st.NewTask("set-cluster-snap-state", "...")
+	t.Set("snap-name", "bar")
+	t.Set("cluster-snap-state", asserts.ClusterSnapStateClustered)
+	chg.AddTask(t)
+	errTask := st.NewTask("error-trigger", "...")
+	errTask.WaitFor(t)
+	chg.AddTask(errTask)
+	st.Unlock()
+
+	for i := 0; i < 5; i++ {
+		runner.Ensure()
+		runner.Wait()
+	}
+
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(chg.Status(), check.Equals, state.ErrorStatus)
+	c.Check(t.Status(), check.Equals, state.UndoneStatus)
+	evacuated, err = clusterstate.EvacuatedSnaps(st)
+	c.Assert(err, check.IsNil)
+	c.Check(evacuated, check.DeepEquals, []string{"bar", "foo"})
+}
+
 func registerAccount(stack *assertstest.StoreStack, accountID string) *assertstest.SigningAccounts {
 	sa := assertstest.NewSigningAccounts(stack)
 
@@ -1154,7 +1451,12 @@ func makeBundleWithUntrustedBrand(c *check.C) []byte {
 
 func newStateWithStoreStack(c *check.C) (*state.State, *assertstest.StoreStack) {
 	signing := assertstest.NewStoreStack("canonical", nil)
+	return newStateTrusting(c, signing), signing
+}
 
+// newStateTrusting returns a state with clustering enabled and an assertion
+// database trusting the given store stack.
+func newStateTrusting(c *check.C, signing *assertstest.StoreStack) *state.State {
 	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
 		Backstore: asserts.NewMemoryBackstore(),
 		Trusted:   signing.Trusted,
@@ -1176,5 +1478,5 @@ func newStateWithStoreStack(c *check.C) (*state.State, *assertstest.StoreStack)
 	c.Assert(err, check.IsNil)
 	tr.Commit()
 
-	return st, signing
+	return st
 }
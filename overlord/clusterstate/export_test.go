@@ -21,7 +21,15 @@ package clusterstate
 
 import (
 	"context"
+	"crypto/tls"
+	"net"
+	"os/user"
+	"time"
 
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -57,3 +65,79 @@ func MockStoreInstallGoal(f func(...snapstate.StoreSnap) snapstate.InstallGoal)
 	storeInstallGoal = f
 	return restore
 }
+
+func MockCurrentInfo(f func(*state.State, string) (*snap.Info, error)) func() {
+	restore := testutil.Backup(&currentInfo)
+	currentInfo = f
+	return restore
+}
+
+func MockServiceControl(f func(*state.State, []*snap.AppInfo, *servicestate.Instruction, *user.User, *servicestate.Flags, *hookstate.Context) ([]*state.TaskSet, error)) func() {
+	restore := testutil.Backup(&serviceControl)
+	serviceControl = f
+	return restore
+}
+
+func MockAutomaticSnapshot(f func(*state.State, string) (*state.TaskSet, error)) func() {
+	restore := testutil.Backup(&automaticSnapshot)
+	automaticSnapshot = f
+	return restore
+}
+
+type (
+	AssembleSetup = assembleSetup
+	Assembler     = assembler
+)
+
+func MockNewAssembleState(f func(assemblestate.AssembleConfig, assemblestate.AssembleSession, func(assemblestate.DeviceToken, func(assemblestate.DeviceToken) bool) (assemblestate.RouteSelector, error), func(assemblestate.AssembleSession), asserts.RODatabase) (Assembler, error)) func() {
+	restore := testutil.Backup(&newAssembleState)
+	newAssembleState = f
+	return restore
+}
+
+func MockAssertionDevices(f func([]assemblestate.Identity, assemblestate.Routes) ([]any, error)) func() {
+	restore := testutil.Backup(&assertionDevices)
+	assertionDevices = f
+	return restore
+}
+
+func MockNetListen(f func(network, address string) (net.Listener, error)) func() {
+	restore := testutil.Backup(&netListen)
+	netListen = f
+	return restore
+}
+
+func MockAssemblePeriod(d time.Duration) func() {
+	restore := testutil.Backup(&assemblePeriod)
+	assemblePeriod = d
+	return restore
+}
+
+func MockDiscoverPeers(f func(ctx context.Context, address string, discoveries chan<- []string) error) func() {
+	restore := testutil.Backup(&discoverPeers)
+	discoverPeers = f
+	return restore
+}
+
+type (
+	RolloutQuery        = rolloutQuery
+	SignedRolloutQuery  = signedRolloutQuery
+	SignedRolloutReport = signedRolloutReport
+)
+
+const (
+	RolloutQuerySigningContext  = rolloutQuerySigningContext
+	RolloutReportSigningContext = rolloutReportSigningContext
+)
+
+var (
+	DevicesAhead        = devicesAhead
+	GenerateCertificate = generateCertificate
+	FetchRolloutReport  = httpsFetchRolloutReport
+)
+
+func MockFetchRolloutReport(f func(ctx context.Context, addr string, cert tls.Certificate, query *SignedRolloutQuery) (*SignedRolloutReport, error)) func() {
+	restore := testutil.Backup(&fetchRolloutReport)
+	fetchRolloutReport = f
+	return restore
+}
@@ -0,0 +1,338 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/randutil"
+)
+
+// JoinRequest is created by a device asking to be admitted into an existing
+// cluster. It is handed to one of the devices of the cluster, which verifies
+// it with [AddDevice].
+type JoinRequest struct {
+	// Identity authenticates the device in the same way as during assembly:
+	// its serial proof is the device key signature of the HMAC calculated
+	// with the secret shared with the cluster. Join requests are not
+	// exchanged over an assembly session, so there is no TLS certificate
+	// fingerprint to bind and the fingerprint is left empty.
+	Identity assemblestate.Identity `json:"identity"`
+	// Address is the address at which the device can be reached by the
+	// other devices of the cluster.
+	Address string `json:"address"`
+}
+
+// NewJoinRequest creates a request for this device to join an existing
+// cluster, authenticated with the given secret that must also be provided to
+// [AddDevice]. Callers must hold the state lock.
+func NewJoinRequest(st *state.State, signer DeviceKeySigner, secret, address string) (*JoinRequest, error) {
+	if err := checkClusteringEnabled(st); err != nil {
+		return nil, err
+	}
+
+	if secret == "" {
+		return nil, errors.New("cannot create join request: secret must be provided")
+	}
+	if err := validateAddress(address); err != nil {
+		return nil, fmt.Errorf("cannot create join request: %v", err)
+	}
+
+	cluster, err := CurrentCluster(st)
+	if err != nil && !errors.Is(err, ErrNoClusterAssertion) {
+		return nil, err
+	}
+	if cluster != nil {
+		return nil, fmt.Errorf("cannot create join request: device is already part of cluster %q", cluster.ClusterID())
+	}
+
+	serial, err := devicestate.Serial(st)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create join request without a serial assertion: %w", err)
+	}
+
+	rdt, err := randutil.CryptoToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	id, err := assemblestate.NewIdentity(
+		assemblestate.DeviceToken(rdt), assemblestate.Fingerprint{}, secret,
+		serial, assertstate.DB(st), signer.SignWithDeviceKey,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create join request: %v", err)
+	}
+
+	return &JoinRequest{
+		Identity: id,
+		Address:  address,
+	}, nil
+}
+
+// AddDeviceOptions carries the parameters for admitting a device into the
+// current cluster.
+type AddDeviceOptions struct {
+	// Secret is the secret the join request was created with.
+	Secret string
+	// Request is the join request created by the device.
+	Request JoinRequest
+	// Subclusters optionally lists the subclusters the device is added to.
+	Subclusters []string
+}
+
+// AddDevice verifies the join request of a device and returns the headers of
+// the next cluster assertion, which adds the device to the current cluster,
+// ready to be signed. Callers must hold the state lock.
+func AddDevice(st *state.State, opts AddDeviceOptions) (map[string]any, error) {
+	if err := checkClusteringEnabled(st); err != nil {
+		return nil, err
+	}
+
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		return nil, fmt.Errorf("cannot add device to cluster: %w", err)
+	}
+
+	if err := validateAddress(opts.Request.Address); err != nil {
+		return nil, fmt.Errorf("cannot add device to cluster: %v", err)
+	}
+
+	serial, err := assemblestate.VerifyIdentity(opts.Request.Identity, opts.Secret, assertstate.DB(st))
+	if err != nil {
+		return nil, fmt.Errorf("cannot add device to cluster: %v", err)
+	}
+
+	devices := cluster.Devices()
+	for _, dev := range devices {
+		if dev.DeviceID == serial.DeviceID() {
+			return nil, fmt.Errorf("cannot add device to cluster: device %q is already part of cluster %q", serial.DeviceID(), cluster.ClusterID())
+		}
+		for _, addr := range dev.Addresses {
+			if sameAddress(addr, opts.Request.Address) {
+				return nil, fmt.Errorf("cannot add device to cluster: address %q is already used by device %d", opts.Request.Address, dev.ID)
+			}
+		}
+	}
+
+	// ids are never reused, so that the id of a removed device cannot be
+	// mistaken for the one of a device joining later on
+	var cs clusterState
+	if err := st.Get("cluster", &cs); err != nil {
+		return nil, err
+	}
+	nextID := highestDeviceID(cluster, cs.LastDeviceID) + 1
+
+	subclusters := cloneSubclusters(cluster.Subclusters())
+	for _, name := range opts.Subclusters {
+		i := subclusterIndex(subclusters, name)
+		if i < 0 {
+			return nil, fmt.Errorf("cannot add device to cluster: unknown subcluster %q", name)
+		}
+		if !deviceInSubcluster(subclusters[i], nextID) {
+			subclusters[i].Devices = append(subclusters[i].Devices, nextID)
+		}
+	}
+
+	devices = append(devices[:len(devices):len(devices)], asserts.ClusterDevice{
+		ID:        nextID,
+		Addresses: []string{opts.Request.Address},
+		DeviceID:  serial.DeviceID(),
+	})
+
+	return nextClusterHeaders(cluster, devices, subclusters), nil
+}
+
+// RemoveDeviceOptions carries the parameters for removing a device from the
+// current cluster.
+type RemoveDeviceOptions struct {
+	// Device is the id of the device to remove.
+	Device int
+	// Replacement is the optional id of a device that takes over the
+	// subclusters of the removed device, and thus their snaps.
+	Replacement int
+}
+
+// RemoveDevice returns the headers of the next cluster assertion, which
+// removes a device from the current cluster, ready to be signed. The removed
+// device leaves all of its subclusters. A subcluster cannot be left without
+// devices, as its snaps would not run anywhere anymore: the subclusters of
+// the removed device are moved to the replacement device instead, if any.
+// Callers must hold the state lock.
+func RemoveDevice(st *state.State, opts RemoveDeviceOptions) (map[string]any, error) {
+	if err := checkClusteringEnabled(st); err != nil {
+		return nil, err
+	}
+
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		return nil, fmt.Errorf("cannot remove device from cluster: %w", err)
+	}
+
+	if opts.Replacement == opts.Device {
+		return nil, fmt.Errorf("cannot remove device %d from cluster: device cannot replace itself", opts.Device)
+	}
+
+	var devices []asserts.ClusterDevice
+	found, replacementFound := false, false
+	for _, dev := range cluster.Devices() {
+		switch dev.ID {
+		case opts.Device:
+			found = true
+			continue
+		case opts.Replacement:
+			replacementFound = true
+		}
+		devices = append(devices, dev)
+	}
+	if !found {
+		return nil, fmt.Errorf("cannot remove device %d from cluster: device not found in cluster %q", opts.Device, cluster.ClusterID())
+	}
+	if opts.Replacement != 0 && !replacementFound {
+		return nil, fmt.Errorf("cannot remove device %d from cluster: replacement device %d not found in cluster %q", opts.Device, opts.Replacement, cluster.ClusterID())
+	}
+	if len(devices) == 0 {
+		return nil, fmt.Errorf("cannot remove device %d from cluster: device is the last one of cluster %q", opts.Device, cluster.ClusterID())
+	}
+
+	subclusters := cloneSubclusters(cluster.Subclusters())
+	for i := range subclusters {
+		sc := &subclusters[i]
+		if !deviceInSubcluster(*sc, opts.Device) {
+			continue
+		}
+
+		remaining := make([]int, 0, len(sc.Devices))
+		for _, id := range sc.Devices {
+			if id != opts.Device {
+				remaining = append(remaining, id)
+			}
+		}
+		if opts.Replacement != 0 && !deviceInSubcluster(*sc, opts.Replacement) {
+			remaining = append(remaining, opts.Replacement)
+		}
+		if len(remaining) == 0 {
+			return nil, fmt.Errorf("cannot remove device %d from cluster: subcluster %q would be left without devices, a replacement device is needed", opts.Device, sc.Name)
+		}
+		sc.Devices = remaining
+	}
+
+	return nextClusterHeaders(cluster, devices, subclusters), nil
+}
+
+// sameAddress returns whether the given addresses, as checked by
+// validateAddress, are the same IP address and port.
+func sameAddress(a, b string) bool {
+	hostA, portA, errA := net.SplitHostPort(a)
+	hostB, portB, errB := net.SplitHostPort(b)
+	if errA != nil || errB != nil {
+		return a == b
+	}
+	ipA, ipB := net.ParseIP(hostA), net.ParseIP(hostB)
+	if ipA == nil || ipB == nil {
+		return a == b
+	}
+	return portA == portB && ipA.Equal(ipB)
+}
+
+func cloneSubclusters(subclusters []asserts.Subcluster) []asserts.Subcluster {
+	cloned := make([]asserts.Subcluster, 0, len(subclusters))
+	for _, sc := range subclusters {
+		sc.Devices = append([]int(nil), sc.Devices...)
+		cloned = append(cloned, sc)
+	}
+	return cloned
+}
+
+func subclusterIndex(subclusters []asserts.Subcluster, name string) int {
+	for i, sc := range subclusters {
+		if sc.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextClusterHeaders returns the headers of the assertion following the given
+// cluster assertion, with the given devices and subclusters.
+func nextClusterHeaders(cluster *asserts.Cluster, devices []asserts.ClusterDevice, subclusters []asserts.Subcluster) map[string]any {
+	devs := make([]any, 0, len(devices))
+	for _, dev := range devices {
+		addrs := make([]any, 0, len(dev.Addresses))
+		for _, addr := range dev.Addresses {
+			addrs = append(addrs, addr)
+		}
+		devs = append(devs, map[string]any{
+			"id":        strconv.Itoa(dev.ID),
+			"device":    dev.DeviceID.String(),
+			"addresses": addrs,
+		})
+	}
+
+	headers := map[string]any{
+		"type":         asserts.ClusterType.Name,
+		"authority-id": cluster.AuthorityID(),
+		"cluster-id":   cluster.ClusterID(),
+		"sequence":     strconv.Itoa(cluster.Sequence() + 1),
+		"devices":      devs,
+		"timestamp":    time.Now().UTC().Format(time.RFC3339),
+	}
+
+	if len(subclusters) == 0 {
+		return headers
+	}
+
+	scs := make([]any, 0, len(subclusters))
+	for _, sc := range subclusters {
+		ids := make([]any, 0, len(sc.Devices))
+		for _, id := range sc.Devices {
+			ids = append(ids, strconv.Itoa(id))
+		}
+		snaps := make([]any, 0, len(sc.Snaps))
+		for _, sn := range sc.Snaps {
+			snaps = append(snaps, map[string]any{
+				"state":    string(sn.State),
+				"instance": sn.Instance,
+				"channel":  sn.Channel,
+			})
+		}
+		subcluster := map[string]any{
+			"name":    sc.Name,
+			"devices": ids,
+			"snaps":   snaps,
+		}
+		if sc.MaxParallel > 0 {
+			subcluster["max-parallel"] = strconv.Itoa(sc.MaxParallel)
+		}
+		scs = append(scs, subcluster)
+	}
+	headers["subclusters"] = scs
+
+	return headers
+}
@@ -0,0 +1,364 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type membershipSuite struct {
+	stack *assertstest.StoreStack
+	sa    *assertstest.SigningAccounts
+	// st is the state of a device of the cluster
+	st *state.State
+}
+
+var _ = check.Suite(&membershipSuite{})
+
+const membershipAccountID = "cluster-brand"
+
+func (s *membershipSuite) SetUpTest(c *check.C) {
+	s.st, s.stack = newStateWithStoreStack(c)
+	s.sa = registerAccount(s.stack, membershipAccountID)
+
+	devices := []map[string]any{
+		{
+			"id":        "1",
+			"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.10:7070"},
+		},
+		{
+			"id":        "2",
+			"device":    "serial-2.ubuntu-core-24-amd64.canonical",
+			"addresses": []any{"192.168.0.20:7070"},
+		},
+	}
+	subclusters := []map[string]any{
+		{
+			"name":    "default",
+			"devices": []any{"1", "2"},
+			"snaps": []any{
+				map[string]any{"state": "clustered", "instance": "shared-snap", "channel": "stable"},
+			},
+		},
+		{
+			"name":    "single",
+			"devices": []any{"2"},
+			"snaps": []any{
+				map[string]any{"state": "clustered", "instance": "single-snap", "channel": "edge"},
+			},
+		},
+	}
+
+	s.st.Lock()
+	defer s.st.Unlock()
+	bundle, _ := makeClusterBundleWithSigning(c, s.sa, membershipAccountID, "cluster-id", 1, devices, subclusters)
+	c.Assert(clusterstate.InitializeNewCluster(s.st, bytes.NewReader(bundle)), check.IsNil)
+}
+
+type keySigner struct {
+	key asserts.PrivateKey
+}
+
+func (k keySigner) SignWithDeviceKey(data []byte) ([]byte, error) {
+	return asserts.RawSignWithKey(data, k.key)
+}
+
+// joiningDevice returns the state of a device that is not part of a cluster
+// yet, along with a signer using its device key.
+func (s *membershipSuite) joiningDevice(c *check.C, serial string) (*state.State, clusterstate.DeviceKeySigner) {
+	deviceKey, _ := assertstest.GenerateKey(752)
+	encodedKey, err := asserts.EncodePublicKey(deviceKey.PublicKey())
+	c.Assert(err, check.IsNil)
+
+	a, err := s.stack.Sign(asserts.SerialType, map[string]any{
+		"authority-id":        "canonical",
+		"brand-id":            "canonical",
+		"model":               "ubuntu-core-24-amd64",
+		"serial":              serial,
+		"device-key":          string(encodedKey),
+		"device-key-sha3-384": deviceKey.PublicKey().ID(),
+		"timestamp":           time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	st := newStateTrusting(c, s.stack)
+	st.Lock()
+	defer st.Unlock()
+	addSerialToState(c, st, a.(*asserts.Serial))
+
+	return st, keySigner{key: deviceKey}
+}
+
+func (s *membershipSuite) joinRequest(c *check.C, serial string) *clusterstate.JoinRequest {
+	st, signer := s.joiningDevice(c, serial)
+	st.Lock()
+	defer st.Unlock()
+
+	req, err := clusterstate.NewJoinRequest(st, signer, "secret", "192.168.0.30:7070")
+	c.Assert(err, check.IsNil)
+	return req
+}
+
+// updateCluster signs the given cluster assertion headers and installs the
+// result as the next cluster assertion of the device of the cluster.
+func (s *membershipSuite) updateCluster(c *check.C, headers map[string]any) *asserts.Cluster {
+	c.Check(headers["authority-id"], check.Equals, membershipAccountID)
+
+	a, err := s.sa.Signing(membershipAccountID).Sign(asserts.ClusterType, headers, nil, "")
+	c.Assert(err, check.IsNil)
+
+	var buf bytes.Buffer
+	c.Assert(asserts.NewEncoder(&buf).Encode(a), check.IsNil)
+	c.Assert(clusterstate.UpdateCluster(s.st, &buf), check.IsNil)
+
+	cluster, err := clusterstate.CurrentCluster(s.st)
+	c.Assert(err, check.IsNil)
+	return cluster
+}
+
+func subclusterDevices(cluster *asserts.Cluster) map[string][]int {
+	devices := make(map[string][]int)
+	for _, sc := range cluster.Subclusters() {
+		devices[sc.Name] = sc.Devices
+	}
+	return devices
+}
+
+func (s *membershipSuite) TestNewJoinRequest(c *check.C) {
+	req := s.joinRequest(c, "serial-3")
+	c.Check(req.Address, check.Equals, "192.168.0.30:7070")
+	c.Check(req.Identity.RDT, check.Not(check.Equals), assemblestate.DeviceToken(""))
+	c.Check(req.Identity.FP, check.Equals, assemblestate.Fingerprint{})
+	c.Check(req.Identity.SerialBundle, check.Matches, "(?s).*serial: serial-3\n.*")
+
+	// requests are carried around as JSON
+	data, err := json.Marshal(req)
+	c.Assert(err, check.IsNil)
+	var decoded clusterstate.JoinRequest
+	c.Assert(json.Unmarshal(data, &decoded), check.IsNil)
+	c.Check(decoded, check.DeepEquals, *req)
+}
+
+func (s *membershipSuite) TestNewJoinRequestErrors(c *check.C) {
+	st, signer := s.joiningDevice(c, "serial-3")
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := clusterstate.NewJoinRequest(st, signer, "", "192.168.0.30:7070")
+	c.Check(err, check.ErrorMatches, "cannot create join request: secret must be provided")
+
+	_, err = clusterstate.NewJoinRequest(st, signer, "secret", "192.168.0.30")
+	c.Check(err, check.ErrorMatches, `cannot create join request: invalid address "192.168.0.30": .*`)
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "experimental.clustering", false), check.IsNil)
+	tr.Commit()
+	_, err = clusterstate.NewJoinRequest(st, signer, "secret", "192.168.0.30:7070")
+	c.Check(err, check.ErrorMatches, `experimental feature disabled - test it by setting 'experimental.clustering' to true`)
+}
+
+func (s *membershipSuite) TestNewJoinRequestNoSerial(c *check.C) {
+	st, _ := newStateWithStoreStack(c)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := clusterstate.NewJoinRequest(st, &fakeSigner{}, "secret", "192.168.0.30:7070")
+	c.Check(err, check.ErrorMatches, "cannot create join request without a serial assertion: .*")
+}
+
+func (s *membershipSuite) TestNewJoinRequestAlreadyInCluster(c *check.C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	_, err := clusterstate.NewJoinRequest(s.st, &fakeSigner{}, "secret", "192.168.0.30:7070")
+	c.Check(err, check.ErrorMatches, `cannot create join request: device is already part of cluster "cluster-id"`)
+}
+
+func (s *membershipSuite) TestAddDevice(c *check.C) {
+	req := s.joinRequest(c, "serial-3")
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	headers, err := clusterstate.AddDevice(s.st, clusterstate.AddDeviceOptions{
+		Secret:      "secret",
+		Request:     *req,
+		Subclusters: []string{"single"},
+	})
+	c.Assert(err, check.IsNil)
+
+	cluster := s.updateCluster(c, headers)
+	c.Check(cluster.ClusterID(), check.Equals, "cluster-id")
+	c.Check(cluster.Sequence(), check.Equals, 2)
+	c.Assert(cluster.Devices(), check.HasLen, 3)
+	dev := cluster.Devices()[2]
+	c.Check(dev.ID, check.Equals, 3)
+	c.Check(dev.Serial, check.Equals, "serial-3")
+	c.Check(dev.Addresses, check.DeepEquals, []string{"192.168.0.30:7070"})
+	c.Check(subclusterDevices(cluster), check.DeepEquals, map[string][]int{
+		"default": {1, 2},
+		"single":  {2, 3},
+	})
+
+	// the snaps of the subclusters are kept
+	c.Check(cluster.Subclusters()[1].Snaps, check.DeepEquals, []asserts.ClusterSnap{
+		{State: asserts.ClusterSnapStateClustered, Instance: "single-snap", Channel: "edge"},
+	})
+}
+
+func (s *membershipSuite) TestAddDeviceErrors(c *check.C) {
+	req := s.joinRequest(c, "serial-3")
+	member := s.joinRequest(c, "serial-1")
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	badAddress := *req
+	badAddress.Address = "host:7070"
+	usedAddress := *req
+	usedAddress.Address = "192.168.0.20:7070"
+
+	for _, tc := range []struct {
+		opts clusterstate.AddDeviceOptions
+		err  string
+	}{
+		{clusterstate.AddDeviceOptions{Secret: "other", Request: *req}, `cannot add device to cluster: serial proof verification failed for device .*`},
+		{clusterstate.AddDeviceOptions{Secret: "secret", Request: badAddress}, `cannot add device to cluster: invalid address "host:7070": host must be an IP address`},
+		{clusterstate.AddDeviceOptions{Secret: "secret", Request: usedAddress}, `cannot add device to cluster: address "192.168.0.20:7070" is already used by device 2`},
+		{clusterstate.AddDeviceOptions{Secret: "secret", Request: *req, Subclusters: []string{"unknown"}}, `cannot add device to cluster: unknown subcluster "unknown"`},
+		{clusterstate.AddDeviceOptions{Secret: "secret", Request: *member}, `cannot add device to cluster: device "serial-1.ubuntu-core-24-amd64.canonical" is already part of cluster "cluster-id"`},
+	} {
+		_, err := clusterstate.AddDevice(s.st, tc.opts)
+		c.Check(err, check.ErrorMatches, tc.err)
+	}
+}
+
+func (s *membershipSuite) TestAddDeviceDoesNotReuseRemovedIDs(c *check.C) {
+	req := s.joinRequest(c, "serial-3")
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	// the device with the highest id leaves the cluster
+	headers, err := clusterstate.RemoveDevice(s.st, clusterstate.RemoveDeviceOptions{Device: 2, Replacement: 1})
+	c.Assert(err, check.IsNil)
+	cluster := s.updateCluster(c, headers)
+	c.Assert(cluster.Devices(), check.HasLen, 1)
+
+	headers, err = clusterstate.AddDevice(s.st, clusterstate.AddDeviceOptions{Secret: "secret", Request: *req})
+	c.Assert(err, check.IsNil)
+
+	cluster = s.updateCluster(c, headers)
+	c.Assert(cluster.Devices(), check.HasLen, 2)
+	dev := cluster.Devices()[1]
+	c.Check(dev.Serial, check.Equals, "serial-3")
+	c.Check(dev.ID, check.Equals, 3)
+}
+
+func (s *membershipSuite) TestAddDeviceNoCluster(c *check.C) {
+	req := s.joinRequest(c, "serial-3")
+	st, _ := newStateWithStoreStack(c)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := clusterstate.AddDevice(st, clusterstate.AddDeviceOptions{Secret: "secret", Request: *req})
+	c.Check(err, check.ErrorMatches, "cannot add device to cluster: clusterstate: no cluster assertion")
+}
+
+func (s *membershipSuite) TestRemoveDevice(c *check.C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	headers, err := clusterstate.RemoveDevice(s.st, clusterstate.RemoveDeviceOptions{Device: 1})
+	c.Assert(err, check.IsNil)
+
+	cluster := s.updateCluster(c, headers)
+	c.Check(cluster.Sequence(), check.Equals, 2)
+	c.Assert(cluster.Devices(), check.HasLen, 1)
+	c.Check(cluster.Devices()[0].ID, check.Equals, 2)
+	c.Check(subclusterDevices(cluster), check.DeepEquals, map[string][]int{
+		"default": {2},
+		"single":  {2},
+	})
+}
+
+func (s *membershipSuite) TestReplaceDevice(c *check.C) {
+	req := s.joinRequest(c, "serial-3")
+
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	headers, err := clusterstate.AddDevice(s.st, clusterstate.AddDeviceOptions{Secret: "secret", Request: *req})
+	c.Assert(err, check.IsNil)
+	s.updateCluster(c, headers)
+
+	// the subclusters of the failed device, and thus their snaps, move to
+	// its replacement
+	headers, err = clusterstate.RemoveDevice(s.st, clusterstate.RemoveDeviceOptions{Device: 2, Replacement: 3})
+	c.Assert(err, check.IsNil)
+
+	cluster := s.updateCluster(c, headers)
+	c.Check(cluster.Sequence(), check.Equals, 3)
+	c.Assert(cluster.Devices(), check.HasLen, 2)
+	c.Check(cluster.Devices()[0].ID, check.Equals, 1)
+	c.Check(cluster.Devices()[1].ID, check.Equals, 3)
+	c.Check(subclusterDevices(cluster), check.DeepEquals, map[string][]int{
+		"default": {1, 3},
+		"single":  {3},
+	})
+}
+
+func (s *membershipSuite) TestRemoveDeviceErrors(c *check.C) {
+	s.st.Lock()
+	defer s.st.Unlock()
+
+	for _, tc := range []struct {
+		opts clusterstate.RemoveDeviceOptions
+		err  string
+	}{
+		{clusterstate.RemoveDeviceOptions{Device: 5}, `cannot remove device 5 from cluster: device not found in cluster "cluster-id"`},
+		{clusterstate.RemoveDeviceOptions{Device: 1, Replacement: 5}, `cannot remove device 1 from cluster: replacement device 5 not found in cluster "cluster-id"`},
+		{clusterstate.RemoveDeviceOptions{Device: 1, Replacement: 1}, `cannot remove device 1 from cluster: device cannot replace itself`},
+		{clusterstate.RemoveDeviceOptions{Device: 2}, `cannot remove device 2 from cluster: subcluster "single" would be left without devices, a replacement device is needed`},
+	} {
+		_, err := clusterstate.RemoveDevice(s.st, tc.opts)
+		c.Check(err, check.ErrorMatches, tc.err)
+	}
+
+	// the cluster cannot be left without devices
+	headers, err := clusterstate.RemoveDevice(s.st, clusterstate.RemoveDeviceOptions{Device: 1})
+	c.Assert(err, check.IsNil)
+	s.updateCluster(c, headers)
+
+	_, err = clusterstate.RemoveDevice(s.st, clusterstate.RemoveDeviceOptions{Device: 2})
+	c.Check(err, check.ErrorMatches, `cannot remove device 2 from cluster: device is the last one of cluster "cluster-id"`)
+}
@@ -0,0 +1,851 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/httputil"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/randutil"
+)
+
+var (
+	// rolloutRetryPeriod is how often a device waiting for its turn in a
+	// rollout checks on the devices ahead of it.
+	rolloutRetryPeriod = 10 * time.Second
+	// rolloutQueryTimeout bounds the time spent asking a peer for its
+	// rollout report.
+	rolloutQueryTimeout = 10 * time.Second
+
+	// fetchRolloutReport asks the peer listening on addr for its signed
+	// rollout report, presenting the given client certificate.
+	fetchRolloutReport = httpsFetchRolloutReport
+)
+
+// The data signed with the device key for rollout queries and reports starts
+// with a context that tells them apart from each other and from the serial
+// proofs of cluster assembly.
+const (
+	rolloutQuerySigningContext  = "snapd cluster rollout query\n"
+	rolloutReportSigningContext = "snapd cluster rollout report\n"
+)
+
+// RolloutStatus describes where a device is in the rollout of the current
+// cluster assertion to one of its subclusters.
+type RolloutStatus string
+
+const (
+	// RolloutWaiting means that the device has changes to apply and is
+	// waiting for its turn.
+	RolloutWaiting RolloutStatus = "waiting"
+	// RolloutApplying means that the device is applying its changes.
+	RolloutApplying RolloutStatus = "applying"
+	// RolloutDone means that the device has nothing left to apply.
+	RolloutDone RolloutStatus = "done"
+)
+
+// SubclusterRollout is the rollout state of a subcluster on one device.
+type SubclusterRollout struct {
+	Status RolloutStatus `json:"status"`
+	// Healthy is true when none of the clustered snaps of the subcluster
+	// reports a health problem.
+	Healthy bool `json:"healthy"`
+}
+
+// RolloutReport is what a device reports to its peers about the rollout of
+// the subclusters it belongs to that coordinate their rollouts.
+type RolloutReport struct {
+	ClusterID   string                       `json:"cluster-id"`
+	Sequence    int                          `json:"sequence"`
+	Device      int                          `json:"device"`
+	Subclusters map[string]SubclusterRollout `json:"subclusters"`
+	// Nonce is chosen by the device asking for the report, so that old
+	// reports cannot be replayed.
+	Nonce string `json:"nonce,omitempty"`
+}
+
+// signedRolloutReport carries a report signed with the device key of the
+// reporting device, together with what is needed to verify it.
+type signedRolloutReport struct {
+	Report       json.RawMessage `json:"report"`
+	SerialBundle string          `json:"serial-bundle"`
+	Signature    []byte          `json:"signature"`
+}
+
+// rolloutQuery is sent by a device of the cluster to ask a peer for its
+// rollout report.
+type rolloutQuery struct {
+	// Nonce is expected back in the report.
+	Nonce string `json:"nonce"`
+	// Device is the id of the querying device in the cluster assertion.
+	Device int `json:"device"`
+	// FP is the fingerprint of the TLS certificate the querying device
+	// presents, so that the query cannot be replayed over another
+	// connection.
+	FP assemblestate.Fingerprint `json:"fp"`
+}
+
+// signedRolloutQuery carries a query signed with the device key of the
+// querying device, together with what is needed to verify it.
+type signedRolloutQuery struct {
+	Query        json.RawMessage `json:"query"`
+	SerialBundle string          `json:"serial-bundle"`
+	Signature    []byte          `json:"signature"`
+}
+
+// rolloutCredentials authenticate this device when asking its peers for
+// their rollout reports.
+type rolloutCredentials struct {
+	device       int
+	cert         tls.Certificate
+	serialBundle string
+	sign         func(data []byte) ([]byte, error)
+}
+
+// newRolloutCredentials returns the credentials of this device of the cluster
+// for asking its peers for their rollout reports, with a certificate of its
+// own. Callers must hold the state lock, which is taken again to sign
+// queries.
+func newRolloutCredentials(st *state.State, signer DeviceKeySigner, cluster *asserts.Cluster) (*rolloutCredentials, error) {
+	serial, err := devicestate.Serial(st)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceID, ok := clusterDeviceIDBySerial(cluster, serial.Serial())
+	if !ok {
+		return nil, fmt.Errorf("device with serial %q not found in cluster assertion", serial.Serial())
+	}
+
+	bundle, err := assemblestate.BuildSerialBundle(serial, assertstate.DB(st))
+	if err != nil {
+		return nil, err
+	}
+
+	cert, key, err := generateCertificate(nil, rolloutCertificateLifetime)
+	if err != nil {
+		return nil, fmt.Errorf("cannot generate rollout certificate: %v", err)
+	}
+	tlsCert, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rolloutCredentials{
+		device:       deviceID,
+		cert:         tlsCert,
+		serialBundle: bundle,
+		sign: func(data []byte) ([]byte, error) {
+			st.Lock()
+			defer st.Unlock()
+			return signer.SignWithDeviceKey(data)
+		},
+	}, nil
+}
+
+// signQuery returns a query for a rollout report with the given nonce, signed
+// with the device key.
+func (creds *rolloutCredentials) signQuery(nonce string) (*signedRolloutQuery, error) {
+	data, err := json.Marshal(rolloutQuery{
+		Nonce:  nonce,
+		Device: creds.device,
+		FP:     assemblestate.CalculateFP(creds.cert.Certificate[0]),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := creds.sign(append([]byte(rolloutQuerySigningContext), data...))
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign rollout query: %v", err)
+	}
+
+	return &signedRolloutQuery{
+		Query:        data,
+		SerialBundle: creds.serialBundle,
+		Signature:    sig,
+	}, nil
+}
+
+// verifyRolloutQuery checks that the query was signed by a device of the
+// cluster, which presented the TLS certificate with the given fingerprint.
+func verifyRolloutQuery(signed *signedRolloutQuery, db asserts.RODatabase, cluster *asserts.Cluster, fp assemblestate.Fingerprint) (*rolloutQuery, error) {
+	serial, err := assemblestate.VerifySerialBundle(signed.SerialBundle, db)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rollout query: %v", err)
+	}
+
+	if err := asserts.RawVerifyWithKey(append([]byte(rolloutQuerySigningContext), signed.Query...), signed.Signature, serial.DeviceKey()); err != nil {
+		return nil, fmt.Errorf("invalid rollout query signature: %v", err)
+	}
+
+	var query rolloutQuery
+	if err := json.Unmarshal(signed.Query, &query); err != nil {
+		return nil, fmt.Errorf("cannot decode rollout query: %v", err)
+	}
+
+	if query.FP != fp {
+		return nil, errors.New("invalid rollout query: unexpected certificate")
+	}
+	dev, ok := clusterDevice(cluster, query.Device)
+	if !ok {
+		return nil, fmt.Errorf("invalid rollout query: unknown device %d", query.Device)
+	}
+	if serial.DeviceID() != dev.DeviceID {
+		return nil, fmt.Errorf("invalid rollout query: signed by device %q instead of %q", serial.DeviceID(), dev.DeviceID)
+	}
+
+	return &query, nil
+}
+
+// coordinatesRollout returns true if the devices of the subcluster take turns
+// to apply changes.
+func coordinatesRollout(sc asserts.Subcluster) bool {
+	return sc.MaxParallel > 0
+}
+
+// localRolloutReport returns the rollout report of this device for the given
+// cluster assertion. Callers must hold the state lock.
+func localRolloutReport(st *state.State, cluster *asserts.Cluster) (*RolloutReport, error) {
+	serial, err := devicestate.Serial(st)
+	if err != nil {
+		return nil, err
+	}
+
+	deviceID, ok := clusterDeviceIDBySerial(cluster, serial.Serial())
+	if !ok {
+		return nil, fmt.Errorf("device with serial %q not found in cluster assertion", serial.Serial())
+	}
+
+	report := &RolloutReport{
+		ClusterID:   cluster.ClusterID(),
+		Sequence:    cluster.Sequence(),
+		Device:      deviceID,
+		Subclusters: make(map[string]SubclusterRollout),
+	}
+
+	changes := inProgressClusterChanges(st)
+	for _, sc := range cluster.Subclusters() {
+		if !coordinatesRollout(sc) || !deviceInSubcluster(sc, deviceID) {
+			continue
+		}
+
+		status := RolloutDone
+		ref := clusterChangeRef{ClusterID: cluster.ClusterID(), Subcluster: sc.Name}
+		if chg := changes[ref]; chg != nil {
+			status = RolloutApplying
+			for _, t := range chg.Tasks() {
+				if t.Kind() == "wait-cluster-rollout" && !t.Status().Ready() {
+					status = RolloutWaiting
+				}
+			}
+		} else {
+			// the cluster assertion might not have been applied yet
+			pending, err := subclusterPending(st, sc)
+			if err != nil {
+				return nil, err
+			}
+			if pending {
+				status = RolloutWaiting
+			}
+		}
+
+		healthy, err := subclusterHealthy(st, sc)
+		if err != nil {
+			return nil, err
+		}
+
+		report.Subclusters[sc.Name] = SubclusterRollout{
+			Status:  status,
+			Healthy: healthy,
+		}
+	}
+
+	return report, nil
+}
+
+func subclusterPending(st *state.State, sc asserts.Subcluster) (bool, error) {
+	installs, removals, updates, evacuations, restorations, err := snapsForSubcluster(st, sc)
+	if err != nil {
+		return false, err
+	}
+	return len(installs) > 0 || len(removals) > 0 || len(updates) > 0 || len(evacuations) > 0 || len(restorations) > 0, nil
+}
+
+// subclusterHealthy returns false if any of the clustered snaps of the
+// subcluster reports that it is waiting, blocked or in error. Snaps that never
+// reported their health are considered healthy.
+func subclusterHealthy(st *state.State, sc asserts.Subcluster) (bool, error) {
+	for _, sn := range sc.Snaps {
+		if sn.State != asserts.ClusterSnapStateClustered {
+			continue
+		}
+
+		health, err := healthstate.Get(st, sn.Instance)
+		if err != nil {
+			return false, err
+		}
+		if health == nil {
+			continue
+		}
+
+		switch health.Status {
+		case healthstate.WaitingStatus, healthstate.BlockedStatus, healthstate.ErrorStatus:
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// devicesAhead returns the devices of the subcluster that must be done with
+// the rollout before the given device can start applying its changes. Devices
+// take turns in the order of their IDs, with at most MaxParallel of them
+// applying changes at once.
+func devicesAhead(sc asserts.Subcluster, deviceID int) []int {
+	ids := append([]int(nil), sc.Devices...)
+	sort.Ints(ids)
+
+	pos := sort.SearchInts(ids, deviceID)
+	if pos == len(ids) || ids[pos] != deviceID {
+		return nil
+	}
+
+	n := pos - sc.MaxParallel + 1
+	if n <= 0 {
+		return nil
+	}
+	return ids[:n]
+}
+
+// rolloutReached returns true if the report shows that the device is done
+// with the rollout of the subcluster at the given sequence, and healthy.
+func rolloutReached(report *RolloutReport, sequence int, subcluster string) bool {
+	if report.Sequence < sequence {
+		return false
+	}
+	sr, ok := report.Subclusters[subcluster]
+	if !ok {
+		// the device left the subcluster in a later sequence
+		return report.Sequence > sequence
+	}
+	return sr.Status == RolloutDone && sr.Healthy
+}
+
+func (m *ClusterManager) doWaitClusterRollout(t *state.Task, tomb *tomb.Tomb) error {
+	st := t.State()
+	st.Lock()
+
+	var name string
+	if err := t.Get("subcluster", &name); err != nil {
+		st.Unlock()
+		return err
+	}
+
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+
+	creds, err := newRolloutCredentials(st, m.signer, cluster)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	db := assertstate.DB(st)
+	st.Unlock()
+
+	i := subclusterIndex(cluster.Subclusters(), name)
+	if i < 0 {
+		// the subcluster was dropped by a later cluster assertion, the
+		// change applying it does not have anything left to wait for
+		return nil
+	}
+	sc := cluster.Subclusters()[i]
+	if !coordinatesRollout(sc) {
+		return nil
+	}
+
+	ctx := tomb.Context(nil)
+	for _, id := range devicesAhead(sc, creds.device) {
+		dev, ok := clusterDevice(cluster, id)
+		if !ok {
+			return fmt.Errorf("internal error: device %d of subcluster %q not found in cluster assertion", id, name)
+		}
+
+		report, err := queryRolloutReport(ctx, db, creds, cluster.ClusterID(), dev)
+		if err != nil {
+			logger.Debugf("cannot get rollout report of cluster device %d: %v", id, err)
+			return &state.Retry{After: rolloutRetryPeriod, Reason: fmt.Sprintf("cannot reach cluster device %d", id)}
+		}
+
+		if !rolloutReached(report, cluster.Sequence(), name) {
+			return &state.Retry{After: rolloutRetryPeriod, Reason: fmt.Sprintf("waiting for cluster device %d", id)}
+		}
+	}
+
+	return nil
+}
+
+func clusterDevice(cluster *asserts.Cluster, id int) (asserts.ClusterDevice, bool) {
+	for _, dev := range cluster.Devices() {
+		if dev.ID == id {
+			return dev, true
+		}
+	}
+	return asserts.ClusterDevice{}, false
+}
+
+// queryRolloutReport asks the given device for its rollout report, trying
+// each of its addresses in turn.
+func queryRolloutReport(ctx context.Context, db asserts.RODatabase, creds *rolloutCredentials, clusterID string, dev asserts.ClusterDevice) (*RolloutReport, error) {
+	if len(dev.Addresses) == 0 {
+		return nil, errors.New("device has no known address")
+	}
+
+	nonce, err := randutil.CryptoToken(16)
+	if err != nil {
+		return nil, err
+	}
+
+	query, err := creds.signQuery(nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	var lastErr error
+	for _, addr := range dev.Addresses {
+		ctx, cancel := context.WithTimeout(ctx, rolloutQueryTimeout)
+		signed, err := fetchRolloutReport(ctx, addr, creds.cert, query)
+		cancel()
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return verifyRolloutReport(signed, db, clusterID, dev, nonce)
+	}
+	return nil, lastErr
+}
+
+// verifyRolloutReport checks that the report was signed by the given device
+// of the cluster in answer to the query with the given nonce.
+func verifyRolloutReport(signed *signedRolloutReport, db asserts.RODatabase, clusterID string, dev asserts.ClusterDevice, nonce string) (*RolloutReport, error) {
+	serial, err := assemblestate.VerifySerialBundle(signed.SerialBundle, db)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rollout report: %v", err)
+	}
+
+	if serial.DeviceID() != dev.DeviceID {
+		return nil, fmt.Errorf("invalid rollout report: signed by device %q instead of %q", serial.DeviceID(), dev.DeviceID)
+	}
+
+	if err := asserts.RawVerifyWithKey(append([]byte(rolloutReportSigningContext), signed.Report...), signed.Signature, serial.DeviceKey()); err != nil {
+		return nil, fmt.Errorf("invalid rollout report signature: %v", err)
+	}
+
+	var report RolloutReport
+	if err := json.Unmarshal(signed.Report, &report); err != nil {
+		return nil, fmt.Errorf("cannot decode rollout report: %v", err)
+	}
+
+	if report.Nonce != nonce {
+		return nil, errors.New("invalid rollout report: unexpected nonce")
+	}
+	if report.ClusterID != clusterID {
+		return nil, fmt.Errorf("invalid rollout report: unexpected cluster %q", report.ClusterID)
+	}
+	if report.Device != dev.ID {
+		return nil, fmt.Errorf("invalid rollout report: unexpected device %d", report.Device)
+	}
+
+	return &report, nil
+}
+
+// signRolloutReport signs the report with the device key. Callers must hold
+// the state lock.
+func (m *ClusterManager) signRolloutReport(report *RolloutReport) (*signedRolloutReport, error) {
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+
+	sig, err := m.signer.SignWithDeviceKey(append([]byte(rolloutReportSigningContext), data...))
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign rollout report: %v", err)
+	}
+
+	serial, err := devicestate.Serial(m.state)
+	if err != nil {
+		return nil, err
+	}
+
+	bundle, err := assemblestate.BuildSerialBundle(serial, assertstate.DB(m.state))
+	if err != nil {
+		return nil, err
+	}
+
+	return &signedRolloutReport{
+		Report:       data,
+		SerialBundle: bundle,
+		Signature:    sig,
+	}, nil
+}
+
+// DeviceRollout is the rollout state of a device of the cluster.
+type DeviceRollout struct {
+	Device      int                          `json:"device"`
+	DeviceID    string                       `json:"device-id"`
+	Sequence    int                          `json:"sequence,omitempty"`
+	Subclusters map[string]SubclusterRollout `json:"subclusters,omitempty"`
+	// Error is set when the device could not be asked for its state.
+	Error string `json:"error,omitempty"`
+}
+
+// Rollout returns the rollout state of the devices of the subclusters that
+// coordinate their rollouts, as reported by each device. The queries to the
+// other devices are signed with the device key through signer. Callers must
+// not hold the state lock.
+func Rollout(ctx context.Context, st *state.State, signer DeviceKeySigner) ([]DeviceRollout, error) {
+	st.Lock()
+	if err := checkClusteringEnabled(st); err != nil {
+		st.Unlock()
+		return nil, err
+	}
+
+	cluster, err := CurrentCluster(st)
+	if err != nil {
+		st.Unlock()
+		return nil, err
+	}
+
+	local, err := localRolloutReport(st, cluster)
+	if err != nil {
+		st.Unlock()
+		return nil, err
+	}
+	creds, err := newRolloutCredentials(st, signer, cluster)
+	if err != nil {
+		st.Unlock()
+		return nil, err
+	}
+	db := assertstate.DB(st)
+	st.Unlock()
+
+	ids := make(map[int]bool)
+	for _, sc := range cluster.Subclusters() {
+		if !coordinatesRollout(sc) {
+			continue
+		}
+		for _, id := range sc.Devices {
+			ids[id] = true
+		}
+	}
+
+	var devices []asserts.ClusterDevice
+	for _, dev := range cluster.Devices() {
+		if ids[dev.ID] {
+			devices = append(devices, dev)
+		}
+	}
+	sort.Slice(devices, func(i, j int) bool { return devices[i].ID < devices[j].ID })
+
+	rollouts := make([]DeviceRollout, len(devices))
+	var wg sync.WaitGroup
+	for i, dev := range devices {
+		rollouts[i] = DeviceRollout{
+			Device:   dev.ID,
+			DeviceID: dev.DeviceID.String(),
+		}
+
+		if dev.ID == local.Device {
+			rollouts[i].Sequence = local.Sequence
+			rollouts[i].Subclusters = local.Subclusters
+			continue
+		}
+
+		wg.Add(1)
+		go func(rollout *DeviceRollout, dev asserts.ClusterDevice) {
+			defer wg.Done()
+			report, err := queryRolloutReport(ctx, db, creds, cluster.ClusterID(), dev)
+			if err != nil {
+				rollout.Error = err.Error()
+				return
+			}
+			rollout.Sequence = report.Sequence
+			rollout.Subclusters = report.Subclusters
+		}(&rollouts[i], dev)
+	}
+	wg.Wait()
+
+	return rollouts, nil
+}
+
+// rolloutServer answers the rollout queries of the peers on the cluster
+// address of this device.
+type rolloutServer struct {
+	address string
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func (s *rolloutServer) stop() {
+	s.cancel()
+	<-s.done
+}
+
+// rolloutAddress returns the address this device answers rollout queries on,
+// or an empty string if none of its subclusters coordinate their rollouts.
+func rolloutAddress(cluster *asserts.Cluster, deviceID int) string {
+	dev, ok := clusterDevice(cluster, deviceID)
+	if !ok || len(dev.Addresses) == 0 {
+		return ""
+	}
+	for _, sc := range cluster.Subclusters() {
+		if coordinatesRollout(sc) && deviceInSubcluster(sc, deviceID) {
+			return dev.Addresses[0]
+		}
+	}
+	return ""
+}
+
+// updateRolloutServer makes sure that rollout queries are answered on the
+// address of this device in the cluster assertion, if any of its subclusters
+// coordinate their rollouts. Callers must hold the state lock.
+func (m *ClusterManager) updateRolloutServer(cluster *asserts.Cluster) error {
+	serial, err := devicestate.Serial(m.state)
+	if err != nil {
+		return err
+	}
+
+	var address string
+	if deviceID, ok := clusterDeviceIDBySerial(cluster, serial.Serial()); ok {
+		address = rolloutAddress(cluster, deviceID)
+	}
+
+	m.rolloutMu.Lock()
+	defer m.rolloutMu.Unlock()
+
+	if m.rollout != nil {
+		if m.rollout.address == address {
+			return nil
+		}
+		m.rollout.stop()
+		m.rollout = nil
+	}
+
+	if address == "" {
+		return nil
+	}
+
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	cert, key, err := generateCertificate(net.ParseIP(host), rolloutCertificateLifetime)
+	if err != nil {
+		return fmt.Errorf("cannot generate rollout certificate: %v", err)
+	}
+	tlsCert, err := tls.X509KeyPair(cert, key)
+	if err != nil {
+		return err
+	}
+
+	ln, err := netListen("tcp", address)
+	if err != nil {
+		return fmt.Errorf("cannot listen for cluster rollout queries: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	srv := &rolloutServer{
+		address: address,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+	go func() {
+		defer close(srv.done)
+		m.serveRollout(ctx, ln, tlsCert)
+	}()
+	m.rollout = srv
+
+	return nil
+}
+
+// rolloutCertificateLifetime is the validity of the certificates used to ask
+// and answer rollout queries. Peers authenticate the signed queries and
+// reports rather than the certificates, which protect the exchange from
+// eavesdroppers and tie the queries to the connection.
+const rolloutCertificateLifetime = 365 * 24 * time.Hour
+
+func (m *ClusterManager) serveRollout(ctx context.Context, ln net.Listener, cert tls.Certificate) {
+	mux := http.NewServeMux()
+	mux.Handle("/cluster/rollout", http.HandlerFunc(m.handleRolloutQuery))
+
+	server := &http.Server{
+		Handler: mux,
+		BaseContext: func(l net.Listener) context.Context {
+			return ctx
+		},
+		ErrorLog: log.New(io.Discard, "", 0),
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		listener := tls.NewListener(ln, &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			// the querying device signs the fingerprint of its
+			// certificate, as in cluster assembly
+			ClientAuth: tls.RequireAnyClientCert,
+			MinVersion: tls.VersionTLS12,
+		})
+		// serve always returns a non-nil error, nothing to handle here
+		_ = server.Serve(listener)
+	}()
+
+	<-ctx.Done()
+
+	// the server is stopped with the state lock held, which the handlers
+	// need, so we cannot wait for them to finish
+	_ = server.Close()
+	wg.Wait()
+}
+
+func (m *ClusterManager) handleRolloutQuery(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		w.WriteHeader(405)
+		return
+	}
+
+	if r.TLS == nil || len(r.TLS.PeerCertificates) != 1 {
+		w.WriteHeader(403)
+		return
+	}
+
+	// the serial bundle makes up most of the query
+	const maxQuerySize = 64 * 1024
+	var signedQuery signedRolloutQuery
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxQuerySize)).Decode(&signedQuery); err != nil {
+		w.WriteHeader(400)
+		return
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	cluster, err := CurrentCluster(m.state)
+	if err != nil {
+		w.WriteHeader(503)
+		logger.Debugf("cannot answer rollout query: %v", err)
+		return
+	}
+
+	fp := assemblestate.CalculateFP(r.TLS.PeerCertificates[0].Raw)
+	query, err := verifyRolloutQuery(&signedQuery, assertstate.DB(m.state), cluster, fp)
+	if err != nil {
+		w.WriteHeader(403)
+		logger.Debugf("cannot authenticate rollout query: %v", err)
+		return
+	}
+
+	report, err := localRolloutReport(m.state, cluster)
+	if err != nil {
+		w.WriteHeader(503)
+		logger.Debugf("cannot answer rollout query: %v", err)
+		return
+	}
+	report.Nonce = query.Nonce
+
+	signed, err := m.signRolloutReport(report)
+	if err != nil {
+		w.WriteHeader(500)
+		logger.Debugf("cannot answer rollout query: %v", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(signed)
+}
+
+func httpsFetchRolloutReport(ctx context.Context, addr string, cert tls.Certificate, query *signedRolloutQuery) (*signedRolloutReport, error) {
+	// the report is signed with the device key, verifying the certificate of
+	// the peer would not add anything
+	client := httputil.NewHTTPClient(&httputil.ClientOptions{
+		Timeout: rolloutQueryTimeout,
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+			Certificates:       []tls.Certificate{cert},
+		},
+	})
+	client.CheckRedirect = func(*http.Request, []*http.Request) error {
+		return errors.New("redirects are not expected")
+	}
+	defer client.CloseIdleConnections()
+
+	payload, err := json.Marshal(query)
+	if err != nil {
+		return nil, err
+	}
+
+	url := fmt.Sprintf("https://%s/cluster/rollout", addr)
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != 200 {
+		return nil, fmt.Errorf("response to rollout query contains status code %d", res.StatusCode)
+	}
+
+	const maxReportSize = 64 * 1024
+	var signed signedRolloutReport
+	if err := json.NewDecoder(io.LimitReader(res.Body, maxReportSize)).Decode(&signed); err != nil {
+		return nil, fmt.Errorf("cannot decode rollout report: %v", err)
+	}
+
+	return &signed, nil
+}
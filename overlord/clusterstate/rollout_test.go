@@ -0,0 +1,543 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package clusterstate_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/cluster/assemblestate"
+	"github.com/snapcore/snapd/overlord/assertstate"
+	"github.com/snapcore/snapd/overlord/clusterstate"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type rolloutSuite struct {
+	testutil.BaseTest
+
+	stack *assertstest.StoreStack
+	sa    *assertstest.SigningAccounts
+	// listeners are handed to the managers answering rollout queries
+	listeners map[string]net.Listener
+	addresses []string
+}
+
+var _ = check.Suite(&rolloutSuite{})
+
+const rolloutAccountID = "cluster-brand"
+
+func (s *rolloutSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.stack = assertstest.NewStoreStack("canonical", nil)
+	s.sa = registerAccount(s.stack, rolloutAccountID)
+
+	s.listeners = make(map[string]net.Listener)
+	s.addresses = nil
+	for i := 0; i < 3; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		c.Assert(err, check.IsNil)
+		s.listeners[ln.Addr().String()] = ln
+		s.addresses = append(s.addresses, ln.Addr().String())
+	}
+	s.AddCleanup(func() {
+		for _, ln := range s.listeners {
+			ln.Close()
+		}
+	})
+
+	s.AddCleanup(clusterstate.MockNetListen(func(network, address string) (net.Listener, error) {
+		ln, ok := s.listeners[address]
+		if !ok {
+			return nil, fmt.Errorf("unexpected address %q", address)
+		}
+		return ln, nil
+	}))
+}
+
+type rolloutDevice struct {
+	st     *state.State
+	mgr    *clusterstate.ClusterManager
+	runner *state.TaskRunner
+	signer keySigner
+}
+
+// device returns a device of the cluster tracking the given channel of the
+// snap of the "ha" subcluster.
+func (s *rolloutSuite) device(c *check.C, id int, bundle []byte, channel string) *rolloutDevice {
+	deviceKey, _ := assertstest.GenerateKey(752)
+	encodedKey, err := asserts.EncodePublicKey(deviceKey.PublicKey())
+	c.Assert(err, check.IsNil)
+
+	a, err := s.stack.Sign(asserts.SerialType, map[string]any{
+		"authority-id":        "canonical",
+		"brand-id":            "canonical",
+		"model":               "ubuntu-core-24-amd64",
+		"serial":              fmt.Sprintf("serial-%d", id),
+		"device-key":          string(encodedKey),
+		"device-key-sha3-384": deviceKey.PublicKey().ID(),
+		"timestamp":           time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	st := newStateTrusting(c, s.stack)
+	runner := state.NewTaskRunner(st)
+	signer := keySigner{key: deviceKey}
+	mgr := clusterstate.Manager(st, runner, signer)
+	s.AddCleanup(mgr.Stop)
+
+	st.Lock()
+	defer st.Unlock()
+
+	addSerialToState(c, st, a.(*asserts.Serial))
+	setInstalled(st, "ha-snap")
+	snapst := &snapstate.SnapState{}
+	c.Assert(snapstate.Get(st, "ha-snap", snapst), check.IsNil)
+	snapst.TrackingChannel = channel
+	snapstate.Set(st, "ha-snap", snapst)
+
+	c.Assert(clusterstate.InitializeNewCluster(st, bytes.NewReader(bundle)), check.IsNil)
+
+	return &rolloutDevice{st: st, mgr: mgr, runner: runner, signer: signer}
+}
+
+// serialBundle returns the serial bundle of the device with the given id.
+func (dev *rolloutDevice) serialBundle(c *check.C, id int) string {
+	dev.st.Lock()
+	defer dev.st.Unlock()
+
+	db := assertstate.DB(dev.st)
+	serial, err := db.Find(asserts.SerialType, map[string]string{
+		"brand-id": "canonical",
+		"model":    "ubuntu-core-24-amd64",
+		"serial":   fmt.Sprintf("serial-%d", id),
+	})
+	c.Assert(err, check.IsNil)
+	bundle, err := assemblestate.BuildSerialBundle(serial.(*asserts.Serial), db)
+	c.Assert(err, check.IsNil)
+	return bundle
+}
+
+// unreachable makes the device with the given id refuse connections.
+func (s *rolloutSuite) unreachable(c *check.C, id int) {
+	c.Assert(s.listeners[s.addresses[id-1]].Close(), check.IsNil)
+}
+
+func (s *rolloutSuite) clusterBundle(c *check.C, maxParallel int) []byte {
+	var devices []map[string]any
+	for i, addr := range s.addresses {
+		devices = append(devices, map[string]any{
+			"id":        fmt.Sprint(i + 1),
+			"device":    fmt.Sprintf("serial-%d.ubuntu-core-24-amd64.canonical", i+1),
+			"addresses": []any{addr},
+		})
+	}
+	subclusters := []map[string]any{{
+		"name":         "ha",
+		"devices":      []any{"1", "2", "3"},
+		"max-parallel": fmt.Sprint(maxParallel),
+		"snaps": []any{
+			map[string]any{"state": "clustered", "instance": "ha-snap", "channel": "latest/candidate"},
+		},
+	}}
+
+	bundle, _ := makeClusterBundleWithSigning(c, s.sa, rolloutAccountID, "cluster-id", 1, devices, subclusters)
+	return bundle
+}
+
+func (s *rolloutSuite) mockUpdate(c *check.C) {
+	s.AddCleanup(clusterstate.MockSnapstateUpdateWithGoal(func(ctx context.Context, st *state.State, goal snapstate.UpdateGoal, filter func(*snap.Info, *snapstate.SnapState) bool, opts snapstate.Options) ([]string, *snapstate.UpdateTaskSets, error) {
+		ts := state.NewTaskSet(st.NewTask("refresh", "refresh ha-snap"))
+		return []string{"ha-snap"}, &snapstate.UpdateTaskSets{Refresh: []*state.TaskSet{ts}}, nil
+	}))
+}
+
+// waitTask runs the ensure loop of the device until the change applying the
+// "ha" subcluster is created, and returns its wait-cluster-rollout task.
+func (s *rolloutSuite) waitTask(c *check.C, dev *rolloutDevice) *state.Task {
+	c.Assert(dev.mgr.Ensure(), check.IsNil)
+
+	dev.st.Lock()
+	defer dev.st.Unlock()
+
+	changes := dev.st.Changes()
+	c.Assert(changes, check.HasLen, 1)
+	tasks := changes[0].Tasks()
+	c.Assert(taskKinds(tasks), check.DeepEquals, []string{"wait-cluster-rollout", "refresh"})
+	c.Check(tasks[1].WaitTasks(), check.DeepEquals, []*state.Task{tasks[0]})
+	return tasks[0]
+}
+
+func (s *rolloutSuite) runWait(c *check.C, dev *rolloutDevice, t *state.Task) {
+	dev.st.Lock()
+	// only run the gating task
+	t.Change().Tasks()[1].SetStatus(state.HoldStatus)
+	dev.st.Unlock()
+
+	dev.runner.Ensure()
+	dev.runner.Wait()
+}
+
+func (s *rolloutSuite) TestDevicesAhead(c *check.C) {
+	for _, tc := range []struct {
+		devices     []int
+		maxParallel int
+		device      int
+		ahead       []int
+	}{
+		{[]int{1, 2, 3}, 1, 1, nil},
+		{[]int{1, 2, 3}, 1, 2, []int{1}},
+		{[]int{3, 1, 2}, 1, 3, []int{1, 2}},
+		{[]int{1, 2, 3}, 2, 2, nil},
+		{[]int{1, 2, 3, 4}, 2, 4, []int{1, 2}},
+		{[]int{1, 2, 3}, 5, 3, nil},
+		{[]int{1, 2}, 1, 3, nil},
+	} {
+		sc := asserts.Subcluster{Devices: tc.devices, MaxParallel: tc.maxParallel}
+		c.Check(clusterstate.DevicesAhead(sc, tc.device), check.DeepEquals, tc.ahead, check.Commentf("%+v", tc))
+	}
+}
+
+func (s *rolloutSuite) TestEnsureGatesRollout(c *check.C) {
+	s.mockUpdate(c)
+
+	s.unreachable(c, 2)
+	s.unreachable(c, 3)
+
+	dev := s.device(c, 1, s.clusterBundle(c, 1), "latest/stable")
+	t := s.waitTask(c, dev)
+
+	dev.st.Lock()
+	var name string
+	c.Assert(t.Get("subcluster", &name), check.IsNil)
+	dev.st.Unlock()
+	c.Check(name, check.Equals, "ha")
+
+	rollouts, err := clusterstate.Rollout(context.Background(), dev.st, dev.signer)
+	c.Assert(err, check.IsNil)
+	c.Assert(rollouts, check.HasLen, 3)
+	c.Check(rollouts[0], check.DeepEquals, clusterstate.DeviceRollout{
+		Device:   1,
+		DeviceID: "serial-1.ubuntu-core-24-amd64.canonical",
+		Sequence: 1,
+		Subclusters: map[string]clusterstate.SubclusterRollout{
+			"ha": {Status: clusterstate.RolloutWaiting, Healthy: true},
+		},
+	})
+
+	// the first device does not wait for anybody
+	s.runWait(c, dev, t)
+
+	dev.st.Lock()
+	defer dev.st.Unlock()
+	c.Check(t.Status(), check.Equals, state.DoneStatus)
+}
+
+func (s *rolloutSuite) TestEnsureWithoutCoordination(c *check.C) {
+	s.mockUpdate(c)
+
+	bundle, _ := makeClusterBundleWithSigning(c, s.sa, rolloutAccountID, "cluster-id", 1, []map[string]any{{
+		"id":        "1",
+		"device":    "serial-1.ubuntu-core-24-amd64.canonical",
+		"addresses": []any{"192.168.0.10:7070"},
+	}}, []map[string]any{{
+		"name":    "default",
+		"devices": []any{"1"},
+		"snaps": []any{
+			map[string]any{"state": "clustered", "instance": "ha-snap", "channel": "latest/candidate"},
+		},
+	}})
+	dev := s.device(c, 1, bundle, "latest/stable")
+
+	// no subcluster coordinates its rollouts, rollout queries are not
+	// answered
+	c.Assert(dev.mgr.Ensure(), check.IsNil)
+
+	dev.st.Lock()
+	defer dev.st.Unlock()
+	changes := dev.st.Changes()
+	c.Assert(changes, check.HasLen, 1)
+	c.Check(taskKinds(changes[0].Tasks()), check.DeepEquals, []string{"refresh"})
+}
+
+func (s *rolloutSuite) TestWaitForHealthyPeer(c *check.C) {
+	s.mockUpdate(c)
+	bundle := s.clusterBundle(c, 1)
+	s.unreachable(c, 3)
+
+	// the first device is done with the rollout and answers queries
+	peer := s.device(c, 1, bundle, "latest/candidate")
+	c.Assert(peer.mgr.Ensure(), check.IsNil)
+
+	peer.st.Lock()
+	c.Check(peer.st.Changes(), check.HasLen, 0)
+	err := healthstate.Set(peer.st, "ha-snap", &healthstate.HealthState{Status: healthstate.ErrorStatus})
+	peer.st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	dev := s.device(c, 2, bundle, "latest/stable")
+	t := s.waitTask(c, dev)
+
+	// the peer is not healthy, we keep waiting
+	s.runWait(c, dev, t)
+
+	dev.st.Lock()
+	c.Check(t.Status(), check.Equals, state.DoingStatus)
+	dev.st.Unlock()
+
+	rollouts, err := clusterstate.Rollout(context.Background(), dev.st, dev.signer)
+	c.Assert(err, check.IsNil)
+	c.Assert(rollouts, check.HasLen, 3)
+	c.Check(rollouts[0].Subclusters, check.DeepEquals, map[string]clusterstate.SubclusterRollout{
+		"ha": {Status: clusterstate.RolloutDone, Healthy: false},
+	})
+	c.Check(rollouts[1].Subclusters, check.DeepEquals, map[string]clusterstate.SubclusterRollout{
+		"ha": {Status: clusterstate.RolloutWaiting, Healthy: true},
+	})
+	// the third device does not answer
+	c.Check(rollouts[2].Device, check.Equals, 3)
+	c.Check(rollouts[2].Error, check.Not(check.Equals), "")
+
+	peer.st.Lock()
+	err = healthstate.Set(peer.st, "ha-snap", &healthstate.HealthState{Status: healthstate.OkayStatus})
+	peer.st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	// retry right away
+	dev.st.Lock()
+	t.At(time.Time{})
+	dev.st.Unlock()
+	s.runWait(c, dev, t)
+
+	dev.st.Lock()
+	defer dev.st.Unlock()
+	c.Check(t.Status(), check.Equals, state.DoneStatus)
+}
+
+func (s *rolloutSuite) TestWaitForUnreachablePeer(c *check.C) {
+	s.mockUpdate(c)
+	bundle := s.clusterBundle(c, 2)
+
+	// the first two devices update together, the third one waits for the
+	// first one, which does not answer
+	s.unreachable(c, 1)
+	dev := s.device(c, 3, bundle, "latest/stable")
+	t := s.waitTask(c, dev)
+
+	s.runWait(c, dev, t)
+
+	dev.st.Lock()
+	defer dev.st.Unlock()
+	c.Check(t.Status(), check.Equals, state.DoingStatus)
+}
+
+func (s *rolloutSuite) TestRolloutRejectsInvalidReports(c *check.C) {
+	bundle := s.clusterBundle(c, 1)
+	dev := s.device(c, 1, bundle, "latest/candidate")
+	peer := s.device(c, 2, bundle, "latest/candidate")
+	// a device that is not part of the cluster
+	other := s.device(c, 4, bundle, "latest/candidate")
+
+	peerBundle := peer.serialBundle(c, 2)
+	otherBundle := other.serialBundle(c, 4)
+
+	signedWithContext := func(signingContext string, signer keySigner, serialBundle string, device int, nonce string) *clusterstate.SignedRolloutReport {
+		data, err := json.Marshal(clusterstate.RolloutReport{
+			ClusterID: "cluster-id",
+			Sequence:  1,
+			Device:    device,
+			Nonce:     nonce,
+		})
+		c.Assert(err, check.IsNil)
+		sig, err := signer.SignWithDeviceKey(append([]byte(signingContext), data...))
+		c.Assert(err, check.IsNil)
+		return &clusterstate.SignedRolloutReport{Report: data, SerialBundle: serialBundle, Signature: sig}
+	}
+	signed := func(signer keySigner, serialBundle string, device int, nonce string) *clusterstate.SignedRolloutReport {
+		return signedWithContext(clusterstate.RolloutReportSigningContext, signer, serialBundle, device, nonce)
+	}
+
+	var fetch func(nonce string) *clusterstate.SignedRolloutReport
+	restore := clusterstate.MockFetchRolloutReport(func(ctx context.Context, addr string, cert tls.Certificate, query *clusterstate.SignedRolloutQuery) (*clusterstate.SignedRolloutReport, error) {
+		if addr != s.addresses[1] {
+			return nil, errors.New("unreachable")
+		}
+		// the query comes signed by the device asking
+		c.Check(query.SerialBundle, check.Equals, dev.serialBundle(c, 1))
+		var q clusterstate.RolloutQuery
+		c.Assert(json.Unmarshal(query.Query, &q), check.IsNil)
+		c.Check(q.Device, check.Equals, 1)
+		c.Check(q.FP, check.Equals, assemblestate.CalculateFP(cert.Certificate[0]))
+		c.Check(asserts.RawVerifyWithKey(append([]byte(clusterstate.RolloutQuerySigningContext), query.Query...), query.Signature, dev.signer.key.PublicKey()), check.IsNil)
+		return fetch(q.Nonce), nil
+	})
+	defer restore()
+
+	for _, tc := range []struct {
+		fetch func(nonce string) *clusterstate.SignedRolloutReport
+		err   string
+	}{{
+		fetch: func(nonce string) *clusterstate.SignedRolloutReport {
+			return signed(other.signer, otherBundle, 2, nonce)
+		},
+		err: `invalid rollout report: signed by device "serial-4.ubuntu-core-24-amd64.canonical" instead of "serial-2.ubuntu-core-24-amd64.canonical"`,
+	}, {
+		fetch: func(nonce string) *clusterstate.SignedRolloutReport {
+			return signed(other.signer, peerBundle, 2, nonce)
+		},
+		err: `invalid rollout report signature: .*`,
+	}, {
+		// signatures made for other purposes are not taken as reports
+		fetch: func(nonce string) *clusterstate.SignedRolloutReport {
+			return signedWithContext("", peer.signer, peerBundle, 2, nonce)
+		},
+		err: `invalid rollout report signature: .*`,
+	}, {
+		fetch: func(nonce string) *clusterstate.SignedRolloutReport {
+			return signed(peer.signer, peerBundle, 2, "other-nonce")
+		},
+		err: `invalid rollout report: unexpected nonce`,
+	}, {
+		fetch: func(nonce string) *clusterstate.SignedRolloutReport {
+			return signed(peer.signer, peerBundle, 3, nonce)
+		},
+		err: `invalid rollout report: unexpected device 3`,
+	}, {
+		fetch: func(nonce string) *clusterstate.SignedRolloutReport {
+			return signed(peer.signer, "", 2, nonce)
+		},
+		err: `invalid rollout report: serial bundle is empty`,
+	}, {
+		fetch: func(nonce string) *clusterstate.SignedRolloutReport {
+			return signed(peer.signer, peerBundle, 2, nonce)
+		},
+	}} {
+		fetch = tc.fetch
+		rollouts, err := clusterstate.Rollout(context.Background(), dev.st, dev.signer)
+		c.Assert(err, check.IsNil)
+		c.Assert(rollouts, check.HasLen, 3)
+		c.Check(rollouts[1].Error, check.Matches, tc.err)
+		c.Check(rollouts[2].Error, check.Equals, "unreachable")
+	}
+}
+
+func (s *rolloutSuite) TestAnswerRolloutQueryAuthenticated(c *check.C) {
+	bundle := s.clusterBundle(c, 1)
+
+	// the first device answers queries
+	peer := s.device(c, 1, bundle, "latest/candidate")
+	c.Assert(peer.mgr.Ensure(), check.IsNil)
+
+	dev := s.device(c, 2, bundle, "latest/candidate")
+	// a device that is not part of the cluster
+	other := s.device(c, 4, bundle, "latest/candidate")
+
+	devBundle := dev.serialBundle(c, 2)
+	otherBundle := other.serialBundle(c, 4)
+
+	newCert := func() tls.Certificate {
+		certPEM, keyPEM, err := clusterstate.GenerateCertificate(nil, time.Hour)
+		c.Assert(err, check.IsNil)
+		cert, err := tls.X509KeyPair(certPEM, keyPEM)
+		c.Assert(err, check.IsNil)
+		return cert
+	}
+	cert := newCert()
+	fp := assemblestate.CalculateFP(cert.Certificate[0])
+
+	query := func(signingContext string, signer keySigner, serialBundle string, device int) *clusterstate.SignedRolloutQuery {
+		data, err := json.Marshal(clusterstate.RolloutQuery{
+			Nonce:  "nonce",
+			Device: device,
+			FP:     fp,
+		})
+		c.Assert(err, check.IsNil)
+		sig, err := signer.SignWithDeviceKey(append([]byte(signingContext), data...))
+		c.Assert(err, check.IsNil)
+		return &clusterstate.SignedRolloutQuery{Query: data, SerialBundle: serialBundle, Signature: sig}
+	}
+	querySigningContext := clusterstate.RolloutQuerySigningContext
+
+	for i, tc := range []struct {
+		cert  tls.Certificate
+		query *clusterstate.SignedRolloutQuery
+		err   string
+	}{{
+		// a device that is not part of the cluster
+		cert:  cert,
+		query: query(querySigningContext, other.signer, otherBundle, 4),
+		err:   "response to rollout query contains status code 403",
+	}, {
+		cert:  cert,
+		query: query(querySigningContext, other.signer, otherBundle, 2),
+		err:   "response to rollout query contains status code 403",
+	}, {
+		cert:  cert,
+		query: query(querySigningContext, other.signer, devBundle, 2),
+		err:   "response to rollout query contains status code 403",
+	}, {
+		// signatures made for other purposes are not taken as queries
+		cert:  cert,
+		query: query("", dev.signer, devBundle, 2),
+		err:   "response to rollout query contains status code 403",
+	}, {
+		// the query cannot be replayed over another connection
+		cert:  newCert(),
+		query: query(querySigningContext, dev.signer, devBundle, 2),
+		err:   "response to rollout query contains status code 403",
+	}, {
+		cert:  tls.Certificate{},
+		query: query(querySigningContext, dev.signer, devBundle, 2),
+		err:   ".*certificate required.*",
+	}} {
+		_, err := clusterstate.FetchRolloutReport(context.Background(), s.addresses[0], tc.cert, tc.query)
+		c.Check(err, check.ErrorMatches, tc.err, check.Commentf("#%d", i))
+	}
+
+	// a device of the cluster gets the signed report
+	signed, err := clusterstate.FetchRolloutReport(context.Background(), s.addresses[0], cert, query(querySigningContext, dev.signer, devBundle, 2))
+	c.Assert(err, check.IsNil)
+
+	var report clusterstate.RolloutReport
+	c.Assert(json.Unmarshal(signed.Report, &report), check.IsNil)
+	c.Check(report.Nonce, check.Equals, "nonce")
+	c.Check(report.Device, check.Equals, 1)
+	c.Check(asserts.RawVerifyWithKey(append([]byte(clusterstate.RolloutReportSigningContext), signed.Report...), signed.Signature, peer.signer.key.PublicKey()), check.IsNil)
+}
+
+func (s *rolloutSuite) TestRolloutNoCluster(c *check.C) {
+	st := newStateTrusting(c, s.stack)
+	_, err := clusterstate.Rollout(context.Background(), st, nil)
+	c.Check(err, check.Equals, clusterstate.ErrNoClusterAssertion)
+}
@@ -0,0 +1,54 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore
+
+import (
+	"fmt"
+
+	"github.com/snapcore/snapd/strutil"
+)
+
+func init() {
+	// add supported configuration of this module
+	supportedConfigurations["core.changes.archive.max-size"] = true
+}
+
+// minChangeArchiveSize is the smallest size the archive of pruned changes can
+// be bounded to.
+const minChangeArchiveSize = 64 * 1000
+
+func validateChangeArchive(tr RunTransaction) error {
+	maxSizeStr, err := coreCfg(tr, "changes.archive.max-size")
+	if err != nil {
+		return err
+	}
+	if maxSizeStr == "" {
+		return nil
+	}
+	maxSize, err := strutil.ParseByteSize(maxSizeStr)
+	if err != nil {
+		return fmt.Errorf("changes.archive.max-size cannot be parsed: %v", err)
+	}
+	if maxSize < minChangeArchiveSize {
+		return fmt.Errorf("changes.archive.max-size must be at least %s", strutil.SizeToStr(minChangeArchiveSize))
+	}
+	return nil
+}
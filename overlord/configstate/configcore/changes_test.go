@@ -0,0 +1,61 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+//go:build !nomanagers
+
+/*
+ * Copyright (C) 2019 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package configcore_test
+
+import (
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/configstate/configcore"
+)
+
+type changesSuite struct {
+	configcoreSuite
+}
+
+var _ = Suite(&changesSuite{})
+
+func (s *changesSuite) TestConfigureChangeArchiveHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"changes.archive.max-size": "32MB",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *changesSuite) TestConfigureChangeArchiveInvalid(c *C) {
+	for _, tc := range []struct {
+		value string
+		err   string
+	}{
+		{"lots", `changes.archive.max-size cannot be parsed: .*`},
+		{"1kB", `changes.archive.max-size must be at least 64kB`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"changes.archive.max-size": tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s", tc.value))
+	}
+}
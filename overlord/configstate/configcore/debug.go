@@ -30,13 +30,16 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/systemd"
+	"github.com/snapcore/snapd/timings"
 )
 
 const (
-	optionDebugSnapdLog            = "debug.snapd.log"
-	optionDebugSystemdLogLevel     = "debug.systemd.log-level"
-	coreOptionDebugSnapdLog        = "core." + optionDebugSnapdLog
-	coreOptionDebugSystemdLogLevel = "core." + optionDebugSystemdLogLevel
+	optionDebugSnapdLog                = "debug.snapd.log"
+	optionDebugSystemdLogLevel         = "debug.systemd.log-level"
+	optionDebugTimingsOTLPEndpoint     = "debug.timings.otlp-endpoint"
+	coreOptionDebugSnapdLog            = "core." + optionDebugSnapdLog
+	coreOptionDebugSystemdLogLevel     = "core." + optionDebugSystemdLogLevel
+	coreOptionDebugTimingsOTLPEndpoint = "core." + optionDebugTimingsOTLPEndpoint
 )
 
 var loggerSimpleSetup = logger.SimpleSetup
@@ -44,6 +47,7 @@ var loggerSimpleSetup = logger.SimpleSetup
 func init() {
 	supportedConfigurations[coreOptionDebugSnapdLog] = true
 	supportedConfigurations[coreOptionDebugSystemdLogLevel] = true
+	supportedConfigurations[coreOptionDebugTimingsOTLPEndpoint] = true
 }
 
 func validateDebugSnapdLogSetting(tr RunTransaction) error {
@@ -117,6 +121,21 @@ func validateDebugSystemdLogLevelSetting(tr RunTransaction) error {
 	return nil
 }
 
+func validateDebugTimingsOTLPEndpointSetting(tr RunTransaction) error {
+	value, err := coreCfg(tr, optionDebugTimingsOTLPEndpoint)
+	if err != nil {
+		return err
+	}
+	if value == "" {
+		return nil
+	}
+	// the endpoint is read by the overlord whenever timings are saved
+	if _, err := timings.OTLPTracesURL(value); err != nil {
+		return fmt.Errorf("%q is not a valid value for %s: %v", value, optionDebugTimingsOTLPEndpoint, err)
+	}
+	return nil
+}
+
 func handleDebugSystemdLogLevelConfiguration(tr RunTransaction, opts *fsOnlyContext) error {
 	// Run only if the option changed to avoid extra filesystem
 	// access / systemctl calls
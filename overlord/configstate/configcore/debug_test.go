@@ -24,6 +24,7 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
 
 	. "gopkg.in/check.v1"
 
@@ -205,3 +206,29 @@ func (s *debugSuite) TestConfigureSystemdLogLevelOldSystemd(c *C) {
 	c.Check(systemctlArgs, DeepEquals, []string{"log-level", val})
 	c.Check(sysdAnalyzeCmd.Calls(), DeepEquals, [][]string{{"systemd-analyze", "set-log-level", val}})
 }
+
+func (s *debugSuite) TestConfigureDebugTimingsOTLPEndpointGoodVals(c *C) {
+	for _, val := range []string{"", "http://localhost:4318", "https://collector:4318/custom/traces"} {
+		err := configcore.Run(coreDev, &mockConf{
+			state:   s.state,
+			changes: map[string]any{"debug.timings.otlp-endpoint": val},
+		})
+		c.Check(err, IsNil, Commentf("%q", val))
+	}
+}
+
+func (s *debugSuite) TestConfigureDebugTimingsOTLPEndpointBadVals(c *C) {
+	for _, tc := range []struct {
+		val, err string
+	}{
+		{"localhost:4318", `"localhost:4318" is not a valid value for debug.timings.otlp-endpoint: unsupported scheme "localhost", expected http or https`},
+		{"grpc://localhost:4317", `"grpc://localhost:4317" is not a valid value for debug.timings.otlp-endpoint: unsupported scheme "grpc", expected http or https`},
+		{"http:///v1/traces", `"http:///v1/traces" is not a valid value for debug.timings.otlp-endpoint: missing host`},
+	} {
+		err := configcore.Run(coreDev, &mockConf{
+			state:   s.state,
+			changes: map[string]any{"debug.timings.otlp-endpoint": tc.val},
+		})
+		c.Check(err, ErrorMatches, regexp.QuoteMeta(tc.err))
+	}
+}
@@ -26,6 +26,7 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/overlord/devicestate"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/strutil"
 	"github.com/snapcore/snapd/timeutil"
 )
@@ -43,6 +44,12 @@ func init() {
 	supportedConfigurations["core.refresh.retain"] = true
 	supportedConfigurations["core.refresh.rate-limit"] = true
 	supportedConfigurations["core.refresh.max-inhibition-days"] = true
+	supportedConfigurations["core.refresh.auto-revert"] = true
+	supportedConfigurations["core.refresh.auto-revert-window"] = true
+	supportedConfigurations["core.refresh.auto-revert-statuses"] = true
+	supportedConfigurations["core.refresh.blackout"] = true
+	supportedConfigurations["core.refresh.window-end-behavior"] = true
+	supportedConfigurations["core.refresh.rollout"] = true
 }
 
 func reportOrIgnoreInvalidManageRefreshes(tr RunTransaction, optName string) error {
@@ -99,6 +106,41 @@ func validateRefreshSchedule(tr RunTransaction) error {
 		return fmt.Errorf("refresh.metered value %q is invalid", refreshOnMeteredStr)
 	}
 
+	refreshBlackoutStr, err := coreCfg(tr, "refresh.blackout")
+	if err != nil {
+		return err
+	}
+	if refreshBlackoutStr != "" {
+		if _, err := timeutil.ParseDateRanges(refreshBlackoutStr); err != nil {
+			return fmt.Errorf("refresh.blackout cannot be parsed: %v", err)
+		}
+	}
+
+	windowEndBehaviorStr, err := coreCfg(tr, "refresh.window-end-behavior")
+	if err != nil {
+		return err
+	}
+	switch windowEndBehaviorStr {
+	case "", "continue", "defer":
+		// noop
+	default:
+		return fmt.Errorf("refresh.window-end-behavior must be continue or defer, not %q", windowEndBehaviorStr)
+	}
+
+	refreshRolloutStr, err := coreCfg(tr, "refresh.rollout")
+	if err != nil {
+		return err
+	}
+	if refreshRolloutStr != "" {
+		window, err := time.ParseDuration(refreshRolloutStr)
+		if err != nil {
+			return fmt.Errorf("refresh.rollout cannot be parsed: %v", err)
+		}
+		if window < 0 {
+			return fmt.Errorf("refresh.rollout cannot be negative, not %q", refreshRolloutStr)
+		}
+	}
+
 	// check (new) refresh.timer
 	refreshTimerStr, err := coreCfg(tr, "refresh.timer")
 	if err != nil {
@@ -163,3 +205,45 @@ func validateRefreshRateLimit(tr RunTransaction) error {
 	}
 	return nil
 }
+
+func validateRefreshAutoRevert(tr RunTransaction) error {
+	autoRevert, err := coreCfg(tr, "refresh.auto-revert")
+	if err != nil {
+		return err
+	}
+	switch autoRevert {
+	case "", "all", "none":
+		// noop
+	default:
+		for _, name := range strutil.CommaSeparatedList(autoRevert) {
+			if err := snap.ValidateInstanceName(name); err != nil {
+				return fmt.Errorf("refresh.auto-revert must be all, none or a comma-separated list of snaps: %v", err)
+			}
+		}
+	}
+
+	autoRevertWindow, err := coreCfg(tr, "refresh.auto-revert-window")
+	if err != nil {
+		return err
+	}
+	if autoRevertWindow != "" {
+		window, err := time.ParseDuration(autoRevertWindow)
+		if err != nil {
+			return fmt.Errorf("refresh.auto-revert-window cannot be parsed: %v", err)
+		}
+		if window <= 0 {
+			return fmt.Errorf("refresh.auto-revert-window must be positive, not %q", autoRevertWindow)
+		}
+	}
+
+	autoRevertStatuses, err := coreCfg(tr, "refresh.auto-revert-statuses")
+	if err != nil {
+		return err
+	}
+	for _, status := range strutil.CommaSeparatedList(autoRevertStatuses) {
+		if status != "error" && status != "blocked" {
+			return fmt.Errorf("refresh.auto-revert-statuses must be a comma-separated list of error and blocked, not %q", autoRevertStatuses)
+		}
+	}
+	return nil
+}
@@ -21,6 +21,7 @@
 package configcore_test
 
 import (
+	"regexp"
 	"time"
 
 	. "gopkg.in/check.v1"
@@ -252,3 +253,98 @@ func (s *refreshSuite) TestConfigureRefreshMaxInhibitionDays(c *C) {
 		}
 	}
 }
+
+func (s *refreshSuite) TestConfigureRefreshAutoRevert(c *C) {
+	data := []struct {
+		autoRevert, window, statuses any
+		err                          string
+	}{
+		{autoRevert: "foo,bar_1 baz", err: `refresh.auto-revert must be all, none or a comma-separated list of snaps: invalid instance key: "1 baz"`},
+		{autoRevert: "Foo", err: `refresh.auto-revert must be all, none or a comma-separated list of snaps: invalid snap name: "Foo"`},
+		{window: "1 hour", err: `refresh.auto-revert-window cannot be parsed: time: unknown unit " hour" in duration "1 hour"`},
+		{window: "-1h", err: `refresh.auto-revert-window must be positive, not "-1h"`},
+		{window: "0", err: `refresh.auto-revert-window must be positive, not "0"`},
+		{statuses: "error,waiting", err: `refresh.auto-revert-statuses must be a comma-separated list of error and blocked, not "error,waiting"`},
+		// happy cases
+		{autoRevert: ""},
+		{autoRevert: "all"},
+		{autoRevert: "none"},
+		{autoRevert: "foo"},
+		{autoRevert: "foo, bar_1", window: "1h"},
+		{window: "90m"},
+		{statuses: "error"},
+		{statuses: "error, blocked"},
+	}
+	for _, tc := range data {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"refresh.auto-revert":          tc.autoRevert,
+				"refresh.auto-revert-window":   tc.window,
+				"refresh.auto-revert-statuses": tc.statuses,
+			},
+		})
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, regexp.QuoteMeta(tc.err))
+		} else {
+			c.Check(err, IsNil)
+		}
+	}
+}
+
+func (s *refreshSuite) TestConfigureRefreshBlackoutAndWindowEndBehavior(c *C) {
+	data := []struct {
+		blackout, behavior any
+		err                string
+	}{
+		{blackout: "2026-11-20..2026-11-01", err: `refresh.blackout cannot be parsed: cannot parse "2026-11-20..2026-11-01": range ends before it starts`},
+		{blackout: "black-friday", err: `refresh.blackout cannot be parsed: cannot parse "black-friday": "black-friday" is not a valid date`},
+		{behavior: "stop", err: `refresh.window-end-behavior must be continue or defer, not "stop"`},
+		// happy cases
+		{blackout: "2026-11-20..2026-12-31"},
+		{blackout: "11-20..01-05,2027-07-04"},
+		{behavior: "continue"},
+		{behavior: "defer"},
+	}
+	for _, tc := range data {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"refresh.blackout":            tc.blackout,
+				"refresh.window-end-behavior": tc.behavior,
+			},
+		})
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, regexp.QuoteMeta(tc.err))
+		} else {
+			c.Check(err, IsNil)
+		}
+	}
+}
+
+func (s *refreshSuite) TestConfigureRefreshRollout(c *C) {
+	data := []struct {
+		rollout any
+		err     string
+	}{
+		{rollout: "3 days", err: `refresh.rollout cannot be parsed: time: unknown unit " days" in duration "3 days"`},
+		{rollout: "-72h", err: `refresh.rollout cannot be negative, not "-72h"`},
+		// happy cases
+		{rollout: ""},
+		{rollout: "0"},
+		{rollout: "72h"},
+	}
+	for _, tc := range data {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				"refresh.rollout": tc.rollout,
+			},
+		})
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, regexp.QuoteMeta(tc.err))
+		} else {
+			c.Check(err, IsNil)
+		}
+	}
+}
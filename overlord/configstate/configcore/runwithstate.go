@@ -59,7 +59,12 @@ func init() {
 	validateOnly := &flags{validatedOnlyStateConfig: true}
 	addWithStateHandler(validateRefreshSchedule, nil, validateOnly)
 	addWithStateHandler(validateRefreshRateLimit, nil, validateOnly)
+	addWithStateHandler(validateRefreshAutoRevert, nil, validateOnly)
 	addWithStateHandler(validateAutomaticSnapshotsExpiration, nil, validateOnly)
+	addWithStateHandler(validateScheduledSnapshots, nil, validateOnly)
+	addWithStateHandler(validateChangeArchive, nil, validateOnly)
+	addWithStateHandler(validateStorePeerCache, nil, validateOnly)
+	addWithStateHandler(validateStoreLocalRepository, nil, validateOnly)
 
 	// netplan.*
 	addWithStateHandler(validateNetplanSettings, handleNetplanConfiguration, coreOnly)
@@ -73,6 +78,9 @@ func init() {
 	// debug.systemd.log-level
 	addWithStateHandler(validateDebugSystemdLogLevelSetting, handleDebugSystemdLogLevelConfiguration, coreOnly)
 
+	// debug.timings.otlp-endpoint
+	addWithStateHandler(validateDebugTimingsOTLPEndpointSetting, nil, validateOnly)
+
 	// experimental.apparmor-prompting
 	addWithStateHandler(nil, doExperimentalApparmorPromptingDaemonRestart, nil)
 
@@ -22,12 +22,22 @@ package configcore
 
 import (
 	"fmt"
+	"strconv"
 	"time"
+
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
 )
 
 func init() {
 	// add supported configuration of this module
 	supportedConfigurations["core.snapshots.automatic.retention"] = true
+	supportedConfigurations["core.snapshots.schedule"] = true
+	supportedConfigurations["core.snapshots.scheduled.snaps"] = true
+	supportedConfigurations["core.snapshots.scheduled.keep-daily"] = true
+	supportedConfigurations["core.snapshots.scheduled.keep-weekly"] = true
+	supportedConfigurations["core.snapshots.scheduled.max-size"] = true
 }
 
 func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
@@ -46,3 +56,55 @@ func validateAutomaticSnapshotsExpiration(tr RunTransaction) error {
 	}
 	return nil
 }
+
+const maxScheduledSnapshotsKeep = 1000
+
+func validateScheduledSnapshots(tr RunTransaction) error {
+	scheduleStr, err := coreCfg(tr, "snapshots.schedule")
+	if err != nil {
+		return err
+	}
+	if scheduleStr != "" {
+		if _, err := timeutil.ParseSchedule(scheduleStr); err != nil {
+			return fmt.Errorf("snapshots.schedule cannot be parsed: %v", err)
+		}
+	}
+
+	snapsStr, err := coreCfg(tr, "snapshots.scheduled.snaps")
+	if err != nil {
+		return err
+	}
+	for _, name := range strutil.CommaSeparatedList(snapsStr) {
+		if err := naming.ValidateInstance(name); err != nil {
+			return fmt.Errorf("snapshots.scheduled.snaps is invalid: %v", err)
+		}
+	}
+
+	for _, opt := range []string{"snapshots.scheduled.keep-daily", "snapshots.scheduled.keep-weekly"} {
+		keepStr, err := coreCfg(tr, opt)
+		if err != nil {
+			return err
+		}
+		if keepStr == "" {
+			continue
+		}
+		if n, err := strconv.ParseUint(keepStr, 10, 16); err != nil || n > maxScheduledSnapshotsKeep {
+			return fmt.Errorf("%s must be a number between 0 and %d, not %q", opt, maxScheduledSnapshotsKeep, keepStr)
+		}
+	}
+
+	maxSizeStr, err := coreCfg(tr, "snapshots.scheduled.max-size")
+	if err != nil {
+		return err
+	}
+	if maxSizeStr != "" {
+		maxSize, err := strutil.ParseByteSize(maxSizeStr)
+		if err != nil {
+			return fmt.Errorf("snapshots.scheduled.max-size cannot be parsed: %v", err)
+		}
+		if maxSize <= 0 {
+			return fmt.Errorf("snapshots.scheduled.max-size must be greater than zero")
+		}
+	}
+	return nil
+}
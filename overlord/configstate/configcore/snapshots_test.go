@@ -71,3 +71,39 @@ func (s *refreshSuite) TestConfigureAutomaticSnapshotsExpirationInvalid(c *C) {
 	})
 	c.Assert(err, ErrorMatches, `snapshots.automatic.retention cannot be parsed:.*`)
 }
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsHappy(c *C) {
+	err := configcore.Run(classicDev, &mockConf{
+		state: s.state,
+		conf: map[string]any{
+			"snapshots.schedule":              "mon-fri,3:00",
+			"snapshots.scheduled.snaps":       "foo,bar_instance",
+			"snapshots.scheduled.keep-daily":  7,
+			"snapshots.scheduled.keep-weekly": "0",
+			"snapshots.scheduled.max-size":    "10GB",
+		},
+	})
+	c.Assert(err, IsNil)
+}
+
+func (s *snapshotsSuite) TestConfigureScheduledSnapshotsInvalid(c *C) {
+	for _, tc := range []struct {
+		key, value string
+		err        string
+	}{
+		{"snapshots.schedule", "invalid", `snapshots.schedule cannot be parsed: .*`},
+		{"snapshots.scheduled.snaps", "foo,-bar", `snapshots.scheduled.snaps is invalid: invalid snap name: "-bar"`},
+		{"snapshots.scheduled.keep-daily", "-1", `snapshots.scheduled.keep-daily must be a number between 0 and 1000, not "-1"`},
+		{"snapshots.scheduled.keep-weekly", "1001", `snapshots.scheduled.keep-weekly must be a number between 0 and 1000, not "1001"`},
+		{"snapshots.scheduled.max-size", "lots", `snapshots.scheduled.max-size cannot be parsed: .*`},
+		{"snapshots.scheduled.max-size", "0B", `snapshots.scheduled.max-size must be greater than zero`},
+	} {
+		err := configcore.Run(classicDev, &mockConf{
+			state: s.state,
+			conf: map[string]any{
+				tc.key: tc.value,
+			},
+		})
+		c.Check(err, ErrorMatches, tc.err, Commentf("%s=%s", tc.key, tc.value))
+	}
+}
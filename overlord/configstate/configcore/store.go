@@ -22,6 +22,7 @@ package configcore
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -32,6 +33,8 @@ import (
 
 func init() {
 	supportedConfigurations["core.store.access"] = true
+	supportedConfigurations["core.store.peer-cache"] = true
+	supportedConfigurations["core.store.local-repository"] = true
 }
 
 func validateStoreAccess(cfg ConfGetter) error {
@@ -48,6 +51,30 @@ func validateStoreAccess(cfg ConfGetter) error {
 	}
 }
 
+// validateStorePeerCache checks store.peer-cache. Sharing the download cache
+// with other devices on the local network is off unless the option is
+// explicitly set to true. Once enabled, snapd advertises its download cache
+// over mDNS, serves to peers the cached blobs they ask for by sha3-384 digest
+// and tries to fetch blobs from peers before the store. Blobs from peers are
+// only used if they match the sha3-384 digest of their snap-revision or
+// snap-resource-revision assertion.
+func validateStorePeerCache(tr RunTransaction) error {
+	return validateBoolFlag(tr, "store.peer-cache")
+}
+
+// validateStoreLocalRepository checks store.local-repository, the directory
+// snaps and assertions are read from instead of the store.
+func validateStoreLocalRepository(tr RunTransaction) error {
+	dir, err := coreCfg(tr, "store.local-repository")
+	if err != nil {
+		return err
+	}
+	if dir != "" && !filepath.IsAbs(dir) {
+		return fmt.Errorf("store.local-repository must be an absolute path: %q", dir)
+	}
+	return nil
+}
+
 // repairConfig is a set of configuration data that is consumed by the
 // snap-repair command. This struct is duplicated in cmd/snap-repair.
 type repairConfig struct {
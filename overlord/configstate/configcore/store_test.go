@@ -77,6 +77,46 @@ func (s *storeSuite) TestStoreAccessUnhappy(c *C) {
 	c.Assert(err, ErrorMatches, ".*store access can only be set to 'offline'")
 }
 
+func (s *storeSuite) TestStorePeerCache(c *C) {
+	for _, value := range []string{"true", "false", ""} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"store.peer-cache": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%q", value))
+	}
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]any{
+			"store.peer-cache": "maybe",
+		},
+	})
+	c.Assert(err, ErrorMatches, "store.peer-cache can only be set to 'true' or 'false'")
+}
+
+func (s *storeSuite) TestStoreLocalRepository(c *C) {
+	for _, value := range []string{"/media/usb/snaps", ""} {
+		err := configcore.Run(coreDev, &mockConf{
+			state: s.state,
+			changes: map[string]any{
+				"store.local-repository": value,
+			},
+		})
+		c.Check(err, IsNil, Commentf("%q", value))
+	}
+
+	err := configcore.Run(coreDev, &mockConf{
+		state: s.state,
+		changes: map[string]any{
+			"store.local-repository": "media/usb",
+		},
+	})
+	c.Assert(err, ErrorMatches, `store.local-repository must be an absolute path: "media/usb"`)
+}
+
 func (s *storeSuite) TestFilesystemOnlyApply(c *C) {
 	conf := configcore.PlainCoreConfig(map[string]any{
 		"store.access": "offline",
@@ -2277,6 +2277,17 @@ func (m *DeviceManager) SignConfdbControl(groups []any, revision int) (*asserts.
 	return a.(*asserts.ConfdbControl), nil
 }
 
+// SignWithDeviceKey signs the given data with the device's key, so that the
+// signature can be verified with the public key in the serial assertion.
+func (m *DeviceManager) SignWithDeviceKey(data []byte) ([]byte, error) {
+	privKey, err := m.keyPair()
+	if err != nil {
+		return nil, fmt.Errorf("cannot sign without device key")
+	}
+
+	return asserts.RawSignWithKey(data, privKey)
+}
+
 // SignResponseMessage signs a response-message assertion using the device's key.
 func (m *DeviceManager) SignResponseMessage(accountID, messageID string, status asserts.MessageStatus, body []byte) (*asserts.ResponseMessage, error) {
 	serial, err := m.Serial()
@@ -298,6 +298,7 @@ func delayedCrossMgrInit() {
 	snapstate.DeviceCtx = DeviceCtx
 	snapstate.RemodelingChange = RemodelingChange
 	snapstate.SeedRefreshTasks = SeedRefreshTasks
+	snapstate.DeviceSerial = Serial
 }
 
 // proxyStore returns the store assertion for the proxy store if one is set.
@@ -3347,6 +3347,30 @@ func (s *deviceMgrSuite) TestSignResponseMessageInvalid(c *C) {
 	)
 }
 
+func (s *deviceMgrSuite) TestSignWithDeviceKey(c *C) {
+	s.setPCModelInState(c)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeSerialAssertionInState(c, "canonical", "pc", "serialserialserial")
+	s.addKeyToManagerInState(c)
+
+	sig, err := s.mgr.SignWithDeviceKey([]byte("data"))
+	c.Assert(err, IsNil)
+	c.Check(asserts.RawVerifyWithKey([]byte("data"), sig, devKey.PublicKey()), IsNil)
+}
+
+func (s *deviceMgrSuite) TestSignWithDeviceKeyNoKey(c *C) {
+	s.setPCModelInState(c)
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.makeSerialAssertionInState(c, "canonical", "pc", "serialserialserial")
+
+	_, err := s.mgr.SignWithDeviceKey([]byte("data"))
+	c.Assert(err, ErrorMatches, "cannot sign without device key")
+}
+
 type myStateDeviceInitialized struct {
 	called int
 }
@@ -116,3 +116,9 @@ func MockSystemdSdNotify(f func(notifyState string) error) (restore func()) {
 		systemdSdNotify = old
 	}
 }
+
+var NewStateBackend = newStateBackend
+
+func MockMinJournalCompactionSize(size int64) (restore func()) {
+	return testutil.Mock(&minJournalCompactionSize, size)
+}
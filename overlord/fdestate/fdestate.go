@@ -20,12 +20,17 @@ package fdestate
 
 import (
 	"crypto"
+	"crypto/sha256"
 	"errors"
 	"fmt"
+	"io"
+	"path/filepath"
 	"sort"
 	"strings"
 	"time"
 
+	"golang.org/x/crypto/hkdf"
+
 	"github.com/snapcore/snapd/asserts"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/gadget/device"
@@ -38,6 +43,7 @@ import (
 )
 
 var (
+	secbootGetPrimaryKey          = secboot.GetPrimaryKey
 	secbootGetPrimaryKeyDigest    = secboot.GetPrimaryKeyDigest
 	secbootVerifyPrimaryKeyDigest = secboot.VerifyPrimaryKeyDigest
 	secbootGetPCRHandle           = secboot.GetPCRHandle
@@ -405,6 +411,16 @@ func MockGetPrimaryKeyDigest(f func(devicePath string, alg crypto.Hash) ([]byte,
 	}
 }
 
+func MockSecbootGetPrimaryKey(f func(devices []string, fallbackKeyFiles []string) ([]byte, error)) (restore func()) {
+	osutil.MustBeTestBinary("mocking secboot.GetPrimaryKey can be done only from tests")
+
+	old := secbootGetPrimaryKey
+	secbootGetPrimaryKey = f
+	return func() {
+		secbootGetPrimaryKey = old
+	}
+}
+
 func MockVerifyPrimaryKeyDigest(f func(devicePath string, alg crypto.Hash, salt []byte, digest []byte) (bool, error)) (restore func()) {
 	osutil.MustBeTestBinary("mocking VerifyPrimaryKeyDigest can be done only from tests")
 
@@ -810,6 +826,50 @@ func SystemEncryptedFromState(st *state.State) (bool, error) {
 	return encrypted, nil
 }
 
+// DeriveKey returns a key for the given purpose derived from the primary key
+// of the encrypted containers of the system. The primary key is only
+// available while the containers are unlocked with their sealed keys, so this
+// lets snapd keep secrets on disk that are protected the same way. The state
+// needs to be locked by the caller.
+func DeriveKey(st *state.State, purpose string) ([]byte, error) {
+	mgr := fdeMgr(st)
+	encrypted, err := mgr.systemEncrypted()
+	if err != nil {
+		return nil, fmt.Errorf("cannot determine if system is encrypted: %v", err)
+	}
+	if !encrypted {
+		return nil, fmt.Errorf("system data is not encrypted")
+	}
+
+	containers, err := mgr.GetEncryptedContainers()
+	if err != nil {
+		return nil, err
+	}
+	var devices []string
+	for _, container := range containers {
+		devices = append(devices, container.DevPath())
+	}
+	saveFDEDir := dirs.SnapFDEDirUnderSave(dirs.SnapSaveDir)
+	fallbackPrimaryKeyFiles := []string{
+		filepath.Join(saveFDEDir, "aux-key"),
+		filepath.Join(saveFDEDir, "tpm-policy-auth-key"),
+	}
+	primaryKey, err := secbootGetPrimaryKey(devices, fallbackPrimaryKeyFiles)
+	if err != nil {
+		return nil, fmt.Errorf("cannot get primary key: %v", err)
+	}
+	// the primary key with ID 0 is the one managed by snapd
+	if !mgr.VerifyPrimaryKeyAgainstState(0, primaryKey) {
+		return nil, fmt.Errorf("primary key is not matching the FDE state")
+	}
+
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, primaryKey, nil, []byte("snapd "+purpose)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
 type volumesAuthOptionsKey struct{}
 
 // ReplacePlatformKey creates a taskset that replaces the
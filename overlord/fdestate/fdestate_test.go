@@ -21,6 +21,9 @@
 package fdestate_test
 
 import (
+	"crypto"
+	"crypto/hmac"
+	"crypto/sha256"
 	"errors"
 	"fmt"
 	"os"
@@ -695,6 +698,67 @@ func (s *fdeMgrSuite) TestSystemEncryptedFromStateNoEncryptedDisks(c *C) {
 	s.testSystemEncryptedFromState(c, hasEncryptedDisks)
 }
 
+func (s *fdeMgrSuite) TestDeriveKey(c *C) {
+	const onClassic = true
+	s.startedManager(c, onClassic)
+
+	st := s.st
+	st.Lock()
+	defer st.Unlock()
+
+	primaryKey := []byte("primary-key")
+	var fdeSt fdestate.FdeState
+	c.Assert(st.Get("fde", &fdeSt), IsNil)
+	salt := []byte("salt")
+	h := hmac.New(sha256.New, salt)
+	h.Write(primaryKey)
+	fdeSt.PrimaryKeys[0] = fdestate.PrimaryKeyInfo{
+		Digest: fdestate.KeyDigest{Algorithm: secboot.HashAlg(crypto.SHA256), Salt: salt, Digest: h.Sum(nil)},
+	}
+	st.Set("fde", fdeSt)
+
+	defer fdestate.MockSecbootGetPrimaryKey(func(devices []string, fallbackKeyFiles []string) ([]byte, error) {
+		c.Check(devices, DeepEquals, []string{"/dev/disk/by-uuid/aaa", "/dev/disk/by-uuid/bbb"})
+		c.Check(fallbackKeyFiles, DeepEquals, []string{
+			filepath.Join(dirs.SnapSaveDir, "device/fde/aux-key"),
+			filepath.Join(dirs.SnapSaveDir, "device/fde/tpm-policy-auth-key"),
+		})
+		return primaryKey, nil
+	})()
+	defer fdestate.MockDisksDMCryptUUIDFromMountPoint(func(mountpoint string) (string, error) {
+		if mountpoint == dirs.SnapSaveDir {
+			return "bbb", nil
+		}
+		return "aaa", nil
+	})()
+
+	key, err := fdestate.DeriveKey(st, "some-purpose")
+	c.Assert(err, IsNil)
+	c.Check(key, HasLen, 32)
+	again, err := fdestate.DeriveKey(st, "some-purpose")
+	c.Assert(err, IsNil)
+	c.Check(again, DeepEquals, key)
+	other, err := fdestate.DeriveKey(st, "other-purpose")
+	c.Assert(err, IsNil)
+	c.Check(other, Not(DeepEquals), key)
+
+	primaryKey = []byte("other-primary-key")
+	_, err = fdestate.DeriveKey(st, "some-purpose")
+	c.Check(err, ErrorMatches, "primary key is not matching the FDE state")
+}
+
+func (s *fdeMgrSuite) TestDeriveKeyErrors(c *C) {
+	const onClassic = true
+	s.startedManagerNoEncryptedDisks(c, onClassic)
+
+	st := s.st
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := fdestate.DeriveKey(st, "some-purpose")
+	c.Check(err, ErrorMatches, "system data is not encrypted")
+}
+
 func (s *fdeMgrSuite) testReplacePlatformKey(c *C, authMode device.AuthMode, defaultKeyslots bool) {
 	keyslots := []fdestate.KeyslotRef{
 		{ContainerRole: "system-data", Name: "default"},
@@ -0,0 +1,274 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var revertSnapChangeKind = swfeats.RegisterChangeKind("revert-snap")
+
+func init() {
+	swfeats.RegisterEnsure("HealthManager", "ensureAutoRevert")
+}
+
+// defaultAutoRevertWindow is how long after a refresh an error health status
+// reported by the snap leads to the refresh being reverted, unless configured
+// otherwise with the refresh.auto-revert-window core option.
+const defaultAutoRevertWindow = 30 * time.Minute
+
+var timeNow = time.Now
+
+// autoRevertPolicy is the policy configured with the refresh.auto-revert core
+// option, which is either "all" or a comma-separated list of the snaps to
+// which it applies. Without the option, the policy set by the auto-revert
+// header of the model applies, if any.
+type autoRevertPolicy struct {
+	all      bool
+	snaps    []string
+	window   time.Duration
+	statuses []HealthStatus
+}
+
+func (p *autoRevertPolicy) appliesTo(instanceName string) bool {
+	return p.all || strutil.ListContains(p.snaps, instanceName)
+}
+
+// triggeredBy returns whether the given health status leads to a revert,
+// by default only the error one does unless configured otherwise with the
+// refresh.auto-revert-statuses core option.
+func (p *autoRevertPolicy) triggeredBy(status HealthStatus) bool {
+	for _, s := range p.statuses {
+		if s == status {
+			return true
+		}
+	}
+	return false
+}
+
+func modelAutoRevertPolicy(st *state.State, policy *autoRevertPolicy) error {
+	deviceCtx, err := snapstate.DeviceCtx(st, nil, nil)
+	if err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			// no model yet
+			return nil
+		}
+		return err
+	}
+	policy.snaps, policy.all = deviceCtx.Model().AutoRevertSnaps()
+	return nil
+}
+
+func getAutoRevertPolicy(st *state.State) (*autoRevertPolicy, error) {
+	tr := config.NewTransaction(st)
+
+	var value string
+	if err := tr.GetMaybe("core", "refresh.auto-revert", &value); err != nil {
+		return nil, err
+	}
+	policy := &autoRevertPolicy{window: defaultAutoRevertWindow}
+	switch value {
+	case "none":
+		return policy, nil
+	case "":
+		if err := modelAutoRevertPolicy(st, policy); err != nil {
+			return nil, err
+		}
+		if !policy.all && len(policy.snaps) == 0 {
+			return policy, nil
+		}
+	case "all":
+		policy.all = true
+	default:
+		policy.snaps = strutil.CommaSeparatedList(value)
+	}
+
+	var window string
+	if err := tr.GetMaybe("core", "refresh.auto-revert-window", &window); err != nil {
+		return nil, err
+	}
+	if window != "" {
+		d, err := time.ParseDuration(window)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse refresh.auto-revert-window: %v", err)
+		}
+		policy.window = d
+	}
+
+	var statuses string
+	if err := tr.GetMaybe("core", "refresh.auto-revert-statuses", &statuses); err != nil {
+		return nil, err
+	}
+	if statuses == "" {
+		statuses = "error"
+	}
+	for _, str := range strutil.CommaSeparatedList(statuses) {
+		status, err := StatusLookup(str)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse refresh.auto-revert-statuses: %v", err)
+		}
+		policy.statuses = append(policy.statuses, status)
+	}
+	return policy, nil
+}
+
+// autoRevert records a revert of a snap started because of its health.
+type autoRevert struct {
+	// Revision is the revision which was reverted.
+	Revision snap.Revision `json:"revision"`
+	ChangeID string        `json:"change-id,omitempty"`
+	Time     time.Time     `json:"time"`
+}
+
+func autoReverts(st *state.State) (map[string]*autoRevert, error) {
+	var reverts map[string]*autoRevert
+	if err := st.Get("health-auto-reverts", &reverts); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if reverts == nil {
+		reverts = make(map[string]*autoRevert)
+	}
+	return reverts, nil
+}
+
+// ensureAutoRevert reverts the snaps to which the auto-revert policy applies
+// and which reported an error, or otherwise configured, health status shortly
+// after being refreshed.
+func (m *HealthManager) ensureAutoRevert() error {
+	logger.Trace("ensure", "manager", "HealthManager", "func", "ensureAutoRevert")
+	policy, err := getAutoRevertPolicy(m.state)
+	if err != nil {
+		return err
+	}
+	if !policy.all && len(policy.snaps) == 0 {
+		return nil
+	}
+
+	healths, err := All(m.state)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(healths))
+	for name, health := range healths {
+		if policy.triggeredBy(health.Status) && policy.appliesTo(name) {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	sort.Strings(names)
+
+	reverts, err := autoReverts(m.state)
+	if err != nil {
+		return err
+	}
+	var errs []string
+	for _, name := range names {
+		if err := maybeAutoRevert(m.state, name, healths[name], policy.window, reverts); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("cannot revert snaps with failed health checks: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
+
+func maybeAutoRevert(st *state.State, name string, health *HealthState, window time.Duration, reverts map[string]*autoRevert) error {
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, name, &snapst); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil
+		}
+		return err
+	}
+	if !snapst.Active || health.Revision != snapst.Current {
+		return nil
+	}
+	// only the revision the snap was last refreshed to is reverted, which
+	// is the last one of the sequence unlike one it was reverted to
+	idx := snapst.LastIndex(snapst.Current)
+	if idx <= 0 || idx != len(snapst.Sequence.Revisions)-1 {
+		return nil
+	}
+	if snapst.LastRefreshTime == nil || health.Timestamp.Before(*snapst.LastRefreshTime) ||
+		health.Timestamp.After(snapst.LastRefreshTime.Add(window)) {
+		return nil
+	}
+	if prev := reverts[name]; prev != nil && prev.Revision == snapst.Current {
+		// reverting was already attempted
+		return nil
+	}
+	prevRev := snapst.Sequence.Revisions[idx-1].Snap.Revision
+
+	ts, err := snapstate.Revert(st, name, snapstate.Flags{}, "")
+	if err != nil {
+		var conflictErr *snapstate.ChangeConflictError
+		if errors.As(err, &conflictErr) {
+			// the refresh itself, or another change, is still in
+			// progress, try again later
+			return nil
+		}
+		reverts[name] = &autoRevert{Revision: snapst.Current, Time: timeNow()}
+		st.Set("health-auto-reverts", reverts)
+		return fmt.Errorf("cannot revert %q: %v", name, err)
+	}
+
+	reason := health.Message
+	if reason == "" {
+		reason = fmt.Sprintf("health status %s", health.Status)
+	}
+	summary := fmt.Sprintf("Revert %q snap to revision %s after failed health check", name, prevRev)
+	chg := st.NewChange(revertSnapChangeKind, summary)
+	chg.AddAll(ts)
+	chg.Set("snap-names", []string{name})
+
+	reverts[name] = &autoRevert{Revision: snapst.Current, ChangeID: chg.ID(), Time: timeNow()}
+	st.Set("health-auto-reverts", reverts)
+
+	st.Warnf("snap %q is being reverted from revision %s to %s as its health check failed after being refreshed: %s",
+		name, snapst.Current, prevRev, reason)
+	data := map[string]string{
+		"from-revision": snapst.Current.String(),
+		"to-revision":   prevRev.String(),
+		"change-id":     chg.ID(),
+		"message":       reason,
+	}
+	if _, err := st.AddNotice(nil, state.SnapAutoRevertNotice, name, &state.AddNoticeOptions{Data: data}); err != nil {
+		logger.Noticef("cannot add notice about the revert of %q: %v", name, err)
+	}
+	logger.Noticef("reverting %q from revision %s to %s after failed health check: %s", name, snapst.Current, prevRev, reason)
+
+	st.EnsureBefore(0)
+	return nil
+}
@@ -0,0 +1,279 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate_test
+
+import (
+	"encoding/json"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store/storetest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type autoRevertSuite struct {
+	testutil.BaseTest
+
+	state *state.State
+	mgr   *healthstate.HealthManager
+	now   time.Time
+}
+
+var _ = check.Suite(&autoRevertSuite{})
+
+func (s *autoRevertSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.state = state.New(nil)
+	s.mgr = healthstate.Manager(s.state, nil)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.AddCleanup(snapstatetest.UseFallbackDeviceModel())
+	snapstate.ReplaceStore(s.state, storetest.Store{})
+
+	refreshTime := s.now.Add(-10 * time.Minute)
+	var sideInfos []*snap.SideInfo
+	for _, rev := range []int{41, 42} {
+		si := &snap.SideInfo{RealName: "test-snap", Revision: snap.R(rev), SnapID: "test-snap-id"}
+		snaptest.MockSnap(c, "{name: test-snap, version: v1}", si)
+		sideInfos = append(sideInfos, si)
+	}
+	snapstate.Set(s.state, "test-snap", &snapstate.SnapState{
+		Sequence:        snapstatetest.NewSequenceFromSnapSideInfos(sideInfos),
+		Current:         snap.R(42),
+		Active:          true,
+		SnapType:        "app",
+		LastRefreshTime: &refreshTime,
+	})
+}
+
+func (s *autoRevertSuite) setAutoRevert(c *check.C, value, window string) {
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.auto-revert", value), check.IsNil)
+	if window != "" {
+		c.Assert(tr.Set("core", "refresh.auto-revert-window", window), check.IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *autoRevertSuite) setHealth(status healthstate.HealthStatus, rev int, timestamp time.Time) {
+	s.state.Set("health", map[string]*healthstate.HealthState{
+		"test-snap": {
+			Revision:  snap.R(rev),
+			Timestamp: timestamp,
+			Status:    status,
+			Message:   "cannot reach the database",
+			Code:      "db-unreachable",
+		},
+	})
+}
+
+func (s *autoRevertSuite) ensure(c *check.C) {
+	s.state.Unlock()
+	defer s.state.Lock()
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+}
+
+func (s *autoRevertSuite) TestAutoRevert(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAutoRevert(c, "other-snap,test-snap", "")
+	s.setHealth(healthstate.ErrorStatus, 42, s.now.Add(-time.Minute))
+
+	s.ensure(c)
+
+	chgs := s.state.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "revert-snap")
+	c.Check(chg.Summary(), check.Equals, `Revert "test-snap" snap to revision 41 after failed health check`)
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"test-snap"})
+
+	var snapsup snapstate.SnapSetup
+	c.Assert(chg.Tasks()[0].Get("snap-setup", &snapsup), check.IsNil)
+	c.Check(snapsup.Revision(), check.Equals, snap.R(41))
+	c.Check(snapsup.Flags.Revert, check.Equals, true)
+
+	warnings := s.state.AllWarnings()
+	c.Assert(warnings, check.HasLen, 1)
+	c.Check(warnings[0].String(), check.Equals, `snap "test-snap" is being reverted from revision 42 to 41 as its health check failed after being refreshed: cannot reach the database`)
+
+	notices := s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapAutoRevertNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["key"], check.Equals, "test-snap")
+	c.Check(n["last-data"], check.DeepEquals, map[string]any{
+		"from-revision": "42",
+		"to-revision":   "41",
+		"change-id":     chg.ID(),
+		"message":       "cannot reach the database",
+	})
+
+	// reverting is attempted only once
+	chg.SetStatus(state.ErrorStatus)
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 1)
+}
+
+func (s *autoRevertSuite) TestAutoRevertAll(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAutoRevert(c, "all", "")
+	s.setHealth(healthstate.ErrorStatus, 42, s.now)
+
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 1)
+}
+
+func (s *autoRevertSuite) TestAutoRevertConfiguredStatuses(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAutoRevert(c, "all", "")
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.auto-revert-statuses", "error,blocked"), check.IsNil)
+	tr.Commit()
+	s.setHealth(healthstate.BlockedStatus, 42, s.now)
+
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 1)
+}
+
+func (s *autoRevertSuite) TestAutoRevertModelPolicy(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	model := assertstest.FakeAssertion(map[string]any{
+		"type":         "model",
+		"authority-id": "my-brand",
+		"series":       "16",
+		"brand-id":     "my-brand",
+		"model":        "my-model",
+		"architecture": "amd64",
+		"gadget":       "pc",
+		"kernel":       "pc-kernel",
+		"auto-revert":  []any{"test-snap"},
+	}).(*asserts.Model)
+	s.AddCleanup(snapstatetest.MockDeviceModel(model))
+	s.setHealth(healthstate.ErrorStatus, 42, s.now)
+
+	// the configuration takes precedence
+	s.setAutoRevert(c, "none", "")
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+
+	s.setAutoRevert(c, "", "")
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 1)
+}
+
+func (s *autoRevertSuite) TestNoAutoRevert(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, tc := range []struct {
+		policy, window string
+		status         healthstate.HealthStatus
+		rev            int
+		timestamp      time.Time
+		comment        string
+	}{
+		{"", "", healthstate.ErrorStatus, 42, s.now, "not configured"},
+		{"none", "", healthstate.ErrorStatus, 42, s.now, "disabled"},
+		{"other-snap", "", healthstate.ErrorStatus, 42, s.now, "other snap"},
+		{"all", "", healthstate.BlockedStatus, 42, s.now, "not an error"},
+		{"all", "", healthstate.ErrorStatus, 41, s.now, "not the current revision"},
+		{"all", "", healthstate.ErrorStatus, 42, s.now.Add(-time.Hour), "before the refresh"},
+		{"all", "5m", healthstate.ErrorStatus, 42, s.now, "after the window"},
+	} {
+		s.setAutoRevert(c, tc.policy, tc.window)
+		s.setHealth(tc.status, tc.rev, tc.timestamp)
+		s.ensure(c)
+		c.Check(s.state.Changes(), check.HasLen, 0, check.Commentf(tc.comment))
+	}
+}
+
+func (s *autoRevertSuite) TestNoAutoRevertAfterRevert(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// the snap was reverted to revision 41 already
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	snapst.Current = snap.R(41)
+	snapstate.Set(s.state, "test-snap", &snapst)
+
+	s.setAutoRevert(c, "all", "")
+	s.setHealth(healthstate.ErrorStatus, 41, s.now)
+
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 0)
+}
+
+func (s *autoRevertSuite) TestAutoRevertConflict(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setAutoRevert(c, "all", "")
+	s.setHealth(healthstate.ErrorStatus, 42, s.now)
+
+	// the refresh is still in progress
+	chg := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("run-hook", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap", Revision: snap.R(42)}})
+	chg.AddTask(t)
+
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 1)
+
+	t.SetStatus(state.DoneStatus)
+	s.ensure(c)
+	c.Check(s.state.Changes(), check.HasLen, 2)
+}
+
+func noticeToMap(c *check.C, notice *state.Notice) map[string]any {
+	buf, err := json.Marshal(notice)
+	c.Assert(err, check.IsNil)
+	var n map[string]any
+	c.Assert(json.Unmarshal(buf, &n), check.IsNil)
+	return n
+}
@@ -20,7 +20,10 @@
 package healthstate
 
 import (
+	"context"
 	"time"
+
+	"github.com/snapcore/snapd/overlord/hookstate"
 )
 
 func MockCheckTimeout(t time.Duration) (restore func()) {
@@ -32,3 +35,19 @@ func MockCheckTimeout(t time.Duration) (restore func()) {
 }
 
 var KnownStatuses = knownStatuses
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
+func MockEphemeralRunHook(f func(hookMgr *hookstate.HookManager, ctx context.Context, hooksup *hookstate.HookSetup) error) (restore func()) {
+	old := ephemeralRunHook
+	ephemeralRunHook = f
+	return func() {
+		ephemeralRunHook = old
+	}
+}
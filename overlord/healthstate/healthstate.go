@@ -20,11 +20,13 @@
 package healthstate
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
 	"regexp"
+	"sync"
 	"time"
 
 	"github.com/snapcore/snapd/logger"
@@ -96,12 +98,74 @@ type HealthState struct {
 	Status    HealthStatus  `json:"status"`
 	Message   string        `json:"message,omitempty"`
 	Code      string        `json:"code,omitempty"`
+	// RecheckTime is when the snap asked for the check-health hook to be
+	// run again, with snapctl set-health --recheck-in.
+	RecheckTime time.Time `json:"recheck-time,omitzero"`
 }
 
+// maxHealthHistory is how many of the health states last reported by a snap
+// are kept.
+const maxHealthHistory = 20
+
 func Init(hookManager *hookstate.HookManager) {
 	hookManager.Register(regexp.MustCompile("^check-health$"), newHealthHandler)
 }
 
+// HealthManager runs the check-health hook of snaps periodically and acts on
+// the health they report.
+type HealthManager struct {
+	state   *state.State
+	hookMgr *hookstate.HookManager
+
+	intervals map[string]checkInterval
+
+	// checks holds the snaps whose periodic check is running
+	checks       map[string]bool
+	checksWg     sync.WaitGroup
+	checksCtx    context.Context
+	cancelChecks func()
+}
+
+// Manager returns a new HealthManager.
+func Manager(st *state.State, hookManager *hookstate.HookManager) *HealthManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &HealthManager{
+		state:        st,
+		hookMgr:      hookManager,
+		intervals:    make(map[string]checkInterval),
+		checks:       make(map[string]bool),
+		checksCtx:    ctx,
+		cancelChecks: cancel,
+	}
+}
+
+// Ensure implements StateManager.Ensure.
+func (m *HealthManager) Ensure() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	var errs []error
+	if err := m.ensurePeriodicChecks(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := m.ensureAutoRevert(); err != nil {
+		errs = append(errs, err)
+	}
+	return strutil.JoinErrors(errs...)
+}
+
+// Wait implements StateWaiter.Wait. It waits for the running periodic checks
+// to finish.
+func (m *HealthManager) Wait() {
+	m.checksWg.Wait()
+}
+
+// Stop implements StateStopper.Stop. It stops the running periodic checks.
+func (m *HealthManager) Stop() {
+	m.cancelChecks()
+	m.checksWg.Wait()
+}
+
 func newHealthHandler(ctx *hookstate.Context) hookstate.Handler {
 	return &healthHandler{context: ctx}
 }
@@ -161,12 +225,16 @@ func (h *healthHandler) appendHealth(health *HealthState) error {
 	st.Lock()
 	defer st.Unlock()
 
-	return appendHealth(h.context, health)
+	return appendHealth(st, h.context.InstanceName(), health)
 }
 
-func appendHealth(ctx *hookstate.Context, health *HealthState) error {
-	st := ctx.State()
+// Set records the health of a snap reported other than through its hooks.
+// Must be called with the state lock held.
+func Set(st *state.State, snapName string, health *HealthState) error {
+	return appendHealth(st, snapName, health)
+}
 
+func appendHealth(st *state.State, name string, health *HealthState) error {
 	var hs map[string]*HealthState
 	if err := st.Get("health", &hs); err != nil {
 		if !errors.Is(err, state.ErrNoState) {
@@ -174,12 +242,49 @@ func appendHealth(ctx *hookstate.Context, health *HealthState) error {
 		}
 		hs = map[string]*HealthState{}
 	}
-	hs[ctx.InstanceName()] = health
+	prev := hs[name]
+	hs[name] = health
 	st.Set("health", hs)
 
+	var history map[string][]*HealthState
+	if err := st.Get("health-history", &history); err != nil {
+		if !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		history = map[string][]*HealthState{}
+	}
+	history[name] = append(history[name], health)
+	if n := len(history[name]); n > maxHealthHistory {
+		history[name] = history[name][n-maxHealthHistory:]
+	}
+	st.Set("health-history", history)
+
+	if (prev == nil && health.Status != OkayStatus) || (prev != nil && prev.Status != health.Status) {
+		addHealthNotice(st, name, prev, health)
+	}
+
 	return nil
 }
 
+func addHealthNotice(st *state.State, name string, prev, health *HealthState) {
+	data := map[string]string{
+		"status":   health.Status.String(),
+		"revision": health.Revision.String(),
+	}
+	if prev != nil {
+		data["previous-status"] = prev.Status.String()
+	}
+	if health.Message != "" {
+		data["message"] = health.Message
+	}
+	if health.Code != "" {
+		data["code"] = health.Code
+	}
+	if _, err := st.AddNotice(nil, state.SnapHealthNotice, name, &state.AddNoticeOptions{Data: data}); err != nil {
+		logger.Noticef("cannot add notice about the health of %q: %v", name, err)
+	}
+}
+
 // SetFromHookContext extracts the health of a snap from a hook
 // context, and saves it in snapd's state.
 // Must be called with the context lock held.
@@ -193,7 +298,7 @@ func SetFromHookContext(ctx *hookstate.Context) error {
 		}
 		return err
 	}
-	return appendHealth(ctx, &health)
+	return appendHealth(ctx.State(), ctx.InstanceName(), &health)
 }
 
 func All(st *state.State) (map[string]*HealthState, error) {
@@ -204,6 +309,16 @@ func All(st *state.State) (map[string]*HealthState, error) {
 	return hs, nil
 }
 
+// History returns the health states last reported by the given snap, oldest
+// first.
+func History(st *state.State, snap string) ([]*HealthState, error) {
+	var history map[string][]*HealthState
+	if err := st.Get("health-history", &history); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	return history[snap], nil
+}
+
 func Get(st *state.State, snap string) (*HealthState, error) {
 	var hs map[string]json.RawMessage
 	if err := st.Get("health", &hs); err != nil {
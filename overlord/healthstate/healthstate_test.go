@@ -253,3 +253,80 @@ func (s *healthSuite) TestSetFromHookContextEmpty(c *check.C) {
 	// no health in the context -> no health in state
 	c.Check(s.state.Get("health", &hs), testutil.ErrorIs, state.ErrNoState)
 }
+
+func (s *healthSuite) TestSetFromHookContextHistoryAndNotices(c *check.C) {
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "foo", Revision: snap.R(7)}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	t0 := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	statuses := []healthstate.HealthStatus{
+		healthstate.OkayStatus,
+		healthstate.OkayStatus,
+		healthstate.WaitingStatus,
+		healthstate.WaitingStatus,
+		healthstate.OkayStatus,
+	}
+	for i := 0; i < 25; i++ {
+		health := &healthstate.HealthState{
+			Revision:  snap.R(7),
+			Timestamp: t0.Add(time.Duration(i) * time.Minute),
+			Status:    statuses[i%len(statuses)],
+		}
+		if health.Status != healthstate.OkayStatus {
+			health.Message = "waiting for the network"
+		}
+		ctx.Set("health", health)
+		c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+	}
+
+	history, err := healthstate.History(s.state, "foo")
+	c.Assert(err, check.IsNil)
+	c.Assert(history, check.HasLen, 20)
+	c.Check(history[0].Timestamp.Equal(t0.Add(5*time.Minute)), check.Equals, true)
+	c.Check(history[19].Timestamp.Equal(t0.Add(24*time.Minute)), check.Equals, true)
+
+	history, err = healthstate.History(s.state, "other")
+	c.Assert(err, check.IsNil)
+	c.Check(history, check.HasLen, 0)
+
+	// the first report was okay, then there were two transitions every
+	// five reports
+	notices := s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapHealthNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["key"], check.Equals, "foo")
+	c.Check(n["occurrences"], check.Equals, 10.0)
+	c.Check(n["last-data"], check.DeepEquals, map[string]any{
+		"status":          "okay",
+		"previous-status": "waiting",
+		"revision":        "7",
+	})
+}
+
+func (s *healthSuite) TestSetFromHookContextNoticeFirstUnhealthy(c *check.C) {
+	ctx, err := hookstate.NewContext(nil, s.state, &hookstate.HookSetup{Snap: "foo"}, nil, "")
+	c.Assert(err, check.IsNil)
+
+	ctx.Lock()
+	defer ctx.Unlock()
+
+	ctx.Set("health", &healthstate.HealthState{
+		Revision: snap.R(7),
+		Status:   healthstate.BlockedStatus,
+		Message:  "needs configuring",
+		Code:     "no-config",
+	})
+	c.Assert(healthstate.SetFromHookContext(ctx), check.IsNil)
+
+	notices := s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.SnapHealthNotice}})
+	c.Assert(notices, check.HasLen, 1)
+	c.Check(noticeToMap(c, notices[0])["last-data"], check.DeepEquals, map[string]any{
+		"status":   "blocked",
+		"revision": "7",
+		"message":  "needs configuring",
+		"code":     "no-config",
+	})
+}
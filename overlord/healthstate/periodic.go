@@ -0,0 +1,200 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/snap"
+)
+
+func init() {
+	swfeats.RegisterEnsure("HealthManager", "ensurePeriodicChecks")
+}
+
+// unhealthyIntervalDivisor is by how much the interval of the check-health
+// hook is shortened while the snap is not healthy.
+const unhealthyIntervalDivisor = 4
+
+// checkInterval is the interval of the check-health hook of a given revision
+// of a snap, as declared in its snap.yaml.
+type checkInterval struct {
+	revision snap.Revision
+	hasHook  bool
+	interval time.Duration
+}
+
+// nextCheck returns when the check-health hook of a snap with the given
+// health is due to be run next, if at all. The zero time means right away.
+func nextCheck(health *HealthState, interval time.Duration) (next time.Time, ok bool) {
+	if health == nil {
+		return time.Time{}, interval > 0
+	}
+	if !health.RecheckTime.IsZero() {
+		return health.RecheckTime, true
+	}
+	if interval == 0 {
+		return time.Time{}, false
+	}
+	if health.Status != OkayStatus {
+		interval /= unhealthyIntervalDivisor
+		if interval < snap.MinHealthCheckInterval {
+			interval = snap.MinHealthCheckInterval
+		}
+	}
+	return health.Timestamp.Add(interval), true
+}
+
+// checkInterval returns the check-health hook interval of the current
+// revision of the snap, reading it from the snap.yaml only when the revision
+// changed.
+func (m *HealthManager) checkInterval(name string, snapst *snapstate.SnapState) (checkInterval, error) {
+	if ci, ok := m.intervals[name]; ok && ci.revision == snapst.Current {
+		return ci, nil
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return checkInterval{}, err
+	}
+	ci := checkInterval{revision: snapst.Current}
+	if hook := info.Hooks["check-health"]; hook != nil {
+		ci.hasHook = true
+		ci.interval = hook.Interval
+	}
+	m.intervals[name] = ci
+	return ci, nil
+}
+
+var ephemeralRunHook = func(hookMgr *hookstate.HookManager, ctx context.Context, hooksup *hookstate.HookSetup) error {
+	_, err := hookMgr.EphemeralRunHook(ctx, hooksup, nil)
+	return err
+}
+
+// runPeriodicCheck runs the check-health hook of the given snap in the
+// background, without a change, as it is run too often for the change to be
+// of any interest. The health reported by the hook is recorded by its
+// handler as usual. The state needs to be locked by the caller.
+func (m *HealthManager) runPeriodicCheck(name string, rev snap.Revision) {
+	hooksup := &hookstate.HookSetup{
+		Snap:     name,
+		Revision: rev,
+		Hook:     "check-health",
+		Optional: true,
+		Timeout:  checkTimeout,
+	}
+	m.checks[name] = true
+	m.checksWg.Add(1)
+	go func() {
+		defer m.checksWg.Done()
+		err := ephemeralRunHook(m.hookMgr, m.checksCtx, hooksup)
+		if err != nil {
+			logger.Noticef("cannot run periodic health check of %q snap: %v", name, err)
+		}
+
+		m.state.Lock()
+		defer m.state.Unlock()
+		delete(m.checks, name)
+		// schedule the next check
+		m.state.EnsureBefore(0)
+	}()
+}
+
+// ensurePeriodicChecks runs the check-health hook of the snaps which declare
+// an interval for it, or asked for it to be run again, when it is due.
+func (m *HealthManager) ensurePeriodicChecks() error {
+	logger.Trace("ensure", "manager", "HealthManager", "func", "ensurePeriodicChecks")
+	var seeded bool
+	if err := m.state.Get("seeded", &seeded); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	snapStates, err := snapstate.All(m.state)
+	if err != nil {
+		return err
+	}
+	healths, err := All(m.state)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(snapStates))
+	for name := range snapStates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for name := range m.intervals {
+		if snapStates[name] == nil {
+			delete(m.intervals, name)
+		}
+	}
+
+	now := timeNow()
+	var nextDue time.Time
+	for _, name := range names {
+		snapst := snapStates[name]
+		if !snapst.Active {
+			continue
+		}
+		ci, err := m.checkInterval(name, snapst)
+		if err != nil {
+			logger.Noticef("cannot get the check-health interval of %q: %v", name, err)
+			continue
+		}
+		if !ci.hasHook || m.checks[name] {
+			continue
+		}
+		next, ok := nextCheck(healths[name], ci.interval)
+		if !ok {
+			continue
+		}
+		if next.After(now) {
+			if nextDue.IsZero() || next.Before(nextDue) {
+				nextDue = next
+			}
+			continue
+		}
+		if err := snapstate.CheckChangeConflict(m.state, name, nil); err != nil {
+			var conflictErr *snapstate.ChangeConflictError
+			if errors.As(err, &conflictErr) {
+				// try again once the change is done
+				continue
+			}
+			return err
+		}
+
+		m.runPeriodicCheck(name, snapst.Current)
+	}
+
+	if !nextDue.IsZero() {
+		m.state.EnsureBefore(nextDue.Sub(now))
+	}
+	return nil
+}
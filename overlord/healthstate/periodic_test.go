@@ -0,0 +1,305 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package healthstate_test
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type periodicSuite struct {
+	testutil.BaseTest
+
+	state *state.State
+	mgr   *healthstate.HealthManager
+	now   time.Time
+
+	mu   sync.Mutex
+	runs []*hookstate.HookSetup
+	// if set, the periodic checks signal started and run until block is
+	// closed
+	block   chan struct{}
+	started chan struct{}
+}
+
+var _ = check.Suite(&periodicSuite{})
+
+const periodicSnapYaml = `name: test-snap
+version: v1
+hooks:
+ check-health:
+  interval: 20m
+`
+
+func (s *periodicSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	s.AddCleanup(func() { dirs.SetRootDir("") })
+
+	s.now = time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(healthstate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.runs = nil
+	s.block = nil
+	s.AddCleanup(healthstate.MockEphemeralRunHook(func(hookMgr *hookstate.HookManager, ctx context.Context, hooksup *hookstate.HookSetup) error {
+		s.mu.Lock()
+		s.runs = append(s.runs, hooksup)
+		block := s.block
+		s.mu.Unlock()
+		if block != nil {
+			s.started <- struct{}{}
+			select {
+			case <-block:
+			case <-ctx.Done():
+			}
+		}
+		return nil
+	}))
+
+	s.state = state.New(nil)
+	// registers the hook tasks as affecting their snap for conflicts
+	hookMgr, err := hookstate.Manager(s.state, state.NewTaskRunner(s.state))
+	c.Assert(err, check.IsNil)
+	s.mgr = healthstate.Manager(s.state, hookMgr)
+	s.AddCleanup(s.mgr.Stop)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.AddCleanup(snapstatetest.UseFallbackDeviceModel())
+	s.state.Set("seeded", true)
+	s.mockSnap(c, "test-snap", periodicSnapYaml)
+	s.mockSnap(c, "other-snap", "{name: other-snap, version: v1}")
+}
+
+func (s *periodicSuite) mockSnap(c *check.C, name, yaml string) {
+	si := &snap.SideInfo{RealName: name, Revision: snap.R(1)}
+	snaptest.MockSnap(c, yaml, si)
+	snapstate.Set(s.state, name, &snapstate.SnapState{
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{si}),
+		Current:  snap.R(1),
+		Active:   true,
+		SnapType: "app",
+	})
+}
+
+func (s *periodicSuite) setHealth(name string, health *healthstate.HealthState) {
+	hs, _ := healthstate.All(s.state)
+	if hs == nil {
+		hs = make(map[string]*healthstate.HealthState)
+	}
+	hs[name] = health
+	s.state.Set("health", hs)
+}
+
+func (s *periodicSuite) ensure(c *check.C) {
+	s.state.Unlock()
+	defer s.state.Lock()
+	c.Assert(s.mgr.Ensure(), check.IsNil)
+	s.mu.Lock()
+	block := s.block
+	s.mu.Unlock()
+	if block == nil {
+		s.mgr.Wait()
+	}
+}
+
+// checks returns the hook setups of the periodic checks run so far.
+func (s *periodicSuite) checks(c *check.C) []*hookstate.HookSetup {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*hookstate.HookSetup(nil), s.runs...)
+}
+
+func (s *periodicSuite) TestPeriodicCheck(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.setHealth("test-snap", &healthstate.HealthState{
+		Revision:  snap.R(1),
+		Timestamp: s.now.Add(-10 * time.Minute),
+		Status:    healthstate.OkayStatus,
+	})
+
+	// not due yet
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 0)
+
+	s.block = make(chan struct{})
+	s.started = make(chan struct{}, 1)
+	s.now = s.now.Add(10 * time.Minute)
+	s.ensure(c)
+	<-s.started
+	checks := s.checks(c)
+	c.Assert(checks, check.HasLen, 1)
+	c.Check(checks[0], check.DeepEquals, &hookstate.HookSetup{
+		Snap:     "test-snap",
+		Revision: snap.R(1),
+		Hook:     "check-health",
+		Optional: true,
+		Timeout:  30 * time.Second,
+	})
+	// no change is created for periodic checks
+	c.Check(s.state.Changes(), check.HasLen, 0)
+
+	// the check is still in progress
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 1)
+
+	// the check is done, but the snap did not report its health
+	s.mu.Lock()
+	close(s.block)
+	s.block = nil
+	s.mu.Unlock()
+	s.state.Unlock()
+	s.mgr.Wait()
+	s.state.Lock()
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 2)
+}
+
+func (s *periodicSuite) TestPeriodicCheckStop(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.block = make(chan struct{})
+	s.started = make(chan struct{}, 1)
+	s.ensure(c)
+	<-s.started
+
+	s.state.Unlock()
+	defer s.state.Lock()
+	s.mgr.Stop()
+}
+
+func (s *periodicSuite) TestPeriodicCheckNeverChecked(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.ensure(c)
+	checks := s.checks(c)
+	c.Assert(checks, check.HasLen, 1)
+	c.Check(checks[0].Snap, check.Equals, "test-snap")
+}
+
+func (s *periodicSuite) TestPeriodicCheckUnhealthy(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// unhealthy snaps are checked four times as often
+	s.setHealth("test-snap", &healthstate.HealthState{
+		Revision:  snap.R(1),
+		Timestamp: s.now.Add(-5 * time.Minute),
+		Status:    healthstate.WaitingStatus,
+	})
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 1)
+}
+
+func (s *periodicSuite) TestRecheck(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// other-snap has a check-health hook but no interval
+	s.mockSnap(c, "other-snap", "{name: other-snap, version: v1, hooks: {check-health: {}}}")
+	s.setHealth("test-snap", &healthstate.HealthState{
+		Revision:  snap.R(1),
+		Timestamp: s.now,
+		Status:    healthstate.OkayStatus,
+	})
+	s.setHealth("other-snap", &healthstate.HealthState{
+		Revision:    snap.R(1),
+		Timestamp:   s.now.Add(-time.Minute),
+		Status:      healthstate.WaitingStatus,
+		RecheckTime: s.now.Add(2 * time.Minute),
+	})
+
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 0)
+
+	s.now = s.now.Add(2 * time.Minute)
+	s.ensure(c)
+	checks := s.checks(c)
+	c.Assert(checks, check.HasLen, 1)
+	c.Check(checks[0].Snap, check.Equals, "other-snap")
+}
+
+func (s *periodicSuite) TestNoPeriodicCheck(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// other-snap asked to be checked again but has no check-health hook
+	s.setHealth("other-snap", &healthstate.HealthState{
+		Revision:    snap.R(1),
+		Timestamp:   s.now.Add(-time.Hour),
+		Status:      healthstate.WaitingStatus,
+		RecheckTime: s.now.Add(-time.Minute),
+	})
+	s.setHealth("test-snap", &healthstate.HealthState{
+		Revision:  snap.R(1),
+		Timestamp: s.now.Add(-time.Hour),
+		Status:    healthstate.OkayStatus,
+	})
+
+	// not seeded yet
+	s.state.Set("seeded", false)
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 0)
+	s.state.Set("seeded", true)
+
+	// the snap is disabled
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "test-snap", &snapst), check.IsNil)
+	snapst.Active = false
+	snapstate.Set(s.state, "test-snap", &snapst)
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 0)
+}
+
+func (s *periodicSuite) TestPeriodicCheckConflict(c *check.C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	// a refresh is in progress
+	chg := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("run-hook", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap", Revision: snap.R(2)}})
+	chg.AddTask(t)
+
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 0)
+
+	t.SetStatus(state.DoneStatus)
+	s.ensure(c)
+	c.Check(s.checks(c), check.HasLen, 1)
+}
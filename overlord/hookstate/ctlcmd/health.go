@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/overlord/healthstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
 )
 
 var (
@@ -38,8 +39,10 @@ snap's overall health.
 
 It can be called from any hook, and even from the apps themselves. A snap can
 optionally provide a 'check-health' hook to better manage these calls, which is
-then called periodically and with increased frequency while the snap is
-"unhealthy". Any health regression will issue a warning to the user.
+then called periodically, at the interval set for it in snap.yaml, and with
+increased frequency while the snap is "unhealthy". With --recheck-in, the hook
+is called again after the given duration. Any health regression will issue a
+warning to the user.
 
 Note: the health is of the snap only, not of the apps it contains; it’s up to
       the snap developer to determine how the health of the individual apps is
@@ -74,6 +77,7 @@ type healthCommand struct {
 	baseCommand
 	healthPositional `positional-args:"yes"`
 	Code             string `long:"code" value-name:"<code>" description:"optional tool-friendly value representing the problem that makes the snap unhealthy.  Not a number, but a word with 3-30 characters matching [a-z](-?[a-z0-9])+"`
+	RecheckIn        string `long:"recheck-in" value-name:"<duration>" description:"run the check-health hook again after the given duration, of at least one minute"`
 }
 
 var (
@@ -116,6 +120,17 @@ func (c *healthCommand) Execute([]string) error {
 		}
 	}
 
+	var recheckIn time.Duration
+	if c.RecheckIn != "" {
+		recheckIn, err = time.ParseDuration(c.RecheckIn)
+		if err != nil {
+			return fmt.Errorf("cannot parse --recheck-in: %v", err)
+		}
+		if recheckIn < snap.MinHealthCheckInterval {
+			return fmt.Errorf("--recheck-in must be at least %v, not %v", snap.MinHealthCheckInterval, recheckIn)
+		}
+	}
+
 	ctx, err := c.ensureContext()
 	if err != nil {
 		return err
@@ -141,6 +156,9 @@ func (c *healthCommand) Execute([]string) error {
 		Message:   c.Message,
 		Code:      c.Code,
 	}
+	if recheckIn != 0 {
+		health.RecheckTime = health.Timestamp.Add(recheckIn)
+	}
 
 	ctx.Set("health", health)
 
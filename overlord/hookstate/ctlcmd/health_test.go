@@ -20,6 +20,8 @@
 package ctlcmd_test
 
 import (
+	"time"
+
 	"gopkg.in/check.v1"
 
 	"github.com/snapcore/snapd/dirs"
@@ -101,6 +103,12 @@ func (s *healthSuite) TestBadArgs(c *check.C) {
 		}, {
 			[]string{"set-health", "blocked", "áéíóú"},
 			`message must be at least 7 characters long \(got 5\)`,
+		}, {
+			[]string{"set-health", "okay", "--recheck-in=soon"},
+			`cannot parse --recheck-in: .*`,
+		}, {
+			[]string{"set-health", "okay", "--recheck-in=30s"},
+			`--recheck-in must be at least 1m0s, not 30s`,
 		}, {
 			[]string{"set-health", "blocked", "message"},
 			`cannot invoke snapctl operation commands \(here "set-health"\) from outside of a snap`,
@@ -128,6 +136,19 @@ func (s *healthSuite) TestRegularRun(c *check.C) {
 	c.Check(health.Code, check.Equals, "some-code")
 }
 
+func (s *healthSuite) TestRecheckIn(c *check.C) {
+	_, _, err := ctlcmd.Run(s.mockContext, []string{"set-health", "waiting", "waiting for the network", "--recheck-in=5m"}, 0, nil)
+	c.Assert(err, check.IsNil)
+
+	s.mockContext.Lock()
+	defer s.mockContext.Unlock()
+
+	var health healthstate.HealthState
+	c.Assert(s.mockContext.Get("health", &health), check.IsNil)
+	c.Check(health.Status, check.Equals, healthstate.WaitingStatus)
+	c.Check(health.RecheckTime.Sub(health.Timestamp), check.Equals, 5*time.Minute)
+}
+
 func (s *healthSuite) TestMessageTruncation(c *check.C) {
 	_, _, err := ctlcmd.Run(s.mockContext, []string{"set-health", "waiting", "Sometimes messages will get a little bit too verbose and this can lead to some rather nasty UX (as well as potential memory problems in extreme cases) so we kinda have to deal with that", "--code=some-code"}, 0, nil)
 	c.Assert(err, check.IsNil)
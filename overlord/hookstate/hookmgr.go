@@ -533,10 +533,10 @@ func MockRunHook(hookInvoke func(c *Context, tomb *tomb.Tomb) ([]byte, error)) (
 
 var osReadlink = os.Readlink
 
-// snapCmd returns the "snap" command to run. If snapd is re-execed
+// SnapCmd returns the "snap" command to run. If snapd is re-execed
 // it will be the snap command from the core snap, otherwise it will
 // be the system "snap" command (c.f. LP: #1668738).
-func snapCmd() string {
+func SnapCmd() string {
 	// sensible default, assume PATH is correct
 	snapCmd := "snap"
 
@@ -557,7 +557,7 @@ func snapCmd() string {
 var defaultHookTimeout = 10 * time.Minute
 
 func runHookAndWait(hookSource string, revision snap.Revision, hookName, hookContext string, timeout time.Duration, tomb *tomb.Tomb) ([]byte, error) {
-	argv := []string{snapCmd(), "run", "--hook", hookName, "-r", revision.String(), hookSource}
+	argv := []string{SnapCmd(), "run", "--hook", hookName, "-r", revision.String(), hookSource}
 	if timeout == 0 {
 		timeout = defaultHookTimeout
 	}
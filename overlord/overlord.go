@@ -39,10 +39,12 @@ import (
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord/assertstate"
 	"github.com/snapcore/snapd/overlord/certstate"
+	"github.com/snapcore/snapd/overlord/changearchive"
 	"github.com/snapcore/snapd/overlord/clusterstate"
 	"github.com/snapcore/snapd/overlord/cmdstate"
 	"github.com/snapcore/snapd/overlord/confdbstate"
 	"github.com/snapcore/snapd/overlord/configstate"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/configstate/proxyconf"
 	"github.com/snapcore/snapd/overlord/devicemgmtstate"
 	"github.com/snapcore/snapd/overlord/devicestate"
@@ -124,6 +126,10 @@ type Overlord struct {
 	deviceMgmtMgr *devicemgmtstate.DeviceMgmtManager
 	certStateMgr  *certstate.CertManager
 
+	changeArchive *changearchive.Archive
+
+	timingsExporter *timings.OTLPExporter
+
 	// proxyConf mediates the http proxy config
 	proxyConf func(req *http.Request) (*url.URL, error)
 }
@@ -137,10 +143,7 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		inited: true,
 	}
 
-	backend := &overlordStateBackend{
-		path:         dirs.SnapStateFile,
-		ensureBefore: o.ensureBefore,
-	}
+	backend := newStateBackend(o.ensureBefore)
 	s, restartMgr, err := o.loadState(backend, restartHandler)
 	if err != nil {
 		return nil, err
@@ -148,6 +151,13 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 
 	o.noticeMgr = notices.NewNoticeManager(s)
 
+	o.changeArchive = changearchive.New(dirs.SnapChangeArchiveFile)
+	s.Lock()
+	o.changeArchive.Track(s)
+	o.timingsExporter = timings.NewOTLPExporter()
+	s.SetTimingsExporter(o.exportTimings)
+	s.Unlock()
+
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
 
@@ -199,7 +209,7 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 	deviceMgr.AddOnInit(fdeMgr)
 	o.addManager(deviceMgr)
 
-	o.addManager(clusterstate.Manager(s))
+	o.addManager(clusterstate.Manager(s, o.runner, deviceMgr))
 
 	o.addManager(cmdstate.Manager(s, o.runner))
 	o.addManager(snapshotstate.Manager(s, o.runner))
@@ -210,6 +220,7 @@ func New(restartHandler restart.Handler) (*Overlord, error) {
 		return nil, err
 	}
 	healthstate.Init(hookMgr)
+	o.addManager(healthstate.Manager(s, hookMgr))
 
 	o.addManager(devicemgmtstate.Manager(s, o.runner, deviceMgr))
 
@@ -552,6 +563,9 @@ func (o *Overlord) Stop() error {
 		err = o.loopTomb.Wait()
 	}
 	o.stateEng.Stop()
+	if o.timingsExporter != nil {
+		o.timingsExporter.Stop()
+	}
 	if o.stateFLock != nil {
 		// This will also unlock the file
 		o.stateFLock.Close()
@@ -560,6 +574,22 @@ func (o *Overlord) Stop() error {
 	return err
 }
 
+// exportTimings exports the timings saved into the state to the OTLP
+// collector configured with the debug.timings.otlp-endpoint core option, if
+// any.
+func (o *Overlord) exportTimings(t *timings.Timings) {
+	var endpoint string
+	tr := config.NewTransaction(o.State())
+	if err := tr.GetMaybe("core", "debug.timings.otlp-endpoint", &endpoint); err != nil {
+		logger.Debugf("cannot get the timings collector endpoint: %v", err)
+		return
+	}
+	if endpoint == "" {
+		return
+	}
+	o.timingsExporter.Export(endpoint, t)
+}
+
 func (o *Overlord) settle(timeout time.Duration, beforeCleanups func(), breakHint func() bool) error {
 	if err := o.StartUp(); err != nil {
 		return err
@@ -741,6 +771,11 @@ func (o *Overlord) SnapshotManager() *snapshotstate.SnapshotManager {
 	return o.shotMgr
 }
 
+// ChangeArchive returns the archive of the changes pruned from the state.
+func (o *Overlord) ChangeArchive() *changearchive.Archive {
+	return o.changeArchive
+}
+
 // NoticeManager returns the notice manager responsible for mediating requests
 // for notices across all notice backends.
 func (o *Overlord) NoticeManager() *notices.NoticeManager {
@@ -780,6 +815,7 @@ func MockWithState(s *state.State) *Overlord {
 	}
 	o.stateEng = NewStateEngine(s)
 	o.runner = state.NewTaskRunner(s)
+	o.changeArchive = changearchive.New(dirs.SnapChangeArchiveFile)
 
 	return o
 }
@@ -24,6 +24,8 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -41,6 +43,7 @@ import (
 	"github.com/snapcore/snapd/dirs/dirstest"
 	"github.com/snapcore/snapd/osutil"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/devicestate/devicestatetest"
 	"github.com/snapcore/snapd/overlord/hookstate"
 	"github.com/snapcore/snapd/overlord/ifacestate"
@@ -382,6 +385,45 @@ func (ovs *overlordSuite) TestTrivialRunAndStop(c *C) {
 	c.Assert(err, IsNil)
 }
 
+func (ovs *overlordSuite) TestExportTimings(c *C) {
+	exported := make(chan []byte, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/v1/traces")
+		data, err := io.ReadAll(r.Body)
+		c.Check(err, IsNil)
+		exported <- data
+	}))
+	defer server.Close()
+
+	o, err := overlord.New(nil)
+	c.Assert(err, IsNil)
+	defer o.Stop()
+
+	st := o.State()
+	st.Lock()
+	// nothing is exported without a configured endpoint
+	troot := timings.New(map[string]string{"ensure": "seed"})
+	timings.Run(troot, "before", "...", func(timings.Measurer) {})
+	troot.Save(st)
+
+	tr := config.NewTransaction(st)
+	c.Assert(tr.Set("core", "debug.timings.otlp-endpoint", server.URL), IsNil)
+	tr.Commit()
+
+	troot = timings.New(map[string]string{"ensure": "seed"})
+	timings.Run(troot, "after", "...", func(timings.Measurer) {})
+	troot.Save(st)
+	st.Unlock()
+
+	select {
+	case data := <-exported:
+		c.Check(string(data), testutil.Contains, `"name":"after"`)
+		c.Check(string(data), Not(testutil.Contains), `"name":"before"`)
+	case <-time.After(10 * time.Second):
+		c.Fatal("timings were not exported")
+	}
+}
+
 func (ovs *overlordSuite) TestUnknownTasks(c *C) {
 	o, err := overlord.New(nil)
 	c.Assert(err, IsNil)
@@ -20,9 +20,13 @@
 package servicestate
 
 import (
+	"time"
+
 	tomb "gopkg.in/tomb.v2"
 
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap"
 	"github.com/snapcore/snapd/snap/quota"
 	"github.com/snapcore/snapd/testutil"
 )
@@ -63,3 +67,36 @@ func MockResourcesCheckFeatureRequirements(f func(*quota.Resources) error) (rest
 	resourcesCheckFeatureRequirements = f
 	return r
 }
+
+type MemoryEventsMonitor = memoryEventsMonitor
+
+func MockCgroupNewMemoryEventsMonitor(f func(channel chan<- string) (MemoryEventsMonitor, error)) (restore func()) {
+	return testutil.Mock(&cgroupNewMemoryEventsMonitor, f)
+}
+
+func MockCgroupReadMemoryEvents(f func(cgroupPath string) (*cgroup.MemoryEvents, error)) (restore func()) {
+	return testutil.Mock(&cgroupReadMemoryEvents, f)
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	return testutil.Mock(&timeNow, f)
+}
+
+var RunProbe = runProbe
+
+func MockServiceProbeActive(f func(app *snap.AppInfo) (bool, error)) (restore func()) {
+	return testutil.Mock(&serviceProbeActive, f)
+}
+
+func MockRunServiceProbe(f func(probe *snap.ProbeInfo) error) (restore func()) {
+	return testutil.Mock(&runServiceProbe, f)
+}
+
+func MockSnapCmd(f func() string) (restore func()) {
+	return testutil.Mock(&snapCmd, f)
+}
+
+// WaitServiceProbes waits for the liveness probes being run.
+func (m *ServiceManager) WaitServiceProbes() {
+	m.probesRunning.Wait()
+}
@@ -0,0 +1,379 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/hookstate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/systemd"
+)
+
+var restartProbedServiceChangeKind = swfeats.RegisterChangeKind("restart-probed-service")
+
+// probeFailedHealthCode is the code of the health status set for snaps with
+// a service failing its liveness probe.
+const probeFailedHealthCode = "snapd-probe-failed"
+
+// ProbeStatus is the status of the liveness probe of a service.
+type ProbeStatus struct {
+	// Passing is whether the service passed its last probe.
+	Passing bool
+	// Failures is the number of probes the service failed in a row.
+	Failures int
+	// LastCheck is when the service was last probed.
+	LastCheck time.Time
+	// Message explains why the service failed its last probe.
+	Message string
+}
+
+// probeStatusesKey is the key of the state cache holding the status of the
+// liveness probes of services by snap.app name.
+type probeStatusesKey struct{}
+
+func probeStatuses(st *state.State) map[string]*ProbeStatus {
+	statuses, _ := st.Cached(probeStatusesKey{}).(map[string]*ProbeStatus)
+	if statuses == nil {
+		statuses = make(map[string]*ProbeStatus)
+		st.Cache(probeStatusesKey{}, statuses)
+	}
+	return statuses
+}
+
+// ServiceProbeStatus returns the status of the liveness probe of the given
+// service, or nil if it was not probed.
+func ServiceProbeStatus(st *state.State, snapName, appName string) *ProbeStatus {
+	status := probeStatuses(st)[snap.JoinSnapApp(snapName, appName)]
+	if status == nil {
+		return nil
+	}
+	cpy := *status
+	return &cpy
+}
+
+var (
+	serviceProbeActive = func(app *snap.AppInfo) (bool, error) {
+		sysd := systemd.New(systemd.SystemMode, progress.Null)
+		sts, err := sysd.Status([]string{app.ServiceName()})
+		if err != nil {
+			return false, err
+		}
+		return len(sts) == 1 && sts[0].Active, nil
+	}
+
+	runServiceProbe = runProbe
+
+	snapCmd = hookstate.SnapCmd
+)
+
+// runProbe runs the liveness probe, returning why the service failed it.
+func runProbe(probe *snap.ProbeInfo) error {
+	addr := net.JoinHostPort("127.0.0.1", strconv.Itoa(probe.Port))
+	switch probe.Type {
+	case snap.ProbeHTTP:
+		client := &http.Client{Timeout: probe.Timeout}
+		resp, err := client.Get("http://" + addr + probe.Path)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode > 399 {
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		return nil
+	case snap.ProbeTCP:
+		conn, err := net.DialTimeout("tcp", addr, probe.Timeout)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	case snap.ProbeExec:
+		ctx, cancel := context.WithTimeout(context.Background(), probe.Timeout)
+		defer cancel()
+		snapApp := snap.JoinSnapApp(probe.App.Snap.InstanceName(), probe.App.Name)
+		output, err := exec.CommandContext(ctx, snapCmd(), "run", "--command=probe", snapApp).CombinedOutput()
+		if ctx.Err() != nil {
+			return fmt.Errorf("timed out after %v", probe.Timeout)
+		}
+		return osutil.OutputErr(output, err)
+	}
+	return fmt.Errorf("internal error: unknown probe type %q", probe.Type)
+}
+
+// serviceProbe tracks when the liveness probe of a service is run.
+type serviceProbe struct {
+	probe   *snap.ProbeInfo
+	running bool
+	next    time.Time
+}
+
+// snapProbes are the liveness probes of the services of a revision of a
+// snap.
+type snapProbes struct {
+	revision snap.Revision
+	probes   []*snap.ProbeInfo
+}
+
+// livenessProbes returns the liveness probes of the services of the current
+// revision of the snap, reading them from the snap.yaml only when the
+// revision changed.
+func (m *ServiceManager) livenessProbes(name string, snapst *snapstate.SnapState) ([]*snap.ProbeInfo, error) {
+	if sp, ok := m.snapProbes[name]; ok && sp.revision == snapst.Current {
+		return sp.probes, nil
+	}
+	info, err := snapst.CurrentInfo()
+	if err != nil {
+		return nil, err
+	}
+	var probes []*snap.ProbeInfo
+	for _, app := range info.Services() {
+		if app.LivenessProbe != nil {
+			probes = append(probes, app.LivenessProbe)
+		}
+	}
+	m.snapProbes[name] = snapProbes{revision: snapst.Current, probes: probes}
+	return probes, nil
+}
+
+// ensureServiceProbes runs the liveness probes of the services which declare
+// one in the background, when they are due.
+func (m *ServiceManager) ensureServiceProbes() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	logger.Trace("ensure", "manager", "ServiceManager", "func", "ensureServiceProbes")
+	var seeded bool
+	if err := m.state.Get("seeded", &seeded); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if !seeded {
+		return nil
+	}
+
+	snapStates, err := snapstate.All(m.state)
+	if err != nil {
+		return err
+	}
+	for name := range m.snapProbes {
+		if snapStates[name] == nil {
+			delete(m.snapProbes, name)
+		}
+	}
+
+	now := timeNow()
+	var nextDue time.Time
+	wanted := make(map[string]bool)
+	for name, snapst := range snapStates {
+		if !snapst.Active {
+			continue
+		}
+		probes, err := m.livenessProbes(name, snapst)
+		if err != nil {
+			logger.Noticef("cannot get the liveness probes of the services of %q: %v", name, err)
+			continue
+		}
+		for _, probe := range probes {
+			key := snap.JoinSnapApp(name, probe.App.Name)
+			wanted[key] = true
+			sp := m.probes[key]
+			if sp == nil || sp.probe != probe {
+				sp = &serviceProbe{probe: probe}
+				m.probes[key] = sp
+			}
+			if sp.running {
+				continue
+			}
+			if sp.next.After(now) {
+				if nextDue.IsZero() || sp.next.Before(nextDue) {
+					nextDue = sp.next
+				}
+				continue
+			}
+			sp.running = true
+			m.probesRunning.Add(1)
+			go m.probeService(key, sp)
+		}
+	}
+
+	statuses := probeStatuses(m.state)
+	for key := range m.probes {
+		if !wanted[key] {
+			delete(m.probes, key)
+			delete(statuses, key)
+		}
+	}
+
+	if !nextDue.IsZero() {
+		m.state.EnsureBefore(nextDue.Sub(now))
+	}
+	return nil
+}
+
+// probeService runs the liveness probe of the service, if it's active, and
+// records the result.
+func (m *ServiceManager) probeService(key string, sp *serviceProbe) {
+	defer m.probesRunning.Done()
+
+	probe := sp.probe
+	active, err := serviceProbeActive(probe.App)
+	var probeErr error
+	if err == nil && active {
+		probeErr = runServiceProbe(probe)
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	sp.running = false
+	sp.next = timeNow().Add(probe.Interval)
+	m.state.EnsureBefore(probe.Interval)
+	if m.probes[key] != sp {
+		// the snap was refreshed or removed meanwhile
+		return
+	}
+	if err != nil {
+		logger.Noticef("cannot get the status of service %q: %v", key, err)
+		return
+	}
+	if !active {
+		// only running services are probed
+		delete(probeStatuses(m.state), key)
+		return
+	}
+	if err := recordProbeResult(m.state, key, probe, probeErr); err != nil {
+		logger.Noticef("cannot act on the liveness probe of service %q: %v", key, err)
+	}
+}
+
+// recordProbeResult records the result of the liveness probe of a service,
+// acting as per the probe on failure once the service failed it repeatedly.
+func recordProbeResult(st *state.State, key string, probe *snap.ProbeInfo, probeErr error) error {
+	statuses := probeStatuses(st)
+	status := statuses[key]
+	if status == nil {
+		status = &ProbeStatus{}
+		statuses[key] = status
+	}
+	status.LastCheck = timeNow()
+	snapName := probe.App.Snap.InstanceName()
+
+	if probeErr == nil {
+		failed := status.Failures >= probe.FailureThreshold
+		status.Passing = true
+		status.Failures = 0
+		status.Message = ""
+		if failed && probe.OnFailure == snap.ProbeActionUnhealthy {
+			return markProbedSnapHealthy(st, snapName)
+		}
+		return nil
+	}
+
+	status.Passing = false
+	status.Failures++
+	status.Message = probeErr.Error()
+	if status.Failures < probe.FailureThreshold {
+		return nil
+	}
+
+	switch probe.OnFailure {
+	case snap.ProbeActionUnhealthy:
+		if status.Failures == probe.FailureThreshold {
+			logger.Noticef("service %q failed its liveness probe %d times: %v", key, status.Failures, probeErr)
+			return markProbedSnapUnhealthy(st, snapName, probe.App.Name)
+		}
+	case snap.ProbeActionRestart:
+		// the service is restarted again if it keeps failing
+		if status.Failures%probe.FailureThreshold == 0 {
+			logger.Noticef("restarting service %q after failing its liveness probe %d times: %v", key, status.Failures, probeErr)
+			return restartProbedService(st, probe.App)
+		}
+	}
+	return nil
+}
+
+func markProbedSnapUnhealthy(st *state.State, snapName, appName string) error {
+	var snapst snapstate.SnapState
+	if err := snapstate.Get(st, snapName, &snapst); err != nil {
+		return err
+	}
+	return healthstate.Set(st, snapName, &healthstate.HealthState{
+		Revision:  snapst.Current,
+		Timestamp: timeNow(),
+		Status:    healthstate.ErrorStatus,
+		Message:   fmt.Sprintf("service %q is failing its liveness probe", appName),
+		Code:      probeFailedHealthCode,
+	})
+}
+
+func markProbedSnapHealthy(st *state.State, snapName string) error {
+	health, err := healthstate.Get(st, snapName)
+	if err != nil {
+		return err
+	}
+	if health == nil || health.Code != probeFailedHealthCode {
+		// the snap reported its health since
+		return nil
+	}
+	return healthstate.Set(st, snapName, &healthstate.HealthState{
+		Revision:  health.Revision,
+		Timestamp: timeNow(),
+		Status:    healthstate.OkayStatus,
+	})
+}
+
+// restartProbedService creates a change restarting the service, unless other
+// changes are operating on its snap.
+func restartProbedService(st *state.State, app *snap.AppInfo) error {
+	snapName := app.Snap.InstanceName()
+	if err := snapstate.CheckChangeConflict(st, snapName, nil); err != nil {
+		logger.Noticef("cannot restart service %q after failing its liveness probe: %v", app.Name, err)
+		return nil
+	}
+
+	summary := fmt.Sprintf("Restart service %q of snap %q after failing its liveness probe", app.Name, snapName)
+	chg := st.NewChange(restartProbedServiceChangeKind, summary)
+	task := st.NewTask("service-control", fmt.Sprintf("Restarting service %q of snap %q", app.Name, snapName))
+	task.Set("service-action", ServiceAction{
+		Action:   "restart",
+		SnapName: snapName,
+		Services: []string{app.Name},
+		// restart it even if it stopped meanwhile
+		ExplicitServices: []string{app.Name},
+	})
+	chg.AddTask(task)
+	chg.Set("snap-names", []string{snapName})
+	st.EnsureBefore(0)
+	return nil
+}
@@ -0,0 +1,351 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/healthstate"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/testutil"
+)
+
+const probesYaml = `name: test-snap
+version: v1
+apps:
+  svc:
+    daemon: simple
+    liveness-probe:
+      tcp: {port: 5432}
+      interval: 10s
+      failure-threshold: 2
+  watched:
+    daemon: simple
+    liveness-probe:
+      http: {port: 8080, path: /healthz}
+      interval: 1m
+      failure-threshold: 1
+      on-failure: unhealthy
+  other:
+    daemon: simple
+`
+
+type probesSuite struct {
+	baseServiceMgrTestSuite
+
+	mu      sync.Mutex
+	active  map[string]bool
+	results map[string]error
+	probed  []string
+
+	now time.Time
+}
+
+var _ = Suite(&probesSuite{})
+
+func (s *probesSuite) SetUpTest(c *C) {
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+
+	servicestate.MockEnsuredSnapServices(s.mgr, true)
+
+	s.active = map[string]bool{"svc": true, "watched": true}
+	s.results = make(map[string]error)
+	s.probed = nil
+	s.AddCleanup(servicestate.MockServiceProbeActive(func(app *snap.AppInfo) (bool, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		return s.active[app.Name], nil
+	}))
+	s.AddCleanup(servicestate.MockRunServiceProbe(func(probe *snap.ProbeInfo) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.probed = append(s.probed, probe.App.Name)
+		return s.results[probe.App.Name]
+	}))
+
+	s.now = time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	s.AddCleanup(servicestate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, probesYaml, s.testSnapSideInfo)
+}
+
+func (s *probesSuite) setResult(app string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results[app] = err
+}
+
+// ensure runs the probes which are due and waits for them.
+func (s *probesSuite) ensure(c *C) []string {
+	s.mu.Lock()
+	s.probed = nil
+	s.mu.Unlock()
+
+	c.Assert(s.mgr.Ensure(), IsNil)
+	s.mgr.WaitServiceProbes()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	probed := s.probed
+	sort.Strings(probed)
+	return probed
+}
+
+func (s *probesSuite) probeChanges() []string {
+	var summaries []string
+	for _, chg := range s.state.Changes() {
+		if chg.Kind() == "restart-probed-service" {
+			summaries = append(summaries, chg.Summary())
+		}
+	}
+	return summaries
+}
+
+func (s *probesSuite) TestProbesPassing(c *C) {
+	c.Check(s.ensure(c), DeepEquals, []string{"svc", "watched"})
+
+	s.state.Lock()
+	c.Check(servicestate.ServiceProbeStatus(s.state, "test-snap", "svc"), DeepEquals, &servicestate.ProbeStatus{
+		Passing:   true,
+		LastCheck: s.now,
+	})
+	c.Check(servicestate.ServiceProbeStatus(s.state, "test-snap", "other"), IsNil)
+	s.state.Unlock()
+
+	// not due yet
+	c.Check(s.ensure(c), HasLen, 0)
+
+	s.now = s.now.Add(10 * time.Second)
+	c.Check(s.ensure(c), DeepEquals, []string{"svc"})
+	s.now = s.now.Add(50 * time.Second)
+	c.Check(s.ensure(c), DeepEquals, []string{"svc", "watched"})
+}
+
+func (s *probesSuite) TestProbesNotSeeded(c *C) {
+	s.state.Lock()
+	s.state.Set("seeded", false)
+	s.state.Unlock()
+
+	c.Check(s.ensure(c), HasLen, 0)
+}
+
+func (s *probesSuite) TestProbesInactiveService(c *C) {
+	s.active["svc"] = false
+
+	c.Check(s.ensure(c), DeepEquals, []string{"watched"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(servicestate.ServiceProbeStatus(s.state, "test-snap", "svc"), IsNil)
+}
+
+func (s *probesSuite) TestProbeFailingRestart(c *C) {
+	s.setResult("svc", errors.New("connection refused"))
+
+	s.ensure(c)
+	s.state.Lock()
+	c.Check(servicestate.ServiceProbeStatus(s.state, "test-snap", "svc"), DeepEquals, &servicestate.ProbeStatus{
+		Failures:  1,
+		LastCheck: s.now,
+		Message:   "connection refused",
+	})
+	c.Check(s.probeChanges(), HasLen, 0)
+	s.state.Unlock()
+
+	s.now = s.now.Add(10 * time.Second)
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(servicestate.ServiceProbeStatus(s.state, "test-snap", "svc").Failures, Equals, 2)
+	c.Assert(s.probeChanges(), DeepEquals, []string{`Restart service "svc" of snap "test-snap" after failing its liveness probe`})
+	chg := s.state.Changes()[0]
+	var snapNames []string
+	c.Assert(chg.Get("snap-names", &snapNames), IsNil)
+	c.Check(snapNames, DeepEquals, []string{"test-snap"})
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "service-control")
+	var action servicestate.ServiceAction
+	c.Assert(tasks[0].Get("service-action", &action), IsNil)
+	c.Check(action, DeepEquals, servicestate.ServiceAction{
+		SnapName:         "test-snap",
+		Action:           "restart",
+		Services:         []string{"svc"},
+		ExplicitServices: []string{"svc"},
+	})
+}
+
+func (s *probesSuite) TestProbeFailingRestartConflict(c *C) {
+	s.setResult("svc", errors.New("connection refused"))
+
+	s.state.Lock()
+	chg := s.state.NewChange("refresh-snap", "...")
+	t := s.state.NewTask("link-snap", "...")
+	t.Set("snap-setup", &snapstate.SnapSetup{SideInfo: &snap.SideInfo{RealName: "test-snap", Revision: snap.R(43)}})
+	chg.AddTask(t)
+	s.state.Unlock()
+
+	s.ensure(c)
+	s.now = s.now.Add(10 * time.Second)
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.probeChanges(), HasLen, 0)
+}
+
+func (s *probesSuite) TestProbeFailingUnhealthy(c *C) {
+	s.setResult("watched", errors.New("unexpected status 503 Service Unavailable"))
+
+	s.ensure(c)
+
+	s.state.Lock()
+	health, err := healthstate.Get(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Assert(health, NotNil)
+	c.Check(health.Status, Equals, healthstate.ErrorStatus)
+	c.Check(health.Code, Equals, "snapd-probe-failed")
+	c.Check(health.Message, Equals, `service "watched" is failing its liveness probe`)
+	c.Check(health.Revision, Equals, snap.R(42))
+	c.Check(s.probeChanges(), HasLen, 0)
+	s.state.Unlock()
+
+	// the snap is marked unhealthy only once
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	s.state.Lock()
+	history, err := healthstate.History(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(history, HasLen, 1)
+	s.state.Unlock()
+
+	// and as healthy again once the service passes its probe
+	s.setResult("watched", nil)
+	s.now = s.now.Add(time.Minute)
+	s.ensure(c)
+	s.state.Lock()
+	defer s.state.Unlock()
+	health, err = healthstate.Get(s.state, "test-snap")
+	c.Assert(err, IsNil)
+	c.Check(health.Status, Equals, healthstate.OkayStatus)
+	c.Check(health.Code, Equals, "")
+}
+
+func (s *probesSuite) TestProbesRemovedSnap(c *C) {
+	s.ensure(c)
+
+	s.state.Lock()
+	snapstate.Set(s.state, "test-snap", nil)
+	s.state.Unlock()
+
+	s.now = s.now.Add(time.Hour)
+	c.Check(s.ensure(c), HasLen, 0)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(servicestate.ServiceProbeStatus(s.state, "test-snap", "svc"), IsNil)
+}
+
+type runProbeSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&runProbeSuite{})
+
+func (s *runProbeSuite) probe(c *C, yaml string) *snap.ProbeInfo {
+	info := snaptest.MockInfo(c, "name: test-snap\nversion: v1\napps:\n  svc:\n    daemon: simple\n    liveness-probe: "+yaml, nil)
+	return info.Apps["svc"].LivenessProbe
+}
+
+func (s *runProbeSuite) TestHTTP(c *C) {
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.URL.Path, Equals, "/healthz")
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+	u, err := url.Parse(server.URL)
+	c.Assert(err, IsNil)
+
+	probe := s.probe(c, "{http: {port: "+u.Port()+", path: /healthz}}")
+	c.Check(servicestate.RunProbe(probe), IsNil)
+
+	status = http.StatusServiceUnavailable
+	c.Check(servicestate.RunProbe(probe), ErrorMatches, "unexpected status 503 Service Unavailable")
+}
+
+func (s *runProbeSuite) TestTCP(c *C) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+	port := strconv.Itoa(l.Addr().(*net.TCPAddr).Port)
+
+	probe := s.probe(c, "{tcp: {port: "+port+"}}")
+	c.Check(servicestate.RunProbe(probe), IsNil)
+
+	l.Close()
+	c.Check(servicestate.RunProbe(probe), ErrorMatches, ".*connection refused")
+}
+
+func (s *runProbeSuite) TestExec(c *C) {
+	cmd := testutil.MockCommand(c, "snap", "")
+	defer cmd.Restore()
+
+	probe := s.probe(c, "{exec: bin/check}")
+	c.Check(servicestate.RunProbe(probe), IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"snap", "run", "--command=probe", "test-snap.svc"}})
+
+	failing := testutil.MockCommand(c, "snap", "echo database is down; exit 1")
+	defer failing.Restore()
+	c.Check(servicestate.RunProbe(probe), ErrorMatches, "database is down")
+}
+
+func (s *runProbeSuite) TestExecRightSnapCmd(c *C) {
+	dirs.SetRootDir(c.MkDir())
+	defer dirs.SetRootDir("")
+
+	// snapd is re-execed from the snapd snap
+	snapCmdPath := filepath.Join(dirs.SnapMountDir, "snapd/12/usr/bin/snap")
+	cmd := testutil.MockCommand(c, snapCmdPath, "")
+	defer cmd.Restore()
+	defer servicestate.MockSnapCmd(func() string { return snapCmdPath })()
+
+	probe := s.probe(c, "{exec: bin/check}")
+	c.Check(servicestate.RunProbe(probe), IsNil)
+	c.Check(cmd.Calls(), DeepEquals, [][]string{{"snap", "run", "--command=probe", "test-snap.svc"}})
+}
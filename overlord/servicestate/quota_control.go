@@ -98,6 +98,21 @@ func verifyQuotaRequirements(st *state.State, resourceLimits quota.Resources) er
 	// MemoryLimit requires systemd 211, so it's covered by the initial check
 	// CPUQuota requires systemd 213, so no further checks need to be done
 	// TasksMax requires systemd 228, so no further checks need to be done
+	// IO*Max requires systemd 230, so no further checks need to be done
+
+	// MemoryHigh requires systemd 231, so we need to verify the version here
+	if resourceLimits.MemoryHigh != nil {
+		if err := systemd.EnsureAtLeast(231); err != nil {
+			return fmt.Errorf("cannot use the memory high quota with incompatible systemd: %v", err)
+		}
+	}
+
+	// MemorySwapMax requires systemd 232, so we need to verify the version here
+	if resourceLimits.MemorySwap != nil {
+		if err := systemd.EnsureAtLeast(232); err != nil {
+			return fmt.Errorf("cannot use the memory swap quota with incompatible systemd: %v", err)
+		}
+	}
 
 	// AllowedCPUs requires systemd 243, so we need to verify the version here
 	if resourceLimits.CPUSet != nil {
@@ -120,6 +135,16 @@ func verifyQuotaRequirements(st *state.State, resourceLimits quota.Resources) er
 	return nil
 }
 
+func validateMemoryEventPolicy(policy string, hasMemoryLimit bool) error {
+	if err := quota.ValidateMemoryEventPolicy(policy); err != nil {
+		return err
+	}
+	if policy != "" && !hasMemoryLimit {
+		return fmt.Errorf("cannot use memory event policy without a memory quota")
+	}
+	return nil
+}
+
 // CreateQuotaOptions reflects all of options available when creating new quota
 // groups.
 type CreateQuotaOptions struct {
@@ -137,6 +162,10 @@ type CreateQuotaOptions struct {
 
 	// ResourceLimits is the resource limits to be used for the quota group.
 	ResourceLimits quota.Resources
+
+	// MemoryEventPolicy is what is done when the quota group runs out of
+	// memory, see quota.Group.MemoryEventPolicy.
+	MemoryEventPolicy string
 }
 
 // CreateQuota attempts to create the specified quota group with the specified
@@ -169,6 +198,9 @@ func CreateQuota(st *state.State, name string, createOpts CreateQuotaOptions) (*
 	if err := resourcesCheckFeatureRequirements(&createOpts.ResourceLimits); err != nil {
 		return nil, fmt.Errorf("cannot create quota group %q: %v", name, err)
 	}
+	if err := validateMemoryEventPolicy(createOpts.MemoryEventPolicy, createOpts.ResourceLimits.Memory != nil); err != nil {
+		return nil, fmt.Errorf("cannot create quota group %q: %v", name, err)
+	}
 
 	// make sure the specified snaps exist and aren't currently in another group
 	parentGrp := allGrps[createOpts.ParentName]
@@ -191,12 +223,13 @@ func CreateQuota(st *state.State, name string, createOpts CreateQuotaOptions) (*
 
 	// create the task with the action in it
 	qc := QuotaControlAction{
-		Action:         "create",
-		QuotaName:      name,
-		ResourceLimits: createOpts.ResourceLimits,
-		AddSnaps:       createOpts.Snaps,
-		AddServices:    createOpts.Services,
-		ParentName:     createOpts.ParentName,
+		Action:            "create",
+		QuotaName:         name,
+		ResourceLimits:    createOpts.ResourceLimits,
+		AddSnaps:          createOpts.Snaps,
+		AddServices:       createOpts.Services,
+		ParentName:        createOpts.ParentName,
+		MemoryEventPolicy: createOpts.MemoryEventPolicy,
 	}
 
 	ts := state.NewTaskSet()
@@ -273,6 +306,10 @@ type UpdateQuotaOptions struct {
 	// NewResourceLimits is the new resource limits to be used for the quota group. A
 	// limit is only changed if the corresponding limit is != nil.
 	NewResourceLimits quota.Resources
+
+	// NewMemoryEventPolicy is the new memory event policy of the quota
+	// group. It is only changed if not empty.
+	NewMemoryEventPolicy string
 }
 
 // UpdateQuota updates the quota as per the options.
@@ -302,6 +339,10 @@ func UpdateQuota(st *state.State, name string, updateOpts UpdateQuotaOptions) (*
 	if err := resourcesCheckFeatureRequirements(&updateOpts.NewResourceLimits); err != nil {
 		return nil, fmt.Errorf("cannot update group %q: %v", name, err)
 	}
+	hasMemoryLimit := grp.MemoryLimit != 0 || updateOpts.NewResourceLimits.Memory != nil
+	if err := validateMemoryEventPolicy(updateOpts.NewMemoryEventPolicy, hasMemoryLimit); err != nil {
+		return nil, fmt.Errorf("cannot update group %q: %v", name, err)
+	}
 
 	// verify we are not trying to add a mixture of services and snaps
 	if err := groupEnsureOnlySnapsOrServices(updateOpts.AddSnaps, updateOpts.AddServices, grp); err != nil {
@@ -330,11 +371,12 @@ func UpdateQuota(st *state.State, name string, updateOpts UpdateQuotaOptions) (*
 
 	// create the action and the correspoding task set
 	qc := QuotaControlAction{
-		Action:         "update",
-		QuotaName:      name,
-		ResourceLimits: updateOpts.NewResourceLimits,
-		AddSnaps:       updateOpts.AddSnaps,
-		AddServices:    updateOpts.AddServices,
+		Action:            "update",
+		QuotaName:         name,
+		ResourceLimits:    updateOpts.NewResourceLimits,
+		AddSnaps:          updateOpts.AddSnaps,
+		AddServices:       updateOpts.AddServices,
+		MemoryEventPolicy: updateOpts.NewMemoryEventPolicy,
 	}
 
 	ts := state.NewTaskSet()
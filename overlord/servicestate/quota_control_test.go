@@ -336,6 +336,8 @@ func (s *quotaControlSuite) TestCreateQuotaPerQuotaSystemdTooOld(c *C) {
 		//{quota.NewResourcesBuilder().WithCPUPercentage(25).Build(), 213},
 		//{quota.NewResourcesBuilder().WithThreadLimit(64).Build(), 228},
 
+		{quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB).Build(), 231, `cannot use the memory high quota with incompatible systemd: systemd version 230 is too old \(expected at least 231\)`},
+		{quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeGiB).Build(), 232, `cannot use the memory swap quota with incompatible systemd: systemd version 231 is too old \(expected at least 232\)`},
 		{quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build(), 243, `cannot use the cpu-set quota with incompatible systemd: systemd version 242 is too old \(expected at least 243\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeGiB).Build(), 245, `cannot use journal quota with incompatible systemd: systemd version 244 is too old \(expected at least 245\)`},
 	}
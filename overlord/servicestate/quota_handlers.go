@@ -72,6 +72,10 @@ type QuotaControlAction struct {
 	// support moving quota groups from one parent to another, but that is
 	// currently not supported.
 	ParentName string `json:"parent-name,omitempty"`
+
+	// MemoryEventPolicy is the memory event policy to set on the quota
+	// group, for the "update" action only if non-empty.
+	MemoryEventPolicy string `json:"memory-event-policy,omitempty"`
 }
 
 func (m *ServiceManager) doQuotaControl(t *state.Task, _ *tomb.Tomb) error {
@@ -346,6 +350,13 @@ func quotaCreate(st *state.State, action QuotaControlAction, allGrps map[string]
 	if err != nil {
 		return nil, nil, false, err
 	}
+	if action.MemoryEventPolicy != "" {
+		grp.MemoryEventPolicy = action.MemoryEventPolicy
+		allGrps, err = internal.PatchQuotas(st, grp)
+		if err != nil {
+			return nil, nil, false, err
+		}
+	}
 	refreshProfiles := grp.JournalLimit != nil
 	return grp, allGrps, refreshProfiles, nil
 }
@@ -489,6 +500,9 @@ func quotaUpdate(st *state.State, action QuotaControlAction, allGrps map[string]
 	if err := quotaUpdateGroupLimits(grp, action.ResourceLimits); err != nil {
 		return nil, nil, false, err
 	}
+	if action.MemoryEventPolicy != "" {
+		grp.MemoryEventPolicy = action.MemoryEventPolicy
+	}
 
 	// update the quota group state
 	allGrps, err := internal.PatchQuotas(st, grp)
@@ -0,0 +1,386 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/quota"
+)
+
+var restartQuotaServicesChangeKind = swfeats.RegisterChangeKind("restart-quota-services")
+
+// memoryEventsMonitor watches the memory events of the slices of quota
+// groups, see cgroup.MemoryEventsMonitor.
+type memoryEventsMonitor interface {
+	Add(name, cgroupPath string) error
+	Remove(name string)
+	Watched() []string
+	Close()
+}
+
+var (
+	cgroupNewMemoryEventsMonitor = func(channel chan<- string) (memoryEventsMonitor, error) {
+		return cgroup.NewMemoryEventsMonitor(channel)
+	}
+	cgroupReadMemoryEvents = cgroup.ReadMemoryEvents
+
+	timeNow = time.Now
+)
+
+// QuotaMemoryEvents holds the number of memory events of a quota group, as
+// recorded by snapd since the group was created.
+type QuotaMemoryEvents struct {
+	// High is the number of times the processes in the group were
+	// throttled for going over the memory limit.
+	High uint64 `json:"high,omitempty"`
+	// Max is the number of times the processes in the group were about to
+	// go over the memory limit.
+	Max uint64 `json:"max,omitempty"`
+	// OOM is the number of times the processes in the group ran out of
+	// memory.
+	OOM uint64 `json:"oom,omitempty"`
+	// OOMKill is the number of processes in the group killed for running
+	// out of memory.
+	OOMKill uint64 `json:"oom-kill,omitempty"`
+	// Last is the time the last of the events was recorded.
+	Last time.Time `json:"last,omitempty"`
+}
+
+func (e *QuotaMemoryEvents) isZero() bool {
+	return e.High == 0 && e.Max == 0 && e.OOM == 0 && e.OOMKill == 0
+}
+
+// quotaMemoryEventsState is what is kept in the state about the memory
+// events of a quota group.
+type quotaMemoryEventsState struct {
+	Recorded QuotaMemoryEvents `json:"recorded"`
+	// Seen holds the counters of the slice of the group when they were last
+	// read. The counters start over whenever the slice is created again.
+	Seen QuotaMemoryEvents `json:"seen"`
+}
+
+func allQuotaMemoryEvents(st *state.State) (map[string]*quotaMemoryEventsState, error) {
+	var all map[string]*quotaMemoryEventsState
+	if err := st.Get("quota-memory-events", &all); err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	if all == nil {
+		all = make(map[string]*quotaMemoryEventsState)
+	}
+	return all, nil
+}
+
+// QuotaMemoryEventsForGroup returns the memory events recorded for the
+// given quota group, or nil if there were none.
+func QuotaMemoryEventsForGroup(st *state.State, name string) (*QuotaMemoryEvents, error) {
+	all, err := allQuotaMemoryEvents(st)
+	if err != nil {
+		return nil, err
+	}
+	if s, ok := all[name]; ok && !s.Recorded.isZero() {
+		return &s.Recorded, nil
+	}
+	return nil, nil
+}
+
+// counterIncrease returns by how much a counter of memory events went up,
+// which is its current value if it started over.
+func counterIncrease(current, seen uint64) uint64 {
+	if current < seen {
+		return current
+	}
+	return current - seen
+}
+
+// recordQuotaMemoryEvents reads the memory events of the slice of the
+// group, recording the ones that happened since they were last read and
+// acting on them as per the memory event policy of the group.
+func recordQuotaMemoryEvents(st *state.State, grp *quota.Group) error {
+	events, err := cgroupReadMemoryEvents(grp.CgroupPath())
+	if err != nil {
+		return err
+	}
+	current := QuotaMemoryEvents{
+		High:    events.High,
+		Max:     events.Max,
+		OOM:     events.OOM,
+		OOMKill: events.OOMKill,
+	}
+
+	all, err := allQuotaMemoryEvents(st)
+	if err != nil {
+		return err
+	}
+	s := all[grp.Name]
+	if s == nil {
+		s = &quotaMemoryEventsState{}
+		all[grp.Name] = s
+	}
+	increase := QuotaMemoryEvents{
+		High:    counterIncrease(current.High, s.Seen.High),
+		Max:     counterIncrease(current.Max, s.Seen.Max),
+		OOM:     counterIncrease(current.OOM, s.Seen.OOM),
+		OOMKill: counterIncrease(current.OOMKill, s.Seen.OOMKill),
+	}
+	s.Seen = current
+	if !increase.isZero() {
+		s.Recorded.High += increase.High
+		s.Recorded.Max += increase.Max
+		s.Recorded.OOM += increase.OOM
+		s.Recorded.OOMKill += increase.OOMKill
+		s.Recorded.Last = timeNow()
+	}
+	st.Set("quota-memory-events", all)
+	if increase.isZero() {
+		return nil
+	}
+
+	data := make(map[string]string)
+	for kind, n := range map[string]uint64{
+		"high":     increase.High,
+		"max":      increase.Max,
+		"oom":      increase.OOM,
+		"oom-kill": increase.OOMKill,
+	} {
+		if n != 0 {
+			data[kind] = strconv.FormatUint(n, 10)
+		}
+	}
+	if _, err := st.AddNotice(nil, state.QuotaMemoryEventNotice, grp.Name, &state.AddNoticeOptions{Data: data}); err != nil {
+		return err
+	}
+
+	if increase.OOMKill == 0 {
+		return nil
+	}
+	switch grp.MemoryEventPolicy {
+	case quota.MemoryEventPolicyWarn:
+		st.Warnf("%d processes in quota group %q were killed for running out of memory", increase.OOMKill, grp.Name)
+	case quota.MemoryEventPolicyRestart:
+		return restartQuotaGroupServices(st, grp)
+	}
+	return nil
+}
+
+// quotaGroupServices returns the services in the quota group by snap.
+func quotaGroupServices(st *state.State, grp *quota.Group) (map[*snap.Info][]*snap.AppInfo, error) {
+	services := make(map[*snap.Info][]*snap.AppInfo)
+	for _, sn := range grp.Snaps {
+		info, err := snapstate.CurrentInfo(st, sn)
+		if err != nil {
+			return nil, err
+		}
+		if svcs := info.Services(); len(svcs) > 0 {
+			services[info] = svcs
+		}
+	}
+	infos := make(map[string]*snap.Info)
+	for _, svc := range grp.Services {
+		snapName, svcName, err := splitSnapServiceName(svc)
+		if err != nil {
+			return nil, err
+		}
+		info := infos[snapName]
+		if info == nil {
+			info, err = snapstate.CurrentInfo(st, snapName)
+			if err != nil {
+				return nil, err
+			}
+			infos[snapName] = info
+		}
+		if app, ok := info.Apps[svcName]; ok && app.IsService() {
+			services[info] = append(services[info], app)
+		}
+	}
+	return services, nil
+}
+
+// restartQuotaGroupServices creates a change restarting the services in the
+// quota group, unless other changes are operating on the group or its
+// snaps.
+func restartQuotaGroupServices(st *state.State, grp *quota.Group) error {
+	services, err := quotaGroupServices(st, grp)
+	if err != nil {
+		return err
+	}
+	if len(services) == 0 {
+		return nil
+	}
+	snapNames := make([]string, 0, len(services))
+	for info := range services {
+		snapNames = append(snapNames, info.InstanceName())
+	}
+	if err := CheckQuotaChangeConflictMany(st, []string{grp.Name}); err != nil {
+		logger.Noticef("cannot restart services of quota group %q after running out of memory: %v", grp.Name, err)
+		return nil
+	}
+	if err := snapstate.CheckChangeConflictMany(st, snapNames, ""); err != nil {
+		logger.Noticef("cannot restart services of quota group %q after running out of memory: %v", grp.Name, err)
+		return nil
+	}
+
+	chg := st.NewChange(restartQuotaServicesChangeKind, fmt.Sprintf("Restart services of quota group %q after running out of memory", grp.Name))
+	var prevTask *state.Task
+	queueTask := func(task *state.Task) {
+		if prevTask != nil {
+			task.WaitFor(prevTask)
+		}
+		chg.AddTask(task)
+		prevTask = task
+	}
+	addRestartServicesTasks(st, queueTask, grp.Name, services)
+	st.EnsureBefore(0)
+	return nil
+}
+
+// ensureQuotaMemoryEventsMonitored makes sure that the memory events of the
+// slices of all quota groups with a memory limit are monitored.
+func (m *ServiceManager) ensureQuotaMemoryEventsMonitored() error {
+	// memory events are only available with cgroup v2
+	if !cgroup.IsUnified() {
+		return nil
+	}
+
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	allGrps, err := AllQuotas(m.state)
+	if err != nil {
+		return err
+	}
+	wanted := make(map[string]*quota.Group)
+	for name, grp := range allGrps {
+		if grp.MemoryLimit != 0 {
+			wanted[name] = grp
+		}
+	}
+
+	if m.memEvents == nil {
+		if len(wanted) == 0 {
+			return nil
+		}
+		m.memEventsCh = make(chan string)
+		m.memEventsStop = make(chan struct{})
+		m.memEventsDone = make(chan struct{})
+		m.memEvents, err = cgroupNewMemoryEventsMonitor(m.memEventsCh)
+		if err != nil {
+			return err
+		}
+		go m.quotaMemoryEventsLoop()
+	}
+
+	watched := make(map[string]bool)
+	for _, name := range m.memEvents.Watched() {
+		watched[name] = true
+		if _, ok := wanted[name]; !ok {
+			m.memEvents.Remove(name)
+		}
+	}
+
+	// drop what is known about the memory events of removed groups
+	all, err := allQuotaMemoryEvents(m.state)
+	if err != nil {
+		return err
+	}
+	for name := range all {
+		if _, ok := allGrps[name]; !ok {
+			delete(all, name)
+			m.state.Set("quota-memory-events", all)
+		}
+	}
+
+	var missing []*quota.Group
+	for name, grp := range wanted {
+		if !watched[name] {
+			missing = append(missing, grp)
+		}
+	}
+	if len(missing) == 0 {
+		return nil
+	}
+
+	logger.Trace("ensure", "manager", "ServiceManager", "func", "ensureQuotaMemoryEventsMonitored")
+	for _, grp := range missing {
+		name := grp.Name
+		if err := m.memEvents.Add(name, grp.CgroupPath()); err != nil {
+			// the slice is not active, yet
+			logger.Debugf("cannot monitor memory events of quota group %q: %v", name, err)
+			continue
+		}
+		// catch up with the events that happened while not monitored
+		if err := recordQuotaMemoryEvents(m.state, grp); err != nil {
+			logger.Noticef("cannot record memory events of quota group %q: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func (m *ServiceManager) quotaMemoryEventsLoop() {
+	defer close(m.memEventsDone)
+	for {
+		select {
+		case name := <-m.memEventsCh:
+			m.processQuotaMemoryEvents(name)
+		case <-m.memEventsStop:
+			return
+		}
+	}
+}
+
+func (m *ServiceManager) processQuotaMemoryEvents(name string) {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	allGrps, err := AllQuotas(m.state)
+	if err != nil {
+		logger.Noticef("cannot record memory events of quota group %q: %v", name, err)
+		return
+	}
+	grp, ok := allGrps[name]
+	if !ok {
+		return
+	}
+	if err := recordQuotaMemoryEvents(m.state, grp); err != nil {
+		logger.Noticef("cannot record memory events of quota group %q: %v", name, err)
+	}
+}
+
+// Stop implements StateStopper. It stops monitoring the memory events of
+// quota groups and waits for the liveness probes being run.
+func (m *ServiceManager) Stop() {
+	m.probesRunning.Wait()
+	if m.memEvents == nil {
+		return
+	}
+	close(m.memEventsStop)
+	<-m.memEventsDone
+	m.memEvents.Close()
+	m.memEvents = nil
+}
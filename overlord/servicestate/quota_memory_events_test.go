@@ -0,0 +1,409 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package servicestate_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/gadget/quantity"
+	"github.com/snapcore/snapd/overlord/servicestate"
+	"github.com/snapcore/snapd/overlord/servicestate/servicestatetest"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/snap/quota"
+	"github.com/snapcore/snapd/snap/snaptest"
+)
+
+type fakeMemoryEventsMonitor struct {
+	mu      sync.Mutex
+	channel chan<- string
+	watched map[string]string
+	missing map[string]bool
+	closed  bool
+}
+
+func (m *fakeMemoryEventsMonitor) Add(name, cgroupPath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.missing[name] {
+		return fmt.Errorf("no such file or directory")
+	}
+	m.watched[name] = cgroupPath
+	return nil
+}
+
+func (m *fakeMemoryEventsMonitor) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.watched, name)
+}
+
+func (m *fakeMemoryEventsMonitor) Watched() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var names []string
+	for name := range m.watched {
+		names = append(names, name)
+	}
+	return names
+}
+
+func (m *fakeMemoryEventsMonitor) Close() {
+	m.closed = true
+}
+
+type quotaMemoryEventsSuite struct {
+	baseServiceMgrTestSuite
+
+	monitor *fakeMemoryEventsMonitor
+	missing map[string]bool
+
+	eventsMu sync.Mutex
+	events   map[string]*cgroup.MemoryEvents
+
+	now time.Time
+}
+
+var _ = Suite(&quotaMemoryEventsSuite{})
+
+func (s *quotaMemoryEventsSuite) SetUpTest(c *C) {
+	s.baseServiceMgrTestSuite.SetUpTest(c)
+
+	servicestate.MockEnsuredSnapServices(s.mgr, true)
+	s.AddCleanup(cgroup.MockVersion(cgroup.V2, nil))
+
+	s.monitor = nil
+	s.missing = make(map[string]bool)
+	s.AddCleanup(servicestate.MockCgroupNewMemoryEventsMonitor(func(channel chan<- string) (servicestate.MemoryEventsMonitor, error) {
+		c.Assert(s.monitor, IsNil)
+		s.monitor = &fakeMemoryEventsMonitor{
+			channel: channel,
+			watched: make(map[string]string),
+			missing: s.missing,
+		}
+		return s.monitor, nil
+	}))
+	s.AddCleanup(s.mgr.Stop)
+
+	s.events = make(map[string]*cgroup.MemoryEvents)
+	s.AddCleanup(servicestate.MockCgroupReadMemoryEvents(func(cgroupPath string) (*cgroup.MemoryEvents, error) {
+		s.eventsMu.Lock()
+		defer s.eventsMu.Unlock()
+		events, ok := s.events[cgroupPath]
+		if !ok {
+			return nil, fmt.Errorf("no such file or directory")
+		}
+		copy := *events
+		return &copy, nil
+	}))
+
+	s.now = time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	s.AddCleanup(servicestate.MockTimeNow(func() time.Time { return s.now }))
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	snapstate.Set(s.state, "test-snap", s.testSnapState)
+	snaptest.MockSnapCurrent(c, testYaml, s.testSnapSideInfo)
+}
+
+func (s *quotaMemoryEventsSuite) setEvents(cgroupPath string, events *cgroup.MemoryEvents) {
+	s.eventsMu.Lock()
+	defer s.eventsMu.Unlock()
+	s.events[cgroupPath] = events
+}
+
+func (s *quotaMemoryEventsSuite) mockQuota(c *C, name, policy string) {
+	err := servicestatetest.MockQuotaInState(s.state, name, "", []string{"test-snap"}, nil, quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	if policy == "" {
+		return
+	}
+	var allGrps map[string]*quota.Group
+	c.Assert(s.state.Get("quotas", &allGrps), IsNil)
+	allGrps[name].MemoryEventPolicy = policy
+	s.state.Set("quotas", allGrps)
+}
+
+func (s *quotaMemoryEventsSuite) ensure(c *C) {
+	c.Assert(s.mgr.Ensure(), IsNil)
+}
+
+func (s *quotaMemoryEventsSuite) quotaMemoryEventNotices() []*state.Notice {
+	return s.state.Notices(&state.NoticeFilter{Types: []state.NoticeType{state.QuotaMemoryEventNotice}})
+}
+
+func noticeToMap(c *C, notice *state.Notice) map[string]any {
+	buf, err := json.Marshal(notice)
+	c.Assert(err, IsNil)
+	var n map[string]any
+	c.Assert(json.Unmarshal(buf, &n), IsNil)
+	return n
+}
+
+// waitForRecordedOOMKills waits for the memory events pushed through the
+// channel of the monitor to be recorded.
+func (s *quotaMemoryEventsSuite) waitForRecordedOOMKills(c *C, name string, oomKills uint64) {
+	for i := 0; i < 500; i++ {
+		s.state.Lock()
+		events, err := servicestate.QuotaMemoryEventsForGroup(s.state, name)
+		s.state.Unlock()
+		c.Assert(err, IsNil)
+		if events != nil && events.OOMKill == oomKills {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.Fatalf("memory events of %q were not recorded", name)
+}
+
+func (s *quotaMemoryEventsSuite) TestNoQuotasNoMonitor(c *C) {
+	s.ensure(c)
+	c.Check(s.monitor, IsNil)
+}
+
+func (s *quotaMemoryEventsSuite) TestCgroupV1NoMonitor(c *C) {
+	restore := cgroup.MockVersion(cgroup.V1, nil)
+	defer restore()
+
+	s.state.Lock()
+	s.mockQuota(c, "foo", "")
+	s.state.Unlock()
+
+	s.ensure(c)
+	c.Check(s.monitor, IsNil)
+}
+
+func (s *quotaMemoryEventsSuite) TestRecordMemoryEvents(c *C) {
+	s.state.Lock()
+	s.mockQuota(c, "foo", "")
+	s.state.Unlock()
+
+	// events that happened before being monitored are caught up with
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{Low: 4, High: 2, OOMKill: 1})
+	s.ensure(c)
+	c.Assert(s.monitor, NotNil)
+	c.Check(s.monitor.watched, DeepEquals, map[string]string{"foo": "snap.foo.slice"})
+
+	s.state.Lock()
+	events, err := servicestate.QuotaMemoryEventsForGroup(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(events, DeepEquals, &servicestate.QuotaMemoryEvents{High: 2, OOMKill: 1, Last: s.now})
+	notices := s.quotaMemoryEventNotices()
+	c.Assert(notices, HasLen, 1)
+	n := noticeToMap(c, notices[0])
+	c.Check(n["key"], Equals, "foo")
+	c.Check(n["last-data"], DeepEquals, map[string]any{"high": "2", "oom-kill": "1"})
+	// no policy set, so no warnings
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	s.state.Unlock()
+
+	// ensuring again does not record anything new
+	s.ensure(c)
+
+	// the monitor notices changes in the events
+	s.now = s.now.Add(time.Hour)
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{Low: 4, High: 2, Max: 1, OOM: 1, OOMKill: 3})
+	s.monitor.channel <- "foo"
+	s.waitForRecordedOOMKills(c, "foo", 3)
+
+	s.state.Lock()
+	events, err = servicestate.QuotaMemoryEventsForGroup(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(events, DeepEquals, &servicestate.QuotaMemoryEvents{High: 2, Max: 1, OOM: 1, OOMKill: 3, Last: s.now})
+	notices = s.quotaMemoryEventNotices()
+	c.Assert(notices, HasLen, 1)
+	n = noticeToMap(c, notices[0])
+	c.Check(n["occurrences"], Equals, 2.0)
+	c.Check(n["last-data"], DeepEquals, map[string]any{"max": "1", "oom": "1", "oom-kill": "2"})
+	s.state.Unlock()
+
+	// the counters start over when the slice is created again
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{OOMKill: 1})
+	s.monitor.channel <- "foo"
+	s.waitForRecordedOOMKills(c, "foo", 4)
+}
+
+func (s *quotaMemoryEventsSuite) TestMonitorSliceNotActiveYet(c *C) {
+	s.state.Lock()
+	s.mockQuota(c, "foo", "")
+	s.state.Unlock()
+
+	s.missing["foo"] = true
+	s.ensure(c)
+	c.Assert(s.monitor, NotNil)
+	c.Check(s.monitor.watched, HasLen, 0)
+
+	s.missing["foo"] = false
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{})
+	s.ensure(c)
+	c.Check(s.monitor.watched, DeepEquals, map[string]string{"foo": "snap.foo.slice"})
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	events, err := servicestate.QuotaMemoryEventsForGroup(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(events, IsNil)
+	c.Check(s.quotaMemoryEventNotices(), HasLen, 0)
+}
+
+func (s *quotaMemoryEventsSuite) TestRemovedGroupNotMonitored(c *C) {
+	s.state.Lock()
+	s.mockQuota(c, "foo", "")
+	s.state.Unlock()
+
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{OOMKill: 1})
+	s.ensure(c)
+	c.Check(s.monitor.watched, HasLen, 1)
+
+	s.state.Lock()
+	s.state.Set("quotas", map[string]*quota.Group{})
+	s.state.Unlock()
+
+	s.ensure(c)
+	c.Check(s.monitor.watched, HasLen, 0)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	events, err := servicestate.QuotaMemoryEventsForGroup(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(events, IsNil)
+
+	s.mgr.Stop()
+	c.Check(s.monitor.closed, Equals, true)
+}
+
+func (s *quotaMemoryEventsSuite) TestMemoryEventPolicyWarn(c *C) {
+	s.state.Lock()
+	s.mockQuota(c, "foo", quota.MemoryEventPolicyWarn)
+	s.state.Unlock()
+
+	// only processes being killed are warned about
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{High: 10})
+	s.ensure(c)
+	s.state.Lock()
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	s.state.Unlock()
+
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{High: 10, OOMKill: 2})
+	s.monitor.channel <- "foo"
+	s.waitForRecordedOOMKills(c, "foo", 2)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	warnings := s.state.AllWarnings()
+	c.Assert(warnings, HasLen, 1)
+	c.Check(warnings[0].String(), Equals, `2 processes in quota group "foo" were killed for running out of memory`)
+	c.Check(s.state.Changes(), HasLen, 0)
+}
+
+func (s *quotaMemoryEventsSuite) TestMemoryEventPolicyRestart(c *C) {
+	s.state.Lock()
+	s.mockQuota(c, "foo", quota.MemoryEventPolicyRestart)
+	s.state.Unlock()
+
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{OOMKill: 1})
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	c.Check(s.state.AllWarnings(), HasLen, 0)
+	chgs := s.state.Changes()
+	c.Assert(chgs, HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), Equals, "restart-quota-services")
+	c.Check(chg.Summary(), Equals, `Restart services of quota group "foo" after running out of memory`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, HasLen, 1)
+	c.Check(tasks[0].Kind(), Equals, "service-control")
+	var action servicestate.ServiceAction
+	c.Assert(tasks[0].Get("service-action", &action), IsNil)
+	c.Check(action, DeepEquals, servicestate.ServiceAction{
+		Action:   "restart",
+		SnapName: "test-snap",
+		Services: []string{"svc1"},
+	})
+}
+
+func (s *quotaMemoryEventsSuite) TestMemoryEventPolicyRestartConflict(c *C) {
+	s.state.Lock()
+	s.mockQuota(c, "foo", quota.MemoryEventPolicyRestart)
+	chg := s.state.NewChange("quota-control", "...")
+	task := s.state.NewTask("quota-control", "...")
+	task.Set("quota-control-actions", []servicestate.QuotaControlAction{{QuotaName: "foo", Action: "update"}})
+	chg.AddTask(task)
+	s.state.Unlock()
+
+	s.setEvents("snap.foo.slice", &cgroup.MemoryEvents{OOMKill: 1})
+	s.ensure(c)
+
+	s.state.Lock()
+	defer s.state.Unlock()
+	// the events are recorded, but no restart happens
+	events, err := servicestate.QuotaMemoryEventsForGroup(s.state, "foo")
+	c.Assert(err, IsNil)
+	c.Check(events.OOMKill, Equals, uint64(1))
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
+func (s *quotaMemoryEventsSuite) TestCreateUpdateQuotaMemoryEventPolicy(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.mockQuota(c, "foo", "")
+
+	_, err := servicestate.CreateQuota(s.state, "bar", servicestate.CreateQuotaOptions{
+		ResourceLimits:    quota.NewResourcesBuilder().WithThreadLimit(32).Build(),
+		MemoryEventPolicy: quota.MemoryEventPolicyWarn,
+	})
+	c.Check(err, ErrorMatches, `cannot create quota group "bar": cannot use memory event policy without a memory quota`)
+
+	_, err = servicestate.CreateQuota(s.state, "bar", servicestate.CreateQuotaOptions{
+		ResourceLimits:    quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build(),
+		MemoryEventPolicy: "reboot",
+	})
+	c.Check(err, ErrorMatches, `cannot create quota group "bar": invalid memory event policy "reboot"`)
+
+	ts, err := servicestate.CreateQuota(s.state, "bar", servicestate.CreateQuotaOptions{
+		ResourceLimits:    quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build(),
+		MemoryEventPolicy: quota.MemoryEventPolicyRestart,
+	})
+	c.Assert(err, IsNil)
+	var qcs []servicestate.QuotaControlAction
+	c.Assert(ts.Tasks()[0].Get("quota-control-actions", &qcs), IsNil)
+	c.Check(qcs[0].MemoryEventPolicy, Equals, quota.MemoryEventPolicyRestart)
+
+	_, err = servicestate.UpdateQuota(s.state, "foo", servicestate.UpdateQuotaOptions{
+		NewMemoryEventPolicy: "reboot",
+	})
+	c.Check(err, ErrorMatches, `cannot update group "foo": invalid memory event policy "reboot"`)
+
+	ts, err = servicestate.UpdateQuota(s.state, "foo", servicestate.UpdateQuotaOptions{
+		NewMemoryEventPolicy: quota.MemoryEventPolicyWarn,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(ts.Tasks()[0].Get("quota-control-actions", &qcs), IsNil)
+	c.Check(qcs[0].MemoryEventPolicy, Equals, quota.MemoryEventPolicyWarn)
+}
@@ -26,6 +26,7 @@ import (
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/snapcore/snapd/dirs"
@@ -46,6 +47,8 @@ import (
 
 func init() {
 	swfeats.RegisterEnsure("ServiceManager", "ensureSnapServicesUpdated")
+	swfeats.RegisterEnsure("ServiceManager", "ensureQuotaMemoryEventsMonitored")
+	swfeats.RegisterEnsure("ServiceManager", "ensureServiceProbes")
 }
 
 // ServiceManager is responsible for starting and stopping snap services.
@@ -53,13 +56,28 @@ type ServiceManager struct {
 	state *state.State
 
 	ensuredSnapSvcs bool
+
+	// memEvents monitors the memory events of the quota groups with a
+	// memory limit, pushing their names through memEventsCh
+	memEvents     memoryEventsMonitor
+	memEventsCh   chan string
+	memEventsStop chan struct{}
+	memEventsDone chan struct{}
+
+	// probes tracks the liveness probes of services by snap.app name,
+	// as declared by the snaps in snapProbes
+	probes        map[string]*serviceProbe
+	snapProbes    map[string]snapProbes
+	probesRunning sync.WaitGroup
 }
 
 // Manager returns a new service manager.
 func Manager(st *state.State, runner *state.TaskRunner) *ServiceManager {
 	delayedCrossMgrInit()
 	m := &ServiceManager{
-		state: st,
+		state:      st,
+		probes:     make(map[string]*serviceProbe),
+		snapProbes: make(map[string]snapProbes),
 	}
 	// TODO: undo handler
 	runner.AddHandler("service-control", m.doServiceControl, nil)
@@ -211,6 +229,12 @@ func (m *ServiceManager) Ensure() error {
 	if err := m.ensureSnapServicesUpdated(); err != nil {
 		return err
 	}
+	if err := m.ensureQuotaMemoryEventsMonitored(); err != nil {
+		return err
+	}
+	if err := m.ensureServiceProbes(); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -258,10 +258,26 @@ func isSnapshotFilename(filePath string) (ok bool, setID uint64) {
 }
 
 // EstimateSnapshotSize calculates estimated size of the snapshot.
-func EstimateSnapshotSize(si *snap.Info, usernames []string, dirOpts *dirs.SnapDirOptions) (uint64, error) {
+//
+// When saving deduplicated snapshots, only the data modified since the last
+// deduplicated snapshot of the snap is accounted for, as the rest is
+// expected to be already present in the chunk store. Modification is judged
+// by the mtime of the files alone, so new data carrying an older mtime, e.g.
+// files restored or copied with their times preserved, is not accounted for
+// and the estimate can then be too low.
+func EstimateSnapshotSize(ctx context.Context, si *snap.Info, usernames []string, dirOpts *dirs.SnapDirOptions, flags *SaveFlags) (uint64, error) {
+	var since time.Time
+	if flags.deduplicate() {
+		var err error
+		since, err = lastDeduplicatedSnapshotTime(ctx, si.InstanceName())
+		if err != nil {
+			return 0, err
+		}
+	}
+
 	var total uint64
 	calculateSize := func(path string, finfo os.FileInfo, err error) error {
-		if finfo.Mode().IsRegular() {
+		if finfo.Mode().IsRegular() && finfo.ModTime().After(since) {
 			total += uint64(finfo.Size())
 		}
 		return err
@@ -301,11 +317,51 @@ func EstimateSnapshotSize(si *snap.Info, usernames []string, dirOpts *dirs.SnapD
 	return total, nil
 }
 
+// lastDeduplicatedSnapshotTime returns the time of the most recent
+// deduplicated snapshot of the given snap, or the zero time if there is none.
+func lastDeduplicatedSnapshotTime(ctx context.Context, snapName string) (time.Time, error) {
+	var last time.Time
+	err := Iter(ctx, func(r *Reader) error {
+		if r.Broken == "" && r.Snap == snapName && r.chunks != nil && r.Time.After(last) {
+			last = r.Time
+		}
+		return nil
+	})
+	return last, err
+}
+
+// SaveFlags carries extra flags to drive save behavior.
+type SaveFlags struct {
+	// Deduplicate tells save to store the snapshot data in the chunk
+	// store, so that only data not already saved by other snapshots
+	// takes up additional space.
+	Deduplicate bool
+	// Key, if set, is used to encrypt the snapshot data. Encrypted
+	// snapshots are never deduplicated.
+	Key []byte
+}
+
+func (flags *SaveFlags) deduplicate() bool {
+	return flags != nil && flags.Deduplicate && flags.Key == nil
+}
+
 // Save a snapshot
-func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, dynSnapshotOpts *snap.SnapshotOptions, dirOpts *dirs.SnapDirOptions, flags *SaveFlags) (*client.Snapshot, error) {
 	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
 		return nil, err
 	}
+	if flags == nil {
+		flags = &SaveFlags{}
+	}
+
+	var index *chunksIndex
+	if flags.deduplicate() {
+		// the chunks written must not be cleaned up before the
+		// snapshot referencing them is committed
+		chunkStoreLock.RLock()
+		defer chunkStoreLock.RUnlock()
+		index = &chunksIndex{Format: 1, Entries: make(map[string][]chunkRef)}
+	}
 
 	snapshot := &client.Snapshot{
 		SetID:    id,
@@ -317,10 +373,11 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 		Time:     timeNow(),
 		// Pass only dynamic snapshot options here. Static options are tied to the snap version
 		// and should not be repeated in snapshot metadata on every save.
-		Options:  dynSnapshotOpts,
-		SHA3_384: make(map[string]string),
-		Size:     0,
-		Conf:     cfg,
+		Options:   dynSnapshotOpts,
+		SHA3_384:  make(map[string]string),
+		Size:      0,
+		Conf:      cfg,
+		Encrypted: flags.Key != nil,
 		// Note: Auto is no longer set in the Snapshot.
 	}
 
@@ -346,7 +403,7 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 	defer w.Close() // note this does not close the file descriptor (that's done by hand on the atomic writer, above)
 	savingUserData := false
 	baseDataDir := snap.BaseDataDir(si.InstanceName())
-	if err := addSnapDirToZip(ctx, snapshot, w, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+	if err := addSnapDirToZip(ctx, snapshot, w, index, flags.Key, "root", archiveName, baseDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
 		return nil, err
 	}
 
@@ -358,7 +415,17 @@ func Save(ctx context.Context, id uint64, si *snap.Info, cfg map[string]any, use
 	savingUserData = true
 	for _, usr := range users {
 		snapDataDir := filepath.Dir(si.UserDataDir(usr.HomeDir, dirOpts))
-		if err := addSnapDirToZip(ctx, snapshot, w, usr.Username, userArchiveName(usr), snapDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+		if err := addSnapDirToZip(ctx, snapshot, w, index, flags.Key, usr.Username, userArchiveName(usr), snapDataDir, savingUserData, snapshotOptions.Exclude); err != nil {
+			return nil, err
+		}
+	}
+
+	if index != nil {
+		indexWriter, err := w.Create(chunksIndexName)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.NewEncoder(indexWriter).Encode(index); err != nil {
 			return nil, err
 		}
 	}
@@ -399,7 +466,11 @@ var isTesting = snapdenv.Testing()
 // addSnapDirToZip adds the 'common' and the 'rev' revisioned dir under 'snapDir'
 // to the snapshot. If one doesn't exist, it's ignored. If none exists, the
 // operation is skipped.
-func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
+//
+// If 'index' is not nil the data is added to the chunk store instead, and
+// the chunks it's made of are recorded in 'index'. If 'key' is not nil the
+// data is encrypted with it.
+func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, index *chunksIndex, key []byte, username, entry, snapDir string, savingUserData bool, excludePaths []string) error {
 	paths, err := pathsForSnapshot(snapDir, snapshot)
 	if err != nil {
 		return err
@@ -442,24 +513,47 @@ func addSnapDirToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writ
 		expExcludePaths = append(expExcludePaths, expandedPath)
 	}
 
-	return addToZip(ctx, snapshot, w, username, entry, paths, expExcludePaths)
+	return addToZip(ctx, snapshot, w, index, key, username, entry, paths, expExcludePaths)
 }
 
 // addToZip adds 'paths' to the snapshot. tar will change into the paths' parent
 // directory before creating the archive so that parent dirs are not added.
-func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, username, entry string, paths []string, excludePaths []string) error {
-	archiveWriter, err := w.CreateHeader(&zip.FileHeader{Name: entry})
-	if err != nil {
-		return err
+func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, index *chunksIndex, key []byte, username, entry string, paths []string, excludePaths []string) error {
+	var sz osutil.Sizer
+	hasher := crypto.SHA3_384.New()
+
+	var archiveWriter io.Writer
+	var chunker *chunkWriter
+	var encrypter *encryptWriter
+	tarArgs := []string{"--create", "--sparse"}
+	if index != nil {
+		// chunks are compressed individually, compressing the whole
+		// archive would defeat deduplication
+		chunker = newChunkWriter()
+		archiveWriter = io.MultiWriter(chunker, hasher, &sz)
+	} else {
+		zw, err := w.CreateHeader(&zip.FileHeader{Name: entry})
+		if err != nil {
+			return err
+		}
+		archiveWriter = io.MultiWriter(zw, hasher, &sz)
+		if key != nil {
+			// the hash covers the encrypted data, so that the
+			// snapshot can be checked without the key
+			encrypter, err = newEncryptWriter(archiveWriter, key)
+			if err != nil {
+				return err
+			}
+			archiveWriter = encrypter
+		}
+		tarArgs = append(tarArgs, "--gzip")
 	}
 
-	tarArgs := []string{
-		"--create",
-		"--sparse", "--gzip",
+	tarArgs = append(tarArgs,
 		"--format", "gnu",
 		"--anchored",
 		"--no-wildcards-match-slash",
-	}
+	)
 
 	for _, path := range excludePaths {
 		tarArgs = append(tarArgs, fmt.Sprintf("--exclude=%s", path))
@@ -471,11 +565,8 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		tarArgs = append(tarArgs, "--directory", parent, dir)
 	}
 
-	var sz osutil.Sizer
-	hasher := crypto.SHA3_384.New()
-
 	cmd := tarAsUser(ctx, username, tarArgs...)
-	cmd.Stdout = io.MultiWriter(archiveWriter, hasher, &sz)
+	cmd.Stdout = archiveWriter
 
 	// keep (at most) the last 5 non-empty lines of what 'tar' writes to stderr
 	// (those are the most likely contain the reason for fatal errors)
@@ -505,6 +596,22 @@ func addToZip(ctx context.Context, snapshot *client.Snapshot, w *zip.Writer, use
 		return fmt.Errorf("tar failed: %v", err)
 	}
 
+	if encrypter != nil {
+		if err := encrypter.Close(); err != nil {
+			return err
+		}
+	}
+
+	if chunker != nil {
+		refs, err := chunker.Close()
+		if err != nil {
+			return err
+		}
+		index.Entries[entry] = refs
+		logger.Debugf("Snapshot #%d of %q entry %q: %d bytes in %d chunks, %d bytes added to the chunk store.",
+			snapshot.SetID, snapshot.Snap, entry, sz.Size(), len(refs), chunker.stored)
+	}
+
 	snapshot.SHA3_384[entry] = fmt.Sprintf("%x", hasher.Sum(nil))
 	snapshot.Size += sz.Size()
 
@@ -732,22 +839,33 @@ type ImportFlags struct {
 	// noDuplicatedImportCheck tells import not to check for existing snapshot
 	// with same content hash (and not report DuplicatedSnapshotImportError).
 	NoDuplicatedImportCheck bool
+	// Passphrase is used to decrypt exports encrypted with a passphrase.
+	Passphrase string
+	// AllowEncrypted tells import to accept encrypted snapshot sets,
+	// whose key is then returned to the caller for safekeeping.
+	AllowEncrypted bool
 }
 
-// Import a snapshot from the export file format
-func Import(ctx context.Context, id uint64, r io.Reader, flags *ImportFlags) (snapNames []string, err error) {
+// Import a snapshot from the export file format.
+//
+// If the imported snapshot set is encrypted its key is returned as well.
+func Import(ctx context.Context, id uint64, r io.Reader, flags *ImportFlags) (snapNames []string, key []byte, err error) {
 	if err := os.MkdirAll(dirs.SnapshotsDir, 0700); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	errPrefix := fmt.Sprintf("cannot import snapshot %d", id)
 
+	// chunks are imported before the snapshots referencing them
+	chunkStoreLock.RLock()
+	defer chunkStoreLock.RUnlock()
+
 	tr := newImportTransaction(id)
 	if tr.InProgress() {
-		return nil, fmt.Errorf("%s: already in progress for this set id", errPrefix)
+		return nil, nil, fmt.Errorf("%s: already in progress for this set id", errPrefix)
 	}
 	if err := tr.Start(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 	// Cancel once Committed is a NOP
 	defer tr.Cancel()
@@ -757,18 +875,21 @@ func Import(ctx context.Context, id uint64, r io.Reader, flags *ImportFlags) (sn
 	// XXX: this will leak snapshot IDs, i.e. we allocate a new
 	// snapshot ID before but then we error here because of e.g.
 	// duplicated import attempts
-	snapNames, err = unpackVerifySnapshotImport(ctx, r, id, flags)
+	snapNames, key, err = unpackVerifySnapshotImport(ctx, r, id, flags)
 	if err != nil {
 		if _, ok := err.(DuplicatedSnapshotImportError); ok {
-			return nil, err
+			return nil, nil, err
 		}
-		return nil, fmt.Errorf("%s: %v", errPrefix, err)
+		if err == ErrPassphraseRequired {
+			return nil, nil, err
+		}
+		return nil, nil, fmt.Errorf("%s: %v", errPrefix, err)
 	}
 	if err := tr.Commit(); err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
-	return snapNames, nil
+	return snapNames, key, nil
 }
 
 func writeOneSnapshotFile(targetPath string, tr io.Reader) error {
@@ -825,17 +946,22 @@ func checkDuplicatedSnapshotSetWithContentHash(ctx context.Context, contentHash
 	return nil
 }
 
-func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint64, flags *ImportFlags) (snapNames []string, err error) {
+func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint64, flags *ImportFlags) (snapNames []string, key []byte, err error) {
 	var exportFound bool
 
-	tr := tar.NewReader(r)
-	var tarErr error
-	var header *tar.Header
-
 	if flags == nil {
 		flags = &ImportFlags{}
 	}
 
+	r, err = exportReader(r, flags.Passphrase)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tr := tar.NewReader(r)
+	var tarErr error
+	var header *tar.Header
+
 	for tarErr == nil {
 		header, tarErr = tr.Next()
 		if tarErr == io.EOF {
@@ -843,36 +969,57 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 		}
 		switch {
 		case tarErr != nil:
-			return nil, fmt.Errorf("cannot read snapshot import: %v", tarErr)
+			return nil, nil, fmt.Errorf("cannot read snapshot import: %v", tarErr)
 		case header == nil:
 			// should not happen
-			return nil, fmt.Errorf("tar header not found")
+			return nil, nil, fmt.Errorf("tar header not found")
 		case header.Typeflag == tar.TypeDir:
-			return nil, errors.New("unexpected directory in import file")
+			return nil, nil, errors.New("unexpected directory in import file")
 		}
 
 		// files within the snapshot should never use parent elements
 		if strings.Contains(header.Name, "../") {
-			return nil, fmt.Errorf("invalid filename in import file")
+			return nil, nil, fmt.Errorf("invalid filename in import file")
 		}
 
 		if header.Name == "content.json" {
 			var ej contentJSON
 			dec := json.NewDecoder(tr)
 			if err := dec.Decode(&ej); err != nil {
-				return nil, err
+				return nil, nil, err
 			}
 			if !flags.NoDuplicatedImportCheck {
 				// XXX: this is potentially slow as it needs
 				//      to open all snapshots files and read a
 				//      small amount of data from them
 				if err := checkDuplicatedSnapshotSetWithContentHash(ctx, ej.ContentHash); err != nil {
-					return nil, err
+					return nil, nil, err
 				}
 			}
 			continue
 		}
 
+		if header.Name == snapshotKeyName {
+			if !flags.AllowEncrypted {
+				return nil, nil, errors.New("encrypted snapshot sets can only be imported on systems with encrypted data")
+			}
+			key, err = io.ReadAll(io.LimitReader(tr, SnapshotKeySize+1))
+			if err != nil {
+				return nil, nil, err
+			}
+			if len(key) != SnapshotKeySize {
+				return nil, nil, fmt.Errorf("invalid snapshot key size %d", len(key))
+			}
+			continue
+		}
+
+		if strings.HasPrefix(header.Name, chunksDirName+"/") {
+			if err := importChunk(strings.TrimPrefix(header.Name, chunksDirName+"/"), tr); err != nil {
+				return nil, nil, err
+			}
+			continue
+		}
+
 		if header.Name == "export.json" {
 			// XXX: read into memory and validate once we
 			// hashes in export.json
@@ -889,31 +1036,39 @@ func unpackVerifySnapshotImport(ctx context.Context, r io.Reader, realSetID uint
 		// the rest that is still valid.
 		l := strings.SplitN(header.Name, "_", 2)
 		if len(l) != 2 {
-			return nil, fmt.Errorf("unexpected filename in import stream: %v", header.Name)
+			return nil, nil, fmt.Errorf("unexpected filename in import stream: %v", header.Name)
 		}
 		targetPath := path.Join(dirs.SnapshotsDir, fmt.Sprintf("%d_%s", realSetID, l[1]))
 		if err := writeOneSnapshotFile(targetPath, tr); err != nil {
-			return snapNames, err
+			return snapNames, nil, err
 		}
 
 		r, err := backendOpen(targetPath, realSetID)
 		if err != nil {
-			return snapNames, fmt.Errorf("cannot open snapshot: %v", err)
+			return snapNames, nil, fmt.Errorf("cannot open snapshot: %v", err)
+		}
+		if r.Encrypted {
+			if key == nil {
+				r.Close()
+				return snapNames, nil, fmt.Errorf("no key for encrypted snapshot %q", targetPath)
+			}
+			// with the key the data is authenticated as well
+			r.SetKey(key)
 		}
 		err = r.Check(context.TODO(), nil)
 		r.Close()
 		snapNames = append(snapNames, r.Snap)
 		if err != nil {
-			return snapNames, fmt.Errorf("validation failed for %q: %v", targetPath, err)
+			return snapNames, nil, fmt.Errorf("validation failed for %q: %v", targetPath, err)
 		}
 	}
 
 	if !exportFound {
-		return nil, fmt.Errorf("no export.json file in uploaded data")
+		return nil, nil, fmt.Errorf("no export.json file in uploaded data")
 	}
 	// XXX: validate using the unmarshalled export.json hashes here
 
-	return snapNames, nil
+	return snapNames, key, nil
 }
 
 type exportMetadata struct {
@@ -922,13 +1077,34 @@ type exportMetadata struct {
 	Files  []string  `json:"files"`
 }
 
+// ExportFlags carries extra flags to drive export behavior.
+type ExportFlags struct {
+	// Passphrase, if set, is used to encrypt the export.
+	Passphrase string
+	// Key is the key of the snapshot set, needed to export encrypted
+	// snapshot sets. It is carried in the export, so a passphrase is
+	// required as well.
+	Key []byte
+}
+
 type SnapshotExport struct {
 	// open snapshot files
 	snapshotFiles []*os.File
 
+	// paths of the chunks used by deduplicated snapshots
+	chunks []string
+
 	// contentHash of the full snapshot
 	contentHash []byte
 
+	// key of the encrypted snapshot set, nil otherwise
+	setKey []byte
+
+	// key derived from the passphrase and the header to go with it,
+	// if the export is encrypted
+	exportKey    []byte
+	exportHeader []byte
+
 	// remember setID mostly for nicer errors
 	setID uint64
 
@@ -938,9 +1114,16 @@ type SnapshotExport struct {
 
 // NewSnapshotExport will return a SnapshotExport structure. It must be
 // Close()ed after use to avoid leaking file descriptors.
-func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, err error) {
+func NewSnapshotExport(ctx context.Context, setID uint64, flags *ExportFlags) (se *SnapshotExport, err error) {
 	var snapshotFiles []*os.File
 	var snapshotSet client.SnapshotSet
+	var chunks []string
+	var encrypted bool
+	seenChunks := make(map[string]bool)
+
+	if flags == nil {
+		flags = &ExportFlags{}
+	}
 
 	defer func() {
 		// cleanup any open FDs if anything goes wrong
@@ -973,6 +1156,10 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 				return fmt.Errorf("cannot open file from descriptor %d", fd)
 			}
 			snapshotFiles = append(snapshotFiles, f)
+			encrypted = encrypted || reader.Encrypted
+			for _, hash := range reader.chunks.uniqueChunkHashes(seenChunks) {
+				chunks = append(chunks, chunkPathIn(reader.chunks.dir, hash))
+			}
 		}
 		return nil
 	})
@@ -987,7 +1174,24 @@ func NewSnapshotExport(ctx context.Context, setID uint64) (se *SnapshotExport, e
 	if err != nil {
 		return nil, fmt.Errorf("cannot calculate content hash for snapshot export %v: %v", setID, err)
 	}
-	se = &SnapshotExport{snapshotFiles: snapshotFiles, setID: setID, contentHash: h}
+	se = &SnapshotExport{snapshotFiles: snapshotFiles, chunks: chunks, setID: setID, contentHash: h}
+
+	if encrypted {
+		if len(flags.Key) != SnapshotKeySize {
+			return nil, fmt.Errorf("cannot export encrypted snapshot %v without its key", setID)
+		}
+		if flags.Passphrase == "" {
+			return nil, fmt.Errorf("cannot export encrypted snapshot %v without a passphrase", setID)
+		}
+		se.setKey = flags.Key
+	}
+	if flags.Passphrase != "" {
+		// derive the key once, as the export is streamed twice
+		se.exportKey, se.exportHeader, err = newExportPassphraseKey(flags.Passphrase)
+		if err != nil {
+			return nil, err
+		}
+	}
 
 	// ensure we never leak FDs even if the user does not call close
 	runtime.SetFinalizer(se, (*SnapshotExport).Close)
@@ -1031,6 +1235,24 @@ type contentJSON struct {
 }
 
 func (se *SnapshotExport) StreamTo(w io.Writer) error {
+	if se.exportKey == nil {
+		return se.streamTarTo(w)
+	}
+
+	if _, err := w.Write(se.exportHeader); err != nil {
+		return err
+	}
+	ew, err := newEncryptWriter(w, se.exportKey)
+	if err != nil {
+		return err
+	}
+	if err := se.streamTarTo(ew); err != nil {
+		return err
+	}
+	return ew.Close()
+}
+
+func (se *SnapshotExport) streamTarTo(w io.Writer) error {
 	// write out a tar
 	var files []string
 	tw := tar.NewWriter(w)
@@ -1055,6 +1277,32 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 		return err
 	}
 
+	// the key goes before the snapshots, so they can be verified when
+	// importing them
+	if se.setKey != nil {
+		hdr := &tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     snapshotKeyName,
+			Size:     int64(len(se.setKey)),
+			Mode:     0600,
+			ModTime:  timeNow(),
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(se.setKey); err != nil {
+			return err
+		}
+	}
+
+	// write out the chunks of deduplicated snapshots, so they are
+	// already in place when importing the snapshots that use them
+	for _, chunk := range se.chunks {
+		if err := streamChunkTo(tw, chunk); err != nil {
+			return err
+		}
+	}
+
 	// write out the individual snapshots
 	for _, snapshotFile := range se.snapshotFiles {
 		stat, err := snapshotFile.Stat()
@@ -1099,6 +1347,10 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 		Date:   timeNow(),
 		Files:  files,
 	}
+	if len(se.chunks) > 0 {
+		// older versions cannot import deduplicated snapshots
+		meta.Format = 2
+	}
 	metaDataBuf, err := json.Marshal(&meta)
 	if err != nil {
 		return fmt.Errorf("cannot marshal meta-data: %v", err)
@@ -1119,3 +1371,30 @@ func (se *SnapshotExport) StreamTo(w io.Writer) error {
 
 	return nil
 }
+
+func streamChunkTo(tw *tar.Writer, chunkPath string) error {
+	hash := filepath.Base(chunkPath)
+	f, err := os.Open(chunkPath)
+	if err != nil {
+		return fmt.Errorf("cannot open snapshot chunk %.7s…: %v", hash, err)
+	}
+	defer f.Close()
+	stat, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	hdr := &tar.Header{
+		Typeflag: tar.TypeReg,
+		Name:     path.Join(chunksDirName, hash),
+		Size:     stat.Size(),
+		Mode:     0600,
+		ModTime:  stat.ModTime(),
+	}
+	if err := tw.WriteHeader(hdr); err != nil {
+		return fmt.Errorf("cannot write header for snapshot chunk %.7s…: %v", hash, err)
+	}
+	if _, err := io.Copy(tw, f); err != nil {
+		return fmt.Errorf("cannot write data for snapshot chunk %.7s…: %v", hash, err)
+	}
+	return nil
+}
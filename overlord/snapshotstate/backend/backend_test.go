@@ -450,7 +450,7 @@ func (s *snapshotSuite) TestIterSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]any{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -568,8 +568,8 @@ func (s *snapshotSuite) TestAddDirToZipBails(c *check.C) {
 	defer restore()
 	savingUserData := false
 	// note as the zip is nil this would panic if it didn't bail
-	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, "", "an/entry", filepath.Join(s.root, "nonexistent"), savingUserData, nil), check.IsNil)
-	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, "", "an/entry", "/etc/passwd", savingUserData, nil), check.IsNil)
+	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, nil, nil, "", "an/entry", filepath.Join(s.root, "nonexistent"), savingUserData, nil), check.IsNil)
+	c.Check(backend.AddSnapDirToZip(nil, snapshot, nil, nil, nil, "", "an/entry", "/etc/passwd", savingUserData, nil), check.IsNil)
 	c.Check(buf.String(), check.Matches, "(?m).* is does not exist.*")
 }
 
@@ -585,7 +585,7 @@ func (s *snapshotSuite) TestAddDirToZipTarFails(c *check.C) {
 	var buf bytes.Buffer
 	z := zip.NewWriter(&buf)
 	savingUserData := false
-	c.Assert(backend.AddSnapDirToZip(ctx, &client.Snapshot{Revision: rev}, z, "", "an/entry", s.root, savingUserData, nil), check.ErrorMatches, ".* context canceled")
+	c.Assert(backend.AddSnapDirToZip(ctx, &client.Snapshot{Revision: rev}, z, nil, nil, "", "an/entry", s.root, savingUserData, nil), check.ErrorMatches, ".* context canceled")
 }
 
 func (s *snapshotSuite) TestAddDirToZip(c *check.C) {
@@ -602,7 +602,7 @@ func (s *snapshotSuite) TestAddDirToZip(c *check.C) {
 		Revision: rev,
 	}
 	savingUserData := false
-	c.Assert(backend.AddSnapDirToZip(context.Background(), snapshot, z, "", "an/entry", s.root, savingUserData, nil), check.IsNil)
+	c.Assert(backend.AddSnapDirToZip(context.Background(), snapshot, z, nil, nil, "", "an/entry", s.root, savingUserData, nil), check.IsNil)
 	z.Close() // write out the central directory
 
 	c.Check(snapshot.SHA3_384, check.HasLen, 1)
@@ -680,7 +680,7 @@ func (s *snapshotSuite) TestAddDirToZipExclusions(c *check.C) {
 	} {
 		testLabel := check.Commentf("%s/%v", testData.excludes, testData.savingUserData)
 
-		err := backend.AddSnapDirToZip(context.Background(), snapshot, z, "", "an/entry", s.root, testData.savingUserData, testData.excludes)
+		err := backend.AddSnapDirToZip(context.Background(), snapshot, z, nil, nil, "", "an/entry", s.root, testData.savingUserData, testData.excludes)
 		c.Check(err, check.ErrorMatches, "tar failed.*")
 		c.Check(tarArgs, check.DeepEquals, testData.expectedArgs, testLabel)
 	}
@@ -732,7 +732,7 @@ func (s *snapshotSuite) testHappyRoundtrip(c *check.C, marker string) {
 		return statSnapshotOpts, nil
 	})()
 
-	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil)
+	shw, err := backend.Save(context.TODO(), shID, info, cfg, []string{"snapuser"}, dynSnapshotOpts, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 	c.Check(shw.Snap, check.Equals, info.InstanceName())
@@ -810,7 +810,7 @@ func (s *snapshotSuite) TestOpenSetIDoverride(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	cfg := map[string]any{"some-setting": false}
 
-	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, uint64(12))
 
@@ -834,7 +834,7 @@ func (s *snapshotSuite) TestRestoreRoundtripDifferentRevision(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.Revision, check.Equals, info.Revision)
 
@@ -1057,7 +1057,7 @@ func (s *snapshotSuite) TestImport(c *check.C) {
 		c.Assert(err, check.IsNil, comm)
 		defer f.Close()
 
-		snapNames, err := backend.Import(context.Background(), t.setID, f, nil)
+		snapNames, _, err := backend.Import(context.Background(), t.setID, f, nil)
 		if t.error != "" {
 			c.Check(err, check.ErrorMatches, t.error, comm)
 			continue
@@ -1090,7 +1090,7 @@ func (s *snapshotSuite) TestImportCheckError(c *check.C) {
 
 	f, err := os.Open(tarFile1)
 	c.Assert(err, check.IsNil)
-	_, err = backend.Import(context.Background(), 14, f, nil)
+	_, _, err = backend.Import(context.Background(), 14, f, nil)
 	c.Assert(err, check.ErrorMatches, `cannot import snapshot 14: validation failed for .+/14_foo_1.0_199.zip": snapshot entry "archive.tgz" expected hash \(d5ef563…\) does not match actual \(6655519…\)`)
 }
 
@@ -1104,10 +1104,10 @@ func (s *snapshotSuite) TestImportDuplicated(c *check.C) {
 	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33", Epoch: epoch}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	err = export.Init()
 	c.Assert(err, check.IsNil)
@@ -1117,7 +1117,7 @@ func (s *snapshotSuite) TestImportDuplicated(c *check.C) {
 	c.Check(buf.Len(), check.Equals, int(export.Size()))
 
 	// now import it
-	_, err = backend.Import(ctx, 123, buf, nil)
+	_, _, err = backend.Import(ctx, 123, buf, nil)
 	dupErr, ok := err.(backend.DuplicatedSnapshotImportError)
 	c.Assert(ok, check.Equals, true)
 	c.Assert(dupErr, check.DeepEquals, backend.DuplicatedSnapshotImportError{SetID: shID, SnapNames: []string{"hello-snap"}})
@@ -1134,14 +1134,14 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	cfg := map[string]any{"some-setting": false}
 	shID := uint64(12)
 
-	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, cfg, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(shw.SetID, check.Equals, shID)
 
 	c.Check(backend.Filename(shw), check.Equals, filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip"))
 	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
 
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	err = export.Init()
 	c.Assert(err, check.IsNil)
@@ -1153,7 +1153,7 @@ func (s *snapshotSuite) TestImportExportRoundtrip(c *check.C) {
 	// now import it
 	c.Assert(os.Remove(filepath.Join(dirs.SnapshotsDir, "12_hello-snap_v1.33_42.zip")), check.IsNil)
 
-	names, err := backend.Import(ctx, 123, buf, nil)
+	names, _, err := backend.Import(ctx, 123, buf, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(names, check.DeepEquals, []string{"hello-snap"})
 
@@ -1214,7 +1214,7 @@ func (s *snapshotSuite) testEstimateSnapshotSize(c *check.C, snapDataDir string,
 		c.Assert(os.WriteFile(filepath.Join(s.root, d, "somefile"), data, 0644), check.IsNil)
 	}
 
-	sz, err := backend.EstimateSnapshotSize(info, nil, opts)
+	sz, err := backend.EstimateSnapshotSize(context.TODO(), info, nil, opts, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sz, check.Equals, uint64(expected))
 }
@@ -1242,7 +1242,7 @@ func (s *snapshotSuite) TestEstimateSnapshotSizeEmpty(c *check.C) {
 		c.Assert(os.MkdirAll(filepath.Join(s.root, d), 0755), check.IsNil)
 	}
 
-	sz, err := backend.EstimateSnapshotSize(info, nil, nil)
+	sz, err := backend.EstimateSnapshotSize(context.TODO(), info, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sz, check.Equals, uint64(0))
 }
@@ -1262,7 +1262,7 @@ func (s *snapshotSuite) TestEstimateSnapshotPassesUsernames(c *check.C) {
 		},
 	}
 
-	_, err := backend.EstimateSnapshotSize(info, []string{"user1", "user2"}, nil)
+	_, err := backend.EstimateSnapshotSize(context.TODO(), info, []string{"user1", "user2"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(gotUsernames, check.DeepEquals, []string{"user1", "user2"})
 }
@@ -1278,7 +1278,7 @@ func (s *snapshotSuite) TestEstimateSnapshotSizeNotDataDirs(c *check.C) {
 		SideInfo:      snap.SideInfo{Revision: snap.R(7)},
 	}
 
-	sz, err := backend.EstimateSnapshotSize(info, nil, nil)
+	sz, err := backend.EstimateSnapshotSize(context.TODO(), info, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sz, check.Equals, uint64(0))
 }
@@ -1295,7 +1295,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	}
 	// create a snapshot
 	shID := uint64(12)
-	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil)
+	_, err := backend.Save(context.TODO(), shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 
 	// content.json + num_files + export.json + footer
@@ -1325,7 +1325,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	// export once
 	buf := bytes.NewBuffer(nil)
 	ctx := context.Background()
-	se, err := backend.NewSnapshotExport(ctx, shID)
+	se, err := backend.NewSnapshotExport(ctx, shID, nil)
 	c.Assert(err, check.IsNil)
 	err = se.Init()
 	c.Assert(err, check.IsNil)
@@ -1342,7 +1342,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 	// change.
 	restore = backend.MockTimeNow(func() time.Time { return time.Date(2242, 1, 1, 12, 0, 0, 0, time.UTC) })
 	defer restore()
-	se2, err := backend.NewSnapshotExport(ctx, shID)
+	se2, err := backend.NewSnapshotExport(ctx, shID, nil)
 	c.Assert(err, check.IsNil)
 	err = se2.Init()
 	c.Assert(err, check.IsNil)
@@ -1355,7 +1355,7 @@ func (s *snapshotSuite) TestExportTwice(c *check.C) {
 }
 
 func (s *snapshotSuite) TestExportUnhappy(c *check.C) {
-	se, err := backend.NewSnapshotExport(context.Background(), 5)
+	se, err := backend.NewSnapshotExport(context.Background(), 5, nil)
 	c.Assert(err, check.ErrorMatches, "no snapshot data found for 5")
 	c.Assert(se, check.IsNil)
 }
@@ -1661,16 +1661,16 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		Version: "v1.33",
 	}
 	shID := uint64(12)
-	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err := backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
 	// now export it
-	export, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.HasLen, sha256.Size)
 
 	// and check that exporting it again leads to the same content hash
-	export2, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export2, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.DeepEquals, export2.ContentHash())
 
@@ -1683,10 +1683,10 @@ func (s *snapshotSuite) TestSnapshotExportContentHash(c *check.C) {
 		},
 		Version: "v1.33",
 	}
-	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil)
+	shw, err = backend.Save(ctx, shID, info, nil, []string{"snapuser"}, nil, nil, nil)
 	c.Check(err, check.IsNil)
 
-	export3, err := backend.NewSnapshotExport(ctx, shw.SetID)
+	export3, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(export.ContentHash(), check.Not(check.DeepEquals), export3.ContentHash())
 }
@@ -0,0 +1,449 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"compress/gzip"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+)
+
+// The chunk store keeps the data of deduplicated snapshots. Instead of a
+// compressed tarball, the zip of a deduplicated snapshot carries an index
+// (chunksIndexName) listing, for each archive entry, the content-defined
+// chunks that make up the uncompressed tarball. The chunks themselves are
+// kept, gzipped, under dirs.SnapshotsDir/chunks/ and named after the
+// SHA3-384 of their uncompressed content, so a chunk that is already in the
+// store (because an earlier snapshot saved the same data) is not written
+// again.
+
+const (
+	chunksDirName   = "chunks"
+	chunksIndexName = "chunks.json"
+
+	chunkHashLen = 2 * 48 // hex-encoded SHA3-384
+)
+
+var (
+	// content-defined chunking parameters; chunk boundaries are found
+	// where the rolling hash has the bits in chunkMask unset, with the
+	// resulting chunks being at least chunkMinSize and at most
+	// chunkMaxSize bytes long (~1MiB on average).
+	chunkMinSize = 256 * 1024
+	chunkMaxSize = 4 * 1024 * 1024
+	chunkMask    = uint64(1<<20 - 1)
+
+	gearTable = newGearTable()
+
+	// chunkStoreLock serialises the removal of unreferenced chunks
+	// against saves and imports, which write chunks before the snapshot
+	// that references them is committed.
+	chunkStoreLock sync.RWMutex
+)
+
+// newGearTable returns the table of pseudo-random values used by the rolling
+// hash. It must never change as chunk boundaries (and therefore the
+// deduplication ratio between old and new snapshots) depend on it.
+func newGearTable() *[256]uint64 {
+	var table [256]uint64
+	// splitmix64
+	seed := uint64(0x736e617073686f74) // "snapshot"
+	for i := range table {
+		seed += 0x9e3779b97f4a7c15
+		z := seed
+		z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+		z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+		table[i] = z ^ (z >> 31)
+	}
+	return &table
+}
+
+// chunkRef is a reference to a chunk in the chunk store.
+type chunkRef struct {
+	// SHA3_384 of the uncompressed chunk content, hex-encoded
+	Hash string `json:"hash"`
+	// Size of the uncompressed chunk content
+	Size int64 `json:"size"`
+}
+
+// chunksIndex is the content of the chunksIndexName member of a
+// deduplicated snapshot.
+type chunksIndex struct {
+	Format  int                   `json:"format"`
+	Entries map[string][]chunkRef `json:"entries"`
+
+	// dir is the chunk store the index was read from
+	dir string
+}
+
+func chunksDir() string {
+	return filepath.Join(dirs.SnapshotsDir, chunksDirName)
+}
+
+func chunkPathIn(dir, hash string) string {
+	return filepath.Join(dir, hash[:2], hash)
+}
+
+func chunkPath(hash string) string {
+	return chunkPathIn(chunksDir(), hash)
+}
+
+func isChunkHash(hash string) bool {
+	if len(hash) != chunkHashLen {
+		return false
+	}
+	_, err := hex.DecodeString(hash)
+	return err == nil
+}
+
+// storeChunk adds the given chunk content to the chunk store, unless it is
+// already there. It returns the number of bytes written to disk.
+func storeChunk(hash string, data []byte) (int64, error) {
+	p := chunkPath(hash)
+	if osutil.FileExists(p) {
+		return 0, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return 0, err
+	}
+	aw, err := osutil.NewAtomicFile(p, 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return 0, err
+	}
+	// if things worked, we'll commit (and Cancel becomes a NOP)
+	defer aw.Cancel()
+
+	var sz osutil.Sizer
+	gw := gzip.NewWriter(io.MultiWriter(aw, &sz))
+	if _, err := gw.Write(data); err != nil {
+		return 0, err
+	}
+	if err := gw.Close(); err != nil {
+		return 0, err
+	}
+	if err := aw.Commit(); err != nil {
+		return 0, err
+	}
+	return sz.Size(), nil
+}
+
+// importChunk adds a chunk, in the compressed format of the chunk store, to
+// the store after verifying that its content matches the given hash.
+func importChunk(hash string, r io.Reader) error {
+	if !isChunkHash(hash) {
+		return fmt.Errorf("invalid chunk name %q", hash)
+	}
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("cannot read chunk %.7s…: %v", hash, err)
+	}
+	defer gr.Close()
+	data, err := io.ReadAll(gr)
+	if err != nil {
+		return fmt.Errorf("cannot read chunk %.7s…: %v", hash, err)
+	}
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(data)
+	if actualHash := fmt.Sprintf("%x", hasher.Sum(nil)); actualHash != hash {
+		return fmt.Errorf("chunk %.7s… does not match its content hash (%.7s…)", hash, actualHash)
+	}
+	_, err = storeChunk(hash, data)
+	return err
+}
+
+// chunkWriter is an io.Writer that splits what is written to it into
+// content-defined chunks, adding them to the chunk store.
+type chunkWriter struct {
+	buf  []byte
+	hash uint64
+	refs []chunkRef
+	// stored is the number of bytes actually added to the store
+	stored int64
+}
+
+func newChunkWriter() *chunkWriter {
+	return &chunkWriter{buf: make([]byte, 0, chunkMinSize)}
+}
+
+func (cw *chunkWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		cut := -1
+		for i, b := range p {
+			cw.hash = (cw.hash << 1) + gearTable[b]
+			size := len(cw.buf) + i + 1
+			if size < chunkMinSize {
+				continue
+			}
+			if cw.hash&chunkMask == 0 || size >= chunkMaxSize {
+				cut = i + 1
+				break
+			}
+		}
+		if cut < 0 {
+			cw.buf = append(cw.buf, p...)
+			return written + len(p), nil
+		}
+		cw.buf = append(cw.buf, p[:cut]...)
+		if err := cw.flush(); err != nil {
+			return written, err
+		}
+		written += cut
+		p = p[cut:]
+	}
+	return written, nil
+}
+
+func (cw *chunkWriter) flush() error {
+	if len(cw.buf) == 0 {
+		return nil
+	}
+	hasher := crypto.SHA3_384.New()
+	hasher.Write(cw.buf)
+	hash := fmt.Sprintf("%x", hasher.Sum(nil))
+	n, err := storeChunk(hash, cw.buf)
+	if err != nil {
+		return fmt.Errorf("cannot store snapshot chunk: %v", err)
+	}
+	cw.stored += n
+	cw.refs = append(cw.refs, chunkRef{Hash: hash, Size: int64(len(cw.buf))})
+	cw.buf = cw.buf[:0]
+	cw.hash = 0
+	return nil
+}
+
+// Close stores any pending data and returns the references to the chunks
+// written.
+func (cw *chunkWriter) Close() ([]chunkRef, error) {
+	if err := cw.flush(); err != nil {
+		return nil, err
+	}
+	return cw.refs, nil
+}
+
+// chunkReader reassembles the content of a snapshot entry from its chunks,
+// verifying each of them as it goes.
+type chunkReader struct {
+	dir  string
+	refs []chunkRef
+	cur  io.ReadCloser
+	// err is the first error found reading the chunks
+	err error
+}
+
+func (cr *chunkReader) Read(p []byte) (n int, err error) {
+	defer func() {
+		if err != nil && err != io.EOF && cr.err == nil {
+			cr.err = err
+		}
+	}()
+	for {
+		if cr.cur == nil {
+			if len(cr.refs) == 0 {
+				return 0, io.EOF
+			}
+			rc, err := openChunk(cr.dir, cr.refs[0])
+			if err != nil {
+				return 0, err
+			}
+			cr.cur = rc
+			cr.refs = cr.refs[1:]
+		}
+		n, err := cr.cur.Read(p)
+		if err == io.EOF {
+			err = cr.cur.Close()
+			cr.cur = nil
+			if n > 0 || err != nil {
+				return n, err
+			}
+			continue
+		}
+		return n, err
+	}
+}
+
+func (cr *chunkReader) Close() error {
+	if cr.cur != nil {
+		err := cr.cur.Close()
+		cr.cur = nil
+		return err
+	}
+	return nil
+}
+
+// verifiedChunk reads a chunk from the store, checking its size and hash
+// when reaching the end of it.
+type verifiedChunk struct {
+	ref  chunkRef
+	f    *os.File
+	gz   *gzip.Reader
+	hash hash.Hash
+	size int64
+}
+
+func openChunk(dir string, ref chunkRef) (io.ReadCloser, error) {
+	if !isChunkHash(ref.Hash) {
+		return nil, fmt.Errorf("invalid chunk reference %q", ref.Hash)
+	}
+	f, err := os.Open(chunkPathIn(dir, ref.Hash))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("snapshot chunk %.7s… is missing", ref.Hash)
+		}
+		return nil, err
+	}
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("cannot read snapshot chunk %.7s…: %v", ref.Hash, err)
+	}
+	return &verifiedChunk{ref: ref, f: f, gz: gz, hash: crypto.SHA3_384.New()}, nil
+}
+
+func (vc *verifiedChunk) Read(p []byte) (int, error) {
+	n, err := vc.gz.Read(p)
+	vc.hash.Write(p[:n])
+	vc.size += int64(n)
+	if err == io.EOF {
+		if vc.size != vc.ref.Size {
+			return n, fmt.Errorf("snapshot chunk %.7s… size (%d) different from expected (%d)", vc.ref.Hash, vc.size, vc.ref.Size)
+		}
+		if actualHash := fmt.Sprintf("%x", vc.hash.Sum(nil)); actualHash != vc.ref.Hash {
+			return n, fmt.Errorf("snapshot chunk %.7s… does not match its content hash (%.7s…)", vc.ref.Hash, actualHash)
+		}
+	}
+	return n, err
+}
+
+func (vc *verifiedChunk) Close() error {
+	vc.gz.Close()
+	return vc.f.Close()
+}
+
+// readChunksIndex reads the chunks index of the given snapshot file, if it
+// has one; a nil index is returned for snapshots that are not deduplicated.
+func readChunksIndex(f *os.File) (*chunksIndex, error) {
+	r, _, err := zipMember(f, chunksIndexName)
+	if err != nil {
+		if _, ok := err.(missingMemberError); ok {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer r.Close()
+	var idx chunksIndex
+	if err := json.NewDecoder(r).Decode(&idx); err != nil {
+		return nil, fmt.Errorf("cannot decode snapshot chunks index: %v", err)
+	}
+	if idx.Format != 1 {
+		return nil, fmt.Errorf("unsupported snapshot chunks index format %d", idx.Format)
+	}
+	// the chunk store lives next to the snapshots using it
+	idx.dir = filepath.Join(filepath.Dir(f.Name()), chunksDirName)
+	return &idx, nil
+}
+
+// uniqueChunkHashes returns the hashes of the chunks referenced by the given
+// index that are not in seen, each only once, adding them to seen.
+func (idx *chunksIndex) uniqueChunkHashes(seen map[string]bool) []string {
+	if idx == nil {
+		return nil
+	}
+	var hashes []string
+	entries := make([]string, 0, len(idx.Entries))
+	for entry := range idx.Entries {
+		entries = append(entries, entry)
+	}
+	sort.Strings(entries)
+	for _, entry := range entries {
+		for _, ref := range idx.Entries[entry] {
+			if !seen[ref.Hash] {
+				seen[ref.Hash] = true
+				hashes = append(hashes, ref.Hash)
+			}
+		}
+	}
+	return hashes
+}
+
+// CleanupUnreferencedChunks removes from the chunk store the chunks that are
+// no longer referenced by any snapshot, e.g. after snapshots were forgotten.
+//
+// The number of chunks removed is returned.
+func CleanupUnreferencedChunks(ctx context.Context) (removed int, err error) {
+	if exists, _, _ := osutil.DirExists(chunksDir()); !exists {
+		return 0, nil
+	}
+
+	chunkStoreLock.Lock()
+	defer chunkStoreLock.Unlock()
+
+	referenced := make(map[string]bool)
+	err = Iter(ctx, func(r *Reader) error {
+		// read the index directly, as broken snapshots might still
+		// reference chunks that should be kept around
+		idx, err := readChunksIndex(r.File)
+		if err != nil {
+			return fmt.Errorf("cannot determine chunks used by snapshot %q: %v", r.Name(), err)
+		}
+		idx.uniqueChunkHashes(referenced)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("cannot cleanup snapshot chunks: %v", err)
+	}
+
+	paths, err := filepathGlob(filepath.Join(chunksDir(), "*", "*"))
+	if err != nil {
+		return 0, err
+	}
+	var errs []error
+	for _, p := range paths {
+		hash := filepath.Base(p)
+		if !isChunkHash(hash) || referenced[hash] {
+			continue
+		}
+		if err := os.Remove(p); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		removed++
+	}
+	if removed > 0 {
+		logger.Debugf("Removed %d unreferenced snapshot chunks.", removed)
+	}
+	if len(errs) > 0 {
+		return removed, newMultiError("cannot cleanup snapshot chunks", errs)
+	}
+	return removed, nil
+}
@@ -0,0 +1,341 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+var dedupFlags = &backend.SaveFlags{Deduplicate: true}
+
+func randomData(seed int64, size int) []byte {
+	data := make([]byte, size)
+	rand.New(rand.NewSource(seed)).Read(data)
+	return data
+}
+
+func chunkFiles(c *check.C) []string {
+	matches, err := filepath.Glob(filepath.Join(dirs.SnapshotsDir, "chunks", "*", "*"))
+	c.Assert(err, check.IsNil)
+	sort.Strings(matches)
+	return matches
+}
+
+func zipMembers(c *check.C, fn string) []string {
+	zr, err := zip.OpenReader(fn)
+	c.Assert(err, check.IsNil)
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// addBulkData adds some incompressible data to the snap so that the
+// deduplicated snapshot is made of more than one chunk.
+func (s *snapshotSuite) addBulkData(c *check.C) {
+	si := snap.MinimalPlaceInfo("hello-snap", snap.R(42))
+	c.Assert(os.WriteFile(filepath.Join(si.DataDir(), "bulk"), randomData(1, 64*1024), 0644), check.IsNil)
+}
+
+func (s *snapshotSuite) TestChunkWriterDeduplicates(c *check.C) {
+	defer backend.MockChunkSizes(64, 1024, 1<<7-1)()
+
+	data := randomData(42, 64*1024)
+	hashes1, err := backend.ChunkData(data)
+	c.Assert(err, check.IsNil)
+	c.Assert(len(hashes1) > 10, check.Equals, true)
+	for _, hash := range hashes1 {
+		c.Check(filepath.Join(dirs.SnapshotsDir, "chunks", hash[:2], hash), check.Equals, backend.ChunkPath(hash))
+	}
+	stored := chunkFiles(c)
+
+	// chunking is deterministic
+	again, err := backend.ChunkData(data)
+	c.Assert(err, check.IsNil)
+	c.Check(again, check.DeepEquals, hashes1)
+	c.Check(chunkFiles(c), check.DeepEquals, stored)
+
+	// inserting data only affects the chunks around the insertion
+	modified := append(append(append([]byte{}, data[:32*1024]...), []byte("inserted data")...), data[32*1024:]...)
+	hashes2, err := backend.ChunkData(modified)
+	c.Assert(err, check.IsNil)
+
+	known := make(map[string]bool, len(hashes1))
+	for _, hash := range hashes1 {
+		known[hash] = true
+	}
+	var newChunks int
+	for _, hash := range hashes2 {
+		if !known[hash] {
+			newChunks++
+		}
+	}
+	c.Check(newChunks > 0, check.Equals, true)
+	c.Check(newChunks <= 2, check.Equals, true, check.Commentf("%d new chunks", newChunks))
+	c.Check(chunkFiles(c), check.HasLen, len(stored)+newChunks)
+}
+
+func (s *snapshotSuite) TestDeduplicatedRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	logger.SimpleSetup(nil)
+	defer backend.MockChunkSizes(512, 8192, 1<<11-1)()
+	s.addBulkData(c)
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	cfg := map[string]any{"some-setting": false}
+
+	shw, err := backend.Save(context.TODO(), 12, info, cfg, []string{"snapuser"}, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Check(zipMembers(c, backend.Filename(shw)), check.DeepEquals, []string{"chunks.json", "meta.json", "meta.sha3_384"})
+
+	stored := chunkFiles(c)
+	c.Assert(len(stored) > 2, check.Equals, true)
+
+	// saving the same data again does not store anything new
+	shw2, err := backend.Save(context.TODO(), 13, info, cfg, []string{"snapuser"}, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	c.Check(shw2.SHA3_384, check.DeepEquals, shw.SHA3_384)
+	c.Check(chunkFiles(c), check.DeepEquals, stored)
+
+	shs, err := backend.List(context.TODO(), 0, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(shs, check.HasLen, 2)
+
+	shr, err := backend.Open(backend.Filename(shw2), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.IsDeduplicated(), check.Equals, true)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	newroot := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
+	dirs.SetRootDir(newroot)
+
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+	c.Check(exec.Command("diff", "-urN", "-x*.zip", "-xchunks", s.root, newroot).Run(), check.IsNil)
+}
+
+func (s *snapshotSuite) TestDeduplicatedCheckBrokenChunks(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockChunkSizes(512, 8192, 1<<11-1)()
+	s.addBulkData(c)
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Assert(shr.Check(context.TODO(), nil), check.IsNil)
+
+	stored := chunkFiles(c)
+	c.Assert(len(stored) > 1, check.Equals, true)
+
+	// a chunk with the wrong content
+	var buf bytes.Buffer
+	gw := gzip.NewWriter(&buf)
+	gw.Write([]byte("not the right content"))
+	c.Assert(gw.Close(), check.IsNil)
+	c.Assert(os.WriteFile(stored[0], buf.Bytes(), 0600), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, `snapshot chunk [0-9a-f]{7}… (size \(\d+\) different from expected \(\d+\)|does not match its content hash .*)`)
+
+	// a missing chunk
+	c.Assert(os.Remove(stored[0]), check.IsNil)
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, `snapshot chunk [0-9a-f]{7}… is missing`)
+}
+
+func (s *snapshotSuite) TestCleanupUnreferencedChunks(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockChunkSizes(512, 8192, 1<<11-1)()
+
+	// nothing to do without a chunk store
+	removed, err := backend.CleanupUnreferencedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+
+	s.addBulkData(c)
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw1, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	shw2, err := backend.Save(context.TODO(), 13, info, nil, []string{"snapuser"}, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	// a non-deduplicated snapshot does not get in the way
+	_, err = backend.Save(context.TODO(), 14, info, nil, []string{"snapuser"}, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+
+	stored := chunkFiles(c)
+	c.Assert(stored, check.Not(check.HasLen), 0)
+	// unknown files are left alone
+	c.Assert(os.MkdirAll(filepath.Join(dirs.SnapshotsDir, "chunks", "00"), 0700), check.IsNil)
+	c.Assert(os.WriteFile(filepath.Join(dirs.SnapshotsDir, "chunks", "00", "not-a-chunk"), nil, 0600), check.IsNil)
+
+	// the chunks are still used by the second snapshot
+	c.Assert(os.Remove(backend.Filename(shw1)), check.IsNil)
+	removed, err = backend.CleanupUnreferencedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, 0)
+	c.Check(chunkFiles(c), check.HasLen, len(stored)+1)
+
+	c.Assert(os.Remove(backend.Filename(shw2)), check.IsNil)
+	removed, err = backend.CleanupUnreferencedChunks(context.TODO())
+	c.Assert(err, check.IsNil)
+	c.Check(removed, check.Equals, len(stored))
+	c.Check(chunkFiles(c), check.DeepEquals, []string{filepath.Join(dirs.SnapshotsDir, "chunks", "00", "not-a-chunk")})
+}
+
+func (s *snapshotSuite) TestDeduplicatedImportExportRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockChunkSizes(512, 8192, 1<<11-1)()
+	s.addBulkData(c)
+	ctx := context.TODO()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	stored := chunkFiles(c)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, nil)
+	c.Assert(err, check.IsNil)
+	c.Assert(export.Init(), check.IsNil)
+	buf := bytes.NewBuffer(nil)
+	c.Assert(export.StreamTo(buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+
+	// the chunks come before the snapshot using them
+	var names []string
+	var meta struct {
+		Format int `json:"format"`
+	}
+	tr := tar.NewReader(bytes.NewReader(buf.Bytes()))
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		c.Assert(err, check.IsNil)
+		names = append(names, hdr.Name)
+		if hdr.Name == "export.json" {
+			c.Assert(json.NewDecoder(tr).Decode(&meta), check.IsNil)
+		}
+	}
+	c.Assert(names, check.HasLen, len(stored)+3)
+	c.Check(names[0], check.Equals, "content.json")
+	for _, name := range names[1 : len(stored)+1] {
+		c.Check(strings.HasPrefix(name, "chunks/"), check.Equals, true, check.Commentf(name))
+	}
+	c.Check(names[len(stored)+1:], check.DeepEquals, []string{"12_hello-snap_v1.33_42.zip", "export.json"})
+	c.Check(meta.Format, check.Equals, 2)
+
+	// import into an empty snapshots dir
+	c.Assert(os.RemoveAll(dirs.SnapshotsDir), check.IsNil)
+	snapNames, _, err := backend.Import(ctx, 123, buf, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"hello-snap"})
+	c.Check(chunkFiles(c), check.DeepEquals, stored)
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.IsDeduplicated(), check.Equals, true)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestImportBadChunk(c *check.C) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	data := []byte("not gzipped")
+	c.Assert(tw.WriteHeader(&tar.Header{Typeflag: tar.TypeReg, Name: "chunks/" + strings.Repeat("ab", 48), Size: int64(len(data)), Mode: 0600}), check.IsNil)
+	_, err := tw.Write(data)
+	c.Assert(err, check.IsNil)
+	c.Assert(tw.Close(), check.IsNil)
+
+	_, _, err = backend.Import(context.TODO(), 123, &buf, nil)
+	c.Check(err, check.ErrorMatches, `cannot import snapshot 123: cannot read chunk abababa…: .*`)
+	c.Check(chunkFiles(c), check.HasLen, 0)
+}
+
+func (s *snapshotSuite) TestEstimateSnapshotSizeDeduplicated(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+
+	full, err := backend.EstimateSnapshotSize(context.TODO(), info, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	c.Check(full, check.Not(check.Equals), uint64(0))
+
+	_, err = backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+
+	// nothing changed since the last deduplicated snapshot
+	sz, err := backend.EstimateSnapshotSize(context.TODO(), info, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	c.Check(sz, check.Equals, uint64(0))
+
+	// but a full snapshot would still cost the same
+	sz, err = backend.EstimateSnapshotSize(context.TODO(), info, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(sz, check.Equals, full)
+
+	// modified data is accounted for
+	content := []byte("modified versioned system canary\n")
+	fn := filepath.Join(info.DataDir(), "foo")
+	c.Assert(os.WriteFile(fn, content, 0644), check.IsNil)
+	future := time.Now().Add(time.Hour)
+	c.Assert(os.Chtimes(fn, future, future), check.IsNil)
+	sz, err = backend.EstimateSnapshotSize(context.TODO(), info, nil, nil, dedupFlags)
+	c.Assert(err, check.IsNil)
+	c.Check(sz, check.Equals, uint64(len(content)))
+}
@@ -0,0 +1,355 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/hkdf"
+	"golang.org/x/crypto/scrypt"
+)
+
+// Encrypted data is written as a header made of encryptedMagic and a
+// random salt, followed by segments of at most cryptSegmentSize bytes of
+// data, each sealed with AES-256-GCM. The key for the stream is derived
+// from the given key and the salt, so nonces can simply count segments.
+// The last segment is sealed with a distinct nonce so truncation is
+// detected.
+const (
+	encryptedMagic   = "SNAPENC1"
+	cryptSaltSize    = 16
+	cryptSegmentSize = 64 * 1024
+
+	// SnapshotKeySize is the size of the keys used to encrypt
+	// snapshot sets.
+	SnapshotKeySize = 32
+
+	// Exports encrypted with a passphrase start with exportMagic,
+	// followed by the scrypt cost parameter and salt used to derive the
+	// key from the passphrase, and then the encrypted tar stream.
+	exportMagic      = "SNAPEXP1"
+	exportHeaderSize = len(exportMagic) + 1 + cryptSaltSize
+
+	minExportKDFLogN = 10
+	maxExportKDFLogN = 22
+
+	// snapshotKeyName is the name of the member of an export carrying
+	// the key of an encrypted snapshot set
+	snapshotKeyName = "snapshot.key"
+)
+
+var (
+	randRead = rand.Read
+
+	// the scrypt cost parameter (as a power of two) for new exports
+	exportKDFLogN = 15
+
+	errAuthentication = errors.New("wrong key or corrupted data")
+
+	// ErrPassphraseRequired is returned when importing an export
+	// that is encrypted without providing a passphrase.
+	ErrPassphraseRequired = errors.New("snapshot export is encrypted and requires a passphrase")
+)
+
+// NewSnapshotKey returns a new random key for encrypting a snapshot set.
+func NewSnapshotKey() ([]byte, error) {
+	key := make([]byte, SnapshotKeySize)
+	if _, err := randRead(key); err != nil {
+		return nil, fmt.Errorf("cannot generate snapshot key: %v", err)
+	}
+	return key, nil
+}
+
+func newKeyWrapCipher(wrappingKey []byte) (cipher.AEAD, error) {
+	if len(wrappingKey) != SnapshotKeySize {
+		return nil, fmt.Errorf("invalid wrapping key size %d", len(wrappingKey))
+	}
+	block, err := aes.NewCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// keyWrapData binds a wrapped key to its snapshot set.
+func keyWrapData(setID uint64) []byte {
+	prefix := "snapd snapshot key "
+	data := make([]byte, len(prefix)+8)
+	copy(data, prefix)
+	binary.BigEndian.PutUint64(data[len(prefix):], setID)
+	return data
+}
+
+// WrapSnapshotKey encrypts the key of the given snapshot set with the
+// wrapping key, so that it is not kept in the clear.
+func WrapSnapshotKey(wrappingKey []byte, setID uint64, key []byte) ([]byte, error) {
+	aead, err := newKeyWrapCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := randRead(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, key, keyWrapData(setID)), nil
+}
+
+// UnwrapSnapshotKey returns the key of the given snapshot set that was
+// wrapped with WrapSnapshotKey.
+func UnwrapSnapshotKey(wrappingKey []byte, setID uint64, wrapped []byte) ([]byte, error) {
+	aead, err := newKeyWrapCipher(wrappingKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errAuthentication
+	}
+	nonce, sealed := wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():]
+	key, err := aead.Open(nil, nonce, sealed, keyWrapData(setID))
+	if err != nil {
+		return nil, errAuthentication
+	}
+	return key, nil
+}
+
+func newStreamCipher(key, salt []byte) (cipher.AEAD, error) {
+	if len(key) != SnapshotKeySize {
+		return nil, fmt.Errorf("invalid key size %d", len(key))
+	}
+	streamKey := make([]byte, SnapshotKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, key, salt, []byte("snapd snapshot data")), streamKey); err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(streamKey)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func segmentNonce(aead cipher.AEAD, seq uint64, final bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint64(nonce, seq)
+	if final {
+		nonce[len(nonce)-1] = 1
+	}
+	return nonce
+}
+
+// encryptWriter encrypts what is written to it into the underlying writer.
+// It must be closed to write out the final segment.
+type encryptWriter struct {
+	w    io.Writer
+	aead cipher.AEAD
+	seq  uint64
+	buf  []byte
+}
+
+func newEncryptWriter(w io.Writer, key []byte) (*encryptWriter, error) {
+	header := make([]byte, len(encryptedMagic)+cryptSaltSize)
+	copy(header, encryptedMagic)
+	salt := header[len(encryptedMagic):]
+	if _, err := randRead(salt); err != nil {
+		return nil, err
+	}
+	aead, err := newStreamCipher(key, salt)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(header); err != nil {
+		return nil, err
+	}
+	return &encryptWriter{
+		w:    w,
+		aead: aead,
+		buf:  make([]byte, 0, cryptSegmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (ew *encryptWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		// a full segment is only written out once more data comes
+		// in, as the last segment needs to be sealed as such
+		if len(ew.buf) == cryptSegmentSize {
+			if err := ew.seal(false); err != nil {
+				return n, err
+			}
+		}
+		m := copy(ew.buf[len(ew.buf):cryptSegmentSize], p)
+		ew.buf = ew.buf[:len(ew.buf)+m]
+		n += m
+		p = p[m:]
+	}
+	return n, nil
+}
+
+func (ew *encryptWriter) seal(final bool) error {
+	sealed := ew.aead.Seal(ew.buf[:0], segmentNonce(ew.aead, ew.seq, final), ew.buf, nil)
+	ew.seq++
+	ew.buf = ew.buf[:0]
+	_, err := ew.w.Write(sealed)
+	return err
+}
+
+// Close writes out the final segment; it does not close the underlying
+// writer.
+func (ew *encryptWriter) Close() error {
+	return ew.seal(true)
+}
+
+// decryptReader decrypts and authenticates data written by an
+// encryptWriter.
+type decryptReader struct {
+	r     *bufio.Reader
+	aead  cipher.AEAD
+	seq   uint64
+	buf   []byte
+	plain []byte
+	done  bool
+	err   error
+}
+
+func newDecryptReader(r io.Reader, key []byte) (*decryptReader, error) {
+	header := make([]byte, len(encryptedMagic)+cryptSaltSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("cannot read encryption header: %v", err)
+	}
+	if string(header[:len(encryptedMagic)]) != encryptedMagic {
+		return nil, errors.New("data is not encrypted")
+	}
+	aead, err := newStreamCipher(key, header[len(encryptedMagic):])
+	if err != nil {
+		return nil, err
+	}
+	return &decryptReader{
+		r:    bufio.NewReader(r),
+		aead: aead,
+		buf:  make([]byte, cryptSegmentSize+aead.Overhead()),
+	}, nil
+}
+
+func (dr *decryptReader) Read(p []byte) (int, error) {
+	for len(dr.plain) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		dr.err = dr.next()
+	}
+	n := copy(p, dr.plain)
+	dr.plain = dr.plain[n:]
+	return n, nil
+}
+
+func (dr *decryptReader) next() error {
+	n, err := io.ReadFull(dr.r, dr.buf)
+	final := false
+	switch err {
+	case nil:
+		if _, err := dr.r.Peek(1); err == io.EOF {
+			final = true
+		} else if err != nil {
+			return err
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		final = true
+	default:
+		return err
+	}
+	plain, err := dr.aead.Open(dr.buf[:0], segmentNonce(dr.aead, dr.seq, final), dr.buf[:n], nil)
+	if err != nil {
+		return errAuthentication
+	}
+	dr.seq++
+	dr.plain = plain
+	dr.done = final
+	return nil
+}
+
+func passphraseKey(passphrase string, salt []byte, logN int) ([]byte, error) {
+	return scrypt.Key([]byte(passphrase), salt, 1<<logN, 8, 1, SnapshotKeySize)
+}
+
+// newExportPassphraseKey derives a key from the passphrase for a new
+// export, returning it along with the header for the export.
+func newExportPassphraseKey(passphrase string) (key, header []byte, err error) {
+	header = make([]byte, exportHeaderSize)
+	copy(header, exportMagic)
+	header[len(exportMagic)] = byte(exportKDFLogN)
+	salt := header[len(exportMagic)+1:]
+	if _, err := randRead(salt); err != nil {
+		return nil, nil, err
+	}
+	key, err = passphraseKey(passphrase, salt, exportKDFLogN)
+	if err != nil {
+		return nil, nil, fmt.Errorf("cannot derive key from passphrase: %v", err)
+	}
+	return key, header, nil
+}
+
+// exportReader returns a reader for the tar stream of the snapshot export
+// read from r, decrypting it with the passphrase if the export is
+// encrypted.
+func exportReader(r io.Reader, passphrase string) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(len(exportMagic))
+	if err != nil || string(magic) != exportMagic {
+		// not encrypted, reading the tar stream will tell whether
+		// it's valid
+		return br, nil
+	}
+	if passphrase == "" {
+		return nil, ErrPassphraseRequired
+	}
+	header := make([]byte, exportHeaderSize)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("cannot read export header: %v", err)
+	}
+	logN := int(header[len(exportMagic)])
+	if logN < minExportKDFLogN || logN > maxExportKDFLogN {
+		return nil, fmt.Errorf("unsupported key derivation cost %d", logN)
+	}
+	key, err := passphraseKey(passphrase, header[len(exportMagic)+1:], logN)
+	if err != nil {
+		return nil, fmt.Errorf("cannot derive key from passphrase: %v", err)
+	}
+	dr, err := newDecryptReader(br, key)
+	if err != nil {
+		return nil, err
+	}
+	// check the passphrase upfront, for a more helpful error
+	if err := dr.next(); err != nil {
+		if err == errAuthentication {
+			return nil, errors.New("cannot decrypt snapshot export: wrong passphrase or corrupted data")
+		}
+		return nil, fmt.Errorf("cannot decrypt snapshot export: %v", err)
+	}
+	return dr, nil
+}
@@ -0,0 +1,257 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package backend_test
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *snapshotSuite) TestEncryptDecryptRoundtrip(c *check.C) {
+	key, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+	c.Assert(key, check.HasLen, backend.SnapshotKeySize)
+
+	for _, size := range []int{0, 1, backend.CryptSegmentSize - 1, backend.CryptSegmentSize, backend.CryptSegmentSize + 1, 3 * backend.CryptSegmentSize} {
+		comment := check.Commentf("size %d", size)
+		data := randomData(int64(size), size)
+		encrypted, err := backend.Encrypt(data, key)
+		c.Assert(err, check.IsNil, comment)
+		if size > 64 {
+			c.Check(bytes.Contains(encrypted, data[:64]), check.Equals, false, comment)
+		}
+
+		decrypted, err := backend.Decrypt(encrypted, key)
+		c.Assert(err, check.IsNil, comment)
+		c.Check(decrypted, check.DeepEquals, data, comment)
+
+		// the same data encrypts differently every time
+		again, err := backend.Encrypt(data, key)
+		c.Assert(err, check.IsNil, comment)
+		c.Check(again, check.Not(check.DeepEquals), encrypted, comment)
+		c.Check(again, check.HasLen, len(encrypted), comment)
+	}
+}
+
+func (s *snapshotSuite) TestWrapUnwrapSnapshotKey(c *check.C) {
+	wrappingKey, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+	key, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+
+	wrapped, err := backend.WrapSnapshotKey(wrappingKey, 42, key)
+	c.Assert(err, check.IsNil)
+	c.Check(bytes.Contains(wrapped, key), check.Equals, false)
+
+	unwrapped, err := backend.UnwrapSnapshotKey(wrappingKey, 42, wrapped)
+	c.Assert(err, check.IsNil)
+	c.Check(unwrapped, check.DeepEquals, key)
+
+	// the wrapped key is bound to its set
+	_, err = backend.UnwrapSnapshotKey(wrappingKey, 43, wrapped)
+	c.Check(err, check.ErrorMatches, "wrong key or corrupted data")
+
+	otherKey, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+	_, err = backend.UnwrapSnapshotKey(otherKey, 42, wrapped)
+	c.Check(err, check.ErrorMatches, "wrong key or corrupted data")
+
+	_, err = backend.UnwrapSnapshotKey(wrappingKey, 42, wrapped[:4])
+	c.Check(err, check.ErrorMatches, "wrong key or corrupted data")
+
+	_, err = backend.WrapSnapshotKey([]byte("short"), 42, key)
+	c.Check(err, check.ErrorMatches, "invalid wrapping key size 5")
+}
+
+func (s *snapshotSuite) TestDecryptDetectsTampering(c *check.C) {
+	key, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+	otherKey, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+
+	data := randomData(1, 2*backend.CryptSegmentSize+100)
+	encrypted, err := backend.Encrypt(data, key)
+	c.Assert(err, check.IsNil)
+
+	_, err = backend.Decrypt(encrypted, otherKey)
+	c.Check(err, check.ErrorMatches, "wrong key or corrupted data")
+
+	flipped := append([]byte{}, encrypted...)
+	flipped[len(flipped)/2] ^= 1
+	_, err = backend.Decrypt(flipped, key)
+	c.Check(err, check.ErrorMatches, "wrong key or corrupted data")
+
+	// dropping whole segments is detected too
+	_, err = backend.Decrypt(encrypted[:len(encrypted)-100-16], key)
+	c.Check(err, check.ErrorMatches, "wrong key or corrupted data")
+
+	_, err = backend.Decrypt(data, key)
+	c.Check(err, check.ErrorMatches, "data is not encrypted")
+}
+
+func (s *snapshotSuite) TestEncryptedRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	logger.SimpleSetup(nil)
+
+	key, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+
+	// encryption takes precedence over deduplication
+	shw, err := backend.Save(context.TODO(), 12, info, nil, []string{"snapuser"}, nil, nil, &backend.SaveFlags{Deduplicate: true, Key: key})
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Encrypted, check.Equals, true)
+	c.Check(hashkeys(shw), check.DeepEquals, []string{"archive.tgz", "user/snapuser.tgz"})
+	c.Check(zipMembers(c, backend.Filename(shw)), check.DeepEquals, []string{"archive.tgz", "meta.json", "meta.sha3_384", "user/snapuser.tgz"})
+	c.Check(chunkFiles(c), check.HasLen, 0)
+
+	shr, err := backend.Open(backend.Filename(shw), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer shr.Close()
+	c.Check(shr.Encrypted, check.Equals, true)
+
+	// the data can be checked without the key
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+
+	newroot := c.MkDir()
+	c.Assert(os.MkdirAll(filepath.Join(newroot, "home/snapuser"), 0755), check.IsNil)
+	dirs.SetRootDir(newroot)
+
+	// but not restored
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Check(err, check.ErrorMatches, `cannot restore encrypted snapshot ".*" without its key`)
+
+	otherKey, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+	shr.SetKey(otherKey)
+	c.Check(shr.Check(context.TODO(), nil), check.ErrorMatches, `cannot decrypt snapshot entry ".*": wrong key or corrupted data`)
+	_, err = shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Check(err, check.ErrorMatches, `cannot decrypt snapshot ".*" entry ".*": wrong key or corrupted data`)
+
+	shr.SetKey(key)
+	c.Check(shr.Check(context.TODO(), nil), check.IsNil)
+	rs, err := shr.Restore(context.TODO(), snap.R(0), nil, logger.Debugf, nil)
+	c.Assert(err, check.IsNil)
+	rs.Cleanup()
+	c.Check(exec.Command("diff", "-urN", "-x*.zip", s.root, newroot).Run(), check.IsNil)
+}
+
+func (s *snapshotSuite) TestEncryptedImportExportRoundtrip(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockExportKDFLogN(10)()
+	ctx := context.TODO()
+
+	key, err := backend.NewSnapshotKey()
+	c.Assert(err, check.IsNil)
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, nil, nil, &backend.SaveFlags{Key: key})
+	c.Assert(err, check.IsNil)
+
+	// encrypted snapshots need their key and a passphrase to be exported
+	_, err = backend.NewSnapshotExport(ctx, shw.SetID, nil)
+	c.Check(err, check.ErrorMatches, "cannot export encrypted snapshot 12 without its key")
+	_, err = backend.NewSnapshotExport(ctx, shw.SetID, &backend.ExportFlags{Key: key})
+	c.Check(err, check.ErrorMatches, "cannot export encrypted snapshot 12 without a passphrase")
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, &backend.ExportFlags{Key: key, Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	c.Assert(export.Init(), check.IsNil)
+	var buf bytes.Buffer
+	c.Assert(export.StreamTo(&buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+	exported := buf.Bytes()
+
+	// the export is not a plain tar, and does not carry the key in the clear
+	_, err = tar.NewReader(bytes.NewReader(exported)).Next()
+	c.Check(err, check.NotNil)
+	c.Check(bytes.Contains(exported, key), check.Equals, false)
+
+	c.Assert(os.RemoveAll(dirs.SnapshotsDir), check.IsNil)
+
+	_, _, err = backend.Import(ctx, 123, bytes.NewReader(exported), nil)
+	c.Check(err, check.Equals, backend.ErrPassphraseRequired)
+
+	_, _, err = backend.Import(ctx, 123, bytes.NewReader(exported), &backend.ImportFlags{Passphrase: "wrong"})
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 123: cannot decrypt snapshot export: wrong passphrase or corrupted data")
+
+	_, _, err = backend.Import(ctx, 123, bytes.NewReader(exported), &backend.ImportFlags{Passphrase: "sekrit"})
+	c.Check(err, check.ErrorMatches, "cannot import snapshot 123: encrypted snapshot sets can only be imported on systems with encrypted data")
+
+	snapNames, importedKey, err := backend.Import(ctx, 123, bytes.NewReader(exported), &backend.ImportFlags{Passphrase: "sekrit", AllowEncrypted: true})
+	c.Assert(err, check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"hello-snap"})
+	c.Check(importedKey, check.DeepEquals, key)
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.Encrypted, check.Equals, true)
+	rdr.SetKey(importedKey)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
+
+func (s *snapshotSuite) TestPassphraseExportOfPlainSnapshot(c *check.C) {
+	if os.Geteuid() == 0 {
+		c.Skip("this test cannot run as root (runuser will fail)")
+	}
+	defer backend.MockExportKDFLogN(10)()
+	ctx := context.TODO()
+
+	info := &snap.Info{SideInfo: snap.SideInfo{RealName: "hello-snap", Revision: snap.R(42), SnapID: "hello-id"}, Version: "v1.33"}
+	shw, err := backend.Save(ctx, 12, info, nil, []string{"snapuser"}, nil, nil, nil)
+	c.Assert(err, check.IsNil)
+	c.Check(shw.Encrypted, check.Equals, false)
+
+	export, err := backend.NewSnapshotExport(ctx, shw.SetID, &backend.ExportFlags{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	c.Assert(export.Init(), check.IsNil)
+	var buf bytes.Buffer
+	c.Assert(export.StreamTo(&buf), check.IsNil)
+	c.Check(buf.Len(), check.Equals, int(export.Size()))
+
+	c.Assert(os.RemoveAll(dirs.SnapshotsDir), check.IsNil)
+
+	// no key is carried, so any system can import it
+	snapNames, key, err := backend.Import(ctx, 123, &buf, &backend.ImportFlags{Passphrase: "sekrit"})
+	c.Assert(err, check.IsNil)
+	c.Check(snapNames, check.DeepEquals, []string{"hello-snap"})
+	c.Check(key, check.IsNil)
+
+	rdr, err := backend.Open(filepath.Join(dirs.SnapshotsDir, "123_hello-snap_v1.33_42.zip"), backend.ExtractFnameSetID)
+	c.Assert(err, check.IsNil)
+	defer rdr.Close()
+	c.Check(rdr.Encrypted, check.Equals, false)
+	c.Check(rdr.Check(ctx, nil), check.IsNil)
+}
@@ -20,7 +20,9 @@
 package backend
 
 import (
+	"bytes"
 	"context"
+	"io"
 	"os"
 	"os/exec"
 	"time"
@@ -156,3 +158,68 @@ func MockReadSnapshotYaml(f func(si *snap.Info) (*snap.SnapshotOptions, error))
 		snapReadSnapshotYaml = oldReadSnapshotYaml
 	}
 }
+
+func MockChunkSizes(minSize, maxSize int, mask uint64) (restore func()) {
+	oldMin, oldMax, oldMask := chunkMinSize, chunkMaxSize, chunkMask
+	chunkMinSize, chunkMaxSize, chunkMask = minSize, maxSize, mask
+	return func() {
+		chunkMinSize, chunkMaxSize, chunkMask = oldMin, oldMax, oldMask
+	}
+}
+
+// ChunkData splits data into chunks as a deduplicated save would, storing
+// them in the chunk store, and returns the hashes of the chunks.
+func ChunkData(data []byte) ([]string, error) {
+	cw := newChunkWriter()
+	if _, err := cw.Write(data); err != nil {
+		return nil, err
+	}
+	refs, err := cw.Close()
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, len(refs))
+	for i, ref := range refs {
+		hashes[i] = ref.Hash
+	}
+	return hashes, nil
+}
+
+func ChunkPath(hash string) string {
+	return chunkPath(hash)
+}
+
+func MockExportKDFLogN(logN int) (restore func()) {
+	old := exportKDFLogN
+	exportKDFLogN = logN
+	return func() {
+		exportKDFLogN = old
+	}
+}
+
+// Encrypt encrypts data as encrypted snapshot data is written.
+func Encrypt(data, key []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	ew, err := newEncryptWriter(&buf, key)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := ew.Write(data); err != nil {
+		return nil, err
+	}
+	if err := ew.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Decrypt decrypts data encrypted with Encrypt.
+func Decrypt(data, key []byte) ([]byte, error) {
+	dr, err := newDecryptReader(bytes.NewReader(data), key)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(dr)
+}
+
+const CryptSegmentSize = cryptSegmentSize
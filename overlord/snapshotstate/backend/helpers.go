@@ -62,7 +62,13 @@ func zipMember(f *os.File, member string) (r io.ReadCloser, sz int64, err error)
 		}
 	}
 
-	return nil, -1, fmt.Errorf("missing archive member %q", member)
+	return nil, -1, missingMemberError(member)
+}
+
+type missingMemberError string
+
+func (e missingMemberError) Error() string {
+	return fmt.Sprintf("missing archive member %q", string(e))
 }
 
 func userArchiveName(usr *user.User) string {
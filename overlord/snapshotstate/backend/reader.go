@@ -50,6 +50,13 @@ const ExtractFnameSetID = 0
 type Reader struct {
 	*os.File
 	client.Snapshot
+
+	// chunks is the index of the chunks making up the entries of a
+	// deduplicated snapshot, nil otherwise
+	chunks *chunksIndex
+
+	// key is the key of an encrypted snapshot, if known
+	key []byte
 }
 
 // Open a Snapshot given its full filename.
@@ -139,21 +146,66 @@ func Open(fn string, setID uint64) (reader *Reader, e error) {
 		return reader, errors.New(reader.Broken)
 	}
 
+	reader.chunks, err = readChunksIndex(f)
+	if err != nil {
+		reader.Broken = err.Error()
+		return reader, err
+	}
+
 	return reader, nil
 }
 
+// IsDeduplicated returns whether the snapshot data is kept in the chunk store.
+func (r *Reader) IsDeduplicated() bool {
+	return r.chunks != nil
+}
+
+// SetKey sets the key for reading the data of an encrypted snapshot.
+func (r *Reader) SetKey(key []byte) {
+	r.key = key
+}
+
+// entryReader returns a reader for the content of the given snapshot entry,
+// its size, and whether the content is gzip compressed.
+func (r *Reader) entryReader(entry string) (rc io.ReadCloser, sz int64, compressed bool, err error) {
+	if r.chunks == nil {
+		rc, sz, err = zipMember(r.File, entry)
+		return rc, sz, true, err
+	}
+	refs, ok := r.chunks.Entries[entry]
+	if !ok {
+		return nil, -1, false, missingMemberError(entry)
+	}
+	for _, ref := range refs {
+		sz += ref.Size
+	}
+	return &chunkReader{dir: r.chunks.dir, refs: refs}, sz, false, nil
+}
+
 func (r *Reader) checkOne(ctx context.Context, entry string, hasher hash.Hash) error {
-	body, reportedSize, err := zipMember(r.File, entry)
+	body, reportedSize, _, err := r.entryReader(entry)
 	if err != nil {
 		return err
 	}
 	defer body.Close()
 
 	expectedHash := r.SHA3_384[entry]
-	readSize, err := io.Copy(io.MultiWriter(osutil.ContextWriter(ctx), hasher), body)
-	if err != nil {
+	var sz osutil.Sizer
+	var data io.Reader = io.TeeReader(body, io.MultiWriter(osutil.ContextWriter(ctx), hasher, &sz))
+	if r.Encrypted && r.key != nil {
+		// with the key at hand the data can be authenticated too
+		data, err = newDecryptReader(data, r.key)
+		if err != nil {
+			return fmt.Errorf("cannot decrypt snapshot entry %q: %v", entry, err)
+		}
+	}
+	if _, err := io.Copy(io.Discard, data); err != nil {
+		if err == errAuthentication {
+			return fmt.Errorf("cannot decrypt snapshot entry %q: %v", entry, err)
+		}
 		return err
 	}
+	readSize := sz.Size()
 
 	if readSize != reportedSize {
 		return fmt.Errorf("snapshot entry %q size (%d) different from actual (%d)", entry, reportedSize, readSize)
@@ -206,6 +258,10 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 		}
 	}()
 
+	if r.Encrypted && r.key == nil {
+		return rs, fmt.Errorf("cannot restore encrypted snapshot %q without its key", r.Name())
+	}
+
 	sort.Strings(usernames)
 	isRoot := sys.Geteuid() == 0
 	si := snap.MinimalPlaceInfo(r.Snap, r.Revision)
@@ -310,22 +366,31 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		logger.Debugf("Restoring %q from %q into %q.", entry, r.Name(), tempdir)
 
-		body, expectedSize, err := zipMember(r.File, entry)
+		body, expectedSize, compressed, err := r.entryReader(entry)
 		if err != nil {
 			return rs, err
 		}
+		defer body.Close()
 
 		expectedHash := r.SHA3_384[entry]
 
-		tr := io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		var tr io.Reader = io.TeeReader(body, io.MultiWriter(hasher, &sz))
+		if r.Encrypted {
+			tr, err = newDecryptReader(tr, r.key)
+			if err != nil {
+				return rs, fmt.Errorf("cannot decrypt snapshot %q entry %q: %v", r.Name(), entry, err)
+			}
+		}
 
 		// resist the temptation of using archive/tar unless it's proven
 		// that calling out to tar has issues -- there are a lot of
 		// special cases we'd need to consider otherwise
-		cmd := tarAsUser(ctx, username,
-			"--extract",
-			"--preserve-permissions", "--preserve-order", "--gunzip",
-			"--directory", tempdir)
+		tarArgs := []string{"--extract", "--preserve-permissions", "--preserve-order"}
+		if compressed {
+			tarArgs = append(tarArgs, "--gunzip")
+		}
+		tarArgs = append(tarArgs, "--directory", tempdir)
+		cmd := tarAsUser(ctx, username, tarArgs...)
 		cmd.Env = []string{}
 		cmd.Stdin = tr
 		matchCounter := &strutil.MatchCounter{N: 1}
@@ -338,6 +403,13 @@ func (r *Reader) Restore(ctx context.Context, current snap.Revision, usernames [
 
 		// cmd is cancellable if ctx is a cancellable context
 		if err = cmd.Run(); err != nil {
+			if cr, ok := body.(*chunkReader); ok && cr.err != nil {
+				// tar only saw its input end early
+				return rs, fmt.Errorf("cannot read snapshot %q entry %q: %v", r.Name(), entry, cr.err)
+			}
+			if dr, ok := tr.(*decryptReader); ok && dr.err != nil {
+				return rs, fmt.Errorf("cannot decrypt snapshot %q entry %q: %v", r.Name(), entry, dr.err)
+			}
 			matches, count := matchCounter.Matches()
 			if count > 0 {
 				return rs, fmt.Errorf("cannot unpack archive: %s (and %d more)", matches[0], count-1)
@@ -28,6 +28,7 @@ import (
 	"github.com/snapcore/snapd/client"
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
 	"github.com/snapcore/snapd/snap"
@@ -48,12 +49,46 @@ var (
 	SaveExpiration             = saveExpiration
 	ExpiredSnapshotSets        = expiredSnapshotSets
 	RemoveSnapshotState        = removeSnapshotState
+	SnapshotKey                = snapshotKey
+	SaveSnapshotKey            = saveSnapshotKey
 
 	SetSnapshotOpInProgress = setSnapshotOpInProgress
 
 	DefaultAutomaticSnapshotExpiration = defaultAutomaticSnapshotExpiration
+
+	ScheduledRetentionPolicy = scheduledRetentionPolicy
+	ForgetSnapshotSets       = forgetSnapshotSets
+	LastScheduledSnapshot    = lastScheduledSnapshot
+
+	DoPush          = doPush
+	DoPull          = doPull
+	CleanupTransfer = cleanupTransfer
 )
 
+type (
+	RetentionPolicy     = retentionPolicy
+	ScheduledSet        = scheduledSet
+	TransferSecretsData = transferSecrets
+)
+
+// TransferSecrets returns the secrets of the transfer task kept in memory.
+func TransferSecrets(task *state.Task) any {
+	st := task.State()
+	return st.Cached(transferSecretsKey{task.ID()})
+}
+
+func (p *RetentionPolicy) SetsToForget(sets []ScheduledSet) []uint64 {
+	return p.setsToForget(sets)
+}
+
+func MockTimeNow(f func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = f
+	return func() {
+		timeNow = old
+	}
+}
+
 func (summaries snapshotSnapSummaries) AsMaps() []map[string]string {
 	out := make([]map[string]string, len(summaries))
 	for i, summary := range summaries {
@@ -155,7 +190,7 @@ func MockBackendCleanup(f func(*backend.RestoreState)) (restore func()) {
 	}
 }
 
-func MockBackendImport(f func(context.Context, uint64, io.Reader, *backend.ImportFlags) ([]string, error)) (restore func()) {
+func MockBackendImport(f func(context.Context, uint64, io.Reader, *backend.ImportFlags) ([]string, []byte, error)) (restore func()) {
 	old := backendImport
 	backendImport = f
 	return func() {
@@ -171,7 +206,15 @@ func MockBackendCleanupAbandonedImports(f func() (int, error)) (restore func())
 	}
 }
 
-func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error)) (restore func()) {
+func MockBackendCleanupUnreferencedChunks(f func(context.Context) (int, error)) (restore func()) {
+	old := backendCleanupUnreferencedChunks
+	backendCleanupUnreferencedChunks = f
+	return func() {
+		backendCleanupUnreferencedChunks = old
+	}
+}
+
+func MockBackendEstimateSnapshotSize(f func(context.Context, *snap.Info, []string, *dirs.SnapDirOptions, *backend.SaveFlags) (uint64, error)) (restore func()) {
 	old := backendEstimateSnapshotSize
 	backendEstimateSnapshotSize = f
 	return func() {
@@ -179,7 +222,7 @@ func MockBackendEstimateSnapshotSize(f func(*snap.Info, []string, *dirs.SnapDirO
 	}
 }
 
-func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64) (se *SnapshotExport, err error)) (restore func()) {
+func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64, flags *backend.ExportFlags) (se *SnapshotExport, err error)) (restore func()) {
 	old := backendNewSnapshotExport
 	backendNewSnapshotExport = f
 	return func() {
@@ -187,6 +230,22 @@ func MockBackendNewSnapshotExport(f func(ctx context.Context, setID uint64) (se
 	}
 }
 
+func MockBackendNewSnapshotKey(f func() ([]byte, error)) (restore func()) {
+	old := backendNewSnapshotKey
+	backendNewSnapshotKey = f
+	return func() {
+		backendNewSnapshotKey = old
+	}
+}
+
+func MockFdestateDeriveKey(f func(*state.State, string) ([]byte, error)) (restore func()) {
+	old := fdestateDeriveKey
+	fdestateDeriveKey = f
+	return func() {
+		fdestateDeriveKey = old
+	}
+}
+
 func MockConfigGetSnapConfig(f func(*state.State, string) (*json.RawMessage, error)) (restore func()) {
 	old := configGetSnapConfig
 	configGetSnapConfig = f
@@ -215,3 +274,31 @@ func MockGetSnapDirOptions(f func(*state.State, string) (*dirs.SnapDirOptions, e
 		getSnapDirOpts = old
 	}
 }
+
+func MockTargetNew(f func(*target.Spec) (target.Target, error)) (restore func()) {
+	old := targetNew
+	targetNew = f
+	return func() {
+		targetNew = old
+	}
+}
+
+func MockBackendSnapshotExportStream(f func(*backend.SnapshotExport, io.Writer) error) (restore func()) {
+	old := backendSnapshotExportStream
+	backendSnapshotExportStream = f
+	return func() {
+		backendSnapshotExportStream = old
+	}
+}
+
+func MockTransferRetryDelay(d time.Duration) (restore func()) {
+	old := transferRetryDelay
+	transferRetryDelay = d
+	return func() {
+		transferRetryDelay = old
+	}
+}
+
+func SetLastChunksCleanupTime(mgr *SnapshotManager, t time.Time) {
+	mgr.lastChunksCleanupTime = t
+}
@@ -0,0 +1,378 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
+	"github.com/snapcore/snapd/strutil"
+)
+
+var scheduledSnapshotChangeKind = swfeats.RegisterChangeKind("scheduled-snapshot")
+
+const (
+	// scheduledSnapshotMaxPostponement is the longest time until the
+	// next scheduled snapshot, whatever the schedule says
+	scheduledSnapshotMaxPostponement = 31 * 24 * time.Hour
+	// scheduledSnapshotRetryDelay is how long to wait before trying again
+	// when a scheduled snapshot could not be started
+	scheduledSnapshotRetryDelay = 10 * time.Minute
+
+	// default retention of scheduled snapshots, if neither
+	// snapshots.scheduled.keep-daily nor keep-weekly are set
+	defaultScheduledKeepDaily  = 7
+	defaultScheduledKeepWeekly = 4
+)
+
+var (
+	timeNow = time.Now
+
+	retentionInterval = time.Hour // interval between enforceRetention runs as part of Ensure()
+)
+
+// retentionPolicy decides which scheduled snapshot sets are kept.
+type retentionPolicy struct {
+	// KeepDaily is the number of days for which the newest set is kept.
+	KeepDaily int
+	// KeepWeekly is the number of weeks for which the newest set is
+	// kept.
+	KeepWeekly int
+	// MaxSize is the maximum total size of the kept sets, or 0 for no
+	// limit.
+	MaxSize int64
+}
+
+// scheduledSet is a summary of a scheduled snapshot set.
+type scheduledSet struct {
+	ID   uint64
+	Time time.Time
+	Size int64
+}
+
+// setsToForget returns the IDs of the sets not kept by the policy. The
+// newest set is always kept. Days and weeks are counted as those in which
+// there is at least one set, so that no sets are forgotten while no new
+// ones are taken.
+func (p *retentionPolicy) setsToForget(sets []scheduledSet) []uint64 {
+	if len(sets) == 0 {
+		return nil
+	}
+	sets = append([]scheduledSet(nil), sets...)
+	sort.Slice(sets, func(i, j int) bool {
+		if sets[i].Time.Equal(sets[j].Time) {
+			return sets[i].ID > sets[j].ID
+		}
+		return sets[i].Time.After(sets[j].Time)
+	})
+
+	keep := map[uint64]bool{sets[0].ID: true}
+	days := make(map[string]bool)
+	weeks := make(map[string]bool)
+	for _, set := range sets {
+		t := set.Time.Local()
+		day := t.Format("2006-01-02")
+		if !days[day] && len(days) < p.KeepDaily {
+			days[day] = true
+			keep[set.ID] = true
+		}
+		year, week := t.ISOWeek()
+		weekKey := fmt.Sprintf("%d-%d", year, week)
+		if !weeks[weekKey] && len(weeks) < p.KeepWeekly {
+			weeks[weekKey] = true
+			keep[set.ID] = true
+		}
+	}
+
+	var total int64
+	var forget []uint64
+	for i, set := range sets {
+		if keep[set.ID] && (i == 0 || p.MaxSize == 0 || total+set.Size <= p.MaxSize) {
+			total += set.Size
+			continue
+		}
+		forget = append(forget, set.ID)
+	}
+	sort.Slice(forget, func(i, j int) bool { return forget[i] < forget[j] })
+	return forget
+}
+
+// coreOption returns the given core option as a string, or "" if unset.
+func coreOption(tr *config.Transaction, option string) (string, error) {
+	var val any
+	if err := tr.Get("core", option, &val); err != nil {
+		if config.IsNoOption(err) {
+			return "", nil
+		}
+		return "", err
+	}
+	switch v := val.(type) {
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		return fmt.Sprint(v), nil
+	}
+}
+
+// scheduledSnapshotSnaps returns the snaps configured for scheduled
+// snapshots, or nil for all snaps. The state needs to be locked by the
+// caller.
+func scheduledSnapshotSnaps(st *state.State) ([]string, error) {
+	snaps, err := coreOption(config.NewTransaction(st), "snapshots.scheduled.snaps")
+	if err != nil {
+		return nil, err
+	}
+	return strutil.CommaSeparatedList(snaps), nil
+}
+
+// scheduledRetentionPolicy returns the retention policy for scheduled
+// snapshots as configured. The state needs to be locked by the caller.
+func scheduledRetentionPolicy(st *state.State) (*retentionPolicy, error) {
+	tr := config.NewTransaction(st)
+	policy := &retentionPolicy{}
+	var keepSet bool
+	for _, opt := range []struct {
+		name string
+		n    *int
+	}{
+		{"snapshots.scheduled.keep-daily", &policy.KeepDaily},
+		{"snapshots.scheduled.keep-weekly", &policy.KeepWeekly},
+	} {
+		val, err := coreOption(tr, opt.name)
+		if err != nil {
+			return nil, err
+		}
+		if val == "" {
+			continue
+		}
+		n, err := strconv.ParseUint(val, 10, 16)
+		if err != nil {
+			return nil, fmt.Errorf("%s cannot be parsed: %v", opt.name, err)
+		}
+		*opt.n = int(n)
+		keepSet = true
+	}
+	if !keepSet {
+		policy.KeepDaily = defaultScheduledKeepDaily
+		policy.KeepWeekly = defaultScheduledKeepWeekly
+	}
+
+	maxSize, err := coreOption(tr, "snapshots.scheduled.max-size")
+	if err != nil {
+		return nil, err
+	}
+	if maxSize != "" {
+		policy.MaxSize, err = strutil.ParseByteSize(maxSize)
+		if err != nil {
+			return nil, fmt.Errorf("snapshots.scheduled.max-size cannot be parsed: %v", err)
+		}
+	}
+	return policy, nil
+}
+
+// markScheduled records in the state that the given snapshot set was
+// taken according to the snapshot schedule. The state needs to be locked
+// by the caller.
+func markScheduled(st *state.State, setID uint64) error {
+	var snapshots map[uint64]*snapshotState
+	err := st.Get("snapshots", &snapshots)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if snapshots == nil {
+		snapshots = make(map[uint64]*snapshotState)
+	}
+	if snapshots[setID] == nil {
+		snapshots[setID] = &snapshotState{}
+	}
+	snapshots[setID].Scheduled = true
+	st.Set("snapshots", snapshots)
+	return nil
+}
+
+// scheduledSnapshotSets returns the IDs of the snapshot sets taken
+// according to the snapshot schedule. The state needs to be locked by the
+// caller.
+func scheduledSnapshotSets(st *state.State) (map[uint64]bool, error) {
+	var snapshots map[uint64]*snapshotState
+	if err := st.Get("snapshots", &snapshots); err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	sets := make(map[uint64]bool)
+	for setID, snapshotSet := range snapshots {
+		if snapshotSet.Scheduled {
+			sets[setID] = true
+		}
+	}
+	return sets, nil
+}
+
+func scheduledSnapshotInFlight(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.Kind() == scheduledSnapshotChangeKind && !chg.IsReady() {
+			return true
+		}
+	}
+	return false
+}
+
+// lastScheduledSnapshot returns the time the last scheduled snapshot was
+// started, if any. The state needs to be locked by the caller.
+func lastScheduledSnapshot(st *state.State) (time.Time, error) {
+	var last time.Time
+	err := st.Get("last-scheduled-snapshot", &last)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return time.Time{}, err
+	}
+	return last, nil
+}
+
+func launchScheduledSnapshot(st *state.State) error {
+	snaps, err := scheduledSnapshotSnaps(st)
+	if err != nil {
+		return err
+	}
+	if len(snaps) > 0 {
+		// snaps that are not installed (anymore) are skipped
+		active, err := allActiveSnapNames(st)
+		if err != nil {
+			return err
+		}
+		snaps = strutil.Intersection(snaps, active)
+		if len(snaps) == 0 {
+			logger.Noticef("None of the snaps configured for scheduled snapshots are installed.")
+			return nil
+		}
+		sort.Strings(snaps)
+	}
+
+	setID, saved, ts, err := Save(st, snaps, nil, nil, nil)
+	if err != nil {
+		return err
+	}
+	if len(saved) == 0 {
+		return nil
+	}
+	if err := markScheduled(st, setID); err != nil {
+		return err
+	}
+
+	chg := st.NewChange(scheduledSnapshotChangeKind, fmt.Sprintf("Scheduled snapshot of snaps %s", strutil.Quoted(saved)))
+	chg.AddAll(ts)
+	chg.Set("api-data", map[string]any{"snap-names": saved, "set-id": setID})
+	st.EnsureBefore(0)
+	return nil
+}
+
+// enforceRetention forgets the scheduled snapshot sets that are not kept by
+// the retention policy.
+func (mgr *SnapshotManager) enforceRetention() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
+
+	scheduled, err := scheduledSnapshotSets(st)
+	if err != nil {
+		return err
+	}
+	if len(scheduled) == 0 {
+		mgr.lastRetentionTime = timeNow()
+		return nil
+	}
+	policy, err := scheduledRetentionPolicy(st)
+	if err != nil {
+		return err
+	}
+
+	summaries := make(map[uint64]*scheduledSet)
+	err = backendIter(context.TODO(), func(r *backend.Reader) error {
+		if !scheduled[r.SetID] {
+			return nil
+		}
+		// a set still being saved is neither counted nor forgotten
+		if err := checkSnapshotConflict(st, r.SetID, "save-snapshot"); err != nil {
+			return nil
+		}
+		set := summaries[r.SetID]
+		if set == nil {
+			set = &scheduledSet{ID: r.SetID}
+			summaries[r.SetID] = set
+		}
+		if r.Time.After(set.Time) {
+			set.Time = r.Time
+		}
+		set.Size += r.Size
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("cannot list scheduled snapshots: %v", err)
+	}
+
+	sets := make([]scheduledSet, 0, len(summaries))
+	for _, set := range summaries {
+		sets = append(sets, *set)
+	}
+	forget := make(map[uint64]bool)
+	for _, setID := range policy.setsToForget(sets) {
+		forget[setID] = true
+	}
+	// sets that could not be forgotten, e.g. because they are being
+	// exported or restored
+	var notForgotten int
+	if len(forget) > 0 {
+		logger.Noticef("Forgetting scheduled snapshot sets %v as per the retention policy.", sortedSetIDs(forget))
+		if err := forgetSnapshotSets(st, forget); err != nil {
+			return err
+		}
+		// forgetSnapshotSets leaves the sets it skipped in the map
+		notForgotten = len(forget)
+		requestChunksCleanup(st)
+	}
+
+	// try again sooner if some sets could not be forgotten yet
+	if notForgotten == 0 {
+		mgr.lastRetentionTime = timeNow()
+	}
+	return nil
+}
+
+func sortedSetIDs(sets map[uint64]bool) []uint64 {
+	ids := make([]uint64, 0, len(sets))
+	for id := range sets {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
@@ -0,0 +1,320 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (snapshotSuite) TestRetentionPolicySetsToForget(c *check.C) {
+	day := func(d, h int) time.Time {
+		// 2026-01-05 is a Monday
+		return time.Date(2026, 1, 5+d, h, 0, 0, 0, time.Local)
+	}
+	// twice a day over four weeks, set IDs increasing with time
+	var sets []snapshotstate.ScheduledSet
+	for d := 0; d < 28; d++ {
+		for _, h := range []int{3, 15} {
+			sets = append(sets, snapshotstate.ScheduledSet{ID: uint64(len(sets) + 1), Time: day(d, h), Size: 10})
+		}
+	}
+
+	for _, tc := range []struct {
+		policy snapshotstate.RetentionPolicy
+		kept   []uint64
+	}{
+		// the newest set is always kept
+		{snapshotstate.RetentionPolicy{}, []uint64{56}},
+		// newest of each of the last 3 days
+		{snapshotstate.RetentionPolicy{KeepDaily: 3}, []uint64{52, 54, 56}},
+		// newest of each of the last 2 weeks
+		{snapshotstate.RetentionPolicy{KeepWeekly: 2}, []uint64{42, 56}},
+		{snapshotstate.RetentionPolicy{KeepDaily: 2, KeepWeekly: 3}, []uint64{28, 42, 54, 56}},
+		// more than there is
+		{snapshotstate.RetentionPolicy{KeepDaily: 100, KeepWeekly: 100}, func() (ids []uint64) {
+			for i := uint64(2); i <= 56; i += 2 {
+				ids = append(ids, i)
+			}
+			return ids
+		}()},
+		// the size limit drops the oldest of the kept sets
+		{snapshotstate.RetentionPolicy{KeepDaily: 3, KeepWeekly: 3, MaxSize: 35}, []uint64{52, 54, 56}},
+		// but never the newest
+		{snapshotstate.RetentionPolicy{KeepDaily: 3, MaxSize: 5}, []uint64{56}},
+	} {
+		comment := check.Commentf("%+v", tc.policy)
+		forget := tc.policy.SetsToForget(sets)
+		kept := make(map[uint64]bool)
+		for _, set := range sets {
+			kept[set.ID] = true
+		}
+		for _, id := range forget {
+			c.Check(kept[id], check.Equals, true, comment)
+			delete(kept, id)
+		}
+		var keptIDs []uint64
+		for id := uint64(1); id <= uint64(len(sets)); id++ {
+			if kept[id] {
+				keptIDs = append(keptIDs, id)
+			}
+		}
+		c.Check(keptIDs, check.DeepEquals, tc.kept, comment)
+	}
+
+	c.Check((&snapshotstate.RetentionPolicy{KeepDaily: 1}).SetsToForget(nil), check.HasLen, 0)
+}
+
+func (snapshotSuite) TestScheduledRetentionPolicy(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	policy, err := snapshotstate.ScheduledRetentionPolicy(st)
+	c.Assert(err, check.IsNil)
+	c.Check(policy, check.DeepEquals, &snapshotstate.RetentionPolicy{KeepDaily: 7, KeepWeekly: 4})
+
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.scheduled.keep-weekly", 2)
+	tr.Set("core", "snapshots.scheduled.max-size", "1kB")
+	tr.Commit()
+
+	policy, err = snapshotstate.ScheduledRetentionPolicy(st)
+	c.Assert(err, check.IsNil)
+	c.Check(policy, check.DeepEquals, &snapshotstate.RetentionPolicy{KeepWeekly: 2, MaxSize: 1000})
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshot(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{
+			"a-snap": {Active: true},
+			"b-snap": {Active: true},
+		}, nil
+	})()
+
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	// nothing happens without a schedule
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	last, err := snapshotstate.LastScheduledSnapshot(st)
+	c.Assert(err, check.IsNil)
+	c.Check(last.IsZero(), check.Equals, true)
+
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.schedule", "0:00-24:00")
+	tr.Commit()
+	st.Unlock()
+
+	// the first run only anchors the schedule
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 0)
+	last, err = snapshotstate.LastScheduledSnapshot(st)
+	c.Assert(err, check.IsNil)
+	c.Check(last.IsZero(), check.Equals, false)
+
+	// pretend the last scheduled snapshot was a while ago
+	st.Set("last-scheduled-snapshot", time.Now().Add(-48*time.Hour))
+	// and the schedule changed, so the next time is computed again
+	tr = config.NewTransaction(st)
+	tr.Set("core", "snapshots.schedule", "0:00-23:59")
+	tr.Commit()
+	st.Unlock()
+
+	before := time.Now()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	defer st.Unlock()
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	chg := chgs[0]
+	c.Check(chg.Kind(), check.Equals, "scheduled-snapshot")
+	c.Check(chg.Summary(), check.Equals, `Scheduled snapshot of snaps "a-snap", "b-snap"`)
+	tasks := chg.Tasks()
+	c.Assert(tasks, check.HasLen, 2)
+	for _, t := range tasks {
+		c.Check(t.Kind(), check.Equals, "save-snapshot")
+	}
+	var apiData map[string]any
+	c.Assert(chg.Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]any{"snap-names": []any{"a-snap", "b-snap"}, "set-id": 1.})
+
+	var snapshots map[uint64]any
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots, check.DeepEquals, map[uint64]any{
+		1: map[string]any{"expiry-time": "0001-01-01T00:00:00Z", "scheduled": true},
+	})
+	last, err = snapshotstate.LastScheduledSnapshot(st)
+	c.Assert(err, check.IsNil)
+	c.Check(last.Before(before), check.Equals, false)
+
+	// no new snapshot while one is in flight
+	st.Set("last-scheduled-snapshot", time.Now().Add(-48*time.Hour))
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	c.Check(st.Changes(), check.HasLen, 1)
+
+	// scheduled sets do not expire
+	expired, err := snapshotstate.ExpiredSnapshotSets(st, time.Now())
+	c.Assert(err, check.IsNil)
+	c.Check(expired, check.HasLen, 0)
+}
+
+func (snapshotSuite) TestEnsureScheduledSnapshotSelectedSnaps(c *check.C) {
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	for _, name := range []string{"a-snap", "b-snap"} {
+		snapstate.Set(st, name, &snapstate.SnapState{
+			Active: true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+				{RealName: name, Revision: snap.R(1)},
+			}),
+			Current: snap.R(1),
+		})
+	}
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.schedule", "0:00-24:00")
+	tr.Set("core", "snapshots.scheduled.snaps", "b-snap,not-installed")
+	tr.Commit()
+	st.Set("last-scheduled-snapshot", time.Now().Add(-48*time.Hour))
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	st.Lock()
+	defer st.Unlock()
+	chgs := st.Changes()
+	c.Assert(chgs, check.HasLen, 1)
+	c.Check(chgs[0].Summary(), check.Equals, `Scheduled snapshot of snaps "b-snap"`)
+	c.Assert(chgs[0].Tasks(), check.HasLen, 1)
+	c.Check(chgs[0].Tasks()[0].Summary(), check.Equals, `Save data of snap "b-snap" in snapshot set #1`)
+}
+
+func (snapshotSuite) TestEnsureEnforcesRetention(c *check.C) {
+	now := time.Now()
+	var readers []*backend.Reader
+	for i := 1; i <= 4; i++ {
+		for _, name := range []string{"a-snap", "b-snap"} {
+			f, err := os.Create(filepath.Join(c.MkDir(), fmt.Sprintf("%d_%s.zip", i, name)))
+			c.Assert(err, check.IsNil)
+			defer f.Close()
+			readers = append(readers, &backend.Reader{
+				Snapshot: client.Snapshot{
+					SetID: uint64(i),
+					Snap:  name,
+					Time:  now.Add(-time.Duration(5-i) * 24 * time.Hour),
+					Size:  100,
+				},
+				File: f,
+			})
+		}
+	}
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		for _, r := range readers {
+			if err := f(r); err != nil {
+				return err
+			}
+		}
+		return nil
+	})()
+	var removed []string
+	defer snapshotstate.MockOsRemove(func(fileName string) error {
+		removed = append(removed, filepath.Base(fileName))
+		return nil
+	})()
+	defer snapshotstate.MockBackendCleanupUnreferencedChunks(func(context.Context) (int, error) {
+		return 0, nil
+	})()
+
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	// set 4 is not a scheduled one
+	st.Set("snapshots", map[uint64]any{
+		1: map[string]any{"scheduled": true},
+		2: map[string]any{"scheduled": true},
+		3: map[string]any{"scheduled": true},
+	})
+	tr := config.NewTransaction(st)
+	tr.Set("core", "snapshots.scheduled.keep-daily", 2)
+	tr.Commit()
+	// set 1 is being exported, so it cannot be forgotten yet
+	snapshotstate.SetSnapshotOpInProgress(st, 1, "export-snapshot")
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(removed, check.HasLen, 0)
+
+	st.Lock()
+	snapshotstate.UnsetSnapshotOpInProgress(st, 1)
+	tr = config.NewTransaction(st)
+	tr.Set("core", "snapshots.scheduled.keep-daily", 1)
+	tr.Commit()
+	st.Unlock()
+
+	// retention runs again, as not all sets could be forgotten
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(removed, check.DeepEquals, []string{"1_a-snap.zip", "1_b-snap.zip", "2_a-snap.zip", "2_b-snap.zip"})
+
+	st.Lock()
+	var snapshots map[uint64]any
+	c.Assert(st.Get("snapshots", &snapshots), check.IsNil)
+	c.Check(snapshots, check.DeepEquals, map[uint64]any{
+		3: map[string]any{"scheduled": true},
+	})
+	st.Unlock()
+
+	// and not again until the interval passed, even if a newer set would
+	// now replace set 3
+	f, err := os.Create(filepath.Join(c.MkDir(), "5_a-snap.zip"))
+	c.Assert(err, check.IsNil)
+	defer f.Close()
+	readers = append(readers, &backend.Reader{
+		Snapshot: client.Snapshot{SetID: 5, Snap: "a-snap", Time: now, Size: 100},
+		File:     f,
+	})
+	removed = nil
+	st.Lock()
+	snapshots[5] = map[string]any{"scheduled": true}
+	st.Set("snapshots", snapshots)
+	st.Unlock()
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(removed, check.HasLen, 0)
+}
@@ -22,6 +22,7 @@ package snapshotstate
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"time"
@@ -35,9 +36,16 @@ import (
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/overlord/swfeats"
 	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/timeutil"
 )
 
+func init() {
+	swfeats.RegisterEnsure("SnapshotManager", "ensureScheduledSnapshot")
+	swfeats.RegisterEnsure("SnapshotManager", "ensureChunksCleanup")
+}
+
 var (
 	osRemove             = os.Remove
 	snapstateCurrentInfo = snapstate.CurrentInfo
@@ -51,7 +59,8 @@ var (
 	backendRevert        = (*backend.RestoreState).Revert // ditto
 	backendCleanup       = (*backend.RestoreState).Cleanup
 
-	backendCleanupAbandonedImports = backend.CleanupAbandonedImports
+	backendCleanupAbandonedImports   = backend.CleanupAbandonedImports
+	backendCleanupUnreferencedChunks = backend.CleanupUnreferencedChunks
 
 	autoExpirationInterval = time.Hour * 24 // interval between forgetExpiredSnapshots runs as part of Ensure()
 
@@ -63,6 +72,11 @@ type SnapshotManager struct {
 	state *state.State
 
 	lastForgetExpiredSnapshotTime time.Time
+	lastChunksCleanupTime         time.Time
+
+	nextScheduledSnapshot time.Time
+	lastSnapshotSchedule  string
+	lastRetentionTime     time.Time
 }
 
 // Manager returns a new SnapshotManager
@@ -74,6 +88,10 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 	runner.AddHandler("check-snapshot", doCheck, nil)
 	runner.AddHandler("restore-snapshot", doRestore, undoRestore)
 	runner.AddHandler("cleanup-after-restore", doCleanupAfterRestore, nil)
+	runner.AddHandler("push-snapshot", doPush, nil)
+	runner.AddCleanup("push-snapshot", cleanupTransfer)
+	runner.AddHandler("pull-snapshot", doPull, nil)
+	runner.AddCleanup("pull-snapshot", cleanupTransfer)
 
 	manager := &SnapshotManager{
 		state: st,
@@ -87,12 +105,85 @@ func Manager(st *state.State, runner *state.TaskRunner) *SnapshotManager {
 func (mgr *SnapshotManager) Ensure() error {
 	// process expired snapshots once a day.
 	if time.Now().After(mgr.lastForgetExpiredSnapshotTime.Add(autoExpirationInterval)) {
-		return mgr.forgetExpiredSnapshots()
+		if err := mgr.forgetExpiredSnapshots(); err != nil {
+			return err
+		}
+	}
+
+	if err := mgr.ensureScheduledSnapshot(); err != nil {
+		logger.Noticef("%v", err)
+	}
+	if timeNow().After(mgr.lastRetentionTime.Add(retentionInterval)) {
+		if err := mgr.enforceRetention(); err != nil {
+			logger.Noticef("cannot enforce retention of scheduled snapshots: %v", err)
+		}
+	}
+	if err := mgr.ensureChunksCleanup(); err != nil {
+		logger.Noticef("cannot cleanup unreferenced snapshot chunks: %v", err)
 	}
 
 	return nil
 }
 
+// requestChunksCleanup asks for the chunks no snapshot refers to anymore to
+// be removed from the chunk store by the next Ensure. The state needs to be
+// locked by the caller.
+func requestChunksCleanup(st *state.State) {
+	st.Set("snapshot-chunks-cleanup", true)
+	st.EnsureBefore(0)
+}
+
+// forgettingSnapshots returns whether any task is still forgetting a
+// snapshot, either as forget-snapshot or as the undo of save-snapshot. The
+// state needs to be locked by the caller.
+func forgettingSnapshots(st *state.State) bool {
+	for _, chg := range st.Changes() {
+		if chg.IsReady() {
+			continue
+		}
+		for _, t := range chg.Tasks() {
+			switch t.Kind() {
+			case "forget-snapshot":
+				if status := t.Status(); status == state.DoStatus || status == state.DoingStatus {
+					return true
+				}
+			case "save-snapshot":
+				if status := t.Status(); status == state.UndoStatus || status == state.UndoingStatus {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// ensureChunksCleanup removes the chunks no snapshot refers to anymore from
+// the chunk store, when asked to and otherwise once a day, to catch those
+// left behind by failed cleanups. As looking for them means going through
+// all the snapshots, it waits for the snapshots being forgotten to be gone,
+// so that it is done only once for all of them.
+func (mgr *SnapshotManager) ensureChunksCleanup() error {
+	st := mgr.state
+	st.Lock()
+	var requested bool
+	if err := st.Get("snapshot-chunks-cleanup", &requested); err != nil && !errors.Is(err, state.ErrNoState) {
+		st.Unlock()
+		return err
+	}
+	due := timeNow().After(mgr.lastChunksCleanupTime.Add(autoExpirationInterval))
+	if !(requested || due) || forgettingSnapshots(st) {
+		st.Unlock()
+		return nil
+	}
+	st.Set("snapshot-chunks-cleanup", nil)
+	st.Unlock()
+
+	logger.Trace("ensure", "manager", "SnapshotManager", "func", "ensureChunksCleanup")
+	mgr.lastChunksCleanupTime = timeNow()
+	_, err := backendCleanupUnreferencedChunks(context.TODO())
+	return err
+}
+
 func (mgr *SnapshotManager) StartUp() error {
 	if _, err := backendCleanupAbandonedImports(); err != nil {
 		logger.Noticef("cannot cleanup incomplete imports: %v", err)
@@ -113,44 +204,121 @@ func (mgr *SnapshotManager) forgetExpiredSnapshots() error {
 		return nil
 	}
 
-	err = backendIter(context.TODO(), func(r *backend.Reader) error {
-		// forget needs to conflict with check and restore
-		if err := checkSnapshotConflict(mgr.state, r.SetID, "export-snapshot",
-			"check-snapshot", "restore-snapshot"); err != nil {
-			// there is a conflict, do nothing and we will retry this set on next Ensure().
+	if err := forgetSnapshotSets(mgr.state, sets); err != nil {
+		return fmt.Errorf("cannot process expired snapshots: %v", err)
+	}
+
+	// only reset time if there are no sets left because of conflicts
+	if len(sets) == 0 {
+		mgr.lastForgetExpiredSnapshotTime = time.Now()
+	}
+
+	return nil
+}
+
+// forgetSnapshotSets removes the files and state of the given snapshot sets,
+// removing them from the map. Sets with conflicting operations in progress
+// are left in the map, to be retried later. The state needs to be locked by
+// the caller.
+func forgetSnapshotSets(st *state.State, sets map[uint64]bool) error {
+	forgotten := make(map[uint64]bool)
+	err := backendIter(context.TODO(), func(r *backend.Reader) error {
+		if !sets[r.SetID] {
 			return nil
 		}
-		if sets[r.SetID] {
-			delete(sets, r.SetID)
+		if !forgotten[r.SetID] {
+			// forget needs to conflict with check, restore and push
+			if err := checkSnapshotConflict(st, r.SetID, "export-snapshot",
+				"check-snapshot", "restore-snapshot", "push-snapshot"); err != nil {
+				// there is a conflict, do nothing and we will retry this set on next Ensure().
+				return nil
+			}
+			forgotten[r.SetID] = true
 			// remove from state first: in case removeSnapshotState succeeds but osRemove fails we will never attempt
 			// to automatically remove this snapshot again and will leave it on the disk (so the user can still try to remove it manually);
 			// this is better than the other way around where a failing osRemove would be retried forever because snapshot would never
 			// leave the state.
-			if err := removeSnapshotState(mgr.state, r.SetID); err != nil {
+			if err := removeSnapshotState(st, r.SetID); err != nil {
 				return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", r.SetID, err)
 			}
-			if err := osRemove(r.Name()); err != nil {
-				return fmt.Errorf("cannot remove snapshot file %q: %v", r.Name(), err)
-			}
+		}
+		if err := osRemove(r.Name()); err != nil {
+			return fmt.Errorf("cannot remove snapshot file %q: %v", r.Name(), err)
 		}
 		return nil
 	})
+	for setID := range forgotten {
+		delete(sets, setID)
+		if err := maybeRemoveSnapshotKey(st, setID); err != nil {
+			logger.Noticef("cannot remove key of snapshot set #%d: %v", setID, err)
+		}
+	}
+	return err
+}
+
+// ensureScheduledSnapshot starts a snapshot of the configured snaps, if one
+// is due according to snapshots.schedule.
+func (mgr *SnapshotManager) ensureScheduledSnapshot() error {
+	st := mgr.state
+	st.Lock()
+	defer st.Unlock()
 
+	scheduleStr, err := coreOption(config.NewTransaction(st), "snapshots.schedule")
 	if err != nil {
-		return fmt.Errorf("cannot process expired snapshots: %v", err)
+		return err
+	}
+	if scheduleStr == "" {
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = ""
+		return nil
+	}
+	if scheduleStr != mgr.lastSnapshotSchedule {
+		// the schedule has changed
+		mgr.nextScheduledSnapshot = time.Time{}
+		mgr.lastSnapshotSchedule = scheduleStr
+	}
+	schedule, err := timeutil.ParseSchedule(scheduleStr)
+	if err != nil {
+		return fmt.Errorf("cannot parse snapshots.schedule: %v", err)
 	}
 
-	// only reset time if there are no sets left because of conflicts
-	if len(sets) == 0 {
-		mgr.lastForgetExpiredSnapshotTime = time.Now()
+	if scheduledSnapshotInFlight(st) {
+		return nil
+	}
+
+	logger.Trace("ensure", "manager", "SnapshotManager", "func", "ensureScheduledSnapshot")
+
+	now := timeNow()
+	if mgr.nextScheduledSnapshot.IsZero() {
+		last, err := lastScheduledSnapshot(st)
+		if err != nil {
+			return err
+		}
+		if last.IsZero() {
+			// wait for the schedule to come around instead of
+			// snapshotting as soon as it's set
+			last = now
+			st.Set("last-scheduled-snapshot", last)
+		}
+		mgr.nextScheduledSnapshot = now.Add(timeutil.Next(schedule, last, scheduledSnapshotMaxPostponement))
+		logger.Debugf("Next scheduled snapshot at %s.", mgr.nextScheduledSnapshot.Format(time.RFC3339))
+	}
+	if mgr.nextScheduledSnapshot.After(now) {
+		return nil
 	}
 
+	if err := launchScheduledSnapshot(st); err != nil {
+		mgr.nextScheduledSnapshot = now.Add(scheduledSnapshotRetryDelay)
+		return fmt.Errorf("cannot start scheduled snapshot: %v", err)
+	}
+	st.Set("last-scheduled-snapshot", now)
+	mgr.nextScheduledSnapshot = time.Time{}
 	return nil
 }
 
 func (SnapshotManager) affectedSnaps(t *state.Task) ([]string, error) {
-	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" {
-		// check and forget don't affect snaps
+	if k := t.Kind(); k == "check-snapshot" || k == "forget-snapshot" || k == "push-snapshot" {
+		// check, forget and push don't affect snaps
 		// (this could also be written k != save && k != restore, but it's safer this way around)
 		return nil, nil
 	}
@@ -163,13 +331,14 @@ func (SnapshotManager) affectedSnaps(t *state.Task) ([]string, error) {
 }
 
 type snapshotSetup struct {
-	SetID    uint64                `json:"set-id"`
-	Snap     string                `json:"snap"`
-	Users    []string              `json:"users,omitempty"`
-	Options  *snap.SnapshotOptions `json:"options,omitempty"`
-	Filename string                `json:"filename,omitempty"`
-	Current  snap.Revision         `json:"current"`
-	Auto     bool                  `json:"auto,omitempty"`
+	SetID     uint64                `json:"set-id"`
+	Snap      string                `json:"snap"`
+	Users     []string              `json:"users,omitempty"`
+	Options   *snap.SnapshotOptions `json:"options,omitempty"`
+	Filename  string                `json:"filename,omitempty"`
+	Current   snap.Revision         `json:"current"`
+	Auto      bool                  `json:"auto,omitempty"`
+	Encrypted bool                  `json:"encrypted,omitempty"`
 }
 
 func filename(setID uint64, si *snap.Info) string {
@@ -228,20 +397,51 @@ func doSave(task *state.Task, tomb *tomb.Tomb) error {
 
 	st.Lock()
 	opts, err := getSnapDirOpts(st, snapshot.Snap)
+	if err != nil {
+		st.Unlock()
+		return err
+	}
+	flags, err := backendSaveFlags(st)
+	if err == nil && snapshot.Encrypted {
+		flags.Key, err = snapshotKey(st, snapshot.SetID)
+		if err == nil && flags.Key == nil {
+			err = fmt.Errorf("internal error: key of encrypted snapshot set #%d is missing", snapshot.SetID)
+		}
+	}
 	st.Unlock()
 	if err != nil {
 		return err
 	}
 
-	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts)
+	_, err = backendSave(tomb.Context(nil), snapshot.SetID, cur, cfg, snapshot.Users, snapshot.Options, opts, flags)
 	if err != nil {
 		st.Lock()
 		defer st.Unlock()
 		removeSnapshotState(st, snapshot.SetID)
+		if err := maybeRemoveSnapshotKey(st, snapshot.SetID); err != nil {
+			logger.Noticef("cannot remove key of snapshot set #%d: %v", snapshot.SetID, err)
+		}
 	}
 	return err
 }
 
+// useSnapshotKey hands the reader the key of its snapshot set, if it's
+// encrypted. The state needs to be locked by the caller.
+func useSnapshotKey(st *state.State, reader *backend.Reader) error {
+	if !reader.Encrypted {
+		return nil
+	}
+	key, err := snapshotKey(st, reader.SetID)
+	if err != nil {
+		return err
+	}
+	if key == nil {
+		return fmt.Errorf("key of encrypted snapshot set #%d is not available", reader.SetID)
+	}
+	reader.SetKey(key)
+	return nil
+}
+
 // prepareRestore does the steps of doRestore that require the state lock
 // before the backend Restore call.
 func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[string]any, reader *backend.Reader, err error) {
@@ -262,6 +462,10 @@ func prepareRestore(task *state.Task) (snapshot *snapshotSetup, oldCfg map[strin
 	if err != nil {
 		return nil, nil, nil, fmt.Errorf("cannot open snapshot: %v", err)
 	}
+	if err := useSnapshotKey(st, reader); err != nil {
+		reader.Close()
+		return nil, nil, nil, fmt.Errorf("cannot restore snapshot: %v", err)
+	}
 	// note given the Open succeeded, caller needs to close it when done
 
 	return snapshot, oldCfg, reader, nil
@@ -433,6 +637,15 @@ func doCheck(task *state.Task, tomb *tomb.Tomb) error {
 	}
 	defer reader.Close()
 
+	// the data of encrypted snapshots can be checked without the key,
+	// but with it the data is also authenticated
+	st.Lock()
+	err = useSnapshotKey(st, reader)
+	st.Unlock()
+	if err != nil {
+		logger.Debugf("cannot authenticate data of snapshot %q: %v", snapshot.Filename, err)
+	}
+
 	return backendCheck(reader, tomb.Context(nil), snapshot.Users)
 }
 
@@ -458,7 +671,17 @@ func doForget(task *state.Task, _ *tomb.Tomb) error {
 		return fmt.Errorf("internal error: cannot remove state of snapshot set %d: %v", snapshot.SetID, err)
 	}
 
-	return osRemove(snapshot.Filename)
+	if err := osRemove(snapshot.Filename); err != nil {
+		return err
+	}
+
+	if err := maybeRemoveSnapshotKey(st, snapshot.SetID); err != nil {
+		logger.Noticef("cannot remove key of snapshot set #%d: %v", snapshot.SetID, err)
+	}
+
+	// the snapshot might have been the last user of some chunks
+	requestChunksCleanup(st)
+	return nil
 }
 
 func delayedCrossMgrInit() {
@@ -468,7 +691,7 @@ func delayedCrossMgrInit() {
 	snapstate.EstimateSnapshotSize = EstimateSnapshotSize
 }
 
-func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions) (*client.Snapshot, error)) (restore func()) {
+func MockBackendSave(f func(context.Context, uint64, *snap.Info, map[string]any, []string, *snap.SnapshotOptions, *dirs.SnapDirOptions, *backend.SaveFlags) (*client.Snapshot, error)) (restore func()) {
 	old := backendSave
 	backendSave = f
 	return func() {
@@ -35,6 +35,7 @@ import (
 	"github.com/snapcore/snapd/dirs"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapshotstate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/state"
@@ -55,6 +56,8 @@ func (snapshotSuite) TestManager(c *check.C) {
 		"check-snapshot",
 		"cleanup-after-restore",
 		"forget-snapshot",
+		"pull-snapshot",
+		"push-snapshot",
 		"restore-snapshot",
 		"save-snapshot",
 	})
@@ -116,6 +119,72 @@ func (snapshotSuite) TestEnsureForgetsSnapshots(c *check.C) {
 	c.Check(removedSnapshot, check.Matches, ".*/foo.zip")
 }
 
+func (snapshotSuite) TestEnsureCleansUpChunksOnce(c *check.C) {
+	var cleanups int
+	defer snapshotstate.MockBackendCleanupUnreferencedChunks(func(context.Context) (int, error) {
+		cleanups++
+		return 0, nil
+	})()
+
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+	// the daily cleanup is not due
+	snapshotstate.SetLastChunksCleanupTime(mgr, time.Now())
+
+	st.Lock()
+	chg := st.NewChange("forget-snapshot", "...")
+	t1 := st.NewTask("forget-snapshot", "...")
+	t1.SetStatus(state.DoneStatus)
+	t2 := st.NewTask("forget-snapshot", "...")
+	chg.AddTask(t1)
+	chg.AddTask(t2)
+	st.Set("snapshot-chunks-cleanup", true)
+	st.Unlock()
+
+	// not while snapshots are still being forgotten
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(cleanups, check.Equals, 0)
+
+	st.Lock()
+	t2.SetStatus(state.DoneStatus)
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(cleanups, check.Equals, 1)
+
+	// only once
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(cleanups, check.Equals, 1)
+
+	// and then again once a day
+	snapshotstate.SetLastChunksCleanupTime(mgr, time.Now().Add(-25*time.Hour))
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(cleanups, check.Equals, 2)
+
+	st.Lock()
+	defer st.Unlock()
+	var requested bool
+	c.Check(st.Get("snapshot-chunks-cleanup", &requested), testutil.ErrorIs, state.ErrNoState)
+}
+
+func (snapshotSuite) TestEnsureCleansUpChunksErrorLogged(c *check.C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+	defer snapshotstate.MockBackendCleanupUnreferencedChunks(func(context.Context) (int, error) {
+		return 0, errors.New("some error")
+	})()
+
+	st := state.New(nil)
+	mgr := snapshotstate.Manager(st, state.NewTaskRunner(st))
+
+	st.Lock()
+	st.Set("snapshot-chunks-cleanup", true)
+	st.Unlock()
+
+	c.Assert(mgr.Ensure(), check.IsNil)
+	c.Check(logbuf.String(), testutil.Contains, "cannot cleanup unreferenced snapshot chunks: some error\n")
+}
+
 func (snapshotSuite) TestEnsureForgetsSnapshotsRunsRegularly(c *check.C) {
 	var backendIterCalls int
 	shotfile, err := os.Create(filepath.Join(c.MkDir(), "foo.zip"))
@@ -282,7 +351,7 @@ func (snapshotSuite) TestDoSave(c *check.C) {
 
 	expectedOptions := &snap.SnapshotOptions{}
 	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string,
-		options *snap.SnapshotOptions, _ *dirs.SnapDirOptions) (*client.Snapshot, error) {
+		options *snap.SnapshotOptions, _ *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		c.Check(id, check.Equals, uint64(42))
 		c.Check(si, check.DeepEquals, &snapInfo)
 		c.Check(cfg, check.DeepEquals, map[string]any{"hello": "there"})
@@ -338,7 +407,7 @@ func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	})()
 
 	var checkOpts bool
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, opts *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, opts *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		c.Check(opts.HiddenSnapDataDir, check.Equals, true)
 		checkOpts = true
 		return nil, nil
@@ -357,12 +426,86 @@ func (snapshotSuite) TestDoSaveGetsSnapDirOpts(c *check.C) {
 	c.Check(checkOpts, check.Equals, true)
 }
 
+func (snapshotSuite) TestDoSaveDeduplicatesWithFeatureFlag(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+
+	var flags []*backend.SaveFlags
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, _ *dirs.SnapDirOptions, f *backend.SaveFlags) (*client.Snapshot, error) {
+		flags = append(flags, f)
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	for _, enabled := range []bool{false, true} {
+		st.Lock()
+		tr := config.NewTransaction(st)
+		tr.Set("core", "experimental.snapshot-deduplication", enabled)
+		tr.Commit()
+		task := st.NewTask("save-snapshot", "...")
+		task.Set("snapshot-setup", map[string]any{
+			"set-id": 42,
+			"snap":   "a-snap",
+		})
+		st.Unlock()
+
+		c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+	}
+	c.Check(flags, check.DeepEquals, []*backend.SaveFlags{{Deduplicate: false}, {Deduplicate: true}})
+}
+
+func (snapshotSuite) TestDoSaveEncrypted(c *check.C) {
+	snapInfo := snap.Info{
+		SideInfo: snap.SideInfo{
+			RealName: "a-snap",
+			Revision: snap.R(-1),
+		},
+		Version: "1.33",
+	}
+	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
+	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
+
+	var flags *backend.SaveFlags
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, _ *dirs.SnapDirOptions, f *backend.SaveFlags) (*client.Snapshot, error) {
+		flags = f
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := st.NewTask("save-snapshot", "...")
+	task.Set("snapshot-setup", map[string]any{
+		"set-id":    42,
+		"snap":      "a-snap",
+		"encrypted": true,
+	})
+	st.Unlock()
+
+	// the key must be there
+	err := snapshotstate.DoSave(task, &tomb.Tomb{})
+	c.Check(err, check.ErrorMatches, "internal error: key of encrypted snapshot set #42 is missing")
+	c.Check(flags, check.IsNil)
+
+	st.Lock()
+	c.Assert(snapshotstate.SaveSnapshotKey(st, 42, []byte("a-key")), check.IsNil)
+	st.Unlock()
+	c.Assert(snapshotstate.DoSave(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(flags, check.DeepEquals, &backend.SaveFlags{Key: []byte("a-key")})
+}
+
 func (snapshotSuite) TestDoSaveFailsWithNoSnap(c *check.C) {
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) {
 		return nil, errors.New("bzzt")
 	})()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -389,7 +532,7 @@ func (snapshotSuite) TestDoSaveFailsWithNoSnapshot(c *check.C) {
 	}
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -413,7 +556,7 @@ func (snapshotSuite) TestDoSaveFailsBackendError(c *check.C) {
 	}
 	defer snapshotstate.MockSnapstateCurrentInfo(func(*state.State, string) (*snap.Info, error) { return &snapInfo, nil })()
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) { return nil, nil })()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, errors.New("bzzt")
 	})()
 
@@ -442,7 +585,7 @@ func (snapshotSuite) TestDoSaveFailsConfigError(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(*state.State, string) (*json.RawMessage, error) {
 		return nil, errors.New("bzzt")
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -473,7 +616,7 @@ func (snapshotSuite) TestDoSaveFailsBadConfig(c *check.C) {
 		buf := json.RawMessage(`"hello-there"`)
 		return &buf, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		return nil, nil
 	})()
 
@@ -506,7 +649,7 @@ func (snapshotSuite) TestDoSaveFailureRemovesStateEntry(c *check.C) {
 	defer snapshotstate.MockConfigGetSnapConfig(func(_ *state.State, snapname string) (*json.RawMessage, error) {
 		return nil, nil
 	})()
-	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions) (*client.Snapshot, error) {
+	defer snapshotstate.MockBackendSave(func(_ context.Context, id uint64, si *snap.Info, cfg map[string]any, usernames []string, _ *snap.SnapshotOptions, options *dirs.SnapDirOptions, _ *backend.SaveFlags) (*client.Snapshot, error) {
 		var expirations map[uint64]any
 		st.Lock()
 		defer st.Unlock()
@@ -560,6 +703,7 @@ func (rs *readerSuite) SetUpTest(c *check.C) {
 
 	rs.calls = nil
 	rs.restores = []func(){
+		snapshotstate.MockFdestateDeriveKey(mockDeriveKey),
 		snapshotstate.MockOsRemove(func(string) error {
 			rs.calls = append(rs.calls, "remove")
 			return nil
@@ -844,9 +988,21 @@ func (rs *readerSuite) TestDoRemove(c *check.C) {
 		rs.calls = append(rs.calls, "remove")
 		return nil
 	})()
+	defer snapshotstate.MockBackendCleanupUnreferencedChunks(func(context.Context) (int, error) {
+		rs.calls = append(rs.calls, "cleanup-chunks")
+		return 0, nil
+	})()
 	err := snapshotstate.DoForget(rs.task, &tomb.Tomb{})
 	c.Assert(err, check.IsNil)
+	// the chunks are cleaned up by the manager later on
 	c.Check(rs.calls, check.DeepEquals, []string{"remove"})
+
+	st := rs.task.State()
+	st.Lock()
+	defer st.Unlock()
+	var requested bool
+	c.Assert(st.Get("snapshot-chunks-cleanup", &requested), check.IsNil)
+	c.Check(requested, check.Equals, true)
 }
 
 func (rs *readerSuite) TestDoForgetRemovesAutomaticSnapshotExpiry(c *check.C) {
@@ -886,6 +1042,47 @@ func (rs *readerSuite) TestDoForgetRemovesAutomaticSnapshotExpiry(c *check.C) {
 		}})
 }
 
+func (rs *readerSuite) TestDoForgetRemovesSnapshotKey(c *check.C) {
+	defer snapshotstate.MockOsRemove(func(filename string) error {
+		return nil
+	})()
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "foo.zip"))
+	c.Assert(err, check.IsNil)
+	defer shotfile.Close()
+	defer snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		return f(&backend.Reader{
+			Snapshot: client.Snapshot{SetID: 2, Snap: "a-snap"},
+			File:     shotfile,
+		})
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	c.Assert(snapshotstate.SaveSnapshotKey(st, 1, []byte("key-1")), check.IsNil)
+	c.Assert(snapshotstate.SaveSnapshotKey(st, 2, []byte("key-2")), check.IsNil)
+
+	for _, setID := range []uint64{1, 2} {
+		task := st.NewTask("forget-snapshot", "...")
+		task.Set("snapshot-setup", map[string]any{
+			"set-id":   setID,
+			"filename": "a-file",
+			"snap":     "a-snap",
+		})
+		st.Unlock()
+		c.Assert(snapshotstate.DoForget(task, &tomb.Tomb{}), check.IsNil)
+		st.Lock()
+	}
+
+	// the key of set 2 is kept as it still has snapshots
+	var keys map[uint64][]byte
+	c.Assert(st.Get("snapshot-keys", &keys), check.IsNil)
+	c.Check(keys, check.HasLen, 1)
+	key, err := snapshotstate.SnapshotKey(st, 2)
+	c.Assert(err, check.IsNil)
+	c.Check(key, check.DeepEquals, []byte("key-2"))
+}
+
 func (snapshotSuite) TestManagerRunCleanupAbandonedImportsAtStartup(c *check.C) {
 	n := 0
 	restore := snapshotstate.MockBackendCleanupAbandonedImports(func() (int, error) {
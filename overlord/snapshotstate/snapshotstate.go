@@ -29,8 +29,10 @@ import (
 	"time"
 
 	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/features"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/fdestate"
 	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate"
 	"github.com/snapcore/snapd/overlord/state"
@@ -46,6 +48,9 @@ var (
 	backendEstimateSnapshotSize      = backend.EstimateSnapshotSize
 	backendList                      = backend.List
 	backendNewSnapshotExport         = backend.NewSnapshotExport
+	backendNewSnapshotKey            = backend.NewSnapshotKey
+
+	fdestateDeriveKey = fdestate.DeriveKey
 
 	// Default expiration time for automatic snapshots, if not set by the user
 	defaultAutomaticSnapshotExpiration = time.Hour * 24 * 31
@@ -53,6 +58,9 @@ var (
 
 type snapshotState struct {
 	ExpiryTime time.Time `json:"expiry-time"`
+	// Scheduled is set for sets taken according to snapshots.schedule,
+	// which are subject to the retention policy for scheduled snapshots.
+	Scheduled bool `json:"scheduled,omitempty"`
 }
 
 func newSnapshotSetID(st *state.State) (uint64, error) {
@@ -101,7 +109,7 @@ func allActiveSnapNames(st *state.State) ([]string, error) {
 	return names, nil
 }
 
-func EstimateSnapshotSize(st *state.State, instanceName string, users []string) (uint64, error) {
+func EstimateSnapshotSize(ctx context.Context, st *state.State, instanceName string, users []string) (uint64, error) {
 	cur, err := snapstateCurrentInfo(st, instanceName)
 	if err != nil {
 		return 0, err
@@ -115,8 +123,12 @@ func EstimateSnapshotSize(st *state.State, instanceName string, users []string)
 	if err != nil {
 		return 0, err
 	}
+	flags, err := backendSaveFlags(st)
+	if err != nil {
+		return 0, err
+	}
 
-	sz, err := backendEstimateSnapshotSize(cur, users, opts)
+	sz, err := backendEstimateSnapshotSize(ctx, cur, users, opts, flags)
 	if err != nil {
 		return 0, err
 	}
@@ -126,6 +138,18 @@ func EstimateSnapshotSize(st *state.State, instanceName string, users []string)
 	return sz, nil
 }
 
+// backendSaveFlags returns the flags for saving snapshots in the backend, as
+// determined by the system configuration. The state must be locked by the
+// caller.
+func backendSaveFlags(st *state.State) (*backend.SaveFlags, error) {
+	tr := config.NewTransaction(st)
+	deduplicate, err := features.Flag(tr, features.SnapshotDeduplication)
+	if err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	return &backend.SaveFlags{Deduplicate: deduplicate}, nil
+}
+
 func AutomaticSnapshotExpiration(st *state.State) (time.Duration, error) {
 	var expirationStr string
 	tr := config.NewTransaction(st)
@@ -193,6 +217,101 @@ func removeSnapshotState(st *state.State, setIDs ...uint64) error {
 	return nil
 }
 
+// snapshotKeyWrappingKey returns the key the keys of snapshot sets are
+// wrapped with in the state. It is derived from the primary key of the
+// encrypted system, so it's only available while the system data is
+// unlocked with the sealed FDE keys. The state needs to be locked by the
+// caller.
+func snapshotKeyWrappingKey(st *state.State) ([]byte, error) {
+	return fdestateDeriveKey(st, "snapshot-keys")
+}
+
+// checkCanEncrypt checks whether snapshot set keys can be kept on the
+// system, which is only the case if the system data is encrypted. The
+// state needs to be locked by the caller.
+func checkCanEncrypt(st *state.State) error {
+	_, err := snapshotKeyWrappingKey(st)
+	return err
+}
+
+// snapshotKey returns the key of the given snapshot set, or nil if the set
+// is not encrypted. The state needs to be locked by the caller.
+func snapshotKey(st *state.State, setID uint64) ([]byte, error) {
+	var keys map[uint64][]byte
+	err := st.Get("snapshot-keys", &keys)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return nil, err
+	}
+	wrapped := keys[setID]
+	if wrapped == nil {
+		return nil, nil
+	}
+	wrappingKey, err := snapshotKeyWrappingKey(st)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unwrap key of snapshot set #%d: %v", setID, err)
+	}
+	key, err := backend.UnwrapSnapshotKey(wrappingKey, setID, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("cannot unwrap key of snapshot set #%d: %v", setID, err)
+	}
+	return key, nil
+}
+
+// saveSnapshotKey saves the key of the given snapshot set in the state,
+// wrapped with the key derived from the primary key of the encrypted
+// system. The state needs to be locked by the caller.
+func saveSnapshotKey(st *state.State, setID uint64, key []byte) error {
+	var keys map[uint64][]byte
+	err := st.Get("snapshot-keys", &keys)
+	if err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	wrappingKey, err := snapshotKeyWrappingKey(st)
+	if err != nil {
+		return err
+	}
+	wrapped, err := backend.WrapSnapshotKey(wrappingKey, setID, key)
+	if err != nil {
+		return fmt.Errorf("cannot wrap key of snapshot set #%d: %v", setID, err)
+	}
+	if keys == nil {
+		keys = make(map[uint64][]byte)
+	}
+	keys[setID] = wrapped
+	st.Set("snapshot-keys", keys)
+	return nil
+}
+
+// maybeRemoveSnapshotKey removes the key of the given snapshot set from the
+// state, if no snapshots of the set are left. The state needs to be locked
+// by the caller.
+func maybeRemoveSnapshotKey(st *state.State, setID uint64) error {
+	var keys map[uint64][]byte
+	err := st.Get("snapshot-keys", &keys)
+	if err != nil {
+		if errors.Is(err, state.ErrNoState) {
+			return nil
+		}
+		return err
+	}
+	if _, ok := keys[setID]; !ok {
+		return nil
+	}
+
+	_, err = snapSummariesInSnapshotSet(setID, nil)
+	switch err {
+	case client.ErrSnapshotSetNotFound:
+		delete(keys, setID)
+		st.Set("snapshot-keys", keys)
+		return nil
+	case nil:
+		// still in use
+		return nil
+	default:
+		return err
+	}
+}
+
 // expiredSnapshotSets returns expired snapshot sets from the state whose expiry-time is before the given cutoffTime.
 // The state needs to be locked by the caller.
 func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool, error) {
@@ -207,7 +326,8 @@ func expiredSnapshotSets(st *state.State, cutoffTime time.Time) (map[uint64]bool
 
 	expired := make(map[uint64]bool)
 	for setID, snapshotSet := range snapshots {
-		if snapshotSet.ExpiryTime.Before(cutoffTime) {
+		// sets without an expiry time, such as scheduled ones, never expire
+		if !snapshotSet.ExpiryTime.IsZero() && snapshotSet.ExpiryTime.Before(cutoffTime) {
 			expired[setID] = true
 		}
 	}
@@ -335,19 +455,27 @@ func List(ctx context.Context, st *state.State, setID uint64, snapNames []string
 	return sets, nil
 }
 
-// Import a given snapshot ID from an exported snapshot
-func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, snapNames []string, err error) {
+// Import a given snapshot ID from an exported snapshot.
+//
+// The passphrase is needed to import exports encrypted with one.
+func Import(ctx context.Context, st *state.State, r io.Reader, passphrase string) (setID uint64, snapNames []string, err error) {
 	st.Lock()
 	setID, err = newSnapshotSetID(st)
 	// note, this is a new set id which is not exposed yet, no need to mark it
 	// for conflicts via snapshotOp. Also, since we're keeping state lock while
 	// checking conflicts below, there is no need to for setSnapshotOpInProgress.
+	allowEncrypted := false
+	if err == nil && passphrase != "" {
+		// only exports with a passphrase carry encrypted snapshot sets
+		allowEncrypted = checkCanEncrypt(st) == nil
+	}
 	st.Unlock()
 	if err != nil {
 		return 0, nil, err
 	}
 
-	snapNames, err = backendImport(ctx, setID, r, nil)
+	flags := &backend.ImportFlags{Passphrase: passphrase, AllowEncrypted: allowEncrypted}
+	snapNames, key, err := backendImport(ctx, setID, r, flags)
 	if err != nil {
 		if dupErr, ok := err.(backend.DuplicatedSnapshotImportError); ok {
 			st.Lock()
@@ -356,11 +484,19 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 			if err := checkSnapshotConflict(st, dupErr.SetID, "forget-snapshot"); err != nil {
 				// we found an existing snapshot but it's being forgotten, so
 				// retry the import without checking for existing snapshot.
-				flags := &backend.ImportFlags{NoDuplicatedImportCheck: true}
+				flags.NoDuplicatedImportCheck = true
 				st.Unlock()
-				snapNames, err = backendImport(ctx, setID, r, flags)
+				snapNames, key, err = backendImport(ctx, setID, r, flags)
 				st.Lock()
-				return setID, snapNames, err
+				if err != nil {
+					return 0, nil, err
+				}
+				if key != nil {
+					if err := saveSnapshotKey(st, setID, key); err != nil {
+						return 0, nil, err
+					}
+				}
+				return setID, snapNames, nil
 			}
 
 			// trying to import identical snapshot; instead return set ID of
@@ -375,12 +511,35 @@ func Import(ctx context.Context, st *state.State, r io.Reader) (setID uint64, sn
 		}
 		return 0, nil, err
 	}
+	if key != nil {
+		st.Lock()
+		defer st.Unlock()
+		if err := saveSnapshotKey(st, setID, key); err != nil {
+			return 0, nil, err
+		}
+	}
 	return setID, snapNames, nil
 }
 
+// SaveFlags carries extra flags for saving snapshots.
+type SaveFlags struct {
+	// Encrypt the snapshot set with a key of its own, kept on the
+	// system. This requires the system data to be encrypted.
+	Encrypt bool
+}
+
 // Save creates a taskset for taking snapshots of snaps' data.
 // Note that the state must be locked by the caller.
-func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+func Save(st *state.State, instanceNames []string, users []string, options map[string]*snap.SnapshotOptions, flags *SaveFlags) (setID uint64, snapsSaved []string, ts *state.TaskSet, err error) {
+	if flags == nil {
+		flags = &SaveFlags{}
+	}
+	if flags.Encrypt {
+		if err := checkCanEncrypt(st); err != nil {
+			return 0, nil, nil, fmt.Errorf("cannot save encrypted snapshot: %v", err)
+		}
+	}
+
 	if len(instanceNames) == 0 {
 		instanceNames, err = allActiveSnapNames(st)
 		if err != nil {
@@ -409,6 +568,16 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		return 0, nil, nil, err
 	}
 
+	if flags.Encrypt {
+		key, err := backendNewSnapshotKey()
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		if err := saveSnapshotKey(st, setID, key); err != nil {
+			return 0, nil, nil, err
+		}
+	}
+
 	ts = state.NewTaskSet()
 
 	for _, name := range instanceNames {
@@ -416,10 +585,11 @@ func Save(st *state.State, instanceNames []string, users []string, options map[s
 		task := st.NewTask("save-snapshot", desc)
 
 		snapshot := snapshotSetup{
-			SetID:   setID,
-			Snap:    name,
-			Users:   users,
-			Options: options[name],
+			SetID:     setID,
+			Snap:      name,
+			Users:     users,
+			Options:   options[name],
+			Encrypted: flags.Encrypt,
 		}
 
 		task.Set("snapshot-setup", &snapshot)
@@ -572,9 +742,9 @@ func Check(st *state.State, setID uint64, snapNames []string, users []string) (s
 // Forget creates a taskset for deletinig a snapshot.
 // Note that the state must be locked by the caller.
 func Forget(st *state.State, setID uint64, snapNames []string) (snapsFound []string, ts *state.TaskSet, err error) {
-	// forget needs to conflict with check, restore, import and export.
+	// forget needs to conflict with check, restore, import, export and push.
 	if err := checkSnapshotConflict(st, setID, "export-snapshot",
-		"check-snapshot", "restore-snapshot"); err != nil {
+		"check-snapshot", "restore-snapshot", "push-snapshot"); err != nil {
 		return nil, nil, err
 	}
 
@@ -628,15 +798,25 @@ func UnsetSnapshotOpInProgress(st *state.State, setID uint64) string {
 	return op
 }
 
-// Export exports a given snapshot ID
+// Export exports a given snapshot ID, encrypting the export with the
+// passphrase if given. Encrypted snapshot sets can only be exported with a
+// passphrase.
 // Note that the state must be locked by the caller.
-func Export(ctx context.Context, st *state.State, setID uint64) (se *backend.SnapshotExport, err error) {
+func Export(ctx context.Context, st *state.State, setID uint64, passphrase string) (se *backend.SnapshotExport, err error) {
 	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
 		return nil, err
 	}
 
+	key, err := snapshotKey(st, setID)
+	if err != nil {
+		return nil, err
+	}
+	if key != nil && passphrase == "" {
+		return nil, fmt.Errorf("cannot export encrypted snapshot set #%d without a passphrase", setID)
+	}
+
 	setSnapshotOpInProgress(st, setID, "export-snapshot")
-	se, err = backendNewSnapshotExport(ctx, setID)
+	se, err = backendNewSnapshotExport(ctx, setID, &backend.ExportFlags{Passphrase: passphrase, Key: key})
 	if err != nil {
 		UnsetSnapshotOpInProgress(st, setID)
 	}
@@ -78,6 +78,15 @@ func (s *snapshotSuite) SetUpTest(c *check.C) {
 		return nil, nil
 	}
 	s.AddCleanup(osutil.MockMountInfo(""))
+	s.AddCleanup(snapshotstate.MockFdestateDeriveKey(mockDeriveKey))
+}
+
+// mockDeriveKey derives keys as on a system with encrypted data.
+func mockDeriveKey(st *state.State, purpose string) ([]byte, error) {
+	if purpose != "snapshot-keys" {
+		return nil, fmt.Errorf("unexpected purpose %q", purpose)
+	}
+	return bytes.Repeat([]byte{1}, 32), nil
 }
 
 func (s *snapshotSuite) TearDownTest(c *check.C) {
@@ -360,7 +369,7 @@ func (snapshotSuite) TestSaveChecksSnapnamesError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -425,7 +434,7 @@ func (s snapshotSuite) TestSaveChecksSnapstateConflict(c *check.C) {
 	st, restore := s.createConflictingChange(c)
 	defer restore()
 
-	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, []string{"foo"}, nil, nil, nil)
 	c.Assert(err, check.NotNil)
 	c.Check(err, check.FitsTypeOf, &snapstate.ChangeConflictError{})
 }
@@ -461,7 +470,7 @@ func (snapshotSuite) TestSaveConflictsWithSnapstate(c *check.C) {
 	})
 
 	chg := st.NewChange("snapshot-save", "...")
-	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, saveTasks, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	chg.AddAll(saveTasks)
 
@@ -477,7 +486,7 @@ func (snapshotSuite) TestSaveChecksSnapstateConflictError(c *check.C) {
 	st := state.New(nil)
 	st.Lock()
 	defer st.Unlock()
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, "bzzt")
 }
 
@@ -488,7 +497,7 @@ func (snapshotSuite) TestSaveChecksSetIDError(c *check.C) {
 
 	st.Set("last-snapshot-set-id", "3/4")
 
-	_, _, _, err := snapshotstate.Save(st, nil, nil, nil)
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Check(err, check.ErrorMatches, ".* could not unmarshal .*")
 }
 
@@ -497,7 +506,7 @@ func (snapshotSuite) TestSaveNoSnapsInState(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.HasLen, 0)
@@ -509,7 +518,7 @@ func (snapshotSuite) TestSaveSnapNotInstalled(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	setID, saved, taskset, err := snapshotstate.Save(st, []string{"foo"}, nil, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"foo"}, nil, nil, nil)
 	c.Assert(err, check.ErrorMatches, `snap "foo" is not installed`)
 	c.Check(setID, check.Equals, uint64(0))
 	c.Check(saved, check.HasLen, 0)
@@ -535,7 +544,7 @@ func (snapshotSuite) TestSaveSomeSnaps(c *check.C) {
 		"a-snap": {Exclude: []string{"$SNAP_COMMON/exclude", "$SNAP_DATA/exclude"}},
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, snapshotOptions)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, snapshotOptions, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap", "c-snap"})
@@ -581,7 +590,7 @@ func (s snapshotSuite) TestSaveOneSnap(c *check.C) {
 		Current: snap.R(1),
 	})
 
-	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, []string{"a-snap"}, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"a-snap"})
@@ -599,6 +608,77 @@ func (s snapshotSuite) TestSaveOneSnap(c *check.C) {
 	})
 }
 
+func (snapshotSuite) TestSaveEncrypted(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{"a-snap": {Active: true}}, nil
+	})()
+	defer snapshotstate.MockBackendNewSnapshotKey(func() ([]byte, error) { return []byte("a-key"), nil })()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, nil, nil, &snapshotstate.SaveFlags{Encrypt: true})
+	c.Assert(err, check.IsNil)
+	c.Check(setID, check.Equals, uint64(1))
+	c.Check(saved, check.DeepEquals, []string{"a-snap"})
+	tasks := taskset.Tasks()
+	c.Assert(tasks, check.HasLen, 1)
+	var snapshot map[string]any
+	c.Assert(tasks[0].Get("snapshot-setup", &snapshot), check.IsNil)
+	c.Check(snapshot, check.DeepEquals, map[string]any{
+		"set-id":    1.,
+		"snap":      "a-snap",
+		"current":   "unset",
+		"encrypted": true,
+	})
+
+	// the key is not kept in the clear
+	var keys map[uint64][]byte
+	c.Assert(st.Get("snapshot-keys", &keys), check.IsNil)
+	c.Assert(keys, check.HasLen, 1)
+	c.Check(bytes.Contains(keys[1], []byte("a-key")), check.Equals, false)
+	key, err := snapshotstate.SnapshotKey(st, 1)
+	c.Assert(err, check.IsNil)
+	c.Check(key, check.DeepEquals, []byte("a-key"))
+
+	// and cannot be used without the key derived from the FDE primary key
+	restore := snapshotstate.MockFdestateDeriveKey(func(*state.State, string) ([]byte, error) {
+		return nil, errors.New("cannot get primary key: boom")
+	})
+	defer restore()
+	_, err = snapshotstate.SnapshotKey(st, 1)
+	c.Check(err, check.ErrorMatches, `cannot unwrap key of snapshot set #1: cannot get primary key: boom`)
+}
+
+func (snapshotSuite) TestSaveEncryptedNeedsEncryptedData(c *check.C) {
+	defer snapshotstate.MockSnapstateAll(func(*state.State) (map[string]*snapstate.SnapState, error) {
+		return map[string]*snapstate.SnapState{"a-snap": {Active: true}}, nil
+	})()
+	defer snapshotstate.MockBackendNewSnapshotKey(func() ([]byte, error) {
+		c.Fatal("unexpected call")
+		return nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	restore := snapshotstate.MockFdestateDeriveKey(func(*state.State, string) ([]byte, error) {
+		return nil, errors.New("system data is not encrypted")
+	})
+	_, _, _, err := snapshotstate.Save(st, nil, nil, nil, &snapshotstate.SaveFlags{Encrypt: true})
+	c.Check(err, check.ErrorMatches, "cannot save encrypted snapshot: system data is not encrypted")
+	restore()
+
+	restore = snapshotstate.MockFdestateDeriveKey(func(*state.State, string) ([]byte, error) { return nil, errors.New("boom") })
+	_, _, _, err = snapshotstate.Save(st, nil, nil, nil, &snapshotstate.SaveFlags{Encrypt: true})
+	c.Check(err, check.ErrorMatches, "cannot save encrypted snapshot: boom")
+	restore()
+
+	c.Check(st.Get("snapshot-keys", new(map[uint64][]byte)), testutil.ErrorIs, state.ErrNoState)
+}
+
 func (snapshotSuite) TestSaveIntegration(c *check.C) {
 	if os.Geteuid() == 0 {
 		c.Skip("this test cannot run as root (runuser will fail)")
@@ -660,7 +740,7 @@ func (snapshotSuite) TestSaveIntegration(c *check.C) {
 		}
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, snapshotOptions)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, snapshotOptions, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -767,7 +847,7 @@ exec /bin/tar "$@"
 		c.Assert(os.Mkdir(filepath.Join(homedir, "snap", name, "common", "common-"+name), mode), check.IsNil)
 	}
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"one-snap", "too-snap", "tri-snap"})
@@ -867,7 +947,7 @@ exec /bin/tar "$@"
 	// these dir permissions (000) make tar unhappy
 	c.Assert(os.Mkdir(filepath.Join(homedir, "snap/tar-fail-snap/common/common-tar-fail-snap"), 00), check.IsNil)
 
-	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil)
+	setID, saved, taskset, err := snapshotstate.Save(st, nil, []string{"a-user"}, nil, nil)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(1))
 	c.Check(saved, check.DeepEquals, []string{"tar-fail-snap"})
@@ -1263,7 +1343,7 @@ func testRestoreIntegration(c *check.C, snapDataDir string, opts *dirs.SnapDirOp
 			c.Assert(os.MkdirAll(filepath.Join(home, snapDataDir, name, "common", "common-"+name), 0755), check.IsNil)
 		}
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"}, nil, opts)
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user", "b-user"}, nil, opts, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1342,7 +1422,7 @@ func (snapshotSuite) TestRestoreIntegrationFails(c *check.C) {
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, fmt.Sprint(i+1), "canary-"+name), 0755), check.IsNil)
 		c.Assert(os.MkdirAll(filepath.Join(homedir, "snap", name, "common", "common-"+name), 0755), check.IsNil)
 
-		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil, nil)
+		_, err := backend.Save(context.TODO(), 42, snapInfo, nil, []string{"a-user"}, nil, nil, nil)
 		c.Assert(err, check.IsNil)
 	}
 
@@ -1845,30 +1925,65 @@ func (snapshotSuite) TestImportSnapshotHappy(c *check.C) {
 	fakeSnapshotData := "fake-import-data"
 
 	buf := bytes.NewBufferString(fakeSnapshotData)
-	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
+	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
 		d, err := io.ReadAll(r)
 		c.Assert(err, check.IsNil)
 		c.Check(fakeSnapshotData, check.Equals, string(d))
-		return fakeSnapNames, nil
+		return fakeSnapNames, nil, nil
 	})
 	defer restore()
 
-	sid, names, err := snapshotstate.Import(context.TODO(), st, buf)
+	sid, names, err := snapshotstate.Import(context.TODO(), st, buf, "")
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(1))
 	c.Check(names, check.DeepEquals, fakeSnapNames)
 }
 
+func (snapshotSuite) TestImportSnapshotEncrypted(c *check.C) {
+	st := state.New(nil)
+
+	for _, encryptedData := range []bool{false, true} {
+		defer snapshotstate.MockFdestateDeriveKey(func(st *state.State, purpose string) ([]byte, error) {
+			if !encryptedData {
+				return nil, errors.New("system data is not encrypted")
+			}
+			return mockDeriveKey(st, purpose)
+		})()
+		restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
+			c.Check(flags, check.DeepEquals, &backend.ImportFlags{Passphrase: "sekrit", AllowEncrypted: encryptedData})
+			if !flags.AllowEncrypted {
+				return nil, nil, errors.New("not allowed")
+			}
+			return []string{"foo"}, []byte("a-key"), nil
+		})
+		defer restore()
+
+		sid, names, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""), "sekrit")
+		if !encryptedData {
+			c.Check(err, check.ErrorMatches, "not allowed")
+			continue
+		}
+		c.Assert(err, check.IsNil)
+		c.Check(names, check.DeepEquals, []string{"foo"})
+
+		st.Lock()
+		key, err := snapshotstate.SnapshotKey(st, sid)
+		c.Assert(err, check.IsNil)
+		c.Check(key, check.DeepEquals, []byte("a-key"))
+		st.Unlock()
+	}
+}
+
 func (snapshotSuite) TestImportSnapshotImportError(c *check.C) {
 	st := state.New(nil)
 
-	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
-		return nil, errors.New("some-error")
+	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
+		return nil, nil, errors.New("some-error")
 	})
 	defer restore()
 
 	r := bytes.NewBufferString("faked-import-data")
-	sid, _, err := snapshotstate.Import(context.TODO(), st, r)
+	sid, _, err := snapshotstate.Import(context.TODO(), st, r, "")
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, "some-error")
 	c.Check(sid, check.Equals, uint64(0))
@@ -1877,8 +1992,8 @@ func (snapshotSuite) TestImportSnapshotImportError(c *check.C) {
 func (snapshotSuite) TestImportSnapshotDuplicate(c *check.C) {
 	st := state.New(nil)
 
-	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
-		return nil, backend.DuplicatedSnapshotImportError{SetID: 3, SnapNames: []string{"foo-snap"}}
+	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
+		return nil, nil, backend.DuplicatedSnapshotImportError{SetID: 3, SnapNames: []string{"foo-snap"}}
 	})
 	defer restore()
 
@@ -1889,7 +2004,7 @@ func (snapshotSuite) TestImportSnapshotDuplicate(c *check.C) {
 	})
 	st.Unlock()
 
-	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""))
+	sid, snapNames, err := snapshotstate.Import(context.TODO(), st, bytes.NewBufferString(""), "")
 	c.Assert(err, check.IsNil)
 	c.Check(sid, check.Equals, uint64(3))
 	c.Check(snapNames, check.DeepEquals, []string{"foo-snap"})
@@ -1916,11 +2031,11 @@ func (snapshotSuite) TestEstimateSnapshotSize(c *check.C) {
 		Current:  sideInfo.Revision,
 	})
 
-	defer snapshotstate.MockBackendEstimateSnapshotSize(func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error) {
+	defer snapshotstate.MockBackendEstimateSnapshotSize(func(context.Context, *snap.Info, []string, *dirs.SnapDirOptions, *backend.SaveFlags) (uint64, error) {
 		return 123, nil
 	})()
 
-	sz, err := snapshotstate.EstimateSnapshotSize(st, "some-snap", nil)
+	sz, err := snapshotstate.EstimateSnapshotSize(context.TODO(), st, "some-snap", nil)
 	c.Assert(err, check.IsNil)
 	c.Check(sz, check.Equals, uint64(123))
 }
@@ -1937,7 +2052,7 @@ func (snapshotSuite) TestEstimateSnapshotSizeWithConfig(c *check.C) {
 		Current:  sideInfo.Revision,
 	})
 
-	defer snapshotstate.MockBackendEstimateSnapshotSize(func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error) {
+	defer snapshotstate.MockBackendEstimateSnapshotSize(func(context.Context, *snap.Info, []string, *dirs.SnapDirOptions, *backend.SaveFlags) (uint64, error) {
 		return 100, nil
 	})()
 
@@ -1947,7 +2062,7 @@ func (snapshotSuite) TestEstimateSnapshotSizeWithConfig(c *check.C) {
 		return &buf, nil
 	})()
 
-	sz, err := snapshotstate.EstimateSnapshotSize(st, "some-snap", nil)
+	sz, err := snapshotstate.EstimateSnapshotSize(context.TODO(), st, "some-snap", nil)
 	c.Assert(err, check.IsNil)
 	// size is 100 + 18
 	c.Check(sz, check.Equals, uint64(118))
@@ -1965,11 +2080,11 @@ func (snapshotSuite) TestEstimateSnapshotSizeError(c *check.C) {
 		Current:  sideInfo.Revision,
 	})
 
-	defer snapshotstate.MockBackendEstimateSnapshotSize(func(*snap.Info, []string, *dirs.SnapDirOptions) (uint64, error) {
+	defer snapshotstate.MockBackendEstimateSnapshotSize(func(context.Context, *snap.Info, []string, *dirs.SnapDirOptions, *backend.SaveFlags) (uint64, error) {
 		return 0, fmt.Errorf("an error")
 	})()
 
-	_, err := snapshotstate.EstimateSnapshotSize(st, "some-snap", nil)
+	_, err := snapshotstate.EstimateSnapshotSize(context.TODO(), st, "some-snap", nil)
 	c.Assert(err, check.ErrorMatches, `an error`)
 }
 
@@ -1986,12 +2101,12 @@ func (snapshotSuite) TestEstimateSnapshotSizeWithUsers(c *check.C) {
 	})
 
 	var gotUsers []string
-	defer snapshotstate.MockBackendEstimateSnapshotSize(func(info *snap.Info, users []string, opts *dirs.SnapDirOptions) (uint64, error) {
+	defer snapshotstate.MockBackendEstimateSnapshotSize(func(_ context.Context, info *snap.Info, users []string, opts *dirs.SnapDirOptions, _ *backend.SaveFlags) (uint64, error) {
 		gotUsers = users
 		return 0, nil
 	})()
 
-	_, err := snapshotstate.EstimateSnapshotSize(st, "some-snap", []string{"user1", "user2"})
+	_, err := snapshotstate.EstimateSnapshotSize(context.TODO(), st, "some-snap", []string{"user1", "user2"})
 	c.Assert(err, check.IsNil)
 	c.Check(gotUsers, check.DeepEquals, []string{"user1", "user2"})
 }
@@ -2007,23 +2122,45 @@ func (snapshotSuite) TestExportSnapshotConflictsWithForget(c *check.C) {
 	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
 	chg.AddTask(tsk)
 
-	_, err := snapshotstate.Export(context.TODO(), st, 42)
+	_, err := snapshotstate.Export(context.TODO(), st, 42, "")
 	c.Assert(err, check.NotNil)
 	c.Assert(err.Error(), check.Equals, `cannot operate on snapshot set #42 while change "1" is in progress`)
 }
 
+func (snapshotSuite) TestExportEncryptedSnapshotNeedsPassphrase(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	c.Assert(snapshotstate.SaveSnapshotKey(st, 42, []byte("a-key")), check.IsNil)
+
+	var calls []*backend.ExportFlags
+	defer snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64, flags *backend.ExportFlags) (*backend.SnapshotExport, error) {
+		calls = append(calls, flags)
+		return nil, nil
+	})()
+
+	_, err := snapshotstate.Export(context.TODO(), st, 42, "")
+	c.Check(err, check.ErrorMatches, `cannot export encrypted snapshot set #42 without a passphrase`)
+	c.Check(calls, check.HasLen, 0)
+
+	_, err = snapshotstate.Export(context.TODO(), st, 42, "sekrit")
+	c.Assert(err, check.IsNil)
+	c.Check(calls, check.DeepEquals, []*backend.ExportFlags{{Passphrase: "sekrit", Key: []byte("a-key")}})
+}
+
 func (snapshotSuite) TestImportSnapshotDuplicatedNoConflict(c *check.C) {
 	buf := &bytes.Buffer{}
 	var importCalls int
-	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
+	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
 		importCalls++
 		c.Check(id, check.Equals, uint64(1))
-		return nil, backend.DuplicatedSnapshotImportError{SetID: 42, SnapNames: []string{"foo-snap"}}
+		return nil, nil, backend.DuplicatedSnapshotImportError{SetID: 42, SnapNames: []string{"foo-snap"}}
 	})
 	defer restore()
 
 	st := state.New(nil)
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, "")
 	c.Check(importCalls, check.Equals, 1)
 	c.Assert(err, check.IsNil)
 	c.Check(setID, check.Equals, uint64(42))
@@ -2033,21 +2170,22 @@ func (snapshotSuite) TestImportSnapshotDuplicatedNoConflict(c *check.C) {
 func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 	buf := &bytes.Buffer{}
 	var importCalls int
-	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, error) {
+	restore := snapshotstate.MockBackendImport(func(ctx context.Context, id uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
 		importCalls++
 		switch importCalls {
 		case 1:
-			c.Assert(flags, check.IsNil)
+			c.Assert(flags, check.NotNil)
+			c.Assert(flags.NoDuplicatedImportCheck, check.Equals, false)
 		case 2:
 			c.Assert(flags, check.NotNil)
 			c.Assert(flags.NoDuplicatedImportCheck, check.Equals, true)
-			return []string{"foo"}, nil
+			return []string{"foo"}, nil, nil
 		default:
 			c.Fatal("unexpected number call to Import")
 		}
 		// DuplicatedSnapshotImportError is the only case where we can encounter
 		// conflict on import (trying to reuse existing snapshot).
-		return nil, backend.DuplicatedSnapshotImportError{SetID: 42, SnapNames: []string{"not-relevant-because-of-retry"}}
+		return nil, nil, backend.DuplicatedSnapshotImportError{SetID: 42, SnapNames: []string{"not-relevant-because-of-retry"}}
 	})
 	defer restore()
 
@@ -2063,7 +2201,7 @@ func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 	chg.AddTask(tsk)
 
 	st.Unlock()
-	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf)
+	setID, snaps, err := snapshotstate.Import(context.TODO(), st, buf, "")
 	st.Lock()
 	c.Check(importCalls, check.Equals, 2)
 	c.Assert(err, check.IsNil)
@@ -2072,7 +2210,7 @@ func (snapshotSuite) TestImportSnapshotConflictsWithForget(c *check.C) {
 }
 
 func (snapshotSuite) TestExportSnapshotSetsOpInProgress(c *check.C) {
-	restore := snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64) (se *backend.SnapshotExport, err error) {
+	restore := snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64, flags *backend.ExportFlags) (se *backend.SnapshotExport, err error) {
 		return nil, nil
 	})
 	defer restore()
@@ -2081,7 +2219,7 @@ func (snapshotSuite) TestExportSnapshotSetsOpInProgress(c *check.C) {
 	st.Lock()
 	defer st.Unlock()
 
-	_, err := snapshotstate.Export(context.TODO(), st, 42)
+	_, err := snapshotstate.Export(context.TODO(), st, 42, "")
 	c.Assert(err, check.IsNil)
 
 	ops := st.Cached("snapshot-ops")
@@ -2120,5 +2258,5 @@ func (snapshotSuite) TestSetSnapshotOpInProgress(c *check.C) {
 }
 
 func (s *snapshotSuite) TestEnsureLoopLogging(c *check.C) {
-	testutil.CheckEnsureLoopLogging("snapshotmgr.go", c, false)
+	testutil.CheckEnsureLoopLogging("snapshotmgr.go", c, true)
 }
@@ -0,0 +1,129 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+// httpTarget is an object read with GET and written with PUT, as done with
+// WebDAV servers. There is no standard way of resuming a PUT, so uploads
+// are retried from the start.
+type httpTarget struct {
+	client *http.Client
+	url    *url.URL
+
+	username, password string
+	token              string
+}
+
+func newHTTPTarget(u *url.URL, creds map[string]string) (*httpTarget, error) {
+	if err := checkCredentials(creds, "username", "password", "token"); err != nil {
+		return nil, err
+	}
+	t := &httpTarget{
+		client:   newHTTPClient(),
+		url:      u,
+		username: creds["username"],
+		password: creds["password"],
+		token:    creds["token"],
+	}
+	if t.token != "" && (t.username != "" || t.password != "") {
+		return nil, fmt.Errorf("cannot use both a token and a username and password for snapshot target")
+	}
+	return t, nil
+}
+
+func (t *httpTarget) String() string {
+	return t.url.Redacted()
+}
+
+func (t *httpTarget) newRequest(ctx context.Context, method string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, t.url.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case t.token != "":
+		req.Header.Set("Authorization", "Bearer "+t.token)
+	case t.username != "" || t.password != "":
+		req.SetBasicAuth(t.username, t.password)
+	}
+	return req, nil
+}
+
+func (t *httpTarget) Upload(ctx context.Context, r io.ReaderAt, size int64, progress *Progress, checkpoint func(*Progress) error) error {
+	*progress = Progress{Size: size}
+	req, err := t.newRequest(ctx, "PUT", io.NewSectionReader(r, 0, size))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		// all good
+	default:
+		return responseError("upload snapshot", resp)
+	}
+	progress.Uploaded = size
+	return checkpoint(progress)
+}
+
+func (t *httpTarget) Abort(ctx context.Context, progress *Progress) error {
+	// nothing to release
+	return nil
+}
+
+func (t *httpTarget) Size(ctx context.Context) (int64, error) {
+	req, err := t.newRequest(ctx, "HEAD", nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, responseError("get size of snapshot", resp)
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("cannot get size of snapshot: target did not report it")
+	}
+	return resp.ContentLength, nil
+}
+
+func (t *httpTarget) Download(ctx context.Context, offset int64) (io.ReadCloser, int64, error) {
+	req, err := t.newRequest(ctx, "GET", nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return download(t.client, req, offset)
+}
@@ -0,0 +1,432 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package target
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// there can be at most this many parts in a multipart upload
+	s3MaxParts = 10000
+
+	s3DefaultRegion   = "us-east-1"
+	s3UnsignedPayload = "UNSIGNED-PAYLOAD"
+)
+
+var (
+	// uploads are done in parts of at least this size, as S3 wants
+	// parts, other than the last one, to be at least 5MiB
+	s3MinPartSize int64 = 16 * 1024 * 1024
+
+	timeNow = time.Now
+)
+
+// s3Target is an object in an S3-compatible object store, addressed in
+// path-style, i.e. as endpoint/bucket/key. Data larger than a part is
+// uploaded with a multipart upload, which can be resumed.
+type s3Target struct {
+	client   *http.Client
+	endpoint *url.URL
+	bucket   string
+	key      string
+
+	accessKey, secretKey, sessionToken string
+	region                             string
+}
+
+func newS3Target(u *url.URL, creds map[string]string) (*s3Target, error) {
+	if err := checkCredentials(creds, "access-key", "secret-key", "session-token", "region"); err != nil {
+		return nil, err
+	}
+	bucket, key, _ := strings.Cut(strings.TrimPrefix(u.Path, "/"), "/")
+	if bucket == "" || key == "" || strings.HasSuffix(key, "/") {
+		return nil, fmt.Errorf("snapshot target URL %q must be of the form %s://host/bucket/key", u.Redacted(), u.Scheme)
+	}
+	if creds["access-key"] == "" || creds["secret-key"] == "" {
+		return nil, fmt.Errorf("snapshot target %q needs the access-key and secret-key credentials", u.Redacted())
+	}
+	scheme := "https"
+	if u.Scheme == "s3+http" {
+		scheme = "http"
+	}
+	region := creds["region"]
+	if region == "" {
+		region = s3DefaultRegion
+	}
+	return &s3Target{
+		client:       newHTTPClient(),
+		endpoint:     &url.URL{Scheme: scheme, Host: u.Host},
+		bucket:       bucket,
+		key:          key,
+		accessKey:    creds["access-key"],
+		secretKey:    creds["secret-key"],
+		sessionToken: creds["session-token"],
+		region:       region,
+	}, nil
+}
+
+func (t *s3Target) String() string {
+	scheme := "s3"
+	if t.endpoint.Scheme == "http" {
+		scheme = "s3+http"
+	}
+	return fmt.Sprintf("%s://%s/%s/%s", scheme, t.endpoint.Host, t.bucket, t.key)
+}
+
+// s3Escape escapes s as required by AWS signature version 4, keeping
+// slashes if so asked.
+func s3Escape(s string, keepSlash bool) string {
+	var buf strings.Builder
+	for _, b := range []byte(s) {
+		switch {
+		case 'A' <= b && b <= 'Z', 'a' <= b && b <= 'z', '0' <= b && b <= '9',
+			b == '-', b == '_', b == '.', b == '~', b == '/' && keepSlash:
+			buf.WriteByte(b)
+		default:
+			fmt.Fprintf(&buf, "%%%02X", b)
+		}
+	}
+	return buf.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// newRequest returns a request for the object, signed with AWS signature
+// version 4. The payload is not signed, so the body can be streamed.
+func (t *s3Target) newRequest(ctx context.Context, method string, query url.Values, body io.Reader) (*http.Request, error) {
+	escapedPath := "/" + s3Escape(t.bucket, false) + "/" + s3Escape(t.key, true)
+	var queryParts []string
+	for k, vs := range query {
+		for _, v := range vs {
+			queryParts = append(queryParts, s3Escape(k, false)+"="+s3Escape(v, false))
+		}
+	}
+	sort.Strings(queryParts)
+	canonicalQuery := strings.Join(queryParts, "&")
+
+	u := *t.endpoint
+	u.Path = "/" + t.bucket + "/" + t.key
+	u.RawPath = escapedPath
+	u.RawQuery = canonicalQuery
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+
+	now := timeNow().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedPayload)
+	if t.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", t.sessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for k := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(req.Header.Get(k))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", k, headers[k])
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		escapedPath,
+		canonicalQuery,
+		canonicalHeaders.String(),
+		signedHeaders,
+		s3UnsignedPayload,
+	}, "\n")
+	scope := date + "/" + t.region + "/s3/aws4_request"
+	hashedRequest := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hex.EncodeToString(hashedRequest[:]),
+	}, "\n")
+
+	signingKey := hmacSHA256([]byte("AWS4"+t.secretKey), date)
+	signingKey = hmacSHA256(signingKey, t.region)
+	signingKey = hmacSHA256(signingKey, "s3")
+	signingKey = hmacSHA256(signingKey, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		t.accessKey, scope, signedHeaders, signature))
+	return req, nil
+}
+
+type s3Error struct {
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+var errNoSuchUpload = errors.New("multipart upload does not exist")
+
+// s3ResponseError returns an error describing the unexpected response.
+func s3ResponseError(op string, resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 64*1024))
+	var s3err s3Error
+	if xml.Unmarshal(body, &s3err) == nil && s3err.Code != "" {
+		switch s3err.Code {
+		case "NoSuchKey":
+			return ErrNotFound
+		case "NoSuchUpload":
+			return errNoSuchUpload
+		}
+		return fmt.Errorf("cannot %s: %s: %s", op, s3err.Code, s3err.Message)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	return responseError(op, resp)
+}
+
+func (t *s3Target) do(req *http.Request, op string, out any) (*http.Response, error) {
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, s3ResponseError(op, resp)
+	}
+	if out != nil {
+		body, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024))
+		if err != nil {
+			return nil, fmt.Errorf("cannot %s: %v", op, err)
+		}
+		// errors can come with a 200 status too, once the
+		// processing of the request has started
+		var s3err s3Error
+		if xml.Unmarshal(body, &s3err) == nil && s3err.Code != "" {
+			return nil, fmt.Errorf("cannot %s: %s: %s", op, s3err.Code, s3err.Message)
+		}
+		if err := xml.Unmarshal(body, out); err != nil {
+			return nil, fmt.Errorf("cannot %s: cannot decode response: %v", op, err)
+		}
+	}
+	return resp, nil
+}
+
+func s3PartSize(size int64) int64 {
+	partSize := s3MinPartSize
+	if min := (size + s3MaxParts - 1) / s3MaxParts; min > partSize {
+		partSize = min
+	}
+	return partSize
+}
+
+func (t *s3Target) Upload(ctx context.Context, r io.ReaderAt, size int64, progress *Progress, checkpoint func(*Progress) error) error {
+	if progress.Size != size || progress.PartSize != s3PartSize(size) || progress.Uploaded != int64(len(progress.ETags))*progress.PartSize {
+		// not (a valid record of) an upload of this data, start
+		// from scratch
+		if err := t.Abort(ctx, progress); err != nil {
+			return err
+		}
+		*progress = Progress{Size: size, PartSize: s3PartSize(size)}
+	}
+
+	if size <= progress.PartSize {
+		req, err := t.newRequest(ctx, "PUT", nil, io.NewSectionReader(r, 0, size))
+		if err != nil {
+			return err
+		}
+		req.ContentLength = size
+		if _, err := t.do(req, "upload snapshot", nil); err != nil {
+			return err
+		}
+		progress.Uploaded = size
+		return checkpoint(progress)
+	}
+
+	err := t.uploadParts(ctx, r, progress, checkpoint)
+	if err == errNoSuchUpload {
+		// the multipart upload expired or was aborted meanwhile,
+		// start again
+		*progress = Progress{Size: size, PartSize: progress.PartSize}
+		err = t.uploadParts(ctx, r, progress, checkpoint)
+	}
+	return err
+}
+
+type s3InitiateResult struct {
+	UploadID string `xml:"UploadId"`
+}
+
+type s3CompletePart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+type s3Complete struct {
+	XMLName xml.Name         `xml:"CompleteMultipartUpload"`
+	Parts   []s3CompletePart `xml:"Part"`
+}
+
+type s3CompleteResult struct {
+	ETag string `xml:"ETag"`
+}
+
+func (t *s3Target) uploadParts(ctx context.Context, r io.ReaderAt, progress *Progress, checkpoint func(*Progress) error) error {
+	if progress.UploadID == "" {
+		req, err := t.newRequest(ctx, "POST", url.Values{"uploads": {""}}, nil)
+		if err != nil {
+			return err
+		}
+		var result s3InitiateResult
+		if _, err := t.do(req, "start upload of snapshot", &result); err != nil {
+			return err
+		}
+		if result.UploadID == "" {
+			return fmt.Errorf("cannot start upload of snapshot: no upload ID in response")
+		}
+		progress.UploadID = result.UploadID
+		if err := checkpoint(progress); err != nil {
+			return err
+		}
+	}
+
+	for progress.Uploaded < progress.Size {
+		n := progress.Size - progress.Uploaded
+		if n > progress.PartSize {
+			n = progress.PartSize
+		}
+		partNumber := len(progress.ETags) + 1
+		query := url.Values{
+			"partNumber": {strconv.Itoa(partNumber)},
+			"uploadId":   {progress.UploadID},
+		}
+		req, err := t.newRequest(ctx, "PUT", query, io.NewSectionReader(r, progress.Uploaded, n))
+		if err != nil {
+			return err
+		}
+		req.ContentLength = n
+		resp, err := t.do(req, fmt.Sprintf("upload part %d of snapshot", partNumber), nil)
+		if err != nil {
+			return err
+		}
+		etag := resp.Header.Get("ETag")
+		if etag == "" {
+			return fmt.Errorf("cannot upload part %d of snapshot: no ETag in response", partNumber)
+		}
+		progress.ETags = append(progress.ETags, etag)
+		progress.Uploaded += n
+		if err := checkpoint(progress); err != nil {
+			return err
+		}
+	}
+
+	complete := s3Complete{Parts: make([]s3CompletePart, len(progress.ETags))}
+	for i, etag := range progress.ETags {
+		complete.Parts[i] = s3CompletePart{PartNumber: i + 1, ETag: etag}
+	}
+	body, err := xml.Marshal(&complete)
+	if err != nil {
+		return err
+	}
+	req, err := t.newRequest(ctx, "POST", url.Values{"uploadId": {progress.UploadID}}, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	var result s3CompleteResult
+	if _, err := t.do(req, "complete upload of snapshot", &result); err != nil {
+		return err
+	}
+	progress.UploadID = ""
+	progress.ETags = nil
+	return checkpoint(progress)
+}
+
+func (t *s3Target) Abort(ctx context.Context, progress *Progress) error {
+	if progress.UploadID == "" {
+		return nil
+	}
+	req, err := t.newRequest(ctx, "DELETE", url.Values{"uploadId": {progress.UploadID}}, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK, http.StatusNoContent:
+		// aborted
+	default:
+		if err := s3ResponseError("abort upload of snapshot", resp); err != errNoSuchUpload {
+			return err
+		}
+	}
+	progress.UploadID = ""
+	progress.ETags = nil
+	progress.Uploaded = 0
+	return nil
+}
+
+func (t *s3Target) Size(ctx context.Context) (int64, error) {
+	req, err := t.newRequest(ctx, "HEAD", nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := t.do(req, "get size of snapshot", nil)
+	if err != nil {
+		return 0, err
+	}
+	if resp.ContentLength < 0 {
+		return 0, fmt.Errorf("cannot get size of snapshot: target did not report it")
+	}
+	return resp.ContentLength, nil
+}
+
+func (t *s3Target) Download(ctx context.Context, offset int64) (io.ReadCloser, int64, error) {
+	req, err := t.newRequest(ctx, "GET", nil, nil)
+	if err != nil {
+		return nil, 0, err
+	}
+	return download(t.client, req, offset)
+}
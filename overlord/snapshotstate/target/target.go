@@ -0,0 +1,179 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package target implements remote locations that snapshot exports can be
+// pushed to and pulled from.
+package target
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/snapcore/snapd/httputil"
+)
+
+// ErrNotFound is returned when the object of a target does not exist.
+var ErrNotFound = errors.New("snapshot not found on target")
+
+// Spec describes a target: the URL of the object holding the snapshot
+// export, and the credentials to access it.
+//
+// URLs with the s3:// scheme (or s3+http:// for plain HTTP) are objects in
+// an S3-compatible object store, in the form s3://host/bucket/key, using the
+// "access-key", "secret-key" and optionally "region" and "session-token"
+// credentials.
+//
+// URLs with the http:// or https:// scheme are read with GET and written
+// with PUT, as supported by WebDAV servers, using either the "username" and
+// "password" or the "token" credentials.
+type Spec struct {
+	URL         string            `json:"url"`
+	Credentials map[string]string `json:"credentials,omitempty"`
+}
+
+// Progress records how far an upload got, so that it can be resumed.
+type Progress struct {
+	// Size of the data being uploaded.
+	Size int64 `json:"size"`
+	// Uploaded is the amount of data known to be stored by the target.
+	Uploaded int64 `json:"uploaded"`
+
+	// PartSize is the size of the parts of a multipart upload.
+	PartSize int64 `json:"part-size,omitempty"`
+	// UploadID identifies a multipart upload.
+	UploadID string `json:"upload-id,omitempty"`
+	// ETags of the parts uploaded so far.
+	ETags []string `json:"etags,omitempty"`
+}
+
+// Target is a remote location holding a single snapshot export.
+type Target interface {
+	// Upload stores size bytes read from r in the target. If progress
+	// describes an earlier upload of the same data that was interrupted,
+	// the upload is resumed if the target supports it. The checkpoint
+	// function is called with the updated progress whenever a part of
+	// the data has been stored.
+	Upload(ctx context.Context, r io.ReaderAt, size int64, progress *Progress, checkpoint func(*Progress) error) error
+	// Abort releases the resources of an upload that won't be resumed.
+	Abort(ctx context.Context, progress *Progress) error
+	// Size returns the size of the stored data, or ErrNotFound.
+	Size(ctx context.Context) (int64, error)
+	// Download returns the stored data from the given offset on, if the
+	// target supports it, otherwise from the start. The offset the data
+	// starts at is returned as well.
+	Download(ctx context.Context, offset int64) (data io.ReadCloser, start int64, err error)
+	// String returns the location of the target, without credentials.
+	String() string
+}
+
+var newHTTPClient = func() *http.Client {
+	return httputil.NewHTTPClient(nil)
+}
+
+// New returns the target described by the spec.
+func New(spec *Spec) (Target, error) {
+	u, err := url.Parse(spec.URL)
+	if err != nil {
+		return nil, fmt.Errorf("cannot parse snapshot target URL: %v", err)
+	}
+	if u.User != nil {
+		return nil, fmt.Errorf("cannot use credentials in snapshot target URL")
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("snapshot target URL %q has no host", spec.URL)
+	}
+	switch u.Scheme {
+	case "s3", "s3+http":
+		return newS3Target(u, spec.Credentials)
+	case "http", "https":
+		return newHTTPTarget(u, spec.Credentials)
+	default:
+		return nil, fmt.Errorf("unsupported snapshot target URL scheme %q", u.Scheme)
+	}
+}
+
+func checkCredentials(creds map[string]string, known ...string) error {
+	for k := range creds {
+		found := false
+		for _, kn := range known {
+			if k == kn {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown snapshot target credential %q", k)
+		}
+	}
+	return nil
+}
+
+// responseError returns an error describing the unexpected response.
+func responseError(op string, resp *http.Response) error {
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+	msg := strings.TrimSpace(string(body))
+	if msg == "" {
+		return fmt.Errorf("cannot %s: %s", op, resp.Status)
+	}
+	return fmt.Errorf("cannot %s: %s: %s", op, resp.Status, msg)
+}
+
+// downloadStart checks the response to a GET from the given offset on,
+// returning the offset the data in the response starts at.
+func downloadStart(resp *http.Response, offset int64) (int64, error) {
+	switch {
+	case resp.StatusCode == http.StatusOK:
+		// either no range was asked for, or the target ignored it
+		return 0, nil
+	case resp.StatusCode == http.StatusPartialContent && offset > 0:
+		var start int64
+		if _, err := fmt.Sscanf(resp.Header.Get("Content-Range"), "bytes %d-", &start); err != nil || start != offset {
+			return 0, fmt.Errorf("cannot download snapshot: unexpected content range %q", resp.Header.Get("Content-Range"))
+		}
+		return start, nil
+	default:
+		return 0, responseError("download snapshot", resp)
+	}
+}
+
+// download does the GET request from the given offset on, as prepared by
+// newRequest.
+func download(client *http.Client, req *http.Request, offset int64) (io.ReadCloser, int64, error) {
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, 0, err
+	}
+	start, err := downloadStart(resp, offset)
+	if err != nil {
+		resp.Body.Close()
+		return nil, 0, err
+	}
+	return resp.Body, start, nil
+}
@@ -0,0 +1,412 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+// maxTransferAttempts is how many times pushing or pulling a snapshot set
+// is attempted before giving up.
+const maxTransferAttempts = 5
+
+var (
+	targetNew                   = target.New
+	backendSnapshotExportStream = (*backend.SnapshotExport).StreamTo
+
+	transferRetryDelay = time.Minute
+)
+
+// snapshotTransfer is the information a push-snapshot or pull-snapshot task
+// needs to access the target. The credentials of the target and the
+// passphrase are not kept in the task, see transferSecrets.
+type snapshotTransfer struct {
+	Target     target.Spec `json:"target"`
+	Passphrase string      `json:"-"`
+	// Secrets is set if the transfer has credentials or a passphrase.
+	Secrets bool `json:"secrets,omitempty"`
+}
+
+// transferSecrets holds the credentials of the target and the passphrase of
+// a snapshot transfer. They are only kept in memory, cached in the state
+// under transferSecretsKey, to avoid leaking them, which means that the
+// transfer cannot be carried on after a restart of snapd.
+type transferSecrets struct {
+	Credentials map[string]string
+	Passphrase  string
+}
+
+type transferSecretsKey struct {
+	taskID string
+}
+
+// setTransfer sets up the task to transfer the snapshot export from or to
+// the given target. The state needs to be locked by the caller.
+func setTransfer(task *state.Task, spec *target.Spec, passphrase string) {
+	transfer := &snapshotTransfer{Target: target.Spec{URL: spec.URL}}
+	if len(spec.Credentials) > 0 || passphrase != "" {
+		transfer.Secrets = true
+		task.State().Cache(transferSecretsKey{task.ID()}, &transferSecrets{
+			Credentials: spec.Credentials,
+			Passphrase:  passphrase,
+		})
+	}
+	task.Set("snapshot-transfer", transfer)
+}
+
+// Push creates a taskset for pushing the export of a snapshot set to the
+// given target. Encrypted snapshot sets can only be pushed with a
+// passphrase, which is used to encrypt the export.
+// Note that the state must be locked by the caller.
+func Push(st *state.State, setID uint64, spec *target.Spec, passphrase string) (snapsFound []string, ts *state.TaskSet, err error) {
+	tgt, err := targetNew(spec)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// push needs to conflict with forget of itself
+	if err := checkSnapshotConflict(st, setID, "forget-snapshot"); err != nil {
+		return nil, nil, err
+	}
+
+	summaries, err := snapSummariesInSnapshotSet(setID, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	key, err := snapshotKey(st, setID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key != nil && passphrase == "" {
+		return nil, nil, fmt.Errorf("cannot push encrypted snapshot set #%d without a passphrase", setID)
+	}
+
+	desc := fmt.Sprintf("Push snapshot set #%d to %s", setID, tgt)
+	task := st.NewTask("push-snapshot", desc)
+	task.Set("snapshot-setup", &snapshotSetup{SetID: setID})
+	setTransfer(task, spec, passphrase)
+
+	return summaries.snapNames(), state.NewTaskSet(task), nil
+}
+
+// Pull creates a taskset for importing a snapshot set from the export in
+// the given target. The passphrase is needed for exports encrypted with
+// one. Once done, the set ID and the snaps of the imported set are in the
+// api-data of the change.
+// Note that the state must be locked by the caller.
+func Pull(st *state.State, spec *target.Spec, passphrase string) (*state.TaskSet, error) {
+	tgt, err := targetNew(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	desc := fmt.Sprintf("Pull snapshot set from %s", tgt)
+	task := st.NewTask("pull-snapshot", desc)
+	setTransfer(task, spec, passphrase)
+
+	return state.NewTaskSet(task), nil
+}
+
+func stagingFilename(task *state.Task) string {
+	return filepath.Join(dirs.SnapshotsDir, fmt.Sprintf(".%s-%s.tar", task.Kind(), task.ID()))
+}
+
+// prepareTransfer returns the transfer information of the task, including
+// its secrets, and the target it refers to. The state needs to be locked by
+// the caller.
+func prepareTransfer(task *state.Task) (*snapshotTransfer, target.Target, error) {
+	var transfer snapshotTransfer
+	if err := task.Get("snapshot-transfer", &transfer); err != nil {
+		return nil, nil, taskGetErrMsg(task, err, "snapshot transfer")
+	}
+	if transfer.Secrets {
+		cached := task.State().Cached(transferSecretsKey{task.ID()})
+		if cached == nil {
+			return nil, nil, errors.New("cannot find credentials of snapshot transfer in memory: unexpected snapd restart")
+		}
+		secrets, ok := cached.(*transferSecrets)
+		if !ok {
+			return nil, nil, fmt.Errorf("internal error: wrong data type under transferSecretsKey: %T", cached)
+		}
+		transfer.Target.Credentials = secrets.Credentials
+		transfer.Passphrase = secrets.Passphrase
+	}
+	tgt, err := targetNew(&transfer.Target)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &transfer, tgt, nil
+}
+
+// retryTransfer returns the error to return from the task after a failure
+// to talk to the target, having the task retried unless it was attempted
+// too many times already. The state needs to be locked by the caller.
+func retryTransfer(task *state.Task, tgt target.Target, err error) error {
+	if errors.Is(err, target.ErrNotFound) || errors.Is(err, context.Canceled) {
+		return err
+	}
+	var attempts int
+	if err := task.Get("snapshot-transfer-attempts", &attempts); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	attempts++
+	if attempts >= maxTransferAttempts {
+		return err
+	}
+	task.Set("snapshot-transfer-attempts", attempts)
+	task.Logf("Cannot transfer snapshot from or to %s, will retry: %v", tgt, err)
+	return &state.Retry{After: transferRetryDelay, Reason: err.Error()}
+}
+
+// stageExport writes the export of the snapshot set to the staging file,
+// unless done already, and returns the opened file. As exports encrypted
+// with a passphrase differ every time, the same file is used until the
+// push is done so that it can be resumed.
+func stageExport(ctx context.Context, filename string, setID uint64, flags *backend.ExportFlags) (*os.File, error) {
+	if f, err := os.Open(filename); err == nil {
+		return f, nil
+	} else if !os.IsNotExist(err) {
+		return nil, err
+	}
+
+	se, err := backendNewSnapshotExport(ctx, setID, flags)
+	if err != nil {
+		return nil, err
+	}
+	defer se.Close()
+
+	aw, err := osutil.NewAtomicFile(filename, 0600, 0, osutil.NoChown, osutil.NoChown)
+	if err != nil {
+		return nil, err
+	}
+	defer aw.Cancel()
+	if err := backendSnapshotExportStream(se, aw); err != nil {
+		return nil, fmt.Errorf("cannot export snapshot set #%d: %v", setID, err)
+	}
+	if err := aw.Commit(); err != nil {
+		return nil, err
+	}
+	return os.Open(filename)
+}
+
+func doPush(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	var snapshot snapshotSetup
+	if err := task.Get("snapshot-setup", &snapshot); err != nil {
+		return taskGetErrMsg(task, err, "snapshot")
+	}
+	transfer, tgt, err := prepareTransfer(task)
+	if err != nil {
+		return err
+	}
+	key, err := snapshotKey(st, snapshot.SetID)
+	if err != nil {
+		return err
+	}
+	var progress target.Progress
+	if err := task.Get("snapshot-upload", &progress); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+
+	ctx := tomb.Context(nil)
+	st.Unlock()
+	f, err := stageExport(ctx, stagingFilename(task), snapshot.SetID, &backend.ExportFlags{Passphrase: transfer.Passphrase, Key: key})
+	st.Lock()
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	checkpoint := func(p *target.Progress) error {
+		st.Lock()
+		defer st.Unlock()
+		task.Set("snapshot-upload", p)
+		task.SetProgress("", int(p.Uploaded), int(p.Size))
+		return nil
+	}
+	st.Unlock()
+	err = tgt.Upload(ctx, f, fi.Size(), &progress, checkpoint)
+	st.Lock()
+	if err != nil {
+		return retryTransfer(task, tgt, err)
+	}
+	return nil
+}
+
+func doPull(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	transfer, tgt, err := prepareTransfer(task)
+	if err != nil {
+		return err
+	}
+
+	ctx := tomb.Context(nil)
+	st.Unlock()
+	f, err := downloadExport(ctx, tgt, stagingFilename(task))
+	st.Lock()
+	if err != nil {
+		var dlErr *downloadError
+		if errors.As(err, &dlErr) {
+			return retryTransfer(task, tgt, dlErr.err)
+		}
+		return err
+	}
+	defer f.Close()
+
+	st.Unlock()
+	setID, snapNames, err := Import(ctx, st, f, transfer.Passphrase)
+	st.Lock()
+	if err != nil {
+		return fmt.Errorf("cannot import snapshot: %v", err)
+	}
+	task.Logf("Imported snapshot set #%d of snaps %v", setID, snapNames)
+	task.Change().Set("api-data", map[string]any{
+		"set-id":     setID,
+		"snap-names": snapNames,
+	})
+	return nil
+}
+
+// downloadError is an error talking to the target, as opposed to a local
+// error.
+type downloadError struct {
+	err error
+}
+
+func (e *downloadError) Error() string {
+	return e.err.Error()
+}
+
+// downloadExport downloads the export in the target into the staging file,
+// resuming an earlier download if possible, and returns the opened file.
+func downloadExport(ctx context.Context, tgt target.Target, filename string) (*os.File, error) {
+	size, err := tgt.Size(ctx)
+	if err != nil {
+		return nil, &downloadError{err}
+	}
+	f, err := os.OpenFile(filename, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	offset := fi.Size()
+	if offset > size {
+		// not a download of this export
+		offset = 0
+	}
+	if offset < size {
+		if err := downloadFrom(ctx, tgt, f, offset); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func downloadFrom(ctx context.Context, tgt target.Target, f *os.File, offset int64) error {
+	data, start, err := tgt.Download(ctx, offset)
+	if err != nil {
+		return &downloadError{err}
+	}
+	defer data.Close()
+	if start != offset {
+		logger.Debugf("Cannot resume download of snapshot from %s, starting over.", tgt)
+	}
+	if err := f.Truncate(start); err != nil {
+		return err
+	}
+	if _, err := f.Seek(start, io.SeekStart); err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, data); err != nil {
+		// what got written is kept, for the retry to resume from
+		return &downloadError{err}
+	}
+	return nil
+}
+
+// cleanupTransfer removes the staging file of a push-snapshot or
+// pull-snapshot task and, once the change is ready, releases what is left
+// of an upload and drops the secrets of the transfer from memory.
+func cleanupTransfer(task *state.Task, tomb *tomb.Tomb) error {
+	st := task.State()
+	st.Lock()
+	defer st.Unlock()
+
+	if task.Kind() == "push-snapshot" {
+		var progress target.Progress
+		err := task.Get("snapshot-upload", &progress)
+		if err != nil && !errors.Is(err, state.ErrNoState) {
+			return err
+		}
+		if progress.UploadID != "" {
+			_, tgt, err := prepareTransfer(task)
+			if err == nil {
+				st.Unlock()
+				err = tgt.Abort(tomb.Context(nil), &progress)
+				st.Lock()
+			}
+			if err != nil {
+				logger.Noticef("cannot abort upload of snapshot set: %v", err)
+			}
+		}
+	}
+
+	if err := os.Remove(stagingFilename(task)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	st.Cache(transferSecretsKey{task.ID()}, nil)
+	return nil
+}
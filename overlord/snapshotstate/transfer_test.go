@@ -0,0 +1,484 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapshotstate_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/check.v1"
+	"gopkg.in/tomb.v2"
+
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/overlord/snapshotstate"
+	"github.com/snapcore/snapd/overlord/snapshotstate/backend"
+	"github.com/snapcore/snapd/overlord/snapshotstate/target"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+)
+
+// fakeTarget keeps the data in memory, uploading it in parts of partSize
+// bytes.
+type fakeTarget struct {
+	data     []byte
+	partSize int64
+
+	// failAt makes the upload fail once it got that far
+	failAt  int64
+	aborted int
+	offsets []int64
+}
+
+func (t *fakeTarget) Upload(ctx context.Context, r io.ReaderAt, size int64, progress *target.Progress, checkpoint func(*target.Progress) error) error {
+	if progress.Size != size {
+		*progress = target.Progress{Size: size, PartSize: t.partSize, UploadID: "upload"}
+		t.data = nil
+	}
+	for progress.Uploaded < size {
+		if t.failAt > 0 && progress.Uploaded >= t.failAt {
+			t.failAt = 0
+			return errors.New("connection reset")
+		}
+		n := size - progress.Uploaded
+		if n > t.partSize {
+			n = t.partSize
+		}
+		buf := make([]byte, n)
+		if _, err := r.ReadAt(buf, progress.Uploaded); err != nil {
+			return err
+		}
+		t.data = append(t.data, buf...)
+		progress.Uploaded += n
+		if err := checkpoint(progress); err != nil {
+			return err
+		}
+	}
+	progress.UploadID = ""
+	return checkpoint(progress)
+}
+
+func (t *fakeTarget) Abort(ctx context.Context, progress *target.Progress) error {
+	t.aborted++
+	progress.UploadID = ""
+	return nil
+}
+
+func (t *fakeTarget) Size(ctx context.Context) (int64, error) {
+	if t.data == nil {
+		return 0, target.ErrNotFound
+	}
+	return int64(len(t.data)), nil
+}
+
+func (t *fakeTarget) Download(ctx context.Context, offset int64) (io.ReadCloser, int64, error) {
+	t.offsets = append(t.offsets, offset)
+	data := t.data[offset:]
+	if t.failAt > 0 {
+		data = data[:t.failAt-offset]
+		t.failAt = 0
+		return io.NopCloser(io.MultiReader(bytes.NewReader(data), iotestErrReader{})), offset, nil
+	}
+	return io.NopCloser(bytes.NewReader(data)), offset, nil
+}
+
+func (t *fakeTarget) String() string {
+	return "s3://minio/bucket/key"
+}
+
+type iotestErrReader struct{}
+
+func (iotestErrReader) Read([]byte) (int, error) {
+	return 0, errors.New("connection reset")
+}
+
+type transferSuite struct {
+	testutil.BaseTest
+
+	target *fakeTarget
+	specs  []target.Spec
+}
+
+var _ = check.Suite(&transferSuite{})
+
+func (s *transferSuite) SetUpTest(c *check.C) {
+	s.BaseTest.SetUpTest(c)
+	dirs.SetRootDir(c.MkDir())
+	c.Assert(os.MkdirAll(dirs.SnapshotsDir, 0700), check.IsNil)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+	s.target = &fakeTarget{partSize: 4}
+	s.specs = nil
+	s.AddCleanup(snapshotstate.MockTargetNew(func(spec *target.Spec) (target.Target, error) {
+		s.specs = append(s.specs, *spec)
+		if spec.URL == "bad" {
+			return nil, errors.New("bad target")
+		}
+		return s.target, nil
+	}))
+	shotfile, err := os.Create(filepath.Join(c.MkDir(), "42_foo.zip"))
+	c.Assert(err, check.IsNil)
+	s.AddCleanup(func() { shotfile.Close() })
+	s.AddCleanup(snapshotstate.MockBackendIter(func(_ context.Context, f func(*backend.Reader) error) error {
+		for _, name := range []string{"foo", "bar"} {
+			if err := f(&backend.Reader{Snapshot: client.Snapshot{SetID: 42, Snap: name}, File: shotfile}); err != nil {
+				return err
+			}
+		}
+		return nil
+	}))
+	s.AddCleanup(snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64, flags *backend.ExportFlags) (*backend.SnapshotExport, error) {
+		c.Check(setID, check.Equals, uint64(42))
+		return &backend.SnapshotExport{}, nil
+	}))
+	s.AddCleanup(snapshotstate.MockFdestateDeriveKey(mockDeriveKey))
+	s.AddCleanup(snapshotstate.MockBackendSnapshotExportStream(func(se *backend.SnapshotExport, w io.Writer) error {
+		_, err := io.WriteString(w, "exported snapshot")
+		return err
+	}))
+}
+
+var spec = &target.Spec{
+	URL:         "s3://minio/bucket/key",
+	Credentials: map[string]string{"access-key": "access", "secret-key": "secret"},
+}
+
+func (s *transferSuite) TestPush(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	snaps, ts, err := snapshotstate.Push(st, 42, spec, "")
+	c.Assert(err, check.IsNil)
+	c.Check(snaps, check.DeepEquals, []string{"foo", "bar"})
+	c.Assert(ts.Tasks(), check.HasLen, 1)
+	task := ts.Tasks()[0]
+	c.Check(task.Kind(), check.Equals, "push-snapshot")
+	c.Check(task.Summary(), check.Equals, "Push snapshot set #42 to s3://minio/bucket/key")
+
+	// the credentials are not kept in the task
+	var transfer map[string]any
+	c.Assert(task.Get("snapshot-transfer", &transfer), check.IsNil)
+	c.Check(transfer, check.DeepEquals, map[string]any{
+		"target":  map[string]any{"url": "s3://minio/bucket/key"},
+		"secrets": true,
+	})
+	c.Check(snapshotstate.TransferSecrets(task), check.DeepEquals, &snapshotstate.TransferSecretsData{
+		Credentials: map[string]string{"access-key": "access", "secret-key": "secret"},
+	})
+}
+
+func (s *transferSuite) TestPushErrors(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, _, err := snapshotstate.Push(st, 42, &target.Spec{URL: "bad"}, "")
+	c.Check(err, check.ErrorMatches, "bad target")
+
+	_, _, err = snapshotstate.Push(st, 7, spec, "")
+	c.Check(err, check.Equals, client.ErrSnapshotSetNotFound)
+
+	c.Assert(snapshotstate.SaveSnapshotKey(st, 42, []byte("a-key")), check.IsNil)
+	_, _, err = snapshotstate.Push(st, 42, spec, "")
+	c.Check(err, check.ErrorMatches, `cannot push encrypted snapshot set #42 without a passphrase`)
+	_, _, err = snapshotstate.Push(st, 42, spec, "sekrit")
+	c.Check(err, check.IsNil)
+
+	chg := st.NewChange("forget-snapshot-change", "...")
+	tsk := st.NewTask("forget-snapshot", "...")
+	tsk.SetStatus(state.DoingStatus)
+	tsk.Set("snapshot-setup", map[string]int{"set-id": 42})
+	chg.AddTask(tsk)
+	_, _, err = snapshotstate.Push(st, 42, spec, "sekrit")
+	c.Check(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change "1" is in progress`)
+}
+
+func (s *transferSuite) TestForgetConflictsWithPush(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, ts, err := snapshotstate.Push(st, 42, spec, "")
+	c.Assert(err, check.IsNil)
+	chg := st.NewChange("push-snapshot", "...")
+	chg.AddAll(ts)
+
+	_, _, err = snapshotstate.Forget(st, 42, nil)
+	c.Check(err, check.ErrorMatches, `cannot operate on snapshot set #42 while change "1" is in progress`)
+}
+
+func (s *transferSuite) pushTask(c *check.C, st *state.State, passphrase string) *state.Task {
+	_, ts, err := snapshotstate.Push(st, 42, spec, passphrase)
+	c.Assert(err, check.IsNil)
+	chg := st.NewChange("push-snapshot", "...")
+	chg.AddAll(ts)
+	return ts.Tasks()[0]
+}
+
+func (s *transferSuite) TestDoPush(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	task := s.pushTask(c, st, "")
+	st.Unlock()
+
+	c.Assert(snapshotstate.DoPush(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(string(s.target.data), check.Equals, "exported snapshot")
+
+	st.Lock()
+	defer st.Unlock()
+	var progress target.Progress
+	c.Assert(task.Get("snapshot-upload", &progress), check.IsNil)
+	c.Check(progress, check.DeepEquals, target.Progress{Size: 17, Uploaded: 17, PartSize: 4})
+	_, done, total := task.Progress()
+	c.Check(done, check.Equals, 17)
+	c.Check(total, check.Equals, 17)
+}
+
+func (s *transferSuite) TestDoPushEncrypted(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	c.Assert(snapshotstate.SaveSnapshotKey(st, 42, []byte("a-key")), check.IsNil)
+	task := s.pushTask(c, st, "sekrit")
+	st.Unlock()
+
+	var flags []*backend.ExportFlags
+	defer snapshotstate.MockBackendNewSnapshotExport(func(ctx context.Context, setID uint64, f *backend.ExportFlags) (*backend.SnapshotExport, error) {
+		flags = append(flags, f)
+		return &backend.SnapshotExport{}, nil
+	})()
+
+	c.Assert(snapshotstate.DoPush(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(flags, check.DeepEquals, []*backend.ExportFlags{{Passphrase: "sekrit", Key: []byte("a-key")}})
+}
+
+func (s *transferSuite) TestDoPushResumes(c *check.C) {
+	defer snapshotstate.MockTransferRetryDelay(0)()
+	s.target.failAt = 8
+
+	st := state.New(nil)
+	st.Lock()
+	task := s.pushTask(c, st, "")
+	st.Unlock()
+
+	err := snapshotstate.DoPush(task, &tomb.Tomb{})
+	c.Assert(err, check.FitsTypeOf, &state.Retry{})
+	c.Check(string(s.target.data), check.Equals, "exported")
+	staged := filepath.Join(dirs.SnapshotsDir, ".push-snapshot-"+task.ID()+".tar")
+	c.Check(staged, testutil.FileEquals, "exported snapshot")
+
+	// the export is not done again, the staged one is used
+	defer snapshotstate.MockBackendNewSnapshotExport(func(context.Context, uint64, *backend.ExportFlags) (*backend.SnapshotExport, error) {
+		c.Fatal("unexpected export")
+		return nil, nil
+	})()
+	c.Assert(snapshotstate.DoPush(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(string(s.target.data), check.Equals, "exported snapshot")
+
+	st.Lock()
+	defer st.Unlock()
+	var attempts int
+	c.Assert(task.Get("snapshot-transfer-attempts", &attempts), check.IsNil)
+	c.Check(attempts, check.Equals, 1)
+
+	// once the change is ready, the staged export and the credentials
+	// are dropped
+	task.SetStatus(state.DoneStatus)
+	st.Unlock()
+	err = snapshotstate.CleanupTransfer(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Check(staged, testutil.FileAbsent)
+	c.Check(snapshotstate.TransferSecrets(task), check.IsNil)
+	c.Check(s.target.aborted, check.Equals, 0)
+}
+
+func (s *transferSuite) TestDoPushAfterRestart(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	task := s.pushTask(c, st, "")
+	data, err := st.MarshalJSON()
+	st.Unlock()
+	c.Assert(err, check.IsNil)
+
+	// the credentials do not survive a restart
+	c.Check(string(data), check.Not(testutil.Contains), "access-key")
+	st, err = state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, check.IsNil)
+	st.Lock()
+	task = st.Task(task.ID())
+	st.Unlock()
+
+	err = snapshotstate.DoPush(task, &tomb.Tomb{})
+	c.Check(err, check.ErrorMatches, "cannot find credentials of snapshot transfer in memory: unexpected snapd restart")
+	c.Check(s.target.data, check.HasLen, 0)
+}
+
+func (s *transferSuite) TestDoPushGivesUp(c *check.C) {
+	defer snapshotstate.MockTransferRetryDelay(0)()
+
+	st := state.New(nil)
+	st.Lock()
+	task := s.pushTask(c, st, "")
+	st.Unlock()
+
+	for i := 1; i <= 5; i++ {
+		s.target.failAt = 4
+		err := snapshotstate.DoPush(task, &tomb.Tomb{})
+		if i < 5 {
+			c.Assert(err, check.FitsTypeOf, &state.Retry{}, check.Commentf("attempt %d", i))
+		} else {
+			c.Assert(err, check.ErrorMatches, "connection reset")
+		}
+	}
+
+	// the upload that was left behind is aborted
+	st.Lock()
+	task.SetStatus(state.ErrorStatus)
+	st.Unlock()
+	c.Assert(snapshotstate.CleanupTransfer(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(s.target.aborted, check.Equals, 1)
+}
+
+func (s *transferSuite) TestPull(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	_, err := snapshotstate.Pull(st, &target.Spec{URL: "bad"}, "")
+	c.Check(err, check.ErrorMatches, "bad target")
+
+	ts, err := snapshotstate.Pull(st, spec, "sekrit")
+	c.Assert(err, check.IsNil)
+	c.Assert(ts.Tasks(), check.HasLen, 1)
+	task := ts.Tasks()[0]
+	c.Check(task.Kind(), check.Equals, "pull-snapshot")
+	c.Check(task.Summary(), check.Equals, "Pull snapshot set from s3://minio/bucket/key")
+	var transfer map[string]any
+	c.Assert(task.Get("snapshot-transfer", &transfer), check.IsNil)
+	c.Check(transfer, check.DeepEquals, map[string]any{
+		"target":  map[string]any{"url": "s3://minio/bucket/key"},
+		"secrets": true,
+	})
+	c.Check(snapshotstate.TransferSecrets(task), check.DeepEquals, &snapshotstate.TransferSecretsData{
+		Credentials: map[string]string{"access-key": "access", "secret-key": "secret"},
+		Passphrase:  "sekrit",
+	})
+
+	// without secrets there's nothing to keep in memory
+	ts, err = snapshotstate.Pull(st, &target.Spec{URL: "https://example.com/export"}, "")
+	c.Assert(err, check.IsNil)
+	task = ts.Tasks()[0]
+	transfer = nil
+	c.Assert(task.Get("snapshot-transfer", &transfer), check.IsNil)
+	c.Check(transfer, check.DeepEquals, map[string]any{
+		"target": map[string]any{"url": "https://example.com/export"},
+	})
+	c.Check(snapshotstate.TransferSecrets(task), check.IsNil)
+}
+
+func (s *transferSuite) pullTask(c *check.C, st *state.State) *state.Task {
+	ts, err := snapshotstate.Pull(st, spec, "sekrit")
+	c.Assert(err, check.IsNil)
+	chg := st.NewChange("pull-snapshot", "...")
+	chg.AddAll(ts)
+	return ts.Tasks()[0]
+}
+
+func (s *transferSuite) TestDoPullResumes(c *check.C) {
+	defer snapshotstate.MockTransferRetryDelay(0)()
+	s.target.data = []byte("exported snapshot")
+	s.target.failAt = 8
+
+	var imported []string
+	defer snapshotstate.MockBackendImport(func(ctx context.Context, setID uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
+		data, err := io.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		imported = append(imported, string(data))
+		c.Check(flags.Passphrase, check.Equals, "sekrit")
+		return []string{"foo", "bar"}, nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := s.pullTask(c, st)
+	st.Unlock()
+
+	err := snapshotstate.DoPull(task, &tomb.Tomb{})
+	c.Assert(err, check.FitsTypeOf, &state.Retry{})
+	staged := filepath.Join(dirs.SnapshotsDir, ".pull-snapshot-"+task.ID()+".tar")
+	c.Check(staged, testutil.FileEquals, "exported")
+	c.Check(imported, check.HasLen, 0)
+
+	c.Assert(snapshotstate.DoPull(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(s.target.offsets, check.DeepEquals, []int64{0, 8})
+	c.Check(imported, check.DeepEquals, []string{"exported snapshot"})
+
+	st.Lock()
+	defer st.Unlock()
+	var apiData map[string]any
+	c.Assert(task.Change().Get("api-data", &apiData), check.IsNil)
+	c.Check(apiData, check.DeepEquals, map[string]any{
+		"set-id":     1.0,
+		"snap-names": []any{"foo", "bar"},
+	})
+
+	task.SetStatus(state.DoneStatus)
+	st.Unlock()
+	err = snapshotstate.CleanupTransfer(task, &tomb.Tomb{})
+	st.Lock()
+	c.Assert(err, check.IsNil)
+	c.Check(staged, testutil.FileAbsent)
+	c.Check(snapshotstate.TransferSecrets(task), check.IsNil)
+}
+
+func (s *transferSuite) TestDoPullStaleStagingFile(c *check.C) {
+	s.target.data = []byte("export")
+	defer snapshotstate.MockBackendImport(func(ctx context.Context, setID uint64, r io.Reader, flags *backend.ImportFlags) ([]string, []byte, error) {
+		data, err := io.ReadAll(r)
+		c.Assert(err, check.IsNil)
+		c.Check(string(data), check.Equals, "export")
+		return []string{"foo"}, nil, nil
+	})()
+
+	st := state.New(nil)
+	st.Lock()
+	task := s.pullTask(c, st)
+	st.Unlock()
+
+	staged := filepath.Join(dirs.SnapshotsDir, ".pull-snapshot-"+task.ID()+".tar")
+	c.Assert(os.WriteFile(staged, []byte("something much longer"), 0600), check.IsNil)
+
+	c.Assert(snapshotstate.DoPull(task, &tomb.Tomb{}), check.IsNil)
+	c.Check(s.target.offsets, check.DeepEquals, []int64{0})
+}
+
+func (s *transferSuite) TestDoPullNotFound(c *check.C) {
+	st := state.New(nil)
+	st.Lock()
+	task := s.pullTask(c, st)
+	st.Unlock()
+
+	err := snapshotstate.DoPull(task, &tomb.Tomb{})
+	c.Check(err, check.Equals, target.ErrNotFound)
+}
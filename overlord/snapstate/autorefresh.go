@@ -96,12 +96,23 @@ type refreshCandidate struct {
 	// Monitored signals whether this snap is currently being monitored for closure
 	// so its auto-refresh can be continued.
 	Monitored bool `json:"monitored,omitempty"`
+	// FirstSeen is when the revision was first offered for auto-refresh,
+	// from which its refresh.rollout is counted.
+	FirstSeen time.Time `json:"first-seen,omitzero"`
 }
 
 func (rc *refreshCandidate) Type() snap.Type {
 	return rc.SnapSetup.Type
 }
 
+// revision returns the revision of the candidate, if known.
+func (rc *refreshCandidate) revision() snap.Revision {
+	if rc.SideInfo == nil {
+		return snap.Revision{}
+	}
+	return rc.SnapSetup.Revision()
+}
+
 func (rc *refreshCandidate) SnapBase() string {
 	return rc.SnapSetup.Base
 }
@@ -299,7 +310,15 @@ func isStoreOnline(s *state.State) (bool, error) {
 		return false, err
 	}
 
-	return access != "offline", nil
+	if access != "offline" {
+		return true, nil
+	}
+	// snaps can still be refreshed from a local repository
+	var localRepo string
+	if err := tr.GetMaybe("core", "store.local-repository", &localRepo); err != nil {
+		return false, err
+	}
+	return localRepo != "", nil
 }
 
 // Ensure ensures that we refresh all installed snaps periodically
@@ -350,7 +369,9 @@ func (m *autoRefresh) Ensure() (err error) {
 
 	// ensure nothing is in flight already
 	if autoRefreshInFlight(m.state) {
-		return nil
+		// but don't let it spill out of the refresh window or into a
+		// refresh blackout
+		return m.deferInFlightAutoRefreshes(timeNow())
 	}
 
 	logger.Trace("ensure", "manager", "SnapManager", "func", "autoRefresh.Ensure")
@@ -416,6 +437,14 @@ func (m *autoRefresh) Ensure() (err error) {
 				// ignore error, retry the auto-refresh later
 				return nil
 			}
+			var blackoutErr *refreshBlackoutError
+			if errors.As(err, &blackoutErr) {
+				// retry in the first refresh window after the blackout
+				delta := timeutil.Next(refreshSchedule, blackoutErr.until, maxPostponement)
+				m.nextRefresh = time.Now().Add(delta)
+				logger.Noticef("Auto-refresh deferred during refresh blackout, next refresh scheduled for %s.", m.nextRefresh.Format(time.RFC3339))
+				return nil
+			}
 
 			// refreshed or hit an non-persistent network error, so reset nextRefresh
 			m.nextRefresh = time.Time{}
@@ -605,6 +634,11 @@ func (m *autoRefresh) launchAutoRefresh() error {
 	if !m.lastRefreshAttempt.IsZero() && minAttempt.After(now) {
 		return tooSoonError{}
 	}
+	if end, inBlackout, err := refreshBlackoutEnd(m.state, now); err != nil {
+		return err
+	} else if inBlackout {
+		return &refreshBlackoutError{until: end}
+	}
 	m.lastRefreshAttempt = now
 
 	perfTimings := timings.New(map[string]string{"ensure": "auto-refresh"})
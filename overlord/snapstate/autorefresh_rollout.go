@@ -0,0 +1,125 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+// Hook setup by devicestate to get the serial assertion of the device. It's
+// expected to return ErrNoState if the device is not registered yet.
+var DeviceSerial func(st *state.State) (*asserts.Serial, error)
+
+var errRolloutPending = errors.New("revision is not rolled out to the device yet")
+
+// refreshRolloutWindow returns the window over which auto-refreshes to new
+// revisions are spread across devices, as per the refresh.rollout
+// configuration, or zero if they are not.
+func refreshRolloutWindow(st *state.State) (time.Duration, error) {
+	tr := config.NewTransaction(st)
+	var rollout string
+	if err := tr.Get("core", "refresh.rollout", &rollout); err != nil && !config.IsNoOption(err) {
+		return 0, err
+	}
+	if rollout == "" {
+		return 0, nil
+	}
+	window, err := time.ParseDuration(rollout)
+	if err != nil || window < 0 {
+		// log instead of fail in order not to prevent auto-refreshes
+		logger.Noticef("cannot use refresh.rollout configuration: %q is not a valid duration", rollout)
+		return 0, nil
+	}
+	return window, nil
+}
+
+// refreshRolloutOffset returns how long into the rollout window the device
+// adopts new revisions. The offset is derived from the serial assertion of
+// the device, so that it is stable for the device and spread across the
+// devices of the fleet. Devices which are not registered adopt new revisions
+// at the end of the window.
+func refreshRolloutOffset(st *state.State, window time.Duration) (time.Duration, error) {
+	if window <= 0 {
+		return 0, nil
+	}
+	var serial *asserts.Serial
+	if DeviceSerial != nil {
+		var err error
+		serial, err = DeviceSerial(st)
+		if err != nil && !errors.Is(err, state.ErrNoState) {
+			return 0, err
+		}
+	}
+	if serial == nil {
+		return window, nil
+	}
+	h := sha256.Sum256([]byte(serial.BrandID() + "/" + serial.Model() + "/" + serial.Serial()))
+	return time.Duration(binary.BigEndian.Uint64(h[:8]) % uint64(window)), nil
+}
+
+// checkRefreshRollout checks whether the auto-refresh of the snap to the
+// target revision is to be delayed as per the refresh.rollout configuration,
+// returning errRolloutPending if so. The rollout is counted from when the
+// target revision was first offered, as recorded in its refresh candidate.
+// Candidates recorded without that time, e.g. before refresh.rollout was
+// configured, are considered first offered now and recorded as such.
+func checkRefreshRollout(st *state.State, instanceName string, targetRevision snap.Revision, opts Options) error {
+	if !opts.Flags.IsAutoRefresh {
+		return nil
+	}
+	window, err := refreshRolloutWindow(st)
+	if err != nil || window == 0 {
+		return err
+	}
+
+	now := timeNow()
+	firstSeen := now
+	var candidates map[string]*refreshCandidate
+	if err := st.Get("refresh-candidates", &candidates); err != nil && !errors.Is(err, state.ErrNoState) {
+		return err
+	}
+	if cand := candidates[instanceName]; cand != nil && cand.revision() == targetRevision {
+		if cand.FirstSeen.IsZero() {
+			cand.FirstSeen = now
+			st.Set("refresh-candidates", candidates)
+		}
+		firstSeen = cand.FirstSeen
+	}
+
+	offset, err := refreshRolloutOffset(st, window)
+	if err != nil {
+		return err
+	}
+	adoptAt := firstSeen.Add(offset)
+	if now.Before(adoptAt) {
+		logger.Noticef("snap %q auto-refresh to revision %s is staggered by refresh.rollout, next auto-refresh attempt will be after %s", instanceName, targetRevision, adoptAt.Format(time.RFC3339))
+		return errRolloutPending
+	}
+	return nil
+}
@@ -1245,6 +1245,33 @@ func (s *autoRefreshTestSuite) TestSnapStoreOffline(c *C) {
 	s.state.Unlock()
 }
 
+func (s *autoRefreshTestSuite) TestSnapStoreOfflineWithLocalRepository(c *C) {
+	s.addRefreshableSnap("foo")
+	s.AddCleanup(snapstate.MockNewLocalRepository(func(dir string) snapstate.StoreService {
+		c.Check(dir, Equals, "/media/usb")
+		return s.store
+	}))
+	s.AddCleanup(snapstate.MockProcessDelayedSecurityBackendEffects(func(st *state.State, lanes []int, joinLane int) (ts *state.TaskSet) {
+		return state.NewTaskSet(st.NewTask("process-delayed-security-backend-effects", "Process delayed backend effects"))
+	}))
+
+	setStoreAccess(s.state, "offline")
+	s.state.Lock()
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "store.local-repository", "/media/usb")
+	tr.Commit()
+	s.state.Unlock()
+
+	af := snapstate.NewAutoRefresh(s.state)
+	err := af.Ensure()
+	c.Check(err, IsNil)
+
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+	s.state.Lock()
+	c.Check(s.state.Changes(), HasLen, 1)
+	s.state.Unlock()
+}
+
 func (s *autoRefreshTestSuite) testMaybeAddRefreshInhibitNotice(c *C, markerInterfaceConnected bool, warningFallback bool) {
 	st := s.state
 	st.Lock()
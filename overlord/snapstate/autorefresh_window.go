@@ -0,0 +1,195 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/strutil"
+	"github.com/snapcore/snapd/timeutil"
+)
+
+// refreshBlackoutError is returned when an auto-refresh cannot be launched
+// because of a refresh blackout.
+type refreshBlackoutError struct {
+	until time.Time
+}
+
+func (e *refreshBlackoutError) Error() string {
+	return fmt.Sprintf("cannot auto-refresh during refresh blackout until %s", e.until.Format(time.RFC3339))
+}
+
+// refreshBlackout returns the days on which auto-refreshes are not run, as
+// per the refresh.blackout configuration.
+func refreshBlackout(st *state.State) ([]timeutil.DateRange, error) {
+	tr := config.NewTransaction(st)
+	var blackout string
+	if err := tr.Get("core", "refresh.blackout", &blackout); err != nil && !config.IsNoOption(err) {
+		return nil, err
+	}
+	if blackout == "" {
+		return nil, nil
+	}
+	ranges, err := timeutil.ParseDateRanges(blackout)
+	if err != nil {
+		// log instead of fail in order not to prevent auto-refreshes
+		logger.Noticef("cannot use refresh.blackout configuration: %v", err)
+		return nil, nil
+	}
+	return ranges, nil
+}
+
+// refreshBlackoutEnd returns when the refresh blackout the given time is in,
+// if any, ends.
+func refreshBlackoutEnd(st *state.State, t time.Time) (end time.Time, ok bool, err error) {
+	blackout, err := refreshBlackout(st)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	end, ok = timeutil.DateRangesEnd(blackout, t)
+	return end, ok, nil
+}
+
+// deferAtRefreshWindowEnd returns whether the snaps of an auto-refresh which
+// are not being refreshed yet when the refresh window ends are left for a
+// later auto-refresh, as per the refresh.window-end-behavior configuration.
+func deferAtRefreshWindowEnd(st *state.State) (bool, error) {
+	tr := config.NewTransaction(st)
+	var behavior string
+	if err := tr.Get("core", "refresh.window-end-behavior", &behavior); err != nil && !config.IsNoOption(err) {
+		return false, err
+	}
+	return behavior == "defer", nil
+}
+
+// deferAutoRefreshReason returns why auto-refreshes should not get further at
+// the given time, if they shouldn't.
+func (m *autoRefresh) deferAutoRefreshReason(now time.Time) (string, error) {
+	end, inBlackout, err := refreshBlackoutEnd(m.state, now)
+	if err != nil {
+		return "", err
+	}
+	if inBlackout {
+		return fmt.Sprintf("refresh blackout until %s", end.Format(time.RFC3339)), nil
+	}
+
+	deferAtEnd, err := deferAtRefreshWindowEnd(m.state)
+	if err != nil || !deferAtEnd {
+		return "", err
+	}
+	schedule, _, _, err := m.refreshScheduleWithDefaultsFallback()
+	if err != nil {
+		return "", err
+	}
+	if len(schedule) > 0 && !timeutil.Includes(schedule, now) {
+		return "refresh window ended", nil
+	}
+	return "", nil
+}
+
+// deferredTaskKinds are the kinds of the tasks of a snap's refresh which may
+// have run when the rest of the refresh is still deferred.
+var deferredTaskKinds = map[string]bool{
+	"prerequisites":      true,
+	"download-snap":      true,
+	"validate-snap":      true,
+	"download-component": true,
+	"validate-component": true,
+}
+
+// deferrableLanes returns the lanes of the change in which the refresh of the
+// snap did not get past downloading it.
+func deferrableLanes(chg *state.Change) []int {
+	deferrable := make(map[int]bool)
+	for _, t := range chg.Tasks() {
+		ok := false
+		switch t.Status() {
+		case state.DoStatus:
+			ok = true
+		case state.DoneStatus:
+			ok = deferredTaskKinds[t.Kind()]
+		}
+		for _, lane := range t.Lanes() {
+			if prev, seen := deferrable[lane]; !seen || prev {
+				deferrable[lane] = ok
+			}
+		}
+	}
+
+	var lanes []int
+	for lane, ok := range deferrable {
+		if ok {
+			lanes = append(lanes, lane)
+		}
+	}
+	sort.Ints(lanes)
+	return lanes
+}
+
+// deferInFlightAutoRefreshes holds the refreshes of the snaps of in-flight
+// auto-refreshes which did not get past downloading yet, if auto-refreshes
+// should not get further at the given time, leaving them for the next
+// auto-refresh.
+func (m *autoRefresh) deferInFlightAutoRefreshes(now time.Time) error {
+	reason, err := m.deferAutoRefreshReason(now)
+	if err != nil || reason == "" {
+		return err
+	}
+
+	for _, chg := range m.state.Changes() {
+		if chg.Kind() != autoRefreshChangeKind || chg.IsReady() {
+			continue
+		}
+		lanes := deferrableLanes(chg)
+		if len(lanes) == 0 {
+			continue
+		}
+
+		var deferred []string
+		for _, t := range chg.Tasks() {
+			if t.Kind() != "prerequisites" || !laneIn(t.Lanes(), lanes) {
+				continue
+			}
+			if snapsup, err := TaskSnapSetup(t); err == nil {
+				deferred = append(deferred, snapsup.InstanceName())
+			}
+		}
+		logger.Noticef("Auto-refresh of %s deferred to the next auto-refresh: %s", strutil.Quoted(deferred), reason)
+		chg.AbortLanes(lanes)
+		m.state.EnsureBefore(0)
+	}
+	return nil
+}
+
+func laneIn(lanes []int, in []int) bool {
+	for _, lane := range lanes {
+		for _, l := range in {
+			if lane == l {
+				return true
+			}
+		}
+	}
+	return false
+}
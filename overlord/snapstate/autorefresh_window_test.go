@@ -0,0 +1,219 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/snapstate"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/snap"
+)
+
+func (s *autoRefreshTestSuite) setRefreshConfig(c *C, conf map[string]any) {
+	tr := config.NewTransaction(s.state)
+	for k, v := range conf {
+		c.Assert(tr.Set("core", k, v), IsNil)
+	}
+	tr.Commit()
+}
+
+func (s *autoRefreshTestSuite) TestRefreshBlackout(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	lastRefresh := time.Now().Add(-48 * time.Hour)
+	s.state.Set("last-refresh", lastRefresh)
+	now := time.Now()
+	s.setRefreshConfig(c, map[string]any{
+		"refresh.blackout": now.Format("2006-01-02"),
+	})
+
+	af := snapstate.NewAutoRefresh(s.state)
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+
+	// no refresh
+	c.Check(s.store.ops, HasLen, 0)
+	var storedRefresh time.Time
+	c.Assert(s.state.Get("last-refresh", &storedRefresh), IsNil)
+	c.Check(storedRefresh.Equal(lastRefresh), Equals, true)
+
+	// but one is scheduled after the blackout
+	y, m, d := now.Date()
+	blackoutEnd := time.Date(y, m, d+1, 0, 0, 0, 0, now.Location())
+	c.Check(af.NextRefresh().After(blackoutEnd), Equals, true, Commentf("next refresh: %s", af.NextRefresh()))
+
+	// which is not attempted before then
+	s.state.Unlock()
+	err = af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, HasLen, 0)
+}
+
+func (s *autoRefreshTestSuite) TestRefreshBlackoutNotToday(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	s.state.Set("last-refresh", time.Now().Add(-48*time.Hour))
+	s.setRefreshConfig(c, map[string]any{
+		"refresh.blackout": time.Now().Add(-48 * time.Hour).Format("2006-01-02"),
+	})
+
+	af := snapstate.NewAutoRefresh(s.state)
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(s.store.ops, DeepEquals, []string{"list-refresh"})
+}
+
+// addInFlightAutoRefresh adds an auto-refresh change refreshing snap-a, which
+// is being linked, snap-b, which is downloaded, and snap-c, which didn't
+// start yet.
+func (s *autoRefreshTestSuite) addInFlightAutoRefresh() *state.Change {
+	chg := s.state.NewChange("auto-refresh", "...")
+	addLane := func(name string, statuses map[string]state.Status) []*state.Task {
+		lane := s.state.NewLane()
+		var tasks []*state.Task
+		for _, kind := range []string{"prerequisites", "download-snap", "link-snap"} {
+			t := s.state.NewTask(kind, "...")
+			t.Set("snap-setup", &snapstate.SnapSetup{
+				SideInfo: &snap.SideInfo{RealName: name, Revision: snap.R(2)},
+			})
+			if status, ok := statuses[kind]; ok {
+				t.SetStatus(status)
+			}
+			t.JoinLane(lane)
+			chg.AddTask(t)
+			tasks = append(tasks, t)
+		}
+		return tasks
+	}
+	addLane("snap-a", map[string]state.Status{
+		"prerequisites": state.DoneStatus,
+		"download-snap": state.DoneStatus,
+		"link-snap":     state.DoingStatus,
+	})
+	addLane("snap-b", map[string]state.Status{
+		"prerequisites": state.DoneStatus,
+		"download-snap": state.DoneStatus,
+	})
+	addLane("snap-c", nil)
+	return chg
+}
+
+func linkStatuses(chg *state.Change) []state.Status {
+	var statuses []state.Status
+	for _, t := range chg.Tasks() {
+		if t.Kind() == "link-snap" {
+			statuses = append(statuses, t.Status())
+		}
+	}
+	return statuses
+}
+
+func (s *autoRefreshTestSuite) TestInFlightAutoRefreshDeferredDuringBlackout(c *C) {
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.addInFlightAutoRefresh()
+
+	af := snapstate.NewAutoRefresh(s.state)
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(linkStatuses(chg), DeepEquals, []state.Status{state.DoingStatus, state.DoStatus, state.DoStatus})
+
+	s.setRefreshConfig(c, map[string]any{
+		"refresh.blackout": time.Now().Format("2006-01-02"),
+	})
+	s.state.Unlock()
+	err = af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+
+	// snap-a is refreshed still, snap-b and snap-c are left for later
+	c.Check(linkStatuses(chg), DeepEquals, []state.Status{state.DoingStatus, state.HoldStatus, state.HoldStatus})
+	c.Check(logbuf.String(), Matches, `(?s).*Auto-refresh of "snap-b", "snap-c" deferred to the next auto-refresh: refresh blackout until .*`)
+	c.Check(s.store.ops, HasLen, 0)
+}
+
+func (s *autoRefreshTestSuite) TestInFlightAutoRefreshDeferredAtWindowEnd(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.addInFlightAutoRefresh()
+
+	// the refresh window is tomorrow
+	tomorrow := strings.ToLower(time.Now().Add(24 * time.Hour).Weekday().String()[:3])
+	s.setRefreshConfig(c, map[string]any{
+		"refresh.timer": tomorrow + ",00:00-23:59",
+	})
+
+	af := snapstate.NewAutoRefresh(s.state)
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	// refreshes continue past the window by default
+	c.Check(linkStatuses(chg), DeepEquals, []state.Status{state.DoingStatus, state.DoStatus, state.DoStatus})
+
+	s.setRefreshConfig(c, map[string]any{
+		"refresh.window-end-behavior": "defer",
+	})
+	s.state.Unlock()
+	err = af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(linkStatuses(chg), DeepEquals, []state.Status{state.DoingStatus, state.HoldStatus, state.HoldStatus})
+}
+
+func (s *autoRefreshTestSuite) TestInFlightAutoRefreshNotDeferredInWindow(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	chg := s.addInFlightAutoRefresh()
+
+	today := strings.ToLower(time.Now().Weekday().String()[:3])
+	s.setRefreshConfig(c, map[string]any{
+		"refresh.timer":               today + ",00:00-24:00",
+		"refresh.window-end-behavior": "defer",
+	})
+
+	af := snapstate.NewAutoRefresh(s.state)
+	s.state.Unlock()
+	err := af.Ensure()
+	s.state.Lock()
+	c.Check(err, IsNil)
+	c.Check(linkStatuses(chg), DeepEquals, []state.Status{state.DoingStatus, state.DoStatus, state.DoStatus})
+}
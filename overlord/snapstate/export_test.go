@@ -69,6 +69,17 @@ func SetSnapManagerBackend(s *SnapManager, b ManagerBackend) {
 	s.backend = b
 }
 
+type PeerCacheStore = peerCacheStore
+type PeerCacheService = peerCacheService
+
+func MockNewPeerCache(f func(sto PeerCacheStore) PeerCacheService) (restore func()) {
+	return testutil.Mock(&newPeerCache, f)
+}
+
+func MockNewLocalRepository(f func(dir string) StoreService) (restore func()) {
+	return testutil.Mock(&newLocalRepository, f)
+}
+
 func MockSnapReadInfo(mock func(name string, si *snap.SideInfo) (*snap.Info, error)) (restore func()) {
 	old := snapReadInfo
 	snapReadInfo = mock
@@ -642,3 +653,13 @@ var ArrangeInstallTasksForSingleReboot = arrangeInstallTasksForSingleReboot
 func MockProcessDelayedSecurityBackendEffects(f func(st *state.State, lanes []int, joinLane int) (ts *state.TaskSet)) (restore func()) {
 	return testutil.Mock(&ProcessDelayedSecurityBackendEffects, f)
 }
+
+var (
+	RefreshRolloutOffset = refreshRolloutOffset
+	CheckRefreshRollout  = checkRefreshRollout
+	ErrRolloutPending    = errRolloutPending
+)
+
+func MockDeviceSerial(f func(st *state.State) (*asserts.Serial, error)) (restore func()) {
+	return testutil.Mock(&DeviceSerial, f)
+}
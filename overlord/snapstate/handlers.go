@@ -5034,6 +5034,22 @@ func (m *SnapManager) doConditionalAutoRefresh(t *state.Task, tomb *tomb.Tomb) e
 		return nil
 	}
 
+	// the gating hooks may have run past the refresh window or into a
+	// refresh blackout, leave the snaps for the next auto-refresh then
+	reason, err := m.autoRefresh.deferAutoRefreshReason(timeNow())
+	if err != nil {
+		return err
+	}
+	if reason != "" {
+		names := make([]string, 0, len(snaps))
+		for _, candidate := range snaps {
+			names = append(names, candidate.InstanceName())
+		}
+		sort.Strings(names)
+		logger.Noticef("Auto-refresh of %s deferred to the next auto-refresh: %s", strutil.Quoted(names), reason)
+		return nil
+	}
+
 	updateTss, err := autoRefreshPhase2(st, snaps, nil, t.Change().ID())
 	if err != nil {
 		return err
@@ -199,6 +199,8 @@ func (m *SnapManager) doDownloadComponent(t *state.Task, tomb *tomb.Tomb) error
 
 	st.Unlock()
 	timings.Run(perf, "download", fmt.Sprintf("download component %q", compsup.ComponentName()), func(timings.Measurer) {
+		// deltas are applied to the component blob of the snap
+		// instance, which the store derives from the target
 		compRef := compsup.CompSideInfo.Component.String()
 		opts := &store.DownloadOptions{
 			Scheduled: snapsup.IsAutoRefresh,
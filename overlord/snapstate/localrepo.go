@@ -0,0 +1,103 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"context"
+	"io"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/overlord/configstate/config"
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/localstore"
+)
+
+type localRepositoryKey struct{}
+
+type cachedLocalRepository struct {
+	dir string
+	sto *localRepositoryStore
+}
+
+var newLocalRepository = func(dir string) StoreService {
+	return localstore.New(dir)
+}
+
+// localRepository returns the store reading from the local repository set
+// with the store.local-repository option on top of the given store, or nil
+// if the option is unset.
+func localRepository(st *state.State, sto StoreService) StoreService {
+	tr := config.NewTransaction(st)
+	var dir string
+	if err := tr.GetMaybe("core", "store.local-repository", &dir); err != nil {
+		logger.Noticef("cannot read store.local-repository option: %v", err)
+		return nil
+	}
+	if dir == "" {
+		return nil
+	}
+
+	if cached, ok := st.Cached(localRepositoryKey{}).(*cachedLocalRepository); ok && cached.dir == dir && cached.sto.StoreService == sto {
+		return cached.sto
+	}
+	localSto := &localRepositoryStore{
+		StoreService: sto,
+		local:        newLocalRepository(dir),
+	}
+	st.Cache(localRepositoryKey{}, &cachedLocalRepository{dir: dir, sto: localSto})
+	return localSto
+}
+
+// localRepositoryStore gets the snaps to install or refresh, as well as
+// their assertions, from the local repository, while searching for snaps,
+// the catalog, logging in and buying are still served by the store.
+type localRepositoryStore struct {
+	StoreService
+	local StoreService
+}
+
+func (s *localRepositoryStore) SnapAction(ctx context.Context, currentSnaps []*store.CurrentSnap, actions []*store.SnapAction, assertQuery store.AssertionQuery, user *auth.UserState, opts *store.RefreshOptions) ([]store.SnapActionResult, []store.AssertionResult, error) {
+	return s.local.SnapAction(ctx, currentSnaps, actions, assertQuery, user, opts)
+}
+
+func (s *localRepositoryStore) Download(ctx context.Context, name string, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) error {
+	return s.local.Download(ctx, name, targetPath, downloadInfo, pbar, user, dlOpts)
+}
+
+func (s *localRepositoryStore) DownloadStream(ctx context.Context, name string, downloadInfo *snap.DownloadInfo, resume int64, user *auth.UserState) (io.ReadCloser, int, error) {
+	return s.local.DownloadStream(ctx, name, downloadInfo, resume, user)
+}
+
+func (s *localRepositoryStore) Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error) {
+	return s.local.Assertion(assertType, primaryKey, user)
+}
+
+func (s *localRepositoryStore) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+	return s.local.SeqFormingAssertion(assertType, sequenceKey, sequence, user)
+}
+
+func (s *localRepositoryStore) DownloadAssertions(streamURLs []string, b *asserts.Batch, user *auth.UserState) error {
+	return s.local.DownloadAssertions(streamURLs, b, user)
+}
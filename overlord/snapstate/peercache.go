@@ -0,0 +1,58 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package snapstate
+
+import (
+	"net/http"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/peercache"
+)
+
+// peerCacheStore is implemented by stores that can share their download
+// cache with peers on the local network and fetch blobs from them.
+type peerCacheStore interface {
+	SetPeerFinder(f store.PeerFinder)
+	PeerCacheHandler() http.Handler
+}
+
+// peerCacheService serves the download cache to peers and finds theirs.
+type peerCacheService interface {
+	store.PeerFinder
+	Start() error
+	Stop() error
+}
+
+var newPeerCache = func(sto peerCacheStore) peerCacheService {
+	return peercache.New(sto, nil)
+}
+
+func (m *SnapManager) stopPeerCache() {
+	if m.peerCache == nil {
+		return
+	}
+	m.peerCacheStore.SetPeerFinder(nil)
+	if err := m.peerCache.Stop(); err != nil {
+		logger.Noticef("cannot stop sharing downloads cache with peers: %v", err)
+	}
+	m.peerCache = nil
+	m.peerCacheStore = nil
+}
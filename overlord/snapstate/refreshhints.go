@@ -292,6 +292,23 @@ func updateRefreshCandidates(st *state.State, hints map[string]*refreshCandidate
 		}
 	}
 
+	// keep track of when the revisions were first offered, from which
+	// their refresh.rollout is counted
+	window, err := refreshRolloutWindow(st)
+	if err != nil {
+		return err
+	}
+	if window > 0 {
+		now := timeNow()
+		for name, hint := range hints {
+			if old := oldHints[name]; old != nil && old.revision() == hint.revision() && !old.FirstSeen.IsZero() {
+				hint.FirstSeen = old.FirstSeen
+			} else {
+				hint.FirstSeen = now
+			}
+		}
+	}
+
 	if len(oldHints) == 0 {
 		st.Set("refresh-candidates", hints)
 		return nil
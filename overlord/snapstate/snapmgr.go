@@ -20,6 +20,7 @@
 package snapstate
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -35,6 +36,7 @@ import (
 	"github.com/snapcore/snapd/i18n"
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/configstate/config"
 	"github.com/snapcore/snapd/overlord/snapstate/backend"
 	"github.com/snapcore/snapd/overlord/snapstate/sequence"
 	"github.com/snapcore/snapd/overlord/state"
@@ -68,6 +70,7 @@ func init() {
 	swfeats.RegisterEnsure("SnapManager", "ensureDesktopFilesUpdated")
 	swfeats.RegisterEnsure("SnapManager", "ensureDownloadsCleaned")
 	swfeats.RegisterEnsure("SnapManager", "ensureStoreDownloadsCacheCleaned")
+	swfeats.RegisterEnsure("SnapManager", "ensurePeerCache")
 
 	RegisterResealingTaskKind("prepare-kernel-modules-components")
 	// TODO: consider registering these on classic only if the system is an hybrid system
@@ -92,6 +95,11 @@ type SnapManager struct {
 	ensuredDownloadsCleaned    bool
 	ensureStoreCacheCleanNext  time.Time
 
+	// peerCache shares the download cache of peerCacheStore on the
+	// local network
+	peerCache      peerCacheService
+	peerCacheStore peerCacheStore
+
 	changeCallbackID int
 }
 
@@ -623,7 +631,7 @@ var snapReadInfo = snap.ReadInfo
 // AutomaticSnapshot allows to hook snapshot manager's AutomaticSnapshot.
 var AutomaticSnapshot func(st *state.State, instanceName string) (ts *state.TaskSet, err error)
 var AutomaticSnapshotExpiration func(st *state.State) (time.Duration, error)
-var EstimateSnapshotSize func(st *state.State, instanceName string, users []string) (uint64, error)
+var EstimateSnapshotSize func(ctx context.Context, st *state.State, instanceName string, users []string) (uint64, error)
 
 func readInfo(name string, si *snap.SideInfo, flags int) (*snap.Info, error) {
 	info, err := snapReadInfo(name, si)
@@ -795,9 +803,10 @@ func cachedStore(st *state.State) StoreService {
 // the store implementation has the interface consumed here
 var _ StoreService = (*store.Store)(nil)
 
-// Store returns the store service provided by the optional device context or
-// the one used by the snapstate package if the former has no
-// override.
+// Store returns the store service provided by the optional device context or,
+// if the former has no override, the one used by the snapstate package. The
+// latter serves snaps and assertions from the local repository set with the
+// store.local-repository option, if any.
 func Store(st *state.State, deviceCtx DeviceContext) StoreService {
 	if deviceCtx != nil {
 		sto := deviceCtx.Store()
@@ -806,6 +815,9 @@ func Store(st *state.State, deviceCtx DeviceContext) StoreService {
 		}
 	}
 	if cachedStore := cachedStore(st); cachedStore != nil {
+		if localRepo := localRepository(st, cachedStore); localRepo != nil {
+			return localRepo
+		}
 		return cachedStore
 	}
 	panic("internal error: needing the store before managers have initialized it")
@@ -953,6 +965,7 @@ func (m *SnapManager) Stop() {
 	defer st.Unlock()
 
 	st.RemoveChangeStatusChangedHandler(m.changeCallbackID)
+	m.stopPeerCache()
 }
 
 func (m *SnapManager) CanStandby() bool {
@@ -1678,6 +1691,60 @@ func (m *SnapManager) ensureStoreDownloadsCacheCleaned() error {
 	return nil
 }
 
+// peerCacheEnabled returns whether sharing the download cache with the
+// devices on the local network was opted into by setting store.peer-cache to
+// true. Any other value keeps it disabled.
+func peerCacheEnabled(st *state.State) (bool, error) {
+	tr := config.NewTransaction(st)
+	var value any
+	if err := tr.GetMaybe("core", "store.peer-cache", &value); err != nil {
+		return false, err
+	}
+	switch value {
+	case true, "true":
+		return true, nil
+	}
+	return false, nil
+}
+
+// ensurePeerCache starts or stops sharing the download cache with the
+// devices on the local network, following the store.peer-cache option.
+func (m *SnapManager) ensurePeerCache() error {
+	m.state.Lock()
+	defer m.state.Unlock()
+
+	enabled, err := peerCacheEnabled(m.state)
+	if err != nil {
+		return err
+	}
+
+	var sto peerCacheStore
+	if enabled {
+		sto, _ = Store(m.state, nil).(peerCacheStore)
+	}
+	if sto != nil && sto == m.peerCacheStore {
+		return nil
+	}
+
+	logger.Trace("ensure", "manager", "SnapManager", "func", "ensurePeerCache")
+
+	m.stopPeerCache()
+	if sto == nil {
+		return nil
+	}
+
+	pc := newPeerCache(sto)
+	if err := pc.Start(); err != nil {
+		// not fatal, downloads go to the store, try again later
+		logger.Noticef("cannot share downloads cache with peers: %v", err)
+		return nil
+	}
+	sto.SetPeerFinder(pc)
+	m.peerCache = pc
+	m.peerCacheStore = sto
+	return nil
+}
+
 // Ensure implements StateManager.Ensure.
 func (m *SnapManager) Ensure() error {
 	if m.preseed {
@@ -1701,6 +1768,7 @@ func (m *SnapManager) Ensure() error {
 		m.ensureDesktopFilesUpdated(),
 		m.ensureDownloadsCleaned(),
 		m.ensureStoreDownloadsCacheCleaned(),
+		m.ensurePeerCache(),
 	}
 
 	//FIXME: use firstErr helper
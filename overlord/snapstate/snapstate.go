@@ -1501,7 +1501,7 @@ func (u *update) satisfied() (bool, error) {
 }
 
 func doPotentiallySplitUpdate(st *state.State, requested []string, updates []update, opts Options) ([]string, *UpdateTaskSets, error) {
-	if opts.Flags.Transaction == client.TransactionAllSnaps && opts.Flags.Lane == 0 {
+	if opts.Flags.Transaction == client.TransactionAllSnaps && opts.Flags.Lane == 0 && !opts.DryRun {
 		opts.Flags.Lane = st.NewLane()
 	}
 
@@ -1623,6 +1623,13 @@ func doUpdate(st *state.State, requested []string, updates []update, opts Option
 			}
 			return nil, false, nil, err
 		}
+		if err := checkRefreshRollout(st, up.Setup.InstanceName(), up.Setup.Revision(), opts); err != nil {
+			if errors.Is(err, errRolloutPending) {
+				// revision not rolled out to this device yet
+				continue
+			}
+			return nil, false, nil, err
+		}
 
 		// keep track of any snaps that we requested to refresh actually got
 		// their revisions changed. if any did, pass that up to the caller so
@@ -1639,7 +1646,9 @@ func doUpdate(st *state.State, requested []string, updates []update, opts Option
 		if err != nil {
 			if errors.Is(err, &timedBusySnapError{}) && sts.ts != nil {
 				// snap is busy and pre-download tasks were made for it
-				sts.ts.JoinLane(st.NewLane())
+				if !opts.DryRun {
+					sts.ts.JoinLane(st.NewLane())
+				}
 				predownloadTSS = append(predownloadTSS, sts.ts)
 				continue
 			}
@@ -3294,7 +3303,7 @@ func removeTasks(st *state.State, name string, revision snap.Revision, flags *Re
 					return nil, 0, err
 				}
 				if checkDiskSpaceRemove {
-					snapshotSize, err = EstimateSnapshotSize(st, name, nil)
+					snapshotSize, err = EstimateSnapshotSize(context.TODO(), st, name, nil)
 					if err != nil {
 						return nil, 0, err
 					}
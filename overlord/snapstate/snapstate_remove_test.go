@@ -20,6 +20,7 @@
 package snapstate_test
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"os"
@@ -1405,7 +1406,7 @@ func (s *snapmgrTestSuite) testRemoveManyDiskSpaceCheck(c *C, featureFlag, autom
 	var checkFreeSpaceCall, snapshotSizeCall int
 
 	// restored by TearDownTest
-	snapstate.EstimateSnapshotSize = func(st *state.State, instanceName string, users []string) (uint64, error) {
+	snapstate.EstimateSnapshotSize = func(ctx context.Context, st *state.State, instanceName string, users []string) (uint64, error) {
 		snapshotSizeCall++
 		// expect two snapshot size estimations
 		switch instanceName {
@@ -24,6 +24,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
 	"path"
 	"path/filepath"
@@ -65,6 +67,7 @@ import (
 	"github.com/snapcore/snapd/overlord/snapstate/sequence"
 	"github.com/snapcore/snapd/overlord/snapstate/snapstatetest"
 	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/progress"
 	"github.com/snapcore/snapd/release"
 	"github.com/snapcore/snapd/sandbox"
 	"github.com/snapcore/snapd/snap"
@@ -72,6 +75,7 @@ import (
 	"github.com/snapcore/snapd/snap/snaptest"
 	"github.com/snapcore/snapd/snapdenv"
 	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/storetest"
 	"github.com/snapcore/snapd/systemd"
 	"github.com/snapcore/snapd/testutil"
 	"github.com/snapcore/snapd/timeutil"
@@ -286,7 +290,7 @@ func (s *snapmgrBaseTest) SetUpTest(c *C) {
 	}))
 
 	oldEstimateSnapshotSize := snapstate.EstimateSnapshotSize
-	snapstate.EstimateSnapshotSize = func(st *state.State, instanceName string, users []string) (uint64, error) {
+	snapstate.EstimateSnapshotSize = func(ctx context.Context, st *state.State, instanceName string, users []string) (uint64, error) {
 		return 1, nil
 	}
 	restoreInstallSize := snapstate.MockInstallSize(func(st *state.State, snaps []snapstate.MinimalInstallInfo, userID int, prqt snapstate.PrereqTracker) (uint64, error) {
@@ -545,6 +549,100 @@ func (s *snapmgrTestSuite) TestStoreWithDeviceContext(c *C) {
 	c.Check(store3, Equals, stoB)
 }
 
+type recordingRepoStore struct {
+	storetest.Store
+
+	name  string
+	calls *[]string
+}
+
+func (r *recordingRepoStore) Find(context.Context, *store.Search, *auth.UserState) ([]*snap.Info, error) {
+	*r.calls = append(*r.calls, r.name+":find")
+	return nil, nil
+}
+
+func (r *recordingRepoStore) SnapAction(context.Context, []*store.CurrentSnap, []*store.SnapAction, store.AssertionQuery, *auth.UserState, *store.RefreshOptions) ([]store.SnapActionResult, []store.AssertionResult, error) {
+	*r.calls = append(*r.calls, r.name+":snap-action")
+	return nil, nil, nil
+}
+
+func (r *recordingRepoStore) Download(context.Context, string, string, *snap.DownloadInfo, progress.Meter, *auth.UserState, *store.DownloadOptions) error {
+	*r.calls = append(*r.calls, r.name+":download")
+	return nil
+}
+
+func (r *recordingRepoStore) WriteCatalogs(context.Context, io.Writer, store.SnapAdder) error {
+	*r.calls = append(*r.calls, r.name+":write-catalogs")
+	return nil
+}
+
+func (s *snapmgrTestSuite) TestStoreWithLocalRepository(c *C) {
+	var calls []string
+	var dirs []string
+	restore := snapstate.MockNewLocalRepository(func(dir string) snapstate.StoreService {
+		dirs = append(dirs, dir)
+		return &recordingRepoStore{name: dir, calls: &calls}
+	})
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	sto := &recordingRepoStore{name: "store", calls: &calls}
+	snapstate.ReplaceStore(s.state, sto)
+	c.Check(snapstate.Store(s.state, nil), Equals, sto)
+
+	setLocalRepository := func(dir string) {
+		tr := config.NewTransaction(s.state)
+		c.Assert(tr.Set("core", "store.local-repository", dir), IsNil)
+		tr.Commit()
+	}
+
+	setLocalRepository("/media/usb")
+	local1 := snapstate.Store(s.state, nil)
+	c.Check(local1, Not(Equals), sto)
+	// cached
+	c.Check(snapstate.Store(s.state, nil), Equals, local1)
+	c.Check(dirs, DeepEquals, []string{"/media/usb"})
+
+	// snaps come from the local repository, everything else from the store
+	ctx := context.Background()
+	_, _, err := local1.SnapAction(ctx, nil, nil, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Assert(local1.Download(ctx, "foo", "/target", nil, nil, nil, nil), IsNil)
+	_, err = local1.Find(ctx, &store.Search{Query: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Assert(local1.WriteCatalogs(ctx, io.Discard, nil), IsNil)
+	c.Check(calls, DeepEquals, []string{
+		"/media/usb:snap-action",
+		"/media/usb:download",
+		"store:find",
+		"store:write-catalogs",
+	})
+
+	// the store from the device context still wins
+	stoB := &store.Store{}
+	c.Check(snapstate.Store(s.state, &snapstatetest.TrivialDeviceContext{CtxStore: stoB}), Equals, stoB)
+
+	setLocalRepository("/media/other")
+	local2 := snapstate.Store(s.state, nil)
+	c.Check(local2, Not(Equals), local1)
+	c.Check(dirs, DeepEquals, []string{"/media/usb", "/media/other"})
+
+	// replacing the store sets up the local repository on top of the new one
+	stoC := &recordingRepoStore{name: "other-store", calls: &calls}
+	snapstate.ReplaceStore(s.state, stoC)
+	local3 := snapstate.Store(s.state, nil)
+	c.Check(local3, Not(Equals), local2)
+	calls = nil
+	_, err = local3.Find(ctx, &store.Search{Query: "foo"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(calls, DeepEquals, []string{"other-store:find"})
+
+	setLocalRepository("")
+	c.Check(snapstate.Store(s.state, nil), Equals, stoC)
+}
+
 func (s *snapmgrTestSuite) TestUserFromUserID(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -4148,6 +4246,161 @@ func (s *snapmgrTestSuite) TestEnsureSnapStoreCacheCleanBusy(c *C) {
 	c.Check(snapstate.GetStoreCacheCleanNext(s.snapmgr), Equals, now.Add(24*time.Hour))
 }
 
+type peerFakeStore struct {
+	fakeStore
+
+	finder store.PeerFinder
+}
+
+func (f *peerFakeStore) SetPeerFinder(finder store.PeerFinder) {
+	f.finder = finder
+}
+
+func (f *peerFakeStore) PeerCacheHandler() http.Handler {
+	return http.NotFoundHandler()
+}
+
+type fakePeerCache struct {
+	sto      snapstate.PeerCacheStore
+	started  bool
+	stopped  bool
+	startErr error
+}
+
+func (f *fakePeerCache) Peers(ctx context.Context) []string { return nil }
+
+func (f *fakePeerCache) Start() error {
+	if f.startErr != nil {
+		return f.startErr
+	}
+	f.started = true
+	return nil
+}
+
+func (f *fakePeerCache) Stop() error {
+	f.stopped = true
+	return nil
+}
+
+func (s *snapmgrTestSuite) TestEnsurePeerCache(c *C) {
+	var caches []*fakePeerCache
+	var startErr error
+	restore := snapstate.MockNewPeerCache(func(sto snapstate.PeerCacheStore) snapstate.PeerCacheService {
+		pc := &fakePeerCache{sto: sto, startErr: startErr}
+		caches = append(caches, pc)
+		return pc
+	})
+	defer restore()
+	logbuf, restore := logger.MockLogger()
+	defer restore()
+
+	sto := &peerFakeStore{}
+	s.state.Lock()
+	snapstate.ReplaceStore(s.state, sto)
+	s.state.Unlock()
+
+	setPeerCache := func(enabled bool) {
+		s.state.Lock()
+		defer s.state.Unlock()
+		tr := config.NewTransaction(s.state)
+		c.Assert(tr.Set("core", "store.peer-cache", enabled), IsNil)
+		tr.Commit()
+	}
+
+	// not enabled by default
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	c.Check(caches, HasLen, 0)
+
+	setPeerCache(true)
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	c.Assert(caches, HasLen, 1)
+	c.Check(caches[0].started, Equals, true)
+	c.Check(caches[0].sto, Equals, sto)
+	c.Check(sto.finder, Equals, caches[0])
+
+	// nothing to do on the next ensure
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	c.Check(caches, HasLen, 1)
+
+	// a new store gets its own peer cache
+	newSto := &peerFakeStore{}
+	s.state.Lock()
+	snapstate.ReplaceStore(s.state, newSto)
+	s.state.Unlock()
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	c.Assert(caches, HasLen, 2)
+	c.Check(caches[0].stopped, Equals, true)
+	c.Check(sto.finder, IsNil)
+	c.Check(caches[1].started, Equals, true)
+	c.Check(newSto.finder, Equals, caches[1])
+
+	setPeerCache(false)
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	c.Check(caches, HasLen, 2)
+	c.Check(caches[1].stopped, Equals, true)
+	c.Check(newSto.finder, IsNil)
+
+	// failing to start is not fatal and retried
+	startErr = errors.New("boom")
+	setPeerCache(true)
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	c.Check(caches, HasLen, 3)
+	c.Check(newSto.finder, IsNil)
+	c.Check(logbuf.String(), testutil.Contains, "cannot share downloads cache with peers: boom")
+
+	startErr = nil
+	c.Assert(s.snapmgr.Ensure(), IsNil)
+	c.Assert(caches, HasLen, 4)
+	c.Check(newSto.finder, Equals, caches[3])
+
+	// and stopped with the manager
+	s.snapmgr.Stop()
+	c.Check(caches[3].stopped, Equals, true)
+	c.Check(newSto.finder, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestEnsurePeerCacheExplicitOptIn(c *C) {
+	var caches []*fakePeerCache
+	restore := snapstate.MockNewPeerCache(func(sto snapstate.PeerCacheStore) snapstate.PeerCacheService {
+		pc := &fakePeerCache{sto: sto}
+		caches = append(caches, pc)
+		return pc
+	})
+	defer restore()
+
+	s.state.Lock()
+	snapstate.ReplaceStore(s.state, &peerFakeStore{})
+	s.state.Unlock()
+
+	for _, tc := range []struct {
+		value   any
+		enabled bool
+	}{
+		{nil, false},
+		{false, false},
+		{"false", false},
+		{"", false},
+		{"yes", false},
+		{true, true},
+		{"true", true},
+	} {
+		caches = nil
+		s.state.Lock()
+		tr := config.NewTransaction(s.state)
+		c.Assert(tr.Set("core", "store.peer-cache", tc.value), IsNil)
+		tr.Commit()
+		s.state.Unlock()
+
+		c.Assert(s.snapmgr.Ensure(), IsNil)
+		if tc.enabled {
+			c.Check(caches, HasLen, 1, Commentf("%v", tc.value))
+		} else {
+			c.Check(caches, HasLen, 0, Commentf("%v", tc.value))
+		}
+		s.snapmgr.Stop()
+	}
+}
+
 func (s *snapmgrTestSuite) verifyRefreshLast(c *C) {
 	var lastRefresh time.Time
 
@@ -10751,6 +11004,220 @@ func (s *snapmgrTestSuite) TestBackoffOnAutoRefreshWithNewRevision(c *C) {
 	c.Assert(snapst.RefreshFailures, IsNil)
 }
 
+func (s *snapmgrTestSuite) makeSerial(c *C, serialN string) *asserts.Serial {
+	signing := assertstest.NewStoreStack("can0nical", nil)
+	devKey, _ := assertstest.GenerateKey(752)
+	encDevKey, err := asserts.EncodePublicKey(devKey.PublicKey())
+	c.Assert(err, IsNil)
+	serial, err := signing.Sign(asserts.SerialType, map[string]any{
+		"authority-id":        "can0nical",
+		"brand-id":            "can0nical",
+		"model":               "my-model",
+		"serial":              serialN,
+		"device-key":          string(encDevKey),
+		"device-key-sha3-384": devKey.PublicKey().ID(),
+		"timestamp":           time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	return serial.(*asserts.Serial)
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutOffset(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	const window = 72 * time.Hour
+
+	// not registered devices adopt new revisions at the end of the window
+	restore := snapstate.MockDeviceSerial(func(st *state.State) (*asserts.Serial, error) {
+		return nil, state.ErrNoState
+	})
+	defer restore()
+	offset, err := snapstate.RefreshRolloutOffset(s.state, window)
+	c.Assert(err, IsNil)
+	c.Check(offset, Equals, window)
+
+	offsets := make(map[time.Duration]bool)
+	for _, serialN := range []string{"serial-1", "serial-2", "serial-3"} {
+		serial := s.makeSerial(c, serialN)
+		restore := snapstate.MockDeviceSerial(func(st *state.State) (*asserts.Serial, error) {
+			return serial, nil
+		})
+		defer restore()
+
+		offset, err := snapstate.RefreshRolloutOffset(s.state, window)
+		c.Assert(err, IsNil)
+		c.Check(offset >= 0 && offset < window, Equals, true, Commentf("offset: %s", offset))
+		// stable for the device
+		again, err := snapstate.RefreshRolloutOffset(s.state, window)
+		c.Assert(err, IsNil)
+		c.Check(again, Equals, offset)
+		offsets[offset] = true
+	}
+	// and spread across devices
+	c.Check(offsets, HasLen, 3)
+
+	// no window, no offset
+	offset, err = snapstate.RefreshRolloutOffset(s.state, 0)
+	c.Assert(err, IsNil)
+	c.Check(offset, Equals, time.Duration(0))
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutOnAutoRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	now := time.Now()
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+	restore = snapstate.MockDeviceSerial(func(st *state.State) (*asserts.Serial, error) {
+		return nil, state.ErrNoState
+	})
+	defer restore()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.rollout", "72h"), IsNil)
+	tr.Commit()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		}),
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+	snapstate.Set(s.state, "some-other-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+			{RealName: "some-other-snap", SnapID: "some-other-snap-id", Revision: snap.R(1)},
+		}),
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+	// some-other-snap was offered since before the window
+	s.state.Set("refresh-candidates", map[string]*snapstate.RefreshCandidate{
+		"some-other-snap": {
+			SnapSetup: snapstate.SnapSetup{
+				SideInfo: &snap.SideInfo{RealName: "some-other-snap", SnapID: "some-other-snap-id", Revision: snap.R(11)},
+			},
+			FirstSeen: now.Add(-73 * time.Hour),
+		},
+	})
+	candidate := func(name string) *snapstate.RefreshCandidate {
+		var candidates map[string]*snapstate.RefreshCandidate
+		c.Assert(s.state.Get("refresh-candidates", &candidates), IsNil)
+		c.Assert(candidates[name], NotNil)
+		return candidates[name]
+	}
+
+	s.fakeStore.refreshRevnos["some-snap-id"] = snap.R(12)
+	names, _, err := snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	// some-snap auto-refresh staggered
+	c.Check(names, DeepEquals, []string{"some-other-snap"})
+
+	c.Check(candidate("some-snap").Revision(), Equals, snap.R(12))
+	c.Check(candidate("some-snap").FirstSeen.Equal(now), Equals, true)
+	c.Check(candidate("some-other-snap").FirstSeen.Equal(now.Add(-73*time.Hour)), Equals, true)
+	firstSeen := now
+
+	// the snap state is not modified
+	var snapst snapstate.SnapState
+	c.Assert(snapstate.Get(s.state, "some-snap", &snapst), IsNil)
+	c.Check(snapst.Current, Equals, snap.R(1))
+
+	// still staggered a day later, when first seen is kept
+	now = now.Add(24 * time.Hour)
+	names, _, err = snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-other-snap"})
+	c.Check(candidate("some-snap").FirstSeen.Equal(firstSeen), Equals, true)
+
+	// but not once the window passed
+	now = now.Add(48 * time.Hour)
+	names, _, err = snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-other-snap", "some-snap"})
+
+	// a newer revision restarts the rollout
+	s.fakeStore.refreshRevnos["some-snap-id"] = snap.R(13)
+	names, _, err = snapstate.AutoRefresh(context.Background(), s.state)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-other-snap"})
+	c.Check(candidate("some-snap").Revision(), Equals, snap.R(13))
+	c.Check(candidate("some-snap").FirstSeen.Equal(now), Equals, true)
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutRecordsFirstSeen(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	now := time.Now()
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+	restore = snapstate.MockDeviceSerial(func(st *state.State) (*asserts.Serial, error) {
+		return nil, state.ErrNoState
+	})
+	defer restore()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.rollout", "72h"), IsNil)
+	tr.Commit()
+
+	// the candidate was recorded before refresh.rollout was configured
+	s.state.Set("refresh-candidates", map[string]*snapstate.RefreshCandidate{
+		"some-snap": {
+			SnapSetup: snapstate.SnapSetup{
+				SideInfo: &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(11)},
+			},
+		},
+	})
+	opts := snapstate.Options{Flags: snapstate.Flags{IsAutoRefresh: true}}
+
+	err := snapstate.CheckRefreshRollout(s.state, "some-snap", snap.R(11), opts)
+	c.Check(err, Equals, snapstate.ErrRolloutPending)
+	var candidates map[string]*snapstate.RefreshCandidate
+	c.Assert(s.state.Get("refresh-candidates", &candidates), IsNil)
+	c.Check(candidates["some-snap"].FirstSeen.Equal(now), Equals, true)
+
+	// the rollout is counted from then
+	now = now.Add(73 * time.Hour)
+	err = snapstate.CheckRefreshRollout(s.state, "some-snap", snap.R(11), opts)
+	c.Check(err, IsNil)
+}
+
+func (s *snapmgrTestSuite) TestRefreshRolloutNotOnManualRefresh(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	restore := snapstate.MockDeviceSerial(func(st *state.State) (*asserts.Serial, error) {
+		return nil, state.ErrNoState
+	})
+	defer restore()
+
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.rollout", "72h"), IsNil)
+	tr.Commit()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		}),
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+
+	s.fakeStore.refreshRevnos["some-snap-id"] = snap.R(12)
+	names, _, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, s.user.ID, nil)
+	c.Assert(err, IsNil)
+	c.Check(names, DeepEquals, []string{"some-snap"})
+
+	// nothing is recorded for the rollout
+	c.Check(s.state.Get("refresh-candidates", new(map[string]*snapstate.RefreshCandidate)), testutil.ErrorIs, state.ErrNoState)
+}
+
 type customStore struct {
 	*fakeStore
 
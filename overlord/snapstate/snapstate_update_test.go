@@ -5331,6 +5331,47 @@ func (s *snapmgrTestSuite) TestUpdateMany(c *C) {
 	checkIsAutoRefresh(c, ts.Tasks(), false)
 }
 
+func (s *snapmgrTestSuite) TestUpdateManyOnlyCreatesTasks(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active: true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{
+			{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)},
+		}),
+		Current:  snap.R(1),
+		SnapType: "app",
+	})
+	tr := config.NewTransaction(s.state)
+	c.Assert(tr.Set("core", "refresh.rollout", "72h"), IsNil)
+	tr.Commit()
+
+	stateData := func() map[string]any {
+		data, err := json.Marshal(s.state)
+		c.Assert(err, IsNil)
+		var m struct {
+			Data map[string]any `json:"data"`
+		}
+		c.Assert(json.Unmarshal(data, &m), IsNil)
+		return m.Data
+	}
+	before := stateData()
+
+	// the tasks of a manual refresh can be discarded, as done for a
+	// dry-run, without leaving anything behind
+	updates, tts, err := snapstate.UpdateMany(context.Background(), s.state, nil, nil, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(updates, DeepEquals, []string{"some-snap"})
+	var tasks []*state.Task
+	for _, ts := range tts {
+		tasks = append(tasks, ts.Tasks()...)
+	}
+	s.state.DiscardTasks(tasks)
+
+	c.Check(stateData(), DeepEquals, before)
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyIgnoreRunning(c *C) {
 	si := snap.SideInfo{
 		RealName: "some-snap",
@@ -11050,6 +11091,47 @@ func (s *snapmgrTestSuite) TestUpdateManyTransactionalWithLane(c *C) {
 	verifyDelayedEffectsTasks(c, tss[2], []int{lane}, lane)
 }
 
+func (s *snapmgrTestSuite) TestUpdateWithGoalDryRunAllocatesNoLanes(c *C) {
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	for _, name := range []string{"some-snap", "some-other-snap"} {
+		snapID := fmt.Sprintf("%s-id", name)
+		si := &snap.SideInfo{
+			RealName: name,
+			SnapID:   snapID,
+			Revision: snap.R(7),
+		}
+
+		snaptest.MockSnap(c, `name: some-snap`, si)
+		snapstate.Set(s.state, name, &snapstate.SnapState{
+			Active:   true,
+			Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{si}),
+			Current:  si.Revision,
+		})
+	}
+
+	lane := s.state.NewLane()
+	goal := snapstate.StoreUpdateGoal(snapstate.StoreUpdate{InstanceName: "some-snap"}, snapstate.StoreUpdate{InstanceName: "some-other-snap"})
+	affected, uts, err := snapstate.UpdateWithGoal(context.Background(), s.state, goal, nil, snapstate.Options{
+		Flags:  snapstate.Flags{Transaction: client.TransactionAllSnaps},
+		UserID: s.user.ID,
+		DryRun: true,
+	})
+	c.Assert(err, IsNil)
+	c.Check(affected, testutil.DeepUnsortedMatches, []string{"some-snap", "some-other-snap"})
+
+	for _, ts := range uts.Refresh {
+		for _, t := range ts.Tasks() {
+			for _, l := range t.Lanes() {
+				c.Check(l, Equals, 0)
+			}
+		}
+	}
+	// no lane was allocated
+	c.Check(s.state.NewLane(), Equals, lane+1)
+}
+
 func (s *snapmgrTestSuite) TestUpdateManyNoDelayedEffects(c *C) {
 	s.state.Lock()
 	defer s.state.Unlock()
@@ -11232,6 +11314,48 @@ func (s *snapmgrTestSuite) TestConditionalAutoRefreshCreatesPreDownloadChange(c
 	checkPreDownloadChange(c, chgs[1], "some-snap", snap.R(2))
 }
 
+func (s *snapmgrTestSuite) TestConditionalAutoRefreshDeferredDuringBlackout(c *C) {
+	now := time.Date(2026, 11, 27, 10, 0, 0, 0, time.UTC)
+	restore := snapstate.MockTimeNow(func() time.Time { return now })
+	defer restore()
+
+	s.state.Lock()
+	defer s.state.Unlock()
+
+	tr := config.NewTransaction(s.state)
+	tr.Set("core", "refresh.blackout", "11-20..01-05")
+	tr.Commit()
+
+	si := &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(1)}
+	snapstate.Set(s.state, "some-snap", &snapstate.SnapState{
+		Active:   true,
+		Sequence: snapstatetest.NewSequenceFromSnapSideInfos([]*snap.SideInfo{si}),
+		Current:  si.Revision,
+		SnapType: string(snap.TypeApp),
+	})
+
+	chg := s.state.NewChange("auto-refresh", "test change")
+	task := s.state.NewTask("conditional-auto-refresh", "test task")
+	chg.AddTask(task)
+
+	snapsup := &snapstate.SnapSetup{
+		SideInfo: &snap.SideInfo{RealName: "some-snap", SnapID: "some-snap-id", Revision: snap.R(2)},
+		Flags:    snapstate.Flags{IsAutoRefresh: true},
+	}
+	task.Set("snaps", map[string]*snapstate.RefreshCandidate{
+		"some-snap": {
+			SnapSetup: *snapsup,
+		}})
+
+	s.settle(c)
+
+	// the snap is left for the next auto-refresh
+	c.Assert(chg.Err(), IsNil)
+	c.Check(chg.Status(), Equals, state.DoneStatus)
+	c.Check(chg.Tasks(), HasLen, 1)
+	c.Check(s.state.Changes(), HasLen, 1)
+}
+
 func (s *snapmgrTestSuite) TestAutoRefreshCreatePreDownload(c *C) {
 	restore := snapstate.MockRefreshAppsCheck(func(si *snap.Info) error {
 		return snapstate.NewBusySnapError(si, []int{123}, nil, nil)
@@ -66,6 +66,11 @@ type Options struct {
 	// pre-existing behavior of calling InstallMany with one snap vs calling
 	// Install.
 	ExpectOneSnap bool
+	// DryRun should be true when the task sets are only created to report
+	// what the operation would do, and are then discarded without being
+	// run. No lanes are allocated for them and the store is not queried
+	// beyond finding the revisions to install.
+	DryRun bool
 }
 
 func (opts *Options) setDefaultLane(st *state.State) error {
@@ -73,7 +78,7 @@ func (opts *Options) setDefaultLane(st *state.State) error {
 		return errors.New("cannot specify a lane without setting transaction to \"all-snaps\"")
 	}
 
-	if opts.Flags.Transaction == client.TransactionAllSnaps && opts.Flags.Lane == 0 {
+	if opts.Flags.Transaction == client.TransactionAllSnaps && opts.Flags.Lane == 0 && !opts.DryRun {
 		opts.Flags.Lane = st.NewLane()
 	}
 
@@ -929,6 +934,9 @@ func InstallWithGoal(ctx context.Context, st *state.State, goal InstallGoal, opt
 // mean "no lane", and places that implicitly used the empty string as
 // "per-snap" have been changed to use "per-snap" explicitly.
 func generateLane(st *state.State, opts Options) int {
+	if opts.DryRun {
+		return 0
+	}
 	switch opts.Flags.Transaction {
 	case client.TransactionAllSnaps:
 		return opts.Flags.Lane
@@ -1292,8 +1300,11 @@ func UpdateWithGoal(ctx context.Context, st *state.State, goal UpdateGoal, filte
 		}
 	}
 
-	if err := checkDiskSpace(st, changeKind, installInfos, opts.UserID, opts.PrereqTracker); err != nil {
-		return nil, nil, err
+	// checking the disk space can query the store about prerequisites
+	if !opts.DryRun {
+		if err := checkDiskSpace(st, changeKind, installInfos, opts.UserID, opts.PrereqTracker); err != nil {
+			return nil, nil, err
+		}
 	}
 
 	updated, uts, err := updateFromPlan(st, plan, opts)
@@ -93,9 +93,9 @@ func copyData(subkeys []string, pos int, srcData map[string]*json.RawMessage, ds
 	return copyData(subkeys, pos+1, srcDatam, dstDatam)
 }
 
-// CopyState takes a state from the srcStatePath and copies all
-// dataEntries to the dstPath. Note that srcStatePath should never
-// point to a state that is in use.
+// CopyState takes a state from the srcStatePath, together with its
+// journal if any, and copies all dataEntries to the dstPath. Note that
+// srcStatePath should never point to a state that is in use.
 func CopyState(srcStatePath, dstStatePath string, dataEntries []string) error {
 	if osutil.FileExists(dstStatePath) {
 		// XXX: TOCTOU - look into moving this check into
@@ -114,8 +114,9 @@ func CopyState(srcStatePath, dstStatePath string, dataEntries []string) error {
 	defer f.Close()
 
 	// No need to lock/unlock the state here, srcState should not be
-	// in use at all.
-	srcState, err := ReadState(nil, f)
+	// in use at all. The journal next to it holds what was modified since
+	// it was last written out entirely.
+	srcState, err := ReadStateWithJournal(f, srcStatePath+".journal")
 	if err != nil {
 		return err
 	}
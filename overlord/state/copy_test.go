@@ -157,3 +157,29 @@ func (ss *stateSuite) TestCopyStateDuplicatesInDataEntriesAreFine(c *C) {
 	c.Assert(err, IsNil)
 	c.Check(string(dstContent), Equals, `{"data":{"E":{"F":2,"G":3}}`+stateSuffix)
 }
+
+func (ss *stateSuite) TestCopyStateReplaysJournal(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("auth", map[string]any{"last-id": 1})
+	st.Unlock()
+	// the second user only made it to the journal
+	st.Lock()
+	st.Set("auth", map[string]any{"last-id": 2})
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 1)
+	c.Assert(b.records, Equals, 1)
+
+	srcStateFile := filepath.Join(c.MkDir(), "state.json")
+	c.Assert(os.WriteFile(srcStateFile, b.checkpoints[0], 0600), IsNil)
+	c.Assert(os.WriteFile(srcStateFile+".journal", b.journal, 0600), IsNil)
+
+	dstStateFile := filepath.Join(c.MkDir(), "dst-state.json")
+	err := state.CopyState(srcStateFile, dstStateFile, []string{"auth.last-id"})
+	c.Assert(err, IsNil)
+
+	dstContent, err := os.ReadFile(dstStateFile)
+	c.Assert(err, IsNil)
+	c.Check(string(dstContent), Equals, `{"data":{"auth":{"last-id":2}}`+stateSuffix)
+}
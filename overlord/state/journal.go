@@ -0,0 +1,334 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// A JournalBackend is a Backend which, instead of being handed the entire
+// state on every unlock operation, can persist only what was modified since
+// the previous unlock by appending it to a journal. The entire state is still
+// checkpointed via Checkpoint whenever the backend asks for the journal to be
+// compacted, after which the journal must be emptied.
+type JournalBackend interface {
+	Backend
+	// Append appends a record to the journal. A record which was only
+	// partially written must not be followed by any other record.
+	Append(record []byte) error
+	// NeedsCompaction returns whether the entire state should be
+	// checkpointed on the next unlock operation, emptying the journal.
+	NeedsCompaction() bool
+	// Journal returns a reader for the records appended to the journal
+	// since the state was last checkpointed, or nil if there are none.
+	Journal() (io.ReadCloser, error)
+}
+
+// journal keeps track of what was modified in a state using a JournalBackend
+// since it was last persisted.
+type journal struct {
+	// compact is set when the next unlock operation must checkpoint the
+	// entire state, regardless of what the backend asks for.
+	compact bool
+
+	data     map[string]bool
+	changes  map[string]bool
+	tasks    map[string]bool
+	warnings bool
+	notices  bool
+}
+
+func newJournal() *journal {
+	j := &journal{}
+	j.reset()
+	return j
+}
+
+func (j *journal) reset() {
+	j.compact = false
+	j.data = make(map[string]bool)
+	j.changes = make(map[string]bool)
+	j.tasks = make(map[string]bool)
+	j.warnings = false
+	j.notices = false
+}
+
+func (s *State) journalData(key string) {
+	if s.journal != nil {
+		s.journal.data[key] = true
+	}
+}
+
+func (s *State) journalChange(id string) {
+	if s.journal != nil {
+		s.journal.changes[id] = true
+	}
+}
+
+func (s *State) journalTask(t *Task) {
+	if s.journal != nil {
+		s.journal.tasks[t.id] = true
+		// the status of a change is derived from its tasks
+		if t.change != "" {
+			s.journal.changes[t.change] = true
+		}
+	}
+}
+
+func (s *State) journalWarnings() {
+	if s.journal != nil {
+		s.journal.warnings = true
+	}
+}
+
+func (s *State) journalNotices() {
+	if s.journal != nil {
+		s.journal.notices = true
+	}
+}
+
+// journalRecord holds what was modified in the state since it was last
+// persisted. Entries of data, changes and tasks set to null were removed,
+// while warnings and notices are recorded in their entirety when modified.
+type journalRecord struct {
+	Generation uint64 `json:"generation"`
+
+	Data     map[string]*json.RawMessage `json:"data,omitempty"`
+	Changes  map[string]*Change          `json:"changes,omitempty"`
+	Tasks    map[string]*Task            `json:"tasks,omitempty"`
+	Warnings *[]*Warning                 `json:"warnings,omitempty"`
+	Notices  *[]*Notice                  `json:"notices,omitempty"`
+
+	LastChangeId int `json:"last-change-id"`
+	LastTaskId   int `json:"last-task-id"`
+	LastLaneId   int `json:"last-lane-id"`
+	LastNoticeId int `json:"last-notice-id"`
+
+	LastNoticeTimestamp time.Time `json:"last-notice-timestamp,omitzero"`
+}
+
+func (s *State) journalRecordData() []byte {
+	j := s.journal
+	rec := journalRecord{
+		Generation: s.journalGeneration,
+
+		LastTaskId:   s.lastTaskId,
+		LastChangeId: s.lastChangeId,
+		LastLaneId:   s.lastLaneId,
+		LastNoticeId: s.lastNoticeId,
+
+		LastNoticeTimestamp: s.getLastNoticeTimestamp(),
+	}
+	if len(j.data) > 0 {
+		rec.Data = make(map[string]*json.RawMessage, len(j.data))
+		for key := range j.data {
+			rec.Data[key] = s.data[key]
+		}
+	}
+	if len(j.changes) > 0 {
+		rec.Changes = make(map[string]*Change, len(j.changes))
+		for id := range j.changes {
+			rec.Changes[id] = s.changes[id]
+		}
+	}
+	if len(j.tasks) > 0 {
+		rec.Tasks = make(map[string]*Task, len(j.tasks))
+		for id := range j.tasks {
+			rec.Tasks[id] = s.tasks[id]
+		}
+	}
+	if j.warnings {
+		warnings := s.flattenWarnings()
+		rec.Warnings = &warnings
+	}
+	if j.notices {
+		notices := s.flattenNotices()
+		rec.Notices = &notices
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		// this shouldn't happen, because the actual delicate serializing happens at various Set()s
+		logger.Panicf("internal error: could not marshal state journal record: %v", err)
+	}
+	return encodeJournalRecord(data)
+}
+
+// encodeJournalRecord frames the given data as a line prefixed with its
+// checksum, so that records which were only partially written or were
+// corrupted can be detected.
+func encodeJournalRecord(data []byte) []byte {
+	buf := make([]byte, 0, len(data)+10)
+	buf = append(buf, fmt.Sprintf("%08x ", crc32.ChecksumIEEE(data))...)
+	buf = append(buf, data...)
+	return append(buf, '\n')
+}
+
+func decodeJournalRecord(line []byte) (*journalRecord, error) {
+	if len(line) < 10 || line[8] != ' ' || line[len(line)-1] != '\n' {
+		return nil, fmt.Errorf("truncated record")
+	}
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil {
+		return nil, fmt.Errorf("bad checksum")
+	}
+	data := line[9 : len(line)-1]
+	if crc32.ChecksumIEEE(data) != uint32(sum) {
+		return nil, fmt.Errorf("bad checksum")
+	}
+	var rec journalRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, err
+	}
+	return &rec, nil
+}
+
+// replayJournal applies the records read from r on top of the state, skipping
+// the records appended before the state was checkpointed. Replaying stops at
+// the first record which cannot be decoded, as it can only be the last one
+// unless the journal got corrupted. It returns whether any record was skipped
+// or not decoded, in which case the journal should be compacted before
+// appending to it again.
+func (s *State) replayJournal(r io.Reader) (compact bool, err error) {
+	br := bufio.NewReader(r)
+	replayed := make(map[string]*Change)
+	for n := 1; ; n++ {
+		line, err := br.ReadBytes('\n')
+		if len(line) == 0 && err == io.EOF {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return false, fmt.Errorf("cannot read state journal: %v", err)
+		}
+		rec, err := decodeJournalRecord(line)
+		if err != nil {
+			logger.Noticef("cannot decode record %d of the state journal, ignoring it and any following ones: %v", n, err)
+			compact = true
+			break
+		}
+		if rec.Generation != s.journalGeneration {
+			compact = true
+			continue
+		}
+		s.applyJournalRecord(rec, replayed)
+	}
+	for _, chg := range replayed {
+		chg.finishUnmarshal()
+	}
+	return compact, nil
+}
+
+func (s *State) applyJournalRecord(rec *journalRecord, replayed map[string]*Change) {
+	if s.data == nil {
+		s.data = make(customData)
+	}
+	if s.changes == nil {
+		s.changes = make(map[string]*Change)
+	}
+	if s.tasks == nil {
+		s.tasks = make(map[string]*Task)
+	}
+	for key, value := range rec.Data {
+		if value == nil {
+			delete(s.data, key)
+		} else {
+			s.data[key] = value
+		}
+	}
+	for id, t := range rec.Tasks {
+		if t == nil {
+			delete(s.tasks, id)
+			continue
+		}
+		t.state = s
+		s.tasks[id] = t
+	}
+	for id, chg := range rec.Changes {
+		if chg == nil {
+			delete(s.changes, id)
+			delete(replayed, id)
+			continue
+		}
+		chg.state = s
+		s.changes[id] = chg
+		replayed[id] = chg
+	}
+	if rec.Warnings != nil {
+		s.unflattenWarnings(*rec.Warnings)
+	}
+	if rec.Notices != nil {
+		s.unflattenNotices(*rec.Notices)
+	}
+	s.lastChangeId = rec.LastChangeId
+	s.lastTaskId = rec.LastTaskId
+	s.lastLaneId = rec.LastLaneId
+	s.lastNoticeId = rec.LastNoticeId
+	s.HandleReportedLastNoticeTimestamp(rec.LastNoticeTimestamp)
+}
+
+// readJournal replays the journal of the backend, if any, on top of the
+// state that was just read, see replayJournal.
+func (s *State) readJournal(backend JournalBackend) (compact bool, err error) {
+	r, err := backend.Journal()
+	if err != nil {
+		return false, fmt.Errorf("cannot read state journal: %v", err)
+	}
+	if r == nil {
+		return false, nil
+	}
+	defer r.Close()
+	return s.replayJournal(r)
+}
+
+// ReadStateWithJournal returns the state deserialized from r, with the
+// records of the journal at journalPath replayed on top of it, as when the
+// state is read via a JournalBackend. It is meant for reading a state which
+// is not in use, and the returned state has no backend. A missing journal is
+// not an error.
+func ReadStateWithJournal(r io.Reader, journalPath string) (*State, error) {
+	s, err := ReadState(nil, r)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(journalPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read state journal: %v", err)
+	}
+	defer f.Close()
+
+	s.Lock()
+	defer s.unlock()
+	if _, err := s.replayJournal(f); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
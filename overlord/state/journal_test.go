@@ -0,0 +1,452 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package state_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+)
+
+type journalSuite struct{}
+
+var _ = Suite(&journalSuite{})
+
+type fakeJournalBackend struct {
+	fakeStateBackend
+	journal         []byte
+	records         int
+	appendError     error
+	needsCompaction bool
+}
+
+func (b *fakeJournalBackend) Checkpoint(data []byte) error {
+	if err := b.fakeStateBackend.Checkpoint(data); err != nil {
+		return err
+	}
+	b.journal = nil
+	b.records = 0
+	return nil
+}
+
+func (b *fakeJournalBackend) Append(record []byte) error {
+	if b.appendError != nil {
+		return b.appendError
+	}
+	b.journal = append(b.journal, record...)
+	b.records++
+	return nil
+}
+
+func (b *fakeJournalBackend) NeedsCompaction() bool {
+	return b.needsCompaction
+}
+
+func (b *fakeJournalBackend) Journal() (io.ReadCloser, error) {
+	if b.journal == nil {
+		return nil, nil
+	}
+	return io.NopCloser(bytes.NewReader(b.journal)), nil
+}
+
+// reload reads the state back from what was persisted via the backend.
+func (b *fakeJournalBackend) reload(c *C) *state.State {
+	c.Assert(len(b.checkpoints) > 0, Equals, true)
+	st, err := state.ReadState(b, bytes.NewReader(b.checkpoints[len(b.checkpoints)-1]))
+	c.Assert(err, IsNil)
+	return st
+}
+
+func marshalledState(c *C, st *state.State) map[string]any {
+	st.Lock()
+	defer st.Unlock()
+	data, err := st.MarshalJSON()
+	c.Assert(err, IsNil)
+	var m map[string]any
+	c.Assert(json.Unmarshal(data, &m), IsNil)
+	// notices are not kept in order
+	if notices, ok := m["notices"].([]any); ok {
+		sort.Slice(notices, func(i, j int) bool {
+			return notices[i].(map[string]any)["id"].(string) < notices[j].(map[string]any)["id"].(string)
+		})
+	}
+	return m
+}
+
+func (js *journalSuite) TestFirstUnlockCheckpoints(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Unlock()
+
+	c.Check(b.checkpoints, HasLen, 1)
+	c.Check(b.records, Equals, 0)
+	c.Check(string(b.checkpoints[0]), Matches, `.*"journal-generation":1.*`)
+}
+
+func (js *journalSuite) TestUnlockAppendsModifications(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Set("baz", 1)
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 1)
+
+	st.Lock()
+	st.Set("baz", 2)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 1)
+	c.Check(b.records, Equals, 1)
+	// only what was modified is recorded
+	c.Check(string(b.journal), Matches, `[0-9a-f]{8} \{"generation":1,"data":\{"baz":2\},"last-change-id".*\}\n`)
+
+	// unlocking without modifications appends nothing
+	st.Lock()
+	var baz int
+	c.Assert(st.Get("baz", &baz), IsNil)
+	st.Unlock()
+	c.Check(b.records, Equals, 1)
+}
+
+func (js *journalSuite) TestReadStateReplaysJournal(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("foo", "bar")
+	st.Set("gone", true)
+	chg1 := st.NewChange("kind1", "summary 1")
+	chg1.AddTask(st.NewTask("task1", "summary 1"))
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 1)
+
+	st.Lock()
+	st.Set("foo", "baz")
+	st.Set("gone", nil)
+	chg2 := st.NewChange("kind2", "summary 2")
+	t1 := st.NewTask("task2", "summary 2")
+	t2 := st.NewTask("task3", "summary 3")
+	t2.WaitFor(t1)
+	chg2.AddTask(t1)
+	chg2.AddTask(t2)
+	st.Unlock()
+
+	st.Lock()
+	for _, t := range chg1.Tasks() {
+		t.SetStatus(state.DoneStatus)
+	}
+	t1.Set("key", "value")
+	t1.Logf("some log")
+	st.NewLane()
+	st.Warnf("some warning")
+	st.Unlock()
+
+	c.Check(b.checkpoints, HasLen, 1)
+	c.Check(b.records, Equals, 2)
+
+	st1 := b.reload(c)
+	c.Check(st1.Modified(), Equals, false)
+	// the same state is recovered as if it had been checkpointed entirely
+	st.Lock()
+	data, err := st.MarshalJSON()
+	st.Unlock()
+	c.Assert(err, IsNil)
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	c.Check(marshalledState(c, st1), DeepEquals, marshalledState(c, st2))
+
+	st1.Lock()
+	defer st1.Unlock()
+	c.Check(st1.Change(chg1.ID()).IsReady(), Equals, true)
+	select {
+	case <-st1.Change(chg1.ID()).Ready():
+	default:
+		c.Errorf("change should be ready")
+	}
+	c.Check(st1.Change(chg2.ID()).Tasks(), HasLen, 2)
+	c.Check(st1.Task(t2.ID()).WaitTasks()[0].ID(), Equals, t1.ID())
+}
+
+func (js *journalSuite) TestReadStateReplaysPrune(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	chg := st.NewChange("kind", "summary")
+	t := st.NewTask("task", "summary")
+	chg.AddTask(t)
+	t.SetStatus(state.DoneStatus)
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 1)
+
+	st.Lock()
+	st.Prune(time.Now(), 0, time.Hour, 100)
+	c.Check(st.Changes(), HasLen, 0)
+	st.Unlock()
+	c.Check(b.records, Equals, 1)
+
+	st1 := b.reload(c)
+	st1.Lock()
+	defer st1.Unlock()
+	c.Check(st1.Changes(), HasLen, 0)
+	c.Check(st1.TaskCount(), Equals, 0)
+}
+
+func (js *journalSuite) TestReadStateReplaysPruneOfJournaledChanges(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 1)
+
+	// the changes only ever made it to the journal
+	st.Lock()
+	var chgs []*state.Change
+	for i := 0; i < 3; i++ {
+		chg := st.NewChange("kind", "summary")
+		t := st.NewTask("task", "summary")
+		chg.AddTask(t)
+		chgs = append(chgs, chg)
+	}
+	st.Unlock()
+
+	st.Lock()
+	for _, chg := range chgs {
+		for _, t := range chg.Tasks() {
+			t.SetStatus(state.DoneStatus)
+		}
+	}
+	st.Unlock()
+
+	// prune all but the newest ready change
+	st.Lock()
+	st.Prune(time.Now(), time.Hour, time.Hour, 1)
+	c.Check(st.Changes(), HasLen, 1)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 1)
+	c.Check(b.records, Equals, 3)
+
+	st1 := b.reload(c)
+	st.Lock()
+	data, err := st.MarshalJSON()
+	st.Unlock()
+	c.Assert(err, IsNil)
+	st2, err := state.ReadState(nil, bytes.NewReader(data))
+	c.Assert(err, IsNil)
+	c.Check(marshalledState(c, st1), DeepEquals, marshalledState(c, st2))
+
+	st1.Lock()
+	defer st1.Unlock()
+	c.Assert(st1.Changes(), HasLen, 1)
+	for _, t := range st1.Changes()[0].Tasks() {
+		c.Check(t, NotNil)
+	}
+	// the reopened state can be pruned further
+	st1.Prune(time.Now(), 0, time.Hour, 100)
+	c.Check(st1.Changes(), HasLen, 0)
+	c.Check(st1.TaskCount(), Equals, 0)
+}
+
+func (js *journalSuite) TestReadStateTornRecord(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 3)
+	st.Unlock()
+	c.Assert(b.records, Equals, 2)
+
+	// the last record was only partially written
+	b.journal = b.journal[:len(b.journal)-5]
+
+	st1 := b.reload(c)
+	st1.Lock()
+	var a int
+	c.Assert(st1.Get("a", &a), IsNil)
+	c.Check(a, Equals, 2)
+	// the journal gets compacted before appending to it again
+	st1.Set("a", 4)
+	st1.Unlock()
+	c.Check(b.checkpoints, HasLen, 2)
+	c.Check(b.records, Equals, 0)
+
+	st2 := b.reload(c)
+	st2.Lock()
+	defer st2.Unlock()
+	c.Assert(st2.Get("a", &a), IsNil)
+	c.Check(a, Equals, 4)
+}
+
+func (js *journalSuite) TestReadStateCorruptRecord(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 3)
+	st.Unlock()
+	c.Assert(b.records, Equals, 2)
+
+	// flip a byte of the value in the first record
+	i := bytes.Index(b.journal, []byte(`"a":2`))
+	c.Assert(i > 0, Equals, true)
+	b.journal[i+4] = '9'
+
+	st1 := b.reload(c)
+	st1.Lock()
+	defer st1.Unlock()
+	var a int
+	c.Assert(st1.Get("a", &a), IsNil)
+	c.Check(a, Equals, 1)
+}
+
+func (js *journalSuite) TestReadStateSkipsStaleGeneration(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	staleJournal := b.journal
+
+	b.needsCompaction = true
+	st.Lock()
+	st.Set("a", 3)
+	st.Unlock()
+	c.Assert(b.checkpoints, HasLen, 2)
+	c.Check(string(b.checkpoints[1]), Matches, `.*"journal-generation":2.*`)
+
+	// the journal was not emptied after the checkpoint
+	b.journal = staleJournal
+	st1 := b.reload(c)
+	st1.Lock()
+	defer st1.Unlock()
+	var a int
+	c.Assert(st1.Get("a", &a), IsNil)
+	c.Check(a, Equals, 3)
+}
+
+func (js *journalSuite) TestUnlockCompacts(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(b.records, Equals, 1)
+
+	b.needsCompaction = true
+	st.Lock()
+	st.Set("a", 3)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 2)
+	c.Check(b.records, Equals, 0)
+	c.Check(string(b.checkpoints[1]), Matches, `.*"a":3.*`)
+}
+
+func (js *journalSuite) TestUnlockAppendErrorCheckpoints(c *C) {
+	b := &fakeJournalBackend{}
+	st := state.New(b)
+	st.Lock()
+	st.Set("a", 1)
+	st.Unlock()
+
+	b.appendError = errors.New("boom")
+	st.Lock()
+	st.Set("a", 2)
+	st.Unlock()
+	c.Check(b.checkpoints, HasLen, 2)
+	c.Check(string(b.checkpoints[1]), Matches, `.*"a":2.*"journal-generation":2.*`)
+	c.Check(st.Modified(), Equals, false)
+}
+
+// discardStateBackend persists nothing, so that only the cost of preparing
+// what would be written is measured.
+type discardStateBackend struct{}
+
+func (discardStateBackend) Checkpoint(data []byte) error { return nil }
+func (discardStateBackend) EnsureBefore(d time.Duration) {}
+
+type discardJournalBackend struct {
+	discardStateBackend
+}
+
+func (discardJournalBackend) Append(record []byte) error      { return nil }
+func (discardJournalBackend) NeedsCompaction() bool           { return false }
+func (discardJournalBackend) Journal() (io.ReadCloser, error) { return nil, nil }
+
+func benchmarkState(b *testing.B, backend state.Backend) (*state.State, *state.Task) {
+	st := state.New(backend)
+	st.Lock()
+	defer st.Unlock()
+	for i := 0; i < 200; i++ {
+		st.Set(fmt.Sprintf("key-%d", i), map[string]any{"value": i, "blob": make([]byte, 512)})
+	}
+	var t *state.Task
+	for i := 0; i < 100; i++ {
+		chg := st.NewChange("kind", fmt.Sprintf("change %d", i))
+		for j := 0; j < 20; j++ {
+			t = st.NewTask("task", fmt.Sprintf("task %d of change %d", j, i))
+			t.Set("data", map[string]any{"value": j, "blob": make([]byte, 256)})
+			t.Logf("some log message")
+			chg.AddTask(t)
+		}
+	}
+	return st, t
+}
+
+func benchmarkUnlock(b *testing.B, backend state.Backend) {
+	st, t := benchmarkState(b, backend)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		st.Lock()
+		t.Set("counter", i)
+		st.Unlock()
+	}
+}
+
+func BenchmarkUnlockCheckpoint(b *testing.B) {
+	benchmarkUnlock(b, discardStateBackend{})
+}
+
+func BenchmarkUnlockJournal(b *testing.B) {
+	benchmarkUnlock(b, discardJournalBackend{})
+}
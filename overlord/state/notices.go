@@ -307,11 +307,24 @@ const (
 	// expired. The key for interfaces-requests-rule-update notices is the
 	// rule ID.
 	InterfacesRequestsRuleUpdateNotice NoticeType = "interfaces-requests-rule-update"
+
+	// Recorded whenever the processes in a quota group hit its memory
+	// limit. The key for quota-memory-event notices is the quota group name.
+	QuotaMemoryEventNotice NoticeType = "quota-memory-event"
+
+	// Recorded whenever a snap is reverted because it reported an error
+	// health status after being refreshed. The key for snap-auto-revert
+	// notices is the snap instance name.
+	SnapAutoRevertNotice NoticeType = "snap-auto-revert"
+
+	// Recorded whenever the health status reported by a snap changes. The
+	// key for snap-health notices is the snap instance name.
+	SnapHealthNotice NoticeType = "snap-health"
 )
 
 func (t NoticeType) Valid() bool {
 	switch t {
-	case ChangeUpdateNotice, WarningNotice, RefreshInhibitNotice, SnapRunInhibitNotice, InterfacesRequestsPromptNotice, InterfacesRequestsRuleUpdateNotice:
+	case ChangeUpdateNotice, WarningNotice, RefreshInhibitNotice, SnapRunInhibitNotice, InterfacesRequestsPromptNotice, InterfacesRequestsRuleUpdateNotice, QuotaMemoryEventNotice, SnapAutoRevertNotice, SnapHealthNotice:
 		return true
 	}
 	return false
@@ -388,6 +401,7 @@ func (s *State) AddNotice(userID *uint32, noticeType NoticeType, key string, opt
 	}
 
 	s.writing()
+	s.journalNotices()
 	s.noticesMu.Lock()
 	defer s.noticesMu.Unlock()
 
@@ -527,6 +541,7 @@ func (f *NoticeFilter) futureNoticesPossible(now time.Time) bool {
 // from state to another notice backend.
 func (s *State) DrainNotices(filter *NoticeFilter) []*Notice {
 	s.writing()
+	s.journalNotices()
 	s.noticesMu.Lock()
 	defer s.noticesMu.Unlock()
 
@@ -33,6 +33,7 @@ import (
 
 	"github.com/snapcore/snapd/logger"
 	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/timings"
 )
 
 // A Backend is used by State to checkpoint on every unlock operation
@@ -122,6 +123,15 @@ type State struct {
 
 	modified bool
 
+	// journal keeps track of the modifications since the state was last
+	// persisted when the backend is a JournalBackend
+	journal *journal
+	// journalGeneration is bumped whenever the entire state is
+	// checkpointed with a JournalBackend, so that records appended to the
+	// journal before can be told apart from the ones appended after,
+	// should the journal not have been emptied
+	journalGeneration uint64
+
 	cache map[any]any
 
 	pendingChangeByAttr map[string]func(*Change) bool
@@ -129,6 +139,9 @@ type State struct {
 	// task/changes observing
 	taskHandlers   map[int]func(t *Task, old, new Status) (remove bool)
 	changeHandlers map[int]func(chg *Change, old, new Status)
+	pruneHandlers  map[int]func(chgs []*Change)
+
+	timingsExporter func(t *timings.Timings)
 
 	lockWaitStart int64
 	lockHoldStart int64
@@ -148,10 +161,15 @@ func New(backend Backend) *State {
 		pendingChangeByAttr: make(map[string]func(*Change) bool),
 		taskHandlers:        make(map[int]func(t *Task, old Status, new Status) bool),
 		changeHandlers:      make(map[int]func(chg *Change, old Status, new Status)),
+		pruneHandlers:       make(map[int]func(chgs []*Change)),
 	}
 	// The noticeCond.L must be the same as the lock which is held during
 	// WaitNotices, since noticeCond.Wait() will unlock noticeCond.L.
 	st.noticeCond = sync.NewCond(st.noticesMu.RLocker())
+	if _, ok := backend.(JournalBackend); ok {
+		st.journal = newJournal()
+		st.journal.compact = true
+	}
 	return st
 }
 
@@ -204,6 +222,8 @@ type marshalledState struct {
 	LastNoticeId int `json:"last-notice-id"`
 
 	LastNoticeTimestamp time.Time `json:"last-notice-timestamp,omitzero"`
+
+	JournalGeneration uint64 `json:"journal-generation,omitempty"`
 }
 
 // MarshalJSON makes State a json.Marshaller
@@ -222,6 +242,8 @@ func (s *State) MarshalJSON() ([]byte, error) {
 		LastNoticeId: s.lastNoticeId,
 
 		LastNoticeTimestamp: s.getLastNoticeTimestamp(),
+
+		JournalGeneration: s.journalGeneration,
 	})
 }
 
@@ -242,6 +264,7 @@ func (s *State) UnmarshalJSON(data []byte) error {
 	s.lastTaskId = unmarshalled.LastTaskId
 	s.lastLaneId = unmarshalled.LastLaneId
 	s.lastNoticeId = unmarshalled.LastNoticeId
+	s.journalGeneration = unmarshalled.JournalGeneration
 	// Update the last notice timestamp if the one saved to disk is later.
 	// The timestamp on disk is only guaranteed to reflect the most recent
 	// timestamp of notices which are stored in state, since state lock was
@@ -298,12 +321,29 @@ func (s *State) Unlock() {
 		return
 	}
 
+	jb, journaling := s.backend.(JournalBackend)
+	if journaling && !s.journal.compact && !jb.NeedsCompaction() {
+		err := jb.Append(s.journalRecordData())
+		if err == nil {
+			s.modified = false
+			s.journal.reset()
+			return
+		}
+		logger.Noticef("cannot append to state journal, checkpointing instead: %v", err)
+	}
+	if journaling {
+		s.journalGeneration++
+	}
+
 	data := s.checkpointData()
 	var err error
 	start := time.Now()
 	for time.Since(start) <= unlockCheckpointRetryMaxTime {
 		if err = s.backend.Checkpoint(data); err == nil {
 			s.modified = false
+			if journaling {
+				s.journal.reset()
+			}
 			return
 		}
 		time.Sleep(unlockCheckpointRetryInterval)
@@ -361,6 +401,7 @@ func (s *State) Has(key string) bool {
 // The provided value must properly marshal and unmarshal with encoding/json.
 func (s *State) Set(key string, value any) {
 	s.writing()
+	s.journalData(key)
 	s.data.set(key, value)
 }
 
@@ -389,6 +430,7 @@ func (s *State) NewChange(kind, summary string) *Change {
 	id := strconv.Itoa(s.lastChangeId)
 	chg := newChange(s, id, kind, summary)
 	s.changes[id] = chg
+	s.journalChange(id)
 	// Add change-update notice for newly spawned change
 	// NOTE: Implies State.writing()
 	if err := chg.addNotice(); err != nil {
@@ -429,9 +471,24 @@ func (s *State) NewTask(kind, summary string) *Task {
 	id := strconv.Itoa(s.lastTaskId)
 	t := newTask(s, id, kind, summary)
 	s.tasks[id] = t
+	s.journalTask(t)
 	return t
 }
 
+// DiscardTasks removes from the state the given tasks, which must not have
+// been registered with a Change. It is meant for tasks that were created
+// only to inspect what an operation would do.
+func (s *State) DiscardTasks(tasks []*Task) {
+	s.writing()
+	for _, t := range tasks {
+		if t.Change() != nil {
+			panic(fmt.Sprintf("internal error: cannot discard task %s of change %s", t.ID(), t.Change().ID()))
+		}
+		delete(s.tasks, t.ID())
+		s.journalTask(t)
+	}
+}
+
 // Tasks returns all tasks currently known to the state and linked to changes.
 func (s *State) Tasks() []*Task {
 	s.reading()
@@ -501,6 +558,8 @@ func (s *State) RegisterPendingChangeByAttr(attr string, f func(*Change) bool) {
 //   - it removes tasks unlinked to changes after pruneWait. When there are more
 //     changes than the limit set via "maxReadyChanges" those changes in ready
 //     state will also removed even if they are below the pruneWait duration.
+//     The ready changes are handed to the handlers registered with
+//     AddChangesPrunedHandler before being removed.
 //
 //   - it removes expired warnings and notices.
 func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Duration, maxReadyChanges int) {
@@ -528,6 +587,7 @@ func (s *State) Prune(startOfOperation time.Time, pruneWait, abortWait time.Dura
 
 	s.pruneNotices(now)
 
+	var pruned []*Change
 NextChange:
 	for _, chg := range changes {
 		readyTime := chg.ReadyTime()
@@ -539,6 +599,7 @@ NextChange:
 			if spawnTime.Before(pruneLimit) && len(chg.Tasks()) == 0 {
 				chg.Abort()
 				delete(s.changes, chg.ID())
+				s.journalChange(chg.ID())
 			} else if spawnTime.Before(abortLimit) {
 				for attr, pending := range s.pendingChangeByAttr {
 					if chg.Has(attr) && pending(chg) {
@@ -551,20 +612,30 @@ NextChange:
 		}
 		// change old or we have too many changes
 		if readyTime.Before(pruneLimit) || readyChangesCount > maxReadyChanges {
-			s.writing()
-			for _, t := range chg.Tasks() {
-				delete(s.tasks, t.ID())
-			}
-			delete(s.changes, chg.ID())
+			pruned = append(pruned, chg)
 			readyChangesCount--
 		}
 	}
 
+	if len(pruned) > 0 {
+		s.writing()
+		s.notifyChangesPrunedHandlers(pruned)
+	}
+	for _, chg := range pruned {
+		for _, t := range chg.Tasks() {
+			delete(s.tasks, t.ID())
+			s.journalTask(t)
+		}
+		delete(s.changes, chg.ID())
+		s.journalChange(chg.ID())
+	}
+
 	for tid, t := range s.tasks {
 		// TODO: this could be done more aggressively
 		if t.Change() == nil && t.SpawnTime().Before(pruneLimit) {
 			s.writing()
 			delete(s.tasks, tid)
+			s.journalTask(t)
 		}
 	}
 }
@@ -655,11 +726,50 @@ func (s *State) notifyChangeStatusChangedHandlers(chg *Change, old, new Status)
 	}
 }
 
+// AddChangesPrunedHandler adds a callback function that will be invoked by
+// Prune with the ready changes it is about to remove, while their tasks can
+// still be accessed.
+func (s *State) AddChangesPrunedHandler(f func(chgs []*Change)) (id int) {
+	s.reading()
+	id = s.lastHandlerId
+	s.lastHandlerId++
+	s.pruneHandlers[id] = f
+	return id
+}
+
+func (s *State) RemoveChangesPrunedHandler(id int) {
+	s.reading()
+	delete(s.pruneHandlers, id)
+}
+
+func (s *State) notifyChangesPrunedHandlers(chgs []*Change) {
+	s.reading()
+	for _, f := range s.pruneHandlers {
+		f(chgs)
+	}
+}
+
 // SaveTimings implements timings.GetSaver
 func (s *State) SaveTimings(timings any) {
 	s.Set("timings", timings)
 }
 
+// SetTimingsExporter sets a function that will be handed the timings saved
+// into the state, or unsets it if f is nil. It is called with the state
+// locked, so it should not block.
+func (s *State) SetTimingsExporter(f func(t *timings.Timings)) {
+	s.reading()
+	s.timingsExporter = f
+}
+
+// ExportTimings implements timings.Exporter
+func (s *State) ExportTimings(t *timings.Timings) {
+	s.reading()
+	if s.timingsExporter != nil {
+		s.timingsExporter(t)
+	}
+}
+
 // ReadState returns the state deserialized from r.
 func ReadState(backend Backend, r io.Reader) (*State, error) {
 	s := new(State)
@@ -672,10 +782,17 @@ func ReadState(backend Backend, r io.Reader) (*State, error) {
 	}
 	s.backend = backend
 	s.noticeCond = sync.NewCond(s.noticesMu.RLocker())
+	if jb, ok := backend.(JournalBackend); ok {
+		s.journal = newJournal()
+		if s.journal.compact, err = s.readJournal(jb); err != nil {
+			return nil, err
+		}
+	}
 	s.modified = false
 	s.cache = make(map[any]any)
 	s.pendingChangeByAttr = make(map[string]func(*Change) bool)
 	s.changeHandlers = make(map[int]func(chg *Change, old Status, new Status))
 	s.taskHandlers = make(map[int]func(t *Task, old Status, new Status) bool)
+	s.pruneHandlers = make(map[int]func(chgs []*Change))
 	return s, err
 }
@@ -597,6 +597,7 @@ func (ss *stateSuite) TestEmptyStateDataAndCheckpointReadAndSet(c *C) {
 		"pendingChangeByAttr",
 		"taskHandlers",
 		"changeHandlers",
+		"pruneHandlers",
 	})
 }
 
@@ -759,6 +760,25 @@ func (ss *stateSuite) TestNewTaskHiddenUntilLinked(c *C) {
 	c.Check(st.Task(t1.ID()), IsNil)
 }
 
+func (ss *stateSuite) TestDiscardTasks(c *C) {
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+
+	t1 := st.NewTask("check", "...")
+	t2 := st.NewTask("check", "...")
+	t3 := st.NewTask("check", "...")
+	c.Check(st.AllTasksForTests(), HasLen, 3)
+
+	st.DiscardTasks([]*state.Task{t1, t2})
+	c.Check(st.AllTasksForTests(), DeepEquals, []*state.Task{t3})
+
+	chg := st.NewChange("install", "...")
+	chg.AddTask(t3)
+	c.Check(func() { st.DiscardTasks([]*state.Task{t3}) }, PanicMatches, `internal error: cannot discard task 3 of change 1`)
+	c.Check(st.Task(t3.ID()), Equals, t3)
+}
+
 func (ss *stateSuite) TestMethodEntrance(c *C) {
 	st := state.New(&fakeStateBackend{})
 
@@ -770,6 +790,7 @@ func (ss *stateSuite) TestMethodEntrance(c *C) {
 		func() { st.Set("foo", 1) },
 		func() { st.NewChange("install", "...") },
 		func() { st.NewTask("download", "...") },
+		func() { st.DiscardTasks(nil) },
 		func() { st.UnmarshalJSON(nil) },
 		func() { st.NewLane() },
 		func() { st.Warnf("hello") },
@@ -880,6 +901,51 @@ func (ss *stateSuite) TestPrune(c *C) {
 	c.Check(st.AllWarnings(), HasLen, 1)
 }
 
+func (ss *stateSuite) TestPruneChangesPrunedHandler(c *C) {
+	st := state.New(&fakeStateBackend{})
+	st.Lock()
+	defer st.Unlock()
+
+	now := time.Now()
+	pruneWait := 1 * time.Hour
+
+	t1 := st.NewTask("foo", "...")
+	chg1 := st.NewChange("prune", "...")
+	chg1.AddTask(t1)
+	state.MockChangeTimes(chg1, now.Add(-pruneWait), now.Add(-pruneWait))
+
+	t2 := st.NewTask("foo", "...")
+	chg2 := st.NewChange("ready-but-recent", "...")
+	chg2.AddTask(t2)
+	state.MockChangeTimes(chg2, now.Add(-pruneWait), now.Add(-pruneWait/2))
+
+	var pruned []*state.Change
+	var prunedTasks []*state.Task
+	id := st.AddChangesPrunedHandler(func(chgs []*state.Change) {
+		pruned = append(pruned, chgs...)
+		for _, chg := range chgs {
+			// the tasks are still around
+			prunedTasks = append(prunedTasks, chg.Tasks()...)
+		}
+	})
+
+	st.Prune(now, pruneWait, 3*time.Hour, 100)
+	c.Check(pruned, DeepEquals, []*state.Change{chg1})
+	c.Check(prunedTasks, DeepEquals, []*state.Task{t1})
+	c.Check(st.Change(chg1.ID()), IsNil)
+	c.Check(st.Task(t1.ID()), IsNil)
+
+	// nothing is handed over if nothing is pruned
+	pruned = nil
+	st.Prune(now, pruneWait, 3*time.Hour, 100)
+	c.Check(pruned, HasLen, 0)
+
+	st.RemoveChangesPrunedHandler(id)
+	st.Prune(now, 0, 3*time.Hour, 100)
+	c.Check(pruned, HasLen, 0)
+	c.Check(st.Change(chg2.ID()), IsNil)
+}
+
 func (ss *stateSuite) TestRegisterPendingChangeByAttr(c *C) {
 	st := state.New(&fakeStateBackend{})
 	st.Lock()
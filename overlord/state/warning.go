@@ -210,6 +210,7 @@ func (s *State) AddWarning(message string, options *AddWarningOptions) {
 	}
 
 	s.writing()
+	s.journalWarnings()
 	s.warningsMu.Lock()
 	defer s.warningsMu.Unlock()
 
@@ -243,6 +244,7 @@ func (s *State) AddWarning(message string, options *AddWarningOptions) {
 // Returns state.ErrNoState if no warning exists with given message.
 func (s *State) RemoveWarning(message string) error {
 	s.writing()
+	s.journalWarnings()
 	s.warningsMu.Lock()
 	defer s.warningsMu.Unlock()
 	_, ok := s.warnings[message]
@@ -277,6 +279,7 @@ func (s *State) OkayWarnings(t time.Time) int {
 	t = t.UTC()
 
 	s.writing()
+	s.journalWarnings()
 	s.warningsMu.Lock()
 	defer s.warningsMu.Unlock()
 
@@ -346,6 +349,7 @@ func (s *State) WarningsSummary() (int, time.Time) {
 // warnings. For use in debugging.
 func (s *State) UnshowAllWarnings() {
 	s.writing()
+	s.journalWarnings()
 	s.warningsMu.Lock()
 	defer s.warningsMu.Unlock()
 	for _, w := range s.warnings {
@@ -0,0 +1,237 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil/inotify"
+)
+
+// MemoryEvents holds the counters of the memory events of a cgroup, as
+// found in the memory.events file of the cgroup v2 hierarchy, see
+// https://www.kernel.org/doc/html/latest/admin-guide/cgroup-v2.html
+type MemoryEvents struct {
+	// Low is the number of times the cgroup was reclaimed despite being
+	// under its low boundary.
+	Low uint64
+	// High is the number of times the processes of the cgroup were
+	// throttled because the high boundary was exceeded.
+	High uint64
+	// Max is the number of times the memory usage of the cgroup was about
+	// to go over the max boundary.
+	Max uint64
+	// OOM is the number of times the memory usage of the cgroup hit the
+	// limit and allocations failed.
+	OOM uint64
+	// OOMKill is the number of processes of the cgroup killed by the OOM
+	// killer.
+	OOMKill uint64
+}
+
+func memoryEventsPath(cgroupPath string) string {
+	return filepath.Join(rootPath, cgroupMountPoint, cgroupPath, "memory.events")
+}
+
+// ReadMemoryEvents returns the memory event counters of the given cgroup,
+// whose path is relative to the root of the cgroup v2 hierarchy.
+func ReadMemoryEvents(cgroupPath string) (*MemoryEvents, error) {
+	if !IsUnified() {
+		return nil, fmt.Errorf("cannot read memory events with cgroup v1")
+	}
+	data, err := osReadFile(memoryEventsPath(cgroupPath))
+	if err != nil {
+		return nil, err
+	}
+	return parseMemoryEvents(data)
+}
+
+func parseMemoryEvents(data []byte) (*MemoryEvents, error) {
+	var events MemoryEvents
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("cannot parse memory events: invalid line %q", scanner.Text())
+		}
+		value, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse memory events: invalid value of %q: %v", fields[0], err)
+		}
+		switch fields[0] {
+		case "low":
+			events.Low = value
+		case "high":
+			events.High = value
+		case "max":
+			events.Max = value
+		case "oom":
+			events.OOM = value
+		case "oom_kill":
+			events.OOMKill = value
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot parse memory events: %v", err)
+	}
+	return &events, nil
+}
+
+// MemoryEventsMonitor watches the memory.events files of cgroups for
+// changes.
+type MemoryEventsMonitor struct {
+	wd      *inotify.Watcher
+	channel chan<- string
+
+	mu sync.Mutex
+	// watched maps the names the cgroups were added with to the path of
+	// their memory.events file
+	watched map[string]string
+	// names maps the path of the memory.events files to the names of the
+	// cgroups
+	names map[string]string
+
+	closing chan struct{}
+	done    chan struct{}
+}
+
+// NewMemoryEventsMonitor returns a monitor pushing the name of a watched
+// cgroup through the channel whenever its memory event counters change.
+// This allows the caller to use the same channel for several cgroups.
+func NewMemoryEventsMonitor(channel chan<- string) (*MemoryEventsMonitor, error) {
+	wd, err := inotify.NewWatcher()
+	if err != nil {
+		return nil, fmt.Errorf("cannot initialize inotify: %w", err)
+	}
+	m := &MemoryEventsMonitor{
+		wd:      wd,
+		channel: channel,
+		watched: make(map[string]string),
+		names:   make(map[string]string),
+		closing: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	go m.mainLoop()
+	return m, nil
+}
+
+// Add starts watching the memory events of the given cgroup, whose path is
+// relative to the root of the cgroup v2 hierarchy, under the given name.
+// Adding a name that is watched already updates the cgroup being watched.
+func (m *MemoryEventsMonitor) Add(name, cgroupPath string) error {
+	path := memoryEventsPath(cgroupPath)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if old, ok := m.watched[name]; ok {
+		if old == path {
+			return nil
+		}
+		m.removeLocked(name)
+	}
+	if err := m.wd.AddWatch(path, inotify.InModify); err != nil {
+		return err
+	}
+	m.watched[name] = path
+	m.names[path] = name
+	return nil
+}
+
+// Remove stops watching the memory events of the cgroup added with the
+// given name.
+func (m *MemoryEventsMonitor) Remove(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.removeLocked(name)
+}
+
+func (m *MemoryEventsMonitor) removeLocked(name string) {
+	path, ok := m.watched[name]
+	if !ok {
+		return
+	}
+	if err := m.wd.RemoveWatch(path); err != nil {
+		logger.Noticef("cannot remove watch of %s: %v", path, err)
+	}
+	delete(m.watched, name)
+	delete(m.names, path)
+}
+
+// Watched returns the names of the cgroups being watched.
+func (m *MemoryEventsMonitor) Watched() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	names := make([]string, 0, len(m.watched))
+	for name := range m.watched {
+		names = append(names, name)
+	}
+	return names
+}
+
+// Close stops the monitor and waits for it to finish.
+func (m *MemoryEventsMonitor) Close() {
+	close(m.closing)
+	<-m.done
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.wd.Close()
+}
+
+func (m *MemoryEventsMonitor) mainLoop() {
+	defer close(m.done)
+	for {
+		var event *inotify.Event
+		select {
+		case event = <-m.wd.Event:
+		case <-m.closing:
+			return
+		}
+		if event == nil {
+			// the watcher was closed
+			return
+		}
+		m.mu.Lock()
+		name, ok := m.names[event.Name]
+		if ok && event.Mask&inotify.InIgnored != 0 {
+			// the cgroup is gone, and so is the watch
+			m.removeLocked(name)
+		}
+		m.mu.Unlock()
+		if !ok || event.Mask&inotify.InModify == 0 {
+			continue
+		}
+		select {
+		case m.channel <- name:
+		case <-m.closing:
+			return
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package cgroup_test
+
+import (
+	"os"
+	"path/filepath"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/dirs"
+	"github.com/snapcore/snapd/sandbox/cgroup"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type memoryEventsSuite struct {
+	testutil.BaseTest
+
+	rootDir string
+}
+
+var _ = Suite(&memoryEventsSuite{})
+
+func (s *memoryEventsSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	s.rootDir = c.MkDir()
+	dirs.SetRootDir(s.rootDir)
+	s.AddCleanup(func() { dirs.SetRootDir("/") })
+
+	s.AddCleanup(cgroup.MockVersion(cgroup.V2, nil))
+}
+
+func (s *memoryEventsSuite) writeMemoryEvents(c *C, cgroupPath, content string) {
+	path := filepath.Join(s.rootDir, "/sys/fs/cgroup", cgroupPath, "memory.events")
+	c.Assert(os.MkdirAll(filepath.Dir(path), 0755), IsNil)
+	c.Assert(os.WriteFile(path, []byte(content), 0644), IsNil)
+}
+
+func (s *memoryEventsSuite) TestReadMemoryEventsHappy(c *C) {
+	s.writeMemoryEvents(c, "snap.foo.slice", "low 0\nhigh 12\nmax 3\noom 2\noom_kill 1\noom_group_kill 0\n")
+
+	events, err := cgroup.ReadMemoryEvents("snap.foo.slice")
+	c.Assert(err, IsNil)
+	c.Check(events, DeepEquals, &cgroup.MemoryEvents{
+		High:    12,
+		Max:     3,
+		OOM:     2,
+		OOMKill: 1,
+	})
+}
+
+func (s *memoryEventsSuite) TestReadMemoryEventsErrors(c *C) {
+	_, err := cgroup.ReadMemoryEvents("snap.foo.slice")
+	c.Check(os.IsNotExist(err), Equals, true)
+
+	s.writeMemoryEvents(c, "snap.foo.slice", "high 1 2\n")
+	_, err = cgroup.ReadMemoryEvents("snap.foo.slice")
+	c.Check(err, ErrorMatches, `cannot parse memory events: invalid line "high 1 2"`)
+
+	s.writeMemoryEvents(c, "snap.foo.slice", "oom_kill x\n")
+	_, err = cgroup.ReadMemoryEvents("snap.foo.slice")
+	c.Check(err, ErrorMatches, `cannot parse memory events: invalid value of "oom_kill": .*`)
+
+	restore := cgroup.MockVersion(cgroup.V1, nil)
+	defer restore()
+	_, err = cgroup.ReadMemoryEvents("snap.foo.slice")
+	c.Check(err, ErrorMatches, "cannot read memory events with cgroup v1")
+}
+
+func (s *memoryEventsSuite) TestMemoryEventsMonitor(c *C) {
+	s.writeMemoryEvents(c, "snap.foo.slice", "oom_kill 0\n")
+	s.writeMemoryEvents(c, "snap.bar.slice", "oom_kill 0\n")
+
+	ch := make(chan string)
+	m, err := cgroup.NewMemoryEventsMonitor(ch)
+	c.Assert(err, IsNil)
+	defer m.Close()
+
+	c.Assert(m.Add("foo", "snap.foo.slice"), IsNil)
+	c.Assert(m.Add("bar", "snap.bar.slice"), IsNil)
+	// adding again is fine
+	c.Assert(m.Add("bar", "snap.bar.slice"), IsNil)
+	c.Check(m.Watched(), testutil.DeepUnsortedMatches, []string{"foo", "bar"})
+
+	s.writeMemoryEvents(c, "snap.bar.slice", "oom_kill 1\n")
+	select {
+	case name := <-ch:
+		c.Check(name, Equals, "bar")
+	case <-time.After(10 * time.Second):
+		c.Fatal("no memory events notification")
+	}
+
+	m.Remove("bar")
+	c.Check(m.Watched(), DeepEquals, []string{"foo"})
+
+	s.writeMemoryEvents(c, "snap.bar.slice", "oom_kill 2\n")
+	s.writeMemoryEvents(c, "snap.foo.slice", "oom_kill 1\n")
+	select {
+	case name := <-ch:
+		c.Check(name, Equals, "foo")
+	case <-time.After(10 * time.Second):
+		c.Fatal("no memory events notification")
+	}
+}
+
+func (s *memoryEventsSuite) TestMemoryEventsMonitorAddMissing(c *C) {
+	m, err := cgroup.NewMemoryEventsMonitor(make(chan string))
+	c.Assert(err, IsNil)
+	defer m.Close()
+
+	err = m.Add("foo", "snap.foo.slice")
+	c.Check(err, ErrorMatches, `inotify_add_watch .*/sys/fs/cgroup/snap.foo.slice/memory.events: no such file or directory`)
+	c.Check(m.Watched(), HasLen, 0)
+}
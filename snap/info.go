@@ -1276,6 +1276,55 @@ type TimerInfo struct {
 	Timer string
 }
 
+// ProbeType is the type of the liveness probe of a service.
+type ProbeType string
+
+const (
+	// ProbeHTTP probes send a GET request to a port on localhost.
+	ProbeHTTP ProbeType = "http"
+	// ProbeTCP probes connect to a port on localhost.
+	ProbeTCP ProbeType = "tcp"
+	// ProbeExec probes run a command of the snap.
+	ProbeExec ProbeType = "exec"
+)
+
+// ProbeAction is what is done when a service fails its liveness probe
+// repeatedly.
+type ProbeAction string
+
+const (
+	// ProbeActionRestart restarts the service.
+	ProbeActionRestart ProbeAction = "restart"
+	// ProbeActionUnhealthy marks the snap as unhealthy until the
+	// service passes its liveness probe again.
+	ProbeActionUnhealthy ProbeAction = "unhealthy"
+)
+
+const (
+	DefaultProbeInterval         = 30 * time.Second
+	DefaultProbeTimeout          = 5 * time.Second
+	DefaultProbeFailureThreshold = 3
+)
+
+// ProbeInfo provides information on the liveness probe of a service.
+type ProbeInfo struct {
+	App *AppInfo
+
+	Type ProbeType
+	// Port is the port on localhost used by http and tcp probes.
+	Port int
+	// Path is the path requested by http probes.
+	Path string
+	// Command is the command run by exec probes, like the other commands
+	// of the app.
+	Command string
+
+	Interval         time.Duration
+	Timeout          time.Duration
+	FailureThreshold int
+	OnFailure        ProbeAction
+}
+
 // StopModeType is the type for the "stop-mode:" of a snap app
 type StopModeType string
 
@@ -1362,6 +1411,8 @@ type AppInfo struct {
 	Timer *TimerInfo
 
 	Autostart string
+
+	LivenessProbe *ProbeInfo
 }
 
 // Runnable returns a Runnable for this app.
@@ -1412,6 +1463,10 @@ type HookInfo struct {
 	Environment  strutil.OrderedMap
 	CommandChain []string
 
+	// Interval is how often the check-health hook is run periodically, if
+	// at all.
+	Interval time.Duration
+
 	Explicit bool
 }
 
@@ -25,6 +25,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 
 	"gopkg.in/yaml.v2"
 
@@ -110,6 +111,27 @@ type appYaml struct {
 	Timer string `yaml:"timer,omitempty"`
 
 	Autostart string `yaml:"autostart,omitempty"`
+
+	LivenessProbe *probeYaml `yaml:"liveness-probe,omitempty"`
+}
+
+type probeYaml struct {
+	HTTP             *httpProbeYaml  `yaml:"http,omitempty"`
+	TCP              *tcpProbeYaml   `yaml:"tcp,omitempty"`
+	Exec             string          `yaml:"exec,omitempty"`
+	Interval         timeout.Timeout `yaml:"interval,omitempty"`
+	Timeout          timeout.Timeout `yaml:"timeout,omitempty"`
+	FailureThreshold int             `yaml:"failure-threshold,omitempty"`
+	OnFailure        ProbeAction     `yaml:"on-failure,omitempty"`
+}
+
+type httpProbeYaml struct {
+	Port int    `yaml:"port"`
+	Path string `yaml:"path,omitempty"`
+}
+
+type tcpProbeYaml struct {
+	Port int `yaml:"port"`
 }
 
 type hookYaml struct {
@@ -117,6 +139,7 @@ type hookYaml struct {
 	SlotNames    []string           `yaml:"slots,omitempty"`
 	Environment  strutil.OrderedMap `yaml:"environment,omitempty"`
 	CommandChain []string           `yaml:"command-chain,omitempty"`
+	Interval     timeout.Timeout    `yaml:"interval,omitempty"`
 }
 
 type componentYaml struct {
@@ -476,6 +499,13 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 		if app.Daemon != "" && app.DaemonScope == "" {
 			app.DaemonScope = SystemDaemon
 		}
+		if yApp.LivenessProbe != nil {
+			probe, err := probeFromYaml(yApp.LivenessProbe, app)
+			if err != nil {
+				return err
+			}
+			app.LivenessProbe = probe
+		}
 
 		snap.Apps[appName] = app
 		for _, alias := range app.LegacyAliases {
@@ -551,6 +581,56 @@ func setAppsFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) error {
 	return nil
 }
 
+func probeFromYaml(yProbe *probeYaml, app *AppInfo) (*ProbeInfo, error) {
+	probe := &ProbeInfo{
+		App:              app,
+		Interval:         DefaultProbeInterval,
+		Timeout:          DefaultProbeTimeout,
+		FailureThreshold: DefaultProbeFailureThreshold,
+		OnFailure:        ProbeActionRestart,
+	}
+	var types []ProbeType
+	if yProbe.HTTP != nil {
+		types = append(types, ProbeHTTP)
+		probe.Port = yProbe.HTTP.Port
+		probe.Path = yProbe.HTTP.Path
+		if probe.Path == "" {
+			probe.Path = "/"
+		}
+	}
+	if yProbe.TCP != nil {
+		types = append(types, ProbeTCP)
+		probe.Port = yProbe.TCP.Port
+	}
+	if yProbe.Exec != "" {
+		types = append(types, ProbeExec)
+		probe.Command = yProbe.Exec
+	}
+	if len(types) > 1 {
+		return nil, fmt.Errorf("cannot use more than one of http, tcp and exec in the liveness probe of app %q", app.Name)
+	}
+	if len(types) == 1 {
+		probe.Type = types[0]
+	}
+	if yProbe.Interval != 0 {
+		probe.Interval = time.Duration(yProbe.Interval)
+		// the timeout cannot be longer than the interval
+		if yProbe.Timeout == 0 && probe.Interval < probe.Timeout {
+			probe.Timeout = probe.Interval
+		}
+	}
+	if yProbe.Timeout != 0 {
+		probe.Timeout = time.Duration(yProbe.Timeout)
+	}
+	if yProbe.FailureThreshold != 0 {
+		probe.FailureThreshold = yProbe.FailureThreshold
+	}
+	if yProbe.OnFailure != "" {
+		probe.OnFailure = yProbe.OnFailure
+	}
+	return probe, nil
+}
+
 func setHooksFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) {
 	for hookName, yHook := range y.Hooks {
 		if !IsHookSupported(hookName) {
@@ -563,6 +643,7 @@ func setHooksFromSnapYaml(y snapYaml, snap *Info, strk *scopedTracker) {
 			Name:         hookName,
 			Environment:  yHook.Environment,
 			CommandChain: yHook.CommandChain,
+			Interval:     time.Duration(yHook.Interval),
 			Explicit:     true,
 		}
 		if len(y.Plugs) > 0 || len(yHook.PlugNames) > 0 {
@@ -1844,6 +1844,81 @@ apps:
 	c.Check(info.Apps["foo"].WatchdogTimeout, Equals, timeout.Timeout(12*time.Second))
 }
 
+func (s *YamlSuite) TestSnapYamlLivenessProbe(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+  http:
+    daemon: simple
+    liveness-probe:
+      http:
+        port: 8080
+      interval: 2s
+      failure-threshold: 5
+      on-failure: unhealthy
+  tcp:
+    daemon: simple
+    liveness-probe:
+      tcp:
+        port: 5432
+  exec:
+    daemon: simple
+    liveness-probe:
+      exec: bin/check
+      timeout: 10s
+  none:
+    daemon: simple
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+
+	c.Check(info.Apps["http"].LivenessProbe, DeepEquals, &snap.ProbeInfo{
+		App:              info.Apps["http"],
+		Type:             snap.ProbeHTTP,
+		Port:             8080,
+		Path:             "/",
+		Interval:         2 * time.Second,
+		Timeout:          2 * time.Second,
+		FailureThreshold: 5,
+		OnFailure:        snap.ProbeActionUnhealthy,
+	})
+	c.Check(info.Apps["tcp"].LivenessProbe, DeepEquals, &snap.ProbeInfo{
+		App:              info.Apps["tcp"],
+		Type:             snap.ProbeTCP,
+		Port:             5432,
+		Interval:         snap.DefaultProbeInterval,
+		Timeout:          snap.DefaultProbeTimeout,
+		FailureThreshold: snap.DefaultProbeFailureThreshold,
+		OnFailure:        snap.ProbeActionRestart,
+	})
+	c.Check(info.Apps["exec"].LivenessProbe, DeepEquals, &snap.ProbeInfo{
+		App:              info.Apps["exec"],
+		Type:             snap.ProbeExec,
+		Command:          "bin/check",
+		Interval:         snap.DefaultProbeInterval,
+		Timeout:          10 * time.Second,
+		FailureThreshold: snap.DefaultProbeFailureThreshold,
+		OnFailure:        snap.ProbeActionRestart,
+	})
+	c.Check(info.Apps["none"].LivenessProbe, IsNil)
+}
+
+func (s *YamlSuite) TestSnapYamlLivenessProbeTooManyTypes(c *C) {
+	y := []byte(`
+name: foo
+version: 1.0
+apps:
+  foo:
+    daemon: simple
+    liveness-probe:
+      tcp: {port: 80}
+      exec: bin/check
+`)
+	_, err := snap.InfoFromSnapYaml(y)
+	c.Check(err, ErrorMatches, `.*cannot use more than one of http, tcp and exec in the liveness probe of app "foo"`)
+}
+
 func (s *YamlSuite) TestLayout(c *C) {
 	y := []byte(`
 name: foo
@@ -1984,6 +2059,18 @@ hooks:
 	c.Check(hook.CommandChain, DeepEquals, []string{"hookchain1", "hookchain2"})
 }
 
+func (s *YamlSuite) TestSnapYamlCheckHealthInterval(c *C) {
+	y := []byte(`name: wat
+version: 42
+hooks:
+ check-health:
+  interval: 15m
+`)
+	info, err := snap.InfoFromSnapYaml(y)
+	c.Assert(err, IsNil)
+	c.Check(info.Hooks["check-health"].Interval, Equals, 15*time.Minute)
+}
+
 func (s *YamlSuite) TestSnapYamlRestartDelay(c *C) {
 	yAutostart := []byte(`name: wat
 version: 42
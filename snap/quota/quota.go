@@ -83,6 +83,28 @@ type GroupQuotaJournal struct {
 	RatePeriod time.Duration `json:"rate-period,omitempty"`
 }
 
+const (
+	// MemoryEventPolicyNotice only records the memory events of the group
+	// as notices.
+	MemoryEventPolicyNotice = "notice"
+	// MemoryEventPolicyWarn also adds a warning when processes in the
+	// group are killed for running out of memory.
+	MemoryEventPolicyWarn = "warn"
+	// MemoryEventPolicyRestart also restarts the services in the group when
+	// processes in the group are killed for running out of memory.
+	MemoryEventPolicyRestart = "restart"
+)
+
+// ValidateMemoryEventPolicy checks that the given memory event policy is
+// a known one.
+func ValidateMemoryEventPolicy(policy string) error {
+	switch policy {
+	case "", MemoryEventPolicyNotice, MemoryEventPolicyWarn, MemoryEventPolicyRestart:
+		return nil
+	}
+	return fmt.Errorf("invalid memory event policy %q", policy)
+}
+
 // Group is a quota group of snaps, services or sub-groups that are all subject
 // to specific resource quotas. The only quota resource types currently
 // supported is memory, but this can be expanded in the future.
@@ -109,6 +131,21 @@ type Group struct {
 	// ExhaustionBehavior. MemoryLimit is expressed in bytes.
 	MemoryLimit quantity.Size `json:"memory-limit,omitempty"`
 
+	// MemoryHighLimit is the memory usage above which the processes in the
+	// group are throttled and put under heavy reclaim pressure, which allows
+	// bursty workloads to go over it for a short while without being
+	// killed. MemoryHighLimit is expressed in bytes.
+	MemoryHighLimit quantity.Size `json:"memory-high-limit,omitempty"`
+
+	// MemorySwapLimit is the limit of swap available to the processes in the
+	// group. MemorySwapLimit is expressed in bytes.
+	MemorySwapLimit quantity.Size `json:"memory-swap-limit,omitempty"`
+
+	// MemoryEventPolicy is what is done, besides recording a notice, when
+	// the processes in the group hit the MemoryLimit. It is one of the
+	// MemoryEventPolicy* values, empty meaning MemoryEventPolicyNotice.
+	MemoryEventPolicy string `json:"memory-event-policy,omitempty"`
+
 	// CPULimit is the quotas for the cpu and consists of a couple of nubs.
 	// It is possible to control the percentage of the cpu available for the group
 	// and which cores (requires cgroupsv2) are allowed to be used.
@@ -124,6 +161,11 @@ type Group struct {
 	// journald.
 	JournalLimit *GroupQuotaJournal `json:"journal-limit,omitempty"`
 
+	// IOLimit is the block I/O bandwidth and IOPS limits for the processes in
+	// the group, set per device. Once a limit is reached, further I/O to the
+	// device is throttled.
+	IOLimit []ResourceIODevice `json:"io-limit,omitempty"`
+
 	// ParentGroup is the the parent group that this group is a child of. If it
 	// is empty, then this is a "root" quota group.
 	ParentGroup string `json:"parent-group,omitempty"`
@@ -165,6 +207,12 @@ func (grp *Group) GetQuotaResources() Resources {
 	if grp.MemoryLimit != 0 {
 		resourcesBuilder.WithMemoryLimit(grp.MemoryLimit)
 	}
+	if grp.MemoryHighLimit != 0 {
+		resourcesBuilder.WithMemoryHighLimit(grp.MemoryHighLimit)
+	}
+	if grp.MemorySwapLimit != 0 {
+		resourcesBuilder.WithMemorySwapLimit(grp.MemorySwapLimit)
+	}
 	if grp.CPULimit != nil {
 		if grp.CPULimit.Count != 0 {
 			resourcesBuilder.WithCPUCount(grp.CPULimit.Count)
@@ -192,6 +240,20 @@ func (grp *Group) GetQuotaResources() Resources {
 			resourcesBuilder.WithJournalRate(grp.JournalLimit.RateCount, grp.JournalLimit.RatePeriod)
 		}
 	}
+	for _, dev := range grp.IOLimit {
+		if dev.ReadBps != 0 {
+			resourcesBuilder.WithIOReadBandwidth(dev.Device, dev.ReadBps)
+		}
+		if dev.WriteBps != 0 {
+			resourcesBuilder.WithIOWriteBandwidth(dev.Device, dev.WriteBps)
+		}
+		if dev.ReadIOPS != 0 {
+			resourcesBuilder.WithIOReadIOPS(dev.Device, dev.ReadIOPS)
+		}
+		if dev.WriteIOPS != 0 {
+			resourcesBuilder.WithIOWriteIOPS(dev.Device, dev.WriteIOPS)
+		}
+	}
 	return resourcesBuilder.Build()
 }
 
@@ -244,6 +306,26 @@ func (grp *Group) CurrentTaskUsage() (int, error) {
 	return int(count), nil
 }
 
+// CurrentIOUsage returns the number of bytes read from and written to block
+// devices by the quota group. For quota groups which do not yet have a
+// backing systemd slice on the system (i.e. quota groups without any snaps in
+// them), the usage is reported as 0.
+func (grp *Group) CurrentIOUsage() (read, write quantity.Size, err error) {
+	sysd := systemd.New(systemd.SystemMode, progress.Null)
+
+	// check if this group is actually active, it could not physically exist yet
+	// since it has no snaps in it
+	isActive, err := sysd.IsActive(grp.SliceFileName())
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isActive {
+		return 0, 0, nil
+	}
+
+	return sysd.CurrentIOUsage(grp.SliceFileName())
+}
+
 // SliceFileName returns the name of the slice file that should be used for this
 // quota group. This name will include all of the group's parents in the name.
 // For example, a group named "bar" that is a child of the "foo" group will have
@@ -274,6 +356,17 @@ func (grp *Group) SliceFileName() string {
 	return buf.String()
 }
 
+// CgroupPath returns the path of the cgroup of the slice of the group,
+// relative to the root of the cgroup hierarchy. Slices of sub-groups are
+// nested in the slices of their parent groups.
+func (grp *Group) CgroupPath() string {
+	var slices []string
+	for g := grp; g != nil; g = g.parentGroup {
+		slices = append([]string{g.SliceFileName()}, slices...)
+	}
+	return filepath.Join(slices...)
+}
+
 // JournalQuotaSet returns true if the group is subject to
 // a journal quota. This should only be used in cases where the caller
 // is interested in knowing if a quota group is affected by a journal
@@ -720,6 +813,46 @@ func (grp *Group) validateThreadResourceFit(allQuotas map[string]*groupQuotaAllo
 	return nil
 }
 
+// largestSubGroupLimit returns the largest limit of the given kind of the
+// sub-groups of the group. Sub-groups without such a limit are subject to
+// the limits of their own sub-groups.
+func (grp *Group) largestSubGroupLimit(groupLimit func(*Group) quantity.Size) quantity.Size {
+	var largest quantity.Size
+	for _, subGroup := range grp.subGroups {
+		limit := groupLimit(subGroup)
+		if limit == 0 {
+			limit = subGroup.largestSubGroupLimit(groupLimit)
+		}
+		largest = maxq(largest, limit)
+	}
+	return largest
+}
+
+// validateCeilingResourceFit verifies that a new limit, which applies to each
+// group on its own instead of being shared between sub-groups like the memory
+// limit, is not smaller than the limits of the same kind of the sub-groups of
+// the group, and not larger than the limit of the nearest parent group which
+// has one.
+func (grp *Group) validateCeilingResourceFit(kind string, limit quantity.Size, groupLimit func(*Group) quantity.Size) error {
+	if largest := grp.largestSubGroupLimit(groupLimit); largest > limit {
+		return fmt.Errorf("group %s limit of %s is too small to fit current subgroup %s limit of %s",
+			kind, limit.IECString(), kind, largest.IECString())
+	}
+
+	parent := grp.parentGroup
+	for parent != nil {
+		if parentLimit := groupLimit(parent); parentLimit != 0 {
+			if limit > parentLimit {
+				return fmt.Errorf("sub-group %s limit of %s is too large to fit inside group %q %s limit of %s",
+					kind, limit.IECString(), parent.Name, kind, parentLimit.IECString())
+			}
+			break
+		}
+		parent = parent.parentGroup
+	}
+	return nil
+}
+
 // validateQuotasFit verifies that the given group's current limits fits correctly
 // into the group's parent group's limits. This is done in multiple steps, where the first
 // one is to get a statistics for the upper-most parent group, to get a combined overview
@@ -741,6 +874,22 @@ func (grp *Group) validateQuotasFit(resourceLimits Resources) error {
 			return err
 		}
 	}
+	if resourceLimits.MemoryHigh != nil {
+		err := grp.validateCeilingResourceFit("memory high", resourceLimits.MemoryHigh.Limit, func(g *Group) quantity.Size {
+			return g.MemoryHighLimit
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if resourceLimits.MemorySwap != nil {
+		err := grp.validateCeilingResourceFit("memory swap", resourceLimits.MemorySwap.Limit, func(g *Group) quantity.Size {
+			return g.MemorySwapLimit
+		})
+		if err != nil {
+			return err
+		}
+	}
 	if resourceLimits.CPU != nil && resourceLimits.CPU.Percentage != 0 {
 		if err := grp.validateCPUResourceFit(allQuotas, resourceLimits); err != nil {
 			return err
@@ -776,6 +925,12 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 	if resourceLimits.Memory != nil {
 		grp.MemoryLimit = resourceLimits.Memory.Limit
 	}
+	if resourceLimits.MemoryHigh != nil {
+		grp.MemoryHighLimit = resourceLimits.MemoryHigh.Limit
+	}
+	if resourceLimits.MemorySwap != nil {
+		grp.MemorySwapLimit = resourceLimits.MemorySwap.Limit
+	}
 	if resourceLimits.CPU != nil {
 		grp.CPULimit = &GroupQuotaCPU{
 			Count:      resourceLimits.CPU.Count,
@@ -804,6 +959,9 @@ func (grp *Group) UpdateQuotaLimits(resourceLimits Resources) error {
 			grp.JournalLimit.RatePeriod = resourceLimits.Journal.Rate.Period
 		}
 	}
+	if resourceLimits.IO != nil {
+		grp.IOLimit = mergeIODevices(grp.IOLimit, resourceLimits.IO.Devices)
+	}
 	return nil
 }
 
@@ -824,6 +982,13 @@ func (grp *Group) validate() error {
 		return err
 	}
 
+	if err := ValidateMemoryEventPolicy(grp.MemoryEventPolicy); err != nil {
+		return err
+	}
+	if grp.MemoryEventPolicy != "" && grp.MemoryLimit == 0 {
+		return fmt.Errorf("cannot use memory event policy without a memory quota")
+	}
+
 	if grp.ParentGroup != "" && grp.Name == grp.ParentGroup {
 		return fmt.Errorf("group has circular parent reference to itself")
 	}
@@ -298,6 +298,39 @@ func (ts *quotaTestSuite) TestComplexSubGroups(c *C) {
 	c.Assert(subsubsub1.SliceFileName(), Equals, "snap.myroot-sub1-subsub1-subsubsub1.slice")
 }
 
+func (ts *quotaTestSuite) TestCgroupPath(c *C) {
+	rootGrp, err := quota.NewGroup("myroot", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(rootGrp.CgroupPath(), Equals, "snap.myroot.slice")
+
+	sub, err := rootGrp.NewSubGroup("sub", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(sub.CgroupPath(), Equals, "snap.myroot.slice/snap.myroot-sub.slice")
+
+	subsub, err := sub.NewSubGroup("subsub", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(subsub.CgroupPath(), Equals, "snap.myroot.slice/snap.myroot-sub.slice/snap.myroot-sub-subsub.slice")
+}
+
+func (ts *quotaTestSuite) TestMemoryEventPolicy(c *C) {
+	for _, policy := range []string{"", "notice", "warn", "restart"} {
+		c.Check(quota.ValidateMemoryEventPolicy(policy), IsNil)
+	}
+	c.Check(quota.ValidateMemoryEventPolicy("reboot"), ErrorMatches, `invalid memory event policy "reboot"`)
+
+	grp, err := quota.NewGroup("foo", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	grp.MemoryEventPolicy = quota.MemoryEventPolicyRestart
+	c.Check(grp.ValidateGroup(), IsNil)
+	grp.MemoryEventPolicy = "reboot"
+	c.Check(grp.ValidateGroup(), ErrorMatches, `invalid memory event policy "reboot"`)
+
+	grp, err = quota.NewGroup("bar", quota.NewResourcesBuilder().WithThreadLimit(32).Build())
+	c.Assert(err, IsNil)
+	grp.MemoryEventPolicy = quota.MemoryEventPolicyWarn
+	c.Check(grp.ValidateGroup(), ErrorMatches, "cannot use memory event policy without a memory quota")
+}
+
 func (ts *quotaTestSuite) TestGroupIsMixableSnapsSubgroups(c *C) {
 	parent, err := quota.NewGroup("parent", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build())
 	c.Assert(err, IsNil)
@@ -1575,6 +1608,124 @@ func (ts *quotaTestSuite) TestJournalQuotasUpdatesCorrectly(c *C) {
 	c.Check(grp1.JournalLimit.RatePeriod, Equals, time.Microsecond*5)
 }
 
+func (ts *quotaTestSuite) TestIOQuotasUpdatesCorrectly(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, DeepEquals, []quota.ResourceIODevice{
+		{Device: "/dev/sda", ReadBps: quantity.SizeMiB},
+	})
+
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().
+		WithIOReadBandwidth("/dev/sda", 512*quantity.SizeKiB).
+		WithIOWriteIOPS("/dev/sda", 100).
+		WithIOReadIOPS("/dev/sdb", 1000).
+		Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.IOLimit, DeepEquals, []quota.ResourceIODevice{
+		{Device: "/dev/sda", ReadBps: 512 * quantity.SizeKiB, WriteIOPS: 100},
+		{Device: "/dev/sdb", ReadIOPS: 1000},
+	})
+	c.Check(grp1.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().
+		WithIOReadBandwidth("/dev/sda", 512*quantity.SizeKiB).
+		WithIOWriteIOPS("/dev/sda", 100).
+		WithIOReadIOPS("/dev/sdb", 1000).
+		Build())
+}
+
+func (ts *quotaTestSuite) TestMemoryHighAndSwapQuotasUpdatesCorrectly(c *C) {
+	grp1, err := quota.NewGroup("groot1", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.MemoryHighLimit, Equals, quantity.SizeGiB)
+	c.Check(grp1.MemorySwapLimit, Equals, quantity.Size(0))
+
+	err = grp1.UpdateQuotaLimits(quota.NewResourcesBuilder().WithMemoryHighLimit(512 * quantity.SizeMiB).WithMemorySwapLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	c.Check(grp1.MemoryLimit, Equals, quantity.SizeGiB)
+	c.Check(grp1.MemoryHighLimit, Equals, 512*quantity.SizeMiB)
+	c.Check(grp1.MemorySwapLimit, Equals, quantity.SizeGiB)
+	c.Check(grp1.GetQuotaResources(), DeepEquals, quota.NewResourcesBuilder().
+		WithMemoryLimit(quantity.SizeGiB).
+		WithMemoryHighLimit(512*quantity.SizeMiB).
+		WithMemorySwapLimit(quantity.SizeGiB).
+		Build())
+}
+
+func (ts *quotaTestSuite) TestNestingOfMemoryHighAndSwapLimits(c *C) {
+	grp1, err := quota.NewGroup("groot", quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB).WithMemorySwapLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+
+	subgrp1, err := grp1.NewSubGroup("cpu-sub", quota.NewResourcesBuilder().WithCPUCount(2).WithCPUPercentage(50).Build())
+	c.Assert(err, IsNil)
+
+	// unlike memory limits, the limits of siblings are not added up
+	_, err = subgrp1.NewSubGroup("high-sub1", quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB).Build())
+	c.Assert(err, IsNil)
+	highgrp, err := subgrp1.NewSubGroup("high-sub2", quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB/2).Build())
+	c.Assert(err, IsNil)
+
+	// but they cannot be larger than the limit of the nearest parent
+	_, err = subgrp1.NewSubGroup("high-sub3", quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB*2).Build())
+	c.Check(err, ErrorMatches, `sub-group memory high limit of 2 GiB is too large to fit inside group "groot" memory high limit of 1 GiB`)
+	_, err = highgrp.NewSubGroup("high-sub-sub", quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB).Build())
+	c.Check(err, ErrorMatches, `sub-group memory high limit of 1 GiB is too large to fit inside group "high-sub2" memory high limit of 512 MiB`)
+	_, err = subgrp1.NewSubGroup("swap-sub", quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeGiB*2).Build())
+	c.Check(err, ErrorMatches, `sub-group memory swap limit of 2 GiB is too large to fit inside group "groot" memory swap limit of 1 GiB`)
+
+	// and the limits of the parent cannot be made smaller than those of
+	// the sub-groups
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB / 4).Build())
+	c.Check(err, ErrorMatches, `group memory high limit of 256 MiB is too small to fit current subgroup memory high limit of 1 GiB`)
+	err = grp1.QuotaUpdateCheck(quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeGiB * 2).Build())
+	c.Check(err, IsNil)
+}
+
+func (ts *quotaTestSuite) TestCurrentIOUsage(c *C) {
+	systemctlCalls := 0
+	r := systemd.MockSystemctl(func(args ...string) ([]byte, error) {
+		systemctlCalls++
+		switch systemctlCalls {
+
+		// inactive case, usage must be 0
+		case 1:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("inactive"), systemctlInactiveServiceError{}
+
+		// active case
+		case 2:
+			c.Assert(args, DeepEquals, []string{"is-active", "snap.group.slice"})
+			return []byte("active"), nil
+		case 3:
+			c.Assert(args, DeepEquals, []string{"show", "--property", "IOReadBytes", "snap.group.slice"})
+			return []byte("IOReadBytes=4096"), nil
+		case 4:
+			c.Assert(args, DeepEquals, []string{"show", "--property", "IOWriteBytes", "snap.group.slice"})
+			return []byte("IOWriteBytes=1024"), nil
+
+		default:
+			c.Errorf("unexpected number of systemctl calls (%d) (current call is %+v)", systemctlCalls, args)
+			return []byte("broken test"), fmt.Errorf("broken test")
+		}
+	})
+	defer r()
+
+	grp1, err := quota.NewGroup("group", quota.NewResourcesBuilder().WithIOWriteBandwidth("/dev/sda", quantity.SizeMiB).Build())
+	c.Assert(err, IsNil)
+
+	// group initially is inactive, so it has no io usage
+	read, write, err := grp1.CurrentIOUsage()
+	c.Check(err, IsNil)
+	c.Check(read, Equals, quantity.Size(0))
+	c.Check(write, Equals, quantity.Size(0))
+	c.Check(systemctlCalls, Equals, 1)
+
+	// now with the slice mocked as active it has real usage
+	read, write, err = grp1.CurrentIOUsage()
+	c.Check(err, IsNil)
+	c.Check(read, Equals, 4*quantity.SizeKiB)
+	c.Check(write, Equals, quantity.SizeKiB)
+	c.Check(systemctlCalls, Equals, 4)
+}
+
 func (ts *quotaTestSuite) TestServiceMapEmptyOnEmptyGroup(c *C) {
 	rootGrp, err := quota.NewGroup("myroot", quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).Build())
 	c.Assert(err, IsNil)
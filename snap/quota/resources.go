@@ -21,6 +21,7 @@ package quota
 
 import (
 	"fmt"
+	"path/filepath"
 	"time"
 
 	"github.com/snapcore/snapd/gadget/quantity"
@@ -43,6 +44,19 @@ type ResourceMemory struct {
 	Limit quantity.Size `json:"limit"`
 }
 
+// ResourceMemoryHigh is the memory usage above which the processes in the
+// group are throttled and put under heavy reclaim pressure. Unlike the limit
+// of ResourceMemory, going over it does not invoke the oom-killer.
+type ResourceMemoryHigh struct {
+	Limit quantity.Size `json:"limit"`
+}
+
+// ResourceMemorySwap is the limit of swap available to the processes in the
+// group.
+type ResourceMemorySwap struct {
+	Limit quantity.Size `json:"limit"`
+}
+
 type ResourceCPU struct {
 	Count      int `json:"count"`
 	Percentage int `json:"percentage"`
@@ -75,15 +89,57 @@ type ResourceJournal struct {
 	Rate *ResourceJournalRate `json:"rate,omitempty"`
 }
 
+// ResourceIODevice is the block I/O limits for a single device, which is
+// given by the path to its device node. A zero value means that there is no
+// limit of that kind for the device.
+type ResourceIODevice struct {
+	Device string `json:"device"`
+	// ReadBps and WriteBps are the maximum bytes per second.
+	ReadBps  quantity.Size `json:"read-bps,omitempty"`
+	WriteBps quantity.Size `json:"write-bps,omitempty"`
+	// ReadIOPS and WriteIOPS are the maximum I/O operations per second.
+	ReadIOPS  int `json:"read-iops,omitempty"`
+	WriteIOPS int `json:"write-iops,omitempty"`
+}
+
+func (d *ResourceIODevice) unset() bool {
+	return d.ReadBps == 0 && d.WriteBps == 0 && d.ReadIOPS == 0 && d.WriteIOPS == 0
+}
+
+// merge applies the limits set in the other device on top of the current
+// ones.
+func (d *ResourceIODevice) merge(other *ResourceIODevice) {
+	if other.ReadBps != 0 {
+		d.ReadBps = other.ReadBps
+	}
+	if other.WriteBps != 0 {
+		d.WriteBps = other.WriteBps
+	}
+	if other.ReadIOPS != 0 {
+		d.ReadIOPS = other.ReadIOPS
+	}
+	if other.WriteIOPS != 0 {
+		d.WriteIOPS = other.WriteIOPS
+	}
+}
+
+// ResourceIO represents the block I/O quotas, which are set per device.
+type ResourceIO struct {
+	Devices []ResourceIODevice `json:"devices"`
+}
+
 // Resources are built up of multiple quota limits. Each quota limit is a pointer
 // value to indicate that their presence may be optional, and because we want to detect
 // whenever someone changes a limit to '0' explicitly.
 type Resources struct {
-	Memory  *ResourceMemory  `json:"memory,omitempty"`
-	CPU     *ResourceCPU     `json:"cpu,omitempty"`
-	CPUSet  *ResourceCPUSet  `json:"cpu-set,omitempty"`
-	Threads *ResourceThreads `json:"thread,omitempty"`
-	Journal *ResourceJournal `json:"journal,omitempty"`
+	Memory     *ResourceMemory     `json:"memory,omitempty"`
+	MemoryHigh *ResourceMemoryHigh `json:"memory-high,omitempty"`
+	MemorySwap *ResourceMemorySwap `json:"memory-swap,omitempty"`
+	CPU        *ResourceCPU        `json:"cpu,omitempty"`
+	CPUSet     *ResourceCPUSet     `json:"cpu-set,omitempty"`
+	Threads    *ResourceThreads    `json:"thread,omitempty"`
+	Journal    *ResourceJournal    `json:"journal,omitempty"`
+	IO         *ResourceIO         `json:"io,omitempty"`
 }
 
 const (
@@ -109,6 +165,26 @@ func (qr *Resources) validateMemoryQuota() error {
 	return nil
 }
 
+func (qr *Resources) validateMemoryHighQuota() error {
+	if qr.MemoryHigh.Limit == 0 {
+		return fmt.Errorf("memory high quota must have a limit set")
+	}
+	// throttling above the memory limit would never happen, as the
+	// oom-killer is invoked first
+	if qr.Memory != nil && qr.MemoryHigh.Limit > qr.Memory.Limit {
+		return fmt.Errorf("memory high limit of %s must not be larger than the memory limit of %s",
+			qr.MemoryHigh.Limit.IECString(), qr.Memory.Limit.IECString())
+	}
+	return nil
+}
+
+func (qr *Resources) validateMemorySwapQuota() error {
+	if qr.MemorySwap.Limit == 0 {
+		return fmt.Errorf("memory swap quota must have a limit set")
+	}
+	return nil
+}
+
 func cpuFitsIntoCPUSet(count, percentage int, cpuSet []int) error {
 	if len(cpuSet) > 0 && count != 0 {
 		maxCPUUsage := len(cpuSet) * 100
@@ -186,6 +262,31 @@ func (qr *Resources) validateJournalQuota() error {
 	return nil
 }
 
+func (qr *Resources) validateIOQuota() error {
+	if len(qr.IO.Devices) == 0 {
+		return fmt.Errorf("io quota must have at least one device set")
+	}
+
+	seen := make(map[string]bool, len(qr.IO.Devices))
+	for _, dev := range qr.IO.Devices {
+		if !filepath.IsAbs(dev.Device) || filepath.Clean(dev.Device) != dev.Device {
+			return fmt.Errorf("invalid io quota device %q: must be a clean absolute path", dev.Device)
+		}
+		if seen[dev.Device] {
+			return fmt.Errorf("invalid io quota with device %q set more than once", dev.Device)
+		}
+		seen[dev.Device] = true
+
+		if dev.ReadIOPS < 0 || dev.WriteIOPS < 0 {
+			return fmt.Errorf("invalid io quota for device %q with a negative iops limit", dev.Device)
+		}
+		if dev.unset() {
+			return fmt.Errorf("io quota for device %q must have a limit set", dev.Device)
+		}
+	}
+	return nil
+}
+
 // CheckFeatureRequirements checks if the current system meets the
 // requirements for the given resource request.
 //
@@ -199,7 +300,26 @@ func (qr *Resources) CheckFeatureRequirements() error {
 			return fmt.Errorf("cannot use CPU set with cgroup version %d", cgroupVer)
 		}
 	}
-	if qr.Memory != nil && cgroupCheckMemoryCgroupErr != nil {
+	if qr.IO != nil {
+		// io.max is only available with the unified hierarchy
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use io quota with cgroup version %d", cgroupVer)
+		}
+	}
+	if qr.MemoryHigh != nil || qr.MemorySwap != nil {
+		// memory.high and memory.swap.max are only available with the
+		// unified hierarchy
+		if cgroupVerErr != nil {
+			return cgroupVerErr
+		}
+		if cgroupVer < 2 {
+			return fmt.Errorf("cannot use memory high or swap quota with cgroup version %d", cgroupVer)
+		}
+	}
+	if (qr.Memory != nil || qr.MemoryHigh != nil || qr.MemorySwap != nil) && cgroupCheckMemoryCgroupErr != nil {
 		return fmt.Errorf("cannot use memory quota: %v", cgroupCheckMemoryCgroupErr)
 	}
 
@@ -228,6 +348,18 @@ func (qr *Resources) Validate() error {
 		}
 	}
 
+	if qr.MemoryHigh != nil {
+		if err := qr.validateMemoryHighQuota(); err != nil {
+			return err
+		}
+	}
+
+	if qr.MemorySwap != nil {
+		if err := qr.validateMemorySwapQuota(); err != nil {
+			return err
+		}
+	}
+
 	if qr.CPU != nil {
 		if err := qr.validateCPUQuota(); err != nil {
 			return err
@@ -251,6 +383,12 @@ func (qr *Resources) Validate() error {
 			return err
 		}
 	}
+
+	if qr.IO != nil {
+		if err := qr.validateIOQuota(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -280,6 +418,26 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 		}
 	}
 
+	// The memory high limit only throttles the processes in the group, so
+	// unlike the memory limit it can be both increased and decreased.
+	if newLimits.MemoryHigh != nil {
+		if qr.MemoryHigh != nil && newLimits.MemoryHigh.Limit == 0 {
+			return fmt.Errorf("cannot remove memory high limit from quota group")
+		}
+
+		if newLimits.MemoryHigh.Limit <= memoryLimitMin {
+			return fmt.Errorf("memory high limit %d is too small: size must be larger than %s",
+				newLimits.MemoryHigh.Limit, memoryLimitMin.IECString())
+		}
+	}
+
+	// Check that the swap limit is not being removed
+	if qr.MemorySwap != nil && newLimits.MemorySwap != nil {
+		if newLimits.MemorySwap.Limit == 0 {
+			return fmt.Errorf("cannot remove memory swap limit from quota group")
+		}
+	}
+
 	// Check that the cpu limit is not being removed, and we want to verify the new limit
 	// is valid.
 	if qr.CPU != nil && newLimits.CPU != nil {
@@ -347,6 +505,16 @@ func (qr *Resources) ValidateChange(newLimits Resources) error {
 		// rate-limit for the group, overriding the journal default which is 10000/30s
 	}
 
+	// The io limits of a device are merged with the current ones, so zero
+	// values keep the current limits, and they can be both increased and
+	// decreased as the io controller applies them immediately. Devices
+	// given in the change must still have a limit set.
+	if newLimits.IO != nil {
+		if err := newLimits.validateIOQuota(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -356,6 +524,12 @@ func (qr *Resources) clone() Resources {
 	if qr.Memory != nil {
 		resourcesCopy.Memory = &ResourceMemory{Limit: qr.Memory.Limit}
 	}
+	if qr.MemoryHigh != nil {
+		resourcesCopy.MemoryHigh = &ResourceMemoryHigh{Limit: qr.MemoryHigh.Limit}
+	}
+	if qr.MemorySwap != nil {
+		resourcesCopy.MemorySwap = &ResourceMemorySwap{Limit: qr.MemorySwap.Limit}
+	}
 	if qr.CPU != nil {
 		resourcesCopy.CPU = &ResourceCPU{Count: qr.CPU.Count, Percentage: qr.CPU.Percentage}
 	}
@@ -374,6 +548,9 @@ func (qr *Resources) clone() Resources {
 			resourcesCopy.Journal.Rate = &ResourceJournalRate{Count: qr.Journal.Rate.Count, Period: qr.Journal.Rate.Period}
 		}
 	}
+	if qr.IO != nil {
+		resourcesCopy.IO = &ResourceIO{Devices: append([]ResourceIODevice(nil), qr.IO.Devices...)}
+	}
 	return resourcesCopy
 }
 
@@ -382,6 +559,12 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 	if newLimits.Memory != nil {
 		qr.Memory = newLimits.Memory
 	}
+	if newLimits.MemoryHigh != nil {
+		qr.MemoryHigh = newLimits.MemoryHigh
+	}
+	if newLimits.MemorySwap != nil {
+		qr.MemorySwap = newLimits.MemorySwap
+	}
 	if newLimits.CPU != nil {
 		qr.CPU = newLimits.CPU
 	}
@@ -402,6 +585,32 @@ func (qr *Resources) changeInternal(newLimits Resources) {
 			qr.Journal.Rate = newLimits.Journal.Rate
 		}
 	}
+	if newLimits.IO != nil {
+		if qr.IO == nil {
+			qr.IO = &ResourceIO{}
+		}
+		qr.IO.Devices = mergeIODevices(qr.IO.Devices, newLimits.IO.Devices)
+	}
+}
+
+// mergeIODevices returns the current device limits with the new limits
+// applied on top, adding devices not limited before.
+func mergeIODevices(current, newDevices []ResourceIODevice) []ResourceIODevice {
+	merged := append([]ResourceIODevice(nil), current...)
+	for _, newDev := range newDevices {
+		found := false
+		for i := range merged {
+			if merged[i].Device == newDev.Device {
+				merged[i].merge(&newDev)
+				found = true
+				break
+			}
+		}
+		if !found {
+			merged = append(merged, newDev)
+		}
+	}
+	return merged
 }
 
 // Change updates the current quota limits with the new limits. Additional verification
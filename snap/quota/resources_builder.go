@@ -29,6 +29,12 @@ type ResourcesBuilder struct {
 	MemoryLimit    quantity.Size
 	MemoryLimitSet bool
 
+	MemoryHighLimit    quantity.Size
+	MemoryHighLimitSet bool
+
+	MemorySwapLimit    quantity.Size
+	MemorySwapLimitSet bool
+
 	CPUCount    int
 	CPUCountSet bool
 
@@ -49,6 +55,9 @@ type ResourcesBuilder struct {
 	JournalRateCountLimit  int
 	JournalRatePeriodLimit time.Duration
 	JournalRateSet         bool
+
+	IODevices []ResourceIODevice
+	IOSet     bool
 }
 
 func (rb *ResourcesBuilder) WithMemoryLimit(limit quantity.Size) *ResourcesBuilder {
@@ -57,6 +66,18 @@ func (rb *ResourcesBuilder) WithMemoryLimit(limit quantity.Size) *ResourcesBuild
 	return rb
 }
 
+func (rb *ResourcesBuilder) WithMemoryHighLimit(limit quantity.Size) *ResourcesBuilder {
+	rb.MemoryHighLimit = limit
+	rb.MemoryHighLimitSet = true
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithMemorySwapLimit(limit quantity.Size) *ResourcesBuilder {
+	rb.MemorySwapLimit = limit
+	rb.MemorySwapLimitSet = true
+	return rb
+}
+
 func (rb *ResourcesBuilder) WithCPUCount(count int) *ResourcesBuilder {
 	rb.CPUCount = count
 	rb.CPUCountSet = true
@@ -99,6 +120,39 @@ func (rb *ResourcesBuilder) WithJournalRate(count int, period time.Duration) *Re
 	return rb
 }
 
+// ioDevice returns the io limits of the given device, adding them if the
+// device was not seen before.
+func (rb *ResourcesBuilder) ioDevice(device string) *ResourceIODevice {
+	rb.IOSet = true
+	for i := range rb.IODevices {
+		if rb.IODevices[i].Device == device {
+			return &rb.IODevices[i]
+		}
+	}
+	rb.IODevices = append(rb.IODevices, ResourceIODevice{Device: device})
+	return &rb.IODevices[len(rb.IODevices)-1]
+}
+
+func (rb *ResourcesBuilder) WithIOReadBandwidth(device string, limit quantity.Size) *ResourcesBuilder {
+	rb.ioDevice(device).ReadBps = limit
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWriteBandwidth(device string, limit quantity.Size) *ResourcesBuilder {
+	rb.ioDevice(device).WriteBps = limit
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOReadIOPS(device string, limit int) *ResourcesBuilder {
+	rb.ioDevice(device).ReadIOPS = limit
+	return rb
+}
+
+func (rb *ResourcesBuilder) WithIOWriteIOPS(device string, limit int) *ResourcesBuilder {
+	rb.ioDevice(device).WriteIOPS = limit
+	return rb
+}
+
 func (rb *ResourcesBuilder) Build() Resources {
 	var quotaResources Resources
 	if rb.MemoryLimitSet {
@@ -106,6 +160,16 @@ func (rb *ResourcesBuilder) Build() Resources {
 			Limit: rb.MemoryLimit,
 		}
 	}
+	if rb.MemoryHighLimitSet {
+		quotaResources.MemoryHigh = &ResourceMemoryHigh{
+			Limit: rb.MemoryHighLimit,
+		}
+	}
+	if rb.MemorySwapLimitSet {
+		quotaResources.MemorySwap = &ResourceMemorySwap{
+			Limit: rb.MemorySwapLimit,
+		}
+	}
 	if rb.CPUCountSet || rb.CPUPercentageSet {
 		quotaResources.CPU = &ResourceCPU{
 			Count:      rb.CPUCount,
@@ -136,6 +200,11 @@ func (rb *ResourcesBuilder) Build() Resources {
 			}
 		}
 	}
+	if rb.IOSet {
+		quotaResources.IO = &ResourceIO{
+			Devices: append([]ResourceIODevice(nil), rb.IODevices...),
+		}
+	}
 	return quotaResources
 }
 
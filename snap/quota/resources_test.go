@@ -41,6 +41,9 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 	}{
 		{quota.NewResourcesBuilder().Build(), `quota group must have at least one resource limit set`},
 		{quota.NewResourcesBuilder().WithMemoryLimit(0).Build(), `memory quota must have a limit set`},
+		{quota.NewResourcesBuilder().WithMemoryHighLimit(0).Build(), `memory high quota must have a limit set`},
+		{quota.NewResourcesBuilder().WithMemorySwapLimit(0).Build(), `memory swap quota must have a limit set`},
+		{quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).WithMemoryHighLimit(2 * quantity.SizeMiB).Build(), `memory high limit of 2 MiB must not be larger than the memory limit of 1 MiB`},
 		{quota.NewResourcesBuilder().WithCPUPercentage(0).Build(), `invalid cpu quota with a cpu quota of 0`},
 		{quota.NewResourcesBuilder().WithCPUSet(nil).Build(), `cpu-set quota must not be empty`},
 		{quota.NewResourcesBuilder().WithThreadLimit(0).Build(), `invalid thread quota with a thread count of 0`},
@@ -50,6 +53,15 @@ func (s *resourcesTestSuite) TestQuotaValidationFails(c *C) {
 		{quota.NewResourcesBuilder().WithJournalRate(0, 1).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Nanosecond).Build(), `journal quota must have a period of at least 1 microsecond \(minimum resolution\)`},
 		{quota.NewResourcesBuilder().WithJournalSize(0).Build(), `journal size quota must have a limit set`},
+		{quota.Resources{IO: &quota.ResourceIO{}}, `io quota must have at least one device set`},
+		{quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", 0).Build(), `io quota for device "/dev/sda" must have a limit set`},
+		{quota.NewResourcesBuilder().WithIOReadIOPS("/dev/sda", -1).Build(), `invalid io quota for device "/dev/sda" with a negative iops limit`},
+		{quota.NewResourcesBuilder().WithIOWriteIOPS("sda", 10).Build(), `invalid io quota device "sda": must be a clean absolute path`},
+		{quota.NewResourcesBuilder().WithIOWriteIOPS("/dev/../sda", 10).Build(), `invalid io quota device "/dev/../sda": must be a clean absolute path`},
+		{quota.Resources{IO: &quota.ResourceIO{Devices: []quota.ResourceIODevice{
+			{Device: "/dev/sda", ReadIOPS: 10},
+			{Device: "/dev/sda", WriteIOPS: 10},
+		}}}, `invalid io quota with device "/dev/sda" set more than once`},
 	}
 
 	for _, t := range tests {
@@ -69,6 +81,24 @@ func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1(c *C)
 	// cpu set with cgroup v1 is not supported
 	bad := quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build()
 	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use CPU set with cgroup version 1")
+
+	// neither is io
+	bad = quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).Build()
+	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use io quota with cgroup version 1")
+
+	// neither are memory high and swap limits
+	bad = quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeMiB).Build()
+	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use memory high or swap quota with cgroup version 1")
+	bad = quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeMiB).Build()
+	c.Check(bad.CheckFeatureRequirements(), ErrorMatches, "cannot use memory high or swap quota with cgroup version 1")
+}
+
+func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsIOCgroupv2(c *C) {
+	r := quota.MockCgroupVer(2)
+	defer r()
+
+	good := quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).Build()
+	c.Check(good.CheckFeatureRequirements(), IsNil)
 }
 
 func (s *resourcesTestSuite) TestResourceCheckFeatureRequirementsCgroupv1Err(c *C) {
@@ -89,12 +119,16 @@ func (s *resourcesTestSuite) TestQuotaValidationPasses(c *C) {
 		limits quota.Resources
 	}{
 		{quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build()},
+		{quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeMiB).Build()},
+		{quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeMiB).Build()},
+		{quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).WithMemoryHighLimit(quantity.SizeMiB).WithMemorySwapLimit(quantity.SizeGiB).Build()},
 		{quota.NewResourcesBuilder().WithCPUCount(1).WithCPUPercentage(50).Build()},
 		{quota.NewResourcesBuilder().WithCPUSet([]int{0, 1}).Build()},
 		{quota.NewResourcesBuilder().WithThreadLimit(16).Build()},
 		{quota.NewResourcesBuilder().WithJournalSize(quantity.SizeMiB).Build()},
 		{quota.NewResourcesBuilder().WithJournalRate(1, time.Microsecond).Build()},
 		{quota.NewResourcesBuilder().WithJournalNamespace().Build()},
+		{quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).WithIOWriteIOPS("/dev/sdb", 100).Build()},
 	}
 
 	for _, t := range tests {
@@ -114,6 +148,26 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationFails(c *C) {
 			quota.NewResourcesBuilder().WithMemoryLimit(0).Build(),
 			`cannot remove memory limit from quota group`,
 		},
+		{
+			quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryHighLimit(0).Build(),
+			`cannot remove memory high limit from quota group`,
+		},
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryHighLimit(quantity.SizeKiB).Build(),
+			`memory high limit 1024 is too small: size must be larger than 640 KiB`,
+		},
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryHighLimit(2 * quantity.SizeMiB).Build(),
+			`memory high limit of 2 MiB must not be larger than the memory limit of 1 MiB`,
+		},
+		{
+			quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemorySwapLimit(0).Build(),
+			`cannot remove memory swap limit from quota group`,
+		},
 		{
 			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build(),
 			quota.NewResourcesBuilder().WithMemoryLimit(5 * quantity.SizeKiB).Build(),
@@ -205,6 +259,16 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationFails(c *C) {
 			quota.NewResourcesBuilder().WithJournalSize(5 * quantity.SizeGiB).Build(),
 			`journal size quota must be smaller than 4 GiB`,
 		},
+		{
+			quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", 0).Build(),
+			`io quota for device "/dev/sda" must have a limit set`,
+		},
+		{
+			quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithIOReadIOPS("/dev/sdb", -5).Build(),
+			`invalid io quota for device "/dev/sdb" with a negative iops limit`,
+		},
 	}
 
 	for _, t := range tests {
@@ -303,6 +367,28 @@ func (s *resourcesTestSuite) TestQuotaChangeValidationPasses(c *C) {
 			quota.NewResourcesBuilder().WithJournalNamespace().Build(),
 			quota.NewResourcesBuilder().WithCPUCount(4).WithCPUPercentage(25).WithJournalNamespace().Build(),
 		},
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithIOWriteBandwidth("/dev/sda", quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeMiB).WithIOWriteBandwidth("/dev/sda", quantity.SizeMiB).Build(),
+		},
+		// memory high and swap limits can be decreased
+		{
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryHighLimit(512 * quantity.SizeMiB).WithMemorySwapLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithMemoryLimit(quantity.SizeGiB).WithMemoryHighLimit(512 * quantity.SizeMiB).WithMemorySwapLimit(quantity.SizeGiB).Build(),
+		},
+		{
+			quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeGiB).Build(),
+			quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeMiB).Build(),
+			quota.NewResourcesBuilder().WithMemorySwapLimit(quantity.SizeMiB).Build(),
+		},
+		// io limits can be decreased, and are merged per device
+		{
+			quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeGiB).WithIOReadIOPS("/dev/sda", 500).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).WithIOWriteIOPS("/dev/sdb", 100).Build(),
+			quota.NewResourcesBuilder().WithIOReadBandwidth("/dev/sda", quantity.SizeMiB).WithIOReadIOPS("/dev/sda", 500).WithIOWriteIOPS("/dev/sdb", 100).Build(),
+		},
 	}
 
 	for _, t := range tests {
@@ -29,6 +29,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"github.com/snapcore/snapd/osutil"
@@ -209,9 +210,22 @@ func ValidateHook(hook *HookInfo) error {
 		}
 	}
 
+	if hook.Interval != 0 {
+		if hook.Name != "check-health" {
+			return fmt.Errorf("cannot specify an interval for hook %q, only for check-health", hook.Name)
+		}
+		if hook.Interval < MinHealthCheckInterval {
+			return fmt.Errorf("check-health hook interval must be at least %v, not %v", MinHealthCheckInterval, hook.Interval)
+		}
+	}
+
 	return nil
 }
 
+// MinHealthCheckInterval is the shortest interval the check-health hook can be
+// run at periodically.
+const MinHealthCheckInterval = time.Minute
+
 // ValidateAlias checks if a string can be used as an alias name.
 func ValidateAlias(alias string) error {
 	return naming.ValidateAlias(alias)
@@ -749,6 +763,52 @@ func validateAppOrderNames(app *AppInfo, dependencies []string) error {
 	return nil
 }
 
+func validateAppLivenessProbe(app *AppInfo) error {
+	probe := app.LivenessProbe
+	if probe == nil {
+		return nil
+	}
+	if !app.IsService() {
+		return errors.New("liveness-probe is only applicable to services")
+	}
+	if app.DaemonScope != SystemDaemon {
+		return errors.New("liveness-probe is only supported for system services")
+	}
+
+	switch probe.Type {
+	case ProbeHTTP, ProbeTCP:
+		if probe.Port < 1 || probe.Port > 65535 {
+			return fmt.Errorf("liveness-probe port must be between 1 and 65535, not %d", probe.Port)
+		}
+		if probe.Type == ProbeHTTP && !strings.HasPrefix(probe.Path, "/") {
+			return fmt.Errorf("liveness-probe path must start with /, not %q", probe.Path)
+		}
+	case ProbeExec:
+		if err := validateField("liveness-probe exec", probe.Command, appContentWhitelist); err != nil {
+			return err
+		}
+	default:
+		return errors.New("liveness-probe must use one of http, tcp or exec")
+	}
+
+	if probe.Interval < time.Second {
+		return fmt.Errorf("liveness-probe interval must be at least 1s, not %v", probe.Interval)
+	}
+	if probe.Timeout <= 0 || probe.Timeout > probe.Interval {
+		return fmt.Errorf("liveness-probe timeout must be positive and not longer than the interval, not %v", probe.Timeout)
+	}
+	if probe.FailureThreshold < 1 {
+		return fmt.Errorf("liveness-probe failure-threshold must be at least 1, not %d", probe.FailureThreshold)
+	}
+	switch probe.OnFailure {
+	case ProbeActionRestart, ProbeActionUnhealthy:
+		// valid
+	default:
+		return fmt.Errorf("liveness-probe on-failure must be restart or unhealthy, not %q", probe.OnFailure)
+	}
+	return nil
+}
+
 func validateAppTimeouts(app *AppInfo) error {
 	type T struct {
 		desc    string
@@ -964,6 +1024,10 @@ func ValidateApp(app *AppInfo) error {
 		return err
 	}
 
+	if err := validateAppLivenessProbe(app); err != nil {
+		return err
+	}
+
 	// validate stop-mode
 	if err := app.StopMode.Validate(); err != nil {
 		return err
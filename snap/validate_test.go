@@ -25,6 +25,7 @@ import (
 	"sort"
 	"strconv"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	. "gopkg.in/check.v1"
@@ -145,6 +146,7 @@ func (s *ValidateSuite) TestValidateHook(c *C) {
 		{Name: "a-aa"},
 		{Name: "a-b-c"},
 		{Name: "valid", CommandChain: []string{"valid"}},
+		{Name: "check-health", Interval: time.Minute},
 	}
 	for _, hook := range validHooks {
 		err := ValidateHook(hook)
@@ -173,6 +175,11 @@ func (s *ValidateSuite) TestValidateHook(c *C) {
 		err := ValidateHook(hook)
 		c.Assert(err, ErrorMatches, `hook command-chain contains illegal.*`)
 	}
+
+	err := ValidateHook(&HookInfo{Name: "configure", Interval: time.Hour})
+	c.Check(err, ErrorMatches, `cannot specify an interval for hook "configure", only for check-health`)
+	err = ValidateHook(&HookInfo{Name: "check-health", Interval: 30 * time.Second})
+	c.Check(err, ErrorMatches, `check-health hook interval must be at least 1m0s, not 30s`)
 }
 
 // ValidateApp
@@ -1695,6 +1702,42 @@ apps:
 	}
 }
 
+func (s *ValidateSuite) TestValidateAppLivenessProbe(c *C) {
+	meta := `
+name: foo
+version: 1.0
+apps:
+  foo:
+`
+	for _, tc := range []struct {
+		app, err string
+	}{
+		{"    daemon: simple\n    liveness-probe: {http: {port: 8080, path: /healthz}}", ""},
+		{"    daemon: simple\n    liveness-probe: {tcp: {port: 5432}, interval: 10s, failure-threshold: 1, on-failure: unhealthy}", ""},
+		{"    daemon: simple\n    liveness-probe: {exec: bin/check --quick}", ""},
+		{"    liveness-probe: {tcp: {port: 5432}}", "liveness-probe is only applicable to services"},
+		{"    daemon: simple\n    daemon-scope: user\n    liveness-probe: {tcp: {port: 5432}}", "liveness-probe is only supported for system services"},
+		{"    daemon: simple\n    liveness-probe: {interval: 10s}", "liveness-probe must use one of http, tcp or exec"},
+		{"    daemon: simple\n    liveness-probe: {tcp: {port: 0}}", "liveness-probe port must be between 1 and 65535, not 0"},
+		{"    daemon: simple\n    liveness-probe: {http: {port: 80, path: healthz}}", "liveness-probe path must start with /, not \"healthz\""},
+		{"    daemon: simple\n    liveness-probe: {exec: \"bin/check 'x'\"}", "app description field 'liveness-probe exec' contains illegal .*"},
+		{"    daemon: simple\n    liveness-probe: {tcp: {port: 80}, interval: 100ms}", "liveness-probe interval must be at least 1s, not 100ms"},
+		{"    daemon: simple\n    liveness-probe: {tcp: {port: 80}, timeout: 1m}", "liveness-probe timeout must be positive and not longer than the interval, not 1m0s"},
+		{"    daemon: simple\n    liveness-probe: {tcp: {port: 80}, failure-threshold: -1}", "liveness-probe failure-threshold must be at least 1, not -1"},
+		{"    daemon: simple\n    liveness-probe: {tcp: {port: 80}, on-failure: reboot}", "liveness-probe on-failure must be restart or unhealthy, not \"reboot\""},
+	} {
+		info, err := InfoFromSnapYaml([]byte(meta + tc.app))
+		c.Assert(err, IsNil, Commentf(tc.app))
+
+		err = Validate(info)
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, `invalid definition of application "foo": `+tc.err, Commentf(tc.app))
+		} else {
+			c.Check(err, IsNil, Commentf(tc.app))
+		}
+	}
+}
+
 func (s *YamlSuite) TestValidateAppTimer(c *C) {
 	meta := []byte(`
 name: foo
@@ -113,6 +113,14 @@ func MockDownloadSpeedParams(measureWindow time.Duration, minSpeed float64) (res
 	}
 }
 
+func MockPeerSpeedMeasureWindow(measureWindow time.Duration) (restore func()) {
+	old := peerSpeedMeasureWindow
+	peerSpeedMeasureWindow = measureWindow
+	return func() {
+		peerSpeedMeasureWindow = old
+	}
+}
+
 func IsTransferSpeedError(err error) (ok bool, speed float64) {
 	de, ok := err.(*transferSpeedError)
 	if !ok {
@@ -0,0 +1,934 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package localstore implements a store backed by a local directory, for
+// instance on a mounted USB stick, holding snaps, components and the
+// assertions for them.
+//
+// The repository directory (and its subdirectories) can contain:
+//
+//   - *.snap files, each with a matching snap-revision assertion
+//   - *.comp files, each with matching snap-resource-revision and
+//     snap-resource-pair assertions
+//   - *.assert files with streams of assertions, including the
+//     snap-declaration and account assertions for the snaps
+//   - an optional channels.yaml file mapping snap names to the revisions
+//     released in each channel
+//
+// A channels.yaml file looks like:
+//
+//	hello:
+//	  latest/stable: 42
+//	  latest/edge: 43
+//
+// Snaps that are not listed in channels.yaml have their highest revision
+// available in the repository in all channels.
+//
+// The assertions are not trusted by the local store itself, they are
+// checked against the system assertion database by assertstate, exactly
+// like assertions coming from the snap store.
+package localstore
+
+import (
+	"context"
+	"crypto"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/client"
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/osutil"
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/channel"
+	"github.com/snapcore/snapd/snap/naming"
+	"github.com/snapcore/snapd/snap/snapfile"
+	"github.com/snapcore/snapd/store"
+)
+
+// ChannelsFile is the name of the optional file mapping snaps to the
+// revisions released in each channel.
+const ChannelsFile = "channels.yaml"
+
+// assertionURLPrefix prefixes the stream "URLs" of assertions returned for
+// assertion queries, they are resolved by DownloadAssertions.
+const assertionURLPrefix = "local-assertion:"
+
+var channelRisks = []string{"stable", "candidate", "beta", "edge"}
+
+var snapfileOpen = snapfile.Open
+
+// Store is a store reading snaps, components and assertions from a local
+// directory.
+type Store struct {
+	dir string
+
+	mu sync.Mutex
+	// digests caches the sha3-384 digests of the blobs in the repository
+	digests map[string]blobDigest
+}
+
+type blobDigest struct {
+	size    int64
+	modTime time.Time
+	sha3    string
+}
+
+// New returns a store reading from the given repository directory.
+func New(dir string) *Store {
+	return &Store{
+		dir:     dir,
+		digests: make(map[string]blobDigest),
+	}
+}
+
+// Dir returns the repository directory.
+func (s *Store) Dir() string {
+	return s.dir
+}
+
+// repoSnap is a snap revision found in the repository.
+type repoSnap struct {
+	path    string
+	snapRev *asserts.SnapRevision
+	decl    *asserts.SnapDeclaration
+	comps   []*repoComponent
+}
+
+func (rs *repoSnap) revision() snap.Revision {
+	return snap.R(rs.snapRev.SnapRevision())
+}
+
+// repoComponent is a component revision found in the repository.
+type repoComponent struct {
+	path   string
+	resRev *asserts.SnapResourceRevision
+	// pairs are the snap revisions the component revision can be used
+	// with
+	pairs []int
+}
+
+// index is the content of the repository at a point in time.
+type index struct {
+	assertions map[string]asserts.Assertion
+	// snaps maps snap names to their revisions, from highest to lowest
+	snaps    map[string][]*repoSnap
+	channels map[string]map[string]int
+}
+
+// scan indexes the content of the repository.
+func (s *Store) scan() (*index, error) {
+	var snapPaths, compPaths, assertPaths []string
+	err := filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		switch filepath.Ext(path) {
+		case ".snap":
+			snapPaths = append(snapPaths, path)
+		case ".comp":
+			compPaths = append(compPaths, path)
+		case ".assert":
+			assertPaths = append(assertPaths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("cannot read local repository: %v", err)
+	}
+
+	idx := &index{
+		assertions: make(map[string]asserts.Assertion),
+		snaps:      make(map[string][]*repoSnap),
+	}
+	for _, path := range assertPaths {
+		if err := idx.addAssertions(path); err != nil {
+			return nil, err
+		}
+	}
+	if err := idx.readChannels(filepath.Join(s.dir, ChannelsFile)); err != nil {
+		return nil, err
+	}
+
+	snapRevs := make(map[string]*asserts.SnapRevision)
+	resRevs := make(map[string]*asserts.SnapResourceRevision)
+	decls := make(map[string]*asserts.SnapDeclaration)
+	var pairs []*asserts.SnapResourcePair
+	for _, a := range idx.assertions {
+		switch a := a.(type) {
+		case *asserts.SnapRevision:
+			snapRevs[a.SnapSHA3_384()] = a
+		case *asserts.SnapResourceRevision:
+			resRevs[a.ResourceSHA3_384()] = a
+		case *asserts.SnapDeclaration:
+			decls[a.SnapID()] = a
+		case *asserts.SnapResourcePair:
+			pairs = append(pairs, a)
+		}
+	}
+
+	snapsByID := make(map[string]map[int]*repoSnap)
+	for _, path := range snapPaths {
+		digest, err := s.digest(path)
+		if err != nil {
+			return nil, err
+		}
+		snapRev := snapRevs[digest]
+		if snapRev == nil {
+			logger.Noticef("ignoring %s from local repository: no snap-revision assertion", path)
+			continue
+		}
+		decl := decls[snapRev.SnapID()]
+		if decl == nil {
+			logger.Noticef("ignoring %s from local repository: no snap-declaration assertion", path)
+			continue
+		}
+		rs := &repoSnap{
+			path:    path,
+			snapRev: snapRev,
+			decl:    decl,
+		}
+		if snapsByID[decl.SnapID()] == nil {
+			snapsByID[decl.SnapID()] = make(map[int]*repoSnap)
+		}
+		snapsByID[decl.SnapID()][snapRev.SnapRevision()] = rs
+		idx.snaps[decl.SnapName()] = append(idx.snaps[decl.SnapName()], rs)
+	}
+
+	for _, path := range compPaths {
+		digest, err := s.digest(path)
+		if err != nil {
+			return nil, err
+		}
+		resRev := resRevs[digest]
+		if resRev == nil {
+			logger.Noticef("ignoring %s from local repository: no snap-resource-revision assertion", path)
+			continue
+		}
+		rc := &repoComponent{path: path, resRev: resRev}
+		for _, pair := range pairs {
+			if pair.SnapID() != resRev.SnapID() || pair.ResourceName() != resRev.ResourceName() || pair.ResourceRevision() != resRev.ResourceRevision() {
+				continue
+			}
+			rc.pairs = append(rc.pairs, pair.SnapRevision())
+			if rs := snapsByID[pair.SnapID()][pair.SnapRevision()]; rs != nil {
+				rs.comps = append(rs.comps, rc)
+			}
+		}
+	}
+
+	for _, revs := range idx.snaps {
+		sort.Slice(revs, func(i, j int) bool {
+			return revs[i].snapRev.SnapRevision() > revs[j].snapRev.SnapRevision()
+		})
+	}
+	return idx, nil
+}
+
+func (idx *index) addAssertions(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := asserts.NewDecoder(f)
+	for {
+		a, err := dec.Decode()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("cannot read assertions from %s: %v", path, err)
+		}
+		key := a.Ref().Unique()
+		if prev := idx.assertions[key]; prev != nil && prev.Revision() >= a.Revision() {
+			continue
+		}
+		idx.assertions[key] = a
+	}
+}
+
+func (idx *index) readChannels(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	var channels map[string]map[string]int
+	if err := yaml.Unmarshal(data, &channels); err != nil {
+		return fmt.Errorf("cannot read %s: %v", ChannelsFile, err)
+	}
+	idx.channels = make(map[string]map[string]int, len(channels))
+	for name, releases := range channels {
+		idx.channels[name] = make(map[string]int, len(releases))
+		for ch, rev := range releases {
+			full, err := channel.Full(ch)
+			if err != nil {
+				return fmt.Errorf("cannot read %s: invalid channel %q for snap %q", ChannelsFile, ch, name)
+			}
+			idx.channels[name][full] = rev
+		}
+	}
+	return nil
+}
+
+// digest returns the sha3-384 digest of the blob at path, reusing the one
+// computed earlier if the blob did not change.
+func (s *Store) digest(path string) (string, error) {
+	fi, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	cached, ok := s.digests[path]
+	s.mu.Unlock()
+	if ok && cached.size == fi.Size() && cached.modTime.Equal(fi.ModTime()) {
+		return cached.sha3, nil
+	}
+
+	digest, _, err := asserts.SnapFileSHA3_384(path)
+	if err != nil {
+		return "", fmt.Errorf("cannot compute digest of %s: %v", path, err)
+	}
+
+	s.mu.Lock()
+	s.digests[path] = blobDigest{size: fi.Size(), modTime: fi.ModTime(), sha3: digest}
+	s.mu.Unlock()
+	return digest, nil
+}
+
+// find returns the snap revision to use for the given snap when following
+// the given channel, or the given revision if set.
+func (idx *index) find(name, ch string, rev snap.Revision) (*repoSnap, error) {
+	revs := idx.snaps[name]
+	if len(revs) == 0 {
+		return nil, store.ErrSnapNotFound
+	}
+
+	if !rev.Unset() {
+		for _, rs := range revs {
+			if rs.revision() == rev {
+				return rs, nil
+			}
+		}
+		return nil, &store.RevisionNotAvailableError{}
+	}
+
+	releases, ok := idx.channels[name]
+	if !ok {
+		// not listed, the highest revision is in all channels
+		return revs[0], nil
+	}
+
+	full, err := channel.Full(ch)
+	if err != nil {
+		return nil, err
+	}
+	if full == "" {
+		full = "latest/stable"
+	}
+	// like with the store, a closed risk follows the next more stable one
+	track, risk, branch := splitChannel(full)
+	candidates := []string{full}
+	if branch != "" {
+		candidates = append(candidates, track+"/"+risk)
+	}
+	for i := riskIndex(risk) - 1; i >= 0; i-- {
+		candidates = append(candidates, track+"/"+channelRisks[i])
+	}
+	for _, candidate := range candidates {
+		r, ok := releases[candidate]
+		if !ok {
+			continue
+		}
+		for _, rs := range revs {
+			if rs.revision().N == r {
+				return rs, nil
+			}
+		}
+		return nil, fmt.Errorf("revision %d of snap %q released in %s is missing from the local repository", r, name, candidate)
+	}
+	return nil, &store.RevisionNotAvailableError{Channel: ch}
+}
+
+func splitChannel(full string) (track, risk, branch string) {
+	parts := strings.SplitN(full, "/", 3)
+	track, risk = parts[0], parts[1]
+	if len(parts) == 3 {
+		branch = parts[2]
+	}
+	return track, risk, branch
+}
+
+func riskIndex(risk string) int {
+	for i, r := range channelRisks {
+		if r == risk {
+			return i
+		}
+	}
+	return 0
+}
+
+// info returns the snap.Info for the snap revision, as the store would.
+func (idx *index) info(rs *repoSnap, ch string) (*snap.Info, error) {
+	container, err := snapfileOpen(rs.path)
+	if err != nil {
+		return nil, err
+	}
+	si := &snap.SideInfo{
+		RealName: rs.decl.SnapName(),
+		SnapID:   rs.decl.SnapID(),
+		Revision: rs.revision(),
+		Channel:  ch,
+	}
+	info, err := snap.ReadInfoFromSnapFile(container, si)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read snap from local repository: %v", err)
+	}
+
+	info.Publisher = snap.StoreAccount{ID: rs.decl.PublisherID()}
+	ref := &asserts.Ref{Type: asserts.AccountType, PrimaryKey: []string{rs.decl.PublisherID()}}
+	if acct, ok := idx.assertions[ref.Unique()].(*asserts.Account); ok {
+		info.Publisher.Username = acct.Username()
+		info.Publisher.DisplayName = acct.DisplayName()
+		info.Publisher.Validation = acct.Validation()
+	}
+	info.DownloadInfo = snap.DownloadInfo{
+		DownloadURL: rs.path,
+		Size:        int64(rs.snapRev.SnapSize()),
+		Sha3_384:    hexDigest(rs.snapRev.SnapSHA3_384()),
+	}
+	return info, nil
+}
+
+// hexDigest converts a digest from the encoding used in assertions to the
+// hex one used in download information.
+func hexDigest(digest string) string {
+	raw, err := base64.RawURLEncoding.DecodeString(digest)
+	if err != nil {
+		// cannot happen for digests from decoded assertions
+		return digest
+	}
+	return hex.EncodeToString(raw)
+}
+
+// resources returns the components available for the snap revision.
+func (idx *index) resources(rs *repoSnap) ([]store.SnapResourceResult, error) {
+	var results []store.SnapResourceResult
+	for _, rc := range rs.comps {
+		container, err := snapfileOpen(rc.path)
+		if err != nil {
+			return nil, err
+		}
+		compInfo, err := snap.ReadComponentInfoFromContainer(container, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read component from local repository: %v", err)
+		}
+		results = append(results, store.SnapResourceResult{
+			DownloadInfo: snap.DownloadInfo{
+				DownloadURL: rc.path,
+				Size:        int64(rc.resRev.ResourceSize()),
+				Sha3_384:    hexDigest(rc.resRev.ResourceSHA3_384()),
+			},
+			Type:      "component/" + string(compInfo.Type),
+			Name:      rc.resRev.ResourceName(),
+			Revision:  rc.resRev.ResourceRevision(),
+			Version:   compInfo.Version(""),
+			CreatedAt: rc.resRev.Timestamp().Format(time.RFC3339),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		return results[i].Name < results[j].Name
+	})
+	return results, nil
+}
+
+// SnapAction resolves install, refresh and download actions against the
+// repository, and assertion queries against the assertions in it.
+func (s *Store) SnapAction(ctx context.Context, currentSnaps []*store.CurrentSnap, actions []*store.SnapAction, assertQuery store.AssertionQuery, user *auth.UserState, opts *store.RefreshOptions) ([]store.SnapActionResult, []store.AssertionResult, error) {
+	idx, err := s.scan()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var ars []store.AssertionResult
+	if assertQuery != nil {
+		ars, err = idx.resolveAssertions(assertQuery)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(actions) == 0 {
+		return nil, ars, nil
+	}
+
+	curSnaps := make(map[string]*store.CurrentSnap, len(currentSnaps))
+	for _, cur := range currentSnaps {
+		curSnaps[cur.SnapID] = cur
+	}
+
+	var sars []store.SnapActionResult
+	refreshErrors := make(map[string]error)
+	installErrors := make(map[string]error)
+	downloadErrors := make(map[string]error)
+	for _, a := range actions {
+		switch a.Action {
+		case "refresh":
+			cur := curSnaps[a.SnapID]
+			if cur == nil {
+				return nil, nil, fmt.Errorf("internal error: refresh of snap %q without current snap", a.InstanceName)
+			}
+			ch := a.Channel
+			if ch == "" && a.Revision.Unset() {
+				ch = cur.TrackingChannel
+			}
+			snapName, _ := snap.SplitInstanceName(cur.InstanceName)
+			rs, err := idx.find(snapName, ch, a.Revision)
+			if err != nil {
+				refreshErrors[cur.InstanceName] = err
+				continue
+			}
+			if rs.revision() == cur.Revision && !a.ResourceInstall && !resourcesChanged(cur, rs) || revisionBlocked(rs.revision(), cur.Block) {
+				refreshErrors[cur.InstanceName] = store.ErrNoUpdateAvailable
+				continue
+			}
+			sar, err := idx.actionResult(rs, ch, cur.InstanceName)
+			if err != nil {
+				return nil, nil, err
+			}
+			if !sar.Info.Epoch.CanRead(cur.Epoch) {
+				refreshErrors[cur.InstanceName] = store.ErrNoUpdateAvailable
+				continue
+			}
+			sars = append(sars, sar)
+		case "install", "download":
+			errs := installErrors
+			if a.Action == "download" {
+				errs = downloadErrors
+			}
+			snapName, _ := snap.SplitInstanceName(a.InstanceName)
+			rs, err := idx.find(snapName, a.Channel, a.Revision)
+			if err != nil {
+				errs[a.InstanceName] = err
+				continue
+			}
+			sar, err := idx.actionResult(rs, a.Channel, a.InstanceName)
+			if err != nil {
+				return nil, nil, err
+			}
+			sars = append(sars, sar)
+		default:
+			return nil, nil, fmt.Errorf("internal error: unsupported action %q", a.Action)
+		}
+	}
+
+	if len(refreshErrors)+len(installErrors)+len(downloadErrors) != 0 {
+		// normalize empty maps
+		if len(refreshErrors) == 0 {
+			refreshErrors = nil
+		}
+		if len(installErrors) == 0 {
+			installErrors = nil
+		}
+		if len(downloadErrors) == 0 {
+			downloadErrors = nil
+		}
+		return sars, ars, &store.SnapActionError{
+			NoResults: len(sars) == 0,
+			Refresh:   refreshErrors,
+			Install:   installErrors,
+			Download:  downloadErrors,
+		}
+	}
+	return sars, ars, nil
+}
+
+func (idx *index) actionResult(rs *repoSnap, ch, instanceName string) (store.SnapActionResult, error) {
+	full, err := channel.Full(ch)
+	if err != nil {
+		return store.SnapActionResult{}, err
+	}
+	info, err := idx.info(rs, full)
+	if err != nil {
+		return store.SnapActionResult{}, err
+	}
+	_, info.InstanceKey = snap.SplitInstanceName(instanceName)
+	resources, err := idx.resources(rs)
+	if err != nil {
+		return store.SnapActionResult{}, err
+	}
+	return store.SnapActionResult{Info: info, Resources: resources}, nil
+}
+
+func resourcesChanged(cur *store.CurrentSnap, rs *repoSnap) bool {
+	for _, rc := range rs.comps {
+		curRev, ok := cur.Resources[rc.resRev.ResourceName()]
+		if ok && !curRev.Local() && curRev.N != rc.resRev.ResourceRevision() {
+			return true
+		}
+	}
+	return false
+}
+
+func revisionBlocked(rev snap.Revision, blocked []snap.Revision) bool {
+	for _, b := range blocked {
+		if b == rev {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveAssertions finds the newer assertions in the repository for the
+// ones in the query.
+func (idx *index) resolveAssertions(assertQuery store.AssertionQuery) ([]store.AssertionResult, error) {
+	toResolve, toResolveSeq, err := assertQuery.ToResolve()
+	if err != nil {
+		return nil, err
+	}
+
+	var ars []store.AssertionResult
+	for grouping, atRevs := range toResolve {
+		var urls []string
+		for _, at := range atRevs {
+			a := idx.assertions[at.Ref.Unique()]
+			if a == nil {
+				nf := &asserts.NotFoundError{Type: at.Ref.Type, Headers: headers(at.Ref.Type, at.Ref.PrimaryKey)}
+				if err := assertQuery.AddError(nf, &at.Ref); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			if a.Revision() > at.Revision {
+				urls = append(urls, assertionURLPrefix+at.Ref.Unique())
+			}
+		}
+		if len(urls) > 0 {
+			ars = append(ars, store.AssertionResult{Grouping: grouping, StreamURLs: urls})
+		}
+	}
+	for grouping, atSeqs := range toResolveSeq {
+		var urls []string
+		for _, at := range atSeqs {
+			seqNum := 0
+			if at.Pinned {
+				seqNum = at.Sequence
+			}
+			a, err := idx.seqFormingAssertion(at.Type, at.SequenceKey, seqNum)
+			if err != nil {
+				if err := assertQuery.AddSequenceError(err, at); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			seqA := a.(asserts.SequenceMember)
+			if seqA.Sequence() > at.Sequence || seqA.Sequence() == at.Sequence && a.Revision() > at.Revision {
+				urls = append(urls, assertionURLPrefix+a.Ref().Unique())
+			}
+		}
+		if len(urls) > 0 {
+			ars = append(ars, store.AssertionResult{Grouping: grouping, StreamURLs: urls})
+		}
+	}
+	return ars, nil
+}
+
+func headers(assertType *asserts.AssertionType, primaryKey []string) map[string]string {
+	h := make(map[string]string, len(primaryKey))
+	for i, name := range assertType.PrimaryKey {
+		if i < len(primaryKey) {
+			h[name] = primaryKey[i]
+		}
+	}
+	return h
+}
+
+func (idx *index) seqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int) (asserts.Assertion, error) {
+	if !assertType.SequenceForming() {
+		return nil, fmt.Errorf("internal error: requested non sequence-forming assertion type %q", assertType.Name)
+	}
+	var found asserts.Assertion
+	for _, a := range idx.assertions {
+		if a.Type() != assertType {
+			continue
+		}
+		pk := a.Ref().PrimaryKey
+		if !strSliceEqual(pk[:len(pk)-1], sequenceKey) {
+			continue
+		}
+		seq := a.(asserts.SequenceMember).Sequence()
+		if sequence > 0 && seq != sequence {
+			continue
+		}
+		if found == nil || seq > found.(asserts.SequenceMember).Sequence() {
+			found = a
+		}
+	}
+	if found == nil {
+		hdrs := headers(assertType, sequenceKey)
+		if sequence > 0 {
+			hdrs["sequence"] = fmt.Sprint(sequence)
+		}
+		return nil, &asserts.NotFoundError{Type: assertType, Headers: hdrs}
+	}
+	return found, nil
+}
+
+func strSliceEqual(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// Assertion returns the assertion with the given type and primary key from
+// the repository.
+func (s *Store) Assertion(assertType *asserts.AssertionType, primaryKey []string, user *auth.UserState) (asserts.Assertion, error) {
+	idx, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+	ref := &asserts.Ref{Type: assertType, PrimaryKey: primaryKey}
+	a := idx.assertions[ref.Unique()]
+	if a == nil {
+		return nil, &asserts.NotFoundError{Type: assertType, Headers: headers(assertType, primaryKey)}
+	}
+	return a, nil
+}
+
+// SeqFormingAssertion returns the sequence-forming assertion with the given
+// sequence key and sequence number, or the latest one if sequence is not
+// positive, from the repository.
+func (s *Store) SeqFormingAssertion(assertType *asserts.AssertionType, sequenceKey []string, sequence int, user *auth.UserState) (asserts.Assertion, error) {
+	idx, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+	return idx.seqFormingAssertion(assertType, sequenceKey, sequence)
+}
+
+// DownloadAssertions adds the assertions referred to by streamURLs, as
+// returned by SnapAction, to the batch.
+func (s *Store) DownloadAssertions(streamURLs []string, b *asserts.Batch, user *auth.UserState) error {
+	idx, err := s.scan()
+	if err != nil {
+		return err
+	}
+	for _, u := range streamURLs {
+		a := idx.assertions[strings.TrimPrefix(u, assertionURLPrefix)]
+		if !strings.HasPrefix(u, assertionURLPrefix) || a == nil {
+			return fmt.Errorf("cannot find assertion %q in local repository", u)
+		}
+		if err := b.Add(a); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SnapInfo returns the information about the highest revision of the snap in
+// the repository.
+func (s *Store) SnapInfo(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (*snap.Info, error) {
+	idx, err := s.scan()
+	if err != nil {
+		return nil, err
+	}
+	revs := idx.snaps[spec.Name]
+	if len(revs) == 0 {
+		return nil, store.ErrSnapNotFound
+	}
+	return idx.info(revs[0], "")
+}
+
+// SnapExists checks whether the snap is in the repository.
+func (s *Store) SnapExists(ctx context.Context, spec store.SnapSpec, user *auth.UserState) (naming.SnapRef, *channel.Channel, error) {
+	idx, err := s.scan()
+	if err != nil {
+		return nil, nil, err
+	}
+	revs := idx.snaps[spec.Name]
+	if len(revs) == 0 {
+		return nil, nil, store.ErrSnapNotFound
+	}
+	return naming.NewSnapRef(spec.Name, revs[0].decl.SnapID()), nil, nil
+}
+
+// Download copies the blob described by downloadInfo from the repository to
+// targetPath, checking its digest.
+func (s *Store) Download(ctx context.Context, name string, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *store.DownloadOptions) (err error) {
+	src, err := s.open(downloadInfo)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	if err := os.MkdirAll(filepath.Dir(targetPath), 0755); err != nil {
+		return err
+	}
+	partialPath := targetPath + ".partial"
+	w, err := os.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(partialPath)
+		}
+	}()
+
+	if pbar == nil {
+		pbar = progress.Null
+	}
+	pbar.Start(name, float64(downloadInfo.Size))
+	defer pbar.Finished()
+
+	h := crypto.SHA3_384.New()
+	if _, err := io.Copy(io.MultiWriter(w, h, pbar), src); err != nil {
+		return err
+	}
+	if actual := fmt.Sprintf("%x", h.Sum(nil)); actual != downloadInfo.Sha3_384 {
+		return fmt.Errorf("sha3-384 mismatch for %q from local repository: got %s but expected %s", name, actual, downloadInfo.Sha3_384)
+	}
+	if err := w.Sync(); err != nil {
+		return err
+	}
+	return os.Rename(partialPath, targetPath)
+}
+
+// DownloadStream opens the blob described by downloadInfo in the repository.
+func (s *Store) DownloadStream(ctx context.Context, name string, downloadInfo *snap.DownloadInfo, resume int64, user *auth.UserState) (io.ReadCloser, int, error) {
+	f, err := s.open(downloadInfo)
+	if err != nil {
+		return nil, 0, err
+	}
+	status := 200
+	if resume > 0 {
+		if _, err := f.Seek(resume, io.SeekStart); err != nil {
+			f.Close()
+			return nil, 0, err
+		}
+		status = 206
+	}
+	return f, status, nil
+}
+
+// open opens the blob in the repository, making sure it is inside the
+// repository.
+func (s *Store) open(downloadInfo *snap.DownloadInfo) (*os.File, error) {
+	path := filepath.Clean(downloadInfo.DownloadURL)
+	dir := filepath.Clean(s.dir)
+	if !strings.HasPrefix(path, dir+string(filepath.Separator)) || !osutil.FileExists(path) {
+		return nil, fmt.Errorf("cannot find %q in local repository", downloadInfo.DownloadURL)
+	}
+	return os.Open(path)
+}
+
+// EnsureDeviceSession is a no-op, the local repository needs no session.
+func (s *Store) EnsureDeviceSession() error {
+	return nil
+}
+
+// CleanDownloadsCache is a no-op, the local repository is not cached.
+func (s *Store) CleanDownloadsCache() error {
+	return nil
+}
+
+// SuggestedCurrency returns no currency.
+func (s *Store) SuggestedCurrency() string {
+	return ""
+}
+
+// The operations below need the snap store.
+
+func (s *Store) Find(ctx context.Context, search *store.Search, user *auth.UserState) ([]*snap.Info, error) {
+	return nil, store.ErrStoreOffline
+}
+
+func (s *Store) Sections(ctx context.Context, user *auth.UserState) ([]string, error) {
+	return nil, store.ErrStoreOffline
+}
+
+func (s *Store) Categories(ctx context.Context, user *auth.UserState) ([]store.CategoryDetails, error) {
+	return nil, store.ErrStoreOffline
+}
+
+func (s *Store) WriteCatalogs(ctx context.Context, names io.Writer, adder store.SnapAdder) error {
+	return store.ErrStoreOffline
+}
+
+func (s *Store) DownloadIcon(ctx context.Context, name, targetPath, downloadURL string) error {
+	return store.ErrStoreOffline
+}
+
+func (s *Store) Buy(options *client.BuyOptions, user *auth.UserState) (*client.BuyResult, error) {
+	return nil, store.ErrStoreOffline
+}
+
+func (s *Store) ReadyToBuy(user *auth.UserState) error {
+	return store.ErrStoreOffline
+}
+
+func (s *Store) ConnectivityCheck() (map[string]bool, error) {
+	return nil, store.ErrStoreOffline
+}
+
+func (s *Store) CreateCohorts(ctx context.Context, snaps []string) (map[string]string, error) {
+	return nil, store.ErrStoreOffline
+}
+
+func (s *Store) LoginUser(username, password, otp string) (string, string, error) {
+	return "", "", store.ErrStoreOffline
+}
+
+func (s *Store) UserInfo(email string) (*store.User, error) {
+	return nil, store.ErrStoreOffline
+}
+
+func (s *Store) ExchangeMessages(ctx context.Context, req *store.MessageExchangeRequest) (*store.MessageExchangeResponse, error) {
+	return nil, store.ErrStoreOffline
+}
@@ -0,0 +1,524 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package localstore_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/asserts"
+	"github.com/snapcore/snapd/asserts/assertstest"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/snap/snaptest"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/store/localstore"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type localStoreSuite struct {
+	testutil.BaseTest
+
+	storeSigning *assertstest.StoreStack
+	dev1Acct     *asserts.Account
+
+	dir string
+	sto *localstore.Store
+
+	// yamls maps the blobs in the repository to their snap.yaml or
+	// component.yaml
+	yamls map[string]string
+}
+
+var _ = Suite(&localStoreSuite{})
+
+const helloSnapID = "hellosnapidididididididididididi"
+
+func (s *localStoreSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+	s.AddCleanup(snap.MockSanitizePlugsSlots(func(snapInfo *snap.Info) {}))
+
+	s.storeSigning = assertstest.NewStoreStack("can0nical", nil)
+	s.dev1Acct = assertstest.NewAccount(s.storeSigning, "developer1", map[string]any{
+		"account-id": "dev1-id",
+		"validation": "verified",
+	}, "")
+
+	s.dir = c.MkDir()
+	s.sto = localstore.New(s.dir)
+	s.yamls = make(map[string]string)
+
+	s.AddCleanup(localstore.MockSnapfileOpen(func(path string) (snap.Container, error) {
+		yaml, ok := s.yamls[path]
+		if !ok {
+			return nil, fmt.Errorf("unexpected blob %s", path)
+		}
+		name := "meta/snap.yaml"
+		if filepath.Ext(path) == ".comp" {
+			name = "meta/component.yaml"
+		}
+		return snaptest.MockContainer(c, [][]string{{name, yaml}}), nil
+	}))
+
+	decl, err := s.storeSigning.Sign(asserts.SnapDeclarationType, map[string]any{
+		"series":       "16",
+		"snap-id":      helloSnapID,
+		"snap-name":    "hello",
+		"publisher-id": "dev1-id",
+		"timestamp":    time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, "hello.assert", s.dev1Acct, decl)
+}
+
+func (s *localStoreSuite) writeAssertions(c *C, name string, as ...asserts.Assertion) {
+	buf := &bytes.Buffer{}
+	enc := asserts.NewEncoder(buf)
+	for _, a := range as {
+		c.Assert(enc.Encode(a), IsNil)
+	}
+	c.Assert(os.WriteFile(filepath.Join(s.dir, name), buf.Bytes(), 0644), IsNil)
+}
+
+// addSnap adds revision rev of the hello snap with the given epoch to the
+// repository.
+func (s *localStoreSuite) addSnap(c *C, rev int, epoch string) string {
+	path := filepath.Join(s.dir, fmt.Sprintf("hello_%d.snap", rev))
+	content := fmt.Sprintf("hello snap revision %d", rev)
+	c.Assert(os.WriteFile(path, []byte(content), 0644), IsNil)
+	s.yamls[path] = fmt.Sprintf("name: hello\nversion: %d.0\nepoch: %s\ncomponents:\n  kmod:\n    type: standard\n", rev, epoch)
+
+	digest, size, err := asserts.SnapFileSHA3_384(path)
+	c.Assert(err, IsNil)
+	snapRev, err := s.storeSigning.Sign(asserts.SnapRevisionType, map[string]any{
+		"snap-sha3-384": digest,
+		"snap-id":       helloSnapID,
+		"snap-size":     fmt.Sprint(size),
+		"snap-revision": fmt.Sprint(rev),
+		"developer-id":  "dev1-id",
+		"timestamp":     time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, fmt.Sprintf("hello_%d.assert", rev), snapRev)
+	return path
+}
+
+// addComponent adds revision compRev of the kmod component of the hello snap,
+// to be used with revision snapRev of the snap.
+func (s *localStoreSuite) addComponent(c *C, compRev, snapRev int) string {
+	path := filepath.Join(s.dir, fmt.Sprintf("hello+kmod_%d.comp", compRev))
+	c.Assert(os.WriteFile(path, []byte(fmt.Sprintf("kmod revision %d", compRev)), 0644), IsNil)
+	s.yamls[path] = fmt.Sprintf("component: hello+kmod\ntype: standard\nversion: %d.1\n", compRev)
+
+	digest, size, err := asserts.SnapFileSHA3_384(path)
+	c.Assert(err, IsNil)
+	resRev, err := s.storeSigning.Sign(asserts.SnapResourceRevisionType, map[string]any{
+		"snap-id":           helloSnapID,
+		"resource-name":     "kmod",
+		"resource-sha3-384": digest,
+		"resource-size":     fmt.Sprint(size),
+		"resource-revision": fmt.Sprint(compRev),
+		"developer-id":      "dev1-id",
+		"timestamp":         time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	pair, err := s.storeSigning.Sign(asserts.SnapResourcePairType, map[string]any{
+		"snap-id":           helloSnapID,
+		"resource-name":     "kmod",
+		"resource-revision": fmt.Sprint(compRev),
+		"snap-revision":     fmt.Sprint(snapRev),
+		"developer-id":      "dev1-id",
+		"timestamp":         time.Now().Format(time.RFC3339),
+	}, nil, "")
+	c.Assert(err, IsNil)
+	s.writeAssertions(c, fmt.Sprintf("hello+kmod_%d.assert", compRev), resRev, pair)
+	return path
+}
+
+func (s *localStoreSuite) writeChannels(c *C, content string) {
+	c.Assert(os.WriteFile(filepath.Join(s.dir, localstore.ChannelsFile), []byte(content), 0644), IsNil)
+}
+
+func (s *localStoreSuite) install(c *C, ch string, rev snap.Revision) ([]store.SnapActionResult, error) {
+	sars, _, err := s.sto.SnapAction(context.Background(), nil, []*store.SnapAction{{
+		Action:       "install",
+		InstanceName: "hello",
+		Channel:      ch,
+		Revision:     rev,
+	}}, nil, nil, nil)
+	return sars, err
+}
+
+func (s *localStoreSuite) refresh(c *C, cur *store.CurrentSnap) ([]store.SnapActionResult, error) {
+	sars, _, err := s.sto.SnapAction(context.Background(), []*store.CurrentSnap{cur}, []*store.SnapAction{{
+		Action:       "refresh",
+		InstanceName: cur.InstanceName,
+		SnapID:       cur.SnapID,
+	}}, nil, nil, nil)
+	return sars, err
+}
+
+func (s *localStoreSuite) TestInstallHighestRevision(c *C) {
+	s.addSnap(c, 1, "0")
+	path := s.addSnap(c, 2, "0")
+
+	sars, err := s.install(c, "latest/edge", snap.R(0))
+	c.Assert(err, IsNil)
+	c.Assert(sars, HasLen, 1)
+	info := sars[0].Info
+	c.Check(info.SnapName(), Equals, "hello")
+	c.Check(info.SnapID, Equals, helloSnapID)
+	c.Check(info.Revision, Equals, snap.R(2))
+	c.Check(info.Version, Equals, "2.0")
+	c.Check(info.Channel, Equals, "latest/edge")
+	c.Check(info.Publisher, Equals, snap.StoreAccount{
+		ID:          "dev1-id",
+		Username:    "developer1",
+		DisplayName: "Developer1",
+		Validation:  "verified",
+	})
+	c.Check(info.DownloadURL, Equals, path)
+	c.Check(info.Size, Equals, int64(len("hello snap revision 2")))
+	c.Check(info.Sha3_384, HasLen, 96)
+}
+
+func (s *localStoreSuite) TestInstallFollowsChannels(c *C) {
+	s.addSnap(c, 1, "0")
+	s.addSnap(c, 2, "0")
+	s.addSnap(c, 3, "0")
+	s.writeChannels(c, `
+hello:
+  stable: 1
+  latest/beta: 2
+  2.0/edge: 3
+`)
+
+	for _, tc := range []struct {
+		channel string
+		rev     snap.Revision
+		err     string
+	}{
+		{"", snap.R(1), ""},
+		{"stable", snap.R(1), ""},
+		{"candidate", snap.R(1), ""},
+		{"beta", snap.R(2), ""},
+		{"edge", snap.R(2), ""},
+		{"latest/beta/hotfix", snap.R(2), ""},
+		{"2.0/edge", snap.R(3), ""},
+		{"2.0/stable", snap.Revision{}, `cannot install snap "hello": no snap revision available as specified`},
+		{"3.0", snap.Revision{}, `cannot install snap "hello": no snap revision available as specified`},
+	} {
+		sars, err := s.install(c, tc.channel, snap.R(0))
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err, Commentf("%q", tc.channel))
+			continue
+		}
+		c.Assert(err, IsNil, Commentf("%q", tc.channel))
+		c.Check(sars[0].Info.Revision, Equals, tc.rev, Commentf("%q", tc.channel))
+	}
+}
+
+func (s *localStoreSuite) TestInstallRevision(c *C) {
+	s.addSnap(c, 1, "0")
+	s.addSnap(c, 2, "0")
+	s.writeChannels(c, "hello:\n  stable: 2\n")
+
+	sars, err := s.install(c, "", snap.R(1))
+	c.Assert(err, IsNil)
+	c.Check(sars[0].Info.Revision, Equals, snap.R(1))
+
+	_, err = s.install(c, "", snap.R(3))
+	c.Check(err, ErrorMatches, `cannot install snap "hello": no snap revision available as specified`)
+}
+
+func (s *localStoreSuite) TestInstallNotFound(c *C) {
+	_, err := s.install(c, "", snap.R(0))
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Install["hello"], Equals, store.ErrSnapNotFound)
+	c.Check(err.(*store.SnapActionError).NoResults, Equals, true)
+}
+
+func (s *localStoreSuite) TestSnapWithoutAssertionsIgnored(c *C) {
+	path := filepath.Join(s.dir, "hello_9.snap")
+	c.Assert(os.WriteFile(path, []byte("unasserted"), 0644), IsNil)
+	s.addSnap(c, 1, "0")
+
+	sars, err := s.install(c, "", snap.R(0))
+	c.Assert(err, IsNil)
+	c.Check(sars[0].Info.Revision, Equals, snap.R(1))
+}
+
+func (s *localStoreSuite) TestRefresh(c *C) {
+	s.addSnap(c, 1, "0")
+	s.addSnap(c, 2, "0")
+	s.writeChannels(c, "hello:\n  stable: 1\n  edge: 2\n")
+
+	cur := &store.CurrentSnap{
+		InstanceName:    "hello",
+		SnapID:          helloSnapID,
+		Revision:        snap.R(1),
+		TrackingChannel: "latest/stable",
+		Epoch:           snap.E("0"),
+	}
+	_, err := s.refresh(c, cur)
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Refresh["hello"], Equals, store.ErrNoUpdateAvailable)
+
+	cur.TrackingChannel = "latest/edge"
+	sars, err := s.refresh(c, cur)
+	c.Assert(err, IsNil)
+	c.Check(sars[0].Info.Revision, Equals, snap.R(2))
+	c.Check(sars[0].Info.Channel, Equals, "latest/edge")
+
+	// blocked revisions are not refreshed to
+	cur.Block = []snap.Revision{snap.R(2)}
+	_, err = s.refresh(c, cur)
+	c.Check(err.(*store.SnapActionError).Refresh["hello"], Equals, store.ErrNoUpdateAvailable)
+}
+
+func (s *localStoreSuite) TestRefreshEpochMismatch(c *C) {
+	s.addSnap(c, 1, "0")
+	s.addSnap(c, 2, "2")
+
+	_, err := s.refresh(c, &store.CurrentSnap{
+		InstanceName: "hello",
+		SnapID:       helloSnapID,
+		Revision:     snap.R(1),
+		Epoch:        snap.E("0"),
+	})
+	c.Assert(err, FitsTypeOf, &store.SnapActionError{})
+	c.Check(err.(*store.SnapActionError).Refresh["hello"], Equals, store.ErrNoUpdateAvailable)
+}
+
+func (s *localStoreSuite) TestComponents(c *C) {
+	s.addSnap(c, 1, "0")
+	s.addSnap(c, 2, "0")
+	s.addComponent(c, 10, 1)
+	path := s.addComponent(c, 11, 2)
+
+	sars, err := s.install(c, "", snap.R(0))
+	c.Assert(err, IsNil)
+	c.Assert(sars[0].Resources, HasLen, 1)
+	res := sars[0].Resources[0]
+	c.Check(res.Name, Equals, "kmod")
+	c.Check(res.Type, Equals, "component/standard")
+	c.Check(res.Revision, Equals, 11)
+	c.Check(res.Version, Equals, "11.1")
+	c.Check(res.DownloadInfo.DownloadURL, Equals, path)
+
+	// a new component revision for the same snap revision is a refresh
+	s.addComponent(c, 12, 2)
+	sars, err = s.refresh(c, &store.CurrentSnap{
+		InstanceName: "hello",
+		SnapID:       helloSnapID,
+		Revision:     snap.R(2),
+		Epoch:        snap.E("0"),
+		Resources:    map[string]snap.Revision{"kmod": snap.R(11)},
+	})
+	c.Assert(err, IsNil)
+	c.Check(sars[0].Info.Revision, Equals, snap.R(2))
+	c.Assert(sars[0].Resources, HasLen, 2)
+}
+
+func (s *localStoreSuite) TestDownload(c *C) {
+	s.addSnap(c, 1, "0")
+	sars, err := s.install(c, "", snap.R(0))
+	c.Assert(err, IsNil)
+
+	target := filepath.Join(c.MkDir(), "hello_1.snap")
+	err = s.sto.Download(context.Background(), "hello", target, &sars[0].Info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, "hello snap revision 1")
+
+	r, status, err := s.sto.DownloadStream(context.Background(), "hello", &sars[0].Info.DownloadInfo, 6, nil)
+	c.Assert(err, IsNil)
+	defer r.Close()
+	c.Check(status, Equals, 206)
+	data, err := io.ReadAll(r)
+	c.Assert(err, IsNil)
+	c.Check(string(data), Equals, "snap revision 1")
+}
+
+func (s *localStoreSuite) TestDownloadDigestMismatch(c *C) {
+	path := s.addSnap(c, 1, "0")
+	sars, err := s.install(c, "", snap.R(0))
+	c.Assert(err, IsNil)
+	c.Assert(os.WriteFile(path, []byte("changed underneath"), 0644), IsNil)
+
+	target := filepath.Join(c.MkDir(), "hello_1.snap")
+	err = s.sto.Download(context.Background(), "hello", target, &sars[0].Info.DownloadInfo, nil, nil, nil)
+	c.Assert(err, ErrorMatches, `sha3-384 mismatch for "hello" from local repository: .*`)
+	c.Check(target, testutil.FileAbsent)
+	c.Check(target+".partial", testutil.FileAbsent)
+}
+
+func (s *localStoreSuite) TestDownloadOutsideRepository(c *C) {
+	outside := filepath.Join(c.MkDir(), "other.snap")
+	c.Assert(os.WriteFile(outside, nil, 0644), IsNil)
+
+	target := filepath.Join(c.MkDir(), "hello_1.snap")
+	for _, url := range []string{outside, filepath.Join(s.dir, "../other.snap"), "https://example.com/hello.snap"} {
+		err := s.sto.Download(context.Background(), "hello", target, &snap.DownloadInfo{DownloadURL: url}, nil, nil, nil)
+		c.Check(err, ErrorMatches, `cannot find ".*" in local repository`)
+	}
+}
+
+func (s *localStoreSuite) TestAssertion(c *C) {
+	a, err := s.sto.Assertion(asserts.SnapDeclarationType, []string{"16", helloSnapID}, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.SnapDeclaration).SnapName(), Equals, "hello")
+
+	_, err = s.sto.Assertion(asserts.SnapDeclarationType, []string{"16", "otherid"}, nil)
+	c.Check(err, testutil.ErrorIs, &asserts.NotFoundError{})
+}
+
+func (s *localStoreSuite) TestSeqFormingAssertion(c *C) {
+	var vsets []asserts.Assertion
+	for seq := 1; seq <= 2; seq++ {
+		vs, err := s.storeSigning.Sign(asserts.ValidationSetType, map[string]any{
+			"type":         "validation-set",
+			"authority-id": "can0nical",
+			"series":       "16",
+			"account-id":   "can0nical",
+			"name":         "base",
+			"sequence":     fmt.Sprint(seq),
+			"snaps": []any{
+				map[string]any{
+					"name":     "hello",
+					"id":       helloSnapID,
+					"presence": "optional",
+				},
+			},
+			"timestamp": time.Now().Format(time.RFC3339),
+		}, nil, "")
+		c.Assert(err, IsNil)
+		vsets = append(vsets, vs)
+	}
+	s.writeAssertions(c, "vsets.assert", vsets...)
+
+	a, err := s.sto.SeqFormingAssertion(asserts.ValidationSetType, []string{"16", "can0nical", "base"}, 0, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.ValidationSet).Sequence(), Equals, 2)
+
+	a, err = s.sto.SeqFormingAssertion(asserts.ValidationSetType, []string{"16", "can0nical", "base"}, 1, nil)
+	c.Assert(err, IsNil)
+	c.Check(a.(*asserts.ValidationSet).Sequence(), Equals, 1)
+
+	_, err = s.sto.SeqFormingAssertion(asserts.ValidationSetType, []string{"16", "can0nical", "base"}, 3, nil)
+	c.Check(err, testutil.ErrorIs, &asserts.NotFoundError{})
+}
+
+type fakeAssertionQuery struct {
+	toResolve    map[asserts.Grouping][]*asserts.AtRevision
+	toResolveSeq map[asserts.Grouping][]*asserts.AtSequence
+	errors       []error
+}
+
+func (q *fakeAssertionQuery) ToResolve() (map[asserts.Grouping][]*asserts.AtRevision, map[asserts.Grouping][]*asserts.AtSequence, error) {
+	return q.toResolve, q.toResolveSeq, nil
+}
+
+func (q *fakeAssertionQuery) AddError(e error, ref *asserts.Ref) error {
+	q.errors = append(q.errors, e)
+	return nil
+}
+
+func (q *fakeAssertionQuery) AddSequenceError(e error, atSeq *asserts.AtSequence) error {
+	q.errors = append(q.errors, e)
+	return nil
+}
+
+func (q *fakeAssertionQuery) AddGroupingError(e error, grouping asserts.Grouping) error {
+	q.errors = append(q.errors, e)
+	return nil
+}
+
+func (s *localStoreSuite) TestSnapActionAssertions(c *C) {
+	declRef := asserts.Ref{Type: asserts.SnapDeclarationType, PrimaryKey: []string{"16", helloSnapID}}
+	otherRef := asserts.Ref{Type: asserts.SnapDeclarationType, PrimaryKey: []string{"16", "otherid"}}
+	q := &fakeAssertionQuery{
+		toResolve: map[asserts.Grouping][]*asserts.AtRevision{
+			"g1": {
+				{Ref: declRef, Revision: asserts.RevisionNotKnown},
+				{Ref: otherRef, Revision: asserts.RevisionNotKnown},
+			},
+			// already up to date
+			"g2": {{Ref: declRef, Revision: 0}},
+		},
+	}
+
+	sars, ars, err := s.sto.SnapAction(context.Background(), nil, nil, q, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(sars, HasLen, 0)
+	c.Assert(ars, HasLen, 1)
+	c.Check(ars[0].Grouping, Equals, asserts.Grouping("g1"))
+	c.Assert(ars[0].StreamURLs, HasLen, 1)
+	c.Assert(q.errors, HasLen, 1)
+	c.Check(q.errors[0], testutil.ErrorIs, &asserts.NotFoundError{})
+
+	db, err := asserts.OpenDatabase(&asserts.DatabaseConfig{
+		Backstore: asserts.NewMemoryBackstore(),
+		Trusted:   s.storeSigning.Trusted,
+	})
+	c.Assert(err, IsNil)
+	c.Assert(db.Add(s.storeSigning.StoreAccountKey("")), IsNil)
+	c.Assert(db.Add(s.dev1Acct), IsNil)
+	b := asserts.NewBatch(nil)
+	c.Assert(s.sto.DownloadAssertions(ars[0].StreamURLs, b, nil), IsNil)
+	c.Assert(b.CommitTo(db, nil), IsNil)
+	_, err = db.Find(asserts.SnapDeclarationType, map[string]string{"series": "16", "snap-id": helloSnapID})
+	c.Check(err, IsNil)
+
+	err = s.sto.DownloadAssertions([]string{"https://example.com/assertion"}, b, nil)
+	c.Check(err, ErrorMatches, `cannot find assertion "https://example.com/assertion" in local repository`)
+}
+
+func (s *localStoreSuite) TestSnapInfoAndExists(c *C) {
+	s.addSnap(c, 1, "0")
+	s.addSnap(c, 3, "0")
+
+	info, err := s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "hello"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(info.Revision, Equals, snap.R(3))
+
+	ref, _, err := s.sto.SnapExists(context.Background(), store.SnapSpec{Name: "hello"}, nil)
+	c.Assert(err, IsNil)
+	c.Check(ref.ID(), Equals, helloSnapID)
+
+	_, err = s.sto.SnapInfo(context.Background(), store.SnapSpec{Name: "other"}, nil)
+	c.Check(err, Equals, store.ErrSnapNotFound)
+}
+
+func (s *localStoreSuite) TestStoreOnlyOperations(c *C) {
+	_, err := s.sto.Find(context.Background(), &store.Search{Query: "hello"}, nil)
+	c.Check(err, Equals, store.ErrStoreOffline)
+	_, err = s.sto.Sections(context.Background(), nil)
+	c.Check(err, Equals, store.ErrStoreOffline)
+	c.Check(s.sto.EnsureDeviceSession(), IsNil)
+}
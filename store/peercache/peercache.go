@@ -0,0 +1,291 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+// Package peercache shares the snapd download cache with other devices on
+// the local network and finds the devices that share theirs, using mDNS
+// service discovery.
+package peercache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/netutil/mdns"
+	"github.com/snapcore/snapd/randutil"
+)
+
+// ServiceType is the DNS-SD service type under which download caches are
+// advertised.
+const ServiceType = "_snapd-cache._tcp"
+
+var (
+	defaultBrowseTimeout = 1 * time.Second
+	defaultPeersLifetime = 1 * time.Minute
+)
+
+// Cache is the download cache to share.
+type Cache interface {
+	// PeerCacheHandler returns the handler serving the cached blobs.
+	PeerCacheHandler() http.Handler
+}
+
+// Options customizes a PeerCache.
+type Options struct {
+	// ListenAddr is the address the cache is served on, defaults to ":0",
+	// i.e. all addresses and any port.
+	ListenAddr string
+	// MDNSConn is the connection mDNS queries are answered on, defaults to
+	// a connection joined to the mDNS multicast group. It is closed by
+	// Stop.
+	MDNSConn net.PacketConn
+	// QueryAddr is where queries for peers are sent, defaults to the mDNS
+	// multicast group.
+	QueryAddr net.Addr
+	// BrowseTimeout is how long answers from peers are waited for.
+	BrowseTimeout time.Duration
+	// PeersLifetime is how long the peers found are remembered before
+	// looking for them again.
+	PeersLifetime time.Duration
+}
+
+// PeerCache serves the download cache to peers and implements
+// store.PeerFinder.
+type PeerCache struct {
+	cache    Cache
+	opts     Options
+	instance string
+
+	server   *http.Server
+	listener net.Listener
+	mdnsConn net.PacketConn
+	cancel   context.CancelFunc
+	done     chan struct{}
+
+	mu        sync.Mutex
+	peers     []string
+	peersTime time.Time
+}
+
+// New returns a PeerCache sharing the given cache once started.
+func New(cache Cache, opts *Options) *PeerCache {
+	pc := &PeerCache{
+		cache:    cache,
+		instance: "snapd-" + strings.ToLower(randutil.RandomString(12)),
+	}
+	if opts != nil {
+		pc.opts = *opts
+	}
+	if pc.opts.ListenAddr == "" {
+		pc.opts.ListenAddr = ":0"
+	}
+	if pc.opts.QueryAddr == nil {
+		pc.opts.QueryAddr = mdns.GroupAddr
+	}
+	if pc.opts.BrowseTimeout == 0 {
+		pc.opts.BrowseTimeout = defaultBrowseTimeout
+	}
+	if pc.opts.PeersLifetime == 0 {
+		pc.opts.PeersLifetime = defaultPeersLifetime
+	}
+	return pc
+}
+
+// Instance returns the name under which the cache is advertised.
+func (pc *PeerCache) Instance() string {
+	return pc.instance
+}
+
+// Addr returns the address the cache is served on, or nil if the cache is not
+// started.
+func (pc *PeerCache) Addr() net.Addr {
+	if pc.listener == nil {
+		return nil
+	}
+	return pc.listener.Addr()
+}
+
+// Start starts serving the cache and advertising it.
+func (pc *PeerCache) Start() (err error) {
+	if pc.listener != nil {
+		return errors.New("internal error: peer cache already started")
+	}
+
+	ln, err := net.Listen("tcp4", pc.opts.ListenAddr)
+	if err != nil {
+		return fmt.Errorf("cannot listen for peers: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			ln.Close()
+		}
+	}()
+
+	tcpAddr := ln.Addr().(*net.TCPAddr)
+	ips, err := advertisedIPs(tcpAddr.IP)
+	if err != nil {
+		return err
+	}
+
+	conn := pc.opts.MDNSConn
+	if conn == nil {
+		conn, err = mdns.Listen(nil)
+		if err != nil {
+			return fmt.Errorf("cannot listen for mDNS queries: %v", err)
+		}
+	}
+	defer func() {
+		if err != nil {
+			conn.Close()
+		}
+	}()
+
+	responder, err := mdns.NewResponder(conn, mdns.GroupAddr, mdns.Service{
+		Instance: pc.instance,
+		Service:  ServiceType,
+		Port:     tcpAddr.Port,
+		IPs:      ips,
+	})
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	pc.listener = ln
+	pc.mdnsConn = conn
+	pc.cancel = cancel
+	pc.done = make(chan struct{}, 2)
+	pc.server = &http.Server{
+		Handler:           pc.cache.PeerCacheHandler(),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		if err := pc.server.Serve(ln); err != nil && err != http.ErrServerClosed {
+			logger.Noticef("Cannot serve download cache to peers: %v", err)
+		}
+		pc.done <- struct{}{}
+	}()
+	go func() {
+		if err := responder.Serve(ctx); err != nil {
+			logger.Noticef("Cannot answer mDNS queries: %v", err)
+		}
+		pc.done <- struct{}{}
+	}()
+
+	logger.Noticef("Sharing download cache with peers on %s as %s.", ln.Addr(), pc.instance)
+	return nil
+}
+
+// Stop stops serving and advertising the cache.
+func (pc *PeerCache) Stop() error {
+	if pc.listener == nil {
+		return nil
+	}
+
+	pc.cancel()
+	err := pc.server.Close()
+	<-pc.done
+	<-pc.done
+	if cerr := pc.mdnsConn.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+
+	pc.listener = nil
+	pc.mdnsConn = nil
+	pc.server = nil
+	return err
+}
+
+// Peers returns the base URLs of the download caches of the other devices on
+// the local network. Peers are looked for again once the ones found are older
+// than the configured lifetime.
+func (pc *PeerCache) Peers(ctx context.Context) []string {
+	pc.mu.Lock()
+	defer pc.mu.Unlock()
+
+	if !pc.peersTime.IsZero() && time.Since(pc.peersTime) < pc.opts.PeersLifetime {
+		return pc.peers
+	}
+
+	peers, err := pc.browse(ctx)
+	if err != nil {
+		logger.Noticef("Cannot look for download cache peers: %v", err)
+	}
+	pc.peers = peers
+	pc.peersTime = time.Now()
+	return peers
+}
+
+func (pc *PeerCache) browse(ctx context.Context) ([]string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(ctx, pc.opts.BrowseTimeout)
+	defer cancel()
+	entries, err := mdns.Browse(ctx, conn, pc.opts.QueryAddr, ServiceType)
+	if err != nil {
+		return nil, err
+	}
+
+	var peers []string
+	for _, e := range entries {
+		if strings.EqualFold(e.Instance, pc.instance) {
+			continue
+		}
+		for _, addr := range e.Addresses() {
+			peers = append(peers, "http://"+addr)
+		}
+	}
+	return peers, nil
+}
+
+// advertisedIPs returns the addresses to advertise for a server listening on
+// ip, which are all the non-loopback IPv4 addresses of the host if ip is
+// unspecified.
+func advertisedIPs(ip net.IP) ([]net.IP, error) {
+	if !ip.IsUnspecified() {
+		return []net.IP{ip}, nil
+	}
+
+	addrs, err := netInterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list network addresses: %v", err)
+	}
+	var ips []net.IP
+	for _, addr := range addrs {
+		ipNet, ok := addr.(*net.IPNet)
+		if !ok || ipNet.IP.IsLoopback() || ipNet.IP.To4() == nil {
+			continue
+		}
+		ips = append(ips, ipNet.IP.To4())
+	}
+	return ips, nil
+}
+
+var netInterfaceAddrs = net.InterfaceAddrs
@@ -0,0 +1,126 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package peercache_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/store/peercache"
+	"github.com/snapcore/snapd/testutil"
+)
+
+func Test(t *testing.T) { TestingT(t) }
+
+type peerCacheSuite struct {
+	testutil.BaseTest
+}
+
+var _ = Suite(&peerCacheSuite{})
+
+type fakeCache string
+
+func (f fakeCache) PeerCacheHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s:%s", f, r.URL.Path)
+	})
+}
+
+// startPeer starts a peer cache on loopback which sends its queries for
+// peers to queryAddr, and returns it with the address it answers mDNS queries
+// on.
+func (s *peerCacheSuite) startPeer(c *C, name string, queryAddr net.Addr) (*peercache.PeerCache, net.Addr) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+
+	pc := peercache.New(fakeCache(name), &peercache.Options{
+		ListenAddr:    "127.0.0.1:0",
+		MDNSConn:      conn,
+		QueryAddr:     queryAddr,
+		BrowseTimeout: 200 * time.Millisecond,
+		PeersLifetime: time.Hour,
+	})
+	c.Assert(pc.Start(), IsNil)
+	s.AddCleanup(func() { c.Check(pc.Stop(), IsNil) })
+	return pc, conn.LocalAddr()
+}
+
+func (s *peerCacheSuite) TestPeersOnLoopback(c *C) {
+	// the "multicast group" of the first peer is the second one
+	other, otherMDNS := s.startPeer(c, "other", nil)
+	pc, _ := s.startPeer(c, "self", otherMDNS)
+
+	peers := pc.Peers(context.Background())
+	c.Assert(peers, DeepEquals, []string{"http://" + other.Addr().String()})
+
+	resp, err := http.Get(peers[0] + "/v2/blobs/abc")
+	c.Assert(err, IsNil)
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	c.Assert(err, IsNil)
+	c.Check(string(body), Equals, "other:/v2/blobs/abc")
+}
+
+func (s *peerCacheSuite) TestPeersExcludesSelf(c *C) {
+	conn, err := net.ListenPacket("udp4", "127.0.0.1:0")
+	c.Assert(err, IsNil)
+
+	// queries are answered by the cache itself
+	pc := peercache.New(fakeCache("self"), &peercache.Options{
+		ListenAddr:    "127.0.0.1:0",
+		MDNSConn:      conn,
+		QueryAddr:     conn.LocalAddr(),
+		BrowseTimeout: 200 * time.Millisecond,
+	})
+	c.Assert(pc.Start(), IsNil)
+	defer pc.Stop()
+
+	c.Check(pc.Peers(context.Background()), HasLen, 0)
+}
+
+func (s *peerCacheSuite) TestPeersAreRemembered(c *C) {
+	other, otherMDNS := s.startPeer(c, "other", nil)
+	pc, _ := s.startPeer(c, "self", otherMDNS)
+
+	expected := []string{"http://" + other.Addr().String()}
+	c.Assert(pc.Peers(context.Background()), DeepEquals, expected)
+
+	// the other peer going away is only noticed once the peers expire
+	c.Assert(other.Stop(), IsNil)
+	c.Check(pc.Peers(context.Background()), DeepEquals, expected)
+}
+
+func (s *peerCacheSuite) TestStartTwice(c *C) {
+	pc, _ := s.startPeer(c, "self", nil)
+	c.Check(pc.Start(), ErrorMatches, "internal error: peer cache already started")
+}
+
+func (s *peerCacheSuite) TestStopNotStarted(c *C) {
+	pc := peercache.New(fakeCache("self"), nil)
+	c.Check(pc.Stop(), IsNil)
+	c.Check(pc.Addr(), IsNil)
+}
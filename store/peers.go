@@ -0,0 +1,226 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store
+
+import (
+	"context"
+	"crypto"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/snap"
+)
+
+// PeerBlobsPath is the path under which peers serve the blobs of their
+// download cache, by sha3-384 digest.
+const PeerBlobsPath = "/v2/blobs/"
+
+// PeerFinder finds other devices on the local network that share their
+// download cache.
+type PeerFinder interface {
+	// Peers returns the base URLs of the peers.
+	Peers(ctx context.Context) []string
+}
+
+// SetPeerFinder makes the store try to fetch blobs from the peers found by
+// the given finder before downloading them from the store. A nil finder
+// disables fetching from peers.
+func (s *Store) SetPeerFinder(f PeerFinder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.peers = f
+}
+
+func (s *Store) peerFinder() PeerFinder {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.peers
+}
+
+var (
+	// a peer must start sending within this time
+	peerResponseTimeout = 5 * time.Second
+	// and keep sending at least at downloadSpeedMin, measured over
+	// windows of this duration, as fetching from a peer is given up
+	// sooner than downloading from the store
+	peerSpeedMeasureWindow = 30 * time.Second
+
+	peerClient = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil,
+			ResponseHeaderTimeout: peerResponseTimeout,
+		},
+	}
+)
+
+// downloadFromPeers tries to fetch the blob described by downloadInfo from
+// the peers into targetPath. The blob is only used if it matches the
+// sha3-384 digest, which is the one the snap-revision or
+// snap-resource-revision assertion of the blob carries, and which is checked
+// again against the assertion before the blob is installed.
+func (s *Store) downloadFromPeers(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter) bool {
+	finder := s.peerFinder()
+	if finder == nil || downloadInfo.Sha3_384 == "" {
+		return false
+	}
+	if pbar == nil {
+		pbar = progress.Null
+	}
+
+	for _, peer := range finder.Peers(ctx) {
+		err := downloadFromPeer(ctx, name, peer, targetPath, downloadInfo, pbar)
+		if err == nil {
+			logger.Noticef("Fetched %s from peer %s.", name, peer)
+			return true
+		}
+		logger.Debugf("Cannot fetch %s from peer %s: %v", name, peer, err)
+	}
+	return false
+}
+
+func downloadFromPeer(ctx context.Context, name, peer, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter) (err error) {
+	// give up on peers which stall or are too slow
+	tc, downloadCtx := NewTransferSpeedMonitoringWriterAndContext(ctx, peerSpeedMeasureWindow, downloadSpeedMin)
+
+	url := strings.TrimSuffix(peer, "/") + PeerBlobsPath + downloadInfo.Sha3_384
+	req, err := http.NewRequestWithContext(downloadCtx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := peerClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	if downloadInfo.Size > 0 && resp.ContentLength >= 0 && resp.ContentLength != downloadInfo.Size {
+		return fmt.Errorf("unexpected size %d, expected %d", resp.ContentLength, downloadInfo.Size)
+	}
+
+	partialPath := targetPath + ".peer"
+	w, err := os.OpenFile(partialPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := w.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+		if err != nil {
+			os.Remove(partialPath)
+		}
+	}()
+
+	body := io.Reader(resp.Body)
+	if downloadInfo.Size > 0 {
+		// never read more than what we expect
+		body = io.LimitReader(resp.Body, downloadInfo.Size+1)
+	}
+	h := crypto.SHA3_384.New()
+	pbar.Start(name, float64(resp.ContentLength))
+	stopMonitorCh := tc.Monitor()
+	n, err := io.Copy(io.MultiWriter(w, h, pbar, tc), body)
+	close(stopMonitorCh)
+	pbar.Finished()
+	if err := tc.Err(); err != nil {
+		return err
+	}
+	if err != nil {
+		return err
+	}
+	if downloadInfo.Size > 0 && n != downloadInfo.Size {
+		return fmt.Errorf("unexpected size %d, expected %d", n, downloadInfo.Size)
+	}
+	if actualSha3 := fmt.Sprintf("%x", h.Sum(nil)); actualSha3 != downloadInfo.Sha3_384 {
+		return HashError{filepath.Base(targetPath), actualSha3, downloadInfo.Sha3_384}
+	}
+	if err := w.Sync(); err != nil {
+		return err
+	}
+	return os.Rename(partialPath, targetPath)
+}
+
+var validBlobKey = regexp.MustCompile("^[0-9a-f]{96}$")
+
+// PeerCacheHandler returns a handler serving the blobs of the download cache
+// to peers under PeerBlobsPath.
+func (s *Store) PeerCacheHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(PeerBlobsPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" && r.Method != "HEAD" {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		key := strings.TrimPrefix(r.URL.Path, PeerBlobsPath)
+		if !validBlobKey.MatchString(key) {
+			http.Error(w, "invalid blob digest", http.StatusBadRequest)
+			return
+		}
+		path := s.cacher.GetPath(key)
+		if path == "" {
+			http.NotFound(w, r)
+			return
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			// the entry may have been removed from the cache
+			// meanwhile
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		// only serve the blob named by the digest, whatever was
+		// stored under it
+		h := crypto.SHA3_384.New()
+		if _, err := io.Copy(h, f); err != nil {
+			http.Error(w, "cannot read blob", http.StatusInternalServerError)
+			return
+		}
+		if digest := fmt.Sprintf("%x", h.Sum(nil)); digest != key {
+			logger.Noticef("Not serving cached blob %s to peer: its sha3-384 digest is %s.", key, digest)
+			http.NotFound(w, r)
+			return
+		}
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			http.Error(w, "cannot read blob", http.StatusInternalServerError)
+			return
+		}
+		fi, err := f.Stat()
+		if err != nil {
+			http.Error(w, "cannot read blob", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		http.ServeContent(w, r, "", fi.ModTime(), f)
+	})
+	return mux
+}
@@ -0,0 +1,292 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package store_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/sha3"
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/auth"
+	"github.com/snapcore/snapd/progress"
+	"github.com/snapcore/snapd/progress/progresstest"
+	"github.com/snapcore/snapd/snap"
+	"github.com/snapcore/snapd/store"
+	"github.com/snapcore/snapd/testutil"
+)
+
+type peersSuite struct {
+	baseStoreSuite
+
+	// the device downloading and the peer sharing its cache
+	store, peer         *store.Store
+	storeDir, peerDir   string
+	peerServer          *httptest.Server
+	storeDownloadCalled int
+}
+
+var _ = Suite(&peersSuite{})
+
+type staticPeers []string
+
+func (p staticPeers) Peers(ctx context.Context) []string {
+	return p
+}
+
+func (s *peersSuite) SetUpTest(c *C) {
+	s.baseStoreSuite.SetUpTest(c)
+
+	policy := store.CachePolicy{MaxItems: 10}
+	s.storeDir = c.MkDir()
+	s.store = store.New(nil, nil)
+	s.AddCleanup(s.store.MockCacher(store.NewCacheManager(s.storeDir, policy)))
+
+	s.peerDir = c.MkDir()
+	s.peer = store.New(nil, nil)
+	s.AddCleanup(s.peer.MockCacher(store.NewCacheManager(s.peerDir, policy)))
+
+	s.peerServer = httptest.NewServer(s.peer.PeerCacheHandler())
+	s.AddCleanup(s.peerServer.Close)
+	s.store.SetPeerFinder(staticPeers{s.peerServer.URL})
+
+	s.storeDownloadCalled = 0
+	s.AddCleanup(store.MockDownload(func(ctx context.Context, name, sha3, url string, user *auth.UserState, _ *store.Store, w io.ReadWriteSeeker, resume int64, pbar progress.Meter, dlOpts *store.DownloadOptions) error {
+		s.storeDownloadCalled++
+		_, err := w.Write([]byte("from the store"))
+		return err
+	}))
+}
+
+func digest(content string) string {
+	h := sha3.New384()
+	h.Write([]byte(content))
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+func (s *peersSuite) putInPeerCache(c *C, key, content string) {
+	c.Assert(os.WriteFile(filepath.Join(s.peerDir, key), []byte(content), 0644), IsNil)
+}
+
+func (s *peersSuite) TestDownloadFromPeer(c *C) {
+	content := "from a peer"
+	key := digest(content)
+	s.putInPeerCache(c, key, content)
+
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    key,
+	}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, content)
+	c.Check(s.storeDownloadCalled, Equals, 0)
+
+	// and it is now in the local cache as well
+	c.Check(filepath.Join(s.storeDir, key), testutil.FileEquals, content)
+	c.Check(target+".peer", testutil.FileAbsent)
+}
+
+func (s *peersSuite) TestDownloadFromPeerProgress(c *C) {
+	content := "from a peer"
+	key := digest(content)
+	s.putInPeerCache(c, key, content)
+
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    key,
+	}
+	pbar := &progresstest.Meter{}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, pbar, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(s.storeDownloadCalled, Equals, 0)
+
+	c.Check(pbar.Labels, DeepEquals, []string{"foo"})
+	c.Check(pbar.Totals, DeepEquals, []float64{float64(len(content))})
+	c.Check(pbar.Finishes, Equals, 1)
+	var written int
+	for _, b := range pbar.Written {
+		written += len(b)
+	}
+	c.Check(written, Equals, len(content))
+}
+
+func (s *peersSuite) TestDownloadPeerStallsFallsBackToStore(c *C) {
+	s.AddCleanup(store.MockPeerSpeedMeasureWindow(50 * time.Millisecond))
+
+	content := "from the store"
+	key := digest(content)
+	stalling := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(content[:4]))
+		w.(http.Flusher).Flush()
+		// never send the rest
+		select {
+		case <-r.Context().Done():
+		case <-time.After(10 * time.Second):
+			c.Error("fetching from a stalling peer was not given up")
+		}
+	}))
+	defer stalling.Close()
+	s.store.SetPeerFinder(staticPeers{stalling.URL})
+
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    key,
+	}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, content)
+	c.Check(s.storeDownloadCalled, Equals, 1)
+	c.Check(target+".peer", testutil.FileAbsent)
+}
+
+func (s *peersSuite) TestDownloadFromPeerHashMismatchFallsBackToStore(c *C) {
+	content := "from the store"
+	key := digest(content)
+	// the peer has something else under the digest
+	s.putInPeerCache(c, key, "tampered with!")
+
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    key,
+	}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, content)
+	c.Check(s.storeDownloadCalled, Equals, 1)
+	c.Check(target+".peer", testutil.FileAbsent)
+}
+
+func (s *peersSuite) TestDownloadFromPeerSizeMismatchFallsBackToStore(c *C) {
+	content := "from the store"
+	key := digest(content)
+	s.putInPeerCache(c, key, content+" and more")
+
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    key,
+	}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, content)
+	c.Check(s.storeDownloadCalled, Equals, 1)
+}
+
+func (s *peersSuite) TestDownloadNotOnPeerFallsBackToStore(c *C) {
+	content := "from the store"
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    digest(content),
+	}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, content)
+	c.Check(s.storeDownloadCalled, Equals, 1)
+}
+
+func (s *peersSuite) TestDownloadPeerUnreachableFallsBackToStore(c *C) {
+	s.peerServer.Close()
+
+	content := "from the store"
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    digest(content),
+	}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, content)
+	c.Check(s.storeDownloadCalled, Equals, 1)
+}
+
+func (s *peersSuite) TestDownloadNoPeerFinder(c *C) {
+	content := "from a peer"
+	key := digest(content)
+	s.putInPeerCache(c, key, content)
+	s.store.SetPeerFinder(nil)
+
+	target := filepath.Join(c.MkDir(), "foo_1.snap")
+	dlInfo := &snap.DownloadInfo{
+		DownloadURL: "URL",
+		Size:        int64(len(content)),
+		Sha3_384:    key,
+	}
+	err := s.store.Download(s.ctx, "foo", target, dlInfo, nil, nil, nil)
+	c.Assert(err, IsNil)
+	c.Check(target, testutil.FileEquals, "from the store")
+	c.Check(s.storeDownloadCalled, Equals, 1)
+}
+
+func (s *peersSuite) TestPeerCacheHandler(c *C) {
+	content := "cached"
+	key := digest(content)
+	s.putInPeerCache(c, key, content)
+	// something else stored under a digest is not served
+	tampered := digest("expected")
+	s.putInPeerCache(c, tampered, "tampered with!")
+
+	for _, tc := range []struct {
+		method, path string
+		status       int
+		body         string
+	}{
+		{"GET", "/v2/blobs/" + key, 200, content},
+		{"HEAD", "/v2/blobs/" + key, 200, ""},
+		{"GET", "/v2/blobs/" + digest("other"), 404, "404 page not found\n"},
+		{"GET", "/v2/blobs/" + tampered, 404, "404 page not found\n"},
+		{"GET", "/v2/blobs/" + strings.Repeat("z", 96), 400, "invalid blob digest\n"},
+		{"GET", "/v2/blobs/" + key[:10], 400, "invalid blob digest\n"},
+		{"POST", "/v2/blobs/" + key, 405, "method not allowed\n"},
+		{"GET", "/v2/other", 404, "404 page not found\n"},
+	} {
+		req, err := http.NewRequest(tc.method, s.peerServer.URL+tc.path, nil)
+		c.Assert(err, IsNil)
+		resp, err := http.DefaultClient.Do(req)
+		c.Assert(err, IsNil)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		c.Assert(err, IsNil)
+		c.Check(resp.StatusCode, Equals, tc.status, Commentf("%s %s", tc.method, tc.path))
+		c.Check(string(body), Equals, tc.body, Commentf("%s %s", tc.method, tc.path))
+	}
+}
@@ -166,6 +166,8 @@ type Store struct {
 	suggestedCurrency string
 
 	cacher downloadCache
+	// peers is protected by mu
+	peers PeerFinder
 
 	proxy              func(*http.Request) (*url.URL, error)
 	proxyConnectHeader http.Header
@@ -26,6 +26,7 @@ import (
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -97,6 +98,14 @@ type currentSnapV2JSON struct {
 	// Held is an optional map that can contain a "by" key mapping to a list of
 	// snaps with holds on the current snap (see CurrentSnap#Held).
 	Held map[string][]string `json:"held,omitempty"`
+	// Resources is an optional list of the store resources installed for
+	// the snap, so that the store can offer deltas for them.
+	Resources []currentResourceV2JSON `json:"resources,omitempty"`
+}
+
+type currentResourceV2JSON struct {
+	Name     string `json:"name"`
+	Revision int    `json:"revision"`
 }
 
 type SnapActionFlags int
@@ -311,6 +320,26 @@ func genInstanceKey(curSnap *CurrentSnap, salt string) (string, error) {
 	return fmt.Sprintf("%s:%s", curSnap.SnapID, enc), nil
 }
 
+// currentResourcesJSON returns the context information for the installed
+// resources that came from the store, sorted by name. Only the store can offer
+// deltas for those.
+func currentResourcesJSON(resources map[string]snap.Revision) []currentResourceV2JSON {
+	var resJSONs []currentResourceV2JSON
+	for name, rev := range resources {
+		if !rev.Store() {
+			continue
+		}
+		resJSONs = append(resJSONs, currentResourceV2JSON{
+			Name:     name,
+			Revision: rev.N,
+		})
+	}
+	sort.Slice(resJSONs, func(i, j int) bool {
+		return resJSONs[i].Name < resJSONs[j].Name
+	})
+	return resJSONs
+}
+
 // SnapActionResult encapsulates the non-error result of a single
 // action of the SnapAction call.
 type SnapActionResult struct {
@@ -361,6 +390,7 @@ func (s *Store) snapAction(ctx context.Context, currentSnaps []*CurrentSnap, act
 	curSnaps := make(map[string]*CurrentSnap, len(currentSnaps))
 	curSnapJSONs := make([]*currentSnapV2JSON, len(currentSnaps))
 	instanceNameToKey := make(map[string]string, len(currentSnaps))
+	withDeltas := len(s.supportedDeltaFormats()) > 0
 	for i, curSnap := range currentSnaps {
 		if curSnap.SnapID == "" || curSnap.InstanceName == "" || curSnap.Revision.Unset() {
 			return nil, nil, fmt.Errorf("internal error: invalid current snap information")
@@ -401,6 +431,9 @@ func (s *Store) snapAction(ctx context.Context, currentSnaps []*CurrentSnap, act
 		if len(curSnap.HeldBy) > 0 && (storeVer <= 0 || storeVer >= 55) {
 			curSnapJSONs[i].Held = map[string][]string{"by": curSnap.HeldBy}
 		}
+		if withDeltas {
+			curSnapJSONs[i].Resources = currentResourcesJSON(curSnap.Resources)
+		}
 	}
 
 	// do not include toResolveSeq len in the initial size since it may have
@@ -572,6 +572,9 @@ func (s *storeActionSuite) TestSnapActionNoSkipIfResourceChange(c *C) {
 			"tracking-channel": "stable",
 			"refreshed-date":   helloRefreshedDateStr,
 			"epoch":            anyZeroEpoch,
+			"resources": []any{
+				map[string]any{"name": "comp", "revision": float64(2)},
+			},
 		})
 		c.Assert(req.Actions, HasLen, 1)
 		c.Assert(req.Actions[0], DeepEquals, map[string]any{
@@ -673,6 +676,9 @@ func (s *storeActionSuite) TestSnapActionSkipIfNoResourceChange(c *C) {
 			"tracking-channel": "stable",
 			"refreshed-date":   helloRefreshedDateStr,
 			"epoch":            anyZeroEpoch,
+			"resources": []any{
+				map[string]any{"name": "comp", "revision": float64(2)},
+			},
 		})
 		c.Assert(req.Actions, HasLen, 1)
 		c.Assert(req.Actions[0], DeepEquals, map[string]any{
@@ -1344,6 +1350,129 @@ func (s *storeActionSuite) TestSnapActionWithDeltas(c *C) {
 	c.Assert(results[0].Revision, Equals, snap.R(26))
 }
 
+func (s *storeActionSuite) TestSnapActionWithResourceDeltas(c *C) {
+	origUseDeltas := os.Getenv("SNAPD_USE_DELTAS_EXPERIMENTAL")
+	defer os.Setenv("SNAPD_USE_DELTAS_EXPERIMENTAL", origUseDeltas)
+	c.Assert(os.Setenv("SNAPD_USE_DELTAS_EXPERIMENTAL", "1"), IsNil)
+
+	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assertRequest(c, r, "POST", snapActionPath)
+		c.Check(r.Header.Get("Snap-Accept-Delta-Format"), Equals, "snap-1-1-xdelta3,xdelta3")
+		jsonReq, err := io.ReadAll(r.Body)
+		c.Assert(err, IsNil)
+		var req struct {
+			Context []map[string]any `json:"context"`
+			Actions []map[string]any `json:"actions"`
+		}
+
+		err = json.Unmarshal(jsonReq, &req)
+		c.Assert(err, IsNil)
+
+		// only resources with store revisions are reported, so
+		// that the store can offer deltas against them
+		c.Assert(req.Context, HasLen, 1)
+		c.Assert(req.Context[0], DeepEquals, map[string]any{
+			"snap-id":          helloWorldSnapID,
+			"instance-key":     helloWorldSnapID,
+			"revision":         float64(1),
+			"tracking-channel": "stable",
+			"refreshed-date":   helloRefreshedDateStr,
+			"epoch":            anyZeroEpoch,
+			"resources": []any{
+				map[string]any{"name": "comp", "revision": float64(2)},
+				map[string]any{"name": "kmod", "revision": float64(7)},
+			},
+		})
+
+		io.WriteString(w, `{
+  "results": [{
+     "result": "refresh",
+     "instance-key": "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+     "snap-id": "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+     "name": "hello-world",
+     "snap": {
+       "snap-id": "buPKUD3TKqCOgLEjjHx5kSiCpIs5cMuQ",
+       "name": "hello-world",
+       "revision": 2,
+       "version": "6.2",
+       "publisher": {
+          "id": "canonical",
+          "username": "canonical",
+          "display-name": "Canonical"
+       },
+       "resources": [
+         {
+           "name": "kmod",
+           "type": "component/kernel-modules",
+           "revision": 8,
+           "version": "1",
+           "download": {
+             "sha3-384": "38b060a751ac96384cd9327eb1b1e36a21fdb71114be07434c0cc7bf63f6e1da274edebfe76f65fbd51ad2f14898b95b",
+             "size": 1048576,
+             "url": "https://example.com/kmod.comp",
+             "deltas": [{
+               "format": "xdelta3",
+               "source": 7,
+               "target": 8,
+               "url": "https://example.com/kmod-7-8.delta",
+               "size": 1024,
+               "sha3-384": "1d2c5d5ef9ab75dc35a49d65eba2dabcb9fc49c08d91d36d0a4bb39c8ae6e1e9bc7a5e3d1dc4c1a2c9c8d7d8bce8ba35"
+             }]
+           }
+         }
+       ]
+     }
+  }]
+}`)
+	}))
+
+	c.Assert(mockServer, NotNil)
+	defer mockServer.Close()
+
+	mockServerURL, _ := url.Parse(mockServer.URL)
+	cfg := store.Config{
+		StoreBaseURL: mockServerURL,
+	}
+	dauthCtx := &testDauthContext{c: c, device: s.device}
+	sto := store.New(&cfg, dauthCtx)
+
+	results, _, err := sto.SnapAction(s.ctx, []*store.CurrentSnap{
+		{
+			InstanceName:    "hello-world",
+			SnapID:          helloWorldSnapID,
+			TrackingChannel: "stable",
+			Revision:        snap.R(1),
+			RefreshedDate:   helloRefreshedDate,
+			Resources: map[string]snap.Revision{
+				"kmod":  snap.R(7),
+				"comp":  snap.R(2),
+				"local": snap.R(-1),
+			},
+		},
+	}, []*store.SnapAction{
+		{
+			Action:       "refresh",
+			SnapID:       helloWorldSnapID,
+			InstanceName: "hello-world",
+			Channel:      "stable",
+		},
+	}, nil, nil, &store.RefreshOptions{IncludeResources: true})
+	c.Assert(err, IsNil)
+	c.Assert(results, HasLen, 1)
+
+	res := results[0].ResourceResult("kmod")
+	c.Assert(res, NotNil)
+	c.Check(res.Revision, Equals, 8)
+	c.Check(res.DownloadInfo.Deltas, DeepEquals, []snap.DeltaInfo{{
+		FromRevision: 7,
+		ToRevision:   8,
+		Format:       "xdelta3",
+		DownloadURL:  "https://example.com/kmod-7-8.delta",
+		Size:         1024,
+		Sha3_384:     "1d2c5d5ef9ab75dc35a49d65eba2dabcb9fc49c08d91d36d0a4bb39c8ae6e1e9bc7a5e3d1dc4c1a2c9c8d7d8bce8ba35",
+	}})
+}
+
 func (s *storeActionSuite) TestSnapActionOptions(c *C) {
 	mockServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assertRequest(c, r, "POST", snapActionPath)
@@ -136,6 +136,10 @@ func (s *Store) Download(ctx context.Context, name string, targetPath string, do
 		return nil
 	}
 
+	if s.downloadFromPeers(ctx, name, targetPath, downloadInfo, pbar) {
+		return s.cacher.Put(downloadInfo.Sha3_384, targetPath)
+	}
+
 	if len(s.supportedDeltaFormats()) > 0 {
 		logger.Debugf("Available deltas returned by store: %v", downloadInfo.Deltas)
 		if len(downloadInfo.Deltas) > 0 {
@@ -822,19 +826,35 @@ func (s *Store) selectDelta(downloadInfo *snap.DownloadInfo) (*snap.DeltaInfo, e
 	return &deltaInfo, nil
 }
 
-// applyDelta generates a target snap from a previously downloaded snap and a downloaded delta.
+// applyDelta generates a target snap or component from a previously
+// downloaded one and a downloaded delta.
 var applyDelta = func(ctx context.Context, s *Store, name string, deltaPath string, deltaInfo *snap.DeltaInfo, targetPath string, targetSha3_384 string) error {
 	return s.applyDeltaImpl(ctx, name, deltaPath, deltaInfo, targetPath, targetSha3_384)
 }
 
 var squashfsApplyDelta = squashfs.ApplyDelta
 
+// deltaSourcePath returns the path of the blob that a delta for the snap or
+// component with the given name applies to. Component blobs are named after
+// the snap instance and the component, like the target blob of the download,
+// so the name of the source component blob is derived from the target.
+func deltaSourcePath(name, targetPath string, fromRevision int) (kind, path string) {
+	base := filepath.Base(targetPath)
+	if !strings.HasSuffix(base, ".comp") {
+		return "snap", filepath.Join(dirs.SnapBlobDir, fmt.Sprintf("%s_%d.snap", name, fromRevision))
+	}
+	containerName := strings.TrimSuffix(base, ".comp")
+	if idx := strings.LastIndex(containerName, "_"); idx > 0 {
+		containerName = containerName[:idx]
+	}
+	return "component", filepath.Join(dirs.SnapBlobDir, fmt.Sprintf("%s_%d.comp", containerName, fromRevision))
+}
+
 func (s *Store) applyDeltaImpl(ctx context.Context, name string, deltaPath string, deltaInfo *snap.DeltaInfo, targetPath string, targetSha3_384 string) error {
-	snapBase := fmt.Sprintf("%s_%d.snap", name, deltaInfo.FromRevision)
-	snapPath := filepath.Join(dirs.SnapBlobDir, snapBase)
+	kind, snapPath := deltaSourcePath(name, targetPath, deltaInfo.FromRevision)
 
 	if !osutil.FileExists(snapPath) {
-		return fmt.Errorf("snap %q revision %d not found at %s", name, deltaInfo.FromRevision, snapPath)
+		return fmt.Errorf("%s %q revision %d not found at %s", kind, name, deltaInfo.FromRevision, snapPath)
 	}
 
 	partialTargetPath := targetPath + ".partial"
@@ -876,7 +896,8 @@ func (s *Store) applyDeltaImpl(ctx context.Context, name string, deltaPath strin
 	return nil
 }
 
-// downloadAndApplyDelta downloads and then applies the delta to the current snap.
+// downloadAndApplyDelta downloads and then applies the delta to the current
+// snap or component.
 func (s *Store) downloadAndApplyDelta(ctx context.Context, name, targetPath string, downloadInfo *snap.DownloadInfo, pbar progress.Meter, user *auth.UserState, dlOpts *DownloadOptions) error {
 	deltaInfo, err := s.selectDelta(downloadInfo)
 	if err != nil {
@@ -697,6 +697,8 @@ func (s *storeDownloadSuite) TestDownloadDelta(c *C) {
 }
 
 var applyDeltaTests = []struct {
+	name            string
+	containerName   string
 	deltaInfo       snap.DeltaInfo
 	currentRevision uint
 	error           string
@@ -715,14 +717,41 @@ var applyDeltaTests = []struct {
 	deltaInfo:       snap.DeltaInfo{Format: "xdelta3", FromRevision: 24, ToRevision: 26},
 	currentRevision: 23,
 	error:           "snap \"foo\" revision 24 not found",
+}, {
+	// A delta for a component is applied to the component blob.
+	name:            "foo+comp",
+	deltaInfo:       snap.DeltaInfo{Format: "xdelta3", FromRevision: 3, ToRevision: 5},
+	currentRevision: 3,
+	error:           "",
+}, {
+	// An error is returned if the expected current component does not exist on disk.
+	name:            "foo+comp",
+	deltaInfo:       snap.DeltaInfo{Format: "xdelta3", FromRevision: 3, ToRevision: 5},
+	currentRevision: 2,
+	error:           "component \"foo+comp\" revision 3 not found",
+}, {
+	// A delta for a component of a snap instance is applied to the
+	// component blob of the instance.
+	name:            "foo+comp",
+	containerName:   "foo_bar+comp",
+	deltaInfo:       snap.DeltaInfo{Format: "xdelta3", FromRevision: 3, ToRevision: 5},
+	currentRevision: 3,
+	error:           "",
 }}
 
 func (s *storeDownloadSuite) TestApplyDelta(c *C) {
 	for _, testCase := range applyDeltaTests {
-		name := "foo"
-		currentSnapName := fmt.Sprintf("%s_%d.snap", name, testCase.currentRevision)
+		name, ext := "foo", "snap"
+		if testCase.name != "" {
+			name, ext = testCase.name, "comp"
+		}
+		containerName := name
+		if testCase.containerName != "" {
+			containerName = testCase.containerName
+		}
+		currentSnapName := fmt.Sprintf("%s_%d.%s", containerName, testCase.currentRevision, ext)
 		currentSnapPath := filepath.Join(dirs.SnapBlobDir, currentSnapName)
-		targetSnapName := fmt.Sprintf("%s_%d.snap", name, testCase.deltaInfo.ToRevision)
+		targetSnapName := fmt.Sprintf("%s_%d.%s", containerName, testCase.deltaInfo.ToRevision, ext)
 		targetSnapPath := filepath.Join(dirs.SnapBlobDir, targetSnapName)
 		err := os.MkdirAll(filepath.Dir(currentSnapPath), 0755)
 		c.Assert(err, IsNil)
@@ -26,6 +26,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -449,6 +450,9 @@ type Systemd interface {
 	// threads if enabled, etc) part of the unit, which can be a service or a
 	// slice.
 	CurrentTasksCount(unit string) (uint64, error)
+	// CurrentIOUsage returns the number of bytes read from and written to
+	// block devices by the unit, which requires IO accounting for it.
+	CurrentIOUsage(unit string) (read, write quantity.Size, err error)
 	// Run a command
 	Run(command []string, opts *RunOptions) ([]byte, error)
 	// Set log level for the system
@@ -888,6 +892,10 @@ func (s *systemd) getPropertyStringValue(unit, key string) (string, error) {
 
 var errNotSet = errors.New("property value is not available")
 
+// ErrIOUsageUnavailable is returned by CurrentIOUsage when io accounting is
+// not enabled for the unit.
+var ErrIOUsageUnavailable = errors.New("io usage unavailable")
+
 func (s *systemd) getPropertyUintValue(unit, key string) (uint64, error) {
 	valStr, err := s.getPropertyStringValue(unit, key)
 	if err != nil {
@@ -934,6 +942,23 @@ func (s *systemd) CurrentMemoryUsage(unit string) (quantity.Size, error) {
 	return quantity.Size(memBytes), nil
 }
 
+func (s *systemd) CurrentIOUsage(unit string) (read, write quantity.Size, err error) {
+	var usage [2]uint64
+	for i, key := range []string{"IOReadBytes", "IOWriteBytes"} {
+		usage[i], err = s.getPropertyUintValue(unit, key)
+		if err != nil && err != errNotSet {
+			return 0, 0, err
+		}
+		// without io accounting the value is not set or is reported as
+		// the maximum value
+		if err == errNotSet || usage[i] == math.MaxUint64 {
+			return 0, 0, ErrIOUsageUnavailable
+		}
+	}
+
+	return quantity.Size(usage[0]), quantity.Size(usage[1]), nil
+}
+
 func (s *systemd) InactiveEnterTimestamp(unit string) (time.Time, error) {
 	timeStr, err := s.getPropertyStringValue(unit, "InactiveEnterTimestamp")
 	if err != nil {
@@ -2619,6 +2619,36 @@ func (s *SystemdTestSuite) TestCurrentUsageFamilyHappy(c *C) {
 	})
 }
 
+func (s *SystemdTestSuite) TestCurrentIOUsage(c *C) {
+	s.outs = [][]byte{
+		[]byte(`IOReadBytes=4096`),
+		[]byte(`IOWriteBytes=1024`),
+		// without io accounting
+		[]byte(`IOReadBytes=18446744073709551615`),
+		[]byte(`IOReadBytes=[not set]`),
+		[]byte(`IOReadBytes=blah`),
+	}
+	sysd := New(SystemMode, s.rep)
+	read, write, err := sysd.CurrentIOUsage("bar.slice")
+	c.Assert(err, IsNil)
+	c.Check(read, Equals, 4*quantity.SizeKiB)
+	c.Check(write, Equals, quantity.SizeKiB)
+
+	_, _, err = sysd.CurrentIOUsage("bar.slice")
+	c.Check(err, Equals, ErrIOUsageUnavailable)
+	_, _, err = sysd.CurrentIOUsage("bar.slice")
+	c.Check(err, Equals, ErrIOUsageUnavailable)
+	_, _, err = sysd.CurrentIOUsage("bar.slice")
+	c.Check(err, ErrorMatches, `invalid property value from systemd for IOReadBytes: cannot parse "blah" as an integer`)
+	c.Check(s.argses, DeepEquals, [][]string{
+		{"show", "--property", "IOReadBytes", "bar.slice"},
+		{"show", "--property", "IOWriteBytes", "bar.slice"},
+		{"show", "--property", "IOReadBytes", "bar.slice"},
+		{"show", "--property", "IOReadBytes", "bar.slice"},
+		{"show", "--property", "IOReadBytes", "bar.slice"},
+	})
+}
+
 func (s *SystemdTestSuite) TestInactiveEnterTimestampZero(c *C) {
 	s.outs = [][]byte{
 		[]byte(`InactiveEnterTimestamp=`),
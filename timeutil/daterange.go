@@ -0,0 +1,162 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timeutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+const dateRangeToken = ".."
+
+// Date is a calendar day. The year is zero for days which recur every year.
+type Date struct {
+	Year  int
+	Month time.Month
+	Day   int
+}
+
+func (d Date) String() string {
+	if d.Year == 0 {
+		return fmt.Sprintf("%02d-%02d", d.Month, d.Day)
+	}
+	return fmt.Sprintf("%04d-%02d-%02d", d.Year, d.Month, d.Day)
+}
+
+// ord orders dates, ignoring the year of recurring ones.
+func (d Date) ord(withYear bool) int {
+	o := int(d.Month)*100 + d.Day
+	if withYear {
+		o += d.Year * 10000
+	}
+	return o
+}
+
+// DateRange is an inclusive range of days, such as "2026-11-20..2026-12-31",
+// or of days recurring every year, such as "12-20..01-05".
+type DateRange struct {
+	Start Date
+	End   Date
+}
+
+func (r DateRange) String() string {
+	if r.Start == r.End {
+		return r.Start.String()
+	}
+	return r.Start.String() + dateRangeToken + r.End.String()
+}
+
+// Yearly returns whether the range recurs every year.
+func (r DateRange) Yearly() bool {
+	return r.Start.Year == 0
+}
+
+// Includes returns whether the day of t, in its location, is inside the
+// range.
+func (r DateRange) Includes(t time.Time) bool {
+	y, m, d := t.Date()
+	day := Date{Year: y, Month: m, Day: d}
+	withYear := !r.Yearly()
+	start, end, o := r.Start.ord(withYear), r.End.ord(withYear), day.ord(withYear)
+	if start <= end {
+		return start <= o && o <= end
+	}
+	// a yearly range over the end of the year, eg. 12-20..01-05
+	return o >= start || o <= end
+}
+
+// DateRangesEnd returns when the days covered by the given ranges, which
+// include t, end. It returns false if t is not in any of the ranges.
+func DateRangesEnd(ranges []DateRange, t time.Time) (end time.Time, ok bool) {
+	includes := func(t time.Time) bool {
+		for _, r := range ranges {
+			if r.Includes(t) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !includes(t) {
+		return time.Time{}, false
+	}
+	end = sod(t)
+	// the ranges may cover the whole year, give up after that
+	for i := 0; i <= 366 && includes(end); i++ {
+		y, m, d := end.Date()
+		end = time.Date(y, m, d+1, 0, 0, 0, 0, end.Location())
+	}
+	return end, true
+}
+
+func parseDate(s string) (Date, error) {
+	yearly := strings.Count(s, "-") == 1
+	if yearly {
+		// use a leap year so that 02-29 is valid
+		s = "2000-" + s
+	}
+	t, err := time.Parse("2006-01-02", s)
+	if err != nil {
+		return Date{}, err
+	}
+	date := Date{Year: t.Year(), Month: t.Month(), Day: t.Day()}
+	if yearly {
+		date.Year = 0
+	}
+	return date, nil
+}
+
+// ParseDateRanges parses a comma-separated list of days or ranges of days,
+// such as "2026-11-20..2026-12-31,2027-01-01". Days without a year, such as
+// "12-20..01-05", recur every year.
+func ParseDateRanges(s string) ([]DateRange, error) {
+	var ranges []DateRange
+	for _, rs := range strings.Split(s, ",") {
+		rs = strings.TrimSpace(rs)
+		split := strings.Split(rs, dateRangeToken)
+		if rs == "" || len(split) > 2 {
+			return nil, fmt.Errorf("cannot parse %q: not a valid date range", rs)
+		}
+
+		var r DateRange
+		var err error
+		r.Start, err = parseDate(split[0])
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %q: %q is not a valid date", rs, split[0])
+		}
+		r.End = r.Start
+		if len(split) == 2 {
+			r.End, err = parseDate(split[1])
+			if err != nil {
+				return nil, fmt.Errorf("cannot parse %q: %q is not a valid date", rs, split[1])
+			}
+		}
+
+		if (r.Start.Year == 0) != (r.End.Year == 0) {
+			return nil, fmt.Errorf("cannot parse %q: cannot mix dates with and without a year", rs)
+		}
+		if !r.Yearly() && r.End.ord(true) < r.Start.ord(true) {
+			return nil, fmt.Errorf("cannot parse %q: range ends before it starts", rs)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
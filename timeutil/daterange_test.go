@@ -0,0 +1,117 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timeutil_test
+
+import (
+	"strings"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/timeutil"
+)
+
+type dateRangeSuite struct{}
+
+var _ = Suite(&dateRangeSuite{})
+
+func (s *dateRangeSuite) TestParseDateRanges(c *C) {
+	for _, t := range []struct {
+		in       string
+		expected string
+	}{
+		{"2026-11-20", "2026-11-20"},
+		{"2026-11-20..2026-12-31", "2026-11-20..2026-12-31"},
+		{"2026-11-20..2026-12-31, 2027-01-01", "2026-11-20..2026-12-31,2027-01-01"},
+		{"12-20..01-05", "12-20..01-05"},
+		{"02-29", "02-29"},
+	} {
+		ranges, err := timeutil.ParseDateRanges(t.in)
+		c.Assert(err, IsNil, Commentf("%q", t.in))
+		var strs []string
+		for _, r := range ranges {
+			strs = append(strs, r.String())
+		}
+		c.Check(strings.Join(strs, ","), Equals, t.expected)
+	}
+}
+
+func (s *dateRangeSuite) TestParseDateRangesErrors(c *C) {
+	for _, t := range []struct {
+		in  string
+		err string
+	}{
+		{"", `cannot parse "": not a valid date range`},
+		{"2026-11-20,", `cannot parse "": not a valid date range`},
+		{"2026-11-20..2026-11-22..2026-11-23", `cannot parse ".*": not a valid date range`},
+		{"2026-13-01", `cannot parse "2026-13-01": "2026-13-01" is not a valid date`},
+		{"2026-11-20..tomorrow", `cannot parse ".*": "tomorrow" is not a valid date`},
+		{"02-30", `cannot parse "02-30": "02-30" is not a valid date`},
+		{"2026-12-20..01-05", `cannot parse ".*": cannot mix dates with and without a year`},
+		{"2026-12-31..2026-12-01", `cannot parse ".*": range ends before it starts`},
+	} {
+		_, err := timeutil.ParseDateRanges(t.in)
+		c.Check(err, ErrorMatches, t.err, Commentf("%q", t.in))
+	}
+}
+
+func (s *dateRangeSuite) TestIncludes(c *C) {
+	ranges, err := timeutil.ParseDateRanges("2026-11-20..2026-12-01,12-24..01-02,07-04")
+	c.Assert(err, IsNil)
+
+	for _, t := range []struct {
+		day      string
+		expected []bool
+	}{
+		{"2026-11-19", []bool{false, false, false}},
+		{"2026-11-20", []bool{true, false, false}},
+		{"2026-12-01", []bool{true, false, false}},
+		{"2027-11-25", []bool{false, false, false}},
+		{"2026-12-24", []bool{false, true, false}},
+		{"2027-01-02", []bool{false, true, false}},
+		{"2027-01-03", []bool{false, false, false}},
+		{"2030-07-04", []bool{false, false, true}},
+	} {
+		day, err := time.ParseInLocation("2006-01-02 15:04", t.day+" 13:30", time.Local)
+		c.Assert(err, IsNil)
+		for i, r := range ranges {
+			c.Check(r.Includes(day), Equals, t.expected[i], Commentf("%s in %s", t.day, r))
+		}
+	}
+}
+
+func (s *dateRangeSuite) TestDateRangesEnd(c *C) {
+	ranges, err := timeutil.ParseDateRanges("2026-12-20..2026-12-24,12-25..01-01")
+	c.Assert(err, IsNil)
+
+	end, ok := timeutil.DateRangesEnd(ranges, time.Date(2026, 12, 22, 10, 0, 0, 0, time.UTC))
+	c.Check(ok, Equals, true)
+	c.Check(end, Equals, time.Date(2027, 1, 2, 0, 0, 0, 0, time.UTC))
+
+	_, ok = timeutil.DateRangesEnd(ranges, time.Date(2026, 12, 19, 23, 59, 0, 0, time.UTC))
+	c.Check(ok, Equals, false)
+
+	// ranges covering the whole year end eventually
+	ranges, err = timeutil.ParseDateRanges("01-01..12-31")
+	c.Assert(err, IsNil)
+	end, ok = timeutil.DateRangesEnd(ranges, time.Date(2026, 12, 22, 10, 0, 0, 0, time.UTC))
+	c.Check(ok, Equals, true)
+	c.Check(end.After(time.Date(2027, 12, 22, 0, 0, 0, 0, time.UTC)), Equals, true)
+}
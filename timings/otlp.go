@@ -0,0 +1,363 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timings
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/snapcore/snapd/logger"
+)
+
+// An Exporter is handed every Timings tree as it is saved. A GetSaver can
+// implement it to have the timings it stores also exported elsewhere.
+type Exporter interface {
+	ExportTimings(t *Timings)
+}
+
+// The types below follow the JSON encoding of the OTLP trace protocol, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding.
+
+type otlpTraces struct {
+	ResourceSpans []*otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource      `json:"resource"`
+	ScopeSpans []*otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope   `json:"scope"`
+	Spans []*otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              int             `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string       `json:"key"`
+	Value otlpAnyValue `json:"value"`
+}
+
+type otlpAnyValue struct {
+	StringValue string `json:"stringValue"`
+}
+
+// otlpSpanKindInternal is SPAN_KIND_INTERNAL
+const otlpSpanKindInternal = 1
+
+func unixNanoStr(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+// hexID returns an ID of the given size in bytes, hex encoded. A decimal id,
+// such as the ID of a change, is used as is, zero-padded, so that it can be
+// recognized in the exported traces, anything else is hashed.
+func hexID(size int, parts ...string) string {
+	if len(parts) == 1 && len(parts[0]) <= 2*size {
+		if _, err := strconv.ParseUint(parts[0], 10, 64); err == nil {
+			return strings.Repeat("0", 2*size-len(parts[0])) + parts[0]
+		}
+	}
+	sum := sha256.Sum256([]byte(strings.Join(parts, "\x00")))
+	return hex.EncodeToString(sum[:size])
+}
+
+func spanEnd(start, stop time.Time) time.Time {
+	// spans which were never stopped are exported as instantaneous
+	if stop.IsZero() {
+		return start
+	}
+	return stop
+}
+
+// otlpSpans returns the spans of the tree. The spans of a tree tagged with a
+// change-id belong to the trace with the change ID as trace ID, for those of
+// a task there is a root span for the task itself.
+func (t *Timings) otlpSpans() []*otlpSpan {
+	if len(t.timings) == 0 {
+		return nil
+	}
+
+	start := t.timings[0].start
+	var stop time.Time
+	for _, tm := range t.timings {
+		if end := spanEnd(tm.start, tm.stop); end.After(stop) {
+			stop = end
+		}
+	}
+
+	var traceID string
+	if changeID := t.tags["change-id"]; changeID != "" {
+		traceID = hexID(16, changeID)
+	} else {
+		traceID = hexID(16, "snapd", unixNanoStr(start))
+	}
+
+	var name string
+	switch {
+	case t.tags["task-kind"] != "":
+		name = t.tags["task-kind"]
+	case t.tags["ensure"] != "":
+		name = "ensure " + t.tags["ensure"]
+	case t.tags["startup"] != "":
+		name = "startup " + t.tags["startup"]
+	default:
+		name = "snapd"
+	}
+
+	tags := make([]string, 0, len(t.tags))
+	for tag := range t.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	attrs := make([]otlpAttribute, 0, len(tags))
+	for _, tag := range tags {
+		attrs = append(attrs, otlpAttribute{
+			Key:   "snapd." + tag,
+			Value: otlpAnyValue{StringValue: t.tags[tag]},
+		})
+	}
+
+	root := &otlpSpan{
+		TraceID:           traceID,
+		SpanID:            hexID(8, traceID, t.tags["task-id"], name, unixNanoStr(start)),
+		Name:              name,
+		Kind:              otlpSpanKindInternal,
+		StartTimeUnixNano: unixNanoStr(start),
+		EndTimeUnixNano:   unixNanoStr(stop),
+		Attributes:        attrs,
+	}
+	spans := []*otlpSpan{root}
+	return appendOTLPSpans(spans, root, t.timings)
+}
+
+func appendOTLPSpans(spans []*otlpSpan, parent *otlpSpan, timings []*Span) []*otlpSpan {
+	for i, tm := range timings {
+		span := &otlpSpan{
+			TraceID:           parent.TraceID,
+			SpanID:            hexID(8, parent.SpanID, strconv.Itoa(i), tm.label),
+			ParentSpanID:      parent.SpanID,
+			Name:              tm.label,
+			Kind:              otlpSpanKindInternal,
+			StartTimeUnixNano: unixNanoStr(tm.start),
+			EndTimeUnixNano:   unixNanoStr(spanEnd(tm.start, tm.stop)),
+		}
+		if tm.summary != "" {
+			span.Attributes = []otlpAttribute{{
+				Key:   "snapd.summary",
+				Value: otlpAnyValue{StringValue: tm.summary},
+			}}
+		}
+		spans = append(spans, span)
+		spans = appendOTLPSpans(spans, span, tm.timings)
+	}
+	return spans
+}
+
+func encodeOTLPTraces(spans []*otlpSpan) ([]byte, error) {
+	traces := otlpTraces{
+		ResourceSpans: []*otlpResourceSpans{{
+			Resource: otlpResource{
+				Attributes: []otlpAttribute{{
+					Key:   "service.name",
+					Value: otlpAnyValue{StringValue: "snapd"},
+				}},
+			},
+			ScopeSpans: []*otlpScopeSpans{{
+				Scope: otlpScope{Name: "github.com/snapcore/snapd/timings"},
+				Spans: spans,
+			}},
+		}},
+	}
+	return json.Marshal(traces)
+}
+
+// OTLPTracesURL returns the URL traces are sent to for the given collector
+// endpoint. As for the OTLP exporters of the OpenTelemetry SDKs, the
+// /v1/traces path is used unless the endpoint has a path of its own.
+func OTLPTracesURL(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("unsupported scheme %q, expected http or https", u.Scheme)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("missing host")
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/v1/traces"
+	}
+	return u.String(), nil
+}
+
+var (
+	otlpExportTimeout   = 5 * time.Second
+	otlpMaxPendingSpans = 10000
+)
+
+type otlpBatch struct {
+	url   string
+	spans []*otlpSpan
+}
+
+// OTLPExporter sends Timings trees as OTLP traces, encoded as JSON, to an
+// OpenTelemetry collector over HTTP. Sending happens in the background so
+// that exporting does not block; trees are dropped if the collector cannot
+// keep up or cannot be reached.
+type OTLPExporter struct {
+	client *http.Client
+
+	mu       sync.Mutex
+	pending  []*otlpBatch
+	npending int
+	wakeup   chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// NewOTLPExporter returns a new OTLPExporter.
+func NewOTLPExporter() *OTLPExporter {
+	return &OTLPExporter{
+		client: &http.Client{Timeout: otlpExportTimeout},
+		wakeup: make(chan struct{}, 1),
+	}
+}
+
+// Export queues the spans of the Timings tree to be sent to the collector at
+// the given endpoint.
+func (e *OTLPExporter) Export(endpoint string, t *Timings) {
+	u, err := OTLPTracesURL(endpoint)
+	if err != nil {
+		logger.Debugf("cannot export timings to %q: %v", endpoint, err)
+		return
+	}
+	spans := t.otlpSpans()
+	if len(spans) == 0 {
+		return
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.npending+len(spans) > otlpMaxPendingSpans {
+		logger.Debugf("cannot export timings to %q: too many pending spans", endpoint)
+		return
+	}
+	if e.stop == nil {
+		e.stop = make(chan struct{})
+		e.done = make(chan struct{})
+		go e.loop(e.stop, e.done)
+	}
+	if n := len(e.pending); n > 0 && e.pending[n-1].url == u {
+		e.pending[n-1].spans = append(e.pending[n-1].spans, spans...)
+	} else {
+		e.pending = append(e.pending, &otlpBatch{url: u, spans: spans})
+	}
+	e.npending += len(spans)
+	select {
+	case e.wakeup <- struct{}{}:
+	default:
+	}
+}
+
+// Stop stops sending traces, dropping those which were not sent yet.
+func (e *OTLPExporter) Stop() {
+	e.mu.Lock()
+	stop, done := e.stop, e.done
+	e.stop, e.done = nil, nil
+	e.pending = nil
+	e.npending = 0
+	e.mu.Unlock()
+
+	if stop != nil {
+		close(stop)
+		<-done
+	}
+}
+
+func (e *OTLPExporter) loop(stop, done chan struct{}) {
+	defer close(done)
+	for {
+		select {
+		case <-stop:
+			return
+		case <-e.wakeup:
+		}
+
+		e.mu.Lock()
+		pending := e.pending
+		e.pending = nil
+		e.npending = 0
+		e.mu.Unlock()
+
+		for _, batch := range pending {
+			if err := e.send(batch); err != nil {
+				logger.Debugf("cannot export timings to %q: %v", batch.url, err)
+			}
+		}
+	}
+}
+
+func (e *OTLPExporter) send(batch *otlpBatch) error {
+	data, err := encodeOTLPTraces(batch.spans)
+	if err != nil {
+		return err
+	}
+	resp, err := e.client.Post(batch.url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,209 @@
+// -*- Mode: Go; indent-tabs-mode: t -*-
+
+/*
+ * Copyright (C) 2026 Canonical Ltd
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU General Public License version 3 as
+ * published by the Free Software Foundation.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU General Public License for more details.
+ *
+ * You should have received a copy of the GNU General Public License
+ * along with this program.  If not, see <http://www.gnu.org/licenses/>.
+ *
+ */
+
+package timings_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"time"
+
+	. "gopkg.in/check.v1"
+
+	"github.com/snapcore/snapd/overlord/state"
+	"github.com/snapcore/snapd/testutil"
+	"github.com/snapcore/snapd/timings"
+)
+
+type otlpSuite struct {
+	testutil.BaseTest
+
+	requests chan map[string]any
+	server   *httptest.Server
+}
+
+var _ = Suite(&otlpSuite{})
+
+func (s *otlpSuite) SetUpTest(c *C) {
+	s.BaseTest.SetUpTest(c)
+
+	fakeTime := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	s.AddCleanup(timings.MockTimeNow(func() time.Time {
+		fakeTime = fakeTime.Add(time.Millisecond)
+		return fakeTime
+	}))
+
+	s.requests = make(chan map[string]any, 10)
+	s.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.Check(r.Method, Equals, "POST")
+		c.Check(r.URL.Path, Equals, "/v1/traces")
+		c.Check(r.Header.Get("Content-Type"), Equals, "application/json")
+		var req map[string]any
+		c.Check(json.NewDecoder(r.Body).Decode(&req), IsNil)
+		s.requests <- req
+	}))
+	s.AddCleanup(s.server.Close)
+}
+
+func (s *otlpSuite) nextSpans(c *C) []any {
+	select {
+	case req := <-s.requests:
+		resourceSpans := req["resourceSpans"].([]any)
+		c.Assert(resourceSpans, HasLen, 1)
+		rs := resourceSpans[0].(map[string]any)
+		c.Check(rs["resource"], DeepEquals, map[string]any{
+			"attributes": []any{map[string]any{
+				"key":   "service.name",
+				"value": map[string]any{"stringValue": "snapd"},
+			}},
+		})
+		scopeSpans := rs["scopeSpans"].([]any)
+		c.Assert(scopeSpans, HasLen, 1)
+		return scopeSpans[0].(map[string]any)["spans"].([]any)
+	case <-time.After(10 * time.Second):
+		c.Fatal("timings were not exported")
+	}
+	return nil
+}
+
+func (s *otlpSuite) TestExportTaskTimings(c *C) {
+	e := timings.NewOTLPExporter()
+	defer e.Stop()
+
+	troot := timings.New(map[string]string{"change-id": "12", "task-id": "34", "task-kind": "download-snap"})
+	span := troot.StartSpan("download", "download snap")
+	nested := span.StartSpan("verify", "")
+	nested.Stop()
+	span.Stop()
+
+	e.Export(s.server.URL, troot)
+
+	spans := s.nextSpans(c)
+	c.Assert(spans, HasLen, 3)
+	root := spans[0].(map[string]any)
+	c.Check(root["traceId"], Equals, "00000000000000000000000000000012")
+	c.Check(root["name"], Equals, "download-snap")
+	c.Check(root["parentSpanId"], IsNil)
+	c.Check(root["startTimeUnixNano"], Equals, "1790856000001000000")
+	c.Check(root["endTimeUnixNano"], Equals, "1790856000004000000")
+	c.Check(root["attributes"], DeepEquals, []any{
+		map[string]any{"key": "snapd.change-id", "value": map[string]any{"stringValue": "12"}},
+		map[string]any{"key": "snapd.task-id", "value": map[string]any{"stringValue": "34"}},
+		map[string]any{"key": "snapd.task-kind", "value": map[string]any{"stringValue": "download-snap"}},
+	})
+
+	download := spans[1].(map[string]any)
+	c.Check(download["traceId"], Equals, root["traceId"])
+	c.Check(download["parentSpanId"], Equals, root["spanId"])
+	c.Check(download["name"], Equals, "download")
+	c.Check(download["attributes"], DeepEquals, []any{
+		map[string]any{"key": "snapd.summary", "value": map[string]any{"stringValue": "download snap"}},
+	})
+	c.Check(download["startTimeUnixNano"], Equals, "1790856000001000000")
+	c.Check(download["endTimeUnixNano"], Equals, "1790856000004000000")
+
+	verify := spans[2].(map[string]any)
+	c.Check(verify["parentSpanId"], Equals, download["spanId"])
+	c.Check(verify["name"], Equals, "verify")
+	c.Check(verify["attributes"], IsNil)
+	c.Check(verify["startTimeUnixNano"], Equals, "1790856000002000000")
+	c.Check(verify["endTimeUnixNano"], Equals, "1790856000003000000")
+
+	for _, span := range spans {
+		c.Check(span.(map[string]any)["spanId"], Matches, "[0-9a-f]{16}")
+	}
+}
+
+func (s *otlpSuite) TestExportEnsureTimings(c *C) {
+	e := timings.NewOTLPExporter()
+	defer e.Stop()
+
+	troot := timings.New(map[string]string{"ensure": "auto-refresh"})
+	timings.Run(troot, "refresh-candidates", "query the store", func(timings.Measurer) {})
+
+	// the path is added to the endpoint
+	e.Export(s.server.URL+"/", troot)
+
+	spans := s.nextSpans(c)
+	c.Assert(spans, HasLen, 2)
+	root := spans[0].(map[string]any)
+	c.Check(root["name"], Equals, "ensure auto-refresh")
+	c.Check(root["traceId"], Matches, "[0-9a-f]{32}")
+	c.Check(spans[1].(map[string]any)["name"], Equals, "refresh-candidates")
+}
+
+func (s *otlpSuite) TestExportFromState(c *C) {
+	e := timings.NewOTLPExporter()
+	defer e.Stop()
+
+	st := state.New(nil)
+	st.Lock()
+	defer st.Unlock()
+	st.SetTimingsExporter(func(t *timings.Timings) {
+		e.Export(s.server.URL, t)
+	})
+
+	troot := timings.New(map[string]string{"change-id": "1", "task-id": "2", "task-kind": "kind"})
+	timings.Run(troot, "label", "summary", func(timings.Measurer) {})
+	troot.Save(st)
+
+	spans := s.nextSpans(c)
+	c.Assert(spans, HasLen, 2)
+	c.Check(spans[0].(map[string]any)["name"], Equals, "kind")
+
+	// timings are still saved into the state
+	var stateTimings []any
+	c.Assert(st.Get("timings", &stateTimings), IsNil)
+	c.Check(stateTimings, HasLen, 1)
+}
+
+func (s *otlpSuite) TestExportNothing(c *C) {
+	e := timings.NewOTLPExporter()
+	defer e.Stop()
+
+	e.Export(s.server.URL, timings.New(map[string]string{"ensure": "seed"}))
+	e.Export("not-a-url", timings.New(nil))
+
+	select {
+	case <-s.requests:
+		c.Fatal("unexpected export")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func (s *otlpSuite) TestOTLPTracesURL(c *C) {
+	for _, tc := range []struct {
+		endpoint, url, err string
+	}{
+		{"http://localhost:4318", "http://localhost:4318/v1/traces", ""},
+		{"http://localhost:4318/", "http://localhost:4318/v1/traces", ""},
+		{"https://collector/custom", "https://collector/custom", ""},
+		{"localhost:4318", "", `unsupported scheme "localhost", expected http or https`},
+		{"http:///path", "", "missing host"},
+	} {
+		u, err := timings.OTLPTracesURL(tc.endpoint)
+		if tc.err != "" {
+			c.Check(err, ErrorMatches, tc.err)
+			continue
+		}
+		c.Assert(err, IsNil)
+		c.Check(u, Equals, tc.url)
+	}
+}
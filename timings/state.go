@@ -129,8 +129,13 @@ type GetSaver interface {
 // are kept. Timings are only stored if their duration is greater than
 // or equal to DurationThreshold.  If GetSaver is a state.State, it's
 // responsibility of the caller to lock the state before calling this
-// function.
+// function. If GetSaver is also an Exporter, the Timings are first
+// handed to it, regardless of their duration.
 func (t *Timings) Save(s GetSaver) {
+	if e, ok := s.(Exporter); ok {
+		e.ExportTimings(t)
+	}
+
 	var stateTimings []*json.RawMessage
 	if err := s.GetMaybeTimings(&stateTimings); err != nil {
 		logger.Noticef("could not get timings data from the state: %v", err)
@@ -70,10 +70,20 @@ MemoryAccounting=true
 		valuesTemplate := `MemoryMax=%[1]d
 # for compatibility with older versions of systemd
 MemoryLimit=%[1]d
-
 `
 		fmt.Fprintf(buf, valuesTemplate, grp.MemoryLimit)
 	}
+	// The MemoryHigh and MemorySwapMax settings are only available since
+	// systemd 231 and 232 respectively, and require cgroup v2
+	if grp.MemoryHighLimit != 0 {
+		fmt.Fprintf(buf, "MemoryHigh=%d\n", grp.MemoryHighLimit)
+	}
+	if grp.MemorySwapLimit != 0 {
+		fmt.Fprintf(buf, "MemorySwapMax=%d\n", grp.MemorySwapLimit)
+	}
+	if grp.MemoryLimit != 0 || grp.MemoryHighLimit != 0 || grp.MemorySwapLimit != 0 {
+		buf.WriteString("\n")
+	}
 	return buf.String()
 }
 
@@ -90,6 +100,36 @@ TasksAccounting=true
 	return buf.String()
 }
 
+func formatIOGroupSlice(grp *quota.Group) string {
+	if len(grp.IOLimit) == 0 {
+		return ""
+	}
+
+	header := `
+# Enable io accounting, so the io usage of the slice can be reported
+IOAccounting=true
+`
+	buf := bytes.NewBufferString(header)
+
+	// The IO*Max settings are only available since systemd 230 and map to
+	// io.max of the cgroup v2 io controller
+	for _, dev := range grp.IOLimit {
+		if dev.ReadBps != 0 {
+			fmt.Fprintf(buf, "IOReadBandwidthMax=%s %d\n", dev.Device, dev.ReadBps)
+		}
+		if dev.WriteBps != 0 {
+			fmt.Fprintf(buf, "IOWriteBandwidthMax=%s %d\n", dev.Device, dev.WriteBps)
+		}
+		if dev.ReadIOPS != 0 {
+			fmt.Fprintf(buf, "IOReadIOPSMax=%s %d\n", dev.Device, dev.ReadIOPS)
+		}
+		if dev.WriteIOPS != 0 {
+			fmt.Fprintf(buf, "IOWriteIOPSMax=%s %d\n", dev.Device, dev.WriteIOPS)
+		}
+	}
+	return buf.String()
+}
+
 // GenerateQuotaSliceUnitFile generates a systemd slice unit definition for the
 // specified quota group.
 func GenerateQuotaSliceUnitFile(grp *quota.Group) []byte {
@@ -98,6 +138,7 @@ func GenerateQuotaSliceUnitFile(grp *quota.Group) []byte {
 	cpuOptions := formatCpuGroupSlice(grp)
 	memoryOptions := formatMemoryGroupSlice(grp)
 	taskOptions := formatTaskGroupSlice(grp)
+	ioOptions := formatIOGroupSlice(grp)
 	template := `[Unit]
 Description=Slice for snap quota group %[1]s
 Before=slices.target
@@ -107,6 +148,6 @@ X-Snappy=yes
 `
 
 	fmt.Fprintf(&buf, template, grp.Name)
-	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions)
+	fmt.Fprint(&buf, cpuOptions, memoryOptions, taskOptions, ioOptions)
 	return buf.Bytes()
 }